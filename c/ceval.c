@@ -1,7 +1,13 @@
 // CLI wrapper for differential testing.
 // Usage:
-//   ceval eval <hex_code> <hex_msg>     → prints "ok:<hex_stack>" or "error"
-//   ceval m001 <hex_xpubkey> <hex_xsig> <hex_msg> → prints "0" or "1"
+//   ceval eval <hex_code> <hex_msg> [options]     → prints "ok:<hex_stack>" or "error"
+//   ceval m001 <hex_xpubkey> <hex_xsig> <hex_msg> [options] → prints "0" or "1"
+// Options set context fields:
+//   secver=<n>   security version (default 0)
+//   revoked=<hex> concatenated 32-byte fingerprints of revoked keys
+//   nonce=<hex>  verifier nonce (default none)
+//   prehashed=1  prehashed mode: the message is ignored
+//   digest=<alg>:<hex> prehashed mode with this HASH_* digest (repeatable)
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
@@ -30,6 +36,51 @@ static int hex_to_bytes(const char *hex, uint8_t *out, size_t out_cap, size_t *o
     return 0;
 }
 
+static uint8_t revoked_buf[65536];
+static size_t revoked_len;
+static uint8_t nonce_buf[65536];
+static uint8_t digest_buf[HASH_MAX_ALG + 1][HASH_MAX_DIGEST_LEN];
+
+static int is_revoked(const uint8_t fingerprint[32], void *arg) {
+    (void)arg;
+    for (size_t i = 0; i + 32 <= revoked_len; i += 32) {
+        if (memcmp(revoked_buf + i, fingerprint, 32) == 0) return 1;
+    }
+    return 0;
+}
+
+// Parse trailing key=value options into ctx. Returns 0 on success.
+static int parse_options(int argc, char *argv[], eval_ctx_t *ctx) {
+    for (int i = 0; i < argc; i++) {
+        if (strncmp(argv[i], "secver=", 7) == 0) {
+            char *end;
+            ctx->security_version = strtoll(argv[i] + 7, &end, 10);
+            if (*end != '\0') return -1;
+        } else if (strncmp(argv[i], "revoked=", 8) == 0) {
+            if (hex_to_bytes(argv[i] + 8, revoked_buf, sizeof(revoked_buf), &revoked_len) != 0) return -1;
+            if (revoked_len % 32 != 0) return -1;
+            ctx->is_revoked = is_revoked;
+        } else if (strncmp(argv[i], "nonce=", 6) == 0) {
+            if (hex_to_bytes(argv[i] + 6, nonce_buf, sizeof(nonce_buf), &ctx->nonce_len) != 0) return -1;
+            ctx->nonce = nonce_buf;
+        } else if (strcmp(argv[i], "prehashed=1") == 0) {
+            ctx->prehashed = 1;
+        } else if (strncmp(argv[i], "digest=", 7) == 0) {
+            char *end;
+            long alg = strtol(argv[i] + 7, &end, 10);
+            if (*end != ':' || alg < 1 || alg > HASH_MAX_ALG) return -1;
+            size_t len;
+            if (hex_to_bytes(end + 1, digest_buf[alg], sizeof(digest_buf[alg]), &len) != 0) return -1;
+            if (len != hash_size((uint8_t)alg)) return -1;
+            ctx->prehashed = 1;
+            ctx->digests[alg] = digest_buf[alg];
+        } else {
+            return -1;
+        }
+    }
+    return 0;
+}
+
 int main(int argc, char *argv[]) {
     if (argc < 2) {
         fprintf(stderr, "usage: ceval eval|m001 ...\n");
@@ -41,8 +92,8 @@ int main(int argc, char *argv[]) {
     size_t len1, len2, len3;
 
     if (strcmp(argv[1], "eval") == 0) {
-        if (argc != 4) {
-            fprintf(stderr, "usage: ceval eval <hex_code> <hex_msg>\n");
+        if (argc < 4) {
+            fprintf(stderr, "usage: ceval eval <hex_code> <hex_msg> [options]\n");
             return 1;
         }
         if (hex_to_bytes(argv[2], buf1, sizeof(buf1), &len1) != 0 ||
@@ -50,10 +101,18 @@ int main(int argc, char *argv[]) {
             fprintf(stderr, "bad hex\n");
             return 1;
         }
+        eval_ctx_t ctx;
+        memset(&ctx, 0, sizeof(ctx));
+        ctx.msg = buf2;
+        ctx.msg_len = len2;
+        if (parse_options(argc - 4, argv + 4, &ctx) != 0) {
+            fprintf(stderr, "bad option\n");
+            return 1;
+        }
 
         eval_t e;
         eval_init(&e);
-        int ret = eval_with_xmsg(&e, buf1, len1, buf2, len2);
+        int ret = eval_with_ctx(&e, buf1, len1, &ctx);
 
         if (ret != 0) {
             printf("error\n");
@@ -67,8 +126,8 @@ int main(int argc, char *argv[]) {
     }
 
     if (strcmp(argv[1], "m001") == 0) {
-        if (argc != 5) {
-            fprintf(stderr, "usage: ceval m001 <hex_xpubkey> <hex_xsig> <hex_msg>\n");
+        if (argc < 5) {
+            fprintf(stderr, "usage: ceval m001 <hex_xpubkey> <hex_xsig> <hex_msg> [options]\n");
             return 1;
         }
         if (hex_to_bytes(argv[2], buf1, sizeof(buf1), &len1) != 0 ||
@@ -77,8 +136,16 @@ int main(int argc, char *argv[]) {
             fprintf(stderr, "bad hex\n");
             return 1;
         }
+        eval_ctx_t ctx;
+        memset(&ctx, 0, sizeof(ctx));
+        ctx.msg = buf3;
+        ctx.msg_len = len3;
+        if (parse_options(argc - 5, argv + 5, &ctx) != 0) {
+            fprintf(stderr, "bad option\n");
+            return 1;
+        }
 
-        int result = run_machine001(buf1, len1, buf2, len2, buf3, len3);
+        int result = run_machine001_ctx(buf1, len1, buf2, len2, &ctx);
         printf("%d\n", result);
         return 0;
     }
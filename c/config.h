@@ -0,0 +1,26 @@
+#pragma once
+
+// Compile-time limits of the C verifier. The defaults match the Go
+// implementation. A verifier short of RAM can lower them with -D, and then
+// rejects programs that need more; -DXSIG_EMBEDDED picks the limits of the
+// embedded profile (see "Embedded profile" in the README).
+
+#ifdef XSIG_EMBEDDED
+#ifndef XSIG_MAX_STACK_SIZE
+#define XSIG_MAX_STACK_SIZE 512
+#endif
+#ifndef XSIG_MAX_KEYS
+#define XSIG_MAX_KEYS 8
+#endif
+#endif
+
+// Bytes on the evaluation stack.
+#ifndef XSIG_MAX_STACK_SIZE
+#define XSIG_MAX_STACK_SIZE 1024
+#endif
+
+// Public keys in one OP_MULTISIGVERIFY (and its variants),
+// OP_WEIGHTEDSIGVERIFY or OP_MIXEDMULTISIGVERIFY, at most 255.
+#ifndef XSIG_MAX_KEYS
+#define XSIG_MAX_KEYS 255
+#endif
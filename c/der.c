@@ -1,9 +1,10 @@
 #include "der.h"
 #include <string.h>
 
-// Parse one DER integer from buf at offset *pos, write 32-byte zero-padded
-// big-endian integer to out. Returns 0 on success.
-static int parse_der_integer(const uint8_t *buf, size_t buf_len, size_t *pos, uint8_t *out) {
+// Parse one DER integer from buf at offset *pos, write out_len-byte
+// zero-padded big-endian integer to out. Returns 0 on success.
+static int parse_der_integer(const uint8_t *buf, size_t buf_len, size_t *pos, uint8_t *out,
+                             size_t out_len) {
     if (*pos >= buf_len) return -1;
     if (buf[*pos] != 0x02) return -1; // not INTEGER tag
     (*pos)++;
@@ -23,17 +24,21 @@ static int parse_der_integer(const uint8_t *buf, size_t buf_len, size_t *pos, ui
         data_len--;
     }
 
-    if (data_len > 32) return -1; // integer too large for P256
+    if (data_len > out_len) return -1; // integer too large for the curve
 
-    // Right-align into 32-byte output, zero-pad left
-    memset(out, 0, 32);
-    memcpy(out + 32 - data_len, int_data, data_len);
+    // Right-align into out_len-byte output, zero-pad left
+    memset(out, 0, out_len);
+    memcpy(out + out_len - data_len, int_data, data_len);
 
     *pos += int_len;
     return 0;
 }
 
 int der_to_raw(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out) {
+    return der_to_raw_size(der_sig, der_len, raw_out, 32);
+}
+
+int der_to_raw_size(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out, size_t int_len) {
     if (der_len < 6) return -1; // minimum: 30 len 02 01 r 02 01 s = 8 bytes, but at least 6
 
     size_t pos = 0;
@@ -49,12 +54,12 @@ int der_to_raw(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out) {
     if (pos + seq_len > der_len) return -1;
 
     // Parse r
-    if (parse_der_integer(der_sig, der_len, &pos, raw_out) != 0) {
+    if (parse_der_integer(der_sig, der_len, &pos, raw_out, int_len) != 0) {
         return -1;
     }
 
     // Parse s
-    if (parse_der_integer(der_sig, der_len, &pos, raw_out + 32) != 0) {
+    if (parse_der_integer(der_sig, der_len, &pos, raw_out + int_len, int_len) != 0) {
         return -1;
     }
 
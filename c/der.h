@@ -8,4 +8,21 @@
 // der_len: length of DER signature
 // raw_out: output buffer, must be at least 64 bytes
 // Returns 0 on success, nonzero on error.
+#ifndef XSIG_NO_DER
 int der_to_raw(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out);
+// der_to_raw for another curve size: r and s are int_len bytes each, and
+// raw_out must hold 2 * int_len bytes.
+int der_to_raw_size(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out, size_t int_len);
+#else
+// Built without the DER parser (make ceval_noder): every DER signature is
+// treated as malformed, only the raw r||s opcodes can validate.
+static inline int der_to_raw(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out) {
+    (void)der_sig; (void)der_len; (void)raw_out;
+    return -1;
+}
+static inline int der_to_raw_size(const uint8_t *der_sig, size_t der_len, uint8_t *raw_out,
+                                  size_t int_len) {
+    (void)der_sig; (void)der_len; (void)raw_out; (void)int_len;
+    return -1;
+}
+#endif
@@ -0,0 +1,308 @@
+#include "ed25519.h"
+#include "mem.h"
+#include <string.h>
+
+// Field elements mod p = 2^255 - 19 as 16 signed limbs of 16 bits, least
+// significant first, and points in extended coordinates (X, Y, Z, T), in the
+// style of TweetNaCl. Not constant time: verification only handles public
+// data.
+
+typedef int64_t gf[16];
+
+static const gf gf0 = {0};
+static const gf gf1 = {1};
+static const gf D = {0x78a3, 0x1359, 0x4dca, 0x75eb, 0xd8ab, 0x4141, 0x0a4d, 0x0070, 0xe898, 0x7779, 0x4079, 0x8cc7, 0xfe73, 0x2b6f, 0x6cee, 0x5203};
+static const gf D2 = {0xf159, 0x26b2, 0x9b94, 0xebd6, 0xb156, 0x8283, 0x149a, 0x00e0, 0xd130, 0xeef3, 0x80f2, 0x198e, 0xfce7, 0x56df, 0xd9dc, 0x2406};
+static const gf X = {0xd51a, 0x8f25, 0x2d60, 0xc956, 0xa7b2, 0x9525, 0xc760, 0x692c, 0xdc5c, 0xfdd6, 0xe231, 0xc0a4, 0x53fe, 0xcd6e, 0x36d3, 0x2169};
+static const gf Y = {0x6658, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666};
+static const gf I = {0xa0b0, 0x4a0e, 0x1b27, 0xc4ee, 0xe478, 0xad2f, 0x1806, 0x2f43, 0xd7a7, 0x3dfb, 0x0099, 0x2b4d, 0xdf0b, 0x4fc1, 0x2480, 0x2b83};
+
+// The group order, little-endian.
+static const uint8_t L[32] = {
+    0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58, 0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+    0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+};
+
+static void set(gf r, const gf a) {
+    for (int i = 0; i < 16; i++) r[i] = a[i];
+}
+
+static void carry(gf o) {
+    for (int i = 0; i < 16; i++) {
+        o[i] += (int64_t)1 << 16;
+        int64_t c = o[i] >> 16;
+        if (i < 15) {
+            o[i + 1] += c - 1;
+        } else {
+            o[0] += 38 * (c - 1);
+        }
+        o[i] -= c * ((int64_t)1 << 16);
+    }
+}
+
+// Swaps p and q if b is 1.
+static void swap(gf p, gf q, int b) {
+    int64_t mask = ~((int64_t)b - 1);
+    for (int i = 0; i < 16; i++) {
+        int64_t t = mask & (p[i] ^ q[i]);
+        p[i] ^= t;
+        q[i] ^= t;
+    }
+}
+
+// Canonical little-endian encoding.
+static void pack(uint8_t o[32], const gf n) {
+    gf m, t;
+    set(t, n);
+    carry(t);
+    carry(t);
+    carry(t);
+    for (int j = 0; j < 2; j++) {
+        m[0] = t[0] - 0xffed;
+        for (int i = 1; i < 15; i++) {
+            m[i] = t[i] - 0xffff - ((m[i - 1] >> 16) & 1);
+            m[i - 1] &= 0xffff;
+        }
+        m[15] = t[15] - 0x7fff - ((m[14] >> 16) & 1);
+        int b = (int)((m[15] >> 16) & 1);
+        m[14] &= 0xffff;
+        swap(t, m, 1 - b);
+    }
+    for (int i = 0; i < 16; i++) {
+        o[2 * i] = (uint8_t)(t[i] & 0xff);
+        o[2 * i + 1] = (uint8_t)(t[i] >> 8);
+    }
+}
+
+static int equal(const gf a, const gf b) {
+    uint8_t c[32], d[32];
+    pack(c, a);
+    pack(d, b);
+    return mem_cmp(c, d, 32) == 0;
+}
+
+static int parity(const gf a) {
+    uint8_t d[32];
+    pack(d, a);
+    return d[0] & 1;
+}
+
+// Decodes a field element, ignoring the top bit. Values from p to 2^255-1
+// are accepted and taken mod p.
+static void unpack(gf o, const uint8_t n[32]) {
+    for (int i = 0; i < 16; i++) o[i] = n[2 * i] + ((int64_t)n[2 * i + 1] << 8);
+    o[15] &= 0x7fff;
+}
+
+static void add(gf o, const gf a, const gf b) {
+    for (int i = 0; i < 16; i++) o[i] = a[i] + b[i];
+}
+
+static void sub(gf o, const gf a, const gf b) {
+    for (int i = 0; i < 16; i++) o[i] = a[i] - b[i];
+}
+
+static void mul(gf o, const gf a, const gf b) {
+    int64_t t[31] = {0};
+    for (int i = 0; i < 16; i++) {
+        for (int j = 0; j < 16; j++) t[i + j] += a[i] * b[j];
+    }
+    for (int i = 0; i < 15; i++) t[i] += 38 * t[i + 16];
+    for (int i = 0; i < 16; i++) o[i] = t[i];
+    carry(o);
+    carry(o);
+}
+
+static void square(gf o, const gf a) {
+    mul(o, a, a);
+}
+
+// o = i^(p-2) = 1/i
+static void invert(gf o, const gf i) {
+    gf c;
+    set(c, i);
+    for (int a = 253; a >= 0; a--) {
+        square(c, c);
+        if (a != 2 && a != 4) mul(c, c, i);
+    }
+    set(o, c);
+}
+
+// o = i^((p-5)/8) = i^(2^252-3)
+static void pow2523(gf o, const gf i) {
+    gf c;
+    set(c, i);
+    for (int a = 250; a >= 0; a--) {
+        square(c, c);
+        if (a != 1) mul(c, c, i);
+    }
+    set(o, c);
+}
+
+// p += q
+static void point_add(gf p[4], gf q[4]) {
+    gf a, b, c, d, t, e, f, g, h;
+    sub(a, p[1], p[0]);
+    sub(t, q[1], q[0]);
+    mul(a, a, t);
+    add(b, p[0], p[1]);
+    add(t, q[0], q[1]);
+    mul(b, b, t);
+    mul(c, p[3], q[3]);
+    mul(c, c, D2);
+    mul(d, p[2], q[2]);
+    add(d, d, d);
+    sub(e, b, a);
+    sub(f, d, c);
+    add(g, d, c);
+    add(h, b, a);
+    mul(p[0], e, f);
+    mul(p[1], h, g);
+    mul(p[2], g, f);
+    mul(p[3], e, h);
+}
+
+static void point_swap(gf p[4], gf q[4], int b) {
+    for (int i = 0; i < 4; i++) swap(p[i], q[i], b);
+}
+
+static void point_pack(uint8_t r[32], gf p[4]) {
+    gf tx, ty, zi;
+    invert(zi, p[2]);
+    mul(tx, p[0], zi);
+    mul(ty, p[1], zi);
+    pack(r, ty);
+    r[31] ^= (uint8_t)(parity(tx) << 7);
+}
+
+// p = [s]q, for a little-endian 32-byte s. Clobbers q.
+static void scalarmult(gf p[4], gf q[4], const uint8_t s[32]) {
+    set(p[0], gf0);
+    set(p[1], gf1);
+    set(p[2], gf1);
+    set(p[3], gf0);
+    for (int i = 255; i >= 0; i--) {
+        int b = (s[i / 8] >> (i & 7)) & 1;
+        point_swap(p, q, b);
+        point_add(q, p);
+        point_add(p, p);
+        point_swap(p, q, b);
+    }
+}
+
+static void scalarbase(gf p[4], const uint8_t s[32]) {
+    gf q[4];
+    set(q[0], X);
+    set(q[1], Y);
+    set(q[2], gf1);
+    mul(q[3], X, Y);
+    scalarmult(p, q, s);
+}
+
+// r = -A for the point A encoded in p. Fails if p is not on the curve.
+static int unpack_neg(gf r[4], const uint8_t p[32]) {
+    gf t, chk, num, den, den2, den4, den6;
+    set(r[2], gf1);
+    unpack(r[1], p);
+    // x^2 = (y^2 - 1) / (d y^2 + 1)
+    square(num, r[1]);
+    mul(den, num, D);
+    sub(num, num, r[2]);
+    add(den, r[2], den);
+
+    // x = num den^3 (num den^7)^((p-5)/8), possibly times sqrt(-1)
+    square(den2, den);
+    square(den4, den2);
+    mul(den6, den4, den2);
+    mul(t, den6, num);
+    mul(t, t, den);
+    pow2523(t, t);
+    mul(t, t, num);
+    mul(t, t, den);
+    mul(t, t, den);
+    mul(r[0], t, den);
+
+    square(chk, r[0]);
+    mul(chk, chk, den);
+    if (!equal(chk, num)) mul(r[0], r[0], I);
+    square(chk, r[0]);
+    mul(chk, chk, den);
+    if (!equal(chk, num)) return -1;
+
+    if (parity(r[0]) == (p[31] >> 7)) sub(r[0], gf0, r[0]);
+    mul(r[3], r[0], r[1]);
+    return 0;
+}
+
+// r = x mod L for a 64-byte little-endian x.
+static void mod_l(uint8_t r[32], int64_t x[64]) {
+    int64_t c;
+    int i, j;
+    for (i = 63; i >= 32; i--) {
+        c = 0;
+        for (j = i - 32; j < i - 12; j++) {
+            x[j] += c - 16 * x[i] * L[j - (i - 32)];
+            c = (x[j] + 128) >> 8;
+            x[j] -= c * 256;
+        }
+        x[j] += c;
+        x[i] = 0;
+    }
+    c = 0;
+    for (j = 0; j < 32; j++) {
+        x[j] += c - (x[31] >> 4) * L[j];
+        c = x[j] >> 8;
+        x[j] &= 255;
+    }
+    for (j = 0; j < 32; j++) x[j] -= c * L[j];
+    for (i = 0; i < 32; i++) {
+        x[i + 1] += x[i] >> 8;
+        r[i] = (uint8_t)(x[i] & 255);
+    }
+}
+
+// Returns 1 if the little-endian s is below L.
+static int scalar_reduced(const uint8_t s[32]) {
+    for (int i = 31; i >= 0; i--) {
+        if (s[i] < L[i]) return 1;
+        if (s[i] > L[i]) return 0;
+    }
+    return 0;
+}
+
+void ed25519_verify_init(sha512_ctx_t *ctx, const uint8_t sig[ED25519_SIG_LEN],
+                         const uint8_t pk[ED25519_PK_LEN]) {
+    sha512_init(ctx);
+    sha512_update(ctx, sig, 32);
+    sha512_update(ctx, pk, ED25519_PK_LEN);
+}
+
+int ed25519_verify_final(sha512_ctx_t *ctx, const uint8_t sig[ED25519_SIG_LEN],
+                         const uint8_t pk[ED25519_PK_LEN]) {
+    gf p[4], q[4];
+    if (!scalar_reduced(sig + 32)) return 0;
+    if (unpack_neg(q, pk) != 0) return 0;
+
+    // k = SHA-512(R || A || M) mod L
+    uint8_t digest[SHA512_DIGEST_LEN], k[32];
+    sha512_final(ctx, digest);
+    int64_t x[64];
+    for (int i = 0; i < 64; i++) x[i] = digest[i];
+    mod_l(k, x);
+
+    // R = [k](-A) + [S]B
+    uint8_t r[32];
+    scalarmult(p, q, k);
+    scalarbase(q, sig + 32);
+    point_add(p, q);
+    point_pack(r, p);
+    return mem_cmp(r, sig, 32) == 0;
+}
+
+int ed25519_verify(const uint8_t sig[ED25519_SIG_LEN], const uint8_t *msg, size_t msg_len,
+                   const uint8_t pk[ED25519_PK_LEN]) {
+    sha512_ctx_t ctx;
+    ed25519_verify_init(&ctx, sig, pk);
+    sha512_update(&ctx, msg, msg_len);
+    return ed25519_verify_final(&ctx, sig, pk);
+}
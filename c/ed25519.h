@@ -0,0 +1,23 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+#include "sha512.h"
+
+#define ED25519_PK_LEN  32
+#define ED25519_SIG_LEN 64
+
+// Verifies an Ed25519 (RFC 8032) signature with the same rules as Go's
+// crypto/ed25519: S must be reduced, R must be the canonical encoding of
+// [S]B - [k]A, and the public key may be a non-canonical encoding of a
+// point. Returns 1 if valid, 0 otherwise.
+int ed25519_verify(const uint8_t sig[ED25519_SIG_LEN], const uint8_t *msg, size_t msg_len,
+                   const uint8_t pk[ED25519_PK_LEN]);
+
+// ed25519_verify for a message fed in pieces: ed25519_verify_init starts
+// SHA-512(R || A || M) in ctx, the caller adds M with sha512_update, and
+// ed25519_verify_final checks the signature.
+void ed25519_verify_init(sha512_ctx_t *ctx, const uint8_t sig[ED25519_SIG_LEN],
+                         const uint8_t pk[ED25519_PK_LEN]);
+int ed25519_verify_final(sha512_ctx_t *ctx, const uint8_t sig[ED25519_SIG_LEN],
+                         const uint8_t pk[ED25519_PK_LEN]);
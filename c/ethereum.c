@@ -0,0 +1,74 @@
+#include "ethereum.h"
+#include "keccak.h"
+#include "secp256k1.h"
+#include "mem.h"
+#include <string.h>
+
+// (n - 1) / 2 for the secp256k1 order n: the largest low s
+static const uint8_t HALF_N[32] = {
+    0x7F, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+    0x5D, 0x57, 0x6E, 0x73, 0x57, 0xA4, 0x50, 0x1D, 0xDF, 0xE9, 0x2F, 0x46, 0x68, 0x1B, 0x20, 0xA0,
+};
+
+static const char TYPED_DATA_TYPE[] = "XsigMessage(bytes message)";
+
+// keccak256("\x19Ethereum Signed Message:\n" || decimal len(msg) || msg)
+static void personal_sign_hash(const uint8_t *msg, size_t msg_len, uint8_t out[32]) {
+    static const char prefix[] = "\x19" "Ethereum Signed Message:\n";
+    char digits[20];
+    int n = 0;
+    size_t len = msg_len;
+    do {
+        digits[n++] = (char)('0' + len % 10);
+        len /= 10;
+    } while (len > 0);
+
+    keccak256_ctx_t ctx;
+    keccak256_init(&ctx);
+    keccak256_update(&ctx, (const uint8_t *)prefix, sizeof(prefix) - 1);
+    while (n > 0) {
+        keccak256_update(&ctx, (const uint8_t *)&digits[--n], 1);
+    }
+    keccak256_update(&ctx, msg, msg_len);
+    keccak256_final(&ctx, out);
+}
+
+// keccak256(0x19 0x01 || domain separator || hashStruct(XsigMessage(msg)))
+static void typed_data_hash(const uint8_t domain_separator[ETH_DOMAIN_LEN],
+                            const uint8_t *msg, size_t msg_len, uint8_t out[32]) {
+    uint8_t struct_fields[64], struct_hash[32];
+    keccak256((const uint8_t *)TYPED_DATA_TYPE, sizeof(TYPED_DATA_TYPE) - 1, struct_fields);
+    keccak256(msg, msg_len, struct_fields + 32);
+    keccak256(struct_fields, sizeof(struct_fields), struct_hash);
+
+    static const uint8_t prefix[2] = {0x19, 0x01};
+    keccak256_ctx_t ctx;
+    keccak256_init(&ctx);
+    keccak256_update(&ctx, prefix, sizeof(prefix));
+    keccak256_update(&ctx, domain_separator, ETH_DOMAIN_LEN);
+    keccak256_update(&ctx, struct_hash, sizeof(struct_hash));
+    keccak256_final(&ctx, out);
+}
+
+int eth_verify(uint8_t mode, const uint8_t domain_separator[ETH_DOMAIN_LEN],
+               const uint8_t *msg, size_t msg_len,
+               const uint8_t address[ETH_ADDRESS_LEN], const uint8_t sig[ETH_SIG_LEN]) {
+    uint8_t hash[32];
+    if (mode == ETH_PERSONAL_SIGN) {
+        personal_sign_hash(msg, msg_len, hash);
+    } else if (mode == ETH_TYPED_DATA) {
+        typed_data_hash(domain_separator, msg, msg_len, hash);
+    } else {
+        return 0;
+    }
+
+    uint8_t v = sig[64];
+    if (v >= 27) v -= 27;
+    if (v > 1) return 0;
+    if (mem_cmp(sig + 32, HALF_N, 32) > 0) return 0;
+
+    uint8_t pk_xy[64], pk_hash[32];
+    if (secp256k1_ecdsa_recover(hash, sig, v, pk_xy) != 0) return 0;
+    keccak256(pk_xy, sizeof(pk_xy), pk_hash);
+    return mem_cmp(pk_hash + 32 - ETH_ADDRESS_LEN, address, ETH_ADDRESS_LEN) == 0;
+}
@@ -0,0 +1,21 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+// Signature modes, matching internal/crypto/ethereum.go.
+#define ETH_PERSONAL_SIGN 0
+#define ETH_TYPED_DATA    1
+
+#define ETH_ADDRESS_LEN   20
+#define ETH_SIG_LEN       65
+#define ETH_DOMAIN_LEN    32
+
+// Verifies an Ethereum wallet signature r || s || v of msg by address:
+// EIP-191 personal_sign, or EIP-712 typed data of an XsigMessage(bytes
+// message) in the domain with the given separator (ignored for
+// personal_sign). v is 27 or 28, or 0 or 1, and s must be low (EIP-2).
+// Returns 1 if valid, 0 otherwise.
+int eth_verify(uint8_t mode, const uint8_t domain_separator[ETH_DOMAIN_LEN],
+               const uint8_t *msg, size_t msg_len,
+               const uint8_t address[ETH_ADDRESS_LEN], const uint8_t sig[ETH_SIG_LEN]);
@@ -1,13 +1,226 @@
 #include "eval.h"
 #include "der.h"
+#include "sha256.h"
 #include "p256/p256.h"
+#include "secp256k1.h"
+#include "slhdsa.h"
+#include "rsa.h"
+#include "webauthn.h"
+#include "sshsig.h"
+#include "ethereum.h"
+#include "ed25519.h"
+#include "hash.h"
+#include "p384.h"
+#include "mem.h"
 #include <string.h>
 
 void eval_init(eval_t *e) {
     stack_init(&e->stack);
+    e->alt_top = 0;
+    e->n_objects = 0;
+    e->digest_alg = 0;
 }
 
-static int do_sigverify(eval_t *e, const uint8_t *xmsg, size_t xmsg_len) {
+// Overflow-checked int64 arithmetic. Return nonzero if the result does not
+// fit, matching Go's addInt64/subInt64/mulInt64.
+static int add_int64(int64_t a, int64_t b, int64_t *r) {
+    if ((b > 0 && a > INT64_MAX - b) || (b < 0 && a < INT64_MIN - b)) return -1;
+    *r = a + b;
+    return 0;
+}
+
+static int sub_int64(int64_t a, int64_t b, int64_t *r) {
+    if ((b < 0 && a > INT64_MAX + b) || (b > 0 && a < INT64_MIN + b)) return -1;
+    *r = a - b;
+    return 0;
+}
+
+static int mul_int64(int64_t a, int64_t b, int64_t *r) {
+    if (a == 0 || b == 0) {
+        *r = 0;
+        return 0;
+    }
+    if (a > 0) {
+        if (b > 0) {
+            if (a > INT64_MAX / b) return -1;
+        } else if (b < INT64_MIN / a) {
+            return -1;
+        }
+    } else {
+        if (b > 0) {
+            if (a < INT64_MIN / b) return -1;
+        } else if (a < INT64_MAX / b) {
+            return -1;
+        }
+    }
+    *r = a * b;
+    return 0;
+}
+
+// Binary number words. Operands in push order: x is pushed first, y on top.
+static int do_num_binary(eval_t *e, uint8_t opcode) {
+    int64_t x, y, r;
+    if (stack_pop_num(&e->stack, &y) != 0) return -1;
+    if (stack_pop_num(&e->stack, &x) != 0) return -1;
+
+    switch (opcode) {
+    case OP_NUMADD:
+        if (add_int64(x, y, &r) != 0) return -1;
+        return stack_push_num(&e->stack, r);
+    case OP_NUMSUB:
+        if (sub_int64(x, y, &r) != 0) return -1;
+        return stack_push_num(&e->stack, r);
+    case OP_NUMMUL:
+        if (mul_int64(x, y, &r) != 0) return -1;
+        return stack_push_num(&e->stack, r);
+    case OP_NUMLESSTHAN:
+        return stack_push(&e->stack, x < y ? 1 : 0);
+    case OP_NUMEQUAL:
+        return stack_push(&e->stack, x == y ? 1 : 0);
+    }
+    return -1;
+}
+
+static int do_threshold(eval_t *e) {
+    uint8_t n, k;
+
+    if (stack_pop(&e->stack, &n) != 0) return -1;
+    if (stack_pop(&e->stack, &k) != 0) return -1;
+
+    if (n == 0) return -1;
+    if (k == 0) return -1;
+    if (k > n) return -1;
+
+    int count_true = 0;
+    for (int i = 0; i < (int)n; i++) {
+        uint8_t cond;
+        if (stack_pop(&e->stack, &cond) != 0) return -1;
+        if (cond > 1) return -1;
+        count_true += cond;
+    }
+
+    return stack_push(&e->stack, count_true >= (int)k ? 1 : 0);
+}
+
+// Returns 1 if the verifier revoked pk, see eval_ctx_t.is_revoked.
+static int key_revoked(const eval_ctx_t *ctx, const uint8_t *pk, size_t pk_len) {
+    if (ctx->is_revoked == NULL) return 0;
+    uint8_t fingerprint[SHA256_DIGEST_LEN];
+    sha256(pk, pk_len, fingerprint);
+    return ctx->is_revoked(fingerprint, ctx->revoked_arg) != 0;
+}
+
+// The alg digest of the message, as in Go's messageDigest: the one the
+// verifier supplied in prehashed mode, otherwise ctx->msg hashed, and kept
+// while the same algorithm is asked for again (e.g. by every attempt of a
+// multisig). NULL if alg is unknown or, in prehashed mode, not supplied.
+static const uint8_t *message_digest(eval_t *e, const eval_ctx_t *ctx, uint8_t alg) {
+    if (hash_size(alg) == 0) return NULL;
+    if (ctx->prehashed) return ctx->digests[alg];
+    if (e->digest_alg != alg) {
+        hash_digest(alg, ctx->msg, ctx->msg_len, e->digest);
+        e->digest_alg = alg;
+    }
+    return e->digest;
+}
+
+// p256_verify over the SHA-256 hash of the message, except that a revoked
+// key never validates. Returns 1 if the signature is valid.
+static int verify_sig(const eval_ctx_t *ctx, const uint8_t hash[SHA256_DIGEST_LEN],
+                      const uint8_t raw_sig[64], const uint8_t pk[33]) {
+    if (key_revoked(ctx, pk, 33)) return 0;
+    return p256_verify_digest(hash, (uint8_t *)raw_sig, pk) == P256_SUCCESS;
+}
+
+// Public key length for a key type, 0 if the type is unknown.
+static size_t typed_key_len(uint8_t key_type) {
+    switch (key_type) {
+    case KEY_TYPE_P256:
+    case KEY_TYPE_SECP256K1:
+        return 33;
+    case KEY_TYPE_SCHNORR:
+        return SCHNORR_PK_LEN;
+    case KEY_TYPE_ED25519:
+        return ED25519_PK_LEN;
+    }
+    return 0;
+}
+
+// verify_sig for a key of any type. Ed25519 signs the verifier's message,
+// the others its SHA-256 hash. sig is DER for the ECDSA types; a malformed
+// one does not validate.
+static int verify_typed_sig(const eval_ctx_t *ctx, uint8_t key_type, const uint8_t *hash,
+                            const uint8_t *pk, const uint8_t *sig, size_t sig_len) {
+    uint8_t raw_sig[64];
+    switch (key_type) {
+    case KEY_TYPE_P256:
+        if (der_to_raw(sig, sig_len, raw_sig) != 0) return 0;
+        return verify_sig(ctx, hash, raw_sig, pk);
+    case KEY_TYPE_SECP256K1:
+        if (key_revoked(ctx, pk, 33)) return 0;
+        if (der_to_raw(sig, sig_len, raw_sig) != 0) return 0;
+        return secp256k1_ecdsa_verify_digest(hash, raw_sig, pk);
+    case KEY_TYPE_SCHNORR:
+        if (key_revoked(ctx, pk, SCHNORR_PK_LEN)) return 0;
+        if (sig_len != SCHNORR_SIG_LEN) return 0;
+        return secp256k1_schnorr_verify_digest(hash, sig, pk);
+    case KEY_TYPE_ED25519:
+        if (key_revoked(ctx, pk, ED25519_PK_LEN)) return 0;
+        if (sig_len != ED25519_SIG_LEN) return 0;
+        return ed25519_verify(sig, ctx->msg, ctx->msg_len, pk);
+    }
+    return 0;
+}
+
+// Reads a public key of the given type at *at into pk (room for 33 bytes),
+// see stack_read_bytes.
+static int read_typed_key(const eval_t *e, int *at, uint8_t key_type, uint8_t *pk) {
+    switch (key_type) {
+    case KEY_TYPE_P256:
+    case KEY_TYPE_SECP256K1:
+        return stack_read_pubkey_compressed(&e->stack, at, pk);
+    case KEY_TYPE_SCHNORR:
+        return stack_read_bytes(&e->stack, at, pk, SCHNORR_PK_LEN);
+    case KEY_TYPE_ED25519:
+        return stack_read_bytes(&e->stack, at, pk, ED25519_PK_LEN);
+    }
+    return -1;
+}
+
+// Pops a public key of the given type into pk (room for 33 bytes).
+static int pop_typed_key(eval_t *e, uint8_t key_type, uint8_t *pk) {
+    return read_typed_key(e, &e->stack.top, key_type, pk);
+}
+
+// Pops N and N commitments, checks the N public keys below them against
+// the commitments and leaves the keys in place.
+static int do_checkpkhash(eval_t *e) {
+    uint8_t n;
+    if (stack_pop(&e->stack, &n) != 0) return -1;
+    if (n == 0) return -1;
+
+    int commitments_at = e->stack.top;
+    uint8_t commitment[MAX_BLOB_SIZE];
+    size_t len;
+    for (int i = 0; i < (int)n; i++) {
+        if (stack_pop_blob(&e->stack, commitment, &len) != 0) return -1;
+        if (len != 20 && len != 32) return -1;
+    }
+
+    // the keys are read where they are, and stay there
+    int pks_at = e->stack.top;
+    for (int i = 0; i < (int)n; i++) {
+        uint8_t pk[33];
+        uint8_t fingerprint[SHA256_DIGEST_LEN];
+        if (stack_read_pubkey_compressed(&e->stack, &pks_at, pk) != 0) return -1;
+        if (stack_read_blob(&e->stack, &commitments_at, commitment, &len) != 0) return -1;
+        sha256(pk, 33, fingerprint);
+        if (mem_cmp(fingerprint, commitment, len) != 0) return -1;
+    }
+    return 0;
+}
+
+static int do_sigverify(eval_t *e, const eval_ctx_t *ctx) {
     uint8_t pk[33];
     if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) {
         return -1;
@@ -24,11 +237,347 @@ static int do_sigverify(eval_t *e, const uint8_t *xmsg, size_t xmsg_len) {
         return -1;
     }
 
-    p256_ret_t ret = p256_verify((uint8_t *)xmsg, xmsg_len, raw_sig, pk);
-    return stack_push(&e->stack, ret == P256_SUCCESS ? 1 : 0);
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+    return stack_push(&e->stack, verify_sig(ctx, hash, raw_sig, pk));
+}
+
+// Like do_sigverify, with a fixed-size r || s signature: no DER involved.
+static int do_sigverify_raw(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t pk[33];
+    if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) {
+        return -1;
+    }
+
+    uint8_t raw_sig[RAW_SIG_LEN];
+    if (stack_pop_bytes(&e->stack, raw_sig, RAW_SIG_LEN) != 0) {
+        return -1;
+    }
+
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+    return stack_push(&e->stack, verify_sig(ctx, hash, raw_sig, pk));
+}
+
+// OP_SIGVERIFYSECP256K1 (DER signature), OP_SIGVERIFYSCHNORR and
+// OP_SIGVERIFYED25519 (64 bytes).
+static int do_sigverify_typed(eval_t *e, const eval_ctx_t *ctx, uint8_t key_type) {
+    uint8_t pk[33];
+    if (pop_typed_key(e, key_type, pk) != 0) return -1;
+
+    uint8_t sig[MAX_SIG_DER_LEN];
+    size_t sig_len = SCHNORR_SIG_LEN;
+    if (key_type == KEY_TYPE_SCHNORR || key_type == KEY_TYPE_ED25519) {
+        if (stack_pop_bytes(&e->stack, sig, sig_len) != 0) return -1;
+    } else if (stack_pop_signature(&e->stack, sig, &sig_len) != 0) {
+        return -1;
+    }
+
+    const uint8_t *hash = NULL;
+    if (key_type != KEY_TYPE_ED25519) {
+        hash = message_digest(e, ctx, HASH_SHA256);
+        if (hash == NULL) return -1;
+    }
+    return stack_push(&e->stack, verify_typed_sig(ctx, key_type, hash, pk, sig, sig_len));
+}
+
+// OP_SIGVERIFYHASH: pops the hash algorithm, the key type (P-256,
+// secp256k1 or P-384), a compressed public key and a DER signature over the
+// digest of the message. A malformed signature does not validate.
+static int do_sigverifyhash(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t alg, key_type;
+    if (stack_pop(&e->stack, &alg) != 0) return -1;
+    if (hash_size(alg) == 0) return -1;
+    if (stack_pop(&e->stack, &key_type) != 0) return -1;
+
+    uint8_t pk[P384_PK_LEN];
+    size_t pk_len = 33;
+    switch (key_type) {
+    case KEY_TYPE_P256:
+    case KEY_TYPE_SECP256K1:
+        if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) return -1;
+        break;
+    case KEY_TYPE_P384:
+        pk_len = P384_PK_LEN;
+        if (stack_pop_bytes(&e->stack, pk, pk_len) != 0) return -1;
+        if (pk[0] != 0x02 && pk[0] != 0x03) return -1;
+        break;
+    default:
+        return -1;
+    }
+
+    uint8_t der_sig[MAX_SIG_DER_LEN_P384];
+    size_t der_len;
+    if (stack_pop_signature_max(&e->stack, der_sig, &der_len, MAX_SIG_DER_LEN_P384) != 0) {
+        return -1;
+    }
+
+    const uint8_t *digest = message_digest(e, ctx, alg);
+    if (digest == NULL) return -1;
+
+    // digests longer than the curve order are truncated to their leftmost bytes
+    int valid = 0;
+    uint8_t raw_sig[P384_RAW_SIG_LEN];
+    if (!key_revoked(ctx, pk, pk_len)) {
+        switch (key_type) {
+        case KEY_TYPE_P256:
+            valid = der_to_raw(der_sig, der_len, raw_sig) == 0 &&
+                    p256_verify_digest(digest, raw_sig, pk) == P256_SUCCESS;
+            break;
+        case KEY_TYPE_SECP256K1:
+            valid = der_to_raw(der_sig, der_len, raw_sig) == 0 &&
+                    secp256k1_ecdsa_verify_digest(digest, raw_sig, pk);
+            break;
+        case KEY_TYPE_P384:
+            valid = der_to_raw_size(der_sig, der_len, raw_sig, 48) == 0 &&
+                    p384_ecdsa_verify_digest(digest, hash_size(alg), raw_sig, pk);
+            break;
+        }
+    }
+    return stack_push(&e->stack, valid ? 1 : 0);
+}
+
+// Pops an object index and returns that object (see OP_PUSHLARGE).
+static int pop_object(eval_t *e, const uint8_t **obj, size_t *obj_len) {
+    uint8_t index;
+    if (stack_pop(&e->stack, &index) != 0) return -1;
+    if ((int)index >= e->n_objects) return -1;
+    *obj = e->objects[index];
+    *obj_len = e->object_lens[index];
+    return 0;
+}
+
+// OP_SLHDSAVERIFY: pops the parameter set, the public key and the index of
+// the object holding the signature. An empty object does not validate.
+static int do_slhdsaverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t param_set;
+    uint8_t pk[SLHDSA_PK_LEN];
+    const uint8_t *sig;
+    size_t sig_len;
+    if (stack_pop(&e->stack, &param_set) != 0) return -1;
+    if (slhdsa_sig_len(param_set) == 0) return -1;
+    if (stack_pop_bytes(&e->stack, pk, SLHDSA_PK_LEN) != 0) return -1;
+    if (pop_object(e, &sig, &sig_len) != 0) return -1;
+
+    int valid = !key_revoked(ctx, pk, SLHDSA_PK_LEN) &&
+                slhdsa_verify(param_set, ctx->msg, ctx->msg_len, sig, sig_len, pk);
+    return stack_push(&e->stack, valid ? 1 : 0);
+}
+
+// OP_RSAVERIFY: pops the scheme, the 32-byte commitment to the key (its
+// fingerprint), the index of the key object and the index of the signature
+// object. Fails unless the key matches its commitment and is well formed.
+static int do_rsaverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t scheme;
+    uint8_t commitment[SHA256_DIGEST_LEN], fingerprint[SHA256_DIGEST_LEN];
+    const uint8_t *key, *sig;
+    size_t key_len, sig_len;
+    if (stack_pop(&e->stack, &scheme) != 0) return -1;
+    if (scheme != RSA_PKCS1V15 && scheme != RSA_PSS) return -1;
+    if (stack_pop_bytes(&e->stack, commitment, SHA256_DIGEST_LEN) != 0) return -1;
+    if (pop_object(e, &key, &key_len) != 0) return -1;
+    sha256(key, key_len, fingerprint);
+    if (mem_cmp(fingerprint, commitment, SHA256_DIGEST_LEN) != 0) return -1;
+    if (rsa_key_check(key, key_len) != 0) return -1;
+    if (pop_object(e, &sig, &sig_len) != 0) return -1;
+
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+    int valid = !key_revoked(ctx, key, key_len) &&
+                rsa_verify_digest(scheme, hash, key, key_len, sig, sig_len);
+    return stack_push(&e->stack, valid ? 1 : 0);
+}
+
+// OP_WEBAUTHNVERIFY: pops the required flags, the RP ID hash, the public
+// key, the indices of the client data and authenticator data objects, and
+// the DER signature.
+static int do_webauthnverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t flags;
+    uint8_t rp_id_hash[WEBAUTHN_RP_ID_HASH_LEN];
+    uint8_t pk[33];
+    const uint8_t *client_data, *auth_data;
+    size_t client_data_len, auth_data_len;
+    if (stack_pop(&e->stack, &flags) != 0) return -1;
+    if (stack_pop_bytes(&e->stack, rp_id_hash, WEBAUTHN_RP_ID_HASH_LEN) != 0) return -1;
+    if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) return -1;
+    if (pop_object(e, &client_data, &client_data_len) != 0) return -1;
+    if (pop_object(e, &auth_data, &auth_data_len) != 0) return -1;
+
+    uint8_t der_sig[MAX_SIG_DER_LEN];
+    size_t der_len;
+    if (stack_pop_signature(&e->stack, der_sig, &der_len) != 0) return -1;
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+
+    // Malformed DER is a failed verification, as in Go
+    uint8_t raw_sig[64];
+    if (der_to_raw(der_sig, der_len, raw_sig) != 0) {
+        return stack_push(&e->stack, 0);
+    }
+
+    int valid = !key_revoked(ctx, pk, 33) &&
+                webauthn_verify_digest(hash, pk, rp_id_hash, flags,
+                                auth_data, auth_data_len, client_data, client_data_len, raw_sig);
+    return stack_push(&e->stack, valid ? 1 : 0);
+}
+
+// OP_SSHSIGVERIFY: pops the namespace and the SSH public key (blobs) and the
+// index of the object holding the signature. Fails on an empty namespace or
+// a malformed or unsupported key.
+static int do_sshsigverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t ns[MAX_BLOB_SIZE], key[MAX_BLOB_SIZE];
+    size_t ns_len, key_len;
+    const uint8_t *sig;
+    size_t sig_len;
+    if (stack_pop_blob(&e->stack, ns, &ns_len) != 0) return -1;
+    if (ns_len == 0) return -1;
+    if (stack_pop_blob(&e->stack, key, &key_len) != 0) return -1;
+    if (sshsig_key_check(key, key_len) != 0) return -1;
+    if (pop_object(e, &sig, &sig_len) != 0) return -1;
+
+    // The blob names the hash algorithm; one sshsig does not accept is just
+    // a bad signature
+    uint8_t alg = sshsig_hash_alg(sig, sig_len);
+    if (alg == 0 || key_revoked(ctx, key, key_len)) {
+        return stack_push(&e->stack, 0);
+    }
+    const uint8_t *digest = message_digest(e, ctx, alg);
+    if (digest == NULL) return -1;
+    int valid = sshsig_verify_digest(digest, key, key_len, ns, ns_len, sig, sig_len);
+    return stack_push(&e->stack, valid ? 1 : 0);
+}
+
+// OP_ETHVERIFY: pops the mode, the EIP-712 domain separator in typed data
+// mode, the 20-byte address and the 65-byte signature. Fails on an unknown
+// mode.
+static int do_ethverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t mode;
+    uint8_t domain_separator[ETH_DOMAIN_LEN] = {0};
+    uint8_t address[ETH_ADDRESS_LEN], sig[ETH_SIG_LEN];
+    if (stack_pop(&e->stack, &mode) != 0) return -1;
+    if (mode == ETH_TYPED_DATA) {
+        if (stack_pop_bytes(&e->stack, domain_separator, ETH_DOMAIN_LEN) != 0) return -1;
+    } else if (mode != ETH_PERSONAL_SIGN) {
+        return -1;
+    }
+    if (stack_pop_bytes(&e->stack, address, ETH_ADDRESS_LEN) != 0) return -1;
+    if (stack_pop_bytes(&e->stack, sig, ETH_SIG_LEN) != 0) return -1;
+
+    int valid = !key_revoked(ctx, address, ETH_ADDRESS_LEN) &&
+                eth_verify(mode, domain_separator, ctx->msg, ctx->msg_len, address, sig);
+    return stack_push(&e->stack, valid ? 1 : 0);
+}
+
+// OP_MIXEDMULTISIGVERIFY: K-of-N over keys of any type, one signature slot
+// per key (an empty blob if that key did not sign).
+static int do_mixedmultisigverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t n_public_keys, n_min_valid;
+    if (stack_pop(&e->stack, &n_public_keys) != 0) return -1;
+    if (stack_pop(&e->stack, &n_min_valid) != 0) return -1;
+    if (n_public_keys == 0 || n_min_valid == 0) return -1;
+    if (n_min_valid > n_public_keys) return -1;
+
+#if XSIG_MAX_KEYS < 255
+    if (n_public_keys > XSIG_MAX_KEYS) return -1;
+#endif
+
+    // Pop the (key type, public key) pairs, then read them back from the
+    // stack as needed
+    int pks_at = e->stack.top;
+    int need_ed25519 = 0, need_hash = 0;
+    for (int i = 0; i < (int)n_public_keys; i++) {
+        uint8_t key_type, pk[33];
+        if (stack_pop(&e->stack, &key_type) != 0) return -1;
+        if (pop_typed_key(e, key_type, pk) != 0) return -1;
+        if (key_type == KEY_TYPE_ED25519) {
+            need_ed25519 = 1;
+        } else {
+            need_hash = 1;
+        }
+
+        int at = pks_at;
+        for (int j = 0; j < i; j++) {
+            uint8_t other_type, other[33];
+            if (stack_read(&e->stack, &at, &other_type) != 0) return -1;
+            if (read_typed_key(e, &at, other_type, other) != 0) return -1;
+            if (other_type == key_type &&
+                mem_cmp(other, pk, typed_key_len(key_type)) == 0) {
+                return -1;
+            }
+        }
+    }
+
+    // Go checks this after popping the signatures, but either way a failure
+    // is an error
+    if (need_ed25519 && ctx->prehashed) return -1;
+    const uint8_t *hash = NULL;
+    if (need_hash) {
+        hash = message_digest(e, ctx, HASH_SHA256);
+        if (hash == NULL) return -1;
+    }
+
+    // The signatures are below the keys, so popping them leaves the keys
+    int count_valid = 0;
+    for (int i = 0; i < (int)n_public_keys; i++) {
+        uint8_t key_type, pk[33];
+        if (stack_read(&e->stack, &pks_at, &key_type) != 0) return -1;
+        if (read_typed_key(e, &pks_at, key_type, pk) != 0) return -1;
+        uint8_t sig[MAX_BLOB_SIZE];
+        size_t sig_len;
+        if (stack_pop_blob(&e->stack, sig, &sig_len) != 0) return -1;
+        if (sig_len > 0 && verify_typed_sig(ctx, key_type, hash, pk, sig, sig_len)) {
+            count_valid++;
+        }
+    }
+
+    return stack_push(&e->stack, count_valid >= n_min_valid ? 1 : 0);
+}
+
+// Like do_sigverify, over a message taken from the stack.
+static int do_checksigfromstack(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t pk[33];
+    if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) {
+        return -1;
+    }
+
+    uint8_t msg[MAX_BLOB_SIZE];
+    size_t msg_len;
+    if (stack_pop_blob(&e->stack, msg, &msg_len) != 0) {
+        return -1;
+    }
+
+    uint8_t der_sig[MAX_SIG_DER_LEN];
+    size_t der_len;
+    if (stack_pop_signature(&e->stack, der_sig, &der_len) != 0) {
+        return -1;
+    }
+
+    // Malformed DER is a failed verification, as in Go
+    uint8_t raw_sig[64];
+    if (der_to_raw(der_sig, der_len, raw_sig) != 0) {
+        return stack_push(&e->stack, 0);
+    }
+
+    uint8_t hash[SHA256_DIGEST_LEN];
+    sha256(msg, msg_len, hash);
+    return stack_push(&e->stack, verify_sig(ctx, hash, raw_sig, pk));
 }
 
-static int do_multisigverify(eval_t *e, const uint8_t *xmsg, size_t xmsg_len) {
+// Reads a multisig signature at *at: r || s if raw, otherwise DER.
+static int read_multisig_sig(const eval_t *e, int *at, int raw, uint8_t sig[MAX_SIG_DER_LEN],
+                             size_t *sig_len) {
+    if (raw) {
+        *sig_len = RAW_SIG_LEN;
+        return stack_read_bytes(&e->stack, at, sig, RAW_SIG_LEN);
+    }
+    return stack_read_signature(&e->stack, at, sig, sig_len);
+}
+
+// OP_MULTISIGVERIFY, or OP_STRICTMULTISIGVERIFY if strict: that one fails
+// on a repeated public key or signature, and spends each signature on at
+// most one key. With raw, signatures are r || s (OP_MULTISIGVERIFYRAW,
+// always strict).
+static int do_multisigverify(eval_t *e, const eval_ctx_t *ctx, int strict, int raw) {
     uint8_t n_public_keys, n_min_valid;
 
     if (stack_pop(&e->stack, &n_public_keys) != 0) return -1;
@@ -38,33 +587,74 @@ static int do_multisigverify(eval_t *e, const uint8_t *xmsg, size_t xmsg_len) {
     if (n_min_valid == 0) return -1;
     if (n_min_valid > n_public_keys) return -1;
 
-    // Pop public keys (max 255)
-    uint8_t pks[255][33];
+#if XSIG_MAX_KEYS < 255
+    if (n_public_keys > XSIG_MAX_KEYS) return -1;
+#endif
+
+    // Pop the public keys and the signatures to check them, then read them
+    // back from the stack one at a time
+    int pks_at = e->stack.top;
+    uint8_t pk[33];
     for (int i = 0; i < (int)n_public_keys; i++) {
-        if (stack_pop_pubkey_compressed(&e->stack, pks[i]) != 0) {
+        if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) {
             return -1;
         }
     }
 
-    // Pop signatures
-    uint8_t sigs[255][MAX_SIG_DER_LEN];
-    size_t sig_lens[255];
+    int sigs_at = e->stack.top;
+    uint8_t sig[MAX_SIG_DER_LEN];
+    size_t sig_len;
     for (int i = 0; i < (int)n_min_valid; i++) {
-        if (stack_pop_signature(&e->stack, sigs[i], &sig_lens[i]) != 0) {
-            return -1;
+        if (read_multisig_sig(e, &e->stack.top, raw, sig, &sig_len) != 0) return -1;
+    }
+
+    if (strict) {
+        int at_j = pks_at;
+        for (int j = 0; j < (int)n_public_keys; j++) {
+            if (stack_read_bytes(&e->stack, &at_j, pk, 33) != 0) return -1;
+            int at_i = pks_at;
+            for (int i = 0; i < j; i++) {
+                uint8_t other[33];
+                if (stack_read_bytes(&e->stack, &at_i, other, 33) != 0) return -1;
+                if (mem_cmp(other, pk, 33) == 0) return -1;
+            }
+        }
+        at_j = sigs_at;
+        for (int j = 0; j < (int)n_min_valid; j++) {
+            if (read_multisig_sig(e, &at_j, raw, sig, &sig_len) != 0) return -1;
+            int at_i = sigs_at;
+            for (int i = 0; i < j; i++) {
+                uint8_t other[MAX_SIG_DER_LEN];
+                size_t other_len;
+                if (read_multisig_sig(e, &at_i, raw, other, &other_len) != 0) return -1;
+                if (other_len == sig_len && mem_cmp(other, sig, sig_len) == 0) return -1;
+            }
         }
     }
 
+    // Hashed once for all the N1 x N2 attempts
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+
     // Verify: for each public key, try each signature.
     // Matches Go's outer=keys, inner=sigs loop.
+    uint8_t used[XSIG_MAX_KEYS] = {0};
     int count_valid = 0;
+    int at_pk = pks_at;
     for (int i = 0; i < (int)n_public_keys; i++) {
+        if (stack_read_bytes(&e->stack, &at_pk, pk, 33) != 0) return -1;
+        int at_sig = sigs_at;
         for (int j = 0; j < (int)n_min_valid; j++) {
+            if (read_multisig_sig(e, &at_sig, raw, sig, &sig_len) != 0) return -1;
+            if (strict && used[j]) continue;
             uint8_t raw_sig[64];
-            if (der_to_raw(sigs[j], sig_lens[j], raw_sig) != 0) {
+            if (raw) {
+                memcpy(raw_sig, sig, RAW_SIG_LEN);
+            } else if (der_to_raw(sig, sig_len, raw_sig) != 0) {
                 continue;
             }
-            if (p256_verify((uint8_t *)xmsg, xmsg_len, raw_sig, pks[i]) == P256_SUCCESS) {
+            if (verify_sig(ctx, hash, raw_sig, pk)) {
+                used[j] = 1;
                 count_valid++;
                 break; // next key (continue OUTER in Go)
             }
@@ -74,13 +664,354 @@ static int do_multisigverify(eval_t *e, const uint8_t *xmsg, size_t xmsg_len) {
     return stack_push(&e->stack, count_valid >= (int)n_min_valid ? 1 : 0);
 }
 
+static int do_weightedsigverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t n_public_keys, n_signatures;
+    int64_t target;
+
+    if (stack_pop(&e->stack, &n_public_keys) != 0) return -1;
+    if (stack_pop_num(&e->stack, &target) != 0) return -1;
+
+    if (n_public_keys == 0) return -1;
+    if (target <= 0) return -1;
+
+#if XSIG_MAX_KEYS < 255
+    if (n_public_keys > XSIG_MAX_KEYS) return -1;
+#endif
+
+    // Pop (weight, public key) pairs and the signatures to check them, then
+    // read them back from the stack one at a time
+    int pks_at = e->stack.top;
+    uint8_t pk[33];
+    int64_t weight;
+    for (int i = 0; i < (int)n_public_keys; i++) {
+        if (stack_pop_num(&e->stack, &weight) != 0) return -1;
+        if (weight <= 0) return -1;
+        if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) {
+            return -1;
+        }
+
+        // A key listed twice would count its weight twice
+        int at = pks_at;
+        for (int j = 0; j < i; j++) {
+            uint8_t other[33];
+            int64_t other_weight;
+            if (stack_read_num(&e->stack, &at, &other_weight) != 0) return -1;
+            if (stack_read_pubkey_compressed(&e->stack, &at, other) != 0) return -1;
+            if (mem_cmp(other, pk, sizeof(pk)) == 0) return -1;
+        }
+    }
+
+    if (stack_pop(&e->stack, &n_signatures) != 0) return -1;
+    if (n_signatures > n_public_keys) return -1;
+
+    int sigs_at = e->stack.top;
+    uint8_t sig[MAX_SIG_DER_LEN];
+    size_t sig_len;
+    for (int i = 0; i < (int)n_signatures; i++) {
+        if (stack_pop_signature(&e->stack, sig, &sig_len) != 0) {
+            return -1;
+        }
+    }
+
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+
+    // Each signature is spent on at most one key.
+    uint8_t used[XSIG_MAX_KEYS] = {0};
+    int64_t sum = 0;
+    int at_pk = pks_at;
+    for (int i = 0; i < (int)n_public_keys; i++) {
+        if (stack_read_num(&e->stack, &at_pk, &weight) != 0) return -1;
+        if (stack_read_pubkey_compressed(&e->stack, &at_pk, pk) != 0) return -1;
+        int at_sig = sigs_at;
+        for (int j = 0; j < (int)n_signatures; j++) {
+            if (stack_read_signature(&e->stack, &at_sig, sig, &sig_len) != 0) return -1;
+            if (used[j]) continue;
+            uint8_t raw_sig[64];
+            if (der_to_raw(sig, sig_len, raw_sig) != 0) {
+                continue;
+            }
+            if (verify_sig(ctx, hash, raw_sig, pk)) {
+                used[j] = 1;
+                if (add_int64(sum, weight, &sum) != 0) return -1;
+                break;
+            }
+        }
+    }
+
+    return stack_push(&e->stack, sum >= target ? 1 : 0);
+}
+
+static int do_equal(eval_t *e) {
+    uint8_t a[MAX_BLOB_SIZE], b[MAX_BLOB_SIZE];
+    size_t a_len, b_len;
+    if (stack_pop_blob(&e->stack, a, &a_len) != 0) return -1;
+    if (stack_pop_blob(&e->stack, b, &b_len) != 0) return -1;
+    int eq = a_len == b_len && mem_cmp(a, b, a_len) == 0;
+    return stack_push(&e->stack, eq ? 1 : 0);
+}
+
+static int do_cat(eval_t *e) {
+    uint8_t a[2 * MAX_BLOB_SIZE], b[MAX_BLOB_SIZE];
+    size_t a_len, b_len;
+    if (stack_pop_blob(&e->stack, b, &b_len) != 0) return -1;
+    if (stack_pop_blob(&e->stack, a, &a_len) != 0) return -1;
+    if (a_len + b_len > MAX_BLOB_SIZE) return -1;
+    memcpy(a + a_len, b, b_len);
+    return stack_push_blob(&e->stack, a, a_len + b_len);
+}
+
+static int do_split(eval_t *e) {
+    int64_t n;
+    uint8_t a[MAX_BLOB_SIZE];
+    size_t a_len;
+    if (stack_pop_num(&e->stack, &n) != 0) return -1;
+    if (stack_pop_blob(&e->stack, a, &a_len) != 0) return -1;
+    if (n < 0 || n > (int64_t)a_len) return -1;
+    if (stack_push_blob(&e->stack, a, (size_t)n) != 0) return -1;
+    return stack_push_blob(&e->stack, a + n, a_len - (size_t)n);
+}
+
+static int do_size(eval_t *e) {
+    uint8_t a[MAX_BLOB_SIZE];
+    size_t a_len;
+    if (stack_pop_blob(&e->stack, a, &a_len) != 0) return -1;
+    if (stack_push_blob(&e->stack, a, a_len) != 0) return -1;
+    return stack_push_num(&e->stack, (int64_t)a_len);
+}
+
+// Find the field with the given tag in a structured message:
+// tag(1) || len(2, big-endian) || value, tags strictly increasing. The
+// whole message is validated, matching Go's LookupMessageField.
+static int msg_field_lookup(const uint8_t *msg, size_t msg_len, uint8_t tag,
+                            const uint8_t **value, size_t *value_len) {
+    size_t pos = 0;
+    int prev_tag = -1;
+    int found = 0;
+    while (pos < msg_len) {
+        if (msg_len - pos < 3) return -1;
+        uint8_t t = msg[pos];
+        size_t n = ((size_t)msg[pos + 1] << 8) | msg[pos + 2];
+        pos += 3;
+        if ((int)t <= prev_tag) return -1;
+        if (n > msg_len - pos) return -1;
+        if (t == tag) {
+            *value = msg + pos;
+            *value_len = n;
+            found = 1;
+        }
+        prev_tag = t;
+        pos += n;
+    }
+    return found ? 0 : -1;
+}
+
+static int do_msgfield(eval_t *e, const uint8_t *xmsg, size_t xmsg_len) {
+    uint8_t tag;
+    const uint8_t *value;
+    size_t value_len;
+    if (stack_pop(&e->stack, &tag) != 0) return -1;
+    if (msg_field_lookup(xmsg, xmsg_len, tag, &value, &value_len) != 0) return -1;
+    if (value_len > MAX_BLOB_SIZE) return -1;
+    return stack_push_blob(&e->stack, value, value_len);
+}
+
+static int do_checknonce(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t tag;
+    const uint8_t *value;
+    size_t value_len;
+    if (stack_pop(&e->stack, &tag) != 0) return -1;
+    if (ctx->nonce_len == 0) return -1;
+    if (msg_field_lookup(ctx->msg, ctx->msg_len, tag, &value, &value_len) != 0) return -1;
+    int match = value_len == ctx->nonce_len && mem_cmp(value, ctx->nonce, value_len) == 0;
+    return stack_push(&e->stack, match ? 1 : 0);
+}
+
+static int do_checksecurityversion(eval_t *e, int64_t security_version) {
+    int64_t v;
+    if (security_version < 0) return -1;
+    if (stack_pop_num(&e->stack, &v) != 0) return -1;
+    if (v < 0) return -1;
+    return stack_push(&e->stack, v >= security_version ? 1 : 0);
+}
+
+// Merkle hashing, matching Go's MerkleLeafHash / MerkleNodeHash.
+static void merkle_leaf_hash(const uint8_t pk[33], uint8_t out[32]) {
+    sha256_ctx_t ctx;
+    uint8_t prefix = 0x00;
+    sha256_init(&ctx);
+    sha256_update(&ctx, &prefix, 1);
+    sha256_update(&ctx, pk, 33);
+    sha256_final(&ctx, out);
+}
+
+static void merkle_node_hash(const uint8_t left[32], const uint8_t right[32], uint8_t out[32]) {
+    sha256_ctx_t ctx;
+    uint8_t prefix = 0x01;
+    sha256_init(&ctx);
+    sha256_update(&ctx, &prefix, 1);
+    sha256_update(&ctx, left, 32);
+    sha256_update(&ctx, right, 32);
+    sha256_final(&ctx, out);
+}
+
+static int do_merklesigverify(eval_t *e, const eval_ctx_t *ctx) {
+    uint8_t root[32];
+    uint8_t pk[33];
+    uint8_t depth;
+
+    if (stack_pop_bytes(&e->stack, root, 32) != 0) return -1;
+    if (stack_pop_pubkey_compressed(&e->stack, pk) != 0) return -1;
+    if (stack_pop(&e->stack, &depth) != 0) return -1;
+    if (depth > MAX_MERKLE_DEPTH) return -1;
+
+    // Fold the proof into the leaf hash as we pop it
+    uint8_t node[32];
+    merkle_leaf_hash(pk, node);
+    for (int i = 0; i < (int)depth; i++) {
+        uint8_t side;
+        uint8_t sibling[32];
+        if (stack_pop(&e->stack, &side) != 0) return -1;
+        if (side > 1) return -1;
+        if (stack_pop_bytes(&e->stack, sibling, 32) != 0) return -1;
+        if (side == 1) {
+            merkle_node_hash(sibling, node, node);
+        } else {
+            merkle_node_hash(node, sibling, node);
+        }
+    }
+
+    uint8_t der_sig[MAX_SIG_DER_LEN];
+    size_t der_len;
+    if (stack_pop_signature(&e->stack, der_sig, &der_len) != 0) {
+        return -1;
+    }
+    const uint8_t *hash = message_digest(e, ctx, HASH_SHA256);
+    if (hash == NULL) return -1;
+
+    // A key outside the tree is treated like a bad signature
+    if (mem_cmp(node, root, 32) != 0) {
+        return stack_push(&e->stack, 0);
+    }
+
+    uint8_t raw_sig[64];
+    if (der_to_raw(der_sig, der_len, raw_sig) != 0) {
+        return stack_push(&e->stack, 0);
+    }
+    return stack_push(&e->stack, verify_sig(ctx, hash, raw_sig, pk));
+}
+
+// Flags for one open OP_IF block.
+#define BRANCH_TAKEN    0x01
+#define BRANCH_SAW_ELSE 0x02
+
+// Returns 1 if every enclosing OP_IF block is taken.
+static int branches_taken(const uint8_t *branches, int depth) {
+    for (int i = 0; i < depth; i++) {
+        if (!(branches[i] & BRANCH_TAKEN)) return 0;
+    }
+    return 1;
+}
+
+// OP_IF / OP_ELSE / OP_ENDIF. Matches Go's controlFlow: no jumps, OP_IF pops
+// its condition (0 or 1) only when the enclosing code runs.
+static int do_control_flow(eval_t *e, uint8_t opcode, uint8_t *branches, int *depth) {
+    switch (opcode) {
+    case OP_IF: {
+        if (*depth >= MAX_BRANCH_DEPTH) return -1;
+        uint8_t flags = 0;
+        if (branches_taken(branches, *depth)) {
+            uint8_t cond;
+            if (stack_pop(&e->stack, &cond) != 0) return -1;
+            if (cond > 1) return -1;
+            if (cond == 1) flags = BRANCH_TAKEN;
+        }
+        branches[(*depth)++] = flags;
+        return 0;
+    }
+    case OP_ELSE: {
+        if (*depth == 0) return -1;
+        uint8_t *b = &branches[*depth - 1];
+        if (*b & BRANCH_SAW_ELSE) return -1;
+        *b = (uint8_t)((*b ^ BRANCH_TAKEN) | BRANCH_SAW_ELSE);
+        return 0;
+    }
+    case OP_ENDIF:
+        if (*depth == 0) return -1;
+        (*depth)--;
+        return 0;
+    }
+    return -1;
+}
+
+// Size of the instruction at pc including its operand, or 0 if truncated.
+static size_t instruction_length(const uint8_t *code, size_t code_len, size_t pc) {
+    if (code[pc] == OP_PUSHLARGE) {
+        if (pc + 2 >= code_len) return 0;
+        size_t n = 3 + ((size_t)code[pc + 1] | (size_t)code[pc + 2] << 8);
+        if (pc + n > code_len) return 0;
+        return n;
+    }
+    if (code[pc] != OP_PUSH) return 1;
+    if (pc + 1 >= code_len) return 0;
+    size_t n = 2 + (size_t)code[pc + 1];
+    if (pc + n > code_len) return 0;
+    return n;
+}
+
 int eval_with_xmsg(eval_t *e, const uint8_t *code, size_t code_len,
                    const uint8_t *xmsg, size_t xmsg_len) {
+    eval_ctx_t ctx;
+    memset(&ctx, 0, sizeof(ctx));
+    ctx.msg = xmsg;
+    ctx.msg_len = xmsg_len;
+    return eval_with_ctx(e, code, code_len, &ctx);
+}
+
+// Opcodes that need the message itself, so they fail in prehashed mode.
+static int needs_message(uint8_t opcode) {
+    switch (opcode) {
+    case OP_SIGVERIFYED25519:
+    case OP_SLHDSAVERIFY:
+    case OP_ETHVERIFY:
+    case OP_MSGFIELD:
+    case OP_CHECKNONCE:
+        return 1;
+    default:
+        return 0;
+    }
+}
+
+int eval_with_ctx(eval_t *e, const uint8_t *code, size_t code_len,
+                  const eval_ctx_t *ctx) {
+    const uint8_t *xmsg = ctx->msg;
+    size_t xmsg_len = ctx->msg_len;
+
+    // A cached digest belongs to the message of one evaluation
+    e->digest_alg = 0;
     size_t pc = 0;
+    uint8_t branches[MAX_BRANCH_DEPTH];
+    int depth = 0;
 
     while (pc < code_len) {
         uint8_t opcode = code[pc];
 
+        if (opcode == OP_IF || opcode == OP_ELSE || opcode == OP_ENDIF) {
+            if (do_control_flow(e, opcode, branches, &depth) != 0) return -1;
+            pc++;
+            continue;
+        }
+
+        if (!branches_taken(branches, depth)) {
+            // branch not taken: step over the instruction without running it
+            size_t n = instruction_length(code, code_len, pc);
+            if (n == 0) return -1;
+            pc += n;
+            continue;
+        }
+
+        if (ctx->prehashed && needs_message(opcode)) return -1;
+
         switch (opcode) {
         case OP_ADD: {
             uint8_t a, b;
@@ -121,6 +1052,61 @@ int eval_with_xmsg(eval_t *e, const uint8_t *code, size_t code_len,
             pc++;
             break;
         }
+        case OP_THRESHOLD: {
+            if (do_threshold(e) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_TOALTSTACK: {
+            uint8_t a;
+            if (stack_pop(&e->stack, &a) != 0) return -1;
+            if (e->alt_top >= MAX_ALT_STACK_SIZE) return -1;
+            e->alt[e->alt_top++] = a;
+            pc++;
+            break;
+        }
+        case OP_FROMALTSTACK: {
+            if (e->alt_top <= 0) return -1;
+            if (stack_push(&e->stack, e->alt[--e->alt_top]) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_NUMADD:
+        case OP_NUMSUB:
+        case OP_NUMMUL:
+        case OP_NUMLESSTHAN:
+        case OP_NUMEQUAL: {
+            if (do_num_binary(e, opcode) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_TONUM: {
+            uint8_t a;
+            if (stack_pop(&e->stack, &a) != 0) return -1;
+            if (stack_push_num(&e->stack, a) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_EQUAL: {
+            if (do_equal(e) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_CAT: {
+            if (do_cat(e) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SPLIT: {
+            if (do_split(e) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SIZE: {
+            if (do_size(e) != 0) return -1;
+            pc++;
+            break;
+        }
         case OP_PUSH: {
             if (pc + 1 >= code_len) return -1; // missing length operand
             uint8_t how_many = code[pc + 1];
@@ -131,13 +1117,123 @@ int eval_with_xmsg(eval_t *e, const uint8_t *code, size_t code_len,
             pc = pc + 2 + how_many;
             break;
         }
+        case OP_PUSHLARGE: {
+            size_t n = instruction_length(code, code_len, pc);
+            if (n == 0) return -1;
+            if (e->n_objects >= MAX_OBJECTS) return -1;
+            e->objects[e->n_objects] = code + pc + 3;
+            e->object_lens[e->n_objects] = n - 3;
+            e->n_objects++;
+            pc += n;
+            break;
+        }
+        case OP_SLHDSAVERIFY: {
+            if (do_slhdsaverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_RSAVERIFY: {
+            if (do_rsaverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_WEBAUTHNVERIFY: {
+            if (do_webauthnverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SSHSIGVERIFY: {
+            if (do_sshsigverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_ETHVERIFY: {
+            if (do_ethverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
         case OP_SIGVERIFY: {
-            if (do_sigverify(e, xmsg, xmsg_len) != 0) return -1;
+            if (do_sigverify(e, ctx) != 0) return -1;
             pc++;
             break;
         }
         case OP_MULTISIGVERIFY: {
-            if (do_multisigverify(e, xmsg, xmsg_len) != 0) return -1;
+            if (do_multisigverify(e, ctx, 0, 0) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_STRICTMULTISIGVERIFY: {
+            if (do_multisigverify(e, ctx, 1, 0) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SIGVERIFYSECP256K1: {
+            if (do_sigverify_typed(e, ctx, KEY_TYPE_SECP256K1) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SIGVERIFYSCHNORR: {
+            if (do_sigverify_typed(e, ctx, KEY_TYPE_SCHNORR) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SIGVERIFYED25519: {
+            if (do_sigverify_typed(e, ctx, KEY_TYPE_ED25519) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SIGVERIFYHASH: {
+            if (do_sigverifyhash(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_MIXEDMULTISIGVERIFY: {
+            if (do_mixedmultisigverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_SIGVERIFYRAW: {
+            if (do_sigverify_raw(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_MULTISIGVERIFYRAW: {
+            if (do_multisigverify(e, ctx, 1, 1) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_CHECKSIGFROMSTACK: {
+            if (do_checksigfromstack(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_WEIGHTEDSIGVERIFY: {
+            if (do_weightedsigverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_MERKLESIGVERIFY: {
+            if (do_merklesigverify(e, ctx) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_CHECKSECURITYVERSION: {
+            if (do_checksecurityversion(e, ctx->security_version) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_MSGFIELD: {
+            if (do_msgfield(e, xmsg, xmsg_len) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_CHECKPKHASH: {
+            if (do_checkpkhash(e) != 0) return -1;
+            pc++;
+            break;
+        }
+        case OP_CHECKNONCE: {
+            if (do_checknonce(e, ctx) != 0) return -1;
             pc++;
             break;
         }
@@ -146,9 +1242,48 @@ int eval_with_xmsg(eval_t *e, const uint8_t *code, size_t code_len,
         }
     }
 
+    if (depth != 0) return -1; // unterminated OP_IF
+
     return 0;
 }
 
 int eval_run(eval_t *e, const uint8_t *code, size_t code_len) {
     return eval_with_xmsg(e, code, code_len, (const uint8_t *)"", 0);
 }
+
+int eval_digest_algs(const uint8_t *code, size_t code_len, uint8_t *algs) {
+    *algs = 1 << HASH_SHA256;
+    int pushed = 0;   // whether last holds the last byte of consecutive OP_PUSHes
+    uint8_t last = 0;
+    for (size_t pc = 0; pc < code_len;) {
+        size_t n = instruction_length(code, code_len, pc);
+        if (n == 0) return -1;
+        uint8_t opcode = code[pc];
+        if (needs_message(opcode)) return -1;
+        switch (opcode) {
+        case OP_PUSH:
+            if (n > 2) {
+                last = code[pc + n - 1];
+                pushed = 1;
+            }
+            break;
+        case OP_SIGVERIFYHASH:
+            if (pushed && hash_size(last) != 0) {
+                *algs |= (uint8_t)(1 << last);
+            } else {
+                for (int alg = 1; alg <= HASH_MAX_ALG; alg++) *algs |= (uint8_t)(1 << alg);
+            }
+            pushed = 0;
+            break;
+        case OP_SSHSIGVERIFY:
+            // the signature blob names SHA-256 or SHA-512
+            *algs |= 1 << HASH_SHA512;
+            pushed = 0;
+            break;
+        default:
+            pushed = 0;
+        }
+        pc += n;
+    }
+    return 0;
+}
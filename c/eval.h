@@ -3,6 +3,7 @@
 #include <stdint.h>
 #include <stddef.h>
 #include "stack.h"
+#include "hash.h"
 
 #define OP_ADD            1
 #define OP_MUL            2
@@ -12,13 +13,107 @@
 #define OP_AND            6
 #define OP_OR             7
 #define OP_NOT            8
+#define OP_IF             9
+#define OP_ELSE           10
+#define OP_ENDIF          11
+#define OP_THRESHOLD      12
+#define OP_TOALTSTACK     13
+#define OP_FROMALTSTACK   14
+#define OP_NUMADD         15
+#define OP_NUMSUB         16
+#define OP_NUMMUL         17
+#define OP_NUMLESSTHAN    18
+#define OP_NUMEQUAL       19
+#define OP_TONUM          20
+#define OP_WEIGHTEDSIGVERIFY 21
+#define OP_MERKLESIGVERIFY 22
+#define OP_CHECKSECURITYVERSION 23
+#define OP_MSGFIELD       24
+#define OP_EQUAL          25
+#define OP_CHECKSIGFROMSTACK 26
+#define OP_CAT            27
+#define OP_SPLIT          28
+#define OP_SIZE           29
+#define OP_CHECKNONCE     30
+#define OP_CHECKPKHASH    31
+#define OP_STRICTMULTISIGVERIFY 32
+#define OP_SIGVERIFYRAW   33
+#define OP_MULTISIGVERIFYRAW 34
+#define OP_SIGVERIFYSECP256K1 35
+#define OP_SIGVERIFYSCHNORR 36
+#define OP_MIXEDMULTISIGVERIFY 37
+#define OP_PUSHLARGE      38
+#define OP_SLHDSAVERIFY   39
+#define OP_RSAVERIFY      40
+#define OP_WEBAUTHNVERIFY 41
+#define OP_SSHSIGVERIFY   42
+#define OP_ETHVERIFY      43
+#define OP_SIGVERIFYED25519 44
+#define OP_SIGVERIFYHASH  45
+
+// Key types of OP_MIXEDMULTISIGVERIFY
+#define KEY_TYPE_P256      1
+#define KEY_TYPE_SECP256K1 2
+#define KEY_TYPE_SCHNORR   3
+#define KEY_TYPE_ED25519   4
+#define KEY_TYPE_P384      5 // OP_SIGVERIFYHASH only
+
+#define RAW_SIG_LEN       64
+
+#define MAX_BRANCH_DEPTH  16
+#define MAX_ALT_STACK_SIZE 64
+#define MAX_MERKLE_DEPTH  24
+#define MAX_OBJECTS       8
 
 typedef struct {
     xstack_t stack;
+    uint8_t alt[MAX_ALT_STACK_SIZE];
+    int alt_top;
+    // Operands of OP_PUSHLARGE, in order. They point into the evaluated
+    // code, which must outlive them (for machine001, the xsig's objects
+    // are read while evaluating the xpubkey).
+    const uint8_t *objects[MAX_OBJECTS];
+    size_t object_lens[MAX_OBJECTS];
+    int n_objects;
+    // Digest of the message with HASH_* algorithm digest_alg (0 for none),
+    // kept so that consecutive signature opcodes hash the message once.
+    uint8_t digest[HASH_MAX_DIGEST_LEN];
+    uint8_t digest_alg;
 } eval_t;
 
+// Verifier-supplied state visible to a program, matching Go's
+// lowlevel.Context. Zero-initialize unused fields.
+typedef struct {
+    const uint8_t *msg;
+    size_t msg_len;
+    int64_t security_version; // must be >= 0
+    // Optional: returns nonzero if the key with this fingerprint (SHA-256
+    // of the public key as pushed, e.g. compressed for P-256) is revoked.
+    // Signature opcodes treat a revoked key as not matching any signature.
+    // NULL revokes nothing.
+    int (*is_revoked)(const uint8_t fingerprint[32], void *arg);
+    void *revoked_arg;
+    // Fresh challenge issued by the verifier, checked by OP_CHECKNONCE.
+    const uint8_t *nonce;
+    size_t nonce_len;
+    // Prehashed mode, matching Go's Context.Digests: if prehashed is
+    // nonzero, msg is ignored and never read. Signature opcodes use
+    // digests[alg], the hash_size(alg)-byte digest of the message with
+    // HASH_* algorithm alg, and fail if it is NULL; opcodes that need the
+    // message itself (OP_SIGVERIFYED25519, OP_SLHDSAVERIFY, OP_ETHVERIFY,
+    // OP_MIXEDMULTISIGVERIFY with an Ed25519 key, OP_MSGFIELD and
+    // OP_CHECKNONCE) fail.
+    int prehashed;
+    const uint8_t *digests[HASH_MAX_ALG + 1];
+} eval_ctx_t;
+
 void eval_init(eval_t *e);
 
+// Evaluate bytecode against a context.
+// Returns 0 on success, nonzero on error.
+int eval_with_ctx(eval_t *e, const uint8_t *code, size_t code_len,
+                  const eval_ctx_t *ctx);
+
 // Evaluate bytecode with message (for signature verification).
 // Returns 0 on success, nonzero on error.
 int eval_with_xmsg(eval_t *e, const uint8_t *code, size_t code_len,
@@ -26,3 +121,11 @@ int eval_with_xmsg(eval_t *e, const uint8_t *code, size_t code_len,
 
 // Evaluate bytecode without message (xmsg = empty).
 int eval_run(eval_t *e, const uint8_t *code, size_t code_len);
+
+// Sets bit (1 << alg) of *algs for each HASH_* digest of the message that
+// an evaluation of code may ask for in prehashed mode, matching Go's
+// lowlevel.DigestAlgorithms: SHA-256 always, the algorithm pushed right
+// before an OP_SIGVERIFYHASH (all of them if it comes from elsewhere), and
+// SHA-512 for OP_SSHSIGVERIFY. Returns -1 if code is truncated or has an
+// opcode that needs the message itself, even in a branch not taken.
+int eval_digest_algs(const uint8_t *code, size_t code_len, uint8_t *algs);
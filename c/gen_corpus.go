@@ -55,11 +55,11 @@ func main() {
 	_, pk1, sig1 := crypto.HelperVerifyData(msg1)
 	a1 := machines.MachineCode{}
 	a1.Append(ll.Push(sig1))
-	xsig1 := a1.Serialize(machines.CodeTypeXSig)
+	xsig1 := a1.SerializeUnchecked(machines.CodeTypeXSig)
 	b1 := machines.MachineCode{}
 	b1.Append(ll.Push(pk1))
 	b1.Append(ll.SignatureVerify())
-	xpk1 := b1.Serialize(machines.CodeTypeXPublicKey)
+	xpk1 := b1.SerializeUnchecked(machines.CodeTypeXPublicKey)
 	writeCorpus(mdir, "single_sig", encodeMachine001Input(xsig1, msg1, xpk1))
 
 	// 2-of-3 multisig
@@ -70,7 +70,7 @@ func main() {
 	a3 := machines.MachineCode{}
 	a3.Append(ll.Push(sig3a))
 	a3.Append(ll.Push(sig3b))
-	xsig3 := a3.Serialize(machines.CodeTypeXSig)
+	xsig3 := a3.SerializeUnchecked(machines.CodeTypeXSig)
 	b3 := machines.MachineCode{}
 	b3.Append(ll.Push(pk3a))
 	b3.Append(ll.Push(pk3b))
@@ -78,7 +78,7 @@ func main() {
 	b3.Append(ll.Push1(2))
 	b3.Append(ll.Push1(3))
 	b3.Append(ll.MultisigVerify())
-	xpk3 := b3.Serialize(machines.CodeTypeXPublicKey)
+	xpk3 := b3.SerializeUnchecked(machines.CodeTypeXPublicKey)
 	writeCorpus(mdir, "multisig_2of3", encodeMachine001Input(xsig3, msg3, xpk3))
 
 	// Empty / garbage
@@ -89,8 +89,8 @@ func main() {
 	mc := machines.MachineCode{}
 	mc.Code = []byte{0xFF}
 	writeCorpus(mdir, "bad_opcode_xsig", encodeMachine001Input(
-		mc.Serialize(machines.CodeTypeXSig), []byte("msg"),
-		b1.Serialize(machines.CodeTypeXPublicKey)))
+		mc.SerializeUnchecked(machines.CodeTypeXSig), []byte("msg"),
+		b1.SerializeUnchecked(machines.CodeTypeXPublicKey)))
 
 	// --- eval corpus ---
 	edir := "c/corpus/eval"
@@ -123,6 +123,16 @@ func main() {
 	asm.Append(ll.Not())
 	writeCorpus(edir, "not", encodeEvalInput([]byte{}, asm.Code))
 
+	// push 0, if, push 42, else, push 7, endif → 7
+	asm = ll.Assembler{}
+	asm.Append(ll.Push1(0))
+	asm.Append(ll.If())
+	asm.Append(ll.Push1(42))
+	asm.Append(ll.Else())
+	asm.Append(ll.Push1(7))
+	asm.Append(ll.EndIf())
+	writeCorpus(edir, "if_else", encodeEvalInput([]byte{}, asm.Code))
+
 	// Sigverify with message
 	msgE := []byte("test")
 	_, pkE, sigE := crypto.HelperVerifyData(msgE)
@@ -5,9 +5,24 @@
 package main
 
 import (
+	"bytes"
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"math"
+	"math/big"
+	mrand "math/rand"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/oreparaz/xsig/internal/crypto"
 	ll "github.com/oreparaz/xsig/internal/lowlevel"
@@ -17,34 +32,53 @@ import (
 // ---- types ----
 
 type EvalTV struct {
-	Name        string
-	Code        []byte
-	Msg         []byte
-	ExpectError bool
-	ExpectStack []byte
+	Name            string
+	Code            []byte
+	Msg             []byte
+	SecurityVersion int64
+	Revoked         []byte // concatenated fingerprints
+	Nonce           []byte
+	Prehashed       bool
+	Digests         []byte // alg || digest, concatenated
+	ExpectError     bool
+	ExpectStack     []byte
 }
 
 type M001TV struct {
-	Name     string
-	XPubKey  []byte
-	XSig     []byte
-	Msg      []byte
-	Expected int
+	Name            string
+	XPubKey         []byte
+	XSig            []byte
+	Msg             []byte
+	SecurityVersion int64
+	Revoked         []byte // concatenated fingerprints
+	Nonce           []byte
+	Prehashed       bool
+	Digests         []byte // alg || digest, concatenated
+	Expected        int
 }
 
 // ---- helpers ----
 
 func evalTV(name string, code []byte, msg []byte) EvalTV {
+	return evalTVCtx(name, code, &ll.Context{Xmsg: msg})
+}
+
+func evalTVCtx(name string, code []byte, ctx *ll.Context) EvalTV {
 	e := ll.NewEval()
-	err := e.EvalWithXmsg(code, msg)
+	err := e.EvalWithContext(code, ctx)
 	stack := make([]byte, len(e.Stack.S))
 	copy(stack, e.Stack.S)
 	return EvalTV{
-		Name:        name,
-		Code:        code,
-		Msg:         msg,
-		ExpectError: err != nil,
-		ExpectStack: stack,
+		Name:            name,
+		Code:            code,
+		Msg:             ctx.Xmsg,
+		SecurityVersion: ctx.SecurityVersion,
+		Revoked:         revokedBytes(ctx.Revoked),
+		Nonce:           ctx.Nonce,
+		Prehashed:       ctx.Digests != nil,
+		Digests:         digestsBytes(ctx.Digests),
+		ExpectError:     err != nil,
+		ExpectStack:     stack,
 	}
 }
 
@@ -55,24 +89,126 @@ func evalTVAsm(name string, build func(a *ll.Assembler), msg []byte) EvalTV {
 }
 
 func m001TV(name string, xpubkey, xsig, msg []byte) M001TV {
-	result := machines.RunMachine001(xpubkey, xsig, msg)
+	return m001TVCtx(name, xpubkey, xsig, &ll.Context{Xmsg: msg})
+}
+
+func m001TVCtx(name string, xpubkey, xsig []byte, ctx *ll.Context) M001TV {
+	result := machines.RunMachine001WithContext(xpubkey, xsig, ctx)
 	expected := 0
 	if result {
 		expected = 1
 	}
-	return M001TV{Name: name, XPubKey: xpubkey, XSig: xsig, Msg: msg, Expected: expected}
+	return M001TV{Name: name, XPubKey: xpubkey, XSig: xsig, Msg: ctx.Xmsg,
+		SecurityVersion: ctx.SecurityVersion, Revoked: revokedBytes(ctx.Revoked),
+		Nonce: ctx.Nonce, Prehashed: ctx.Digests != nil, Digests: digestsBytes(ctx.Digests),
+		Expected: expected}
+}
+
+// revokedBytes flattens a revocation set into sorted, concatenated fingerprints.
+func revokedBytes(set ll.RevocationSet) []byte {
+	var fps [][]byte
+	for fp, revoked := range set {
+		if revoked {
+			fp := fp
+			fps = append(fps, fp[:])
+		}
+	}
+	sort.Slice(fps, func(i, j int) bool { return bytes.Compare(fps[i], fps[j]) < 0 })
+	return bytes.Join(fps, nil)
+}
+
+// revocationSet is the inverse of revokedBytes.
+func revocationSet(fingerprints []byte) ll.RevocationSet {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	set := ll.RevocationSet{}
+	for i := 0; i+32 <= len(fingerprints); i += 32 {
+		var fp [32]byte
+		copy(fp[:], fingerprints[i:])
+		set[fp] = true
+	}
+	return set
+}
+
+// digestsBytes flattens prehashed digests into alg || digest entries in
+// increasing alg order.
+func digestsBytes(digests map[byte][]byte) []byte {
+	var out []byte
+	for alg := 0; alg < 256; alg++ {
+		if d, ok := digests[byte(alg)]; ok {
+			out = append(out, byte(alg))
+			out = append(out, d...)
+		}
+	}
+	return out
+}
+
+// messageDigests returns the algs digests of msg, for a prehashed context.
+func messageDigests(msg []byte, algs ...byte) map[byte][]byte {
+	digests := map[byte][]byte{}
+	for _, alg := range algs {
+		digests[alg] = crypto.Digest(alg, msg)
+	}
+	return digests
+}
+
+var allHashes = []byte{crypto.HashSHA256, crypto.HashSHA384, crypto.HashSHA512, crypto.HashSHA3_256}
+
+// prehashedEvalTests re-runs the vectors that have a message in prehashed
+// mode, with every digest of it and with SHA-256 alone: signature opcodes
+// must give the same result, missing digests and opcodes that need the
+// message itself must fail as in Go. The message stays in the vector, which
+// the evaluator must ignore.
+func prehashedEvalTests(tests []EvalTV) []EvalTV {
+	var out []EvalTV
+	for _, tv := range tests {
+		if len(tv.Msg) == 0 {
+			continue
+		}
+		for _, algs := range [][]byte{allHashes, {crypto.HashSHA256}} {
+			name := tv.Name + "_prehashed"
+			if len(algs) == 1 {
+				name += "_sha256"
+			}
+			out = append(out, evalTVCtx(name, tv.Code, &ll.Context{
+				Xmsg: tv.Msg, SecurityVersion: tv.SecurityVersion, Revoked: revocationSet(tv.Revoked),
+				Nonce: tv.Nonce, Digests: messageDigests(tv.Msg, algs...)}))
+		}
+	}
+	return out
+}
+
+// prehashedM001Tests is prehashedEvalTests for machine001 vectors.
+func prehashedM001Tests(tests []M001TV) []M001TV {
+	var out []M001TV
+	for _, tv := range tests {
+		if len(tv.Msg) == 0 {
+			continue
+		}
+		for _, algs := range [][]byte{allHashes, {crypto.HashSHA256}} {
+			name := tv.Name + "_prehashed"
+			if len(algs) == 1 {
+				name += "_sha256"
+			}
+			out = append(out, m001TVCtx(name, tv.XPubKey, tv.XSig, &ll.Context{
+				Xmsg: tv.Msg, SecurityVersion: tv.SecurityVersion, Revoked: revocationSet(tv.Revoked),
+				Nonce: tv.Nonce, Digests: messageDigests(tv.Msg, algs...)}))
+		}
+	}
+	return out
 }
 
 func serializeXSig(build func(mc *machines.MachineCode)) []byte {
 	mc := machines.MachineCode{}
 	build(&mc)
-	return mc.Serialize(machines.CodeTypeXSig)
+	return mc.SerializeUnchecked(machines.CodeTypeXSig)
 }
 
 func serializeXPubKey(build func(mc *machines.MachineCode)) []byte {
 	mc := machines.MachineCode{}
 	build(&mc)
-	return mc.Serialize(machines.CodeTypeXPublicKey)
+	return mc.SerializeUnchecked(machines.CodeTypeXPublicKey)
 }
 
 // ---- eval test generators ----
@@ -269,10 +405,10 @@ func errorTests() []EvalTV {
 		evalTV("and_one_element", []byte{0x03, 0x01, 0x42, 0x06}, nil),
 		evalTV("or_one_element", []byte{0x03, 0x01, 0x42, 0x07}, nil),
 		// Unknown opcodes
-		evalTV("unknown_opcode_09", []byte{0x09}, nil),
-		evalTV("unknown_opcode_0A", []byte{0x0A}, nil),
+		evalTV("unknown_opcode_F0", []byte{0xF0}, nil),
+		evalTV("unknown_opcode_FE", []byte{0xFE}, nil),
 		evalTV("unknown_opcode_FF", []byte{0xFF}, nil),
-		evalTV("unknown_after_valid", []byte{0x03, 0x01, 0x42, 0x09}, nil),
+		evalTV("unknown_after_valid", []byte{0x03, 0x01, 0x42, 0xF0}, nil),
 		// Sigverify on empty stack
 		evalTV("sigverify_empty_stack", []byte{0x04}, nil),
 		// Multisigverify on empty stack
@@ -307,108 +443,2138 @@ func complexSequenceTests() []EvalTV {
 	}
 }
 
-func sigverifyEvalTests() []EvalTV {
-	msg := []byte("test_sigverify")
-	_, pk, sig := crypto.HelperVerifyData(msg)
+func conditionalTests() []EvalTV {
+	ifElse := func(cond int) func(a *ll.Assembler) {
+		return func(a *ll.Assembler) {
+			a.Append(ll.Push1(cond)); a.Append(ll.If())
+			a.Append(ll.Push1(42)); a.Append(ll.Else()); a.Append(ll.Push1(7))
+			a.Append(ll.EndIf())
+		}
+	}
+	nested := func(outer, inner int) func(a *ll.Assembler) {
+		return func(a *ll.Assembler) {
+			a.Append(ll.Push1(inner)); a.Append(ll.Push1(outer))
+			a.Append(ll.If()); a.Append(ll.If()); a.Append(ll.Push1(1))
+			a.Append(ll.Else()); a.Append(ll.Push1(2)); a.Append(ll.EndIf())
+			a.Append(ll.Else()); a.Append(ll.Push1(3)); a.Append(ll.EndIf())
+		}
+	}
+	deep := func(depth int) []byte {
+		a := ll.Assembler{}
+		for i := 0; i < depth; i++ {
+			a.Append(ll.Push1(1)); a.Append(ll.If())
+		}
+		for i := 0; i < depth; i++ {
+			a.Append(ll.EndIf())
+		}
+		return a.Code
+	}
+	return []EvalTV{
+		evalTVAsm("if_taken", ifElse(1), nil),
+		evalTVAsm("if_not_taken", ifElse(0), nil),
+		evalTVAsm("if_cond_not_bool", ifElse(2), nil),
+		evalTVAsm("if_nested_11", nested(1, 1), nil),
+		evalTVAsm("if_nested_10", nested(1, 0), nil),
+		evalTVAsm("if_nested_01", nested(0, 1), nil),
+		evalTVAsm("if_nested_00", nested(0, 0), nil),
+		evalTV("if_no_else", []byte{0x03, 0x01, 0x05, 0x03, 0x01, 0x00, 0x09, 0x08, 0x0b}, nil),
+		evalTV("if_skip_underflow", []byte{0x03, 0x01, 0x00, 0x09, 0x01, 0x04, 0x05, 0x0b}, nil),
+		evalTV("if_skip_push_data", []byte{0x03, 0x01, 0x00, 0x09, 0x03, 0x02, 0x0b, 0x0a, 0x0b}, nil),
+		evalTV("if_skip_truncated_push", []byte{0x03, 0x01, 0x00, 0x09, 0x03, 0x05, 0x01}, nil),
+		evalTV("if_empty_stack", []byte{0x09, 0x0b}, nil),
+		evalTV("if_unterminated", []byte{0x03, 0x01, 0x01, 0x09}, nil),
+		evalTV("if_else_unterminated", []byte{0x03, 0x01, 0x00, 0x09, 0x0a}, nil),
+		evalTV("else_without_if", []byte{0x0a}, nil),
+		evalTV("endif_without_if", []byte{0x0b}, nil),
+		evalTV("if_double_endif", []byte{0x03, 0x01, 0x01, 0x09, 0x0b, 0x0b}, nil),
+		evalTV("if_double_else", []byte{0x03, 0x01, 0x01, 0x09, 0x0a, 0x0a, 0x0b}, nil),
+		evalTV("if_max_depth", deep(ll.MaxBranchDepth), nil),
+		evalTV("if_too_deep", deep(ll.MaxBranchDepth+1), nil),
+	}
+}
+
+func thresholdTests() []EvalTV {
+	thresh := func(k, n int, conds ...int) func(a *ll.Assembler) {
+		return func(a *ll.Assembler) {
+			for _, c := range conds {
+				a.Append(ll.Push1(c))
+			}
+			a.Append(ll.Push1(k)); a.Append(ll.Push1(n)); a.Append(ll.Threshold())
+		}
+	}
+	return []EvalTV{
+		evalTVAsm("threshold_2of3_pass", thresh(2, 3, 1, 0, 1), nil),
+		evalTVAsm("threshold_2of3_fail", thresh(2, 3, 0, 0, 1), nil),
+		evalTVAsm("threshold_3of3_pass", thresh(3, 3, 1, 1, 1), nil),
+		evalTVAsm("threshold_1of1_fail", thresh(1, 1, 0), nil),
+		evalTVAsm("threshold_keeps_rest", thresh(1, 2, 0xAA, 0, 1), nil),
+		evalTVAsm("threshold_n_zero", thresh(1, 0, 1), nil),
+		evalTVAsm("threshold_k_zero", thresh(0, 1, 1), nil),
+		evalTVAsm("threshold_k_gt_n", thresh(2, 1, 1), nil),
+		evalTVAsm("threshold_not_bool", thresh(1, 2, 1, 2), nil),
+		evalTVAsm("threshold_underflow", thresh(1, 3, 1, 1), nil),
+		evalTV("threshold_empty_stack", []byte{0x0c}, nil),
+		evalTV("altstack_roundtrip", []byte{0x03, 0x01, 0x01, 0x03, 0x01, 0x02, 0x0d, 0x03, 0x01, 0x03, 0x0e}, nil),
+		evalTV("toaltstack_empty", []byte{0x0d}, nil),
+		evalTV("fromaltstack_empty", []byte{0x0e}, nil),
+		evalTV("altstack_overflow", func() []byte {
+			var code []byte
+			for i := 0; i <= ll.MaxAltStackSize; i++ {
+				code = append(code, 0x03, 0x01, 0x01, 0x0d)
+			}
+			return code
+		}(), nil),
+	}
+}
+
+func numTests() []EvalTV {
+	binop := func(x, y int64, op func() ll.Instruction) func(a *ll.Assembler) {
+		return func(a *ll.Assembler) {
+			a.Append(ll.PushNum(x)); a.Append(ll.PushNum(y)); a.Append(op())
+		}
+	}
+	return []EvalTV{
+		evalTVAsm("num_add", binop(1000, 24, ll.NumAdd), nil),
+		evalTVAsm("num_add_negative", binop(-5, 3, ll.NumAdd), nil),
+		evalTVAsm("num_add_to_zero", binop(-300, 300, ll.NumAdd), nil),
+		evalTVAsm("num_add_carry_sign", binop(127, 1, ll.NumAdd), nil),
+		evalTVAsm("num_add_overflow", binop(math.MaxInt64, 1, ll.NumAdd), nil),
+		evalTVAsm("num_add_underflow", binop(math.MinInt64, -1, ll.NumAdd), nil),
+		evalTVAsm("num_sub", binop(1000, 24, ll.NumSub), nil),
+		evalTVAsm("num_sub_negative", binop(24, 1000, ll.NumSub), nil),
+		evalTVAsm("num_sub_min", binop(-1, math.MaxInt64, ll.NumSub), nil),
+		evalTVAsm("num_sub_overflow", binop(math.MinInt64, 1, ll.NumSub), nil),
+		evalTVAsm("num_sub_overflow_neg_min", binop(0, math.MinInt64, ll.NumSub), nil),
+		evalTVAsm("num_mul", binop(1000, -3, ll.NumMul), nil),
+		evalTVAsm("num_mul_large", binop(1<<40, 1<<20, ll.NumMul), nil),
+		evalTVAsm("num_mul_min", binop(-(1 << 32), 1<<31, ll.NumMul), nil),
+		evalTVAsm("num_mul_overflow", binop(1<<32, 1<<31, ll.NumMul), nil),
+		evalTVAsm("num_mul_overflow_min_neg1", binop(math.MinInt64, -1, ll.NumMul), nil),
+		evalTVAsm("num_mul_overflow_neg_neg", binop(-(1 << 32), -(1 << 31), ll.NumMul), nil),
+		evalTVAsm("num_lt_true", binop(-300, 5, ll.NumLessThan), nil),
+		evalTVAsm("num_lt_false", binop(5, 5, ll.NumLessThan), nil),
+		evalTVAsm("num_eq_true", binop(1675209600, 1675209600, ll.NumEqual), nil),
+		evalTVAsm("num_eq_false", binop(0, -1, ll.NumEqual), nil),
+		evalTVAsm("tonum_zero", func(a *ll.Assembler) { a.Append(ll.Push1(0)); a.Append(ll.ToNum()) }, nil),
+		evalTVAsm("tonum_200", func(a *ll.Assembler) { a.Append(ll.Push1(200)); a.Append(ll.ToNum()) }, nil),
+		evalTVAsm("num_count_256", func(a *ll.Assembler) {
+			a.Append(ll.PushNum(0))
+			for i := 0; i < 256; i++ {
+				a.Append(ll.Push1(1)); a.Append(ll.ToNum()); a.Append(ll.NumAdd())
+			}
+		}, nil),
+		evalTV("num_not_minimal_zero", []byte{0x03, 0x02, 0x01, 0x00, 0x03, 0x01, 0x00, 0x0f}, nil),
+		evalTV("num_not_minimal_pos", []byte{0x03, 0x03, 0x02, 0x05, 0x00, 0x03, 0x01, 0x00, 0x0f}, nil),
+		evalTV("num_not_minimal_neg", []byte{0x03, 0x03, 0x02, 0x80, 0xff, 0x03, 0x01, 0x00, 0x0f}, nil),
+		evalTV("num_too_long", []byte{0x03, 0x0a, 0x09, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0x03, 0x01, 0x00, 0x10}, nil),
+		evalTV("num_truncated", []byte{0x03, 0x02, 0x01, 0x03, 0x03, 0x01, 0x00, 0x11}, nil),
+		evalTV("num_empty_stack", []byte{0x0f}, nil),
+		evalTV("tonum_empty_stack", []byte{0x14}, nil),
+	}
+}
+
+func sigverifyEvalTests() []EvalTV {
+	msg := []byte("test_sigverify")
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	return []EvalTV{
+		evalTVAsm("sigverify_valid", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(ll.SignatureVerify())
+		}, msg),
+		evalTVAsm("sigverify_wrong_msg", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(ll.SignatureVerify())
+		}, []byte("wrong")),
+		// Sigverify then arithmetic
+		evalTVAsm("sigverify_then_not", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.Push(pk))
+			a.Append(ll.SignatureVerify()); a.Append(ll.Not())
+		}, msg),
+	}
+}
+
+func securityVersionTests() []EvalTV {
+	check := func(v int64) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushNum(v)); a.Append(ll.CheckSecurityVersion())
+		return a.Code
+	}
+	return []EvalTV{
+		evalTVCtx("secver_equal", check(5), &ll.Context{SecurityVersion: 5}),
+		evalTVCtx("secver_newer", check(6), &ll.Context{SecurityVersion: 5}),
+		evalTVCtx("secver_older", check(4), &ll.Context{SecurityVersion: 5}),
+		evalTVCtx("secver_zero_zero", check(0), &ll.Context{}),
+		evalTVCtx("secver_zero_device_one", check(0), &ll.Context{SecurityVersion: 1}),
+		evalTVCtx("secver_large", check(1<<40), &ll.Context{SecurityVersion: 1<<40 - 1}),
+		evalTVCtx("secver_large_older", check(1<<40), &ll.Context{SecurityVersion: 1<<40 + 1}),
+		evalTVCtx("secver_max", check(math.MaxInt64), &ll.Context{SecurityVersion: math.MaxInt64}),
+		evalTVCtx("secver_negative_version", check(-1), &ll.Context{}),
+		evalTVCtx("secver_negative_device", check(1), &ll.Context{SecurityVersion: -1}),
+		evalTVCtx("secver_empty_stack", []byte{ll.OP_CHECKSECURITYVERSION}, &ll.Context{}),
+		evalTVCtx("secver_not_a_num", []byte{ll.OP_PUSH, 1, 9, ll.OP_CHECKSECURITYVERSION}, &ll.Context{}),
+		evalTVCtx("secver_in_branch", func() []byte {
+			a := ll.Assembler{}
+			a.Append(ll.PushNum(3)); a.Append(ll.CheckSecurityVersion())
+			a.Append(ll.If()); a.Append(ll.Push1(7)); a.Append(ll.Else()); a.Append(ll.Push1(8)); a.Append(ll.EndIf())
+			return a.Code
+		}(), &ll.Context{SecurityVersion: 4}),
+	}
+}
+
+func msgFieldTests() []EvalTV {
+	msg, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: 0, Value: []byte{}},
+		{Tag: 1, Value: []byte{0x42}},
+		{Tag: 2, Value: []byte("debug-unlock")},
+		{Tag: 4, Value: ll.EncodeNum(12)},
+		{Tag: 200, Value: make([]byte, 255)},
+		{Tag: 201, Value: make([]byte, 256)},
+	})
+	field := func(tag int) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push1(tag)); a.Append(ll.MsgField())
+		return a.Code
+	}
+	equal := func(x, y []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushBlob(x)); a.Append(ll.PushBlob(y)); a.Append(ll.Equal())
+		return a.Code
+	}
+	fieldEquals := func(tag int, value []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push1(tag)); a.Append(ll.MsgField()); a.Append(ll.PushBlob(value)); a.Append(ll.Equal())
+		return a.Code
+	}
+
+	return []EvalTV{
+		evalTV("equal_empty", equal(nil, nil), nil),
+		evalTV("equal_same", equal([]byte{1, 2, 3}, []byte{1, 2, 3}), nil),
+		evalTV("equal_prefix", equal([]byte{1, 2, 3}, []byte{1, 2}), nil),
+		evalTV("equal_differ", equal([]byte{1, 2, 3}, []byte{1, 2, 4}), nil),
+		evalTV("equal_underflow", []byte{ll.OP_PUSH, 1, 0, ll.OP_EQUAL}, nil),
+		evalTV("equal_short_blob", []byte{ll.OP_PUSH, 2, 0, 5, ll.OP_EQUAL}, nil),
+		evalTV("msgfield_empty_value", field(0), msg),
+		evalTV("msgfield_product", field(1), msg),
+		evalTV("msgfield_command", field(2), msg),
+		evalTV("msgfield_max_blob", field(200), msg),
+		evalTV("msgfield_too_large", field(201), msg),
+		evalTV("msgfield_missing", field(3), msg),
+		evalTV("msgfield_product_is_42", fieldEquals(1, []byte{0x42}), msg),
+		evalTV("msgfield_product_is_43", fieldEquals(1, []byte{0x43}), msg),
+		evalTV("msgfield_command_is_flash", fieldEquals(2, []byte("flash")), msg),
+		evalTVCtx("msgfield_secver", func() []byte {
+			a := ll.Assembler{}
+			a.Append(ll.Push1(4)); a.Append(ll.MsgField()); a.Append(ll.CheckSecurityVersion())
+			return a.Code
+		}(), &ll.Context{Xmsg: msg, SecurityVersion: 13}),
+		evalTV("msgfield_empty_msg", field(1), nil),
+		evalTV("msgfield_truncated_header", field(1), []byte{1, 0}),
+		evalTV("msgfield_truncated_value", field(1), []byte{1, 0, 2, 0x42}),
+		evalTV("msgfield_duplicate_tag", field(1), []byte{1, 0, 1, 0x42, 1, 0, 1, 0x43}),
+		evalTV("msgfield_unsorted", field(1), []byte{2, 0, 0, 1, 0, 1, 0x42}),
+		evalTV("msgfield_trailing_garbage", field(1), []byte{1, 0, 1, 0x42, 0}),
+		evalTV("msgfield_empty_stack", []byte{ll.OP_MSGFIELD}, msg),
+	}
+}
+
+func strictMultisigTests() []EvalTV {
+	msg := []byte("test_strict_multisig")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, _ := crypto.HelperVerifyData(msg)
+
+	multisig := func(a *ll.Assembler, op ll.Instruction, nMin int, pks [][]byte, sigs ...[]byte) {
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		for i := len(pks) - 1; i >= 0; i-- {
+			a.Append(ll.Push(pks[i]))
+		}
+		a.Append(ll.Push1(nMin)); a.Append(ll.Push1(len(pks))); a.Append(op)
+	}
+	loose, strict := ll.MultisigVerify(), ll.StrictMultisigVerify()
+	dup := [][]byte{pk1, pk2, pk1}
+	pks := [][]byte{pk1, pk2, pk3}
+
+	return []EvalTV{
+		evalTVAsm("multisig_dup_key_loose", func(a *ll.Assembler) { multisig(a, loose, 2, dup, sig1, sig1) }, msg),
+		evalTVAsm("multisig_dup_key_strict", func(a *ll.Assembler) { multisig(a, strict, 2, dup, sig1, sig1) }, msg),
+		evalTVAsm("multisig_dup_key_strict_distinct_sigs", func(a *ll.Assembler) { multisig(a, strict, 2, dup, sig1, sig2) }, msg),
+		evalTVAsm("multisig_dup_sig_loose", func(a *ll.Assembler) { multisig(a, loose, 2, pks, sig1, sig1) }, msg),
+		evalTVAsm("multisig_dup_sig_strict", func(a *ll.Assembler) { multisig(a, strict, 2, pks, sig1, sig1) }, msg),
+		evalTVAsm("multisig_strict_ok", func(a *ll.Assembler) { multisig(a, strict, 2, pks, sig2, sig1) }, msg),
+		evalTVAsm("multisig_strict_one_bad", func(a *ll.Assembler) { multisig(a, strict, 2, pks, sig1, []byte{0x30, 0x00}) }, msg),
+		evalTVAsm("multisig_strict_wrong_msg", func(a *ll.Assembler) { multisig(a, strict, 1, pks, sig1) }, []byte("other")),
+		evalTVAsm("multisig_strict_last_key_dup", func(a *ll.Assembler) { multisig(a, strict, 1, [][]byte{pk1, pk2, pk3, pk3}, sig1) }, msg),
+		evalTV("multisig_strict_empty_stack", []byte{ll.OP_STRICTMULTISIGVERIFY}, msg),
+	}
+}
+
+func rawSigTests() []EvalTV {
+	msg := []byte("test_raw_sig")
+	_, pk1, der1 := crypto.HelperVerifyData(msg)
+	_, pk2, der2 := crypto.HelperVerifyData(msg)
+	_, pk3, _ := crypto.HelperVerifyData(msg)
+	sig1, _ := crypto.RawSignature(der1)
+	sig2, _ := crypto.RawSignature(der2)
+	zeroR := append(make([]byte, 32), sig1[32:]...)
+	onesR := append(bytes.Repeat([]byte{0xff}, 32), sig1[32:]...)
+
+	sigverify := func(sig, pk []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(ll.SignatureVerifyRaw())
+		return a.Code
+	}
+	multisig := func(nMin int, pks [][]byte, sigs ...[]byte) []byte {
+		a := ll.Assembler{}
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		for i := len(pks) - 1; i >= 0; i-- {
+			a.Append(ll.Push(pks[i]))
+		}
+		a.Append(ll.Push1(nMin)); a.Append(ll.Push1(len(pks))); a.Append(ll.MultisigVerifyRaw())
+		return a.Code
+	}
+	pks := [][]byte{pk1, pk2, pk3}
+
+	return []EvalTV{
+		evalTV("rawsig_valid", sigverify(sig1, pk1), msg),
+		evalTV("rawsig_wrong_msg", sigverify(sig1, pk1), []byte("other")),
+		evalTV("rawsig_wrong_key", sigverify(sig1, pk2), msg),
+		evalTV("rawsig_der_given", sigverify(der1, pk1), msg),
+		evalTV("rawsig_zero_r", sigverify(zeroR, pk1), msg),
+		evalTV("rawsig_ones_r", sigverify(onesR, pk1), msg),
+		evalTV("rawsig_short_stack", sigverify(sig1[:63], pk1), msg),
+		evalTV("rawsig_empty_stack", []byte{ll.OP_SIGVERIFYRAW}, msg),
+		evalTVCtx("rawsig_revoked", sigverify(sig1, pk1),
+			&ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(pk1): true}}),
+		evalTV("rawmultisig_ok", multisig(2, pks, sig2, sig1), msg),
+		evalTV("rawmultisig_one_bad", multisig(2, pks, sig1, zeroR), msg),
+		evalTV("rawmultisig_dup_sig", multisig(2, pks, sig1, sig1), msg),
+		evalTV("rawmultisig_dup_key", multisig(2, [][]byte{pk1, pk2, pk1}, sig1, sig2), msg),
+		// one signer listed twice: OP_MULTISIGVERIFY would accept it, the raw
+		// variant is always strict
+		evalTV("rawmultisig_dup_key_one_signer", multisig(2, [][]byte{pk1, pk2, pk1}, sig1, sig1), msg),
+		evalTV("rawmultisig_dup_key_1_of_3", multisig(1, [][]byte{pk1, pk2, pk1}, sig1), msg),
+		evalTV("rawmultisig_der_given", multisig(1, pks, der1), msg),
+		evalTV("rawmultisig_empty_stack", []byte{ll.OP_MULTISIGVERIFYRAW}, msg),
+	}
+}
+
+func secp256k1Tests() []EvalTV {
+	msg := []byte("test_secp256k1")
+	_, k1PK, k1Sig := crypto.HelperVerifyDataSecp256k1(msg)
+	_, k1PK2, k1Sig2 := crypto.HelperVerifyDataSecp256k1(msg)
+	_, schnorrPK, schnorrSig := crypto.HelperVerifyDataSchnorr(msg)
+	_, p256PK, p256Sig := crypto.HelperVerifyData(msg)
+	n, _ := hex.DecodeString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141")
+	p, _ := hex.DecodeString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F")
+
+	sigverify := func(sig, pk []byte, op ll.Instruction) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(op)
+		return a.Code
+	}
+	k1 := func(sig, pk []byte) []byte { return sigverify(sig, pk, ll.SignatureVerifySecp256k1()) }
+	schnorr := func(sig, pk []byte) []byte { return sigverify(sig, pk, ll.SignatureVerifySchnorr()) }
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+	highS := append(append([]byte{}, schnorrSig[:32]...), n...)
+	rIsP := append(append([]byte{}, p...), schnorrSig[32:]...)
+	// x = 5 is not on secp256k1: 5^3 + 7 is not a square mod p
+	offCurve := append(make([]byte, 31), 5)
+
+	mixed := func(nMin int, keys []ll.TypedPublicKey, sigs ...[]byte) []byte {
+		a := ll.Assembler{}
+		for _, in := range ll.PushSignatureSlots(sigs) {
+			a.Append(in)
+		}
+		for _, in := range ll.MixedMultisig(nMin, keys) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	keys := []ll.TypedPublicKey{
+		{Type: crypto.KeyTypeP256, PublicKey: p256PK},
+		{Type: crypto.KeyTypeSecp256k1, PublicKey: k1PK},
+		{Type: crypto.KeyTypeSchnorr, PublicKey: schnorrPK},
+	}
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{
+		crypto.KeyFingerprint(k1PK): true,
+		crypto.KeyFingerprint(schnorrPK): true,
+	}}
+
+	return []EvalTV{
+		evalTV("k1_valid", k1(k1Sig, k1PK), msg),
+		evalTV("k1_valid_2", k1(k1Sig2, k1PK2), msg),
+		evalTV("k1_wrong_msg", k1(k1Sig, k1PK), []byte("other")),
+		evalTV("k1_wrong_key", k1(k1Sig, k1PK2), msg),
+		evalTV("k1_other_y", k1(k1Sig, flip(k1PK, 0)), msg),
+		evalTV("k1_off_curve", k1(k1Sig, append([]byte{0x02}, offCurve...)), msg),
+		evalTV("k1_p256_sig", k1(p256Sig, p256PK), msg),
+		evalTV("k1_as_p256", sigverify(k1Sig, k1PK, ll.SignatureVerify()), msg),
+		evalTV("k1_corrupt_sig", k1(flip(k1Sig, len(k1Sig)-1), k1PK), msg),
+		evalTV("k1_dummy_sig", k1([]byte{0x30, 0x00}, k1PK), msg),
+		evalTV("k1_bad_prefix", k1(k1Sig, append([]byte{0x04}, k1PK[1:]...)), msg),
+		evalTV("k1_empty_stack", []byte{ll.OP_SIGVERIFYSECP256K1}, msg),
+		evalTVCtx("k1_revoked", k1(k1Sig, k1PK), revoked),
+		evalTV("schnorr_valid", schnorr(schnorrSig, schnorrPK), msg),
+		evalTV("schnorr_wrong_msg", schnorr(schnorrSig, schnorrPK), []byte("other")),
+		evalTV("schnorr_empty_msg", schnorr(schnorrSig, schnorrPK), nil),
+		evalTV("schnorr_corrupt_r", schnorr(flip(schnorrSig, 3), schnorrPK), msg),
+		evalTV("schnorr_corrupt_s", schnorr(flip(schnorrSig, 63), schnorrPK), msg),
+		evalTV("schnorr_s_is_n", schnorr(highS, schnorrPK), msg),
+		evalTV("schnorr_r_is_p", schnorr(rIsP, schnorrPK), msg),
+		evalTV("schnorr_key_is_p", schnorr(schnorrSig, p), msg),
+		evalTV("schnorr_key_off_curve", schnorr(schnorrSig, offCurve), msg),
+		evalTV("schnorr_zero_sig", schnorr(make([]byte, 64), schnorrPK), msg),
+		evalTV("schnorr_short_stack", schnorr(schnorrSig[:40], schnorrPK), msg),
+		evalTV("schnorr_empty_stack", []byte{ll.OP_SIGVERIFYSCHNORR}, msg),
+		evalTVCtx("schnorr_revoked", schnorr(schnorrSig, schnorrPK), revoked),
+		evalTV("mixed_2of3_p256_schnorr", mixed(2, keys, p256Sig, nil, schnorrSig), msg),
+		evalTV("mixed_2of3_k1_schnorr", mixed(2, keys, nil, k1Sig, schnorrSig), msg),
+		evalTV("mixed_3of3", mixed(3, keys, p256Sig, k1Sig, schnorrSig), msg),
+		evalTV("mixed_2of3_one_sig", mixed(2, keys, p256Sig, nil, nil), msg),
+		evalTV("mixed_2of3_wrong_slots", mixed(2, keys, k1Sig, p256Sig, schnorrSig), msg),
+		evalTV("mixed_2of3_garbage_p256", mixed(2, keys, []byte{0x30, 0x00}, k1Sig, nil), msg),
+		evalTV("mixed_2of3_short_schnorr", mixed(2, keys, p256Sig, nil, schnorrSig[:63]), msg),
+		evalTV("mixed_2of3_wrong_msg", mixed(2, keys, p256Sig, k1Sig, schnorrSig), []byte("other")),
+		evalTVCtx("mixed_2of3_revoked", mixed(2, keys, p256Sig, k1Sig, schnorrSig), revoked),
+		evalTV("mixed_dup_key", mixed(1, []ll.TypedPublicKey{keys[1], keys[0], keys[1]}, k1Sig, nil, nil), msg),
+		evalTV("mixed_same_bytes_other_type", mixed(1, []ll.TypedPublicKey{keys[1], {Type: crypto.KeyTypeP256, PublicKey: k1PK}}, k1Sig, nil), msg),
+		evalTV("mixed_unknown_type", mixed(1, []ll.TypedPublicKey{{Type: 9, PublicKey: k1PK}}, k1Sig), msg),
+		evalTV("mixed_missing_slot", mixed(1, keys, p256Sig, nil), msg),
+		evalTV("mixed_k_gt_n", mixed(4, keys, p256Sig, k1Sig, schnorrSig), msg),
+		evalTV("mixed_empty_stack", []byte{ll.OP_MIXEDMULTISIGVERIFY}, msg),
+	}
+}
+
+func ed25519Tests() []EvalTV {
+	msg := []byte("test_ed25519")
+	// a 2-of-3 FROST group, and a plain Ed25519 key
+	frostKeys, groupPK, groupSig := crypto.HelperVerifyDataFROST(2, 3, msg)
+	otherGroupSig := crypto.SignFROST(frostKeys[1:], msg)
+	edPK, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edSig := ed25519.Sign(edKey, msg)
+	_, k1PK, k1Sig := crypto.HelperVerifyDataSecp256k1(msg)
+
+	verify := func(sig, pk []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(ll.SignatureVerifyEd25519())
+		return a.Code
+	}
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+	// S + L instead of S
+	sPlusL := append(append([]byte{}, groupSig[:32]...), leBytes32(new(big.Int).Add(leInt(groupSig[32:]), ed25519Order))...)
+	// with the identity as key, ([r]B, r) is a signature of anything: Go's
+	// crypto/ed25519 accepts small-order keys
+	h := sha512.Sum512(make([]byte, ed25519.SeedSize))
+	h[0] &= 248
+	h[31] &= 127
+	h[31] |= 64
+	R := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	identity := append([]byte{1}, make([]byte, 31)...)
+	identitySig := append(append([]byte{}, R...), leBytes32(new(big.Int).Mod(leInt(h[:32]), ed25519Order))...)
+
+	mixed := func(nMin int, keys []ll.TypedPublicKey, sigs ...[]byte) []byte {
+		a := ll.Assembler{}
+		for _, in := range ll.PushSignatureSlots(sigs) {
+			a.Append(in)
+		}
+		for _, in := range ll.MixedMultisig(nMin, keys) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	keys := []ll.TypedPublicKey{
+		{Type: crypto.KeyTypeEd25519, PublicKey: groupPK},
+		{Type: crypto.KeyTypeSecp256k1, PublicKey: k1PK},
+		{Type: crypto.KeyTypeEd25519, PublicKey: edPK},
+	}
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(groupPK): true}}
+
+	return []EvalTV{
+		evalTV("ed25519_frost_valid", verify(groupSig, groupPK), msg),
+		evalTV("ed25519_frost_other_signers", verify(otherGroupSig, groupPK), msg),
+		evalTV("ed25519_plain_valid", verify(edSig, edPK), msg),
+		evalTV("ed25519_wrong_msg", verify(groupSig, groupPK), []byte("other")),
+		evalTV("ed25519_empty_msg", verify(groupSig, groupPK), nil),
+		evalTV("ed25519_wrong_key", verify(groupSig, edPK), msg),
+		evalTV("ed25519_corrupt_r", verify(flip(groupSig, 3), groupPK), msg),
+		evalTV("ed25519_corrupt_s", verify(flip(groupSig, 40), groupPK), msg),
+		evalTV("ed25519_s_plus_l", verify(sPlusL, groupPK), msg),
+		evalTV("ed25519_corrupt_key", verify(groupSig, flip(groupPK, 0)), msg),
+		evalTV("ed25519_identity_key", verify(identitySig, identity), msg),
+		evalTV("ed25519_zero_sig", verify(make([]byte, 64), groupPK), msg),
+		evalTV("ed25519_short_stack", verify(groupSig[:40], groupPK), msg),
+		evalTV("ed25519_empty_stack", []byte{ll.OP_SIGVERIFYED25519}, msg),
+		evalTVCtx("ed25519_revoked", verify(groupSig, groupPK), revoked),
+		evalTV("mixed_ed25519_frost_k1", mixed(2, keys, groupSig, k1Sig, nil), msg),
+		evalTV("mixed_ed25519_both", mixed(2, keys, otherGroupSig, nil, edSig), msg),
+		evalTV("mixed_ed25519_swapped", mixed(2, keys, edSig, nil, groupSig), msg),
+		evalTV("mixed_ed25519_short_sig", mixed(1, keys, groupSig[:63], nil, nil), msg),
+		evalTVCtx("mixed_ed25519_revoked", mixed(2, keys, groupSig, nil, edSig), revoked),
+		// SHA-512 pads R || A || msg into a second block
+		evalTV("ed25519_msg_len_50", verify(ed25519.Sign(edKey, bytes.Repeat([]byte{0x5a}, 50)), edPK), bytes.Repeat([]byte{0x5a}, 50)),
+	}
+}
+
+func sigVerifyHashTests() []EvalTV {
+	msg := []byte("test_sigverifyhash")
+	verify := func(keyType, alg byte, pk, sig []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig))
+		for _, in := range ll.SigVerifyHash(keyType, alg, pk) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+
+	var tests []EvalTV
+	names := map[byte]string{crypto.KeyTypeP256: "p256", crypto.KeyTypeSecp256k1: "k1", crypto.KeyTypeP384: "p384"}
+	algs := map[byte]string{crypto.HashSHA256: "sha256", crypto.HashSHA384: "sha384", crypto.HashSHA512: "sha512", crypto.HashSHA3_256: "sha3_256"}
+	for _, keyType := range []byte{crypto.KeyTypeP256, crypto.KeyTypeSecp256k1, crypto.KeyTypeP384} {
+		for alg := crypto.HashSHA256; alg <= crypto.HashSHA3_256; alg++ {
+			pk, sig := crypto.HelperVerifyDataDigest(keyType, alg, msg)
+			name := "sigverifyhash_" + names[keyType] + "_" + algs[alg]
+			tests = append(tests,
+				evalTV(name+"_valid", verify(keyType, alg, pk, sig), msg),
+				evalTV(name+"_wrong_msg", verify(keyType, alg, pk, sig), []byte("other")),
+				evalTV(name+"_corrupt_sig", verify(keyType, alg, pk, flip(sig, len(sig)-1)), msg))
+		}
+	}
+
+	pk, sig := crypto.HelperVerifyDataDigest(crypto.KeyTypeP384, crypto.HashSHA384, msg)
+	_, p256PK, p256Sig := crypto.HelperVerifyData(msg)
+	// r = n, s = 1
+	n := elliptic.P384().Params().N
+	rIsN, _ := asn1.Marshal(struct{ R, S *big.Int }{n, big.NewInt(1)})
+	zeroR, _ := asn1.Marshal(struct{ R, S *big.Int }{big.NewInt(0), big.NewInt(1)})
+	long := append([]byte{0x30, 103}, make([]byte, 103)...)
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(pk): true}}
+
+	// messages whose SHA-384 and SHA-512 padding spills into a second block
+	long112 := bytes.Repeat([]byte{0xa5}, 112)
+	for _, alg := range []byte{crypto.HashSHA384, crypto.HashSHA512} {
+		pk, sig := crypto.HelperVerifyDataDigest(crypto.KeyTypeP384, alg, long112)
+		tests = append(tests, evalTV("sigverifyhash_p384_"+algs[alg]+"_msg_len_112", verify(crypto.KeyTypeP384, alg, pk, sig), long112))
+	}
+
+	return append(tests,
+		evalTV("sigverifyhash_p384_empty_msg", verify(crypto.KeyTypeP384, crypto.HashSHA384, pk, sig), nil),
+		evalTV("sigverifyhash_p384_other_alg", verify(crypto.KeyTypeP384, crypto.HashSHA512, pk, sig), msg),
+		evalTV("sigverifyhash_p384_corrupt_key", verify(crypto.KeyTypeP384, crypto.HashSHA384, flip(pk, 48), sig), msg),
+		evalTV("sigverifyhash_p384_key_prefix", verify(crypto.KeyTypeP384, crypto.HashSHA384, append([]byte{0x04}, pk[1:]...), sig), msg),
+		evalTV("sigverifyhash_p384_r_is_n", verify(crypto.KeyTypeP384, crypto.HashSHA384, pk, rIsN), msg),
+		evalTV("sigverifyhash_p384_zero_r", verify(crypto.KeyTypeP384, crypto.HashSHA384, pk, zeroR), msg),
+		evalTV("sigverifyhash_p384_sig_too_long", verify(crypto.KeyTypeP384, crypto.HashSHA384, pk, long), msg),
+		evalTV("sigverifyhash_p384_malformed_der", verify(crypto.KeyTypeP384, crypto.HashSHA384, pk, []byte{0x30, 0}), msg),
+		evalTV("sigverifyhash_p256_sha256_sigverify", verify(crypto.KeyTypeP256, crypto.HashSHA256, p256PK, p256Sig), msg),
+		evalTV("sigverifyhash_p256_key_as_k1", verify(crypto.KeyTypeSecp256k1, crypto.HashSHA256, p256PK, p256Sig), msg),
+		evalTV("sigverifyhash_unknown_alg", verify(crypto.KeyTypeP384, 0, pk, sig), msg),
+		evalTV("sigverifyhash_unknown_alg_5", verify(crypto.KeyTypeP384, 5, pk, sig), msg),
+		evalTV("sigverifyhash_schnorr_type", verify(crypto.KeyTypeSchnorr, crypto.HashSHA256, pk, sig), msg),
+		evalTV("sigverifyhash_empty_stack", []byte{ll.OP_SIGVERIFYHASH}, msg),
+		evalTVCtx("sigverifyhash_revoked", verify(crypto.KeyTypeP384, crypto.HashSHA384, pk, sig), revoked),
+	)
+}
+
+func sigVerifyHashM001Tests() []M001TV {
+	msg := []byte("artifact 7f3e")
+	pk, sig := crypto.HelperVerifyDataDigest(crypto.KeyTypeP384, crypto.HashSHA384, msg)
+	xsig := serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push(sig)) })
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for _, in := range ll.SigVerifyHash(crypto.KeyTypeP384, crypto.HashSHA384, pk) {
+			mc.Append(in)
+		}
+	})
+	return []M001TV{
+		m001TV("m001_sigverifyhash_p384_valid", xpk, xsig, msg),
+		m001TV("m001_sigverifyhash_p384_wrong_msg", xpk, xsig, []byte("artifact 7f3f")),
+	}
+}
+
+func slhdsaTests() []EvalTV {
+	msg := []byte("test_slhdsa")
+	_, pkF, sigF := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128f, msg)
+	_, pkS, sigS := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128s, msg)
+	_, pkOther, _ := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128f, nil)
+
+	// the signatures of TestSLHDSA_OpenSSLInterop, checked with OpenSSL 3.5.2
+	interopSeeds := make([]byte, 48)
+	for i := range interopSeeds {
+		interopSeeds[i] = byte(i)
+	}
+	interopMsg := []byte("xsig slh-dsa interop")
+	interop := func(paramSet byte) []byte {
+		sk := crypto.SLHDSAKeyFromSeed(paramSet, interopSeeds)
+		a := ll.Assembler{}
+		a.Append(ll.PushLarge(crypto.SignSLHDSA(paramSet, sk, interopMsg)))
+		for _, in := range ll.SLHDSASigVerify(0, paramSet, sk[32:]) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+
+	// objects first, then an OP_SLHDSAVERIFY on the given object
+	verify := func(objects [][]byte, object int, paramSet byte, pk []byte) []byte {
+		a := ll.Assembler{}
+		for _, o := range objects {
+			a.Append(ll.PushLarge(o))
+		}
+		for _, in := range ll.SLHDSASigVerify(object, paramSet, pk) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+	many := func(n int) []byte {
+		a := ll.Assembler{}
+		for i := 0; i < n; i++ {
+			a.Append(ll.PushLarge([]byte{byte(i)}))
+		}
+		a.Append(ll.Push1(1))
+		return a.Code
+	}
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(pkS): true}}
+
+	return []EvalTV{
+		evalTV("slh_128f_valid", verify([][]byte{sigF}, 0, crypto.SLHDSASHA2128f, pkF), msg),
+		evalTV("slh_128s_valid", verify([][]byte{nil, sigS}, 1, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTV("slh_128s_openssl_interop", interop(crypto.SLHDSASHA2128s), interopMsg),
+		evalTV("slh_128f_openssl_interop", interop(crypto.SLHDSASHA2128f), interopMsg),
+		evalTV("slh_wrong_msg", verify([][]byte{sigS}, 0, crypto.SLHDSASHA2128s, pkS), []byte("other")),
+		evalTV("slh_empty_msg", verify([][]byte{sigS}, 0, crypto.SLHDSASHA2128s, pkS), nil),
+		evalTV("slh_wrong_key", verify([][]byte{sigF}, 0, crypto.SLHDSASHA2128f, pkOther), msg),
+		evalTV("slh_corrupt_r", verify([][]byte{flip(sigS, 0)}, 0, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTV("slh_corrupt_fors", verify([][]byte{flip(sigS, 100)}, 0, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTV("slh_corrupt_ht", verify([][]byte{flip(sigS, len(sigS)-1)}, 0, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTV("slh_corrupt_root", verify([][]byte{sigS}, 0, crypto.SLHDSASHA2128s, flip(pkS, 31)), msg),
+		evalTV("slh_other_param_set", verify([][]byte{sigS}, 0, crypto.SLHDSASHA2128f, pkS), msg),
+		evalTV("slh_truncated_sig", verify([][]byte{sigS[:len(sigS)-1]}, 0, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTV("slh_empty_object", verify([][]byte{nil, sigS}, 0, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTVCtx("slh_revoked", verify([][]byte{sigS}, 0, crypto.SLHDSASHA2128s, pkS), revoked),
+		evalTV("slh_unknown_param_set", verify([][]byte{sigS}, 0, 3, pkS), msg),
+		evalTV("slh_no_object", verify([][]byte{sigS}, 1, crypto.SLHDSASHA2128s, pkS), msg),
+		evalTV("slh_short_key", []byte{ll.OP_PUSHLARGE, 0, 0, ll.OP_PUSH, 2, 0, 0, ll.OP_PUSH, 1, 1, ll.OP_SLHDSAVERIFY}, msg),
+		evalTV("slh_empty_stack", []byte{ll.OP_SLHDSAVERIFY}, msg),
+		evalTV("pushlarge_stack_untouched", []byte{ll.OP_PUSH, 1, 9, ll.OP_PUSHLARGE, 2, 0, ll.OP_ADD, ll.OP_ADD}, nil),
+		evalTVAsm("pushlarge_300", func(a *ll.Assembler) {
+			// little-endian length: 0x2C, 0x01
+			a.Append(ll.PushLarge(make([]byte, 300))); a.Append(ll.Push1(1))
+		}, nil),
+		evalTVAsm("pushlarge_skipped", func(a *ll.Assembler) {
+			a.Append(ll.Push1(0)); a.Append(ll.If())
+			a.Append(ll.PushLarge([]byte{ll.OP_ENDIF, ll.OP_PUSH}))
+			a.Append(ll.EndIf()); a.Append(ll.Push1(1))
+		}, nil),
+		evalTV("pushlarge_max_objects", many(ll.MaxObjects), nil),
+		evalTV("pushlarge_too_many", many(ll.MaxObjects+1), nil),
+		evalTV("pushlarge_truncated", []byte{ll.OP_PUSHLARGE, 3, 0, 1, 2}, nil),
+		evalTV("pushlarge_high_byte", []byte{ll.OP_PUSHLARGE, 1, 1, 1}, nil),
+		evalTV("pushlarge_missing_length", []byte{ll.OP_PUSHLARGE, 1}, nil),
+		evalTV("pushlarge_skipped_truncated", []byte{ll.OP_PUSH, 1, 0, ll.OP_IF, ll.OP_PUSHLARGE, 5, 0, 1, ll.OP_ENDIF}, nil),
+	}
+}
+
+// rsaSmallExponentKey is a 2048-bit RSA key with e = 3, which
+// rsa.GenerateKey never produces.
+func rsaSmallExponentKey() *rsa.PrivateKey {
+	three := big.NewInt(3)
+	for {
+		p, _ := rand.Prime(rand.Reader, 1024)
+		q, _ := rand.Prime(rand.Reader, 1024)
+		n := new(big.Int).Mul(p, q)
+		pm1, qm1 := new(big.Int).Sub(p, big.NewInt(1)), new(big.Int).Sub(q, big.NewInt(1))
+		phi := new(big.Int).Mul(pm1, qm1)
+		d := new(big.Int).ModInverse(three, phi)
+		if d == nil || n.BitLen() != 2048 {
+			continue
+		}
+		key := &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: n, E: 3}, D: d, Primes: []*big.Int{p, q}}
+		key.Precompute()
+		return key
+	}
+}
+
+func rsaTests() []EvalTV {
+	msg := []byte("test_rsa")
+	hash := sha256.Sum256(msg)
+	key2048, pk2048, sig2048 := crypto.HelperVerifyDataRSA(2048, crypto.RSAPKCS1v15, msg)
+	key3072, pk3072, _ := crypto.HelperVerifyDataRSA(3072, crypto.RSAPKCS1v15, nil)
+	key4096, pk4096, _ := crypto.HelperVerifyDataRSA(4096, crypto.RSAPSS, nil)
+	_, pkOther, _ := crypto.HelperVerifyDataRSA(2048, crypto.RSAPKCS1v15, nil)
+	keyE3 := rsaSmallExponentKey()
+	pkE3 := crypto.RSAPublicKeyBytes(&keyE3.PublicKey)
+	pss2048 := crypto.SignRSA(crypto.RSAPSS, key2048, msg)
+	pssSalt := func(saltLength int) []byte {
+		sig, err := rsa.SignPSS(rand.Reader, key2048, stdcrypto.SHA256, hash[:], &rsa.PSSOptions{SaltLength: saltLength})
+		if err != nil {
+			panic(err)
+		}
+		return sig
+	}
+
+	// objects sig, key, then OP_RSAVERIFY committing to committed
+	verify := func(scheme byte, sig, pk, committed []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushLarge(sig)); a.Append(ll.PushLarge(pk))
+		for _, in := range ll.RSASigVerify(0, 1, scheme, committed) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	flip := func(b []byte, i int) []byte {
+		c := append([]byte{}, b...)
+		c[i] ^= 0x01
+		return c
+	}
+	evenE := append([]byte{0, 1, 0, 2}, pk2048[4:]...)
+	shortN := append(append([]byte{}, pk2048[:4]...), pk2048[5:]...)
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(pk2048): true}}
+
+	return []EvalTV{
+		evalTV("rsa_pkcs1_2048", verify(crypto.RSAPKCS1v15, sig2048, pk2048, pk2048), msg),
+		evalTV("rsa_pss_2048", verify(crypto.RSAPSS, pss2048, pk2048, pk2048), msg),
+		evalTV("rsa_pkcs1_3072", verify(crypto.RSAPKCS1v15, crypto.SignRSA(crypto.RSAPKCS1v15, key3072, msg), pk3072, pk3072), msg),
+		evalTV("rsa_pss_3072", verify(crypto.RSAPSS, crypto.SignRSA(crypto.RSAPSS, key3072, msg), pk3072, pk3072), msg),
+		evalTV("rsa_pkcs1_4096", verify(crypto.RSAPKCS1v15, crypto.SignRSA(crypto.RSAPKCS1v15, key4096, msg), pk4096, pk4096), msg),
+		evalTV("rsa_pss_4096", verify(crypto.RSAPSS, crypto.SignRSA(crypto.RSAPSS, key4096, msg), pk4096, pk4096), msg),
+		evalTV("rsa_pkcs1_e3", verify(crypto.RSAPKCS1v15, crypto.SignRSA(crypto.RSAPKCS1v15, keyE3, msg), pkE3, pkE3), msg),
+		evalTV("rsa_pss_e3", verify(crypto.RSAPSS, crypto.SignRSA(crypto.RSAPSS, keyE3, msg), pkE3, pkE3), msg),
+		evalTV("rsa_pss_salt_0", verify(crypto.RSAPSS, pssSalt(0), pk2048, pk2048), msg),
+		evalTV("rsa_pss_salt_64", verify(crypto.RSAPSS, pssSalt(64), pk2048, pk2048), msg),
+		evalTV("rsa_pss_salt_max", verify(crypto.RSAPSS, pssSalt(rsa.PSSSaltLengthAuto), pk2048, pk2048), msg),
+		evalTV("rsa_pkcs1_wrong_msg", verify(crypto.RSAPKCS1v15, sig2048, pk2048, pk2048), []byte("other")),
+		evalTV("rsa_pss_wrong_msg", verify(crypto.RSAPSS, pss2048, pk2048, pk2048), nil),
+		evalTV("rsa_pkcs1_as_pss", verify(crypto.RSAPSS, sig2048, pk2048, pk2048), msg),
+		evalTV("rsa_pss_as_pkcs1", verify(crypto.RSAPKCS1v15, pss2048, pk2048, pk2048), msg),
+		evalTV("rsa_pkcs1_corrupt", verify(crypto.RSAPKCS1v15, flip(sig2048, 200), pk2048, pk2048), msg),
+		evalTV("rsa_pss_corrupt", verify(crypto.RSAPSS, flip(pss2048, 0), pk2048, pk2048), msg),
+		evalTV("rsa_other_key", verify(crypto.RSAPKCS1v15, sig2048, pkOther, pkOther), msg),
+		evalTV("rsa_sig_is_n", verify(crypto.RSAPKCS1v15, pk2048[4:], pk2048, pk2048), msg),
+		evalTV("rsa_sig_all_ff", verify(crypto.RSAPSS, bytes.Repeat([]byte{0xFF}, 256), pk2048, pk2048), msg),
+		evalTV("rsa_sig_zero", verify(crypto.RSAPKCS1v15, make([]byte, 256), pk2048, pk2048), msg),
+		evalTV("rsa_sig_one", verify(crypto.RSAPKCS1v15, append(make([]byte, 255), 1), pk2048, pk2048), msg),
+		evalTV("rsa_sig_short", verify(crypto.RSAPKCS1v15, sig2048[1:], pk2048, pk2048), msg),
+		evalTV("rsa_sig_empty", verify(crypto.RSAPKCS1v15, nil, pk2048, pk2048), msg),
+		evalTVCtx("rsa_revoked", verify(crypto.RSAPKCS1v15, sig2048, pk2048, pk2048), revoked),
+		evalTV("rsa_wrong_commitment", verify(crypto.RSAPKCS1v15, sig2048, pkOther, pk2048), msg),
+		evalTV("rsa_even_exponent", verify(crypto.RSAPKCS1v15, sig2048, evenE, evenE), msg),
+		evalTV("rsa_short_modulus", verify(crypto.RSAPKCS1v15, sig2048, shortN, shortN), msg),
+		evalTV("rsa_unknown_scheme", verify(3, sig2048, pk2048, pk2048), msg),
+		evalTVAsm("rsa_no_sig_object", func(a *ll.Assembler) {
+			a.Append(ll.PushLarge(pk2048))
+			for _, in := range ll.RSASigVerify(1, 0, crypto.RSAPKCS1v15, pk2048) {
+				a.Append(in)
+			}
+		}, msg),
+		evalTV("rsa_empty_stack", []byte{ll.OP_RSAVERIFY}, msg),
+	}
+}
+
+func webauthnTests() []EvalTV {
+	msg := []byte("test_webauthn")
+	privateKey, pk, authData, clientData, sig := crypto.HelperVerifyDataWebAuthn("example.com", msg)
+	_, otherPK, _ := crypto.HelperVerifyData(nil)
+
+	// signature, objects authData and clientData, then OP_WEBAUTHNVERIFY
+	verify := func(sig, authData, clientData []byte, rpID string, flags byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig)); a.Append(ll.PushLarge(authData)); a.Append(ll.PushLarge(clientData))
+		for _, in := range ll.WebAuthnSigVerify(0, 1, pk, rpID, flags) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	signed := func(authData, clientData []byte) []byte {
+		return verify(crypto.SignWebAuthn(privateKey, authData, clientData), authData, clientData, "example.com", 0)
+	}
+	presentOnly := crypto.WebAuthnAuthData("example.com", crypto.WebAuthnUserPresent, 0)
+	withExtensions := append(append([]byte{}, authData...), 0xA1, 0x6B)
+	withExtensions[32] |= 0x80
+	longClientData := append(append([]byte{}, clientData[:len(clientData)-1]...),
+		`,"other_keys_can_be_added_here":"`+strings.Repeat("x", 400)+`"}`...)
+	prefix := crypto.WebAuthnClientDataPrefix(msg)
+	spaced := append([]byte(`{"type": "webauthn.get",`), clientData[len(`{"type":"webauthn.get",`):]...)
+	create := append([]byte(`{"type":"webauthn.create"`), clientData[len(`{"type":"webauthn.get"`):]...)
+	otherChallenge := crypto.WebAuthnClientData([]byte("other"), "https://example.com")
+	padded := append(append(append([]byte{}, prefix[:len(prefix)-1]...), `="`...), clientData[len(prefix):]...)
+	badSig := append([]byte{}, sig...)
+	badSig[len(badSig)-1] ^= 1
+	badDER := append([]byte{}, sig...)
+	badDER[2] = 0x05
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(pk): true}}
+
+	return []EvalTV{
+		evalTV("webauthn_valid", verify(sig, authData, clientData, "example.com", 0), msg),
+		evalTV("webauthn_valid_uv", verify(sig, authData, clientData, "example.com", crypto.WebAuthnUserVerified), msg),
+		evalTV("webauthn_up_only", signed(presentOnly, clientData), msg),
+		evalTV("webauthn_up_only_uv_required", verify(crypto.SignWebAuthn(privateKey, presentOnly, clientData), presentOnly, clientData, "example.com", crypto.WebAuthnUserVerified), msg),
+		evalTV("webauthn_no_up", signed(crypto.WebAuthnAuthData("example.com", crypto.WebAuthnUserVerified, 0), clientData), msg),
+		evalTV("webauthn_extensions", signed(withExtensions, clientData), msg),
+		evalTV("webauthn_long_client_data", signed(authData, longClientData), msg),
+		evalTV("webauthn_prefix_only", signed(authData, prefix), msg),
+		evalTV("webauthn_prefix_truncated", signed(authData, prefix[:len(prefix)-1]), msg),
+		evalTV("webauthn_spaced_json", signed(authData, spaced), msg),
+		evalTV("webauthn_create", signed(authData, create), msg),
+		evalTV("webauthn_other_challenge", signed(authData, otherChallenge), msg),
+		evalTV("webauthn_padded_challenge", signed(authData, padded), msg),
+		evalTV("webauthn_short_auth_data", signed(authData[:36], clientData), msg),
+		evalTV("webauthn_empty_objects", signed(nil, nil), msg),
+		evalTV("webauthn_wrong_msg", verify(sig, authData, clientData, "example.com", 0), []byte("other")),
+		evalTV("webauthn_wrong_rp", verify(sig, authData, clientData, "example.org", 0), msg),
+		evalTV("webauthn_swapped_objects", verify(sig, clientData, authData, "example.com", 0), msg),
+		evalTV("webauthn_bad_sig", verify(badSig, authData, clientData, "example.com", 0), msg),
+		evalTV("webauthn_bad_der", verify(badDER, authData, clientData, "example.com", 0), msg),
+		evalTVCtx("webauthn_revoked", verify(sig, authData, clientData, "example.com", 0), revoked),
+		evalTVAsm("webauthn_other_key", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.PushLarge(authData)); a.Append(ll.PushLarge(clientData))
+			for _, in := range ll.WebAuthnSigVerify(0, 1, otherPK, "example.com", 0) {
+				a.Append(in)
+			}
+		}, msg),
+		evalTVAsm("webauthn_no_client_data", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.PushLarge(authData))
+			for _, in := range ll.WebAuthnSigVerify(0, 1, pk, "example.com", 0) {
+				a.Append(in)
+			}
+		}, msg),
+		evalTVAsm("webauthn_no_sig", func(a *ll.Assembler) {
+			a.Append(ll.PushLarge(authData)); a.Append(ll.PushLarge(clientData))
+			for _, in := range ll.WebAuthnSigVerify(0, 1, pk, "example.com", 0) {
+				a.Append(in)
+			}
+		}, msg),
+		evalTV("webauthn_empty_stack", []byte{ll.OP_WEBAUTHNVERIFY}, msg),
+	}
+}
+
+// Made with OpenSSH 9.2, ssh-keygen -Y sign -n xsig over "release 1.0.0"
+// (the ECDSA one with -O hashalg=sha256).
+const (
+	opensshEd25519Key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIES4Vz+1NBaOgNYo8cdDpSzLSsK0612fIiqtOI1FSYnR dev@example.com"
+	opensshECDSAKey   = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBDPCPprkF4qLin3bBLWloMyN7QA2vg6hc86KceADLPYnuTdcHCZsi1c6nooUOnINjSGqIxXzMXaPx5/akufg66A= dev2@example.com"
+	opensshEd25519Sig = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgRLhXP7U0Fo6A1ijxx0OlLMtKwr
+TrXZ8iKq04jUVJidEAAAAEeHNpZwAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEBrh2Qpp1zReW37aHv9nJFlslq60KUKAbHkcQO4OUr0nP+ds/ihBceweQPWzDsB/B
+qftt5uzcXNjYCzqiE50hoP
+-----END SSH SIGNATURE-----`
+	opensshECDSASig   = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAAGgAAAATZWNkc2Etc2hhMi1uaXN0cDI1NgAAAAhuaXN0cDI1NgAAAE
+EEM8I+muQXiouKfdsEtaWgzI3tADa+DqFzzopx4AMs9ie5N1wcJmyLVzqeihQ6cg2NIaoj
+FfMxdo/Hn9qS5+DroAAAAAR4c2lnAAAAAAAAAAZzaGEyNTYAAABkAAAAE2VjZHNhLXNoYT
+ItbmlzdHAyNTYAAABJAAAAIEa72FLhjcFeBQYQ9cxi/BKecNPYy4u4Cwa2yn8IC5ZNAAAA
+IQDp7vaSe1OB7rmuVr+OSsws1HXv7Q3Kh8cTHyD+4f6JAw==
+-----END SSH SIGNATURE-----`
+)
+
+var ed25519Order, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+func leBytes32(x *big.Int) []byte {
+	out := make([]byte, 32)
+	b := x.Bytes()
+	for i := range b {
+		out[i] = b[len(b)-1-i]
+	}
+	return out
+}
+
+func leInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[i] = b[len(b)-1-i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// ed25519Crafted signs for the raw Ed25519 key pk, a point of order 1 or 4
+// whose discrete log nobody knows: with R = [r]B and S = r, [S]B - [k]A = R
+// holds whenever k is a multiple of 4.
+func ed25519Crafted(pk []byte, namespace []byte, msg []byte) (publicKey []byte, blob []byte) {
+	publicKey, _ = crypto.SSHPublicKeyBytes(ed25519.PublicKey(pk))
+	signed := crypto.SSHSigSignedData(namespace, "sha512", msg)
+	for {
+		seed := make([]byte, ed25519.SeedSize)
+		rand.Read(seed)
+		R := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+		h := sha512.Sum512(seed)
+		h[0] &= 248
+		h[31] &= 127
+		h[31] |= 64
+		r := new(big.Int).Mod(leInt(h[:32]), ed25519Order)
+		k := sha512.Sum512(append(append(append([]byte{}, R...), pk...), signed...))
+		if new(big.Int).Mod(leInt(k[:]), ed25519Order).Bit(0)|new(big.Int).Mod(leInt(k[:]), ed25519Order).Bit(1) != 0 {
+			continue
+		}
+		sig := append(append([]byte{}, R...), leBytes32(r)...)
+		return publicKey, crypto.SSHSigBlob(publicKey, namespace, "sha512", crypto.SSHKeyTypeEd25519, sig)
+	}
+}
+
+func sshsigTests() []EvalTV {
+	msg := []byte("test_sshsig")
+	ns := []byte("xsig")
+	edKey, edPK, edSig := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeEd25519, ns, msg)
+	ecKey, ecPK, ecSig := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeECDSAP256, ns, msg)
+	_, _, otherSig := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeEd25519, ns, msg)
+
+	verify := func(sig, pk []byte, namespace string) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushLarge(sig))
+		for _, in := range ll.SSHSigVerify(0, pk, namespace) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	opensshKey := func(line string) []byte {
+		pk, err := crypto.ParseSSHAuthorizedKey([]byte(line))
+		if err != nil { panic(err) }
+		return pk
+	}
+	opensshSig := func(armored string) []byte {
+		sig, err := crypto.ParseSSHSignatureArmor([]byte(armored))
+		if err != nil { panic(err) }
+		return sig
+	}
+	edSigned := crypto.SSHSigSignedData(ns, "sha512", msg)
+	edRaw := ed25519.Sign(edKey.(ed25519.PrivateKey), edSigned)
+	edBlob := func(sig []byte) []byte {
+		return crypto.SSHSigBlob(edPK, ns, "sha512", crypto.SSHKeyTypeEd25519, sig)
+	}
+	// S + L instead of S
+	sPlusL := leBytes32(new(big.Int).Add(leInt(edRaw[32:]), ed25519Order))
+	// ECDSA r and s with custom mpint encodings
+	ecHash := sha256.Sum256(crypto.SSHSigSignedData(ns, "sha512", msg))
+	r, sv, _ := ecdsa.Sign(rand.Reader, ecKey.(*ecdsa.PrivateKey), ecHash[:])
+	mpints := func(rb, sb []byte) []byte {
+		str := func(b []byte) []byte { return append([]byte{0, 0, 0, byte(len(b))}, b...) }
+		return crypto.SSHSigBlob(ecPK, ns, "sha512", crypto.SSHKeyTypeECDSAP256, append(str(rb), str(sb)...))
+	}
+	pad := func(x *big.Int, n int) []byte { return append(make([]byte, n), x.Bytes()...) }
+	negative := append([]byte{0x80}, r.Bytes()...)
+
+	// small-order keys: the identity with the sign bit set (x = 0), and
+	// the order-4 point y = 0 encoded non-canonically as y = p
+	identity := make([]byte, 32)
+	identity[0], identity[31] = 1, 0x80
+	yIsP := append([]byte{0xED}, bytes.Repeat([]byte{0xFF}, 30)...)
+	yIsP = append(yIsP, 0x7F)
+	identityPK, identitySig := ed25519Crafted(identity, ns, msg)
+	yIsPPK, yIsPSig := ed25519Crafted(yIsP, ns, msg)
+	offCurve := append([]byte{2}, make([]byte, 31)...)
+	offCurvePK, offCurveSig := ed25519Crafted(offCurve, ns, msg)
+
+	mutate := func(sig []byte, f func([]byte)) []byte {
+		c := append([]byte{}, sig...)
+		f(c)
+		return c
+	}
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(edPK): true}}
+	rsaKey := opensshKey(opensshEd25519Key)
+	copy(rsaKey[4:], "ssh-rsa")
+
+	return []EvalTV{
+		evalTV("sshsig_ed25519", verify(edSig, edPK, "xsig"), msg),
+		evalTV("sshsig_ed25519_sha256", verify(crypto.SignSSHSig(edKey, ns, "sha256", msg), edPK, "xsig"), msg),
+		evalTV("sshsig_ecdsa", verify(ecSig, ecPK, "xsig"), msg),
+		evalTV("sshsig_ecdsa_sha256", verify(crypto.SignSSHSig(ecKey, ns, "sha256", msg), ecPK, "xsig"), msg),
+		evalTV("sshsig_openssh_ed25519", verify(opensshSig(opensshEd25519Sig), opensshKey(opensshEd25519Key), "xsig"), []byte("release 1.0.0")),
+		evalTV("sshsig_openssh_ecdsa", verify(opensshSig(opensshECDSASig), opensshKey(opensshECDSAKey), "xsig"), []byte("release 1.0.0")),
+		evalTV("sshsig_openssh_wrong_msg", verify(opensshSig(opensshEd25519Sig), opensshKey(opensshEd25519Key), "xsig"), []byte("release 1.0.1")),
+		evalTV("sshsig_ecdsa_mpint_padded", verify(mpints(pad(r, 3), pad(sv, 1)), ecPK, "xsig"), msg),
+		evalTV("sshsig_ecdsa_mpint_negative", verify(mpints(negative, sv.Bytes()), ecPK, "xsig"), msg),
+		evalTV("sshsig_ecdsa_mpint_33", verify(mpints(append([]byte{1}, pad(r, 32-len(r.Bytes()))...), sv.Bytes()), ecPK, "xsig"), msg),
+		evalTV("sshsig_ecdsa_r_zero", verify(mpints(nil, sv.Bytes()), ecPK, "xsig"), msg),
+		evalTV("sshsig_wrong_msg", verify(edSig, edPK, "xsig"), []byte("other")),
+		evalTV("sshsig_wrong_namespace", verify(edSig, edPK, "git"), msg),
+		evalTV("sshsig_signed_other_namespace", verify(crypto.SignSSHSig(edKey, []byte("file"), "sha512", msg), edPK, "xsig"), msg),
+		evalTV("sshsig_sha384", verify(crypto.SignSSHSig(edKey, ns, "sha384", msg), edPK, "xsig"), msg),
+		evalTV("sshsig_other_key", verify(otherSig, edPK, "xsig"), msg),
+		evalTV("sshsig_other_key_relabelled", verify(mutate(otherSig, func(b []byte) { copy(b[14:], edPK) }), edPK, "xsig"), msg),
+		evalTV("sshsig_ecdsa_sig_for_ed25519_key", verify(ecSig, edPK, "xsig"), msg),
+		evalTV("sshsig_corrupt", verify(mutate(edSig, func(b []byte) { b[len(b)-40] ^= 1 }), edPK, "xsig"), msg),
+		evalTV("sshsig_bad_magic", verify(mutate(edSig, func(b []byte) { b[0] = 'X' }), edPK, "xsig"), msg),
+		evalTV("sshsig_version_2", verify(mutate(edSig, func(b []byte) { b[9] = 2 }), edPK, "xsig"), msg),
+		evalTV("sshsig_truncated", verify(edSig[:len(edSig)-1], edPK, "xsig"), msg),
+		evalTV("sshsig_trailing", verify(append(append([]byte{}, edSig...), 0), edPK, "xsig"), msg),
+		evalTV("sshsig_huge_length", verify(mutate(edSig, func(b []byte) { b[10] = 0xFF }), edPK, "xsig"), msg),
+		evalTV("sshsig_empty", verify(nil, edPK, "xsig"), msg),
+		evalTV("sshsig_ed25519_s_plus_l", verify(edBlob(append(append([]byte{}, edRaw[:32]...), sPlusL...)), edPK, "xsig"), msg),
+		evalTV("sshsig_ed25519_short_sig", verify(edBlob(edRaw[:63]), edPK, "xsig"), msg),
+		evalTV("sshsig_ed25519_identity_key", verify(identitySig, identityPK, "xsig"), msg),
+		evalTV("sshsig_ed25519_noncanonical_key", verify(yIsPSig, yIsPPK, "xsig"), msg),
+		evalTV("sshsig_ed25519_off_curve_key", verify(offCurveSig, offCurvePK, "xsig"), msg),
+		evalTVCtx("sshsig_revoked", verify(edSig, edPK, "xsig"), revoked),
+		evalTV("sshsig_empty_namespace", verify(edSig, edPK, ""), msg),
+		evalTV("sshsig_malformed_key", verify(edSig, edPK[:len(edPK)-1], "xsig"), msg),
+		evalTV("sshsig_unsupported_key", verify(edSig, rsaKey, "xsig"), msg),
+		evalTVAsm("sshsig_no_object", func(a *ll.Assembler) {
+			for _, in := range ll.SSHSigVerify(0, edPK, "xsig") {
+				a.Append(in)
+			}
+		}, msg),
+		evalTV("sshsig_empty_stack", []byte{ll.OP_SSHSIGVERIFY}, msg),
+	}
+}
+
+func ethTests() []EvalTV {
+	msg := []byte("test_eth")
+	domain := crypto.EIP712DomainSeparator("xsig", "1", 1, nil)
+	d, address, sig := crypto.HelperVerifyDataEthereum(crypto.EthPersonalSign, nil, msg)
+	_, typedAddress, typedSig := crypto.HelperVerifyDataEthereum(crypto.EthTypedData, domain, msg)
+	_, otherAddress, _ := crypto.HelperVerifyDataEthereum(crypto.EthPersonalSign, nil, msg)
+	longMsg := bytes.Repeat([]byte("0123456789"), 30)
+	_, longAddress, longSig := crypto.HelperVerifyDataEthereum(crypto.EthTypedData, domain, longMsg)
+	_, emptyAddress, emptySig := crypto.HelperVerifyDataEthereum(crypto.EthPersonalSign, nil, nil)
+
+	verify := func(sig []byte, mode byte, address []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig))
+		for _, in := range ll.EthSigVerify(mode, domain, address) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	mutate := func(sig []byte, f func([]byte)) []byte {
+		c := append([]byte{}, sig...)
+		f(c)
+		return c
+	}
+	n, _ := new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	p, _ := new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	// the high-s twin of sig, with the other recovery id
+	highS := mutate(sig, func(b []byte) {
+		new(big.Int).Sub(n, new(big.Int).SetBytes(b[32:64])).FillBytes(b[32:64])
+		b[64] = 55 - b[64]
+	})
+	// an r that is no point's x coordinate: x^3 + 7 is not a square
+	noPoint := big.NewInt(1)
+	for {
+		c := new(big.Int).Exp(noPoint, big.NewInt(3), p)
+		if big.Jacobi(c.Add(c, big.NewInt(7)), p) == -1 {
+			break
+		}
+		noPoint.Add(noPoint, big.NewInt(1))
+	}
+	// web3.eth.accounts.sign("Some data", key) from the web3.js documentation
+	web3Address, _ := hex.DecodeString("2c7536E3605D9C16a7a3D7b1898e529396a65c23")
+	web3Sig, _ := hex.DecodeString("b91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd" +
+		"6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c")
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(address): true}}
+
+	return []EvalTV{
+		evalTV("eth_personal_sign", verify(sig, crypto.EthPersonalSign, address), msg),
+		evalTV("eth_typed_data", verify(typedSig, crypto.EthTypedData, typedAddress), msg),
+		evalTV("eth_web3_docs", verify(web3Sig, crypto.EthPersonalSign, web3Address), []byte("Some data")),
+		evalTV("eth_web3_docs_wrong_msg", verify(web3Sig, crypto.EthPersonalSign, web3Address), []byte("Some datb")),
+		evalTV("eth_long_msg", verify(longSig, crypto.EthTypedData, longAddress), longMsg),
+		evalTV("eth_empty_msg", verify(emptySig, crypto.EthPersonalSign, emptyAddress), nil),
+		evalTV("eth_v_0", verify(mutate(sig, func(b []byte) { b[64] -= 27 }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_v_flipped", verify(mutate(sig, func(b []byte) { b[64] = 55 - b[64] }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_v_2", verify(mutate(sig, func(b []byte) { b[64] = 2 }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_v_29", verify(mutate(sig, func(b []byte) { b[64] = 29 }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_high_s", verify(highS, crypto.EthPersonalSign, address), msg),
+		evalTV("eth_wrong_msg", verify(sig, crypto.EthPersonalSign, address), []byte("test_etH")),
+		evalTV("eth_wrong_address", verify(sig, crypto.EthPersonalSign, otherAddress), msg),
+		evalTV("eth_personal_sig_as_typed", verify(sig, crypto.EthTypedData, address), msg),
+		evalTV("eth_typed_sig_as_personal", verify(typedSig, crypto.EthPersonalSign, typedAddress), msg),
+		evalTV("eth_unprefixed_hash", verify(crypto.SignEthereum(d, crypto.Keccak256(msg)), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_corrupt_r", verify(mutate(sig, func(b []byte) { b[5] ^= 1 }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_corrupt_s", verify(mutate(sig, func(b []byte) { b[40] ^= 1 }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_r_zero", verify(mutate(sig, func(b []byte) { copy(b, make([]byte, 32)) }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_s_zero", verify(mutate(sig, func(b []byte) { copy(b[32:], make([]byte, 32)) }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_r_n", verify(mutate(sig, func(b []byte) { n.FillBytes(b[:32]) }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_r_no_point", verify(mutate(sig, func(b []byte) { noPoint.FillBytes(b[:32]) }), crypto.EthPersonalSign, address), msg),
+		evalTV("eth_zero_sig", verify(make([]byte, 65), crypto.EthPersonalSign, address), msg),
+		evalTVCtx("eth_revoked", verify(sig, crypto.EthPersonalSign, address), revoked),
+		evalTVAsm("eth_unknown_mode", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.Push(address)); a.Append(ll.Push1(2)); a.Append(ll.EthVerify())
+		}, msg),
+		evalTVAsm("eth_short_sig", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig[:64])); a.Append(ll.Push(address)); a.Append(ll.Push1(0)); a.Append(ll.EthVerify())
+		}, msg),
+		evalTVAsm("eth_typed_no_domain", func(a *ll.Assembler) {
+			a.Append(ll.Push(address)); a.Append(ll.Push1(1)); a.Append(ll.EthVerify())
+		}, msg),
+		evalTVAsm("eth_empty_stack", func(a *ll.Assembler) { a.Append(ll.EthVerify()) }, msg),
+	}
+}
+
+func pkHashTests() []EvalTV {
+	msg := []byte("test_pkhash")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, _ := crypto.HelperVerifyData(msg)
+	h1, _ := crypto.PublicKeyHash(pk1, 20)
+	h2, _ := crypto.PublicKeyHash(pk2, 32)
+
+	check := func(a *ll.Assembler, keys [][]byte, commitments [][]byte) {
+		for i := len(keys) - 1; i >= 0; i-- {
+			a.Append(ll.Push(keys[i]))
+		}
+		for _, in := range ll.CheckPKHashes(commitments) {
+			a.Append(in)
+		}
+	}
+
+	return []EvalTV{
+		evalTVAsm("pkhash_20", func(a *ll.Assembler) { check(a, [][]byte{pk1}, [][]byte{h1}) }, nil),
+		evalTVAsm("pkhash_32", func(a *ll.Assembler) { check(a, [][]byte{pk2}, [][]byte{h2}) }, nil),
+		evalTVAsm("pkhash_two", func(a *ll.Assembler) { check(a, [][]byte{pk1, pk2}, [][]byte{h1, h2}) }, nil),
+		evalTVAsm("pkhash_swapped", func(a *ll.Assembler) { check(a, [][]byte{pk2, pk1}, [][]byte{h1, h2}) }, nil),
+		evalTVAsm("pkhash_wrong_key", func(a *ll.Assembler) { check(a, [][]byte{pk2}, [][]byte{h1}) }, nil),
+		evalTVAsm("pkhash_short_commitment", func(a *ll.Assembler) { check(a, [][]byte{pk1}, [][]byte{h1[:19]}) }, nil),
+		evalTVAsm("pkhash_truncated_32", func(a *ll.Assembler) { check(a, [][]byte{pk2}, [][]byte{h2[:20]}) }, nil),
+		evalTVAsm("pkhash_missing_key", func(a *ll.Assembler) { check(a, nil, [][]byte{h1}) }, nil),
+		evalTVAsm("pkhash_bad_key_prefix", func(a *ll.Assembler) {
+			bad := append([]byte{0x04}, pk1[1:]...)
+			check(a, [][]byte{bad}, [][]byte{h1})
+		}, nil),
+		evalTVAsm("pkhash_zero", func(a *ll.Assembler) {
+			a.Append(ll.Push(pk1)); a.Append(ll.Push1(0)); a.Append(ll.CheckPKHash())
+		}, nil),
+		evalTVAsm("pkhash_sigverify", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig1))
+			check(a, [][]byte{pk1}, [][]byte{h1})
+			a.Append(ll.SignatureVerify())
+		}, msg),
+		evalTV("pkhash_empty_stack", []byte{ll.OP_CHECKPKHASH}, nil),
+	}
+}
+
+func nonceTests() []EvalTV {
+	nonce := []byte{0xde, 0xad, 0xbe, 0xef}
+	msg, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: 1, Value: []byte("unlock debug port")},
+		{Tag: 2, Value: nonce},
+		{Tag: 3},
+	})
+	check := func(tag int) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push1(tag)); a.Append(ll.CheckNonce())
+		return a.Code
+	}
+	ctx := func(msg, nonce []byte) *ll.Context { return &ll.Context{Xmsg: msg, Nonce: nonce} }
+
+	return []EvalTV{
+		evalTVCtx("nonce_match", check(2), ctx(msg, nonce)),
+		evalTVCtx("nonce_prefix", check(2), ctx(msg, nonce[:3])),
+		evalTVCtx("nonce_longer", check(2), ctx(msg, append(nonce, 0))),
+		evalTVCtx("nonce_other_field", check(1), ctx(msg, nonce)),
+		evalTVCtx("nonce_empty_field", check(3), ctx(msg, nonce)),
+		evalTVCtx("nonce_missing_field", check(4), ctx(msg, nonce)),
+		evalTVCtx("nonce_none_supplied", check(2), ctx(msg, nil)),
+		evalTVCtx("nonce_none_supplied_empty_field", check(3), ctx(msg, nil)),
+		evalTVCtx("nonce_bad_msg", check(2), ctx(append(msg, 0), nonce)),
+		evalTVCtx("nonce_empty_stack", []byte{ll.OP_CHECKNONCE}, ctx(msg, nonce)),
+	}
+}
+
+func catSplitSizeTests() []EvalTV {
+	long := make([]byte, 200)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	cat := func(x, y []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushBlob(x)); a.Append(ll.PushBlob(y)); a.Append(ll.Cat())
+		return a.Code
+	}
+	split := func(x []byte, n int64) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushBlob(x)); a.Append(ll.PushNum(n)); a.Append(ll.Split())
+		return a.Code
+	}
+	size := func(x []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.PushBlob(x)); a.Append(ll.Size())
+		return a.Code
+	}
+	return []EvalTV{
+		evalTV("cat_empty", cat(nil, nil), nil),
+		evalTV("cat_simple", cat([]byte{1, 2}, []byte{3, 4, 5}), nil),
+		evalTV("cat_left_empty", cat(nil, []byte{7}), nil),
+		evalTV("cat_max", cat(long, long[:55]), nil),
+		evalTV("cat_too_large", cat(long, long[:56]), nil),
+		evalTV("cat_underflow", []byte{ll.OP_PUSH, 1, 0, ll.OP_CAT}, nil),
+		evalTV("cat_short_blob", []byte{ll.OP_PUSH, 1, 0, ll.OP_PUSH, 2, 0, 5, ll.OP_CAT}, nil),
+		evalTV("split_zero", split([]byte{1, 2, 3}, 0), nil),
+		evalTV("split_middle", split([]byte{1, 2, 3}, 1), nil),
+		evalTV("split_end", split([]byte{1, 2, 3}, 3), nil),
+		evalTV("split_past_end", split([]byte{1, 2, 3}, 4), nil),
+		evalTV("split_negative", split([]byte{1, 2, 3}, -1), nil),
+		evalTV("split_empty", split(nil, 0), nil),
+		evalTV("split_long", split(long, 100), nil),
+		evalTV("split_then_cat", append(split(long, 37), ll.OP_CAT), nil),
+		evalTV("split_bad_num", []byte{ll.OP_PUSH, 2, 0, 0, ll.OP_PUSH, 1, 1, ll.OP_SPLIT}, nil),
+		evalTV("split_underflow", []byte{ll.OP_PUSH, 2, 0, 1, ll.OP_SPLIT}, nil),
+		evalTV("size_empty", size(nil), nil),
+		evalTV("size_small", size([]byte{9, 8, 7}), nil),
+		evalTV("size_long", size(long), nil),
+		evalTV("size_max", append(cat(long, long[:55]), ll.OP_SIZE), nil),
+		evalTV("size_underflow", []byte{ll.OP_SIZE}, nil),
+		evalTV("size_short_blob", []byte{ll.OP_PUSH, 1, 4, ll.OP_SIZE}, nil),
+	}
+}
+
+func checkSigFromStackTests() []EvalTV {
+	attestation := []byte("build 1234 passed tests")
+	_, pk, sig := crypto.HelperVerifyData(attestation)
+	_, otherPK, _ := crypto.HelperVerifyData(attestation)
+	_, emptyPK, emptySig := crypto.HelperVerifyData([]byte{})
+	long := make([]byte, 255)
+	_, longPK, longSig := crypto.HelperVerifyData(long)
+
+	csfs := func(sig, msg, pk []byte) []byte {
+		a := ll.Assembler{}
+		a.Append(ll.Push(sig)); a.Append(ll.PushBlob(msg)); a.Append(ll.Push(pk)); a.Append(ll.CheckSigFromStack())
+		return a.Code
+	}
+	return []EvalTV{
+		evalTV("csfs_valid", csfs(sig, attestation, pk), nil),
+		evalTV("csfs_valid_ignores_xmsg", csfs(sig, attestation, pk), []byte("xmsg")),
+		evalTV("csfs_wrong_msg", csfs(sig, []byte("build 1235 passed tests"), pk), nil),
+		evalTV("csfs_wrong_key", csfs(sig, attestation, otherPK), nil),
+		evalTV("csfs_dummy_sig", csfs([]byte{0x30, 0x00}, attestation, pk), nil),
+		evalTV("csfs_empty_msg", csfs(emptySig, nil, emptyPK), nil),
+		evalTVAsm("csfs_max_msg", func(a *ll.Assembler) {
+			// too long for one PushBlob
+			a.Append(ll.Push(longSig)); a.Append(ll.PushBlob(long[:200])); a.Append(ll.PushBlob(long[200:])); a.Append(ll.Cat())
+			a.Append(ll.Push(longPK)); a.Append(ll.CheckSigFromStack())
+		}, nil),
+		evalTV("csfs_empty_stack", []byte{ll.OP_CHECKSIGFROMSTACK}, nil),
+		evalTVAsm("csfs_missing_sig", func(a *ll.Assembler) {
+			a.Append(ll.PushBlob(attestation)); a.Append(ll.Push(pk)); a.Append(ll.CheckSigFromStack())
+		}, nil),
+		evalTVAsm("csfs_short_msg", func(a *ll.Assembler) {
+			a.Append(ll.Push(sig)); a.Append(ll.Push1(200)); a.Append(ll.Push(pk)); a.Append(ll.CheckSigFromStack())
+		}, nil),
+	}
+}
+
+func revocationEvalTests() []EvalTV {
+	msg := []byte("test_revocation")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	revoke := func(pks ...[]byte) *ll.Context {
+		set := ll.RevocationSet{}
+		for _, pk := range pks {
+			set[crypto.KeyFingerprint(pk)] = true
+		}
+		return &ll.Context{Xmsg: msg, Revoked: set}
+	}
+	asm := func(build func(a *ll.Assembler)) []byte {
+		a := ll.Assembler{}
+		build(&a)
+		return a.Code
+	}
+
+	sigverify := asm(func(a *ll.Assembler) {
+		a.Append(ll.Push(sig1)); a.Append(ll.Push(pk1)); a.Append(ll.SignatureVerify())
+	})
+	csfs := asm(func(a *ll.Assembler) {
+		a.Append(ll.Push(sig1)); a.Append(ll.PushBlob(msg)); a.Append(ll.Push(pk1)); a.Append(ll.CheckSigFromStack())
+	})
+	multisig := func(sigs ...[]byte) []byte {
+		return asm(func(a *ll.Assembler) {
+			for _, sig := range sigs {
+				a.Append(ll.Push(sig))
+			}
+			a.Append(ll.Push(pk2)); a.Append(ll.Push(pk1))
+			a.Append(ll.Push1(len(sigs))); a.Append(ll.Push1(2)); a.Append(ll.MultisigVerify())
+		})
+	}
+	weighted := asm(func(a *ll.Assembler) {
+		a.Append(ll.Push(sig2)); a.Append(ll.Push(sig1)); a.Append(ll.Push1(2))
+		a.Append(ll.Push(pk1)); a.Append(ll.PushNum(2)); a.Append(ll.Push(pk2)); a.Append(ll.PushNum(1))
+		a.Append(ll.PushNum(2)); a.Append(ll.Push1(2)); a.Append(ll.WeightedSigVerify())
+	})
+	root, _ := crypto.MerkleRoot([][]byte{pk1, pk2})
+	proof, _ := crypto.MerkleProof([][]byte{pk1, pk2}, 0)
+	merkle := asm(func(a *ll.Assembler) {
+		a.Append(ll.Push(sig1))
+		for _, in := range ll.PushMerkleProof(pk1, proof) {
+			a.Append(in)
+		}
+		a.Append(ll.Push(root)); a.Append(ll.MerkleSigVerify())
+	})
+
+	return []EvalTV{
+		evalTVCtx("revoked_none", sigverify, revoke()),
+		evalTVCtx("revoked_sigverify", sigverify, revoke(pk1)),
+		evalTVCtx("revoked_other_key", sigverify, revoke(pk2)),
+		evalTVCtx("revoked_csfs", csfs, revoke(pk1)),
+		evalTVCtx("revoked_multisig_1of2_revoked_signer", multisig(sig1), revoke(pk1)),
+		evalTVCtx("revoked_multisig_1of2_other_signer", multisig(sig2), revoke(pk1)),
+		evalTVCtx("revoked_multisig_2of2", multisig(sig1, sig2), revoke(pk2)),
+		evalTVCtx("revoked_multisig_both", multisig(sig2), revoke(pk1, pk2)),
+		evalTVCtx("revoked_weighted_heavy", weighted, revoke(pk1)),
+		evalTVCtx("revoked_weighted_light", weighted, revoke(pk2)),
+		evalTVCtx("revoked_merkle", merkle, revoke(pk1)),
+		evalTVCtx("revoked_merkle_sibling", merkle, revoke(pk2)),
+	}
+}
+
+func weightedEvalTests() []EvalTV {
+	msg := []byte("test_weighted")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+
+	// pk1 has weight 3, pk2 and pk3 weight 1 each
+	weighted := func(a *ll.Assembler, pks [][]byte, weights []int64, target int64, sigs ...[]byte) {
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		a.Append(ll.Push1(len(sigs)))
+		for i, pk := range pks {
+			a.Append(ll.Push(pk)); a.Append(ll.PushNum(weights[i]))
+		}
+		a.Append(ll.PushNum(target)); a.Append(ll.Push1(len(pks)))
+		a.Append(ll.WeightedSigVerify())
+	}
+	pks := [][]byte{pk1, pk2, pk3}
+	w := []int64{3, 1, 1}
+
+	return []EvalTV{
+		evalTVAsm("weighted_heavy_alone", func(a *ll.Assembler) { weighted(a, pks, w, 3, sig1) }, msg),
+		evalTVAsm("weighted_light_short", func(a *ll.Assembler) { weighted(a, pks, w, 3, sig2, sig3) }, msg),
+		evalTVAsm("weighted_all", func(a *ll.Assembler) { weighted(a, pks, w, 5, sig3, sig2, sig1) }, msg),
+		evalTVAsm("weighted_dup_sig", func(a *ll.Assembler) { weighted(a, pks, w, 2, sig2, sig2) }, msg),
+		evalTVAsm("weighted_no_sigs", func(a *ll.Assembler) { weighted(a, pks, w, 1) }, msg),
+		evalTVAsm("weighted_wrong_msg", func(a *ll.Assembler) { weighted(a, pks, w, 3, sig1) }, []byte("wrong")),
+		evalTVAsm("weighted_repeated_pk_one_sig", func(a *ll.Assembler) {
+			weighted(a, [][]byte{pk1, pk1}, []int64{2, 2}, 4, sig1)
+		}, msg),
+		evalTVAsm("weighted_repeated_pk_two_sigs", func(a *ll.Assembler) {
+			weighted(a, [][]byte{pk1, pk1}, []int64{2, 2}, 4, sig1, sig1)
+		}, msg),
+		evalTVAsm("weighted_repeated_pk_apart", func(a *ll.Assembler) {
+			weighted(a, [][]byte{pk1, pk2, pk1}, []int64{2, 1, 2}, 2, sig2)
+		}, msg),
+		evalTVAsm("weighted_zero_keys", func(a *ll.Assembler) { weighted(a, nil, nil, 1, sig1) }, msg),
+		evalTVAsm("weighted_zero_target", func(a *ll.Assembler) { weighted(a, pks, w, 0, sig1) }, msg),
+		evalTVAsm("weighted_negative_target", func(a *ll.Assembler) { weighted(a, pks, w, -3, sig1) }, msg),
+		evalTVAsm("weighted_zero_weight", func(a *ll.Assembler) { weighted(a, pks, []int64{3, 0, 1}, 3, sig1) }, msg),
+		evalTVAsm("weighted_too_many_sigs", func(a *ll.Assembler) {
+			weighted(a, [][]byte{pk1}, []int64{1}, 1, sig1, sig2)
+		}, msg),
+		evalTVAsm("weighted_sum_overflow", func(a *ll.Assembler) {
+			weighted(a, pks, []int64{math.MaxInt64, 1, 1}, 3, sig1, sig2)
+		}, msg),
+		evalTVAsm("weighted_max_weight", func(a *ll.Assembler) {
+			weighted(a, pks, []int64{math.MaxInt64, 1, 1}, math.MaxInt64, sig1)
+		}, msg),
+		evalTV("weighted_empty_stack", []byte{ll.OP_WEIGHTEDSIGVERIFY}, msg),
+		evalTV("weighted_missing_target", []byte{ll.OP_PUSH, 1, 1, ll.OP_WEIGHTEDSIGVERIFY}, msg),
+	}
+}
+
+func merkleEvalTests() []EvalTV {
+	msg := []byte("test_merkle")
+	var pks, sigs [][]byte
+	for i := 0; i < 5; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		pks = append(pks, pk)
+		sigs = append(sigs, sig)
+	}
+	root, _ := crypto.MerkleRoot(pks)
+	_, outsider, outsiderSig := crypto.HelperVerifyData(msg)
+
+	merkle := func(a *ll.Assembler, sig, pk []byte, proof []crypto.MerkleStep, root []byte) {
+		a.Append(ll.Push(sig))
+		for _, in := range ll.PushMerkleProof(pk, proof) {
+			a.Append(in)
+		}
+		a.Append(ll.Push(root)); a.Append(ll.MerkleSigVerify())
+	}
+
+	var tests []EvalTV
+	for i := range pks {
+		i := i
+		proof, _ := crypto.MerkleProof(pks, i)
+		tests = append(tests, evalTVAsm(fmt.Sprintf("merkle_leaf_%d", i), func(a *ll.Assembler) {
+			merkle(a, sigs[i], pks[i], proof, root)
+		}, msg))
+	}
+	proof0, _ := crypto.MerkleProof(pks, 0)
+	tampered := append([]crypto.MerkleStep{{Sibling: make([]byte, 32)}}, proof0[1:]...)
+	flipped := append([]crypto.MerkleStep{{Sibling: proof0[0].Sibling, Left: !proof0[0].Left}}, proof0[1:]...)
+	deep := make([]crypto.MerkleStep, ll.MaxMerkleDepth+1)
+	for i := range deep {
+		deep[i].Sibling = make([]byte, 32)
+	}
+	single := crypto.MerkleLeafHash(pks[0])
+
+	return append(tests,
+		evalTVAsm("merkle_wrong_msg", func(a *ll.Assembler) { merkle(a, sigs[0], pks[0], proof0, root) }, []byte("wrong")),
+		evalTVAsm("merkle_outsider", func(a *ll.Assembler) { merkle(a, outsiderSig, outsider, proof0, root) }, msg),
+		evalTVAsm("merkle_other_leaf_proof", func(a *ll.Assembler) { merkle(a, sigs[1], pks[1], proof0, root) }, msg),
+		evalTVAsm("merkle_tampered_sibling", func(a *ll.Assembler) { merkle(a, sigs[0], pks[0], tampered, root) }, msg),
+		evalTVAsm("merkle_flipped_side", func(a *ll.Assembler) { merkle(a, sigs[0], pks[0], flipped, root) }, msg),
+		evalTVAsm("merkle_single_leaf", func(a *ll.Assembler) { merkle(a, sigs[0], pks[0], nil, single) }, msg),
+		evalTVAsm("merkle_dummy_sig", func(a *ll.Assembler) { merkle(a, []byte{0x30, 0x00}, pks[0], proof0, root) }, msg),
+		evalTVAsm("merkle_too_deep", func(a *ll.Assembler) { merkle(a, sigs[0], pks[0], deep, root) }, msg),
+		evalTVAsm("merkle_bad_side", func(a *ll.Assembler) {
+			a.Append(ll.Push(sigs[0])); a.Append(ll.Push(make([]byte, 32))); a.Append(ll.Push1(2))
+			a.Append(ll.Push1(1)); a.Append(ll.Push(pks[0])); a.Append(ll.Push(single))
+			a.Append(ll.MerkleSigVerify())
+		}, msg),
+		evalTVAsm("merkle_missing_steps", func(a *ll.Assembler) {
+			a.Append(ll.Push1(2)); a.Append(ll.Push(pks[0])); a.Append(ll.Push(root))
+			a.Append(ll.MerkleSigVerify())
+		}, msg),
+		evalTVAsm("merkle_missing_sig", func(a *ll.Assembler) {
+			for _, in := range ll.PushMerkleProof(pks[0], proof0) {
+				a.Append(in)
+			}
+			a.Append(ll.Push(root)); a.Append(ll.MerkleSigVerify())
+		}, msg),
+		evalTV("merkle_empty_stack", []byte{ll.OP_MERKLESIGVERIFY}, msg),
+	)
+}
+
+// ---- m001 test generators ----
+
+func singleSigM001Tests() []M001TV {
+	msg := []byte("yolo")
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	xsig := serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push(sig)) })
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk)); mc.Append(ll.SignatureVerify())
+	})
+
+	return []M001TV{
+		m001TV("m001_singlesig_valid", xpk, xsig, msg),
+		m001TV("m001_singlesig_wrong_msg", xpk, xsig, []byte("wrong")),
+	}
+}
+
+func multisigM001Tests() []M001TV {
+	msg := []byte("multisig_test")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+
+	build2of3PK := func(pka, pkb, pkc []byte) []byte {
+		return serializeXPubKey(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(pka)); mc.Append(ll.Push(pkb)); mc.Append(ll.Push(pkc))
+			mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
+		})
+	}
+
+	build2Sigs := func(sa, sb []byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sa)); mc.Append(ll.Push(sb))
+		})
+	}
+
+	xpk := build2of3PK(pk1, pk2, pk3)
+
+	var tests []M001TV
+
+	// Valid 2-of-3 combinations
+	tests = append(tests, m001TV("m001_2of3_sig12", xpk, build2Sigs(sig1, sig2), msg))
+	tests = append(tests, m001TV("m001_2of3_sig21", xpk, build2Sigs(sig2, sig1), msg))
+	tests = append(tests, m001TV("m001_2of3_sig13", xpk, build2Sigs(sig1, sig3), msg))
+	tests = append(tests, m001TV("m001_2of3_sig23", xpk, build2Sigs(sig2, sig3), msg))
+
+	// Duplicate sigs (should fail)
+	tests = append(tests, m001TV("m001_2of3_dup_sig11", xpk, build2Sigs(sig1, sig1), msg))
+	tests = append(tests, m001TV("m001_2of3_dup_sig22", xpk, build2Sigs(sig2, sig2), msg))
+
+	// Wrong message
+	tests = append(tests, m001TV("m001_2of3_wrong_msg", xpk, build2Sigs(sig1, sig2), []byte("bad")))
+
+	// 1-of-1
+	xpk1of1 := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk1)); mc.Append(ll.Push1(1)); mc.Append(ll.Push1(1))
+		mc.Append(ll.MultisigVerify())
+	})
+	xsig1of1 := serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push(sig1)) })
+	tests = append(tests, m001TV("m001_1of1_valid", xpk1of1, xsig1of1, msg))
+
+	// 3-of-3
+	xpk3of3 := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk1)); mc.Append(ll.Push(pk2)); mc.Append(ll.Push(pk3))
+		mc.Append(ll.Push1(3)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
+	})
+	xsig3of3 := serializeXSig(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(sig1)); mc.Append(ll.Push(sig2)); mc.Append(ll.Push(sig3))
+	})
+	tests = append(tests, m001TV("m001_3of3_valid", xpk3of3, xsig3of3, msg))
+
+	// 3-of-3 with only 2 distinct
+	xsig3of3dup := serializeXSig(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(sig1)); mc.Append(ll.Push(sig2)); mc.Append(ll.Push(sig1))
+	})
+	tests = append(tests, m001TV("m001_3of3_only2distinct", xpk3of3, xsig3of3dup, msg))
+
+	// Repeated public keys
+	xpkRepeat := build2of3PK(pk1, pk1, pk3)
+	tests = append(tests, m001TV("m001_2of3_repeated_pk_pass", xpkRepeat, build2Sigs(sig1, sig3), msg))
+	tests = append(tests, m001TV("m001_2of3_repeated_pk_fail", xpkRepeat, build2Sigs(sig2, sig3), msg))
+
+	return tests
+}
+
+func branchM001Tests() []M001TV {
+	msg := []byte("branch_test")
+	_, pkA, sigA := crypto.HelperVerifyData(msg)
+	_, pkB, sigB := crypto.HelperVerifyData(msg)
+	_, pkC, sigC := crypto.HelperVerifyData(msg)
+
+	// A OR (B AND C)
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.If()); mc.Append(ll.Push(pkA)); mc.Append(ll.SignatureVerify())
+		mc.Append(ll.Else())
+		mc.Append(ll.Push(pkB)); mc.Append(ll.Push(pkC))
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(2)); mc.Append(ll.MultisigVerify())
+		mc.Append(ll.EndIf())
+	})
+
+	return []M001TV{
+		m001TV("m001_branch_a", xpk, serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sigA)); mc.Append(ll.Push1(1))
+		}), msg),
+		m001TV("m001_branch_bc", xpk, serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sigB)); mc.Append(ll.Push(sigC)); mc.Append(ll.Push1(0))
+		}), msg),
+		m001TV("m001_branch_a_wrong_side", xpk, serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sigA)); mc.Append(ll.Push1(0))
+		}), msg),
+		m001TV("m001_branch_open_in_xsig", xpk, serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sigA)); mc.Append(ll.Push1(1)); mc.Append(ll.Push1(1)); mc.Append(ll.If())
+		}), msg),
+	}
+}
+
+func thresholdM001Tests() []M001TV {
+	msg := []byte("threshold_test")
+	keys := func(n int) ([][]byte, [][]byte) {
+		var pks, sigs [][]byte
+		for i := 0; i < n; i++ {
+			_, pk, sig := crypto.HelperVerifyData(msg)
+			pks = append(pks, pk)
+			sigs = append(sigs, sig)
+		}
+		return pks, sigs
+	}
+	eng, engSig := keys(3)
+	mgr, mgrSig := keys(2)
+	vp, vpSig := keys(2)
+	dummy := []byte{0x30, 0x00}
+
+	// [two engineers and one manager] or [two vice-presidents]
+	multisig := func(mc *machines.MachineCode, pks [][]byte, k int) {
+		for _, pk := range pks {
+			mc.Append(ll.Push(pk))
+		}
+		mc.Append(ll.Push1(k)); mc.Append(ll.Push1(len(pks))); mc.Append(ll.MultisigVerify())
+	}
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		multisig(mc, eng, 2); mc.Append(ll.ToAltStack())
+		multisig(mc, mgr, 1); mc.Append(ll.ToAltStack())
+		multisig(mc, vp, 2)
+		mc.Append(ll.FromAltStack()); mc.Append(ll.FromAltStack())
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(2)); mc.Append(ll.Threshold())
+		mc.Append(ll.Push1(1)); mc.Append(ll.Push1(2)); mc.Append(ll.Threshold())
+	})
+	xsig := func(sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for _, sig := range sigs {
+				mc.Append(ll.Push(sig))
+			}
+		})
+	}
+
+	return []M001TV{
+		m001TV("m001_threshold_eng_mgr", xpk, xsig(dummy, dummy, mgrSig[0], engSig[0], engSig[2]), msg),
+		m001TV("m001_threshold_vp", xpk, xsig(vpSig[0], vpSig[1], dummy, dummy, dummy), msg),
+		m001TV("m001_threshold_eng_only", xpk, xsig(dummy, dummy, dummy, engSig[0], engSig[1]), msg),
+		m001TV("m001_threshold_one_vp_mgr", xpk, xsig(vpSig[0], dummy, mgrSig[1], dummy, dummy), msg),
+	}
+}
+
+func weightedM001Tests() []M001TV {
+	msg := []byte("weighted_test")
+	_, cto, ctoSig := crypto.HelperVerifyData(msg)
+	pks := [][]byte{cto}
+	weights := []int64{3}
+	var engSig [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		pks = append(pks, pk)
+		weights = append(weights, 1)
+		engSig = append(engSig, sig)
+	}
+
+	// the CTO alone, or all three engineers
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for i, pk := range pks {
+			mc.Append(ll.Push(pk)); mc.Append(ll.PushNum(weights[i]))
+		}
+		mc.Append(ll.PushNum(3)); mc.Append(ll.Push1(len(pks)))
+		mc.Append(ll.WeightedSigVerify())
+	})
+	// the CTO listed twice to reach the target alone
+	dupXpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(cto)); mc.Append(ll.PushNum(2))
+		mc.Append(ll.Push(cto)); mc.Append(ll.PushNum(2))
+		mc.Append(ll.PushNum(4)); mc.Append(ll.Push1(2))
+		mc.Append(ll.WeightedSigVerify())
+	})
+	xsig := func(sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for _, sig := range sigs {
+				mc.Append(ll.Push(sig))
+			}
+			mc.Append(ll.Push1(len(sigs)))
+		})
+	}
+
+	return []M001TV{
+		m001TV("m001_weighted_cto", xpk, xsig(ctoSig), msg),
+		m001TV("m001_weighted_engineers", xpk, xsig(engSig[2], engSig[0], engSig[1]), msg),
+		m001TV("m001_weighted_two_engineers", xpk, xsig(engSig[0], engSig[1]), msg),
+		m001TV("m001_weighted_dup_engineer", xpk, xsig(engSig[0], engSig[0], engSig[0]), msg),
+		m001TV("m001_weighted_wrong_msg", xpk, xsig(ctoSig), []byte("bad")),
+		m001TV("m001_weighted_repeated_cto", dupXpk, xsig(ctoSig), msg),
+	}
+}
+
+func merkleM001Tests() []M001TV {
+	msg := []byte("merkle_test")
+	var pks, sigs [][]byte
+	for i := 0; i < 50; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		pks = append(pks, pk)
+		sigs = append(sigs, sig)
+	}
+	root, _ := crypto.MerkleRoot(pks)
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(root)); mc.Append(ll.MerkleSigVerify())
+	})
+	xsig := func(sig []byte, claimed int) []byte {
+		proof, _ := crypto.MerkleProof(pks, claimed)
+		return serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sig))
+			for _, in := range ll.PushMerkleProof(pks[claimed], proof) {
+				mc.Append(in)
+			}
+		})
+	}
+
+	var tests []M001TV
+	for _, i := range []int{0, 1, 31, 48, 49} {
+		tests = append(tests, m001TV(fmt.Sprintf("m001_merkle_leaf_%d", i), xpk, xsig(sigs[i], i), msg))
+	}
+	return append(tests,
+		m001TV("m001_merkle_wrong_proof", xpk, xsig(sigs[3], 4), msg),
+		m001TV("m001_merkle_wrong_msg", xpk, xsig(sigs[3], 3), []byte("bad")),
+	)
+}
+
+func msgFieldM001Tests() []M001TV {
+	// single signer, only for product 0x42 debug-unlock commands
+	privKey, pk, _ := crypto.HelperVerifyData(nil)
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk)); mc.Append(ll.SignatureVerify())
+		mc.Append(ll.Push1(1)); mc.Append(ll.MsgField()); mc.Append(ll.PushBlob([]byte{0x42}))
+		mc.Append(ll.Equal()); mc.Append(ll.And())
+		mc.Append(ll.Push1(2)); mc.Append(ll.MsgField()); mc.Append(ll.PushBlob([]byte("debug-unlock")))
+		mc.Append(ll.Equal()); mc.Append(ll.And())
+	})
+	message := func(product byte, command string) []byte {
+		msg, _ := ll.EncodeMessage([]ll.MessageField{
+			{Tag: 1, Value: []byte{product}},
+			{Tag: 2, Value: []byte(command)},
+		})
+		return msg
+	}
+	xsig := func(msg []byte) []byte {
+		hash := sha256.Sum256(msg)
+		sig, err := ecdsa.SignASN1(rand.Reader, privKey, hash[:])
+		if err != nil {
+			panic(err)
+		}
+		return serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push(sig)) })
+	}
+
+	unlock42 := message(0x42, "debug-unlock")
+	unlock43 := message(0x43, "debug-unlock")
+	flash42 := message(0x42, "flash")
+	return []M001TV{
+		m001TV("m001_msgfield_ok", xpk, xsig(unlock42), unlock42),
+		m001TV("m001_msgfield_other_product", xpk, xsig(unlock43), unlock43),
+		m001TV("m001_msgfield_other_command", xpk, xsig(flash42), flash42),
+		m001TV("m001_msgfield_unstructured", xpk, xsig([]byte("yolo")), []byte("yolo")),
+	}
+}
+
+func oracleM001Tests() []M001TV {
+	// release signed by a maintainer, and CI attested the build id in it
+	sign := func(priv *ecdsa.PrivateKey, msg []byte) []byte {
+		hash := sha256.Sum256(msg)
+		sig, err := ecdsa.SignASN1(rand.Reader, priv, hash[:])
+		if err != nil {
+			panic(err)
+		}
+		return sig
+	}
+	maintainer, maintainerPK, _ := crypto.HelperVerifyData(nil)
+	ci, ciPK, _ := crypto.HelperVerifyData(nil)
+	msg, _ := ll.EncodeMessage([]ll.MessageField{{Tag: 1, Value: []byte("build-1234")}})
+
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(maintainerPK)); mc.Append(ll.SignatureVerify()); mc.Append(ll.ToAltStack())
+		mc.Append(ll.Push1(1)); mc.Append(ll.MsgField()); mc.Append(ll.Push(ciPK)); mc.Append(ll.CheckSigFromStack())
+		mc.Append(ll.FromAltStack()); mc.Append(ll.And())
+	})
+	xpkDomain := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(maintainerPK)); mc.Append(ll.SignatureVerify()); mc.Append(ll.ToAltStack())
+		mc.Append(ll.PushBlob([]byte("ci-passed:"))); mc.Append(ll.Push1(1)); mc.Append(ll.MsgField()); mc.Append(ll.Cat())
+		mc.Append(ll.Push(ciPK)); mc.Append(ll.CheckSigFromStack())
+		mc.Append(ll.FromAltStack()); mc.Append(ll.And())
+	})
+	xsig := func(ciSig, releaseSig []byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(ciSig)); mc.Append(ll.Push(releaseSig))
+		})
+	}
+
+	return []M001TV{
+		m001TV("m001_oracle_ok", xpk, xsig(sign(ci, []byte("build-1234")), sign(maintainer, msg)), msg),
+		m001TV("m001_oracle_other_build", xpk, xsig(sign(ci, []byte("build-1233")), sign(maintainer, msg)), msg),
+		m001TV("m001_oracle_not_ci", xpk, xsig(sign(maintainer, []byte("build-1234")), sign(maintainer, msg)), msg),
+		m001TV("m001_oracle_no_release_sig", xpk, xsig(sign(ci, []byte("build-1234")), sign(ci, msg)), msg),
+		// CI signs "ci-passed:" || build id, so its other signatures cannot be replayed
+		m001TV("m001_oracle_domain_ok", xpkDomain, xsig(sign(ci, []byte("ci-passed:build-1234")), sign(maintainer, msg)), msg),
+		m001TV("m001_oracle_domain_missing", xpkDomain, xsig(sign(ci, []byte("build-1234")), sign(maintainer, msg)), msg),
+	}
+}
+
+func revocationM001Tests() []M001TV {
+	// 2-of-3 release signing, one signer's key revoked
+	msg := []byte("release_2.0")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk3)); mc.Append(ll.Push(pk2)); mc.Append(ll.Push(pk1))
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
+	})
+	xsig := func(sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for _, sig := range sigs {
+				mc.Append(ll.Push(sig))
+			}
+		})
+	}
+	revoked := &ll.Context{Xmsg: msg, Revoked: ll.RevocationSet{crypto.KeyFingerprint(pk1): true}}
+
+	return []M001TV{
+		m001TV("m001_revocation_before", xpk, xsig(sig1, sig2), msg),
+		m001TVCtx("m001_revocation_revoked_signer", xpk, xsig(sig1, sig2), revoked),
+		m001TVCtx("m001_revocation_other_signers", xpk, xsig(sig2, sig3), revoked),
+	}
+}
+
+func pkHashM001Tests() []M001TV {
+	// 2-of-3 over 20-byte key hashes, the xsig reveals the keys
+	msg := []byte("release_3.0")
+	var pks, sigs, hashes [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		h, _ := crypto.PublicKeyHash(pk, 20)
+		pks, sigs, hashes = append(pks, pk), append(sigs, sig), append(hashes, h)
+	}
+	_, outsider, outsiderSig := crypto.HelperVerifyData(msg)
+
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for _, in := range ll.CheckPKHashes(hashes) {
+			mc.Append(in)
+		}
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
+	})
+	xsig := func(keys [][]byte, sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for _, sig := range sigs {
+				mc.Append(ll.Push(sig))
+			}
+			for i := len(keys) - 1; i >= 0; i-- {
+				mc.Append(ll.Push(keys[i]))
+			}
+		})
+	}
+
+	return []M001TV{
+		m001TV("m001_pkhash_ok", xpk, xsig(pks, sigs[0], sigs[2]), msg),
+		m001TV("m001_pkhash_wrong_msg", xpk, xsig(pks, sigs[0], sigs[2]), []byte("release_3.1")),
+		m001TV("m001_pkhash_outsider", xpk, xsig([][]byte{pks[0], pks[1], outsider}, sigs[0], outsiderSig), msg),
+	}
+}
+
+func rawSigM001Tests() []M001TV {
+	// 2-of-3 with r || s signatures, e.g. from an HSM that does not do DER
+	msg := []byte("release_4.0")
+	var pks, sigs [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, der := crypto.HelperVerifyData(msg)
+		sig, _ := crypto.RawSignature(der)
+		pks, sigs = append(pks, pk), append(sigs, sig)
+	}
+
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pks[2])); mc.Append(ll.Push(pks[1])); mc.Append(ll.Push(pks[0]))
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerifyRaw())
+	})
+	xsig := func(sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for _, sig := range sigs {
+				mc.Append(ll.Push(sig))
+			}
+		})
+	}
+
+	return []M001TV{
+		m001TV("m001_rawsig_ok", xpk, xsig(sigs[0], sigs[2]), msg),
+		m001TV("m001_rawsig_wrong_msg", xpk, xsig(sigs[0], sigs[2]), []byte("release_4.1")),
+		m001TV("m001_rawsig_same_signer_twice", xpk, xsig(sigs[1], sigs[1]), msg),
+	}
+}
+
+func mixedCurveM001Tests() []M001TV {
+	// treasury 2-of-3: an HSM (P-256) and two hardware wallets (secp256k1
+	// ECDSA and BIP-340)
+	msg := []byte("treasury transfer #42")
+	_, p256PK, p256Sig := crypto.HelperVerifyData(msg)
+	_, k1PK, k1Sig := crypto.HelperVerifyDataSecp256k1(msg)
+	_, schnorrPK, schnorrSig := crypto.HelperVerifyDataSchnorr(msg)
 
-	return []EvalTV{
-		evalTVAsm("sigverify_valid", func(a *ll.Assembler) {
-			a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(ll.SignatureVerify())
-		}, msg),
-		evalTVAsm("sigverify_wrong_msg", func(a *ll.Assembler) {
-			a.Append(ll.Push(sig)); a.Append(ll.Push(pk)); a.Append(ll.SignatureVerify())
-		}, []byte("wrong")),
-		// Sigverify then arithmetic
-		evalTVAsm("sigverify_then_not", func(a *ll.Assembler) {
-			a.Append(ll.Push(sig)); a.Append(ll.Push(pk))
-			a.Append(ll.SignatureVerify()); a.Append(ll.Not())
-		}, msg),
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for _, in := range ll.MixedMultisig(2, []ll.TypedPublicKey{
+			{Type: crypto.KeyTypeP256, PublicKey: p256PK},
+			{Type: crypto.KeyTypeSecp256k1, PublicKey: k1PK},
+			{Type: crypto.KeyTypeSchnorr, PublicKey: schnorrPK},
+		}) {
+			mc.Append(in)
+		}
+	})
+	xsig := func(sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for _, in := range ll.PushSignatureSlots(sigs) {
+				mc.Append(in)
+			}
+		})
 	}
-}
 
-// ---- m001 test generators ----
+	return []M001TV{
+		m001TV("m001_mixed_p256_k1", xpk, xsig(p256Sig, k1Sig, nil), msg),
+		m001TV("m001_mixed_k1_schnorr", xpk, xsig(nil, k1Sig, schnorrSig), msg),
+		m001TV("m001_mixed_one_signer", xpk, xsig(nil, nil, schnorrSig), msg),
+		m001TV("m001_mixed_wrong_msg", xpk, xsig(p256Sig, k1Sig, schnorrSig), []byte("treasury transfer #43")),
+	}
+}
 
-func singleSigM001Tests() []M001TV {
-	msg := []byte("yolo")
-	_, pk, sig := crypto.HelperVerifyData(msg)
+func frostM001Tests() []M001TV {
+	// release signing by any 3 of 5 maintainers, as one FROST group key
+	msg := []byte("release 3.1.0")
+	keys, groupPK, sig := crypto.HelperVerifyDataFROST(3, 5, msg)
+	otherKeys := crypto.HelperFROSTGroup(3, 5)
 
-	xsig := serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push(sig)) })
 	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
-		mc.Append(ll.Push(pk)); mc.Append(ll.SignatureVerify())
+		mc.Append(ll.Push(groupPK)); mc.Append(ll.SignatureVerifyEd25519())
 	})
+	xsig := func(sig []byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sig))
+		})
+	}
 
 	return []M001TV{
-		m001TV("m001_singlesig_valid", xpk, xsig, msg),
-		m001TV("m001_singlesig_wrong_msg", xpk, xsig, []byte("wrong")),
+		m001TV("m001_frost_3of5", xpk, xsig(sig), msg),
+		m001TV("m001_frost_other_3", xpk, xsig(crypto.SignFROST([]*crypto.FROSTKeyShare{keys[4], keys[1], keys[3]}, msg)), msg),
+		m001TV("m001_frost_other_group", xpk, xsig(crypto.SignFROST(otherKeys[:3], msg)), msg),
+		m001TV("m001_frost_wrong_msg", xpk, xsig(sig), []byte("release 3.1.1")),
 	}
 }
 
-func multisigM001Tests() []M001TV {
-	msg := []byte("multisig_test")
+func slhdsaM001Tests() []M001TV {
+	// ECDSA 2-of-3 AND SLH-DSA 1-of-2
+	msg := []byte("firmware v7")
 	_, pk1, sig1 := crypto.HelperVerifyData(msg)
 	_, pk2, sig2 := crypto.HelperVerifyData(msg)
-	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+	_, pk3, _ := crypto.HelperVerifyData(msg)
+	_, pqA, _ := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128f, msg)
+	_, pqB, pqSigB := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128s, msg)
 
-	build2of3PK := func(pka, pkb, pkc []byte) []byte {
-		return serializeXPubKey(func(mc *machines.MachineCode) {
-			mc.Append(ll.Push(pka)); mc.Append(ll.Push(pkb)); mc.Append(ll.Push(pkc))
-			mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk1)); mc.Append(ll.Push(pk2)); mc.Append(ll.Push(pk3))
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
+		for _, in := range ll.SLHDSASigVerify(0, crypto.SLHDSASHA2128f, pqA) {
+			mc.Append(in)
+		}
+		for _, in := range ll.SLHDSASigVerify(1, crypto.SLHDSASHA2128s, pqB) {
+			mc.Append(in)
+		}
+		mc.Append(ll.Or()); mc.Append(ll.And())
+	})
+	xsig := func(pqSigA, pqSigB []byte, sigs ...[]byte) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.PushLarge(pqSigA)); mc.Append(ll.PushLarge(pqSigB))
+			for _, sig := range sigs {
+				mc.Append(ll.Push(sig))
+			}
 		})
 	}
 
-	build2Sigs := func(sa, sb []byte) []byte {
+	return []M001TV{
+		m001TV("m001_hybrid_valid", xpk, xsig(nil, pqSigB, sig1, sig2), msg),
+		m001TV("m001_hybrid_no_pq", xpk, xsig(nil, nil, sig1, sig2), msg),
+		m001TV("m001_hybrid_wrong_slot", xpk, xsig(pqSigB, nil, sig1, sig2), msg),
+		m001TV("m001_hybrid_one_ecdsa", xpk, xsig(nil, pqSigB, sig1), msg),
+		m001TV("m001_hybrid_wrong_msg", xpk, xsig(nil, pqSigB, sig1, sig2), []byte("firmware v8")),
+	}
+}
+
+func rsaM001Tests() []M001TV {
+	// RSA-3072 HSM AND a P-256 key, the RSA key carried by the xsig
+	msg := []byte("release 2.4.1")
+	_, rsaPK, rsaSig := crypto.HelperVerifyDataRSA(3072, crypto.RSAPSS, msg)
+	_, pk, sig := crypto.HelperVerifyData(msg)
+	_, otherPK, otherSig := crypto.HelperVerifyDataRSA(3072, crypto.RSAPSS, msg)
+
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk)); mc.Append(ll.SignatureVerify())
+		for _, in := range ll.RSASigVerify(0, 1, crypto.RSAPSS, rsaPK) {
+			mc.Append(in)
+		}
+		mc.Append(ll.And())
+	})
+	xsig := func(rsaSig, rsaPK []byte) []byte {
 		return serializeXSig(func(mc *machines.MachineCode) {
-			mc.Append(ll.Push(sa)); mc.Append(ll.Push(sb))
+			mc.Append(ll.Push(sig)); mc.Append(ll.PushLarge(rsaSig)); mc.Append(ll.PushLarge(rsaPK))
 		})
 	}
 
-	xpk := build2of3PK(pk1, pk2, pk3)
+	return []M001TV{
+		m001TV("m001_rsa_valid", xpk, xsig(rsaSig, rsaPK), msg),
+		m001TV("m001_rsa_no_sig", xpk, xsig(nil, rsaPK), msg),
+		m001TV("m001_rsa_other_key", xpk, xsig(otherSig, otherPK), msg),
+		m001TV("m001_rsa_wrong_msg", xpk, xsig(rsaSig, rsaPK), []byte("release 2.4.2")),
+	}
+}
 
-	var tests []M001TV
+func webauthnM001Tests() []M001TV {
+	// 2-of-3 security keys, each signer leaving two objects
+	msg := []byte("approve deploy 8c1f")
+	var pks, sigs, authData, clientData [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, a, c, sig := crypto.HelperVerifyDataWebAuthn("deploy.example.com", msg)
+		pks, sigs, authData, clientData = append(pks, pk), append(sigs, sig), append(authData, a), append(clientData, c)
+	}
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for i := range pks {
+			for _, in := range ll.WebAuthnSigVerify(2*i, 2*i+1, pks[i], "deploy.example.com", crypto.WebAuthnUserVerified) {
+				mc.Append(in)
+			}
+			mc.Append(ll.ToAltStack())
+		}
+		mc.Append(ll.FromAltStack()); mc.Append(ll.FromAltStack()); mc.Append(ll.FromAltStack())
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.Threshold())
+	})
+	// signatures in reverse, as the last check pops first; nil signers send
+	// a dummy signature and empty objects
+	xsig := func(signed ...bool) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for i := 2; i >= 0; i-- {
+				if signed[i] {
+					mc.Append(ll.Push(sigs[i]))
+				} else {
+					mc.Append(ll.Push(sigs[(i+1)%3]))
+				}
+			}
+			for i := range pks {
+				if signed[i] {
+					mc.Append(ll.PushLarge(authData[i])); mc.Append(ll.PushLarge(clientData[i]))
+				} else {
+					mc.Append(ll.PushLarge(nil)); mc.Append(ll.PushLarge(nil))
+				}
+			}
+		})
+	}
 
-	// Valid 2-of-3 combinations
-	tests = append(tests, m001TV("m001_2of3_sig12", xpk, build2Sigs(sig1, sig2), msg))
-	tests = append(tests, m001TV("m001_2of3_sig21", xpk, build2Sigs(sig2, sig1), msg))
-	tests = append(tests, m001TV("m001_2of3_sig13", xpk, build2Sigs(sig1, sig3), msg))
-	tests = append(tests, m001TV("m001_2of3_sig23", xpk, build2Sigs(sig2, sig3), msg))
+	return []M001TV{
+		m001TV("m001_webauthn_2of3", xpk, xsig(true, false, true), msg),
+		m001TV("m001_webauthn_3of3", xpk, xsig(true, true, true), msg),
+		m001TV("m001_webauthn_1of3", xpk, xsig(false, true, false), msg),
+		m001TV("m001_webauthn_wrong_msg", xpk, xsig(true, true, true), []byte("approve deploy 8c20")),
+	}
+}
 
-	// Duplicate sigs (should fail)
-	tests = append(tests, m001TV("m001_2of3_dup_sig11", xpk, build2Sigs(sig1, sig1), msg))
-	tests = append(tests, m001TV("m001_2of3_dup_sig22", xpk, build2Sigs(sig2, sig2), msg))
+func sshsigM001Tests() []M001TV {
+	// 2-of-3 developers signing with the keys in their ssh-agent
+	msg := []byte("merge 5f2e1c0")
+	ns := []byte("xsig")
+	var pks, sigs [][]byte
+	for _, keyType := range []string{crypto.SSHKeyTypeEd25519, crypto.SSHKeyTypeECDSAP256, crypto.SSHKeyTypeEd25519} {
+		_, pk, sig := crypto.HelperVerifyDataSSH(keyType, ns, msg)
+		pks, sigs = append(pks, pk), append(sigs, sig)
+	}
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for i := range pks {
+			for _, in := range ll.SSHSigVerify(i, pks[i], "xsig") {
+				mc.Append(in)
+			}
+		}
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.Threshold())
+	})
+	xsig := func(signed ...bool) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for i := range signed {
+				if signed[i] {
+					mc.Append(ll.PushLarge(sigs[i]))
+				} else {
+					mc.Append(ll.PushLarge(nil))
+				}
+			}
+		})
+	}
 
-	// Wrong message
-	tests = append(tests, m001TV("m001_2of3_wrong_msg", xpk, build2Sigs(sig1, sig2), []byte("bad")))
+	return []M001TV{
+		m001TV("m001_sshsig_2of3", xpk, xsig(true, true, false), msg),
+		m001TV("m001_sshsig_3of3", xpk, xsig(true, true, true), msg),
+		m001TV("m001_sshsig_1of3", xpk, xsig(false, false, true), msg),
+		m001TV("m001_sshsig_wrong_msg", xpk, xsig(true, true, true), []byte("merge 5f2e1c1")),
+	}
+}
 
-	// 1-of-1
-	xpk1of1 := serializeXPubKey(func(mc *machines.MachineCode) {
-		mc.Append(ll.Push(pk1)); mc.Append(ll.Push1(1)); mc.Append(ll.Push1(1))
-		mc.Append(ll.MultisigVerify())
+func ethM001Tests() []M001TV {
+	// 2-of-3 approval board of Ethereum wallets, one signing typed data
+	msg := []byte("pay invoice 2031")
+	domain := crypto.EIP712DomainSeparator("xsig approvals", "1", 1, nil)
+	modes := []byte{crypto.EthPersonalSign, crypto.EthTypedData, crypto.EthPersonalSign}
+	var addresses, sigs [][]byte
+	for _, mode := range modes {
+		_, address, sig := crypto.HelperVerifyDataEthereum(mode, domain, msg)
+		addresses, sigs = append(addresses, address), append(sigs, sig)
+	}
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		for i := range addresses {
+			for _, in := range ll.EthSigVerify(modes[i], domain, addresses[i]) {
+				mc.Append(in)
+			}
+			mc.Append(ll.ToAltStack())
+		}
+		mc.Append(ll.FromAltStack()); mc.Append(ll.FromAltStack()); mc.Append(ll.FromAltStack())
+		mc.Append(ll.Push1(2)); mc.Append(ll.Push1(3)); mc.Append(ll.Threshold())
 	})
-	xsig1of1 := serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push(sig1)) })
-	tests = append(tests, m001TV("m001_1of1_valid", xpk1of1, xsig1of1, msg))
+	// signatures in reverse, as the last check pops first; non-signers send
+	// 65 zero bytes
+	xsig := func(signed ...bool) []byte {
+		return serializeXSig(func(mc *machines.MachineCode) {
+			for i := 2; i >= 0; i-- {
+				if signed[i] {
+					mc.Append(ll.Push(sigs[i]))
+				} else {
+					mc.Append(ll.Push(make([]byte, crypto.EthSignatureSize)))
+				}
+			}
+		})
+	}
 
-	// 3-of-3
-	xpk3of3 := serializeXPubKey(func(mc *machines.MachineCode) {
-		mc.Append(ll.Push(pk1)); mc.Append(ll.Push(pk2)); mc.Append(ll.Push(pk3))
-		mc.Append(ll.Push1(3)); mc.Append(ll.Push1(3)); mc.Append(ll.MultisigVerify())
-	})
-	xsig3of3 := serializeXSig(func(mc *machines.MachineCode) {
-		mc.Append(ll.Push(sig1)); mc.Append(ll.Push(sig2)); mc.Append(ll.Push(sig3))
+	return []M001TV{
+		m001TV("m001_eth_2of3", xpk, xsig(true, true, false), msg),
+		m001TV("m001_eth_3of3", xpk, xsig(true, true, true), msg),
+		m001TV("m001_eth_1of3", xpk, xsig(false, true, false), msg),
+		m001TV("m001_eth_wrong_msg", xpk, xsig(true, true, true), []byte("pay invoice 2032")),
+	}
+}
+
+func nonceM001Tests() []M001TV {
+	// debug unlock token bound to the device's challenge
+	nonce := []byte("0123456789abcdef")
+	msg, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: 1, Value: []byte("unlock debug port")},
+		{Tag: 2, Value: nonce},
 	})
-	tests = append(tests, m001TV("m001_3of3_valid", xpk3of3, xsig3of3, msg))
+	_, pk, sig := crypto.HelperVerifyData(msg)
 
-	// 3-of-3 with only 2 distinct
-	xsig3of3dup := serializeXSig(func(mc *machines.MachineCode) {
-		mc.Append(ll.Push(sig1)); mc.Append(ll.Push(sig2)); mc.Append(ll.Push(sig1))
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk)); mc.Append(ll.SignatureVerify())
+		mc.Append(ll.Push1(2)); mc.Append(ll.CheckNonce()); mc.Append(ll.And())
+	})
+	xsig := serializeXSig(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(sig))
 	})
-	tests = append(tests, m001TV("m001_3of3_only2distinct", xpk3of3, xsig3of3dup, msg))
 
-	// Repeated public keys
-	xpkRepeat := build2of3PK(pk1, pk1, pk3)
-	tests = append(tests, m001TV("m001_2of3_repeated_pk_pass", xpkRepeat, build2Sigs(sig1, sig3), msg))
-	tests = append(tests, m001TV("m001_2of3_repeated_pk_fail", xpkRepeat, build2Sigs(sig2, sig3), msg))
+	return []M001TV{
+		m001TVCtx("m001_nonce_ok", xpk, xsig, &ll.Context{Xmsg: msg, Nonce: nonce}),
+		m001TVCtx("m001_nonce_next_challenge", xpk, xsig, &ll.Context{Xmsg: msg, Nonce: []byte("fedcba9876543210")}),
+		m001TVCtx("m001_nonce_none", xpk, xsig, &ll.Context{Xmsg: msg}),
+	}
+}
 
-	return tests
+func securityVersionM001Tests() []M001TV {
+	const tagVersion = 4
+	image := func(version int64) []byte {
+		msg, _ := ll.EncodeMessage([]ll.MessageField{
+			{Tag: 1, Value: []byte("firmware_image")},
+			{Tag: tagVersion, Value: ll.EncodeNum(version)},
+		})
+		return msg
+	}
+	msg := image(3)
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	// signature valid and signed image version >= device security version
+	xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(pk)); mc.Append(ll.SignatureVerify())
+		mc.Append(ll.Push1(tagVersion)); mc.Append(ll.MsgField()); mc.Append(ll.CheckSecurityVersion())
+		mc.Append(ll.And())
+	})
+	xsig := serializeXSig(func(mc *machines.MachineCode) {
+		mc.Append(ll.Push(sig))
+	})
+	// an xsig claiming a version of its own
+	lie := serializeXSig(func(mc *machines.MachineCode) {
+		mc.Append(ll.PushNum(4)); mc.Append(ll.Push(sig))
+	})
+	// the xsig never sees the device state
+	xsigOnly := serializeXSig(func(mc *machines.MachineCode) {
+		mc.Append(ll.PushNum(0)); mc.Append(ll.CheckSecurityVersion())
+	})
+	emptyXPK := serializeXPubKey(func(mc *machines.MachineCode) {})
+
+	return []M001TV{
+		m001TVCtx("m001_secver_newer", xpk, xsig, &ll.Context{Xmsg: msg, SecurityVersion: 2}),
+		m001TVCtx("m001_secver_equal", xpk, xsig, &ll.Context{Xmsg: msg, SecurityVersion: 3}),
+		m001TVCtx("m001_secver_rollback", xpk, xsig, &ll.Context{Xmsg: msg, SecurityVersion: 4}),
+		m001TVCtx("m001_secver_wrong_msg", xpk, xsig, &ll.Context{Xmsg: []byte("bad"), SecurityVersion: 2}),
+		m001TVCtx("m001_secver_xsig_lies", xpk, lie, &ll.Context{Xmsg: msg, SecurityVersion: 4}),
+		m001TVCtx("m001_secver_msg_lies", xpk, xsig, &ll.Context{Xmsg: image(4), SecurityVersion: 4}),
+		m001TVCtx("m001_secver_xsig_empty_ctx", emptyXPK, xsigOnly, &ll.Context{SecurityVersion: 9}),
+	}
 }
 
 func finalStackM001Tests() []M001TV {
@@ -502,14 +2668,14 @@ func errorM001Tests() []M001TV {
 			func() []byte {
 				mc := machines.MachineCode{}
 				mc.Code = []byte{0xFF}
-				return mc.Serialize(machines.CodeTypeXSig)
+				return mc.SerializeUnchecked(machines.CodeTypeXSig)
 			}(), msg),
 		// Unknown opcode in xpubkey
 		m001TV("m001_xpk_bad_opcode",
 			func() []byte {
 				mc := machines.MachineCode{}
 				mc.Code = []byte{0xFF}
-				return mc.Serialize(machines.CodeTypeXPublicKey)
+				return mc.SerializeUnchecked(machines.CodeTypeXPublicKey)
 			}(),
 			serializeXSig(func(mc *machines.MachineCode) { mc.Append(ll.Push1(1)) }), msg),
 	}
@@ -518,7 +2684,7 @@ func errorM001Tests() []M001TV {
 // ---- random test generators ----
 
 func randomSmartEvalTests(n int, seed int64) []EvalTV {
-	rng := rand.New(rand.NewSource(seed))
+	rng := mrand.New(mrand.NewSource(seed))
 	tests := make([]EvalTV, n)
 	for i := 0; i < n; i++ {
 		a := ll.Assembler{}
@@ -564,8 +2730,75 @@ func randomSmartEvalTests(n int, seed int64) []EvalTV {
 	return tests
 }
 
+func randomConditionalEvalTests(n int, seed int64) []EvalTV {
+	rng := mrand.New(mrand.NewSource(seed))
+	ops := []func() ll.Instruction{ll.If, ll.Else, ll.EndIf, ll.Not, ll.Add, ll.And}
+	tests := make([]EvalTV, n)
+	for i := 0; i < n; i++ {
+		a := ll.Assembler{}
+		nOps := rng.Intn(30) + 1
+		for j := 0; j < nOps; j++ {
+			if rng.Intn(3) == 0 {
+				a.Append(ll.Push1(rng.Intn(3)))
+			} else {
+				a.Append(ops[rng.Intn(len(ops))]())
+			}
+		}
+		tests[i] = evalTV(fmt.Sprintf("rand_cond_%d", i), a.Code, nil)
+	}
+	return tests
+}
+
+func randomThresholdEvalTests(n int, seed int64) []EvalTV {
+	rng := mrand.New(mrand.NewSource(seed))
+	tests := make([]EvalTV, n)
+	for i := 0; i < n; i++ {
+		a := ll.Assembler{}
+		nOps := rng.Intn(20) + 1
+		for j := 0; j < nOps; j++ {
+			switch rng.Intn(5) {
+			case 0, 1:
+				a.Append(ll.Push1(rng.Intn(4)))
+			case 2:
+				a.Append(ll.Threshold())
+			case 3:
+				a.Append(ll.ToAltStack())
+			case 4:
+				a.Append(ll.FromAltStack())
+			}
+		}
+		tests[i] = evalTV(fmt.Sprintf("rand_thresh_%d", i), a.Code, nil)
+	}
+	return tests
+}
+
+func randomNumEvalTests(n int, seed int64) []EvalTV {
+	rng := mrand.New(mrand.NewSource(seed))
+	edges := []int64{0, 1, -1, 127, 128, -128, -129, 255, 256, math.MaxInt64, math.MinInt64, 1 << 31, -(1 << 32)}
+	ops := []func() ll.Instruction{ll.NumAdd, ll.NumSub, ll.NumMul, ll.NumLessThan, ll.NumEqual, ll.ToNum}
+	tests := make([]EvalTV, n)
+	for i := 0; i < n; i++ {
+		a := ll.Assembler{}
+		nOps := rng.Intn(12) + 1
+		for j := 0; j < nOps; j++ {
+			switch rng.Intn(5) {
+			case 0:
+				a.Append(ll.PushNum(edges[rng.Intn(len(edges))]))
+			case 1:
+				a.Append(ll.PushNum(rng.Int63() >> uint(rng.Intn(63))))
+			case 2:
+				a.Append(ll.PushNum(-rng.Int63() >> uint(rng.Intn(63))))
+			default:
+				a.Append(ops[rng.Intn(len(ops))]())
+			}
+		}
+		tests[i] = evalTV(fmt.Sprintf("rand_num_%d", i), a.Code, nil)
+	}
+	return tests
+}
+
 func randomDumbEvalTests(n int, seed int64) []EvalTV {
-	rng := rand.New(rand.NewSource(seed))
+	rng := mrand.New(mrand.NewSource(seed))
 	tests := make([]EvalTV, n)
 	for i := 0; i < n; i++ {
 		a := ll.Assembler{}
@@ -592,7 +2825,7 @@ func randomDumbEvalTests(n int, seed int64) []EvalTV {
 }
 
 func randomRawByteTests(n int, seed int64) []EvalTV {
-	rng := rand.New(rand.NewSource(seed))
+	rng := mrand.New(mrand.NewSource(seed))
 	tests := make([]EvalTV, n)
 	for i := 0; i < n; i++ {
 		sz := rng.Intn(50) + 1
@@ -606,7 +2839,7 @@ func randomRawByteTests(n int, seed int64) []EvalTV {
 }
 
 func randomSingleSigM001Tests(n int, seed int64) []M001TV {
-	rng := rand.New(rand.NewSource(seed))
+	rng := mrand.New(mrand.NewSource(seed))
 	tests := make([]M001TV, n)
 	for i := 0; i < n; i++ {
 		msgLen := rng.Intn(64) + 1
@@ -631,7 +2864,7 @@ func randomSingleSigM001Tests(n int, seed int64) []M001TV {
 }
 
 func randomMultisigM001Tests(n int, seed int64) []M001TV {
-	rng := rand.New(rand.NewSource(seed))
+	rng := mrand.New(mrand.NewSource(seed))
 	tests := make([]M001TV, n)
 	for i := 0; i < n; i++ {
 		msg := make([]byte, rng.Intn(32)+1)
@@ -694,8 +2927,110 @@ func randomMultisigM001Tests(n int, seed int64) []M001TV {
 	return tests
 }
 
+func randomWeightedM001Tests(n int, seed int64) []M001TV {
+	rng := mrand.New(mrand.NewSource(seed))
+	tests := make([]M001TV, n)
+	for i := 0; i < n; i++ {
+		msg := make([]byte, rng.Intn(32)+1)
+		rng.Read(msg)
+
+		nKeys := rng.Intn(4) + 1
+		var pks, sigs [][]byte
+		var weights []int64
+		var total int64
+		for k := 0; k < nKeys; k++ {
+			_, pk, sig := crypto.HelperVerifyData(msg)
+			pks = append(pks, pk)
+			sigs = append(sigs, sig)
+			weights = append(weights, int64(rng.Intn(5)+1))
+			total += weights[k]
+		}
+		target := int64(rng.Intn(int(total))) + 1
+
+		nSigs := rng.Intn(nKeys + 1)
+		xsig := serializeXSig(func(mc *machines.MachineCode) {
+			for s := 0; s < nSigs; s++ {
+				mc.Append(ll.Push(sigs[rng.Intn(nKeys)]))
+			}
+			mc.Append(ll.Push1(nSigs))
+		})
+		xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+			for k := 0; k < nKeys; k++ {
+				mc.Append(ll.Push(pks[k]))
+				mc.Append(ll.PushNum(weights[k]))
+			}
+			mc.Append(ll.PushNum(target))
+			mc.Append(ll.Push1(nKeys))
+			mc.Append(ll.WeightedSigVerify())
+		})
+
+		tests[i] = m001TV(fmt.Sprintf("rand_weighted_%d", i), xpk, xsig, msg)
+	}
+	return tests
+}
+
+func randomMerkleM001Tests(n int, seed int64) []M001TV {
+	rng := mrand.New(mrand.NewSource(seed))
+	tests := make([]M001TV, n)
+	for i := 0; i < n; i++ {
+		msg := make([]byte, rng.Intn(32)+1)
+		rng.Read(msg)
+
+		nKeys := rng.Intn(20) + 1
+		var pks, sigs [][]byte
+		for k := 0; k < nKeys; k++ {
+			_, pk, sig := crypto.HelperVerifyData(msg)
+			pks = append(pks, pk)
+			sigs = append(sigs, sig)
+		}
+		root, _ := crypto.MerkleRoot(pks)
+		signer := rng.Intn(nKeys)
+		claimed := signer
+		if rng.Intn(4) == 0 {
+			claimed = rng.Intn(nKeys)
+		}
+		proof, _ := crypto.MerkleProof(pks, claimed)
+
+		xsig := serializeXSig(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(sigs[signer]))
+			for _, in := range ll.PushMerkleProof(pks[claimed], proof) {
+				mc.Append(in)
+			}
+		})
+		xpk := serializeXPubKey(func(mc *machines.MachineCode) {
+			mc.Append(ll.Push(root))
+			mc.Append(ll.MerkleSigVerify())
+		})
+
+		tests[i] = m001TV(fmt.Sprintf("rand_merkle_%d", i), xpk, xsig, msg)
+	}
+	return tests
+}
+
 // ---- output ----
 
+// emitOptionalBytes emits data only if non-empty; see optionalRef.
+func emitOptionalBytes(f *os.File, name string, data []byte) {
+	if len(data) > 0 {
+		emitBytes(f, name, data)
+	}
+}
+
+// optionalRef names an array emitted by emitOptionalBytes, or NULL.
+func optionalRef(name string, data []byte) string {
+	if len(data) == 0 {
+		return "NULL"
+	}
+	return name
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func emitBytes(f *os.File, name string, data []byte) {
 	if len(data) == 0 {
 		fmt.Fprintf(f, "static const uint8_t %s[] = {0};\n", name)
@@ -725,18 +3060,66 @@ func main() {
 	evalTests = append(evalTests, pushEdgeTests()...)
 	evalTests = append(evalTests, errorTests()...)
 	evalTests = append(evalTests, complexSequenceTests()...)
+	evalTests = append(evalTests, conditionalTests()...)
+	evalTests = append(evalTests, thresholdTests()...)
+	evalTests = append(evalTests, numTests()...)
 	evalTests = append(evalTests, sigverifyEvalTests()...)
+	evalTests = append(evalTests, securityVersionTests()...)
+	evalTests = append(evalTests, msgFieldTests()...)
+	evalTests = append(evalTests, checkSigFromStackTests()...)
+	evalTests = append(evalTests, catSplitSizeTests()...)
+	evalTests = append(evalTests, revocationEvalTests()...)
+	evalTests = append(evalTests, nonceTests()...)
+	evalTests = append(evalTests, pkHashTests()...)
+	evalTests = append(evalTests, strictMultisigTests()...)
+	evalTests = append(evalTests, rawSigTests()...)
+	evalTests = append(evalTests, secp256k1Tests()...)
+	evalTests = append(evalTests, ed25519Tests()...)
+	evalTests = append(evalTests, sigVerifyHashTests()...)
+	evalTests = append(evalTests, slhdsaTests()...)
+	evalTests = append(evalTests, rsaTests()...)
+	evalTests = append(evalTests, webauthnTests()...)
+	evalTests = append(evalTests, sshsigTests()...)
+	evalTests = append(evalTests, ethTests()...)
+	evalTests = append(evalTests, weightedEvalTests()...)
+	evalTests = append(evalTests, merkleEvalTests()...)
+	evalTests = append(evalTests, prehashedEvalTests(evalTests)...)
 	evalTests = append(evalTests, randomSmartEvalTests(500, 42)...)
 	evalTests = append(evalTests, randomDumbEvalTests(200, 123)...)
+	evalTests = append(evalTests, randomConditionalEvalTests(200, 321)...)
+	evalTests = append(evalTests, randomThresholdEvalTests(200, 654)...)
+	evalTests = append(evalTests, randomNumEvalTests(300, 987)...)
 	evalTests = append(evalTests, randomRawByteTests(200, 456)...)
 
 	m001Tests = append(m001Tests, singleSigM001Tests()...)
 	m001Tests = append(m001Tests, multisigM001Tests()...)
+	m001Tests = append(m001Tests, branchM001Tests()...)
+	m001Tests = append(m001Tests, thresholdM001Tests()...)
+	m001Tests = append(m001Tests, securityVersionM001Tests()...)
+	m001Tests = append(m001Tests, msgFieldM001Tests()...)
+	m001Tests = append(m001Tests, oracleM001Tests()...)
+	m001Tests = append(m001Tests, revocationM001Tests()...)
+	m001Tests = append(m001Tests, nonceM001Tests()...)
+	m001Tests = append(m001Tests, pkHashM001Tests()...)
+	m001Tests = append(m001Tests, rawSigM001Tests()...)
+	m001Tests = append(m001Tests, mixedCurveM001Tests()...)
+	m001Tests = append(m001Tests, frostM001Tests()...)
+	m001Tests = append(m001Tests, sigVerifyHashM001Tests()...)
+	m001Tests = append(m001Tests, slhdsaM001Tests()...)
+	m001Tests = append(m001Tests, rsaM001Tests()...)
+	m001Tests = append(m001Tests, webauthnM001Tests()...)
+	m001Tests = append(m001Tests, sshsigM001Tests()...)
+	m001Tests = append(m001Tests, ethM001Tests()...)
+	m001Tests = append(m001Tests, weightedM001Tests()...)
+	m001Tests = append(m001Tests, merkleM001Tests()...)
 	m001Tests = append(m001Tests, finalStackM001Tests()...)
 	m001Tests = append(m001Tests, phaseTransferM001Tests()...)
 	m001Tests = append(m001Tests, errorM001Tests()...)
+	m001Tests = append(m001Tests, prehashedM001Tests(m001Tests)...)
 	m001Tests = append(m001Tests, randomSingleSigM001Tests(50, 789)...)
 	m001Tests = append(m001Tests, randomMultisigM001Tests(50, 101)...)
+	m001Tests = append(m001Tests, randomWeightedM001Tests(50, 202)...)
+	m001Tests = append(m001Tests, randomMerkleM001Tests(50, 303)...)
 
 	f, err := os.Create("c/test_vectors.h")
 	if err != nil {
@@ -756,6 +3139,11 @@ func main() {
 	fmt.Fprintln(f, "    const char *name;")
 	fmt.Fprintln(f, "    const uint8_t *code; size_t code_len;")
 	fmt.Fprintln(f, "    const uint8_t *msg; size_t msg_len;")
+	fmt.Fprintln(f, "    int64_t security_version;")
+	fmt.Fprintln(f, "    const uint8_t *revoked; size_t revoked_len;")
+	fmt.Fprintln(f, "    const uint8_t *nonce; size_t nonce_len;")
+	fmt.Fprintln(f, "    int prehashed;")
+	fmt.Fprintln(f, "    const uint8_t *digests; size_t digests_len;")
 	fmt.Fprintln(f, "    int expect_error;")
 	fmt.Fprintln(f, "    const uint8_t *expect_stack; size_t expect_stack_len;")
 	fmt.Fprintln(f, "} eval_tv_t;")
@@ -765,6 +3153,11 @@ func main() {
 	fmt.Fprintln(f, "    const uint8_t *xpubkey; size_t xpubkey_len;")
 	fmt.Fprintln(f, "    const uint8_t *xsig; size_t xsig_len;")
 	fmt.Fprintln(f, "    const uint8_t *msg; size_t msg_len;")
+	fmt.Fprintln(f, "    int64_t security_version;")
+	fmt.Fprintln(f, "    const uint8_t *revoked; size_t revoked_len;")
+	fmt.Fprintln(f, "    const uint8_t *nonce; size_t nonce_len;")
+	fmt.Fprintln(f, "    int prehashed;")
+	fmt.Fprintln(f, "    const uint8_t *digests; size_t digests_len;")
 	fmt.Fprintln(f, "    int expected;")
 	fmt.Fprintln(f, "} m001_tv_t;")
 	fmt.Fprintln(f, "")
@@ -774,6 +3167,9 @@ func main() {
 	for i, tv := range evalTests {
 		emitBytes(f, fmt.Sprintf("et_%d_code", i), tv.Code)
 		emitBytes(f, fmt.Sprintf("et_%d_msg", i), tv.Msg)
+		emitOptionalBytes(f, fmt.Sprintf("et_%d_revoked", i), tv.Revoked)
+		emitOptionalBytes(f, fmt.Sprintf("et_%d_nonce", i), tv.Nonce)
+		emitOptionalBytes(f, fmt.Sprintf("et_%d_digests", i), tv.Digests)
 		if !tv.ExpectError {
 			emitBytes(f, fmt.Sprintf("et_%d_stack", i), tv.ExpectStack)
 		}
@@ -793,8 +3189,12 @@ func main() {
 			stackRef = "NULL"
 			stackLen = 0
 		}
-		fmt.Fprintf(f, "    {\"%s\", et_%d_code, %d, et_%d_msg, %d, %d, %s, %d},\n",
-			tv.Name, i, len(tv.Code), i, len(tv.Msg), expectErr, stackRef, stackLen)
+		fmt.Fprintf(f, "    {\"%s\", et_%d_code, %d, et_%d_msg, %d, %dLL, %s, %d, %s, %d, %d, %s, %d, %d, %s, %d},\n",
+			tv.Name, i, len(tv.Code), i, len(tv.Msg), tv.SecurityVersion,
+			optionalRef(fmt.Sprintf("et_%d_revoked", i), tv.Revoked), len(tv.Revoked),
+			optionalRef(fmt.Sprintf("et_%d_nonce", i), tv.Nonce), len(tv.Nonce),
+			boolInt(tv.Prehashed), optionalRef(fmt.Sprintf("et_%d_digests", i), tv.Digests), len(tv.Digests),
+			expectErr, stackRef, stackLen)
 	}
 	fmt.Fprintln(f, "};")
 	fmt.Fprintf(f, "#define NUM_EVAL_TESTS %d\n\n", len(evalTests))
@@ -805,14 +3205,21 @@ func main() {
 		emitBytes(f, fmt.Sprintf("mt_%d_xpk", i), tv.XPubKey)
 		emitBytes(f, fmt.Sprintf("mt_%d_xsig", i), tv.XSig)
 		emitBytes(f, fmt.Sprintf("mt_%d_msg", i), tv.Msg)
+		emitOptionalBytes(f, fmt.Sprintf("mt_%d_revoked", i), tv.Revoked)
+		emitOptionalBytes(f, fmt.Sprintf("mt_%d_nonce", i), tv.Nonce)
+		emitOptionalBytes(f, fmt.Sprintf("mt_%d_digests", i), tv.Digests)
 		fmt.Fprintln(f)
 	}
 
 	// M001 test table
 	fmt.Fprintln(f, "static const m001_tv_t m001_tests[] = {")
 	for i, tv := range m001Tests {
-		fmt.Fprintf(f, "    {\"%s\", mt_%d_xpk, %d, mt_%d_xsig, %d, mt_%d_msg, %d, %d},\n",
-			tv.Name, i, len(tv.XPubKey), i, len(tv.XSig), i, len(tv.Msg), tv.Expected)
+		fmt.Fprintf(f, "    {\"%s\", mt_%d_xpk, %d, mt_%d_xsig, %d, mt_%d_msg, %d, %dLL, %s, %d, %s, %d, %d, %s, %d, %d},\n",
+			tv.Name, i, len(tv.XPubKey), i, len(tv.XSig), i, len(tv.Msg), tv.SecurityVersion,
+			optionalRef(fmt.Sprintf("mt_%d_revoked", i), tv.Revoked), len(tv.Revoked),
+			optionalRef(fmt.Sprintf("mt_%d_nonce", i), tv.Nonce), len(tv.Nonce),
+			boolInt(tv.Prehashed), optionalRef(fmt.Sprintf("mt_%d_digests", i), tv.Digests), len(tv.Digests),
+			tv.Expected)
 	}
 	fmt.Fprintln(f, "};")
 	fmt.Fprintf(f, "#define NUM_M001_TESTS %d\n", len(m001Tests))
@@ -0,0 +1,36 @@
+#include "hash.h"
+#include "keccak.h"
+#include "sha256.h"
+#include "sha512.h"
+
+size_t hash_size(uint8_t alg) {
+    switch (alg) {
+    case HASH_SHA256:
+        return SHA256_DIGEST_LEN;
+    case HASH_SHA384:
+        return SHA384_DIGEST_LEN;
+    case HASH_SHA512:
+        return SHA512_DIGEST_LEN;
+    case HASH_SHA3_256:
+        return SHA3_256_DIGEST_LEN;
+    }
+    return 0;
+}
+
+int hash_digest(uint8_t alg, const uint8_t *data, size_t len, uint8_t *out) {
+    switch (alg) {
+    case HASH_SHA256:
+        sha256(data, len, out);
+        return 0;
+    case HASH_SHA384:
+        sha384(data, len, out);
+        return 0;
+    case HASH_SHA512:
+        sha512(data, len, out);
+        return 0;
+    case HASH_SHA3_256:
+        sha3_256(data, len, out);
+        return 0;
+    }
+    return -1;
+}
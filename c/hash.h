@@ -0,0 +1,20 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+// Message digests of OP_SIGVERIFYHASH, matching internal/crypto/hash.go.
+#define HASH_SHA256   1
+#define HASH_SHA384   2
+#define HASH_SHA512   3
+#define HASH_SHA3_256 4
+#define HASH_MAX_ALG  HASH_SHA3_256
+
+#define HASH_MAX_DIGEST_LEN 64
+
+// Digest size of alg, 0 if alg is unknown.
+size_t hash_size(uint8_t alg);
+
+// Writes the alg digest of data, hash_size(alg) bytes, to out. Returns 0 on
+// success, -1 if alg is unknown.
+int hash_digest(uint8_t alg, const uint8_t *data, size_t len, uint8_t *out);
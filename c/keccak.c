@@ -0,0 +1,106 @@
+#include "keccak.h"
+#include <string.h>
+
+static const uint64_t RC[24] = {
+    0x0000000000000001ULL, 0x0000000000008082ULL, 0x800000000000808AULL, 0x8000000080008000ULL,
+    0x000000000000808BULL, 0x0000000080000001ULL, 0x8000000080008081ULL, 0x8000000000008009ULL,
+    0x000000000000008AULL, 0x0000000000000088ULL, 0x0000000080008009ULL, 0x000000008000000AULL,
+    0x000000008000808BULL, 0x800000000000008BULL, 0x8000000000008089ULL, 0x8000000000008003ULL,
+    0x8000000000008002ULL, 0x8000000000000080ULL, 0x000000000000800AULL, 0x800000008000000AULL,
+    0x8000000080008081ULL, 0x8000000000008080ULL, 0x0000000080000001ULL, 0x8000000080008008ULL,
+};
+
+// rotation offsets and destination lanes of the combined rho and pi steps
+static const unsigned RHO[24] = {1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14,
+                                 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44};
+static const int PI[24] = {10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4,
+                           15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1};
+
+#define ROTL64(x, n) (((x) << (n)) | ((x) >> (64 - (n))))
+
+static void keccak_f1600(uint64_t a[25]) {
+    uint64_t c[5], t;
+    for (int round = 0; round < 24; round++) {
+        // theta
+        for (int x = 0; x < 5; x++) {
+            c[x] = a[x] ^ a[x + 5] ^ a[x + 10] ^ a[x + 15] ^ a[x + 20];
+        }
+        for (int x = 0; x < 5; x++) {
+            uint64_t d = c[(x + 4) % 5] ^ ROTL64(c[(x + 1) % 5], 1);
+            for (int y = 0; y < 25; y += 5) a[y + x] ^= d;
+        }
+        // rho and pi
+        t = a[1];
+        for (int i = 0; i < 24; i++) {
+            int j = PI[i];
+            uint64_t next = a[j];
+            a[j] = ROTL64(t, RHO[i]);
+            t = next;
+        }
+        // chi
+        for (int y = 0; y < 25; y += 5) {
+            for (int x = 0; x < 5; x++) c[x] = a[y + x];
+            for (int x = 0; x < 5; x++) {
+                a[y + x] = c[x] ^ (~c[(x + 1) % 5] & c[(x + 2) % 5]);
+            }
+        }
+        // iota
+        a[0] ^= RC[round];
+    }
+}
+
+static void keccak_absorb(keccak256_ctx_t *ctx) {
+    for (int i = 0; i < KECCAK256_RATE / 8; i++) {
+        uint64_t lane = 0;
+        for (int j = 7; j >= 0; j--) lane = (lane << 8) | ctx->buf[8 * i + j];
+        ctx->a[i] ^= lane;
+    }
+    keccak_f1600(ctx->a);
+    ctx->buf_len = 0;
+}
+
+void keccak256_init(keccak256_ctx_t *ctx) {
+    memset(ctx, 0, sizeof(*ctx));
+    ctx->pad = 0x01;
+}
+
+void sha3_256_init(keccak256_ctx_t *ctx) {
+    memset(ctx, 0, sizeof(*ctx));
+    ctx->pad = 0x06;
+}
+
+void keccak256_update(keccak256_ctx_t *ctx, const uint8_t *data, size_t len) {
+    while (len > 0) {
+        size_t n = KECCAK256_RATE - ctx->buf_len;
+        if (n > len) n = len;
+        memcpy(ctx->buf + ctx->buf_len, data, n);
+        ctx->buf_len += n;
+        data += n;
+        len -= n;
+        if (ctx->buf_len == KECCAK256_RATE) keccak_absorb(ctx);
+    }
+}
+
+void keccak256_final(keccak256_ctx_t *ctx, uint8_t out[KECCAK256_DIGEST_LEN]) {
+    memset(ctx->buf + ctx->buf_len, 0, KECCAK256_RATE - ctx->buf_len);
+    ctx->buf[ctx->buf_len] ^= ctx->pad;
+    ctx->buf[KECCAK256_RATE - 1] ^= 0x80;
+    keccak_absorb(ctx);
+    for (int i = 0; i < KECCAK256_DIGEST_LEN; i++) {
+        out[i] = (uint8_t)(ctx->a[i / 8] >> (8 * (i % 8)));
+    }
+}
+
+void keccak256(const uint8_t *data, size_t len, uint8_t out[KECCAK256_DIGEST_LEN]) {
+    keccak256_ctx_t ctx;
+    keccak256_init(&ctx);
+    keccak256_update(&ctx, data, len);
+    keccak256_final(&ctx, out);
+}
+
+void sha3_256(const uint8_t *data, size_t len, uint8_t out[SHA3_256_DIGEST_LEN]) {
+    keccak256_ctx_t ctx;
+    sha3_256_init(&ctx);
+    keccak256_update(&ctx, data, len);
+    keccak256_final(&ctx, out);
+}
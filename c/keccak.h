@@ -0,0 +1,31 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+#define KECCAK256_DIGEST_LEN 32
+#define KECCAK256_RATE       136
+#define SHA3_256_DIGEST_LEN  32
+
+typedef struct {
+    uint64_t a[25];
+    uint8_t buf[KECCAK256_RATE];
+    size_t buf_len;
+    uint8_t pad; // domain padding: 0x01 for Keccak-256, 0x06 for SHA3-256
+} keccak256_ctx_t;
+
+// Incremental Keccak-256 as used by Ethereum: the original Keccak padding
+// (0x01), not the SHA3-256 one (0x06).
+void keccak256_init(keccak256_ctx_t *ctx);
+void keccak256_update(keccak256_ctx_t *ctx, const uint8_t *data, size_t len);
+void keccak256_final(keccak256_ctx_t *ctx, uint8_t out[KECCAK256_DIGEST_LEN]);
+
+// One-shot Keccak-256.
+void keccak256(const uint8_t *data, size_t len, uint8_t out[KECCAK256_DIGEST_LEN]);
+
+// SHA3-256 (FIPS 202): the same sponge with the standard padding. Updated
+// and finished with keccak256_update and keccak256_final.
+void sha3_256_init(keccak256_ctx_t *ctx);
+
+// One-shot SHA3-256.
+void sha3_256(const uint8_t *data, size_t len, uint8_t out[SHA3_256_DIGEST_LEN]);
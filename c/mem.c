@@ -0,0 +1,10 @@
+#include "mem.h"
+#include <stdint.h>
+
+int mem_cmp(const void *a, const void *b, size_t len) {
+    const uint8_t *x = a, *y = b;
+    for (size_t i = 0; i < len; i++) {
+        if (x[i] != y[i]) return x[i] < y[i] ? -1 : 1;
+    }
+    return 0;
+}
@@ -0,0 +1,7 @@
+#pragma once
+
+#include <stddef.h>
+
+// memcmp, so that the verifier needs nothing from libc but memcpy and
+// memset and builds freestanding (see "Embedded profile" in the README).
+int mem_cmp(const void *a, const void *b, size_t len);
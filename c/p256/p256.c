@@ -19657,14 +19657,18 @@ br_i31_muladd_small(uint32_t *x, uint32_t z, const uint32_t *m)
 	hi = x[mlen];
 	if (mblr == 0) {
 		a0 = x[mlen];
-		memmove(x + 2, x + 1, (mlen - 1) * sizeof *x);
+		for (u = mlen; u > 1; u --) {  /* memmove, without libc */
+			x[u] = x[u - 1];
+		}
 		x[1] = z;
 		a1 = x[mlen];
 		b0 = m[mlen];
 	} else {
 		a0 = ((x[mlen] << (31 - mblr)) | (x[mlen - 1] >> mblr))
 			& 0x7FFFFFFF;
-		memmove(x + 2, x + 1, (mlen - 1) * sizeof *x);
+		for (u = mlen; u > 1; u --) {  /* memmove, without libc */
+			x[u] = x[u - 1];
+		}
 		x[1] = z;
 		a1 = ((x[mlen] << (31 - mblr)) | (x[mlen - 1] >> mblr))
 			& 0x7FFFFFFF;
@@ -20306,15 +20310,21 @@ const br_ec_curve_def br_secp256r1 = {
 p256_ret_t p256_verify(uint8_t *msg, size_t msg_len, uint8_t *sig, const uint8_t *pk)
 {
     unsigned char hash[64];
-    size_t hash_len = 32; // SHA-256
     br_hash_compat_context hc;
     const br_hash_class *hf = &br_sha256_vtable;
-    br_ecdsa_vrfy vrfy = &br_ecdsa_i31_vrfy_raw;
 
     hf->init(&hc.vtable);
     hf->update(&hc.vtable, msg, msg_len);
     hf->out(&hc.vtable, hash);
- 
+
+    return p256_verify_digest(hash, sig, pk);
+}
+
+p256_ret_t p256_verify_digest(const uint8_t *hash, uint8_t *sig, const uint8_t *pk)
+{
+    size_t hash_len = 32; // SHA-256
+    br_ecdsa_vrfy vrfy = &br_ecdsa_i31_vrfy_raw;
+
     size_t sig_len = 64; // P-256
 
     // TODO: p256_verify should take a pk_len argument so that we can verify
@@ -14,6 +14,8 @@ extern "C" {
 #include <stddef.h>
 
 p256_ret_t p256_verify(uint8_t *msg, size_t msg_len, uint8_t *sig, const uint8_t *pk);
+// p256_verify over a message already hashed with SHA-256.
+p256_ret_t p256_verify_digest(const uint8_t *hash, uint8_t *sig, const uint8_t *pk);
 
 #ifdef __cplusplus
 }
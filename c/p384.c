@@ -0,0 +1,344 @@
+#include "p384.h"
+#include <string.h>
+
+// The same arithmetic as secp256k1.c, with 384-bit integers.
+
+// 384-bit integers as 12 little-endian 32-bit limbs.
+typedef struct {
+    uint32_t v[12];
+} u384_t;
+
+// A modulus m = 2^384 - c, with c small enough that folding the high half
+// of a product (hi * 2^384 = hi * c mod m) converges in a few steps.
+typedef struct {
+    u384_t m;
+    u384_t c;
+} modulus_t;
+
+static const modulus_t FP = {
+    {{0xFFFFFFFF, 0x00000000, 0x00000000, 0xFFFFFFFF, 0xFFFFFFFE, 0xFFFFFFFF,
+      0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}},
+    {{0x00000001, 0xFFFFFFFF, 0xFFFFFFFF, 0x00000000, 0x00000001, 0x00000000,
+      0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000}},
+};
+
+static const modulus_t FN = {
+    {{0xCCC52973, 0xECEC196A, 0x48B0A77A, 0x581A0DB2, 0xF4372DDF, 0xC7634D81,
+      0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}},
+    {{0x333AD68D, 0x1313E695, 0xB74F5885, 0xA7E5F24D, 0x0BC8D220, 0x389CB27E,
+      0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000}},
+};
+
+static const u384_t B =
+    {{0xD3EC2AEF, 0x2A85C8ED, 0x8A2ED19D, 0xC656398D, 0x5013875A, 0x0314088F,
+      0xFE814112, 0x181D9C6E, 0xE3F82D19, 0x988E056B, 0xE23EE7E4, 0xB3312FA7}};
+
+// (p + 1) / 4: square roots mod p are a^((p+1)/4) since p = 3 mod 4
+static const u384_t SQRT_EXP =
+    {{0x40000000, 0x00000000, 0xC0000000, 0xBFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF,
+      0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0x3FFFFFFF}};
+
+static const u384_t ONE = {{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}};
+
+// Jacobian coordinates (x/z^2, y/z^3); z = 0 is the point at infinity.
+typedef struct {
+    u384_t x, y, z;
+} point_t;
+
+static const point_t G = {
+    {{0x72760AB7, 0x3A545E38, 0xBF55296C, 0x5502F25D, 0x82542A38, 0x59F741E0,
+      0x8BA79B98, 0x6E1D3B62, 0xF320AD74, 0x8EB1C71E, 0xBE8B0537, 0xAA87CA22}},
+    {{0x90EA0E5F, 0x7A431D7C, 0x1D7E819D, 0x0A60B1CE, 0xB5F0B8C0, 0xE9DA3113,
+      0x289A147C, 0xF8F41DBD, 0x9292DC29, 0x5D9E98BF, 0x96262C6F, 0x3617DE4A}},
+    {{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+};
+
+static void u384_from_be(u384_t *r, const uint8_t in[48]) {
+    for (int i = 0; i < 12; i++) {
+        const uint8_t *p = in + 44 - 4 * i;
+        r->v[i] = ((uint32_t)p[0] << 24) | ((uint32_t)p[1] << 16) |
+                  ((uint32_t)p[2] << 8) | (uint32_t)p[3];
+    }
+}
+
+static int u384_is_zero(const u384_t *a) {
+    uint32_t acc = 0;
+    for (int i = 0; i < 12; i++) acc |= a->v[i];
+    return acc == 0;
+}
+
+static int u384_cmp(const u384_t *a, const u384_t *b) {
+    for (int i = 11; i >= 0; i--) {
+        if (a->v[i] != b->v[i]) return a->v[i] < b->v[i] ? -1 : 1;
+    }
+    return 0;
+}
+
+static uint32_t u384_add(u384_t *r, const u384_t *a, const u384_t *b) {
+    uint64_t carry = 0;
+    for (int i = 0; i < 12; i++) {
+        uint64_t cur = (uint64_t)a->v[i] + b->v[i] + carry;
+        r->v[i] = (uint32_t)cur;
+        carry = cur >> 32;
+    }
+    return (uint32_t)carry;
+}
+
+static uint32_t u384_sub(u384_t *r, const u384_t *a, const u384_t *b) {
+    uint64_t borrow = 0;
+    for (int i = 0; i < 12; i++) {
+        uint64_t cur = (uint64_t)a->v[i] - b->v[i] - borrow;
+        r->v[i] = (uint32_t)cur;
+        borrow = (cur >> 32) & 1;
+    }
+    return (uint32_t)borrow;
+}
+
+// a, b < m
+static void mod_add(u384_t *r, const u384_t *a, const u384_t *b, const modulus_t *m) {
+    if (u384_add(r, a, b) || u384_cmp(r, &m->m) >= 0) {
+        u384_sub(r, r, &m->m);
+    }
+}
+
+// a, b < m
+static void mod_sub(u384_t *r, const u384_t *a, const u384_t *b, const modulus_t *m) {
+    if (u384_sub(r, a, b)) {
+        u384_add(r, r, &m->m);
+    }
+}
+
+// Any a < 2^384.
+static void mod_reduce(u384_t *r, const u384_t *a, const modulus_t *m) {
+    *r = *a;
+    while (u384_cmp(r, &m->m) >= 0) {
+        u384_sub(r, r, &m->m);
+    }
+}
+
+// Any a, b < 2^384.
+static void mod_mul(u384_t *r, const u384_t *a, const u384_t *b, const modulus_t *m) {
+    uint32_t w[25] = {0};
+    for (int i = 0; i < 12; i++) {
+        uint64_t carry = 0;
+        for (int j = 0; j < 12; j++) {
+            uint64_t cur = (uint64_t)a->v[i] * b->v[j] + w[i + j] + carry;
+            w[i + j] = (uint32_t)cur;
+            carry = cur >> 32;
+        }
+        w[i + 12] = (uint32_t)carry;
+    }
+
+    // fold: lo + hi * 2^384 = lo + hi * c (mod m), until hi is zero
+    for (;;) {
+        uint32_t hi = 0;
+        for (int i = 12; i < 25; i++) hi |= w[i];
+        if (hi == 0) break;
+
+        uint32_t n[25] = {0};
+        memcpy(n, w, 12 * sizeof(uint32_t));
+        for (int i = 0; i < 13; i++) {
+            uint64_t carry = 0;
+            int k = i;
+            for (int j = 0; j < 12; j++, k++) {
+                uint64_t cur = (uint64_t)w[12 + i] * m->c.v[j] + n[k] + carry;
+                n[k] = (uint32_t)cur;
+                carry = cur >> 32;
+            }
+            for (; carry && k < 25; k++) {
+                uint64_t cur = (uint64_t)n[k] + carry;
+                n[k] = (uint32_t)cur;
+                carry = cur >> 32;
+            }
+        }
+        memcpy(w, n, sizeof(n));
+    }
+
+    u384_t lo;
+    memcpy(lo.v, w, sizeof(lo.v));
+    mod_reduce(r, &lo, m);
+}
+
+static void mod_pow(u384_t *r, const u384_t *a, const u384_t *e, const modulus_t *m) {
+    u384_t acc = ONE;
+    for (int i = 383; i >= 0; i--) {
+        mod_mul(&acc, &acc, &acc, m);
+        if ((e->v[i / 32] >> (i % 32)) & 1) {
+            mod_mul(&acc, &acc, a, m);
+        }
+    }
+    *r = acc;
+}
+
+// a != 0 (mod m); m is prime
+static void mod_inv(u384_t *r, const u384_t *a, const modulus_t *m) {
+    u384_t e, two = {{2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}};
+    u384_sub(&e, &m->m, &two);
+    mod_pow(r, a, &e, m);
+}
+
+static void fmul(u384_t *r, const u384_t *a, const u384_t *b) { mod_mul(r, a, b, &FP); }
+static void fadd(u384_t *r, const u384_t *a, const u384_t *b) { mod_add(r, a, b, &FP); }
+static void fsub(u384_t *r, const u384_t *a, const u384_t *b) { mod_sub(r, a, b, &FP); }
+
+static void point_double(point_t *r, const point_t *p) {
+    if (u384_is_zero(&p->z) || u384_is_zero(&p->y)) {
+        memset(r, 0, sizeof(*r));
+        return;
+    }
+    // a = -3: S = 4xy^2, M = 3(x - z^2)(x + z^2), x' = M^2 - 2S,
+    // y' = M(S - x') - 8y^4, z' = 2yz
+    u384_t yy, zz, s, m, t, x3, y3, z3;
+    fmul(&yy, &p->y, &p->y);
+    fmul(&s, &p->x, &yy);
+    fadd(&s, &s, &s);
+    fadd(&s, &s, &s);
+    fmul(&zz, &p->z, &p->z);
+    fsub(&t, &p->x, &zz);
+    fadd(&m, &p->x, &zz);
+    fmul(&t, &t, &m);
+    fadd(&m, &t, &t);
+    fadd(&m, &m, &t);
+    fmul(&x3, &m, &m);
+    fsub(&x3, &x3, &s);
+    fsub(&x3, &x3, &s);
+    fmul(&t, &yy, &yy);
+    fadd(&t, &t, &t);
+    fadd(&t, &t, &t);
+    fadd(&t, &t, &t);
+    fsub(&y3, &s, &x3);
+    fmul(&y3, &m, &y3);
+    fsub(&y3, &y3, &t);
+    fmul(&z3, &p->y, &p->z);
+    fadd(&z3, &z3, &z3);
+    r->x = x3;
+    r->y = y3;
+    r->z = z3;
+}
+
+static void point_add(point_t *r, const point_t *p, const point_t *q) {
+    if (u384_is_zero(&p->z)) {
+        *r = *q;
+        return;
+    }
+    if (u384_is_zero(&q->z)) {
+        *r = *p;
+        return;
+    }
+    u384_t pz2, qz2, u1, u2, s1, s2, h, rr, t;
+    fmul(&pz2, &p->z, &p->z);
+    fmul(&qz2, &q->z, &q->z);
+    fmul(&u1, &p->x, &qz2);
+    fmul(&u2, &q->x, &pz2);
+    fmul(&t, &q->z, &qz2);
+    fmul(&s1, &p->y, &t);
+    fmul(&t, &p->z, &pz2);
+    fmul(&s2, &q->y, &t);
+    fsub(&h, &u2, &u1);
+    fsub(&rr, &s2, &s1);
+    if (u384_is_zero(&h)) {
+        if (u384_is_zero(&rr)) {
+            point_double(r, p);
+        } else {
+            memset(r, 0, sizeof(*r));
+        }
+        return;
+    }
+    // x' = r^2 - h^3 - 2u1h^2, y' = r(u1h^2 - x') - s1h^3, z' = h z1 z2
+    u384_t hh, hhh, v, x3, y3, z3;
+    fmul(&hh, &h, &h);
+    fmul(&hhh, &h, &hh);
+    fmul(&v, &u1, &hh);
+    fmul(&x3, &rr, &rr);
+    fsub(&x3, &x3, &hhh);
+    fsub(&x3, &x3, &v);
+    fsub(&x3, &x3, &v);
+    fsub(&y3, &v, &x3);
+    fmul(&y3, &rr, &y3);
+    fmul(&t, &s1, &hhh);
+    fsub(&y3, &y3, &t);
+    fmul(&z3, &p->z, &q->z);
+    fmul(&z3, &h, &z3);
+    r->x = x3;
+    r->y = y3;
+    r->z = z3;
+}
+
+static void point_mul(point_t *r, const point_t *p, const u384_t *k) {
+    point_t acc;
+    memset(&acc, 0, sizeof(acc));
+    for (int i = 383; i >= 0; i--) {
+        point_double(&acc, &acc);
+        if ((k->v[i / 32] >> (i % 32)) & 1) {
+            point_add(&acc, &acc, p);
+        }
+    }
+    *r = acc;
+}
+
+// The x coordinate of p, which must not be the point at infinity.
+static void point_affine_x(u384_t *x, const point_t *p) {
+    u384_t z_inv, z_inv2;
+    mod_inv(&z_inv, &p->z, &FP);
+    fmul(&z_inv2, &z_inv, &z_inv);
+    fmul(x, &p->x, &z_inv2);
+}
+
+// The point with x coordinate x and a y of the given parity, if any.
+static int lift_x(point_t *r, const u384_t *x, int odd) {
+    if (u384_cmp(x, &FP.m) >= 0) return -1;
+    // x^3 - 3x + b
+    u384_t c, y, t;
+    fmul(&c, x, x);
+    fmul(&c, &c, x);
+    fsub(&c, &c, x);
+    fsub(&c, &c, x);
+    fsub(&c, &c, x);
+    fadd(&c, &c, &B);
+    mod_pow(&y, &c, &SQRT_EXP, &FP);
+    fmul(&t, &y, &y);
+    if (u384_cmp(&t, &c) != 0) return -1;
+    if ((int)(y.v[0] & 1) != odd) {
+        u384_sub(&y, &FP.m, &y);
+    }
+    r->x = *x;
+    r->y = y;
+    r->z = ONE;
+    return 0;
+}
+
+int p384_ecdsa_verify_digest(const uint8_t *digest, size_t digest_len,
+                             const uint8_t raw_sig[P384_RAW_SIG_LEN],
+                             const uint8_t pk[P384_PK_LEN]) {
+    if (pk[0] != 0x02 && pk[0] != 0x03) return 0;
+    u384_t x;
+    point_t q;
+    u384_from_be(&x, pk + 1);
+    if (lift_x(&q, &x, pk[0] == 0x03) != 0) return 0;
+
+    u384_t r, s;
+    u384_from_be(&r, raw_sig);
+    u384_from_be(&s, raw_sig + 48);
+    if (u384_is_zero(&r) || u384_is_zero(&s)) return 0;
+    if (u384_cmp(&r, &FN.m) >= 0 || u384_cmp(&s, &FN.m) >= 0) return 0;
+
+    // the leftmost 48 bytes of the digest, as an integer
+    uint8_t z_bytes[48] = {0};
+    if (digest_len > 48) digest_len = 48;
+    memcpy(z_bytes + 48 - digest_len, digest, digest_len);
+    u384_t z, w, u1, u2;
+    u384_from_be(&z, z_bytes);
+    mod_inv(&w, &s, &FN);
+    mod_mul(&u1, &z, &w, &FN);
+    mod_mul(&u2, &r, &w, &FN);
+
+    point_t a, b, R;
+    point_mul(&a, &G, &u1);
+    point_mul(&b, &q, &u2);
+    point_add(&R, &a, &b);
+    if (u384_is_zero(&R.z)) return 0;
+    u384_t rx;
+    point_affine_x(&rx, &R);
+    mod_reduce(&rx, &rx, &FN);
+    return u384_cmp(&rx, &r) == 0;
+}
@@ -0,0 +1,17 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+#define P384_PK_LEN      49
+#define P384_RAW_SIG_LEN 96
+
+// P-384 ECDSA verification, matching crypto/ecdsa in Go. Not constant time:
+// everything verification handles is public.
+
+// ECDSA over a digest computed by the caller, of which at most the leftmost
+// 48 bytes are used. raw_sig is r || s, each 48 bytes big-endian; pk is a
+// compressed public key. Returns 1 if valid, 0 otherwise.
+int p384_ecdsa_verify_digest(const uint8_t *digest, size_t digest_len,
+                             const uint8_t raw_sig[P384_RAW_SIG_LEN],
+                             const uint8_t pk[P384_PK_LEN]);
@@ -0,0 +1,228 @@
+#include "rsa.h"
+#include "sha256.h"
+#include "mem.h"
+#include <string.h>
+
+// RSA verification with Montgomery multiplication over 32-bit limbs, least
+// significant limb first. Not constant time: everything here is public.
+
+#define MAX_LIMBS (RSA_MAX_MODULUS_LEN / 4)
+#define EXPONENT_LEN 4
+
+typedef struct {
+    uint32_t n[MAX_LIMBS];
+    int len;          // limbs
+    uint32_t n0inv;   // -n^-1 mod 2^32
+    uint32_t rr[MAX_LIMBS]; // R^2 mod n, R = 2^(32*len)
+} modulus_t;
+
+static void from_bytes(uint32_t *out, const uint8_t *b, int len) {
+    for (int i = 0; i < len; i++) {
+        const uint8_t *p = b + 4 * (len - 1 - i);
+        out[i] = (uint32_t)p[0] << 24 | (uint32_t)p[1] << 16 | (uint32_t)p[2] << 8 | p[3];
+    }
+}
+
+static void to_bytes(uint8_t *b, const uint32_t *x, int len) {
+    for (int i = 0; i < len; i++) {
+        uint8_t *p = b + 4 * (len - 1 - i);
+        p[0] = (uint8_t)(x[i] >> 24);
+        p[1] = (uint8_t)(x[i] >> 16);
+        p[2] = (uint8_t)(x[i] >> 8);
+        p[3] = (uint8_t)x[i];
+    }
+}
+
+// Returns -1, 0 or 1 as a <, ==, > b.
+static int cmp(const uint32_t *a, const uint32_t *b, int len) {
+    for (int i = len - 1; i >= 0; i--) {
+        if (a[i] != b[i]) return a[i] < b[i] ? -1 : 1;
+    }
+    return 0;
+}
+
+// a -= b, returning the borrow.
+static uint32_t sub(uint32_t *a, const uint32_t *b, int len) {
+    uint64_t borrow = 0;
+    for (int i = 0; i < len; i++) {
+        uint64_t d = (uint64_t)a[i] - b[i] - borrow;
+        a[i] = (uint32_t)d;
+        borrow = (d >> 32) & 1;
+    }
+    return (uint32_t)borrow;
+}
+
+// r = a * b / R mod n. r may alias a or b.
+static void mont_mul(uint32_t *r, const uint32_t *a, const uint32_t *b, const modulus_t *m) {
+    uint32_t t[MAX_LIMBS + 2];
+    int len = m->len;
+    memset(t, 0, sizeof(t));
+    for (int i = 0; i < len; i++) {
+        uint64_t c = 0;
+        for (int j = 0; j < len; j++) {
+            c += (uint64_t)t[j] + (uint64_t)a[j] * b[i];
+            t[j] = (uint32_t)c;
+            c >>= 32;
+        }
+        c += t[len];
+        t[len] = (uint32_t)c;
+        t[len + 1] = (uint32_t)(c >> 32);
+
+        uint32_t u = t[0] * m->n0inv;
+        c = ((uint64_t)t[0] + (uint64_t)u * m->n[0]) >> 32;
+        for (int j = 1; j < len; j++) {
+            c += (uint64_t)t[j] + (uint64_t)u * m->n[j];
+            t[j - 1] = (uint32_t)c;
+            c >>= 32;
+        }
+        c += t[len];
+        t[len - 1] = (uint32_t)c;
+        t[len] = t[len + 1] + (uint32_t)(c >> 32);
+    }
+    // t < 2n
+    if (t[len] != 0 || cmp(t, m->n, len) >= 0) sub(t, m->n, len);
+    memcpy(r, t, (size_t)len * sizeof(uint32_t));
+}
+
+static void modulus_init(modulus_t *m, const uint8_t *n, size_t n_len) {
+    m->len = (int)(n_len / 4);
+    from_bytes(m->n, n, m->len);
+
+    // Newton iteration for n^-1 mod 2^32 (n is odd)
+    uint32_t inv = 1;
+    for (int i = 0; i < 5; i++) inv *= 2 - m->n[0] * inv;
+    m->n0inv = (uint32_t)0 - inv;
+
+    // R^2 mod n by doubling 1, 2 * 32 * len times
+    memset(m->rr, 0, sizeof(m->rr));
+    m->rr[0] = 1;
+    for (int i = 0; i < 64 * m->len; i++) {
+        uint32_t carry = 0;
+        for (int j = 0; j < m->len; j++) {
+            uint32_t next = m->rr[j] >> 31;
+            m->rr[j] = m->rr[j] << 1 | carry;
+            carry = next;
+        }
+        if (carry || cmp(m->rr, m->n, m->len) >= 0) sub(m->rr, m->n, m->len);
+    }
+}
+
+int rsa_key_check(const uint8_t *key, size_t key_len) {
+    if (key_len < EXPONENT_LEN) return -1;
+    size_t n_len = key_len - EXPONENT_LEN;
+    if (n_len != 256 && n_len != 384 && n_len != 512) return -1;
+    const uint8_t *n = key + EXPONENT_LEN;
+    if ((n[0] & 0x80) == 0 || (n[n_len - 1] & 1) == 0) return -1;
+    uint32_t e = (uint32_t)key[0] << 24 | (uint32_t)key[1] << 16 | (uint32_t)key[2] << 8 | key[3];
+    if (e < 3 || e > 0x7FFFFFFF || (e & 1) == 0) return -1;
+    return 0;
+}
+
+// em = sig^e mod n, k bytes. Returns nonzero if sig >= n.
+static int rsa_public(const uint8_t *key, size_t key_len, const uint8_t *sig, uint8_t *em) {
+    modulus_t m;
+    uint32_t x[MAX_LIMBS], xm[MAX_LIMBS], acc[MAX_LIMBS], one[MAX_LIMBS];
+    size_t k = key_len - EXPONENT_LEN;
+    uint32_t e = (uint32_t)key[0] << 24 | (uint32_t)key[1] << 16 | (uint32_t)key[2] << 8 | key[3];
+
+    modulus_init(&m, key + EXPONENT_LEN, k);
+    from_bytes(x, sig, m.len);
+    if (cmp(x, m.n, m.len) >= 0) return -1;
+
+    mont_mul(xm, x, m.rr, &m);
+    memcpy(acc, xm, sizeof(acc));
+    int top = 31;
+    while (((e >> top) & 1) == 0) top--;
+    for (int i = top - 1; i >= 0; i--) {
+        mont_mul(acc, acc, acc, &m);
+        if ((e >> i) & 1) mont_mul(acc, acc, xm, &m);
+    }
+    memset(one, 0, sizeof(one));
+    one[0] = 1;
+    mont_mul(acc, acc, one, &m);
+    to_bytes(em, acc, m.len);
+    return 0;
+}
+
+// DER DigestInfo prefix for SHA-256, RFC 8017 section 9.2
+static const uint8_t SHA256_DIGEST_INFO[] = {
+    0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01,
+    0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20,
+};
+
+// EM = 0x00 0x01 0xFF .. 0xFF 0x00 || DigestInfo || H
+static int pkcs1v15_check(const uint8_t *em, size_t k, const uint8_t hash[SHA256_DIGEST_LEN]) {
+    size_t t_len = sizeof(SHA256_DIGEST_INFO) + SHA256_DIGEST_LEN;
+    size_t ps_len = k - t_len - 3;
+    if (em[0] != 0x00 || em[1] != 0x01) return 0;
+    for (size_t i = 0; i < ps_len; i++) {
+        if (em[2 + i] != 0xFF) return 0;
+    }
+    if (em[2 + ps_len] != 0x00) return 0;
+    if (mem_cmp(em + 3 + ps_len, SHA256_DIGEST_INFO, sizeof(SHA256_DIGEST_INFO)) != 0) return 0;
+    return mem_cmp(em + k - SHA256_DIGEST_LEN, hash, SHA256_DIGEST_LEN) == 0;
+}
+
+// EMSA-PSS-VERIFY (RFC 8017 section 9.1.2) with MGF1-SHA-256, recovering
+// the salt length from the position of the 0x01 separator. The modulus
+// uses all its bits, so emLen = k and one leading bit must be zero.
+static int pss_check(uint8_t *em, size_t k, const uint8_t hash[SHA256_DIGEST_LEN]) {
+    size_t db_len = k - SHA256_DIGEST_LEN - 1;
+    uint8_t *db = em;
+    const uint8_t *h = em + db_len;
+    if (em[k - 1] != 0xbc) return 0;
+    if (em[0] & 0x80) return 0;
+
+    for (uint32_t counter = 0, done = 0; done < db_len; counter++) {
+        uint8_t block[SHA256_DIGEST_LEN];
+        uint8_t c[4] = {(uint8_t)(counter >> 24), (uint8_t)(counter >> 16),
+                        (uint8_t)(counter >> 8), (uint8_t)counter};
+        sha256_ctx_t ctx;
+        sha256_init(&ctx);
+        sha256_update(&ctx, h, SHA256_DIGEST_LEN);
+        sha256_update(&ctx, c, sizeof(c));
+        sha256_final(&ctx, block);
+        for (size_t i = 0; i < SHA256_DIGEST_LEN && done < db_len; i++, done++) {
+            db[done] ^= block[i];
+        }
+    }
+    db[0] &= 0x7F;
+
+    // DB = 0x00 .. 0x00 || 0x01 || salt
+    size_t i = 0;
+    while (i < db_len && db[i] == 0x00) i++;
+    if (i == db_len || db[i] != 0x01) return 0;
+
+    static const uint8_t zeros[8] = {0};
+    uint8_t h2[SHA256_DIGEST_LEN];
+    sha256_ctx_t ctx;
+    sha256_init(&ctx);
+    sha256_update(&ctx, zeros, sizeof(zeros));
+    sha256_update(&ctx, hash, SHA256_DIGEST_LEN);
+    sha256_update(&ctx, db + i + 1, db_len - i - 1);
+    sha256_final(&ctx, h2);
+    return mem_cmp(h, h2, SHA256_DIGEST_LEN) == 0;
+}
+
+int rsa_verify(uint8_t scheme, const uint8_t *msg, size_t msg_len,
+               const uint8_t *key, size_t key_len,
+               const uint8_t *sig, size_t sig_len) {
+    uint8_t hash[SHA256_DIGEST_LEN];
+    sha256(msg, msg_len, hash);
+    return rsa_verify_digest(scheme, hash, key, key_len, sig, sig_len);
+}
+
+int rsa_verify_digest(uint8_t scheme, const uint8_t hash[SHA256_DIGEST_LEN],
+                      const uint8_t *key, size_t key_len,
+                      const uint8_t *sig, size_t sig_len) {
+    uint8_t em[RSA_MAX_MODULUS_LEN];
+    size_t k = key_len - EXPONENT_LEN;
+    if (sig_len != k) return 0;
+    if (rsa_public(key, key_len, sig, em) != 0) return 0;
+
+    switch (scheme) {
+    case RSA_PKCS1V15: return pkcs1v15_check(em, k, hash);
+    case RSA_PSS:      return pss_check(em, k, hash);
+    }
+    return 0;
+}
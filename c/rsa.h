@@ -0,0 +1,28 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+// RSA signature schemes, matching internal/crypto/rsa.go. Both sign
+// SHA-256 of the message.
+#define RSA_PKCS1V15 1
+#define RSA_PSS      2
+
+#define RSA_MAX_MODULUS_LEN 512
+
+// Checks a public key encoded as in crypto.RSAPublicKeyBytes: a 4-byte
+// big-endian exponent (odd, 3 to 2^31-1) followed by an odd 256, 384 or
+// 512-byte modulus with its top bit set. Returns 0 if it is well formed.
+int rsa_key_check(const uint8_t *key, size_t key_len);
+
+// Verifies an RSA signature over SHA-256(msg) under a key that passed
+// rsa_key_check. PSS signatures may use any salt length.
+// Returns 1 if valid, 0 otherwise.
+int rsa_verify(uint8_t scheme, const uint8_t *msg, size_t msg_len,
+               const uint8_t *key, size_t key_len,
+               const uint8_t *sig, size_t sig_len);
+
+// rsa_verify given SHA-256(msg), computed by the caller.
+int rsa_verify_digest(uint8_t scheme, const uint8_t hash[32],
+                      const uint8_t *key, size_t key_len,
+                      const uint8_t *sig, size_t sig_len);
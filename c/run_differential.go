@@ -9,6 +9,7 @@ package main
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
@@ -21,6 +22,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/oreparaz/xsig/internal/crypto"
 	ll "github.com/oreparaz/xsig/internal/lowlevel"
 	machines "github.com/oreparaz/xsig/internal/machine"
 )
@@ -83,60 +85,129 @@ func signMsg(key *ecdsa.PrivateKey, msg []byte) ([]byte, error) {
 	return ecdsa.SignASN1(rand.Reader, key, hash[:])
 }
 
+// generatedKeys collects the public keys of the test being generated, so
+// that genContext can revoke some of them.
+var generatedKeys [][]byte
+
 func compressPK(key *ecdsa.PublicKey) []byte {
-	return elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+	pk := elliptic.MarshalCompressed(key.Curve, key.X, key.Y)
+	generatedKeys = append(generatedKeys, pk)
+	return pk
 }
 
 func serializeXSig(build func(a *ll.Assembler)) []byte {
 	mc := &machines.MachineCode{}
 	build(&mc.Assembler)
-	return mc.Serialize(machines.CodeTypeXSig)
+	return mc.SerializeUnchecked(machines.CodeTypeXSig)
 }
 
 func serializeXPubKey(build func(a *ll.Assembler)) []byte {
 	mc := &machines.MachineCode{}
 	build(&mc.Assembler)
-	return mc.Serialize(machines.CodeTypeXPublicKey)
+	return mc.SerializeUnchecked(machines.CodeTypeXPublicKey)
 }
 
 // ---- eval tests ----
 
+// generatedNonce is the nonce the test being generated signed over, if any.
+var generatedNonce []byte
+
+// genContext picks the verifier state for one test, revoking about a
+// quarter of the keys generated for it. The nonce is usually the one the
+// test signed over, otherwise random or absent.
+func genContext(msg []byte) *ll.Context {
+	ctx := &ll.Context{Xmsg: msg, SecurityVersion: int64(mrand.Intn(8))}
+	switch r := mrand.Intn(4); {
+	case r < 2 && generatedNonce != nil:
+		ctx.Nonce = generatedNonce
+	case r < 3:
+		ctx.Nonce = randBytes(mrand.Intn(17))
+	}
+	generatedNonce = nil
+	for _, pk := range generatedKeys {
+		if mrand.Intn(4) == 0 {
+			if ctx.Revoked == nil {
+				ctx.Revoked = ll.RevocationSet{}
+			}
+			ctx.Revoked[crypto.KeyFingerprint(pk)] = true
+		}
+	}
+	generatedKeys = nil
+	if mrand.Intn(4) == 0 {
+		// prehashed: some digests missing, now and then one of another message
+		ctx.Digests = map[byte][]byte{}
+		for alg := crypto.HashSHA256; alg <= crypto.HashSHA3_256; alg++ {
+			switch r := mrand.Intn(8); {
+			case r == 0:
+				ctx.Digests[alg] = crypto.Digest(alg, append(append([]byte{}, msg...), 0))
+			case r < 6:
+				ctx.Digests[alg] = crypto.Digest(alg, msg)
+			}
+		}
+	}
+	return ctx
+}
+
+// contextArgs renders ctx as ceval options.
+func contextArgs(ctx *ll.Context) []string {
+	args := []string{fmt.Sprintf("secver=%d", ctx.SecurityVersion)}
+	if len(ctx.Revoked) > 0 {
+		var revoked []byte
+		for fp := range ctx.Revoked {
+			revoked = append(revoked, fp[:]...)
+		}
+		args = append(args, "revoked="+hex.EncodeToString(revoked))
+	}
+	if len(ctx.Nonce) > 0 {
+		args = append(args, "nonce="+hex.EncodeToString(ctx.Nonce))
+	}
+	if ctx.Digests != nil {
+		args = append(args, "prehashed=1")
+		for alg, digest := range ctx.Digests {
+			args = append(args, fmt.Sprintf("digest=%d:%s", alg, hex.EncodeToString(digest)))
+		}
+	}
+	return args
+}
+
 func runEvalTest(idx int) error {
 	code, msg := genEvalProgram()
+	ctx := genContext(msg)
 
 	// Run Go
-	goResult, goStack := evalGo(code, msg)
+	goResult, goStack := evalGo(code, ctx)
 
 	// Run C
-	cResult, cStack, err := evalC(code, msg)
+	cResult, cStack, err := evalC(code, ctx)
 	if err != nil {
 		return fmt.Errorf("ceval exec error: %v (code=%x msg=%x)", err, code, msg)
 	}
 
 	if goResult != cResult {
-		return fmt.Errorf("result mismatch: go=%s c=%s (code=%x msg=%x)",
-			goResult, cResult, code, msg)
+		return fmt.Errorf("result mismatch: go=%s c=%s (code=%x msg=%x %v)",
+			goResult, cResult, code, msg, contextArgs(ctx))
 	}
 	if goResult == "ok" && goStack != cStack {
-		return fmt.Errorf("stack mismatch: go=%s c=%s (code=%x msg=%x)",
-			goStack, cStack, code, msg)
+		return fmt.Errorf("stack mismatch: go=%s c=%s (code=%x msg=%x %v)",
+			goStack, cStack, code, msg, contextArgs(ctx))
 	}
 	return nil
 }
 
-func evalGo(code, msg []byte) (result string, stack string) {
+func evalGo(code []byte, ctx *ll.Context) (result string, stack string) {
 	e := ll.NewEval()
-	err := e.EvalWithXmsg(code, msg)
+	err := e.EvalWithContext(code, ctx)
 	if err != nil {
 		return "error", ""
 	}
 	return "ok", hex.EncodeToString(e.Stack.S)
 }
 
-func evalC(code, msg []byte) (result string, stack string, err error) {
-	out, execErr := exec.Command(*cevalBin, "eval",
+func evalC(code []byte, ctx *ll.Context) (result string, stack string, err error) {
+	args := append([]string{"eval",
 		hex.EncodeToString(code),
-		hex.EncodeToString(msg)).CombinedOutput()
+		hex.EncodeToString(ctx.Xmsg)}, contextArgs(ctx)...)
+	out, execErr := exec.Command(*cevalBin, args...).CombinedOutput()
 
 	outStr := strings.TrimSpace(string(out))
 
@@ -154,7 +225,7 @@ func evalC(code, msg []byte) (result string, stack string, err error) {
 }
 
 func genEvalProgram() (code []byte, msg []byte) {
-	r := mrand.Intn(10)
+	r := mrand.Intn(28)
 	switch {
 	case r < 3:
 		return genSmartEval()
@@ -164,6 +235,42 @@ func genEvalProgram() (code []byte, msg []byte) {
 		return genSigverifyEval()
 	case r < 9:
 		return genDumbEval()
+	case r < 10:
+		return genConditionalEval()
+	case r < 11:
+		return genThresholdEval()
+	case r < 12:
+		return genNumEval()
+	case r < 13:
+		return genSecurityVersionEval()
+	case r < 14:
+		return genMsgFieldEval()
+	case r < 15:
+		return genCheckSigFromStackEval()
+	case r < 16:
+		return genCatSplitEval()
+	case r < 17:
+		return genNonceEval()
+	case r < 18:
+		return genPKHashEval()
+	case r < 19:
+		return genRawSigEval()
+	case r < 20:
+		return genSecp256k1Eval()
+	case r < 21:
+		return genSLHDSAEval()
+	case r < 22:
+		return genRSAEval()
+	case r < 23:
+		return genWebAuthnEval()
+	case r < 24:
+		return genSSHSigEval()
+	case r < 25:
+		return genEthEval()
+	case r < 26:
+		return genEd25519Eval()
+	case r < 27:
+		return genSigVerifyHashEval()
 	default:
 		return genRawBytes()
 	}
@@ -267,6 +374,717 @@ func genDumbEval() ([]byte, []byte) {
 	return a.Code, nil
 }
 
+func genConditionalEval() ([]byte, []byte) {
+	a := &ll.Assembler{}
+	fns := []func() ll.Instruction{ll.If, ll.Else, ll.EndIf, ll.Add, ll.Not, ll.And}
+	nOps := mrand.Intn(30) + 1
+	for i := 0; i < nOps; i++ {
+		if mrand.Intn(3) == 0 {
+			a.Append(ll.Push1(mrand.Intn(3)))
+		} else {
+			a.Append(fns[mrand.Intn(len(fns))]())
+		}
+	}
+	return a.Code, nil
+}
+
+func genThresholdEval() ([]byte, []byte) {
+	a := &ll.Assembler{}
+	nOps := mrand.Intn(20) + 1
+	for i := 0; i < nOps; i++ {
+		switch mrand.Intn(5) {
+		case 0, 1:
+			a.Append(ll.Push1(mrand.Intn(4)))
+		case 2:
+			a.Append(ll.Threshold())
+		case 3:
+			a.Append(ll.ToAltStack())
+		case 4:
+			a.Append(ll.FromAltStack())
+		}
+	}
+	return a.Code, nil
+}
+
+func genNumEval() ([]byte, []byte) {
+	a := &ll.Assembler{}
+	fns := []func() ll.Instruction{ll.NumAdd, ll.NumSub, ll.NumMul, ll.NumLessThan, ll.NumEqual, ll.ToNum}
+	nOps := mrand.Intn(12) + 1
+	for i := 0; i < nOps; i++ {
+		switch mrand.Intn(4) {
+		case 0:
+			a.Append(ll.PushNum(mrand.Int63() >> uint(mrand.Intn(63))))
+		case 1:
+			a.Append(ll.PushNum(-mrand.Int63() >> uint(mrand.Intn(63))))
+		default:
+			a.Append(fns[mrand.Intn(len(fns))]())
+		}
+	}
+	return a.Code, nil
+}
+
+func genSecurityVersionEval() ([]byte, []byte) {
+	a := &ll.Assembler{}
+	nOps := mrand.Intn(4) + 1
+	for i := 0; i < nOps; i++ {
+		a.Append(ll.PushNum(int64(mrand.Intn(10) - 1)))
+		a.Append(ll.CheckSecurityVersion())
+		if mrand.Intn(2) == 0 {
+			a.Append(ll.ToNum())
+		}
+	}
+	return a.Code, nil
+}
+
+// genMsgFieldEval reads random fields of a random structured message,
+// sometimes corrupted, and compares them against each other and constants.
+func genMsgFieldEval() ([]byte, []byte) {
+	var fields []ll.MessageField
+	tag := -1
+	for i := mrand.Intn(5); i > 0; i-- {
+		tag += 1 + mrand.Intn(3)
+		value := make([]byte, mrand.Intn(4))
+		if mrand.Intn(10) == 0 {
+			value = make([]byte, 250+mrand.Intn(10))
+		}
+		rand.Read(value)
+		fields = append(fields, ll.MessageField{Tag: byte(tag), Value: value})
+	}
+	msg, _ := ll.EncodeMessage(fields)
+	if len(msg) > 0 && mrand.Intn(5) == 0 {
+		msg[mrand.Intn(len(msg))] ^= byte(1 << uint(mrand.Intn(8)))
+	}
+
+	a := &ll.Assembler{}
+	for i := mrand.Intn(4) + 1; i > 0; i-- {
+		switch mrand.Intn(4) {
+		case 0, 1:
+			a.Append(ll.Push1(mrand.Intn(tag + 2)))
+			a.Append(ll.MsgField())
+		case 2:
+			a.Append(ll.PushBlob([]byte{byte(mrand.Intn(3))}))
+		case 3:
+			a.Append(ll.Equal())
+		}
+	}
+	return a.Code, msg
+}
+
+func genCheckSigFromStackEval() ([]byte, []byte) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	msg := make([]byte, mrand.Intn(ll.MaxPushBlobSize+1))
+	rand.Read(msg)
+	sig, err := signMsg(key, msg)
+	if err != nil {
+		return nil, nil
+	}
+
+	switch mrand.Intn(4) {
+	case 0: // wrong message
+		if len(msg) > 0 {
+			msg[mrand.Intn(len(msg))] ^= 0x01
+		}
+	case 1: // corrupted signature
+		sig[mrand.Intn(len(sig))] ^= byte(1 << uint(mrand.Intn(8)))
+	}
+
+	a := &ll.Assembler{}
+	a.Append(ll.Push(sig))
+	a.Append(ll.PushBlob(msg))
+	a.Append(ll.Push(compressPK(&key.PublicKey)))
+	a.Append(ll.CheckSigFromStack())
+	return a.Code, nil
+}
+
+func genCatSplitEval() ([]byte, []byte) {
+	randBlob := func() []byte {
+		b := make([]byte, mrand.Intn(200))
+		for i := range b {
+			b[i] = byte(mrand.Intn(256))
+		}
+		return b
+	}
+	a := &ll.Assembler{}
+	a.Append(ll.PushBlob(randBlob()))
+	nOps := mrand.Intn(6) + 1
+	for i := 0; i < nOps; i++ {
+		switch mrand.Intn(4) {
+		case 0:
+			a.Append(ll.PushBlob(randBlob()))
+			a.Append(ll.Cat())
+		case 1:
+			a.Append(ll.PushNum(int64(mrand.Intn(210) - 5)))
+			a.Append(ll.Split())
+		case 2:
+			a.Append(ll.Size())
+		case 3:
+			a.Append(ll.Cat())
+		}
+	}
+	return a.Code, nil
+}
+
+func genNonceEval() ([]byte, []byte) {
+	generatedNonce = randBytes(mrand.Intn(17))
+	var fields []ll.MessageField
+	for tag := 0; tag < 4; tag++ {
+		switch mrand.Intn(3) {
+		case 0:
+			fields = append(fields, ll.MessageField{Tag: byte(tag), Value: generatedNonce})
+		case 1:
+			fields = append(fields, ll.MessageField{Tag: byte(tag), Value: randBytes(mrand.Intn(8))})
+		}
+	}
+	msg, _ := ll.EncodeMessage(fields)
+	if mrand.Intn(8) == 0 && len(msg) > 0 {
+		msg = msg[:mrand.Intn(len(msg))]
+	}
+
+	a := &ll.Assembler{}
+	a.Append(ll.Push1(mrand.Intn(5)))
+	a.Append(ll.CheckNonce())
+	return a.Code, msg
+}
+
+func genPKHashEval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(32))
+	n := mrand.Intn(4) + 1
+	var pks, commitments [][]byte
+	a := &ll.Assembler{}
+	for i := 0; i < n; i++ {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		pk := compressPK(&key.PublicKey)
+		size := 20
+		if mrand.Intn(2) == 0 {
+			size = 32
+		}
+		h, _ := crypto.PublicKeyHash(pk, size)
+		switch mrand.Intn(12) {
+		case 0: // wrong key
+			pk = compressPK(&key.PublicKey)
+			pk[1+mrand.Intn(32)] ^= 0x01
+		case 1: // truncated commitment
+			h = h[:mrand.Intn(len(h))]
+		}
+		if i == 0 {
+			sig, err := signMsg(key, msg)
+			if err == nil {
+				a.Append(ll.Push(sig))
+			}
+		}
+		pks = append(pks, pk)
+		commitments = append(commitments, h)
+	}
+	for i := len(pks) - 1; i >= 0; i-- {
+		a.Append(ll.Push(pks[i]))
+	}
+	for _, in := range ll.CheckPKHashes(commitments) {
+		a.Append(in)
+	}
+	if n == 1 && mrand.Intn(2) == 0 {
+		a.Append(ll.SignatureVerify())
+	}
+	return a.Code, msg
+}
+
+// rawScalarEdges are r or s values at the edges of the valid range [1, n-1].
+func rawScalarEdges() [][]byte {
+	n := elliptic.P256().Params().N.Bytes()
+	nMinus1 := append([]byte{}, n...)
+	nMinus1[31]--
+	return [][]byte{make([]byte, 32), {31: 1}, nMinus1, n, bytesOf(0xff, 32)}
+}
+
+func bytesOf(b byte, n int) []byte {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = b
+	}
+	return buf
+}
+
+func genRawSigEval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(48))
+	n := mrand.Intn(3) + 1
+	var pks, sigs [][]byte
+	for i := 0; i < n; i++ {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, err := signMsg(key, msg)
+		if err != nil {
+			return genArithmeticChain()
+		}
+		sig, _ := crypto.RawSignature(der)
+		switch mrand.Intn(10) {
+		case 0: // bit flip
+			sig[mrand.Intn(64)] ^= 1 << uint(mrand.Intn(8))
+		case 1: // r or s at an edge
+			edges := rawScalarEdges()
+			copy(sig[32*mrand.Intn(2):], edges[mrand.Intn(len(edges))])
+		case 2: // short
+			sig = sig[:mrand.Intn(64)]
+		case 3: // DER instead
+			sig = der
+		}
+		pks = append(pks, compressPK(&key.PublicKey))
+		sigs = append(sigs, sig)
+	}
+
+	a := &ll.Assembler{}
+	if n == 1 && mrand.Intn(2) == 0 {
+		a.Append(ll.Push(sigs[0])); a.Append(ll.Push(pks[0])); a.Append(ll.SignatureVerifyRaw())
+		return a.Code, msg
+	}
+	nMin := mrand.Intn(n) + 1
+	for i := 0; i < nMin; i++ {
+		a.Append(ll.Push(sigs[mrand.Intn(n)]))
+	}
+	for i := n - 1; i >= 0; i-- {
+		a.Append(ll.Push(pks[i]))
+	}
+	a.Append(ll.Push1(nMin)); a.Append(ll.Push1(n)); a.Append(ll.MultisigVerifyRaw())
+	return a.Code, msg
+}
+
+// genTypedSigner returns a key and its signature over msg, sometimes
+// corrupted. The key is secp256k1 (ECDSA or BIP-340), or P-256 if withP256.
+func genTypedSigner(msg []byte, withP256 bool) (ll.TypedPublicKey, []byte) {
+	var key ll.TypedPublicKey
+	var sig []byte
+	types := 2
+	if withP256 {
+		types = 3
+	}
+	switch mrand.Intn(types) {
+	case 0:
+		_, pk, s := crypto.HelperVerifyDataSecp256k1(msg)
+		key, sig = ll.TypedPublicKey{Type: crypto.KeyTypeSecp256k1, PublicKey: pk}, s
+	case 1:
+		_, pk, s := crypto.HelperVerifyDataSchnorr(msg)
+		key, sig = ll.TypedPublicKey{Type: crypto.KeyTypeSchnorr, PublicKey: pk}, s
+	default:
+		k, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		sig, _ = signMsg(k, msg)
+		key = ll.TypedPublicKey{Type: crypto.KeyTypeP256, PublicKey: compressPK(&k.PublicKey)}
+	}
+	if key.Type != crypto.KeyTypeP256 {
+		generatedKeys = append(generatedKeys, key.PublicKey)
+	}
+	switch mrand.Intn(8) {
+	case 0:
+		sig[mrand.Intn(len(sig))] ^= 1 << uint(mrand.Intn(8))
+	case 1:
+		sig = sig[:mrand.Intn(len(sig))]
+	case 2:
+		key.PublicKey[1+mrand.Intn(len(key.PublicKey)-1)] ^= 0x01
+	}
+	return key, sig
+}
+
+func genSecp256k1Eval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(48))
+	a := &ll.Assembler{}
+	if mrand.Intn(2) == 0 {
+		key, sig := genTypedSigner(msg, false)
+		a.Append(ll.Push(sig)); a.Append(ll.Push(key.PublicKey))
+		if key.Type == crypto.KeyTypeSecp256k1 {
+			a.Append(ll.SignatureVerifySecp256k1())
+		} else {
+			a.Append(ll.SignatureVerifySchnorr())
+		}
+		return a.Code, msg
+	}
+
+	n := mrand.Intn(4) + 1
+	var keys []ll.TypedPublicKey
+	var sigs [][]byte
+	for i := 0; i < n; i++ {
+		key, sig := genTypedSigner(msg, true)
+		if mrand.Intn(3) == 0 {
+			sig = nil // did not sign
+		}
+		keys, sigs = append(keys, key), append(sigs, sig)
+	}
+	if n > 1 && mrand.Intn(8) == 0 {
+		keys[n-1] = keys[0]
+	}
+	if mrand.Intn(10) == 0 {
+		sigs = sigs[1:]
+	}
+	for _, in := range ll.PushSignatureSlots(sigs) {
+		a.Append(in)
+	}
+	for _, in := range ll.MixedMultisig(mrand.Intn(n)+1, keys) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+func genSigVerifyHashEval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(200))
+	keyType := []byte{crypto.KeyTypeP256, crypto.KeyTypeSecp256k1, crypto.KeyTypeP384}[mrand.Intn(3)]
+	alg := byte(mrand.Intn(4) + 1)
+	signed := msg
+	if mrand.Intn(8) == 0 {
+		signed = randBytes(mrand.Intn(200))
+	}
+	pk, sig := crypto.HelperVerifyDataDigest(keyType, alg, signed)
+	switch mrand.Intn(10) {
+	case 0:
+		// the value of s, keeping the encoding valid
+		sig[len(sig)-1-mrand.Intn(8)] ^= byte(1 << uint(mrand.Intn(8)))
+	case 1:
+		pk[1+mrand.Intn(len(pk)-1)] ^= byte(1 << uint(mrand.Intn(8)))
+	case 2:
+		alg = byte(mrand.Intn(6))
+	case 3:
+		keyType = byte(mrand.Intn(7))
+	}
+	a := &ll.Assembler{}
+	a.Append(ll.Push(sig))
+	for _, in := range ll.SigVerifyHash(keyType, alg, pk) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+// frostGroup is a 2-of-4 FROST group, made once as key generation is slow.
+var frostGroup []*crypto.FROSTKeyShare
+
+// genEd25519Signer returns an Ed25519 key and a signature of msg, by a
+// subset of frostGroup or by a plain key, possibly mutated.
+func genEd25519Signer(msg []byte) (ll.TypedPublicKey, []byte) {
+	if frostGroup == nil {
+		frostGroup = crypto.HelperFROSTGroup(2, 4)
+	}
+	var pk, sig []byte
+	if mrand.Intn(2) == 0 {
+		signers := append([]*crypto.FROSTKeyShare{}, frostGroup...)
+		mrand.Shuffle(len(signers), func(i, j int) { signers[i], signers[j] = signers[j], signers[i] })
+		pk, sig = frostGroup[0].GroupPublicKey, crypto.SignFROST(signers[:2+mrand.Intn(3)], msg)
+	} else {
+		publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+		pk, sig = publicKey, ed25519.Sign(privateKey, msg)
+	}
+	pk = append([]byte{}, pk...)
+	generatedKeys = append(generatedKeys, pk)
+	switch mrand.Intn(8) {
+	case 0:
+		sig[mrand.Intn(len(sig))] ^= 1 << uint(mrand.Intn(8))
+	case 1:
+		sig = sig[:mrand.Intn(len(sig))]
+	case 2:
+		pk[mrand.Intn(len(pk))] ^= 0x01
+	}
+	return ll.TypedPublicKey{Type: crypto.KeyTypeEd25519, PublicKey: pk}, sig
+}
+
+func genEd25519Eval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(48))
+	a := &ll.Assembler{}
+	if mrand.Intn(2) == 0 {
+		key, sig := genEd25519Signer(msg)
+		a.Append(ll.Push(sig)); a.Append(ll.Push(key.PublicKey)); a.Append(ll.SignatureVerifyEd25519())
+		return a.Code, msg
+	}
+
+	n := mrand.Intn(3) + 2
+	var keys []ll.TypedPublicKey
+	var sigs [][]byte
+	for i := 0; i < n; i++ {
+		key, sig := genEd25519Signer(msg)
+		if i > 0 && mrand.Intn(2) == 0 {
+			key, sig = genTypedSigner(msg, true)
+		}
+		if mrand.Intn(3) == 0 {
+			sig = nil // did not sign
+		}
+		keys, sigs = append(keys, key), append(sigs, sig)
+	}
+	for _, in := range ll.PushSignatureSlots(sigs) {
+		a.Append(in)
+	}
+	for _, in := range ll.MixedMultisig(mrand.Intn(n)+1, keys) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+// slhSigner is an SLH-DSA signature made once: signing takes far longer
+// than a differential run, so the generators mutate a few of them.
+type slhSigner struct {
+	paramSet byte
+	msg      []byte
+	pk       []byte
+	sig      []byte
+}
+
+var slhSigners []slhSigner
+
+func genSLHDSASigner() slhSigner {
+	if slhSigners == nil {
+		for _, paramSet := range []byte{crypto.SLHDSASHA2128f, crypto.SLHDSASHA2128f, crypto.SLHDSASHA2128s} {
+			msg := randBytes(mrand.Intn(48))
+			_, pk, sig := crypto.HelperVerifyDataSLHDSA(paramSet, msg)
+			slhSigners = append(slhSigners, slhSigner{paramSet, msg, pk, sig})
+		}
+	}
+	return slhSigners[mrand.Intn(len(slhSigners))]
+}
+
+func genSLHDSAEval() ([]byte, []byte) {
+	signer := genSLHDSASigner()
+	msg := signer.msg
+	if mrand.Intn(6) == 0 {
+		msg = randBytes(mrand.Intn(48))
+	}
+	sig := append([]byte{}, signer.sig...)
+	switch mrand.Intn(6) {
+	case 0:
+		sig[mrand.Intn(len(sig))] ^= byte(1 << uint(mrand.Intn(8)))
+	case 1:
+		sig = sig[:mrand.Intn(len(sig))]
+	}
+
+	// the signature among other objects: empty, short or another signer's
+	a := &ll.Assembler{}
+	nObjects := mrand.Intn(3) + 1
+	index := mrand.Intn(nObjects)
+	for i := 0; i < nObjects; i++ {
+		switch {
+		case i == index:
+			a.Append(ll.PushLarge(sig))
+		case mrand.Intn(2) == 0:
+			a.Append(ll.PushLarge(genSLHDSASigner().sig))
+		default:
+			a.Append(ll.PushLarge(randBytes(mrand.Intn(300))))
+		}
+	}
+	if mrand.Intn(8) == 0 {
+		index = mrand.Intn(5)
+	}
+	paramSet := signer.paramSet
+	if mrand.Intn(8) == 0 {
+		paramSet = byte(mrand.Intn(4))
+	}
+	pk := append([]byte{}, signer.pk...)
+	if mrand.Intn(8) == 0 {
+		pk[mrand.Intn(len(pk))] ^= 0x80
+	}
+	for _, in := range ll.SLHDSASigVerify(index, paramSet, pk) {
+		a.Append(in)
+	}
+	if mrand.Intn(10) == 0 {
+		// a truncated object at the end of the code
+		a.Code = append(a.Code, ll.OP_PUSHLARGE, byte(mrand.Intn(256)), byte(mrand.Intn(3)))
+	}
+	return a.Code, msg
+}
+
+// rsaSigner is an RSA key with a signature over msg, cached like
+// slhSigner because key generation is slow.
+type rsaSigner struct {
+	scheme byte
+	msg    []byte
+	pk     []byte
+	sig    []byte
+}
+
+var rsaSigners []rsaSigner
+
+func genRSASigner() rsaSigner {
+	if rsaSigners == nil {
+		for _, bits := range []int{2048, 2048, 3072} {
+			for _, scheme := range []byte{crypto.RSAPKCS1v15, crypto.RSAPSS} {
+				msg := randBytes(mrand.Intn(48))
+				_, pk, sig := crypto.HelperVerifyDataRSA(bits, scheme, msg)
+				rsaSigners = append(rsaSigners, rsaSigner{scheme, msg, pk, sig})
+			}
+		}
+	}
+	return rsaSigners[mrand.Intn(len(rsaSigners))]
+}
+
+func genRSAEval() ([]byte, []byte) {
+	signer := genRSASigner()
+	msg := signer.msg
+	if mrand.Intn(6) == 0 {
+		msg = randBytes(mrand.Intn(48))
+	}
+	sig := append([]byte{}, signer.sig...)
+	switch mrand.Intn(8) {
+	case 0:
+		sig[mrand.Intn(len(sig))] ^= byte(1 << uint(mrand.Intn(8)))
+	case 1:
+		sig = sig[:mrand.Intn(len(sig))]
+	case 2:
+		sig = genRSASigner().sig
+	case 3:
+		// at or above the modulus
+		copy(sig, signer.pk[4:])
+		sig[len(sig)-1] += byte(mrand.Intn(2))
+	}
+	pk := append([]byte{}, signer.pk...)
+	committed := pk
+	switch mrand.Intn(10) {
+	case 0:
+		pk[mrand.Intn(len(pk))] ^= byte(1 << uint(mrand.Intn(8)))
+		committed = pk
+	case 1:
+		committed = genRSASigner().pk
+	}
+
+	a := &ll.Assembler{}
+	sigObject, keyObject := 0, 1
+	if mrand.Intn(2) == 0 {
+		sigObject, keyObject = 1, 0
+		a.Append(ll.PushLarge(pk)); a.Append(ll.PushLarge(sig))
+	} else {
+		a.Append(ll.PushLarge(sig)); a.Append(ll.PushLarge(pk))
+	}
+	if mrand.Intn(10) == 0 {
+		sigObject = mrand.Intn(4)
+	}
+	scheme := signer.scheme
+	if mrand.Intn(6) == 0 {
+		scheme = byte(mrand.Intn(4))
+	}
+	for _, in := range ll.RSASigVerify(sigObject, keyObject, scheme, committed) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+func genWebAuthnEval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(48))
+	rpID := []string{"example.com", "example.org"}[mrand.Intn(2)]
+	privateKey, pk, _, clientData, _ := crypto.HelperVerifyDataWebAuthn(rpID, msg)
+	authData := crypto.WebAuthnAuthData(rpID, byte(mrand.Intn(256)), mrand.Uint32())
+	if mrand.Intn(4) == 0 {
+		authData = append(authData, randBytes(mrand.Intn(40))...)
+	}
+	switch mrand.Intn(8) {
+	case 0:
+		clientData = crypto.WebAuthnClientData(randBytes(mrand.Intn(48)), "https://"+rpID)
+	case 1:
+		clientData[mrand.Intn(len(clientData))] ^= byte(1 << uint(mrand.Intn(8)))
+	case 2:
+		clientData = clientData[:mrand.Intn(len(clientData))]
+	}
+	sig := crypto.SignWebAuthn(privateKey, authData, clientData)
+	switch mrand.Intn(8) {
+	case 0:
+		authData = authData[:mrand.Intn(len(authData))]
+	case 1:
+		sig[len(sig)-1-mrand.Intn(8)] ^= byte(1 << uint(mrand.Intn(8)))
+	}
+
+	a := &ll.Assembler{}
+	a.Append(ll.Push(sig))
+	authObject, clientObject := 0, 1
+	if mrand.Intn(2) == 0 {
+		authObject, clientObject = 1, 0
+		a.Append(ll.PushLarge(clientData)); a.Append(ll.PushLarge(authData))
+	} else {
+		a.Append(ll.PushLarge(authData)); a.Append(ll.PushLarge(clientData))
+	}
+	if mrand.Intn(10) == 0 {
+		clientObject = mrand.Intn(3)
+	}
+	policyRP := rpID
+	if mrand.Intn(8) == 0 {
+		policyRP = "example.net"
+	}
+	flags := []byte{0, crypto.WebAuthnUserVerified, byte(mrand.Intn(256))}[mrand.Intn(3)]
+	for _, in := range ll.WebAuthnSigVerify(authObject, clientObject, pk, policyRP, flags) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+func genEthEval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(200))
+	mode := byte(mrand.Intn(2))
+	domain := crypto.EIP712DomainSeparator("xsig", "1", uint64(1+mrand.Intn(2)), nil)
+	d, address, sig := crypto.HelperVerifyDataEthereum(mode, domain, msg)
+	switch mrand.Intn(10) {
+	case 0:
+		sig[mrand.Intn(len(sig))] ^= byte(1 << uint(mrand.Intn(8)))
+	case 1:
+		sig[64] = byte(mrand.Intn(32))
+	case 2:
+		sig = crypto.SignEthereum(d, crypto.Keccak256(msg))
+	case 3:
+		copy(sig[:32], randBytes(32))
+	}
+	policyDomain := domain
+	if mrand.Intn(8) == 0 {
+		policyDomain = crypto.EIP712DomainSeparator("xsig", "2", 1, nil)
+	}
+	if mrand.Intn(8) == 0 {
+		mode ^= 1
+	}
+	if mrand.Intn(10) == 0 {
+		_, address, _ = crypto.HelperVerifyDataEthereum(mode, domain, msg)
+	}
+
+	a := &ll.Assembler{}
+	a.Append(ll.Push(sig))
+	for _, in := range ll.EthSigVerify(mode, policyDomain, address) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+func genSSHSigEval() ([]byte, []byte) {
+	msg := randBytes(mrand.Intn(48))
+	keyType := []string{crypto.SSHKeyTypeEd25519, crypto.SSHKeyTypeECDSAP256}[mrand.Intn(2)]
+	namespace := []string{"xsig", "git", "file"}[mrand.Intn(3)]
+	key, pk, _ := crypto.HelperVerifyDataSSH(keyType, []byte(namespace), nil)
+	signNamespace := namespace
+	if mrand.Intn(8) == 0 {
+		signNamespace = "xsig"
+	}
+	signMsg := msg
+	if mrand.Intn(8) == 0 {
+		signMsg = randBytes(mrand.Intn(48))
+	}
+	hashAlgorithm := []string{"sha256", "sha512", "sha512", "sha384"}[mrand.Intn(4)]
+	sig := crypto.SignSSHSig(key, []byte(signNamespace), hashAlgorithm, signMsg)
+	switch mrand.Intn(8) {
+	case 0:
+		sig[mrand.Intn(len(sig))] ^= byte(1 << uint(mrand.Intn(8)))
+	case 1:
+		sig = sig[:mrand.Intn(len(sig))]
+	case 2:
+		sig = append(sig, randBytes(1+mrand.Intn(4))...)
+	}
+	if mrand.Intn(10) == 0 {
+		_, pk, _ = crypto.HelperVerifyDataSSH(keyType, nil, nil)
+	}
+	if mrand.Intn(12) == 0 {
+		pk = pk[:mrand.Intn(len(pk))]
+	}
+
+	a := &ll.Assembler{}
+	a.Append(ll.PushLarge(sig))
+	object := 0
+	if mrand.Intn(12) == 0 {
+		object = 1
+	}
+	for _, in := range ll.SSHSigVerify(object, pk, namespace) {
+		a.Append(in)
+	}
+	return a.Code, msg
+}
+
+func randBytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(mrand.Intn(256))
+	}
+	return b
+}
+
 func genRawBytes() ([]byte, []byte) {
 	n := mrand.Intn(64)
 	code := make([]byte, n)
@@ -284,35 +1102,37 @@ func genRawBytes() ([]byte, []byte) {
 
 func runM001Test(idx int) error {
 	xpubkey, xsig, msg := genM001Input()
+	ctx := genContext(msg)
 
 	// Run Go
-	goResult := m001Go(xpubkey, xsig, msg)
+	goResult := m001Go(xpubkey, xsig, ctx)
 
 	// Run C
-	cResult, err := m001C(xpubkey, xsig, msg)
+	cResult, err := m001C(xpubkey, xsig, ctx)
 	if err != nil {
 		return fmt.Errorf("ceval m001 exec error: %v", err)
 	}
 
 	if goResult != cResult {
-		return fmt.Errorf("m001 mismatch: go=%d c=%d (xpk=%x xsig=%x msg=%x)",
-			goResult, cResult, xpubkey, xsig, msg)
+		return fmt.Errorf("m001 mismatch: go=%d c=%d (xpk=%x xsig=%x msg=%x %v)",
+			goResult, cResult, xpubkey, xsig, msg, contextArgs(ctx))
 	}
 	return nil
 }
 
-func m001Go(xpubkey, xsig, msg []byte) int {
-	if machines.RunMachine001(xpubkey, xsig, msg) {
+func m001Go(xpubkey, xsig []byte, ctx *ll.Context) int {
+	if machines.RunMachine001WithContext(xpubkey, xsig, ctx) {
 		return 1
 	}
 	return 0
 }
 
-func m001C(xpubkey, xsig, msg []byte) (int, error) {
-	out, execErr := exec.Command(*cevalBin, "m001",
+func m001C(xpubkey, xsig []byte, ctx *ll.Context) (int, error) {
+	args := append([]string{"m001",
 		hex.EncodeToString(xpubkey),
 		hex.EncodeToString(xsig),
-		hex.EncodeToString(msg)).CombinedOutput()
+		hex.EncodeToString(ctx.Xmsg)}, contextArgs(ctx)...)
+	out, execErr := exec.Command(*cevalBin, args...).CombinedOutput()
 
 	outStr := strings.TrimSpace(string(out))
 
@@ -327,16 +1147,24 @@ func m001C(xpubkey, xsig, msg []byte) (int, error) {
 }
 
 func genM001Input() (xpubkey, xsig, msg []byte) {
-	r := mrand.Intn(6)
+	r := mrand.Intn(10)
 	switch {
 	case r < 2:
 		return genValidSingleSig()
 	case r < 3:
 		return genValidMultisig()
 	case r < 4:
-		return genCorruptedSingleSig()
+		return genDuplicateMultisig()
 	case r < 5:
+		return genWeightedMultisig()
+	case r < 6:
+		return genMerkleSig()
+	case r < 7:
+		return genCorruptedSingleSig()
+	case r < 8:
 		return genRandomM001()
+	case r < 9:
+		return genHybridM001()
 	default:
 		return genRawM001()
 	}
@@ -406,6 +1234,155 @@ func genValidMultisig() ([]byte, []byte, []byte) {
 	return xpubkey, xsigSer, msg
 }
 
+// genDuplicateMultisig builds multisigs whose key list or signatures may
+// repeat, under either OP_MULTISIGVERIFY or OP_STRICTMULTISIGVERIFY.
+func genDuplicateMultisig() ([]byte, []byte, []byte) {
+	msg := make([]byte, 16+mrand.Intn(48))
+	rand.Read(msg)
+
+	nKeys := 2 + mrand.Intn(3)
+	nMin := 1 + mrand.Intn(nKeys)
+
+	keys := make([]*ecdsa.PrivateKey, nKeys)
+	pks := make([][]byte, nKeys)
+	for i := range keys {
+		if i > 0 && mrand.Intn(3) == 0 {
+			keys[i] = keys[mrand.Intn(i)]
+		} else {
+			keys[i], _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		}
+		pks[i] = compressPK(&keys[i].PublicKey)
+	}
+
+	sigs := make([][]byte, nMin)
+	for i := range sigs {
+		if i > 0 && mrand.Intn(3) == 0 {
+			sigs[i] = sigs[mrand.Intn(i)]
+			continue
+		}
+		sig, err := signMsg(keys[mrand.Intn(nKeys)], msg)
+		if err != nil {
+			return nil, nil, msg
+		}
+		sigs[i] = sig
+	}
+
+	op := ll.MultisigVerify()
+	if mrand.Intn(2) == 0 {
+		op = ll.StrictMultisigVerify()
+	}
+	xpubkey := serializeXPubKey(func(a *ll.Assembler) {
+		for _, pk := range pks {
+			a.Append(ll.Push(pk))
+		}
+		a.Append(ll.Push1(nMin))
+		a.Append(ll.Push1(nKeys))
+		a.Append(op)
+	})
+
+	xsigSer := serializeXSig(func(a *ll.Assembler) {
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+	})
+
+	return xpubkey, xsigSer, msg
+}
+
+func genWeightedMultisig() ([]byte, []byte, []byte) {
+	msg := make([]byte, 16+mrand.Intn(48))
+	rand.Read(msg)
+
+	nKeys := 1 + mrand.Intn(4)
+	keys := make([]*ecdsa.PrivateKey, nKeys)
+	pks := make([][]byte, nKeys)
+	weights := make([]int64, nKeys)
+	var total int64
+	for i := range keys {
+		keys[i], _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		pks[i] = compressPK(&keys[i].PublicKey)
+		weights[i] = int64(1 + mrand.Intn(5))
+		total += weights[i]
+	}
+	target := 1 + mrand.Int63n(total)
+
+	// random signers, possibly repeated
+	nSigs := mrand.Intn(nKeys + 1)
+	sigs := make([][]byte, nSigs)
+	for i := range sigs {
+		sig, err := signMsg(keys[mrand.Intn(nKeys)], msg)
+		if err != nil {
+			return nil, nil, msg
+		}
+		sigs[i] = sig
+	}
+
+	xpubkey := serializeXPubKey(func(a *ll.Assembler) {
+		for i, pk := range pks {
+			a.Append(ll.Push(pk))
+			a.Append(ll.PushNum(weights[i]))
+		}
+		a.Append(ll.PushNum(target))
+		a.Append(ll.Push1(nKeys))
+		a.Append(ll.WeightedSigVerify())
+	})
+
+	xsigSer := serializeXSig(func(a *ll.Assembler) {
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		a.Append(ll.Push1(nSigs))
+	})
+
+	return xpubkey, xsigSer, msg
+}
+
+func genMerkleSig() ([]byte, []byte, []byte) {
+	msg := make([]byte, 16+mrand.Intn(48))
+	rand.Read(msg)
+
+	nKeys := 1 + mrand.Intn(40)
+	keys := make([]*ecdsa.PrivateKey, nKeys)
+	pks := make([][]byte, nKeys)
+	for i := range keys {
+		keys[i], _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		pks[i] = compressPK(&keys[i].PublicKey)
+	}
+	root, err := crypto.MerkleRoot(pks)
+	if err != nil {
+		return nil, nil, msg
+	}
+
+	// usually an honest proof, sometimes a proof for someone else's leaf
+	signer := mrand.Intn(nKeys)
+	claimed := signer
+	if mrand.Intn(4) == 0 {
+		claimed = mrand.Intn(nKeys)
+	}
+	proof, err := crypto.MerkleProof(pks, claimed)
+	if err != nil {
+		return nil, nil, msg
+	}
+	sig, err := signMsg(keys[signer], msg)
+	if err != nil {
+		return nil, nil, msg
+	}
+
+	xpubkey := serializeXPubKey(func(a *ll.Assembler) {
+		a.Append(ll.Push(root))
+		a.Append(ll.MerkleSigVerify())
+	})
+
+	xsigSer := serializeXSig(func(a *ll.Assembler) {
+		a.Append(ll.Push(sig))
+		for _, in := range ll.PushMerkleProof(pks[claimed], proof) {
+			a.Append(in)
+		}
+	})
+
+	return xpubkey, xsigSer, msg
+}
+
 func genCorruptedSingleSig() ([]byte, []byte, []byte) {
 	xpubkey, xsig, msg := genValidSingleSig()
 
@@ -451,6 +1428,52 @@ func genRandomM001() ([]byte, []byte, []byte) {
 	return xpubkey, xsigSer, msg
 }
 
+// genHybridM001 is an ECDSA 1-of-1 AND SLH-DSA 1-of-2, the SLH-DSA
+// signatures being objects pushed by the xsig (or, sometimes, the xpubkey).
+func genHybridM001() ([]byte, []byte, []byte) {
+	signer := genSLHDSASigner()
+	other := genSLHDSASigner()
+	msg := signer.msg
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sig, err := signMsg(key, msg)
+	if err != nil {
+		return nil, nil, msg
+	}
+
+	// the signer's slot, and its key's position in the xpubkey: these
+	// differ a quarter of the time
+	slot := mrand.Intn(2)
+	slots := [][]byte{nil, nil}
+	slots[slot] = signer.sig
+	keys := []slhSigner{other, other}
+	keys[slot] = signer
+	if mrand.Intn(4) == 0 {
+		keys[0], keys[1] = keys[1], keys[0]
+	}
+	inXPubKey := mrand.Intn(6) == 0
+
+	xpubkey := serializeXPubKey(func(a *ll.Assembler) {
+		if inXPubKey {
+			a.Append(ll.PushLarge(slots[0])); a.Append(ll.PushLarge(slots[1]))
+		}
+		a.Append(ll.Push(compressPK(&key.PublicKey)))
+		a.Append(ll.SignatureVerify())
+		for i, k := range keys {
+			for _, in := range ll.SLHDSASigVerify(i, k.paramSet, k.pk) {
+				a.Append(in)
+			}
+		}
+		a.Append(ll.Or()); a.Append(ll.And())
+	})
+	xsigSer := serializeXSig(func(a *ll.Assembler) {
+		if !inXPubKey {
+			a.Append(ll.PushLarge(slots[0])); a.Append(ll.PushLarge(slots[1]))
+		}
+		a.Append(ll.Push(sig))
+	})
+	return xpubkey, xsigSer, msg
+}
+
 func genRawM001() ([]byte, []byte, []byte) {
 	xpk := make([]byte, mrand.Intn(64))
 	for i := range xpk {
@@ -0,0 +1,430 @@
+#include "secp256k1.h"
+#include "sha256.h"
+#include <string.h>
+
+// 256-bit integers as 8 little-endian 32-bit limbs.
+typedef struct {
+    uint32_t v[8];
+} u256_t;
+
+// A modulus m = 2^256 - c, with c small enough that folding the high half
+// of a product (hi * 2^256 = hi * c mod m) converges in a few steps.
+typedef struct {
+    u256_t m;
+    u256_t c;
+} modulus_t;
+
+static const modulus_t FP = {
+    {{0xFFFFFC2F, 0xFFFFFFFE, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}},
+    {{0x000003D1, 0x00000001, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000, 0x00000000}},
+};
+
+static const modulus_t FN = {
+    {{0xD0364141, 0xBFD25E8C, 0xAF48A03B, 0xBAAEDCE6, 0xFFFFFFFE, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF}},
+    {{0x2FC9BEBF, 0x402DA173, 0x50B75FC4, 0x45512319, 0x00000001, 0x00000000, 0x00000000, 0x00000000}},
+};
+
+// (p + 1) / 4: square roots mod p are a^((p+1)/4) since p = 3 mod 4
+static const u256_t SQRT_EXP =
+    {{0xBFFFFF0C, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0xFFFFFFFF, 0x3FFFFFFF}};
+
+static const u256_t ONE = {{1, 0, 0, 0, 0, 0, 0, 0}};
+static const u256_t SEVEN = {{7, 0, 0, 0, 0, 0, 0, 0}};
+
+// Jacobian coordinates (x/z^2, y/z^3); z = 0 is the point at infinity.
+typedef struct {
+    u256_t x, y, z;
+} point_t;
+
+static const point_t G = {
+    {{0x16F81798, 0x59F2815B, 0x2DCE28D9, 0x029BFCDB, 0xCE870B07, 0x55A06295, 0xF9DCBBAC, 0x79BE667E}},
+    {{0xFB10D4B8, 0x9C47D08F, 0xA6855419, 0xFD17B448, 0x0E1108A8, 0x5DA4FBFC, 0x26A3C465, 0x483ADA77}},
+    {{1, 0, 0, 0, 0, 0, 0, 0}},
+};
+
+static void u256_from_be(u256_t *r, const uint8_t in[32]) {
+    for (int i = 0; i < 8; i++) {
+        const uint8_t *p = in + 28 - 4 * i;
+        r->v[i] = ((uint32_t)p[0] << 24) | ((uint32_t)p[1] << 16) |
+                  ((uint32_t)p[2] << 8) | (uint32_t)p[3];
+    }
+}
+
+static void u256_to_be(uint8_t out[32], const u256_t *a) {
+    for (int i = 0; i < 8; i++) {
+        uint8_t *p = out + 28 - 4 * i;
+        p[0] = (uint8_t)(a->v[i] >> 24);
+        p[1] = (uint8_t)(a->v[i] >> 16);
+        p[2] = (uint8_t)(a->v[i] >> 8);
+        p[3] = (uint8_t)a->v[i];
+    }
+}
+
+static int u256_is_zero(const u256_t *a) {
+    uint32_t acc = 0;
+    for (int i = 0; i < 8; i++) acc |= a->v[i];
+    return acc == 0;
+}
+
+static int u256_cmp(const u256_t *a, const u256_t *b) {
+    for (int i = 7; i >= 0; i--) {
+        if (a->v[i] != b->v[i]) return a->v[i] < b->v[i] ? -1 : 1;
+    }
+    return 0;
+}
+
+static uint32_t u256_add(u256_t *r, const u256_t *a, const u256_t *b) {
+    uint64_t carry = 0;
+    for (int i = 0; i < 8; i++) {
+        uint64_t cur = (uint64_t)a->v[i] + b->v[i] + carry;
+        r->v[i] = (uint32_t)cur;
+        carry = cur >> 32;
+    }
+    return (uint32_t)carry;
+}
+
+static uint32_t u256_sub(u256_t *r, const u256_t *a, const u256_t *b) {
+    uint64_t borrow = 0;
+    for (int i = 0; i < 8; i++) {
+        uint64_t cur = (uint64_t)a->v[i] - b->v[i] - borrow;
+        r->v[i] = (uint32_t)cur;
+        borrow = (cur >> 32) & 1;
+    }
+    return (uint32_t)borrow;
+}
+
+// a, b < m
+static void mod_add(u256_t *r, const u256_t *a, const u256_t *b, const modulus_t *m) {
+    if (u256_add(r, a, b) || u256_cmp(r, &m->m) >= 0) {
+        u256_sub(r, r, &m->m);
+    }
+}
+
+// a, b < m
+static void mod_sub(u256_t *r, const u256_t *a, const u256_t *b, const modulus_t *m) {
+    if (u256_sub(r, a, b)) {
+        u256_add(r, r, &m->m);
+    }
+}
+
+// Any a < 2^256.
+static void mod_reduce(u256_t *r, const u256_t *a, const modulus_t *m) {
+    *r = *a;
+    while (u256_cmp(r, &m->m) >= 0) {
+        u256_sub(r, r, &m->m);
+    }
+}
+
+// Any a, b < 2^256.
+static void mod_mul(u256_t *r, const u256_t *a, const u256_t *b, const modulus_t *m) {
+    uint32_t w[17] = {0};
+    for (int i = 0; i < 8; i++) {
+        uint64_t carry = 0;
+        for (int j = 0; j < 8; j++) {
+            uint64_t cur = (uint64_t)a->v[i] * b->v[j] + w[i + j] + carry;
+            w[i + j] = (uint32_t)cur;
+            carry = cur >> 32;
+        }
+        w[i + 8] = (uint32_t)carry;
+    }
+
+    // fold: lo + hi * 2^256 = lo + hi * c (mod m), until hi is zero
+    for (;;) {
+        uint32_t hi = 0;
+        for (int i = 8; i < 17; i++) hi |= w[i];
+        if (hi == 0) break;
+
+        uint32_t n[17] = {0};
+        memcpy(n, w, 8 * sizeof(uint32_t));
+        for (int i = 0; i < 9; i++) {
+            uint64_t carry = 0;
+            int k = i;
+            for (int j = 0; j < 8; j++, k++) {
+                uint64_t cur = (uint64_t)w[8 + i] * m->c.v[j] + n[k] + carry;
+                n[k] = (uint32_t)cur;
+                carry = cur >> 32;
+            }
+            for (; carry && k < 17; k++) {
+                uint64_t cur = (uint64_t)n[k] + carry;
+                n[k] = (uint32_t)cur;
+                carry = cur >> 32;
+            }
+        }
+        memcpy(w, n, sizeof(n));
+    }
+
+    u256_t lo;
+    memcpy(lo.v, w, sizeof(lo.v));
+    mod_reduce(r, &lo, m);
+}
+
+static void mod_pow(u256_t *r, const u256_t *a, const u256_t *e, const modulus_t *m) {
+    u256_t acc = ONE;
+    for (int i = 255; i >= 0; i--) {
+        mod_mul(&acc, &acc, &acc, m);
+        if ((e->v[i / 32] >> (i % 32)) & 1) {
+            mod_mul(&acc, &acc, a, m);
+        }
+    }
+    *r = acc;
+}
+
+// a != 0 (mod m); m is prime
+static void mod_inv(u256_t *r, const u256_t *a, const modulus_t *m) {
+    u256_t e, two = {{2, 0, 0, 0, 0, 0, 0, 0}};
+    u256_sub(&e, &m->m, &two);
+    mod_pow(r, a, &e, m);
+}
+
+static void fmul(u256_t *r, const u256_t *a, const u256_t *b) { mod_mul(r, a, b, &FP); }
+static void fadd(u256_t *r, const u256_t *a, const u256_t *b) { mod_add(r, a, b, &FP); }
+static void fsub(u256_t *r, const u256_t *a, const u256_t *b) { mod_sub(r, a, b, &FP); }
+
+static void point_double(point_t *r, const point_t *p) {
+    if (u256_is_zero(&p->z) || u256_is_zero(&p->y)) {
+        memset(r, 0, sizeof(*r));
+        return;
+    }
+    // S = 4xy^2, M = 3x^2, x' = M^2 - 2S, y' = M(S - x') - 8y^4, z' = 2yz
+    u256_t yy, s, m, t, x3, y3, z3;
+    fmul(&yy, &p->y, &p->y);
+    fmul(&s, &p->x, &yy);
+    fadd(&s, &s, &s);
+    fadd(&s, &s, &s);
+    fmul(&t, &p->x, &p->x);
+    fadd(&m, &t, &t);
+    fadd(&m, &m, &t);
+    fmul(&x3, &m, &m);
+    fsub(&x3, &x3, &s);
+    fsub(&x3, &x3, &s);
+    fmul(&t, &yy, &yy);
+    fadd(&t, &t, &t);
+    fadd(&t, &t, &t);
+    fadd(&t, &t, &t);
+    fsub(&y3, &s, &x3);
+    fmul(&y3, &m, &y3);
+    fsub(&y3, &y3, &t);
+    fmul(&z3, &p->y, &p->z);
+    fadd(&z3, &z3, &z3);
+    r->x = x3;
+    r->y = y3;
+    r->z = z3;
+}
+
+static void point_add(point_t *r, const point_t *p, const point_t *q) {
+    if (u256_is_zero(&p->z)) {
+        *r = *q;
+        return;
+    }
+    if (u256_is_zero(&q->z)) {
+        *r = *p;
+        return;
+    }
+    u256_t pz2, qz2, u1, u2, s1, s2, h, rr, t;
+    fmul(&pz2, &p->z, &p->z);
+    fmul(&qz2, &q->z, &q->z);
+    fmul(&u1, &p->x, &qz2);
+    fmul(&u2, &q->x, &pz2);
+    fmul(&t, &q->z, &qz2);
+    fmul(&s1, &p->y, &t);
+    fmul(&t, &p->z, &pz2);
+    fmul(&s2, &q->y, &t);
+    fsub(&h, &u2, &u1);
+    fsub(&rr, &s2, &s1);
+    if (u256_is_zero(&h)) {
+        if (u256_is_zero(&rr)) {
+            point_double(r, p);
+        } else {
+            memset(r, 0, sizeof(*r));
+        }
+        return;
+    }
+    // x' = r^2 - h^3 - 2u1h^2, y' = r(u1h^2 - x') - s1h^3, z' = h z1 z2
+    u256_t hh, hhh, v, x3, y3, z3;
+    fmul(&hh, &h, &h);
+    fmul(&hhh, &h, &hh);
+    fmul(&v, &u1, &hh);
+    fmul(&x3, &rr, &rr);
+    fsub(&x3, &x3, &hhh);
+    fsub(&x3, &x3, &v);
+    fsub(&x3, &x3, &v);
+    fsub(&y3, &v, &x3);
+    fmul(&y3, &rr, &y3);
+    fmul(&t, &s1, &hhh);
+    fsub(&y3, &y3, &t);
+    fmul(&z3, &p->z, &q->z);
+    fmul(&z3, &h, &z3);
+    r->x = x3;
+    r->y = y3;
+    r->z = z3;
+}
+
+static void point_mul(point_t *r, const point_t *p, const u256_t *k) {
+    point_t acc;
+    memset(&acc, 0, sizeof(acc));
+    for (int i = 255; i >= 0; i--) {
+        point_double(&acc, &acc);
+        if ((k->v[i / 32] >> (i % 32)) & 1) {
+            point_add(&acc, &acc, p);
+        }
+    }
+    *r = acc;
+}
+
+// p must not be the point at infinity.
+static void point_affine(u256_t *x, u256_t *y, const point_t *p) {
+    u256_t z_inv, z_inv2, t;
+    mod_inv(&z_inv, &p->z, &FP);
+    fmul(&z_inv2, &z_inv, &z_inv);
+    fmul(x, &p->x, &z_inv2);
+    fmul(&t, &z_inv, &z_inv2);
+    fmul(y, &p->y, &t);
+}
+
+// The point with x coordinate x and a y of the given parity, if any.
+static int lift_x(point_t *r, const u256_t *x, int odd) {
+    if (u256_cmp(x, &FP.m) >= 0) return -1;
+    u256_t c, y, t;
+    fmul(&c, x, x);
+    fmul(&c, &c, x);
+    fadd(&c, &c, &SEVEN);
+    mod_pow(&y, &c, &SQRT_EXP, &FP);
+    fmul(&t, &y, &y);
+    if (u256_cmp(&t, &c) != 0) return -1;
+    if ((int)(y.v[0] & 1) != odd) {
+        u256_sub(&y, &FP.m, &y);
+    }
+    r->x = *x;
+    r->y = y;
+    r->z = ONE;
+    return 0;
+}
+
+// k1 * G + k2 * q
+static void double_mul(point_t *r, const u256_t *k1, const point_t *q, const u256_t *k2) {
+    point_t a, b;
+    point_mul(&a, &G, k1);
+    point_mul(&b, q, k2);
+    point_add(r, &a, &b);
+}
+
+int secp256k1_ecdsa_verify(const uint8_t *msg, size_t msg_len,
+                           const uint8_t raw_sig[64],
+                           const uint8_t pk[SECP256K1_PK_LEN]) {
+    uint8_t hash[SHA256_DIGEST_LEN];
+    sha256(msg, msg_len, hash);
+    return secp256k1_ecdsa_verify_digest(hash, raw_sig, pk);
+}
+
+int secp256k1_ecdsa_verify_digest(const uint8_t hash[32], const uint8_t raw_sig[64],
+                                  const uint8_t pk[SECP256K1_PK_LEN]) {
+    if (pk[0] != 0x02 && pk[0] != 0x03) return 0;
+    u256_t x;
+    point_t q;
+    u256_from_be(&x, pk + 1);
+    if (lift_x(&q, &x, pk[0] == 0x03) != 0) return 0;
+
+    u256_t r, s;
+    u256_from_be(&r, raw_sig);
+    u256_from_be(&s, raw_sig + 32);
+    if (u256_is_zero(&r) || u256_is_zero(&s)) return 0;
+    if (u256_cmp(&r, &FN.m) >= 0 || u256_cmp(&s, &FN.m) >= 0) return 0;
+
+    u256_t z, w, u1, u2;
+    u256_from_be(&z, hash);
+    mod_inv(&w, &s, &FN);
+    mod_mul(&u1, &z, &w, &FN);
+    mod_mul(&u2, &r, &w, &FN);
+
+    point_t R;
+    double_mul(&R, &u1, &q, &u2);
+    if (u256_is_zero(&R.z)) return 0;
+    u256_t rx, ry;
+    point_affine(&rx, &ry, &R);
+    mod_reduce(&rx, &rx, &FN);
+    return u256_cmp(&rx, &r) == 0;
+}
+
+int secp256k1_ecdsa_recover(const uint8_t hash[32], const uint8_t raw_sig[64], int odd,
+                            uint8_t pk_xy[64]) {
+    u256_t r, s;
+    u256_from_be(&r, raw_sig);
+    u256_from_be(&s, raw_sig + 32);
+    if (u256_is_zero(&r) || u256_is_zero(&s)) return -1;
+    if (u256_cmp(&r, &FN.m) >= 0 || u256_cmp(&s, &FN.m) >= 0) return -1;
+
+    // the x coordinate of R is taken to be r; r + n is not tried
+    point_t R;
+    if (lift_x(&R, &r, odd) != 0) return -1;
+
+    // Q = r^-1 (s R - z G)
+    u256_t z, r_inv, u1, u2;
+    u256_from_be(&z, hash);
+    mod_reduce(&z, &z, &FN);
+    u256_sub(&u1, &FN.m, &z); // n - z, which mod_mul reduces to 0 for z = 0
+    mod_inv(&r_inv, &r, &FN);
+    mod_mul(&u1, &u1, &r_inv, &FN);
+    mod_mul(&u2, &s, &r_inv, &FN);
+
+    point_t Q;
+    double_mul(&Q, &u1, &R, &u2);
+    if (u256_is_zero(&Q.z)) return -1;
+    u256_t qx, qy;
+    point_affine(&qx, &qy, &Q);
+    u256_to_be(pk_xy, &qx);
+    u256_to_be(pk_xy + 32, &qy);
+    return 0;
+}
+
+static void bip340_challenge(uint8_t out[32], const uint8_t r[32], const uint8_t pk[32],
+                             const uint8_t m[32]) {
+    static const char tag[] = "BIP0340/challenge";
+    uint8_t tag_hash[SHA256_DIGEST_LEN];
+    sha256((const uint8_t *)tag, sizeof(tag) - 1, tag_hash);
+
+    sha256_ctx_t ctx;
+    sha256_init(&ctx);
+    sha256_update(&ctx, tag_hash, sizeof(tag_hash));
+    sha256_update(&ctx, tag_hash, sizeof(tag_hash));
+    sha256_update(&ctx, r, 32);
+    sha256_update(&ctx, pk, 32);
+    sha256_update(&ctx, m, 32);
+    sha256_final(&ctx, out);
+}
+
+int secp256k1_schnorr_verify(const uint8_t *msg, size_t msg_len,
+                             const uint8_t sig[SCHNORR_SIG_LEN],
+                             const uint8_t pk[SCHNORR_PK_LEN]) {
+    uint8_t m[SHA256_DIGEST_LEN];
+    sha256(msg, msg_len, m);
+    return secp256k1_schnorr_verify_digest(m, sig, pk);
+}
+
+int secp256k1_schnorr_verify_digest(const uint8_t m[SHA256_DIGEST_LEN],
+                                    const uint8_t sig[SCHNORR_SIG_LEN],
+                                    const uint8_t pk[SCHNORR_PK_LEN]) {
+    u256_t px;
+    point_t p;
+    u256_from_be(&px, pk);
+    if (lift_x(&p, &px, 0) != 0) return 0;
+
+    u256_t r, s;
+    u256_from_be(&r, sig);
+    u256_from_be(&s, sig + 32);
+    if (u256_cmp(&r, &FP.m) >= 0 || u256_cmp(&s, &FN.m) >= 0) return 0;
+
+    uint8_t e_bytes[SHA256_DIGEST_LEN];
+    bip340_challenge(e_bytes, sig, pk, m);
+    u256_t e, neg_e;
+    u256_from_be(&e, e_bytes);
+    mod_reduce(&e, &e, &FN);
+    u256_sub(&neg_e, &FN.m, &e); // n - e; for e = 0 that is n, giving infinity
+
+    point_t R;
+    double_mul(&R, &s, &p, &neg_e);
+    if (u256_is_zero(&R.z)) return 0;
+    u256_t rx, ry;
+    point_affine(&rx, &ry, &R);
+    if (ry.v[0] & 1) return 0;
+    return u256_cmp(&rx, &r) == 0;
+}
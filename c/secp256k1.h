@@ -0,0 +1,40 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+#define SECP256K1_PK_LEN          33
+#define SCHNORR_PK_LEN            32
+#define SCHNORR_SIG_LEN           64
+
+// secp256k1 signature verification, matching internal/crypto/secp256k1.go.
+// Not constant time: everything verification handles is public.
+
+// ECDSA over SHA-256(msg). raw_sig is r || s, pk a compressed public key.
+// Returns 1 if valid, 0 otherwise.
+int secp256k1_ecdsa_verify(const uint8_t *msg, size_t msg_len,
+                           const uint8_t raw_sig[64],
+                           const uint8_t pk[SECP256K1_PK_LEN]);
+
+// ECDSA over a 32-byte hash computed by the caller.
+int secp256k1_ecdsa_verify_digest(const uint8_t hash[32], const uint8_t raw_sig[64],
+                                  const uint8_t pk[SECP256K1_PK_LEN]);
+
+// ECDSA public key recovery: the key whose signature r || s over the 32-byte
+// hash has an R with an odd y if odd, and the x coordinate r. Writes the
+// uncompressed key without its prefix, X || Y, to pk_xy. Returns 0 on
+// success, -1 if there is no such key.
+int secp256k1_ecdsa_recover(const uint8_t hash[32], const uint8_t raw_sig[64], int odd,
+                            uint8_t pk_xy[64]);
+
+// BIP-340 Schnorr, the BIP-340 message being SHA-256(msg). pk is an x-only
+// public key. Returns 1 if valid, 0 otherwise.
+int secp256k1_schnorr_verify(const uint8_t *msg, size_t msg_len,
+                             const uint8_t sig[SCHNORR_SIG_LEN],
+                             const uint8_t pk[SCHNORR_PK_LEN]);
+
+// BIP-340 Schnorr over the 32-byte message m, which for
+// secp256k1_schnorr_verify is SHA-256(msg).
+int secp256k1_schnorr_verify_digest(const uint8_t m[32],
+                                    const uint8_t sig[SCHNORR_SIG_LEN],
+                                    const uint8_t pk[SCHNORR_PK_LEN]);
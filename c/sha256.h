@@ -0,0 +1,21 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+#define SHA256_DIGEST_LEN 32
+
+typedef struct {
+    uint32_t h[8];
+    uint8_t buf[64];
+    size_t buf_len;
+    uint64_t total_len;
+} sha256_ctx_t;
+
+// Incremental SHA-256 (FIPS 180-4).
+void sha256_init(sha256_ctx_t *ctx);
+void sha256_update(sha256_ctx_t *ctx, const uint8_t *data, size_t len);
+void sha256_final(sha256_ctx_t *ctx, uint8_t out[SHA256_DIGEST_LEN]);
+
+// One-shot SHA-256.
+void sha256(const uint8_t *data, size_t len, uint8_t out[SHA256_DIGEST_LEN]);
@@ -0,0 +1,30 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+#define SHA512_DIGEST_LEN 64
+#define SHA384_DIGEST_LEN 48
+
+typedef struct {
+    uint64_t h[8];
+    uint8_t buf[128];
+    size_t buf_len;
+    uint64_t total_len;
+} sha512_ctx_t;
+
+// Incremental SHA-512 (FIPS 180-4), for Ed25519 and sshsig.
+void sha512_init(sha512_ctx_t *ctx);
+void sha512_update(sha512_ctx_t *ctx, const uint8_t *data, size_t len);
+void sha512_final(sha512_ctx_t *ctx, uint8_t out[SHA512_DIGEST_LEN]);
+
+// One-shot SHA-512.
+void sha512(const uint8_t *data, size_t len, uint8_t out[SHA512_DIGEST_LEN]);
+
+// SHA-384: SHA-512 with other initial values, truncated. Updated with
+// sha512_update.
+void sha384_init(sha512_ctx_t *ctx);
+void sha384_final(sha512_ctx_t *ctx, uint8_t out[SHA384_DIGEST_LEN]);
+
+// One-shot SHA-384.
+void sha384(const uint8_t *data, size_t len, uint8_t out[SHA384_DIGEST_LEN]);
@@ -0,0 +1,301 @@
+#include "slhdsa.h"
+#include "sha256.h"
+#include "mem.h"
+#include <string.h>
+
+// SLH-DSA verification for the SHA2 parameter sets of security category 1,
+// matching internal/crypto/slhdsa.go. Only SHA-256 is needed. Works in
+// place on the signature: no buffer larger than a few hashes.
+
+#define N     16
+#define LG_W  4
+#define W     (1 << LG_W)
+#define LEN1  (8 * N / LG_W)
+#define LEN2  3
+#define LEN   (LEN1 + LEN2)
+
+#define MAX_K 33
+
+// Address types
+#define ADRS_WOTS_HASH  0
+#define ADRS_WOTS_PK    1
+#define ADRS_TREE       2
+#define ADRS_FORS_TREE  3
+#define ADRS_FORS_ROOTS 4
+
+typedef struct {
+    int h;  // total tree height
+    int d;  // layers
+    int hp; // height of each XMSS tree
+    int a;  // FORS tree height
+    int k;  // FORS trees
+    int m;  // message digest bytes
+} params_t;
+
+static const params_t PARAMS_128S = {63, 7, 9, 12, 14, 30};
+static const params_t PARAMS_128F = {66, 22, 3, 6, 33, 34};
+
+static const params_t *params_for(uint8_t param_set) {
+    switch (param_set) {
+    case SLHDSA_SHA2_128S: return &PARAMS_128S;
+    case SLHDSA_SHA2_128F: return &PARAMS_128F;
+    }
+    return NULL;
+}
+
+static size_t fors_sig_len(const params_t *p) {
+    return (size_t)p->k * (1 + p->a) * N;
+}
+
+static size_t xmss_sig_len(const params_t *p) {
+    return (size_t)(LEN + p->hp) * N;
+}
+
+size_t slhdsa_sig_len(uint8_t param_set) {
+    const params_t *p = params_for(param_set);
+    if (p == NULL) return 0;
+    return N + fors_sig_len(p) + (size_t)p->d * xmss_sig_len(p);
+}
+
+// ADRS: layer (4 bytes), tree address (12), type (4), then three words.
+typedef uint8_t adrs_t[32];
+
+static void put32(uint8_t *b, uint32_t v) {
+    b[0] = (uint8_t)(v >> 24);
+    b[1] = (uint8_t)(v >> 16);
+    b[2] = (uint8_t)(v >> 8);
+    b[3] = (uint8_t)v;
+}
+
+static uint32_t get32(const uint8_t *b) {
+    return (uint32_t)b[0] << 24 | (uint32_t)b[1] << 16 | (uint32_t)b[2] << 8 | b[3];
+}
+
+static void set_layer(adrs_t a, uint32_t l) { put32(a, l); }
+
+static void set_tree(adrs_t a, uint64_t t) {
+    put32(a + 4, 0);
+    put32(a + 8, (uint32_t)(t >> 32));
+    put32(a + 12, (uint32_t)t);
+}
+
+static void set_type_and_clear(adrs_t a, uint32_t t) {
+    put32(a + 16, t);
+    memset(a + 20, 0, 12);
+}
+
+static void set_key_pair(adrs_t a, uint32_t i) { put32(a + 20, i); }
+
+// chain address and tree height share a word, as do hash address and
+// tree index
+static void set_word2(adrs_t a, uint32_t v) { put32(a + 24, v); }
+static void set_word3(adrs_t a, uint32_t v) { put32(a + 28, v); }
+
+// The tweakable hashes F, H and T_l are Trunc_n(SHA-256(PK.seed ||
+// 0^(64-n) || ADRSc || M)). seeded has absorbed the first block.
+static void thash_begin(sha256_ctx_t *h, const sha256_ctx_t *seeded, const adrs_t a) {
+    uint8_t c[22]; // ADRSc
+    c[0] = a[3];
+    memcpy(c + 1, a + 8, 8);
+    c[9] = a[19];
+    memcpy(c + 10, a + 20, 12);
+    *h = *seeded;
+    sha256_update(h, c, sizeof(c));
+}
+
+static void thash_end(sha256_ctx_t *h, uint8_t out[N]) {
+    uint8_t digest[SHA256_DIGEST_LEN];
+    sha256_final(h, digest);
+    memcpy(out, digest, N);
+}
+
+static void thash1(const sha256_ctx_t *seeded, const adrs_t a,
+                   const uint8_t *in, uint8_t out[N]) {
+    sha256_ctx_t h;
+    thash_begin(&h, seeded, a);
+    sha256_update(&h, in, N);
+    thash_end(&h, out);
+}
+
+static void thash2(const sha256_ctx_t *seeded, const adrs_t a,
+                   const uint8_t *left, const uint8_t *right, uint8_t out[N]) {
+    sha256_ctx_t h;
+    thash_begin(&h, seeded, a);
+    sha256_update(&h, left, N);
+    sha256_update(&h, right, N);
+    thash_end(&h, out);
+}
+
+// Splits x into out_len big-endian b-bit integers.
+static void base_2b(const uint8_t *x, int b, int out_len, uint32_t *out) {
+    int in = 0, bits = 0;
+    uint32_t total = 0;
+    for (int i = 0; i < out_len; i++) {
+        while (bits < b) {
+            total = total << 8 | x[in++];
+            bits += 8;
+        }
+        bits -= b;
+        out[i] = (total >> bits) & (((uint32_t)1 << b) - 1);
+    }
+}
+
+static uint64_t read_be(const uint8_t *x, int len) {
+    uint64_t v = 0;
+    for (int i = 0; i < len; i++) v = v << 8 | x[i];
+    return v;
+}
+
+// Hashes node (in place) up an authentication path; adrs holds the tree
+// index of node.
+static void climb(uint8_t node[N], uint32_t leaf, const uint8_t *auth, int height,
+                  const sha256_ctx_t *seeded, adrs_t adrs) {
+    uint32_t index = get32(adrs + 28);
+    for (int j = 0; j < height; j++) {
+        set_word2(adrs, (uint32_t)j + 1);
+        index /= 2;
+        set_word3(adrs, index);
+        if (((leaf >> j) & 1) == 0) {
+            thash2(seeded, adrs, node, auth + j * N, node);
+        } else {
+            thash2(seeded, adrs, auth + j * N, node, node);
+        }
+    }
+}
+
+// WOTS+ public key from a signature of msg (n bytes), compressed with T_len.
+static void wots_pk_from_sig(const uint8_t *sig, const uint8_t msg[N],
+                             const sha256_ctx_t *seeded, adrs_t adrs, uint8_t out[N]) {
+    uint32_t digits[LEN];
+    base_2b(msg, LG_W, LEN1, digits);
+    uint32_t csum = 0;
+    for (int i = 0; i < LEN1; i++) csum += W - 1 - digits[i];
+    csum <<= (8 - (LEN2 * LG_W) % 8) % 8;
+    uint8_t csum_bytes[2] = {(uint8_t)(csum >> 8), (uint8_t)csum};
+    base_2b(csum_bytes, LG_W, LEN2, digits + LEN1);
+
+    adrs_t pk_adrs;
+    memcpy(pk_adrs, adrs, sizeof(adrs_t));
+    set_type_and_clear(pk_adrs, ADRS_WOTS_PK);
+    memcpy(pk_adrs + 20, adrs + 20, 4); // key pair address
+    sha256_ctx_t t;
+    thash_begin(&t, seeded, pk_adrs);
+
+    for (int i = 0; i < LEN; i++) {
+        uint8_t tmp[N];
+        memcpy(tmp, sig + i * N, N);
+        set_word2(adrs, (uint32_t)i);
+        for (uint32_t j = digits[i]; j < W - 1; j++) {
+            set_word3(adrs, j);
+            thash1(seeded, adrs, tmp, tmp);
+        }
+        sha256_update(&t, tmp, N);
+    }
+    thash_end(&t, out);
+}
+
+// Root of an XMSS tree from a signature of node; the result replaces node.
+static void xmss_pk_from_sig(const params_t *p, uint32_t idx, const uint8_t *sig,
+                             uint8_t node[N], const sha256_ctx_t *seeded, adrs_t adrs) {
+    set_type_and_clear(adrs, ADRS_WOTS_HASH);
+    set_key_pair(adrs, idx);
+    wots_pk_from_sig(sig, node, seeded, adrs, node);
+    set_type_and_clear(adrs, ADRS_TREE);
+    set_word3(adrs, idx);
+    climb(node, idx, sig + LEN * N, p->hp, seeded, adrs);
+}
+
+static void fors_pk_from_sig(const params_t *p, const uint8_t *sig, const uint8_t *md,
+                             const sha256_ctx_t *seeded, adrs_t adrs, uint8_t out[N]) {
+    uint32_t indices[MAX_K];
+    base_2b(md, p->a, p->k, indices);
+
+    adrs_t roots_adrs;
+    memcpy(roots_adrs, adrs, sizeof(adrs_t));
+    set_type_and_clear(roots_adrs, ADRS_FORS_ROOTS);
+    memcpy(roots_adrs + 20, adrs + 20, 4); // key pair address
+    sha256_ctx_t t;
+    thash_begin(&t, seeded, roots_adrs);
+
+    for (int i = 0; i < p->k; i++) {
+        const uint8_t *s = sig + (size_t)i * (1 + p->a) * N;
+        uint8_t node[N];
+        set_word2(adrs, 0);
+        set_word3(adrs, ((uint32_t)i << p->a) + indices[i]);
+        thash1(seeded, adrs, s, node);
+        climb(node, indices[i], s + N, p->a, seeded, adrs);
+        sha256_update(&t, node, N);
+    }
+    thash_end(&t, out);
+}
+
+// H_msg: MGF1-SHA-256(R || PK.seed || SHA-256(R || PK.seed || PK.root ||
+// M'), m), M' being 0x00 0x00 || msg (pure, empty context).
+static void hash_msg(const params_t *p, const uint8_t *r, const uint8_t *pk,
+                     const uint8_t *msg, size_t msg_len, uint8_t *out) {
+    static const uint8_t prefix[2] = {0, 0};
+    uint8_t seed[2 * N + SHA256_DIGEST_LEN + 4];
+    sha256_ctx_t h;
+    sha256_init(&h);
+    sha256_update(&h, r, N);
+    sha256_update(&h, pk, SLHDSA_PK_LEN);
+    sha256_update(&h, prefix, sizeof(prefix));
+    sha256_update(&h, msg, msg_len);
+    memcpy(seed, r, N);
+    memcpy(seed + N, pk, N);
+    sha256_final(&h, seed + 2 * N);
+
+    for (int done = 0, counter = 0; done < p->m; counter++) {
+        uint8_t block[SHA256_DIGEST_LEN];
+        put32(seed + 2 * N + SHA256_DIGEST_LEN, (uint32_t)counter);
+        sha256(seed, sizeof(seed), block);
+        int n = p->m - done < SHA256_DIGEST_LEN ? p->m - done : SHA256_DIGEST_LEN;
+        memcpy(out + done, block, (size_t)n);
+        done += n;
+    }
+}
+
+int slhdsa_verify(uint8_t param_set, const uint8_t *msg, size_t msg_len,
+                  const uint8_t *sig, size_t sig_len,
+                  const uint8_t pk[SLHDSA_PK_LEN]) {
+    const params_t *p = params_for(param_set);
+    if (p == NULL || sig_len != slhdsa_sig_len(param_set)) return 0;
+
+    uint8_t digest[40];
+    hash_msg(p, sig, pk, msg, msg_len, digest);
+    int md_len = (p->k * p->a + 7) / 8;
+    int tree_len = (p->h - p->hp + 7) / 8;
+    int leaf_len = (p->hp + 7) / 8;
+    uint64_t idx_tree = read_be(digest + md_len, tree_len) &
+                        (((uint64_t)1 << (p->h - p->hp)) - 1);
+    uint32_t idx_leaf = (uint32_t)(read_be(digest + md_len + tree_len, leaf_len) &
+                                   (((uint64_t)1 << p->hp) - 1));
+
+    uint8_t block[64] = {0};
+    memcpy(block, pk, N);
+    sha256_ctx_t seeded;
+    sha256_init(&seeded);
+    sha256_update(&seeded, block, sizeof(block));
+
+    adrs_t adrs;
+    memset(adrs, 0, sizeof(adrs));
+    set_tree(adrs, idx_tree);
+    set_type_and_clear(adrs, ADRS_FORS_TREE);
+    set_key_pair(adrs, idx_leaf);
+    uint8_t node[N];
+    fors_pk_from_sig(p, sig + N, digest, &seeded, adrs, node);
+
+    // hypertree: each layer signs the root of the one below
+    const uint8_t *ht_sig = sig + N + fors_sig_len(p);
+    memset(adrs, 0, sizeof(adrs));
+    for (int j = 0; j < p->d; j++) {
+        if (j > 0) {
+            idx_leaf = (uint32_t)(idx_tree & (((uint64_t)1 << p->hp) - 1));
+            idx_tree >>= p->hp;
+        }
+        set_layer(adrs, (uint32_t)j);
+        set_tree(adrs, idx_tree);
+        xmss_pk_from_sig(p, idx_leaf, ht_sig + (size_t)j * xmss_sig_len(p), node, &seeded, adrs);
+    }
+    return mem_cmp(node, pk + N, N) == 0;
+}
@@ -0,0 +1,20 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+// SLH-DSA parameter sets, matching internal/crypto/slhdsa.go
+#define SLHDSA_SHA2_128S 1
+#define SLHDSA_SHA2_128F 2
+
+#define SLHDSA_PK_LEN 32
+
+// Signature length of a parameter set, 0 if it is unknown.
+size_t slhdsa_sig_len(uint8_t param_set);
+
+// SLH-DSA (FIPS 205) verification of a pure signature with an empty
+// context string. pk is PK.seed || PK.root.
+// Returns 1 if valid, 0 otherwise.
+int slhdsa_verify(uint8_t param_set, const uint8_t *msg, size_t msg_len,
+                  const uint8_t *sig, size_t sig_len,
+                  const uint8_t pk[SLHDSA_PK_LEN]);
@@ -0,0 +1,210 @@
+#include "sshsig.h"
+#include "ed25519.h"
+#include "sha256.h"
+#include "sha512.h"
+#include "hash.h"
+#include "p256/p256.h"
+#include "mem.h"
+#include <string.h>
+
+#define KEY_TYPE_ECDSA   "ecdsa-sha2-nistp256"
+#define KEY_TYPE_ED25519 "ssh-ed25519"
+#define MAGIC            "SSHSIG"
+#define MAGIC_LEN        6
+#define VERSION          1
+
+// Reads the length-prefixed strings of the SSH wire format; ok drops to 0
+// on the first read past the end.
+typedef struct {
+    const uint8_t *p;
+    size_t left;
+    int ok;
+} reader_t;
+
+static const uint8_t *read_bytes(reader_t *r, size_t n) {
+    if (!r->ok || r->left < n) {
+        r->ok = 0;
+        return NULL;
+    }
+    const uint8_t *out = r->p;
+    r->p += n;
+    r->left -= n;
+    return out;
+}
+
+static uint32_t read_uint32(reader_t *r) {
+    const uint8_t *b = read_bytes(r, 4);
+    if (b == NULL) return 0;
+    return (uint32_t)b[0] << 24 | (uint32_t)b[1] << 16 | (uint32_t)b[2] << 8 | b[3];
+}
+
+static const uint8_t *read_string(reader_t *r, size_t *len) {
+    *len = read_uint32(r);
+    return read_bytes(r, *len);
+}
+
+static int done(const reader_t *r) {
+    return r->ok && r->left == 0;
+}
+
+static int is(const uint8_t *s, size_t len, const char *want) {
+    if (s == NULL) return 0;
+    for (size_t i = 0; i < len; i++) {
+        if (want[i] == '\0' || s[i] != (uint8_t)want[i]) return 0;
+    }
+    return want[len] == '\0';
+}
+
+// Splits a key into its type (1 for ECDSA, 2 for Ed25519) and the point,
+// or returns 0 if it is malformed.
+static int parse_key(const uint8_t *key, size_t key_len, const uint8_t **point) {
+    reader_t r = {key, key_len, 1};
+    size_t type_len, curve_len, point_len;
+    const uint8_t *type = read_string(&r, &type_len);
+    if (is(type, type_len, KEY_TYPE_ECDSA)) {
+        const uint8_t *curve = read_string(&r, &curve_len);
+        *point = read_string(&r, &point_len);
+        if (!done(&r) || !is(curve, curve_len, "nistp256") || point_len != 65 || (*point)[0] != 0x04) return 0;
+        return 1;
+    }
+    if (is(type, type_len, KEY_TYPE_ED25519)) {
+        *point = read_string(&r, &point_len);
+        if (!done(&r) || point_len != ED25519_PK_LEN) return 0;
+        return 2;
+    }
+    return 0;
+}
+
+int sshsig_key_check(const uint8_t *key, size_t key_len) {
+    const uint8_t *point;
+    return parse_key(key, key_len, &point) != 0 ? 0 : -1;
+}
+
+// The signed data goes to SHA-256 for ECDSA and into the Ed25519 hash.
+typedef struct {
+    int ed25519;
+    sha256_ctx_t sha256;
+    sha512_ctx_t sha512;
+} signed_data_t;
+
+static void signed_update(signed_data_t *s, const uint8_t *data, size_t len) {
+    if (s->ed25519) {
+        sha512_update(&s->sha512, data, len);
+    } else {
+        sha256_update(&s->sha256, data, len);
+    }
+}
+
+static void signed_string(signed_data_t *s, const uint8_t *data, size_t len) {
+    uint8_t be[4] = {(uint8_t)(len >> 24), (uint8_t)(len >> 16), (uint8_t)(len >> 8), (uint8_t)len};
+    signed_update(s, be, 4);
+    signed_update(s, data, len);
+}
+
+// Reads an SSH mpint into 32 big-endian bytes. Leading zeros are allowed, as
+// in OpenSSH; negative values are not.
+static int read_mpint(const uint8_t *b, size_t len, uint8_t out[32]) {
+    if (len > 0 && (b[0] & 0x80) != 0) return -1;
+    while (len > 0 && b[0] == 0) {
+        b++;
+        len--;
+    }
+    if (len > 32) return -1;
+    memset(out, 0, 32);
+    memcpy(out + 32 - len, b, len);
+    return 0;
+}
+
+// The fields of a signature blob.
+typedef struct {
+    const uint8_t *signer, *ns, *reserved, *hash_alg, *signature;
+    size_t signer_len, ns_len, reserved_len, hash_alg_len, signature_len;
+} blob_t;
+
+// Splits a signature blob into its fields. Returns 0 if it is well formed.
+static int parse_blob(const uint8_t *blob, size_t blob_len, blob_t *b) {
+    reader_t r = {blob, blob_len, 1};
+    const uint8_t *magic = read_bytes(&r, MAGIC_LEN);
+    uint32_t version = read_uint32(&r);
+    b->signer = read_string(&r, &b->signer_len);
+    b->ns = read_string(&r, &b->ns_len);
+    b->reserved = read_string(&r, &b->reserved_len);
+    b->hash_alg = read_string(&r, &b->hash_alg_len);
+    b->signature = read_string(&r, &b->signature_len);
+    if (!done(&r) || mem_cmp(magic, MAGIC, MAGIC_LEN) != 0 || version != VERSION) return -1;
+    return 0;
+}
+
+static uint8_t blob_hash_alg(const blob_t *b) {
+    if (is(b->hash_alg, b->hash_alg_len, "sha256")) return HASH_SHA256;
+    if (is(b->hash_alg, b->hash_alg_len, "sha512")) return HASH_SHA512;
+    return 0;
+}
+
+uint8_t sshsig_hash_alg(const uint8_t *blob, size_t blob_len) {
+    blob_t b;
+    if (parse_blob(blob, blob_len, &b) != 0) return 0;
+    return blob_hash_alg(&b);
+}
+
+int sshsig_verify(const uint8_t *msg, size_t msg_len,
+                  const uint8_t *key, size_t key_len,
+                  const uint8_t *ns, size_t ns_len,
+                  const uint8_t *blob, size_t blob_len) {
+    uint8_t alg = sshsig_hash_alg(blob, blob_len);
+    if (alg == 0) return 0;
+    uint8_t msg_hash[SHA512_DIGEST_LEN];
+    hash_digest(alg, msg, msg_len, msg_hash);
+    return sshsig_verify_digest(msg_hash, key, key_len, ns, ns_len, blob, blob_len);
+}
+
+int sshsig_verify_digest(const uint8_t *msg_hash,
+                         const uint8_t *key, size_t key_len,
+                         const uint8_t *ns, size_t ns_len,
+                         const uint8_t *blob, size_t blob_len) {
+    const uint8_t *point;
+    int key_type = parse_key(key, key_len, &point);
+    if (key_type == 0) return 0;
+
+    blob_t b;
+    if (parse_blob(blob, blob_len, &b) != 0) return 0;
+    if (b.signer_len != key_len || mem_cmp(b.signer, key, key_len) != 0) return 0;
+    if (b.ns_len != ns_len || mem_cmp(b.ns, ns, ns_len) != 0) return 0;
+    uint8_t alg = blob_hash_alg(&b);
+    if (alg == 0) return 0;
+
+    reader_t r = {b.signature, b.signature_len, 1};
+    size_t sig_type_len, sig_len;
+    const uint8_t *sig_type = read_string(&r, &sig_type_len);
+    const uint8_t *sig = read_string(&r, &sig_len);
+    if (!done(&r)) return 0;
+    if (!is(sig_type, sig_type_len, key_type == 1 ? KEY_TYPE_ECDSA : KEY_TYPE_ED25519)) return 0;
+
+    signed_data_t s;
+    s.ed25519 = key_type == 2;
+    if (s.ed25519) {
+        if (sig_len != ED25519_SIG_LEN) return 0;
+        ed25519_verify_init(&s.sha512, sig, point);
+    } else {
+        sha256_init(&s.sha256);
+    }
+    signed_update(&s, (const uint8_t *)MAGIC, MAGIC_LEN);
+    signed_string(&s, ns, ns_len);
+    signed_string(&s, b.reserved, b.reserved_len);
+    signed_string(&s, b.hash_alg, b.hash_alg_len);
+    signed_string(&s, msg_hash, hash_size(alg));
+
+    if (s.ed25519) {
+        return ed25519_verify_final(&s.sha512, sig, point);
+    }
+
+    uint8_t raw_sig[64], hash[SHA256_DIGEST_LEN];
+    size_t r_len, s_len;
+    r = (reader_t){sig, sig_len, 1};
+    const uint8_t *r_bytes = read_string(&r, &r_len);
+    const uint8_t *s_bytes = read_string(&r, &s_len);
+    if (!done(&r)) return 0;
+    if (read_mpint(r_bytes, r_len, raw_sig) != 0 || read_mpint(s_bytes, s_len, raw_sig + 32) != 0) return 0;
+    sha256_final(&s.sha256, hash);
+    return p256_verify_digest(hash, raw_sig, point) == P256_SUCCESS;
+}
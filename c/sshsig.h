@@ -0,0 +1,28 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+// Checks an SSH wire-format public key, matching
+// crypto.CheckSSHPublicKey: ecdsa-sha2-nistp256 with an uncompressed point,
+// or ssh-ed25519. Returns 0 if it is well formed.
+int sshsig_key_check(const uint8_t *key, size_t key_len);
+
+// Verifies an ssh-keygen -Y sign signature blob (PROTOCOL.sshsig) over msg
+// by key, which passed sshsig_key_check, in namespace ns. The message hash
+// may be SHA-256 or SHA-512. Returns 1 if valid, 0 otherwise.
+int sshsig_verify(const uint8_t *msg, size_t msg_len,
+                  const uint8_t *key, size_t key_len,
+                  const uint8_t *ns, size_t ns_len,
+                  const uint8_t *blob, size_t blob_len);
+
+// The HASH_* algorithm (HASH_SHA256 or HASH_SHA512) a signature blob hashes
+// the message with, 0 if the blob is malformed or names another one.
+uint8_t sshsig_hash_alg(const uint8_t *blob, size_t blob_len);
+
+// sshsig_verify given the digest of the message with
+// sshsig_hash_alg(blob), computed by the caller.
+int sshsig_verify_digest(const uint8_t *msg_hash,
+                         const uint8_t *key, size_t key_len,
+                         const uint8_t *ns, size_t ns_len,
+                         const uint8_t *blob, size_t blob_len);
@@ -13,10 +13,14 @@ int stack_push(xstack_t *st, uint8_t val) {
 }
 
 int stack_pop(xstack_t *st, uint8_t *val) {
-    if (st->top <= 0) {
+    return stack_read(st, &st->top, val);
+}
+
+int stack_read(const xstack_t *st, int *at, uint8_t *val) {
+    if (*at <= 0) {
         return -1; // stack underflow
     }
-    *val = st->s[--st->top];
+    *val = st->s[--*at];
     return 0;
 }
 
@@ -34,8 +38,12 @@ int stack_push_bytes(xstack_t *st, const uint8_t *buf, size_t len) {
 }
 
 int stack_pop_bytes(xstack_t *st, uint8_t *buf, size_t len) {
+    return stack_read_bytes(st, &st->top, buf, len);
+}
+
+int stack_read_bytes(const xstack_t *st, int *at, uint8_t *buf, size_t len) {
     for (size_t i = 0; i < len; i++) {
-        if (stack_pop(st, &buf[i]) != 0) {
+        if (stack_read(st, at, &buf[i]) != 0) {
             return -1;
         }
     }
@@ -43,8 +51,12 @@ int stack_pop_bytes(xstack_t *st, uint8_t *buf, size_t len) {
 }
 
 int stack_pop_pubkey_compressed(xstack_t *st, uint8_t *pk_out) {
+    return stack_read_pubkey_compressed(st, &st->top, pk_out);
+}
+
+int stack_read_pubkey_compressed(const xstack_t *st, int *at, uint8_t *pk_out) {
     // Pop 33 bytes (LIFO order) matching Go's PopPublicKeyCompressed
-    if (stack_pop_bytes(st, pk_out, 33) != 0) {
+    if (stack_read_bytes(st, at, pk_out, 33) != 0) {
         return -1;
     }
     if (pk_out[0] != 0x02 && pk_out[0] != 0x03) {
@@ -54,9 +66,24 @@ int stack_pop_pubkey_compressed(xstack_t *st, uint8_t *pk_out) {
 }
 
 int stack_pop_signature(xstack_t *st, uint8_t *sig_out, size_t *sig_len) {
+    return stack_pop_signature_max(st, sig_out, sig_len, MAX_SIG_DER_LEN);
+}
+
+int stack_read_signature(const xstack_t *st, int *at, uint8_t *sig_out, size_t *sig_len) {
+    return stack_read_signature_max(st, at, sig_out, sig_len, MAX_SIG_DER_LEN);
+}
+
+// stack_pop_signature for signatures of up to max_len bytes, for curves
+// larger than 256 bits.
+int stack_pop_signature_max(xstack_t *st, uint8_t *sig_out, size_t *sig_len, size_t max_len) {
+    return stack_read_signature_max(st, &st->top, sig_out, sig_len, max_len);
+}
+
+int stack_read_signature_max(const xstack_t *st, int *at, uint8_t *sig_out,
+                             size_t *sig_len, size_t max_len) {
     // Parse DER: 0x30 || L1 || [L1 bytes]
     uint8_t marker;
-    if (stack_pop(st, &marker) != 0) {
+    if (stack_read(st, at, &marker) != 0) {
         return -1; // underflow
     }
     if (marker != 0x30) {
@@ -65,16 +92,16 @@ int stack_pop_signature(xstack_t *st, uint8_t *sig_out, size_t *sig_len) {
     sig_out[0] = marker;
 
     uint8_t sig_body_len;
-    if (stack_pop(st, &sig_body_len) != 0) {
+    if (stack_read(st, at, &sig_body_len) != 0) {
         return -1; // underflow
     }
-    if (2 + (size_t)sig_body_len > MAX_SIG_DER_LEN) {
+    if (2 + (size_t)sig_body_len > max_len) {
         return -3; // DER signature too long
     }
     sig_out[1] = sig_body_len;
 
     for (int i = 0; i < (int)sig_body_len; i++) {
-        if (stack_pop(st, &sig_out[2 + i]) != 0) {
+        if (stack_read(st, at, &sig_out[2 + i]) != 0) {
             return -1; // underflow
         }
     }
@@ -82,3 +109,91 @@ int stack_pop_signature(xstack_t *st, uint8_t *sig_out, size_t *sig_len) {
     *sig_len = 2 + (size_t)sig_body_len;
     return 0;
 }
+
+// Blobs are length-prefixed byte strings: the length is on top, followed by
+// buf[0], buf[1], ... Matches Go's PushBlob/PopBlob.
+int stack_push_blob(xstack_t *st, const uint8_t *buf, size_t len) {
+    if (len > MAX_BLOB_SIZE) {
+        return -1;
+    }
+    for (size_t i = len; i > 0; i--) {
+        if (stack_push(st, buf[i - 1]) != 0) {
+            return -1;
+        }
+    }
+    return stack_push(st, (uint8_t)len);
+}
+
+// buf must hold MAX_BLOB_SIZE bytes.
+int stack_pop_blob(xstack_t *st, uint8_t *buf, size_t *len) {
+    return stack_read_blob(st, &st->top, buf, len);
+}
+
+int stack_read_blob(const xstack_t *st, int *at, uint8_t *buf, size_t *len) {
+    uint8_t n;
+    if (stack_read(st, at, &n) != 0) {
+        return -1;
+    }
+    if (stack_read_bytes(st, at, buf, n) != 0) {
+        return -1;
+    }
+    *len = n;
+    return 0;
+}
+
+// Numbers: minimal little-endian two's complement, at most 8 bytes.
+// Matches Go's EncodeNum/DecodeNum.
+int stack_push_num(xstack_t *st, int64_t x) {
+    uint8_t buf[MAX_NUM_SIZE + 1];
+    size_t len = 0;
+    while (x != 0 && x != -1) {
+        buf[len++] = (uint8_t)(x & 0xFF);
+        x >>= 8; // arithmetic shift on every compiler we target
+    }
+    if (x == 0 && len > 0 && (buf[len - 1] & 0x80)) {
+        buf[len++] = 0x00;
+    }
+    if (x == -1 && (len == 0 || !(buf[len - 1] & 0x80))) {
+        buf[len++] = 0xFF;
+    }
+    return stack_push_blob(st, buf, len);
+}
+
+int stack_pop_num(xstack_t *st, int64_t *x) {
+    return stack_read_num(st, &st->top, x);
+}
+
+int stack_read_num(const xstack_t *st, int *at, int64_t *x) {
+    uint8_t buf[MAX_NUM_SIZE];
+    uint8_t n;
+    if (stack_read(st, at, &n) != 0) {
+        return -1;
+    }
+    if (n > MAX_NUM_SIZE) {
+        return -2; // number too long
+    }
+    size_t len = n;
+    if (stack_read_bytes(st, at, buf, len) != 0) {
+        return -1;
+    }
+    if (len == 0) {
+        *x = 0;
+        return 0;
+    }
+    uint8_t last = buf[len - 1];
+    if (len == 1 && last == 0x00) {
+        return -3; // not minimally encoded
+    }
+    if (len > 1) {
+        uint8_t sign_below = buf[len - 2] & 0x80;
+        if ((last == 0x00 && !sign_below) || (last == 0xFF && sign_below)) {
+            return -3; // not minimally encoded
+        }
+    }
+    uint64_t u = (last & 0x80) ? UINT64_MAX : 0; // sign-extend
+    for (size_t i = len; i > 0; i--) {
+        u = (u << 8) | buf[i - 1];
+    }
+    *x = (int64_t)u;
+    return 0;
+}
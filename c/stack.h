@@ -2,9 +2,13 @@
 
 #include <stdint.h>
 #include <stddef.h>
+#include "config.h"
 
-#define MAX_STACK_SIZE 1024
+#define MAX_STACK_SIZE XSIG_MAX_STACK_SIZE
 #define MAX_SIG_DER_LEN 74
+#define MAX_SIG_DER_LEN_P384 104
+#define MAX_BLOB_SIZE 255
+#define MAX_NUM_SIZE 8
 
 typedef struct {
     uint8_t s[MAX_STACK_SIZE];
@@ -19,3 +23,22 @@ int stack_push_bytes(xstack_t *st, const uint8_t *buf, size_t len);
 int stack_pop_bytes(xstack_t *st, uint8_t *buf, size_t len);
 int stack_pop_pubkey_compressed(xstack_t *st, uint8_t *pk_out);
 int stack_pop_signature(xstack_t *st, uint8_t *sig_out, size_t *sig_len);
+int stack_pop_signature_max(xstack_t *st, uint8_t *sig_out, size_t *sig_len, size_t max_len);
+int stack_push_blob(xstack_t *st, const uint8_t *buf, size_t len);
+int stack_pop_blob(xstack_t *st, uint8_t *buf, size_t *len);
+int stack_push_num(xstack_t *st, int64_t x);
+int stack_pop_num(xstack_t *st, int64_t *x);
+
+// Popping leaves the bytes in s[] until something is pushed over them, so
+// an opcode can pop all its operands, then read each back when it needs it
+// instead of keeping a copy of all of them. stack_read_* read a value the
+// way stack_pop_* would with the stack top at *at, and move *at past it
+// (stack_pop_* are stack_read_* at &st->top).
+int stack_read(const xstack_t *st, int *at, uint8_t *val);
+int stack_read_bytes(const xstack_t *st, int *at, uint8_t *buf, size_t len);
+int stack_read_pubkey_compressed(const xstack_t *st, int *at, uint8_t *pk_out);
+int stack_read_signature(const xstack_t *st, int *at, uint8_t *sig_out, size_t *sig_len);
+int stack_read_signature_max(const xstack_t *st, int *at, uint8_t *sig_out,
+                             size_t *sig_len, size_t max_len);
+int stack_read_blob(const xstack_t *st, int *at, uint8_t *buf, size_t *len);
+int stack_read_num(const xstack_t *st, int *at, int64_t *x);
@@ -0,0 +1,152 @@
+// Tests of the embedded profile, built with -DXSIG_EMBEDDED against the
+// freestanding xsig_embedded.o (make test-embedded):
+//   - the worst-case RAM stated in the README holds: run_machine001_ctx and
+//     eval_with_ctx are run over every test vector on a thread stack painted
+//     with a pattern, and the deepest byte overwritten is measured;
+//   - the lower limits only ever reject: C never accepts a vector Go
+//     rejects, and an evaluation that succeeds leaves Go's stack.
+#define _POSIX_C_SOURCE 200112L
+#include <pthread.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include "eval.h"
+#include "xsig.h"
+#include "test_vectors.h"
+
+// Worst-case RAM of an evaluation in the embedded profile, as documented in
+// the README: the C stack of run_machine001_ctx, its eval_t included. The
+// library has no heap and no writable static data.
+#define DOCUMENTED_STACK_BYTES 8192
+// RAM of the incremental digests of a streamed message, outside of that.
+#define DOCUMENTED_DIGESTS_BYTES 1280
+
+#define THREAD_STACK_SIZE (1 << 20)
+#define PAINT 0xa5
+
+static uint8_t thread_stack[THREAD_STACK_SIZE] __attribute__((aligned(64)));
+
+typedef struct {
+    const uint8_t *fingerprints;
+    size_t len;
+} revoked_list_t;
+
+static int is_revoked(const uint8_t fingerprint[32], void *arg) {
+    const revoked_list_t *list = arg;
+    for (size_t i = 0; i + 32 <= list->len; i += 32) {
+        if (memcmp(list->fingerprints + i, fingerprint, 32) == 0) return 1;
+    }
+    return 0;
+}
+
+static void set_context(eval_ctx_t *ctx, revoked_list_t *revoked, const uint8_t *msg,
+                        size_t msg_len, int64_t security_version, const uint8_t *nonce,
+                        size_t nonce_len, int prehashed, const uint8_t *digests,
+                        size_t digests_len) {
+    memset(ctx, 0, sizeof(*ctx));
+    ctx->msg = msg;
+    ctx->msg_len = msg_len;
+    ctx->security_version = security_version;
+    ctx->is_revoked = is_revoked;
+    ctx->revoked_arg = revoked;
+    ctx->nonce = nonce;
+    ctx->nonce_len = nonce_len;
+    ctx->prehashed = prehashed;
+    for (size_t i = 0; i < digests_len; i += 1 + hash_size(digests[i])) {
+        ctx->digests[digests[i]] = digests + i + 1;
+    }
+}
+
+static int failures;
+static int accepted;
+
+static void run_eval_vectors(void) {
+    for (int i = 0; i < NUM_EVAL_TESTS; i++) {
+        const eval_tv_t *tv = &eval_tests[i];
+        revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+        eval_ctx_t ctx;
+        set_context(&ctx, &revoked, tv->msg, tv->msg_len, tv->security_version,
+                    tv->nonce, tv->nonce_len, tv->prehashed, tv->digests, tv->digests_len);
+        eval_t e;
+        eval_init(&e);
+        if (eval_with_ctx(&e, tv->code, tv->code_len, &ctx) != 0) continue;
+        accepted++;
+        if (tv->expect_error || (size_t)e.stack.top != tv->expect_stack_len ||
+            memcmp(e.stack.s, tv->expect_stack, tv->expect_stack_len) != 0) {
+            printf("FAIL: %s — succeeded with a stack Go does not have\n", tv->name);
+            failures++;
+        }
+    }
+}
+
+static void run_m001_vectors(void) {
+    for (int i = 0; i < NUM_M001_TESTS; i++) {
+        const m001_tv_t *tv = &m001_tests[i];
+        revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+        eval_ctx_t ctx;
+        set_context(&ctx, &revoked, tv->msg, tv->msg_len, tv->security_version,
+                    tv->nonce, tv->nonce_len, tv->prehashed, tv->digests, tv->digests_len);
+        if (run_machine001_ctx(tv->xpubkey, tv->xpubkey_len, tv->xsig, tv->xsig_len, &ctx) != 1) {
+            continue;
+        }
+        accepted++;
+        if (tv->expected != 1) {
+            printf("FAIL: %s — accepted, Go rejects it\n", tv->name);
+            failures++;
+        }
+    }
+}
+
+static void *run_nothing(void *arg) {
+    return arg;
+}
+
+static void *run_vectors(void *arg) {
+    run_eval_vectors();
+    run_m001_vectors();
+    return arg;
+}
+
+// Runs f on thread_stack and returns how many bytes of it were written.
+static size_t stack_used(void *(*f)(void *)) {
+    memset(thread_stack, PAINT, sizeof(thread_stack));
+    pthread_attr_t attr;
+    pthread_t thread;
+    if (pthread_attr_init(&attr) != 0 ||
+        pthread_attr_setstack(&attr, thread_stack, sizeof(thread_stack)) != 0 ||
+        pthread_create(&thread, &attr, f, NULL) != 0 ||
+        pthread_join(thread, NULL) != 0) {
+        printf("FAIL: cannot run a thread\n");
+        exit(1);
+    }
+    // the stack grows down: untouched bytes are at the start
+    size_t untouched = 0;
+    while (untouched < sizeof(thread_stack) && thread_stack[untouched] == PAINT) untouched++;
+    return sizeof(thread_stack) - untouched;
+}
+
+int main(void) {
+    // The thread itself (e.g. its TLS, kept at the top of its stack) is not
+    // the library's.
+    size_t overhead = stack_used(run_nothing);
+    size_t used = stack_used(run_vectors) - overhead;
+
+    printf("=== Embedded profile (stack %d bytes, %d keys) ===\n",
+           XSIG_MAX_STACK_SIZE, XSIG_MAX_KEYS);
+    printf("Accepted:   %d of %d vectors\n",
+           accepted, NUM_EVAL_TESTS + NUM_M001_TESTS);
+    printf("eval_t:     %zu bytes\n", sizeof(eval_t));
+    printf("C stack:    %zu bytes (documented: %d)\n", used, DOCUMENTED_STACK_BYTES);
+    printf("Digests:    %zu bytes (documented: %d)\n", sizeof(xsig_digests_t),
+           DOCUMENTED_DIGESTS_BYTES);
+
+    if (used > DOCUMENTED_STACK_BYTES) {
+        printf("FAIL: C stack over the documented worst case\n");
+        failures++;
+    }
+    if (sizeof(xsig_digests_t) > DOCUMENTED_DIGESTS_BYTES) {
+        printf("FAIL: xsig_digests_t over the documented size\n");
+        failures++;
+    }
+    return failures > 0 ? 1 : 0;
+}
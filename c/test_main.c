@@ -4,10 +4,43 @@
 #include "xsig.h"
 #include "test_vectors.h"
 
+// Fingerprints revoked by a test vector, 32 bytes each.
+typedef struct {
+    const uint8_t *fingerprints;
+    size_t len;
+} revoked_list_t;
+
+static int is_revoked(const uint8_t fingerprint[32], void *arg) {
+    const revoked_list_t *list = arg;
+    for (size_t i = 0; i + 32 <= list->len; i += 32) {
+        if (memcmp(list->fingerprints + i, fingerprint, 32) == 0) return 1;
+    }
+    return 0;
+}
+
+// Points ctx->digests into a vector's alg || digest entries.
+static void set_digests(eval_ctx_t *ctx, int prehashed, const uint8_t *digests, size_t len) {
+    ctx->prehashed = prehashed;
+    for (size_t i = 0; i < len; i += 1 + hash_size(digests[i])) {
+        ctx->digests[digests[i]] = digests + i + 1;
+    }
+}
+
 static int run_eval_test(const eval_tv_t *tv) {
     eval_t e;
     eval_init(&e);
-    int ret = eval_with_xmsg(&e, tv->code, tv->code_len, tv->msg, tv->msg_len);
+    eval_ctx_t ctx;
+    memset(&ctx, 0, sizeof(ctx));
+    ctx.msg = tv->msg;
+    ctx.msg_len = tv->msg_len;
+    ctx.security_version = tv->security_version;
+    revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+    ctx.is_revoked = is_revoked;
+    ctx.revoked_arg = &revoked;
+    ctx.nonce = tv->nonce;
+    ctx.nonce_len = tv->nonce_len;
+    set_digests(&ctx, tv->prehashed, tv->digests, tv->digests_len);
+    int ret = eval_with_ctx(&e, tv->code, tv->code_len, &ctx);
 
     if (tv->expect_error) {
         if (ret == 0) {
@@ -44,9 +77,19 @@ static int run_eval_test(const eval_tv_t *tv) {
 }
 
 static int run_m001_test(const m001_tv_t *tv) {
-    int result = run_machine001(tv->xpubkey, tv->xpubkey_len,
-                                tv->xsig, tv->xsig_len,
-                                tv->msg, tv->msg_len);
+    eval_ctx_t ctx;
+    memset(&ctx, 0, sizeof(ctx));
+    ctx.msg = tv->msg;
+    ctx.msg_len = tv->msg_len;
+    ctx.security_version = tv->security_version;
+    revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+    ctx.is_revoked = is_revoked;
+    ctx.revoked_arg = &revoked;
+    ctx.nonce = tv->nonce;
+    ctx.nonce_len = tv->nonce_len;
+    set_digests(&ctx, tv->prehashed, tv->digests, tv->digests_len);
+    int result = run_machine001_ctx(tv->xpubkey, tv->xpubkey_len,
+                                    tv->xsig, tv->xsig_len, &ctx);
     if (result != tv->expected) {
         printf("FAIL: %s — got %d, expected %d\n", tv->name, result, tv->expected);
         return 1;
@@ -54,10 +97,44 @@ static int run_m001_test(const m001_tv_t *tv) {
     return 0;
 }
 
+// Verifies a machine001 vector again with the message fed in small chunks
+// to xsig_digests_t, as a verifier streaming it from flash would. Vectors
+// whose xpubkey needs the message itself are skipped. Returns 1 on failure,
+// -1 if skipped.
+static int run_m001_stream_test(const m001_tv_t *tv) {
+    xsig_digests_t d;
+    if (tv->prehashed || xsig_digests_init(&d, tv->xpubkey, tv->xpubkey_len) != 0) {
+        return -1;
+    }
+    for (size_t i = 0; i < tv->msg_len; i += 7) {
+        size_t n = tv->msg_len - i < 7 ? tv->msg_len - i : 7;
+        xsig_digests_update(&d, tv->msg + i, n);
+    }
+
+    eval_ctx_t ctx;
+    memset(&ctx, 0, sizeof(ctx));
+    ctx.security_version = tv->security_version;
+    revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+    ctx.is_revoked = is_revoked;
+    ctx.revoked_arg = &revoked;
+    ctx.nonce = tv->nonce;
+    ctx.nonce_len = tv->nonce_len;
+    xsig_digests_final(&d, &ctx);
+    int result = run_machine001_ctx(tv->xpubkey, tv->xpubkey_len,
+                                    tv->xsig, tv->xsig_len, &ctx);
+    if (result != tv->expected) {
+        printf("FAIL: %s (streamed) — got %d, expected %d\n", tv->name, result, tv->expected);
+        return 1;
+    }
+    return 0;
+}
+
 int main(void) {
     int failures = 0;
     int eval_failures = 0;
     int m001_failures = 0;
+    int stream_failures = 0;
+    int stream_tests = 0;
 
     printf("=== Eval Tests (%d) ===\n", NUM_EVAL_TESTS);
     for (int i = 0; i < NUM_EVAL_TESTS; i++) {
@@ -69,11 +146,19 @@ int main(void) {
         m001_failures += run_m001_test(&m001_tests[i]);
     }
 
-    failures = eval_failures + m001_failures;
-    int total = NUM_EVAL_TESTS + NUM_M001_TESTS;
+    for (int i = 0; i < NUM_M001_TESTS; i++) {
+        int ret = run_m001_stream_test(&m001_tests[i]);
+        if (ret < 0) continue;
+        stream_tests++;
+        stream_failures += ret;
+    }
+
+    failures = eval_failures + m001_failures + stream_failures;
+    int total = NUM_EVAL_TESTS + NUM_M001_TESTS + stream_tests;
 
     printf("\nEval:       %d/%d passed\n", NUM_EVAL_TESTS - eval_failures, NUM_EVAL_TESTS);
     printf("Machine001: %d/%d passed\n", NUM_M001_TESTS - m001_failures, NUM_M001_TESTS);
+    printf("Streamed:   %d/%d passed\n", stream_tests - stream_failures, stream_tests);
     printf("Total:      %d/%d passed\n", total - failures, total);
 
     return failures > 0 ? 1 : 0;
@@ -0,0 +1,103 @@
+// Tests of the build without the DER parser (-DXSIG_NO_DER, make
+// test-noder): the raw signature vectors, which never need DER, give the
+// same results as Go, and a DER signature never verifies.
+#include <stdio.h>
+#include <string.h>
+#include "eval.h"
+#include "xsig.h"
+#include "test_vectors.h"
+
+static int is_raw(const char *name) {
+    return strncmp(name, "rawsig_", 7) == 0 || strncmp(name, "rawmultisig_", 12) == 0 ||
+           strncmp(name, "m001_rawsig_", 12) == 0;
+}
+
+typedef struct {
+    const uint8_t *fingerprints;
+    size_t len;
+} revoked_list_t;
+
+static int is_revoked(const uint8_t fingerprint[32], void *arg) {
+    const revoked_list_t *list = arg;
+    for (size_t i = 0; i + 32 <= list->len; i += 32) {
+        if (memcmp(list->fingerprints + i, fingerprint, 32) == 0) return 1;
+    }
+    return 0;
+}
+
+static void set_context(eval_ctx_t *ctx, revoked_list_t *revoked, const uint8_t *msg,
+                        size_t msg_len, int64_t security_version, const uint8_t *nonce,
+                        size_t nonce_len, int prehashed, const uint8_t *digests,
+                        size_t digests_len) {
+    memset(ctx, 0, sizeof(*ctx));
+    ctx->msg = msg;
+    ctx->msg_len = msg_len;
+    ctx->security_version = security_version;
+    ctx->is_revoked = is_revoked;
+    ctx->revoked_arg = revoked;
+    ctx->nonce = nonce;
+    ctx->nonce_len = nonce_len;
+    ctx->prehashed = prehashed;
+    for (size_t i = 0; i < digests_len; i += 1 + hash_size(digests[i])) {
+        ctx->digests[digests[i]] = digests + i + 1;
+    }
+}
+
+int main(void) {
+    int failures = 0;
+    int tests = 0;
+
+    for (int i = 0; i < NUM_EVAL_TESTS; i++) {
+        const eval_tv_t *tv = &eval_tests[i];
+        if (!is_raw(tv->name)) continue;
+        tests++;
+        revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+        eval_ctx_t ctx;
+        set_context(&ctx, &revoked, tv->msg, tv->msg_len, tv->security_version,
+                    tv->nonce, tv->nonce_len, tv->prehashed, tv->digests, tv->digests_len);
+        eval_t e;
+        eval_init(&e);
+        int ret = eval_with_ctx(&e, tv->code, tv->code_len, &ctx);
+        if ((ret != 0) != tv->expect_error ||
+            (ret == 0 && ((size_t)e.stack.top != tv->expect_stack_len ||
+                          memcmp(e.stack.s, tv->expect_stack, tv->expect_stack_len) != 0))) {
+            printf("FAIL: %s — differs from Go without DER\n", tv->name);
+            failures++;
+        }
+    }
+
+    for (int i = 0; i < NUM_M001_TESTS; i++) {
+        const m001_tv_t *tv = &m001_tests[i];
+        if (!is_raw(tv->name)) continue;
+        tests++;
+        revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+        eval_ctx_t ctx;
+        set_context(&ctx, &revoked, tv->msg, tv->msg_len, tv->security_version,
+                    tv->nonce, tv->nonce_len, tv->prehashed, tv->digests, tv->digests_len);
+        int result = run_machine001_ctx(tv->xpubkey, tv->xpubkey_len, tv->xsig, tv->xsig_len, &ctx);
+        if (result != tv->expected) {
+            printf("FAIL: %s — got %d, expected %d without DER\n", tv->name, result, tv->expected);
+            failures++;
+        }
+    }
+
+    // a valid DER signature is malformed here: OP_SIGVERIFY fails on it
+    for (int i = 0; i < NUM_EVAL_TESTS; i++) {
+        const eval_tv_t *tv = &eval_tests[i];
+        if (strcmp(tv->name, "sigverify_valid") != 0) continue;
+        tests++;
+        revoked_list_t revoked = {tv->revoked, tv->revoked_len};
+        eval_ctx_t ctx;
+        set_context(&ctx, &revoked, tv->msg, tv->msg_len, tv->security_version,
+                    tv->nonce, tv->nonce_len, tv->prehashed, tv->digests, tv->digests_len);
+        eval_t e;
+        eval_init(&e);
+        if (eval_with_ctx(&e, tv->code, tv->code_len, &ctx) == 0) {
+            printf("FAIL: %s — DER signature accepted without DER\n", tv->name);
+            failures++;
+        }
+    }
+
+    printf("=== No DER: %d/%d passed ===\n", tests - failures, tests);
+    return failures > 0 || tests == 0 ? 1 : 0;
+}
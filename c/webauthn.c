@@ -0,0 +1,76 @@
+#include "webauthn.h"
+#include "sha256.h"
+#include "p256/p256.h"
+#include "mem.h"
+#include <string.h>
+
+// RP ID hash, flags and the 4-byte signature counter
+#define AUTH_DATA_MIN_LEN (WEBAUTHN_RP_ID_HASH_LEN + 1 + 4)
+
+#define CLIENT_DATA_HEAD "{\"type\":\"webauthn.get\",\"challenge\":\""
+#define CLIENT_DATA_HEAD_LEN (sizeof(CLIENT_DATA_HEAD) - 1)
+#define CHALLENGE_B64_LEN 43 // 32 bytes in base64url, no padding
+
+static const char b64url[] =
+    "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_";
+
+// base64url without padding of the 32-byte challenge.
+static void encode_challenge(const uint8_t in[SHA256_DIGEST_LEN], char out[CHALLENGE_B64_LEN]) {
+    size_t o = 0;
+    for (size_t i = 0; i < SHA256_DIGEST_LEN; i += 3) {
+        uint32_t v = (uint32_t)in[i] << 16;
+        if (i + 1 < SHA256_DIGEST_LEN) v |= (uint32_t)in[i + 1] << 8;
+        if (i + 2 < SHA256_DIGEST_LEN) v |= in[i + 2];
+        out[o++] = b64url[(v >> 18) & 63];
+        out[o++] = b64url[(v >> 12) & 63];
+        if (o < CHALLENGE_B64_LEN) out[o++] = b64url[(v >> 6) & 63];
+        if (o < CHALLENGE_B64_LEN) out[o++] = b64url[v & 63];
+    }
+}
+
+// The challenge is SHA-256 of the message.
+static int client_data_check(const uint8_t challenge[SHA256_DIGEST_LEN],
+                             const uint8_t *client_data, size_t client_data_len) {
+    if (client_data_len < CLIENT_DATA_HEAD_LEN + CHALLENGE_B64_LEN + 1) return 0;
+    if (mem_cmp(client_data, CLIENT_DATA_HEAD, CLIENT_DATA_HEAD_LEN) != 0) return 0;
+
+    char encoded[CHALLENGE_B64_LEN];
+    encode_challenge(challenge, encoded);
+    if (mem_cmp(client_data + CLIENT_DATA_HEAD_LEN, encoded, CHALLENGE_B64_LEN) != 0) return 0;
+    return client_data[CLIENT_DATA_HEAD_LEN + CHALLENGE_B64_LEN] == '"';
+}
+
+int webauthn_verify(const uint8_t *msg, size_t msg_len, const uint8_t pk[33],
+                    const uint8_t rp_id_hash[WEBAUTHN_RP_ID_HASH_LEN], uint8_t flags,
+                    const uint8_t *auth_data, size_t auth_data_len,
+                    const uint8_t *client_data, size_t client_data_len,
+                    const uint8_t raw_sig[64]) {
+    uint8_t msg_hash[SHA256_DIGEST_LEN];
+    sha256(msg, msg_len, msg_hash);
+    return webauthn_verify_digest(msg_hash, pk, rp_id_hash, flags, auth_data, auth_data_len,
+                                  client_data, client_data_len, raw_sig);
+}
+
+int webauthn_verify_digest(const uint8_t msg_hash[32], const uint8_t pk[33],
+                           const uint8_t rp_id_hash[WEBAUTHN_RP_ID_HASH_LEN], uint8_t flags,
+                           const uint8_t *auth_data, size_t auth_data_len,
+                           const uint8_t *client_data, size_t client_data_len,
+                           const uint8_t raw_sig[64]) {
+    if (auth_data_len < AUTH_DATA_MIN_LEN) return 0;
+    if (mem_cmp(auth_data, rp_id_hash, WEBAUTHN_RP_ID_HASH_LEN) != 0) return 0;
+    flags |= WEBAUTHN_USER_PRESENT;
+    if ((auth_data[WEBAUTHN_RP_ID_HASH_LEN] & flags) != flags) return 0;
+    if (!client_data_check(msg_hash, client_data, client_data_len)) return 0;
+
+    uint8_t client_data_hash[SHA256_DIGEST_LEN], hash[SHA256_DIGEST_LEN];
+    sha256(client_data, client_data_len, client_data_hash);
+    sha256_ctx_t ctx;
+    sha256_init(&ctx);
+    sha256_update(&ctx, auth_data, auth_data_len);
+    sha256_update(&ctx, client_data_hash, SHA256_DIGEST_LEN);
+    sha256_final(&ctx, hash);
+
+    uint8_t sig[64];
+    memcpy(sig, raw_sig, 64);
+    return p256_verify_digest(hash, sig, pk) == P256_SUCCESS;
+}
@@ -0,0 +1,29 @@
+#pragma once
+
+#include <stdint.h>
+#include <stddef.h>
+
+// Authenticator data flags, matching internal/crypto/webauthn.go. User
+// presence is always required.
+#define WEBAUTHN_USER_PRESENT  0x01
+#define WEBAUTHN_USER_VERIFIED 0x04
+
+#define WEBAUTHN_RP_ID_HASH_LEN 32
+
+// Verifies a WebAuthn assertion over msg: the authenticator data is for
+// rp_id_hash with user presence and the requested flags, the client data
+// JSON starts with {"type":"webauthn.get","challenge":"<base64url of
+// SHA-256(msg)>" and raw_sig (r || s) is a P-256 signature by pk of
+// auth_data || SHA-256(client_data). Returns 1 if valid, 0 otherwise.
+int webauthn_verify(const uint8_t *msg, size_t msg_len, const uint8_t pk[33],
+                    const uint8_t rp_id_hash[WEBAUTHN_RP_ID_HASH_LEN], uint8_t flags,
+                    const uint8_t *auth_data, size_t auth_data_len,
+                    const uint8_t *client_data, size_t client_data_len,
+                    const uint8_t raw_sig[64]);
+
+// webauthn_verify given SHA-256(msg), computed by the caller.
+int webauthn_verify_digest(const uint8_t msg_hash[32], const uint8_t pk[33],
+                           const uint8_t rp_id_hash[WEBAUTHN_RP_ID_HASH_LEN], uint8_t flags,
+                           const uint8_t *auth_data, size_t auth_data_len,
+                           const uint8_t *client_data, size_t client_data_len,
+                           const uint8_t raw_sig[64]);
@@ -1,5 +1,6 @@
 #include "xsig.h"
 #include "eval.h"
+#include "mem.h"
 #include <string.h>
 
 // "xsig" + MachineType(0) + CodeType
@@ -11,7 +12,7 @@ static int deserialize(const uint8_t *data, size_t data_len,
                        const uint8_t *expected_prefix,
                        const uint8_t **code_out, size_t *code_len_out) {
     if (data_len < PREFIX_LEN) return -1;
-    if (memcmp(data, expected_prefix, PREFIX_LEN) != 0) return -1;
+    if (mem_cmp(data, expected_prefix, PREFIX_LEN) != 0) return -1;
     *code_out = data + PREFIX_LEN;
     *code_len_out = data_len - PREFIX_LEN;
     return 0;
@@ -20,6 +21,16 @@ static int deserialize(const uint8_t *data, size_t data_len,
 int run_machine001(const uint8_t *xpubkey, size_t xpubkey_len,
                    const uint8_t *xsig, size_t xsig_len,
                    const uint8_t *msg, size_t msg_len) {
+    eval_ctx_t ctx;
+    memset(&ctx, 0, sizeof(ctx));
+    ctx.msg = msg;
+    ctx.msg_len = msg_len;
+    return run_machine001_ctx(xpubkey, xpubkey_len, xsig, xsig_len, &ctx);
+}
+
+int run_machine001_ctx(const uint8_t *xpubkey, size_t xpubkey_len,
+                       const uint8_t *xsig, size_t xsig_len,
+                       const eval_ctx_t *ctx) {
     const uint8_t *code;
     size_t code_len;
 
@@ -34,18 +45,62 @@ int run_machine001(const uint8_t *xpubkey, size_t xpubkey_len,
         return 0;
     }
 
-    // Phase 2: transfer stack, deserialize and evaluate xpubkey with message
-    eval_t e2;
-    eval_init(&e2);
-    memcpy(&e2.stack, &e.stack, sizeof(xstack_t));
+    // Phase 2: keep the stack and objects, deserialize and evaluate xpubkey
+    // with context. The same eval_t is reused rather than copied, so that
+    // only one is ever in RAM.
+    e.alt_top = 0;
 
     if (deserialize(xpubkey, xpubkey_len, PREFIX_XPUBKEY, &code, &code_len) != 0) {
         return 0;
     }
-    if (eval_with_xmsg(&e2, code, code_len, msg, msg_len) != 0) {
+    if (eval_with_ctx(&e, code, code_len, ctx) != 0) {
         return 0;
     }
 
     // Final check: stack must be exactly [1]
-    return (e2.stack.top == 1 && e2.stack.s[0] == 1) ? 1 : 0;
+    return (e.stack.top == 1 && e.stack.s[0] == 1) ? 1 : 0;
+}
+
+int run_machine001_digest(const uint8_t *xpubkey, size_t xpubkey_len,
+                          const uint8_t *xsig, size_t xsig_len,
+                          const uint8_t hash[SHA256_DIGEST_LEN]) {
+    eval_ctx_t ctx;
+    memset(&ctx, 0, sizeof(ctx));
+    ctx.prehashed = 1;
+    ctx.digests[HASH_SHA256] = hash;
+    return run_machine001_ctx(xpubkey, xpubkey_len, xsig, xsig_len, &ctx);
+}
+
+int xsig_digests_init(xsig_digests_t *d, const uint8_t *xpubkey, size_t xpubkey_len) {
+    const uint8_t *code;
+    size_t code_len;
+    memset(d, 0, sizeof(*d));
+    if (deserialize(xpubkey, xpubkey_len, PREFIX_XPUBKEY, &code, &code_len) != 0) {
+        return -1;
+    }
+    if (eval_digest_algs(code, code_len, &d->algs) != 0) return -1;
+    if (d->algs & (1 << HASH_SHA256)) sha256_init(&d->sha256);
+    if (d->algs & (1 << HASH_SHA384)) sha384_init(&d->sha384);
+    if (d->algs & (1 << HASH_SHA512)) sha512_init(&d->sha512);
+    if (d->algs & (1 << HASH_SHA3_256)) sha3_256_init(&d->sha3_256);
+    return 0;
+}
+
+void xsig_digests_update(xsig_digests_t *d, const uint8_t *data, size_t len) {
+    if (d->algs & (1 << HASH_SHA256)) sha256_update(&d->sha256, data, len);
+    if (d->algs & (1 << HASH_SHA384)) sha512_update(&d->sha384, data, len);
+    if (d->algs & (1 << HASH_SHA512)) sha512_update(&d->sha512, data, len);
+    if (d->algs & (1 << HASH_SHA3_256)) keccak256_update(&d->sha3_256, data, len);
+}
+
+void xsig_digests_final(xsig_digests_t *d, eval_ctx_t *ctx) {
+    if (d->algs & (1 << HASH_SHA256)) sha256_final(&d->sha256, d->digests[HASH_SHA256]);
+    if (d->algs & (1 << HASH_SHA384)) sha384_final(&d->sha384, d->digests[HASH_SHA384]);
+    if (d->algs & (1 << HASH_SHA512)) sha512_final(&d->sha512, d->digests[HASH_SHA512]);
+    if (d->algs & (1 << HASH_SHA3_256)) keccak256_final(&d->sha3_256, d->digests[HASH_SHA3_256]);
+
+    ctx->prehashed = 1;
+    for (int alg = 1; alg <= HASH_MAX_ALG; alg++) {
+        ctx->digests[alg] = (d->algs & (1 << alg)) ? d->digests[alg] : NULL;
+    }
 }
@@ -2,9 +2,53 @@
 
 #include <stdint.h>
 #include <stddef.h>
+#include "eval.h"
+#include "keccak.h"
+#include "sha256.h"
+#include "sha512.h"
 
 // Evaluate an xsig machine001 program.
 // Returns 1 if verification succeeds (final stack == [1]), 0 otherwise.
 int run_machine001(const uint8_t *xpubkey, size_t xpubkey_len,
                    const uint8_t *xsig, size_t xsig_len,
                    const uint8_t *msg, size_t msg_len);
+
+// Same as run_machine001, with verifier-supplied state. Only the xpubkey
+// sees ctx; the xsig is evaluated with an empty context.
+int run_machine001_ctx(const uint8_t *xpubkey, size_t xpubkey_len,
+                       const uint8_t *xsig, size_t xsig_len,
+                       const eval_ctx_t *ctx);
+
+// Same as run_machine001, in prehashed mode with the SHA-256 digest of the
+// message (see eval_ctx_t.prehashed): enough for every signature opcode but
+// OP_SIGVERIFYHASH with another digest and sshsigs hashed with SHA-512.
+int run_machine001_digest(const uint8_t *xpubkey, size_t xpubkey_len,
+                          const uint8_t *xsig, size_t xsig_len,
+                          const uint8_t hash[SHA256_DIGEST_LEN]);
+
+// Incremental hashing of a message too large to hold in memory (e.g. a
+// firmware image read from flash), computing in one pass every digest the
+// xpubkey may ask for:
+//
+//     xsig_digests_t d;
+//     if (xsig_digests_init(&d, xpubkey, xpubkey_len) != 0) reject;
+//     for each chunk: xsig_digests_update(&d, chunk, chunk_len);
+//     eval_ctx_t ctx = {0};
+//     xsig_digests_final(&d, &ctx);
+//     valid = run_machine001_ctx(xpubkey, xpubkey_len, xsig, xsig_len, &ctx);
+typedef struct {
+    uint8_t algs; // bit (1 << alg) for each HASH_* digest computed
+    sha256_ctx_t sha256;
+    sha512_ctx_t sha384;
+    sha512_ctx_t sha512;
+    keccak256_ctx_t sha3_256;
+    uint8_t digests[HASH_MAX_ALG + 1][HASH_MAX_DIGEST_LEN];
+} xsig_digests_t;
+
+// Returns -1 if the xpubkey is malformed or needs the message itself (see
+// eval_digest_algs).
+int xsig_digests_init(xsig_digests_t *d, const uint8_t *xpubkey, size_t xpubkey_len);
+void xsig_digests_update(xsig_digests_t *d, const uint8_t *data, size_t len);
+// Finishes the digests and puts ctx in prehashed mode with them. ctx points
+// into d, which must outlive the evaluation; other fields are left alone.
+void xsig_digests_final(xsig_digests_t *d, eval_ctx_t *ctx);
@@ -80,7 +80,7 @@ func main() {
 	xPublicKey.Append(ll.Push1(2))
 	xPublicKey.Append(ll.Push1(3))
 	xPublicKey.Append(ll.MultisigVerify())
-	xPublicKeyCode := xPublicKey.Serialize(machines.CodeTypeXPublicKey)
+	xPublicKeyCode, err := xPublicKey.Serialize(machines.CodeTypeXPublicKey); check(err)
 
 	log.Printf("xpublickey: %x\n", xPublicKeyCode)
 
@@ -97,7 +97,7 @@ func main() {
 	xsig.Append(ll.Push(sig2))
 	_ = sig3
 
-	xSignatureCode := xsig.Serialize(machines.CodeTypeXSig)
+	xSignatureCode, err := xsig.Serialize(machines.CodeTypeXSig); check(err)
 
 	log.Printf("xsignature: %x\n", xSignatureCode)
 
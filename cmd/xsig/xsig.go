@@ -0,0 +1,88 @@
+// Command xsig verifies xsigs from the command line:
+//
+//	xsig verify -xpubkey HEX -xsig HEX [-file PATH]
+//
+// The xpubkey and the xsig are given in hex, as printed by cmd/demo, or as
+// @PATH to read the hex from a file. The message is read from -file, or from
+// standard input if it is missing or "-", and is streamed: it is hashed once
+// and never loaded in memory, so it can be e.g. a large disk image.
+//
+// The exit status is 0 if the xsig is valid, 1 if it is not and 2 on any
+// other error, such as an xpubkey that needs the whole message.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"github.com/oreparaz/xsig/pkg"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = "usage: xsig verify -xpubkey HEX|@PATH -xsig HEX|@PATH [-file PATH]"
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "verify" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	valid, err := verify(os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "xsig:", err)
+		os.Exit(2)
+	}
+	if !valid {
+		fmt.Println("invalid")
+		os.Exit(1)
+	}
+	fmt.Println("valid")
+}
+
+func verify(args []string) (bool, error) {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	xPubKeyArg := flags.String("xpubkey", "", "xpubkey in hex, or @PATH of a file holding it")
+	xSigArg := flags.String("xsig", "", "xsig in hex, or @PATH of a file holding it")
+	file := flags.String("file", "-", "message to verify; - is standard input")
+	err := flags.Parse(args)
+	if err != nil {
+		return false, err
+	}
+	if *xPubKeyArg == "" || *xSigArg == "" || flags.NArg() != 0 {
+		return false, errors.New(usage)
+	}
+
+	xPubKey, err := decodeHexArg(*xPubKeyArg)
+	if err != nil {
+		return false, errors.Wrapf(err, "xpubkey")
+	}
+	xSig, err := decodeHexArg(*xSigArg)
+	if err != nil {
+		return false, errors.Wrapf(err, "xsig")
+	}
+
+	var msg io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		msg = f
+	}
+	return pkg.EvaluateXSigReader(xPubKey, xSig, msg)
+}
+
+// decodeHexArg decodes a hex argument, read from a file if it starts with @.
+func decodeHexArg(arg string) ([]byte, error) {
+	if strings.HasPrefix(arg, "@") {
+		b, err := os.ReadFile(arg[1:])
+		if err != nil {
+			return nil, err
+		}
+		arg = string(b)
+	}
+	return hex.DecodeString(strings.TrimSpace(arg))
+}
@@ -55,3 +55,52 @@ func TestVerifySignature_TruncatedKey(t *testing.T) {
 	dummySig := []byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01}
 	assert.False(t, VerifySignature([]byte("test"), shortKey, dummySig))
 }
+
+func TestPublicKeyHash(t *testing.T) {
+	_, pk, _ := HelperVerifyData(nil)
+	fp := KeyFingerprint(pk)
+	h, err := PublicKeyHash(pk, 32)
+	assert.Nil(t, err)
+	assert.Equal(t, fp[:], h)
+	h, err = PublicKeyHash(pk, 20)
+	assert.Nil(t, err)
+	assert.Equal(t, fp[:20], h)
+	_, err = PublicKeyHash(pk, 16)
+	assert.NotNil(t, err)
+}
+
+func TestVerifySignatureRaw(t *testing.T) {
+	msg := []byte("raw")
+	for i := 0; i < 20; i++ {
+		_, pk, der := HelperVerifyData(msg)
+		raw, err := RawSignature(der)
+		assert.Nil(t, err)
+		assert.Len(t, raw, RawSignatureSize)
+		assert.True(t, VerifySignatureRaw(msg, pk, raw))
+		assert.False(t, VerifySignatureRaw([]byte("other"), pk, raw))
+		assert.False(t, VerifySignatureRaw(msg, pk, raw[:63]))
+		assert.False(t, VerifySignatureRaw(msg, pk, der))
+		hash := Digest(HashSHA256, msg)
+		assert.True(t, VerifyRawDigestSignature(hash, pk, raw))
+		assert.False(t, VerifyRawDigestSignature(hash[:31], pk, raw))
+	}
+
+	// r = 0 and r >= n never validate
+	_, pk, der := HelperVerifyData(msg)
+	raw, _ := RawSignature(der)
+	zero := append(make([]byte, 32), raw[32:]...)
+	assert.False(t, VerifySignatureRaw(msg, pk, zero))
+	big := append(bytes32(0xff), raw[32:]...)
+	assert.False(t, VerifySignatureRaw(msg, pk, big))
+
+	_, err := RawSignature([]byte{0x30, 0x00})
+	assert.NotNil(t, err)
+}
+
+func bytes32(b byte) []byte {
+	buf := make([]byte, 32)
+	for i := range buf {
+		buf[i] = b
+	}
+	return buf
+}
@@ -0,0 +1,395 @@
+package crypto
+
+// The edwards25519 group for FROST, which needs point arithmetic the
+// standard library does not export. Field elements are 16 signed limbs of
+// 16 bits and points are in extended coordinates (X, Y, Z, T), as in
+// TweetNaCl and c/ed25519.c. Scalar multiplication and scalar arithmetic
+// are constant time, since signing handles secret shares and nonces;
+// decoding and comparing points is not, as points are public.
+
+type fe [16]int64
+
+var (
+	feZero   = fe{}
+	feOne    = fe{1}
+	edD      = fe{0x78a3, 0x1359, 0x4dca, 0x75eb, 0xd8ab, 0x4141, 0x0a4d, 0x0070, 0xe898, 0x7779, 0x4079, 0x8cc7, 0xfe73, 0x2b6f, 0x6cee, 0x5203}
+	edD2     = fe{0xf159, 0x26b2, 0x9b94, 0xebd6, 0xb156, 0x8283, 0x149a, 0x00e0, 0xd130, 0xeef3, 0x80f2, 0x198e, 0xfce7, 0x56df, 0xd9dc, 0x2406}
+	edBX     = fe{0xd51a, 0x8f25, 0x2d60, 0xc956, 0xa7b2, 0x9525, 0xc760, 0x692c, 0xdc5c, 0xfdd6, 0xe231, 0xc0a4, 0x53fe, 0xcd6e, 0x36d3, 0x2169}
+	edBY     = fe{0x6658, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666, 0x6666}
+	edSqrtM1 = fe{0xa0b0, 0x4a0e, 0x1b27, 0xc4ee, 0xe478, 0xad2f, 0x1806, 0x2f43, 0xd7a7, 0x3dfb, 0x0099, 0x2b4d, 0xdf0b, 0x4fc1, 0x2480, 0x2b83}
+)
+
+// edL is the order of the prime-order subgroup, little-endian.
+var edL = [32]byte{
+	0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58, 0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+}
+
+func feCarry(o *fe) {
+	for i := 0; i < 16; i++ {
+		o[i] += 1 << 16
+		c := o[i] >> 16
+		if i < 15 {
+			o[i+1] += c - 1
+		} else {
+			o[0] += 38 * (c - 1)
+		}
+		o[i] -= c << 16
+	}
+}
+
+// feSwap swaps p and q if b is 1.
+func feSwap(p, q *fe, b int64) {
+	mask := ^(b - 1)
+	for i := 0; i < 16; i++ {
+		t := mask & (p[i] ^ q[i])
+		p[i] ^= t
+		q[i] ^= t
+	}
+}
+
+// fePack returns the canonical little-endian encoding of n.
+func fePack(n *fe) [32]byte {
+	var m fe
+	t := *n
+	feCarry(&t)
+	feCarry(&t)
+	feCarry(&t)
+	for j := 0; j < 2; j++ {
+		m[0] = t[0] - 0xffed
+		for i := 1; i < 15; i++ {
+			m[i] = t[i] - 0xffff - ((m[i-1] >> 16) & 1)
+			m[i-1] &= 0xffff
+		}
+		m[15] = t[15] - 0x7fff - ((m[14] >> 16) & 1)
+		b := (m[15] >> 16) & 1
+		m[14] &= 0xffff
+		feSwap(&t, &m, 1-b)
+	}
+	var o [32]byte
+	for i := 0; i < 16; i++ {
+		o[2*i] = byte(t[i])
+		o[2*i+1] = byte(t[i] >> 8)
+	}
+	return o
+}
+
+func feEqual(a, b *fe) bool {
+	return fePack(a) == fePack(b)
+}
+
+func feParity(a *fe) byte {
+	return fePack(a)[0] & 1
+}
+
+// feUnpack decodes a field element, ignoring the top bit.
+func feUnpack(n []byte) fe {
+	var o fe
+	for i := 0; i < 16; i++ {
+		o[i] = int64(n[2*i]) + int64(n[2*i+1])<<8
+	}
+	o[15] &= 0x7fff
+	return o
+}
+
+func feAdd(a, b *fe) fe {
+	var o fe
+	for i := range o {
+		o[i] = a[i] + b[i]
+	}
+	return o
+}
+
+func feSub(a, b *fe) fe {
+	var o fe
+	for i := range o {
+		o[i] = a[i] - b[i]
+	}
+	return o
+}
+
+func feMul(a, b *fe) fe {
+	var t [31]int64
+	for i := 0; i < 16; i++ {
+		for j := 0; j < 16; j++ {
+			t[i+j] += a[i] * b[j]
+		}
+	}
+	for i := 0; i < 15; i++ {
+		t[i] += 38 * t[i+16]
+	}
+	var o fe
+	copy(o[:], t[:16])
+	feCarry(&o)
+	feCarry(&o)
+	return o
+}
+
+// feInvert returns 1/i = i^(p-2).
+func feInvert(i *fe) fe {
+	c := *i
+	for a := 253; a >= 0; a-- {
+		c = feMul(&c, &c)
+		if a != 2 && a != 4 {
+			c = feMul(&c, i)
+		}
+	}
+	return c
+}
+
+// fePow2523 returns i^((p-5)/8).
+func fePow2523(i *fe) fe {
+	c := *i
+	for a := 250; a >= 0; a-- {
+		c = feMul(&c, &c)
+		if a != 1 {
+			c = feMul(&c, i)
+		}
+	}
+	return c
+}
+
+type edPoint [4]fe
+
+var edIdentity = edPoint{feZero, feOne, feOne, feZero}
+
+func edBase() edPoint {
+	return edPoint{edBX, edBY, feOne, feMul(&edBX, &edBY)}
+}
+
+// edAdd returns p + q, with the complete addition formula.
+func edAdd(p, q *edPoint) edPoint {
+	t := feSub(&q[1], &q[0])
+	a := feSub(&p[1], &p[0])
+	a = feMul(&a, &t)
+	t = feAdd(&q[0], &q[1])
+	b := feAdd(&p[0], &p[1])
+	b = feMul(&b, &t)
+	c := feMul(&p[3], &q[3])
+	c = feMul(&c, &edD2)
+	d := feMul(&p[2], &q[2])
+	d = feAdd(&d, &d)
+	e := feSub(&b, &a)
+	f := feSub(&d, &c)
+	g := feAdd(&d, &c)
+	h := feAdd(&b, &a)
+	return edPoint{feMul(&e, &f), feMul(&h, &g), feMul(&g, &f), feMul(&e, &h)}
+}
+
+func edNeg(p *edPoint) edPoint {
+	return edPoint{feSub(&feZero, &p[0]), p[1], p[2], feSub(&feZero, &p[3])}
+}
+
+func edSwap(p, q *edPoint, b int64) {
+	for i := 0; i < 4; i++ {
+		feSwap(&p[i], &q[i], b)
+	}
+}
+
+// edScalarMult returns [s]q for a little-endian s, with a Montgomery
+// ladder.
+func edScalarMult(q edPoint, s []byte) edPoint {
+	p := edIdentity
+	for i := 255; i >= 0; i-- {
+		b := int64(s[i/8]>>uint(i&7)) & 1
+		edSwap(&p, &q, b)
+		q = edAdd(&q, &p)
+		p = edAdd(&p, &p)
+		edSwap(&p, &q, b)
+	}
+	return p
+}
+
+func edScalarBase(s []byte) edPoint {
+	return edScalarMult(edBase(), s)
+}
+
+// edEncode returns the RFC 8032 encoding of p.
+func edEncode(p *edPoint) []byte {
+	zi := feInvert(&p[2])
+	tx := feMul(&p[0], &zi)
+	ty := feMul(&p[1], &zi)
+	r := fePack(&ty)
+	r[31] ^= feParity(&tx) << 7
+	return r[:]
+}
+
+// edDecode decodes a point as in RFC 8032, section 5.1.3: y must be
+// canonical, and x = 0 cannot have its sign bit set.
+func edDecode(b []byte) (edPoint, bool) {
+	var r edPoint
+	if len(b) != 32 {
+		return r, false
+	}
+	r[2] = feOne
+	r[1] = feUnpack(b)
+	if y := fePack(&r[1]); y[31] != b[31]&0x7f || string(y[:31]) != string(b[:31]) {
+		return r, false
+	}
+	// x^2 = (y^2 - 1) / (d y^2 + 1)
+	num := feMul(&r[1], &r[1])
+	den := feMul(&num, &edD)
+	num = feSub(&num, &r[2])
+	den = feAdd(&r[2], &den)
+
+	// x = num den^3 (num den^7)^((p-5)/8), possibly times sqrt(-1)
+	den2 := feMul(&den, &den)
+	den4 := feMul(&den2, &den2)
+	den6 := feMul(&den4, &den2)
+	t := feMul(&den6, &num)
+	t = feMul(&t, &den)
+	t = fePow2523(&t)
+	t = feMul(&t, &num)
+	t = feMul(&t, &den)
+	t = feMul(&t, &den)
+	r[0] = feMul(&t, &den)
+
+	chk := feMul(&r[0], &r[0])
+	chk = feMul(&chk, &den)
+	if !feEqual(&chk, &num) {
+		r[0] = feMul(&r[0], &edSqrtM1)
+	}
+	chk = feMul(&r[0], &r[0])
+	chk = feMul(&chk, &den)
+	if !feEqual(&chk, &num) {
+		return r, false
+	}
+
+	sign := b[31] >> 7
+	if feEqual(&r[0], &feZero) && sign == 1 {
+		return r, false
+	}
+	if feParity(&r[0]) != sign {
+		r[0] = feSub(&feZero, &r[0])
+	}
+	r[3] = feMul(&r[0], &r[1])
+	return r, true
+}
+
+func edEqual(p, q *edPoint) bool {
+	return string(edEncode(p)) == string(edEncode(q))
+}
+
+func edIsIdentity(p *edPoint) bool {
+	return edEqual(p, &edIdentity)
+}
+
+// edDecodeElement is the DeserializeElement of FROST(Ed25519, SHA-512):
+// edDecode, also rejecting the identity and points outside the prime-order
+// subgroup.
+func edDecodeElement(b []byte) (edPoint, bool) {
+	p, ok := edDecode(b)
+	if !ok || edIsIdentity(&p) {
+		return p, false
+	}
+	lp := edScalarMult(p, edL[:])
+	return p, edIsIdentity(&lp)
+}
+
+// Scalars mod L are 32 bytes, little-endian, reduced.
+type edScalar [32]byte
+
+// scModL reduces a 64-limb little-endian x mod L, in constant time.
+func scModL(x *[64]int64) edScalar {
+	var c int64
+	for i := 63; i >= 32; i-- {
+		c = 0
+		j := i - 32
+		for ; j < i-12; j++ {
+			x[j] += c - 16*x[i]*int64(edL[j-(i-32)])
+			c = (x[j] + 128) >> 8
+			x[j] -= c << 8
+		}
+		x[j] += c
+		x[i] = 0
+	}
+	c = 0
+	for j := 0; j < 32; j++ {
+		x[j] += c - (x[31]>>4)*int64(edL[j])
+		c = x[j] >> 8
+		x[j] &= 255
+	}
+	for j := 0; j < 32; j++ {
+		x[j] -= c * int64(edL[j])
+	}
+	var r edScalar
+	for i := 0; i < 32; i++ {
+		x[i+1] += x[i] >> 8
+		r[i] = byte(x[i])
+	}
+	return r
+}
+
+// scReduce returns a little-endian b of up to 64 bytes mod L.
+func scReduce(b []byte) edScalar {
+	var x [64]int64
+	for i := range b {
+		x[i] = int64(b[i])
+	}
+	return scModL(&x)
+}
+
+// scMulAdd returns a b + c mod L.
+func scMulAdd(a, b, c *edScalar) edScalar {
+	var x [64]int64
+	for i := 0; i < 32; i++ {
+		x[i] = int64(c[i])
+	}
+	for i := 0; i < 32; i++ {
+		for j := 0; j < 32; j++ {
+			x[i+j] += int64(a[i]) * int64(b[j])
+		}
+	}
+	return scModL(&x)
+}
+
+func scMul(a, b *edScalar) edScalar {
+	return scMulAdd(a, b, &edScalar{})
+}
+
+func scAdd(a, b *edScalar) edScalar {
+	return scMulAdd(a, &edScalar{1}, b)
+}
+
+func scNeg(a *edScalar) edScalar {
+	minusOne := edScalar(edL)
+	minusOne[0]--
+	return scMul(a, &minusOne)
+}
+
+// scInvert returns 1/a = a^(L-2).
+func scInvert(a *edScalar) edScalar {
+	e := edL
+	e[0] -= 2
+	r := edScalar{1}
+	for i := 252; i >= 0; i-- {
+		r = scMul(&r, &r)
+		if (e[i/8]>>uint(i&7))&1 == 1 {
+			r = scMul(&r, a)
+		}
+	}
+	return r
+}
+
+func scFromUint(n uint64) edScalar {
+	var s edScalar
+	for i := 0; i < 8; i++ {
+		s[i] = byte(n >> (8 * uint(i)))
+	}
+	return s
+}
+
+// scDecode decodes a canonical scalar, below L.
+func scDecode(b []byte) (edScalar, bool) {
+	var s edScalar
+	if len(b) != 32 {
+		return s, false
+	}
+	copy(s[:], b)
+	for i := 31; i >= 0; i-- {
+		if s[i] != edL[i] {
+			return s, s[i] < edL[i]
+		}
+	}
+	return s, false
+}
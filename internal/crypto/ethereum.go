@@ -0,0 +1,173 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Ethereum signature modes of OP_ETHVERIFY.
+const (
+	// EthPersonalSign is EIP-191 personal_sign (eth_sign in most wallets)
+	// of the message itself.
+	EthPersonalSign = byte(0)
+	// EthTypedData is EIP-712 eth_signTypedData_v4 of an XsigMessage
+	// holding the message, see EthTypedDataType.
+	EthTypedData = byte(1)
+)
+
+// EthAddressSize is the size of an Ethereum address: the last 20 bytes of
+// the Keccak-256 of the uncompressed public key, without its 0x04 prefix.
+const EthAddressSize = 20
+
+// EthSignatureSize is the size of a wallet signature, r || s || v.
+const EthSignatureSize = 65
+
+// EthTypedDataType is the EIP-712 struct a wallet signs in EthTypedData
+// mode, with the message as its only member.
+const EthTypedDataType = "XsigMessage(bytes message)"
+
+// EIP-2: s must be in the lower half of the order, as in the Ethereum
+// transaction rules and OpenZeppelin's ECDSA.recover.
+var k1HalfN = new(big.Int).Rsh(k1N, 1)
+
+// EthAddress returns the address of an uncompressed secp256k1 public key
+// (64 bytes, X || Y).
+func EthAddress(publicKeyXY []byte) []byte {
+	return Keccak256(publicKeyXY)[KeccakSize-EthAddressSize:]
+}
+
+// ParseEthAddress decodes a "0x..." address. A mixed-case address must
+// have a valid EIP-55 checksum; all lower or upper case is taken as is.
+func ParseEthAddress(s string) ([]byte, error) {
+	h := strings.TrimPrefix(s, "0x")
+	if len(h) != 2*EthAddressSize {
+		return nil, errors.Errorf("address %q is not 40 hex digits", s)
+	}
+	address, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, errors.Wrapf(err, "address %q", s)
+	}
+	if h != strings.ToLower(h) && h != strings.ToUpper(h) && h != ethChecksumHex(address) {
+		return nil, errors.Errorf("address %q has a bad EIP-55 checksum", s)
+	}
+	return address, nil
+}
+
+// ethChecksumHex is the EIP-55 mixed-case hex of address: a letter is upper
+// case if the matching nibble of the Keccak-256 of the lower-case hex is 8
+// or more.
+func ethChecksumHex(address []byte) string {
+	lower := hex.EncodeToString(address)
+	hash := Keccak256([]byte(lower))
+	out := []byte(lower)
+	for i, c := range out {
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0F
+		}
+		if c >= 'a' && nibble >= 8 {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return string(out)
+}
+
+// EthPersonalSignHash is what a wallet signs for personal_sign(msg).
+func EthPersonalSignHash(msg []byte) []byte {
+	prefix := "\x19Ethereum Signed Message:\n" + strconv.Itoa(len(msg))
+	return Keccak256([]byte(prefix), msg)
+}
+
+// EIP712DomainSeparator is the hashStruct of an EIP712Domain with the given
+// name, version and chain ID, plus the verifying contract if it is not nil.
+func EIP712DomainSeparator(name string, version string, chainID uint64, verifyingContract []byte) []byte {
+	typ := "EIP712Domain(string name,string version,uint256 chainId"
+	if verifyingContract != nil {
+		typ += ",address verifyingContract"
+	}
+	chain := make([]byte, 32)
+	binary.BigEndian.PutUint64(chain[24:], chainID)
+	fields := [][]byte{
+		Keccak256([]byte(typ + ")")),
+		Keccak256([]byte(name)),
+		Keccak256([]byte(version)),
+		chain,
+	}
+	if verifyingContract != nil {
+		fields = append(fields, append(make([]byte, 32-len(verifyingContract)), verifyingContract...))
+	}
+	return Keccak256(fields...)
+}
+
+// EthTypedDataHash is what a wallet signs for eth_signTypedData_v4 of an
+// XsigMessage holding msg in the domain with the given separator.
+func EthTypedDataHash(domainSeparator []byte, msg []byte) []byte {
+	structHash := Keccak256(Keccak256([]byte(EthTypedDataType)), Keccak256(msg))
+	return Keccak256([]byte{0x19, 0x01}, domainSeparator, structHash)
+}
+
+// EthRecover returns the uncompressed public key (X || Y) that made the
+// wallet signature sig over hash, or nil. v is 27 or 28 as wallets return
+// it, or 0 or 1; s must be low (EIP-2).
+func EthRecover(hash []byte, sig []byte) []byte {
+	if len(sig) != EthSignatureSize {
+		return nil
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return nil
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(k1N) >= 0 || s.Cmp(k1HalfN) > 0 {
+		return nil
+	}
+	// v only gives the parity of R's y; an x of r + n is not supported
+	R, ok := k1LiftX(r, v == 1)
+	if !ok {
+		return nil
+	}
+	// Q = r^-1 (s R - z G)
+	z := new(big.Int).SetBytes(hash)
+	z.Mod(z, k1N)
+	rInv := new(big.Int).ModInverse(r, k1N)
+	u1 := new(big.Int).Sub(k1N, z)
+	u1.Mul(u1, rInv)
+	u1.Mod(u1, k1N)
+	u2 := new(big.Int).Mul(s, rInv)
+	u2.Mod(u2, k1N)
+	Q := k1Add(k1ScalarMult(k1G, u1), k1ScalarMult(R, u2))
+	if Q.z.Sign() == 0 {
+		return nil
+	}
+	x, y := Q.affine()
+	return append(k1Bytes32(x), k1Bytes32(y)...)
+}
+
+// VerifyEthSignature checks that sig is a wallet signature of msg by the
+// given address, in EthPersonalSign mode or, with the domain separator, in
+// EthTypedData mode.
+func VerifyEthSignature(mode byte, domainSeparator []byte, msg []byte, address []byte, sig []byte) bool {
+	var hash []byte
+	switch mode {
+	case EthPersonalSign:
+		hash = EthPersonalSignHash(msg)
+	case EthTypedData:
+		hash = EthTypedDataHash(domainSeparator, msg)
+	default:
+		return false
+	}
+	publicKey := EthRecover(hash, sig)
+	if publicKey == nil {
+		return false
+	}
+	return bytes.Equal(EthAddress(publicKey), address)
+}
@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	assert.Equal(t, unhex("c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"), Keccak256())
+	assert.Equal(t, unhex("4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"), Keccak256([]byte("abc")))
+	assert.Equal(t, Keccak256([]byte("abc")), Keccak256([]byte("a"), nil, []byte("bc")))
+}
+
+// The web3.js documentation example for web3.eth.accounts.sign.
+func TestEthPersonalSign_Web3(t *testing.T) {
+	d := new(big.Int).SetBytes(unhex("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"))
+	address := unhex("2c7536E3605D9C16a7a3D7b1898e529396a65c23")
+	assert.Equal(t, address, EthAddressOf(d))
+
+	msg := []byte("Some data")
+	assert.Equal(t, unhex("1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"), EthPersonalSignHash(msg))
+	sig := unhex("b91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd" +
+		"6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a029" + "1c")
+	assert.True(t, VerifyEthSignature(EthPersonalSign, nil, msg, address, sig))
+	assert.False(t, VerifyEthSignature(EthPersonalSign, nil, []byte("Some datb"), address, sig))
+	assert.False(t, VerifyEthSignature(EthTypedData, make([]byte, 32), msg, address, sig))
+
+	// v of 0 or 1 works as well
+	sig[64] = 1
+	assert.True(t, VerifyEthSignature(EthPersonalSign, nil, msg, address, sig))
+	sig[64] = 0
+	assert.False(t, VerifyEthSignature(EthPersonalSign, nil, msg, address, sig))
+	sig[64] = 29
+	assert.False(t, VerifyEthSignature(EthPersonalSign, nil, msg, address, sig))
+}
+
+// The Mail example of EIP-712.
+func TestEIP712_Mail(t *testing.T) {
+	verifyingContract := unhex("CcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC")
+	assert.Equal(t, unhex("f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"),
+		EIP712DomainSeparator("Ether Mail", "1", 1, verifyingContract))
+
+	digest := unhex("be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2")
+	sig := unhex("4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c")
+	publicKey := EthRecover(digest, sig)
+	assert.Equal(t, unhex("CD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), EthAddress(publicKey))
+	d := new(big.Int).SetBytes(Keccak256([]byte("cow")))
+	assert.Equal(t, EthAddress(publicKey), EthAddressOf(d))
+}
+
+func TestVerifyEthSignature(t *testing.T) {
+	msg := []byte("release 1.2.0")
+	domain := EIP712DomainSeparator("xsig", "1", 1, nil)
+	for _, mode := range []byte{EthPersonalSign, EthTypedData} {
+		d, address, sig := HelperVerifyDataEthereum(mode, domain, msg)
+		assert.True(t, VerifyEthSignature(mode, domain, msg, address, sig))
+		assert.False(t, VerifyEthSignature(mode, domain, []byte("release 1.2.1"), address, sig))
+		assert.False(t, VerifyEthSignature(mode^1, domain, msg, address, sig))
+		assert.False(t, VerifyEthSignature(2, domain, msg, address, sig))
+		assert.False(t, VerifyEthSignature(mode, domain, msg, address, sig[:64]))
+		_, otherAddress, _ := HelperVerifyDataEthereum(mode, domain, msg)
+		assert.False(t, VerifyEthSignature(mode, domain, msg, otherAddress, sig))
+
+		// the high-s twin of the signature is rejected (EIP-2)
+		s := new(big.Int).SetBytes(sig[32:64])
+		highS := append(append(append([]byte{}, sig[:32]...), k1Bytes32(s.Sub(k1N, s))...), 55-sig[64])
+		assert.False(t, VerifyEthSignature(mode, domain, msg, address, highS))
+
+		sig = SignEthereum(d, EthPersonalSignHash(msg))
+		assert.Equal(t, mode == EthPersonalSign, VerifyEthSignature(mode, domain, msg, address, sig))
+	}
+	otherDomain := EIP712DomainSeparator("xsig", "1", 5, nil)
+	_, address, sig := HelperVerifyDataEthereum(EthTypedData, domain, msg)
+	assert.False(t, VerifyEthSignature(EthTypedData, otherDomain, msg, address, sig))
+}
+
+func TestParseEthAddress(t *testing.T) {
+	// EIP-55 examples
+	for _, s := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		address, err := ParseEthAddress(s)
+		assert.Nil(t, err)
+		assert.Equal(t, s[2:], ethChecksumHex(address))
+	}
+	_, err := ParseEthAddress("0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed")
+	assert.Nil(t, err)
+	_, err = ParseEthAddress("5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED")
+	assert.Nil(t, err)
+	_, err = ParseEthAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD")
+	assert.NotNil(t, err)
+	_, err = ParseEthAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA")
+	assert.NotNil(t, err)
+	_, err = ParseEthAddress("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAzz")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,501 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+)
+
+// FROST(Ed25519, SHA-512), RFC 9591: t-of-n threshold signing whose result
+// is a single Ed25519 signature under the group public key, which an
+// xpubkey checks with OP_SIGVERIFYED25519. Keys come from a distributed key
+// generation (Pedersen's, with proofs of knowledge as in the FROST paper),
+// so no one ever holds the group secret key.
+//
+// Key generation, for participants 1..n with at least t signing:
+//
+//  1. each participant calls FROSTDKGStart and broadcasts its
+//     FROSTDKGRound1;
+//  2. with all n of them, FROSTDKGShares gives the share to send, over a
+//     private channel, to each other participant;
+//  3. with the n-1 shares received, FROSTDKGFinish gives the participant's
+//     FROSTKeyShare.
+//
+// Signing, by at least t participants:
+//
+//  1. each signer calls FROSTCommit and sends the FROSTCommitment to the
+//     coordinator, keeping the FROSTNonces;
+//  2. the coordinator sends the message and all the commitments to every
+//     signer, who returns FROSTSign's signature share;
+//  3. FROSTAggregate turns the shares into the signature.
+
+const frostContext = "FROST-ED25519-SHA512-v1"
+
+// FROSTSignatureShareSize is the size of a signature share, a scalar.
+const FROSTSignatureShareSize = 32
+
+// FROSTPublicKeys are the public results of key generation, the same for
+// every participant.
+type FROSTPublicKeys struct {
+	MinSigners int
+	// GroupPublicKey is the Ed25519 public key the signatures verify
+	// under.
+	GroupPublicKey []byte
+	// VerifyingShares are the public keys of the participants' signing
+	// shares, used to check signature shares.
+	VerifyingShares map[uint16][]byte
+}
+
+// FROSTKeyShare is what a participant keeps after key generation. The
+// signing share is secret.
+type FROSTKeyShare struct {
+	Identifier   uint16
+	SigningShare []byte
+	*FROSTPublicKeys
+}
+
+// FROSTDKGRound1 is a participant's broadcast in the first round of key
+// generation: commitments to the coefficients of its secret polynomial and
+// a proof that it knows the first one.
+type FROSTDKGRound1 struct {
+	Identifier  uint16
+	Commitments [][]byte
+	Proof       []byte
+}
+
+// FROSTDKGSecret is the state a participant keeps between the rounds of key
+// generation. It is secret.
+type FROSTDKGSecret struct {
+	identifier   uint16
+	maxSigners   int
+	coefficients []edScalar
+}
+
+// FROSTCommitment is a signer's first-round message: the commitments to its
+// two nonces.
+type FROSTCommitment struct {
+	Identifier uint16
+	Hiding     []byte
+	Binding    []byte
+}
+
+// FROSTNonces are the secret nonces behind a FROSTCommitment. They are used
+// once: FROSTSign erases them.
+type FROSTNonces struct {
+	hiding, binding edScalar
+	commitment      FROSTCommitment
+	used            bool
+}
+
+func frostHash(tag string, data ...[]byte) []byte {
+	h := sha512.New()
+	h.Write([]byte(frostContext + tag))
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// frostHashScalar is H1 ("rho"), H3 ("nonce") or the key generation
+// challenge ("dkg"), reduced mod L.
+func frostHashScalar(tag string, data ...[]byte) edScalar {
+	return scReduce(frostHash(tag, data...))
+}
+
+func frostIdentifier(id uint16) edScalar {
+	return scFromUint(uint64(id))
+}
+
+func frostRandomScalar(rand io.Reader) (edScalar, error) {
+	var b [64]byte
+	if _, err := io.ReadFull(rand, b[:]); err != nil {
+		return edScalar{}, errors.Wrapf(err, "frost: randomness")
+	}
+	return scReduce(b[:]), nil
+}
+
+// frostDKGChallenge is the challenge of the proof of knowledge of a
+// participant's secret: H(identifier || commitment || R).
+func frostDKGChallenge(id uint16, commitment []byte, R []byte) edScalar {
+	ident := frostIdentifier(id)
+	return frostHashScalar("dkg", ident[:], commitment, R)
+}
+
+// frostEvaluate returns the polynomial with the given coefficients at x.
+func frostEvaluate(coefficients []edScalar, x uint16) edScalar {
+	ident := frostIdentifier(x)
+	var value edScalar
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		value = scMulAdd(&value, &ident, &coefficients[i])
+	}
+	return value
+}
+
+// frostEvaluateCommitments returns the commitment to the polynomial at x:
+// [f(x)]G from the commitments [a_i]G to its coefficients.
+func frostEvaluateCommitments(commitments []edPoint, x uint16) edPoint {
+	ident := frostIdentifier(x)
+	value := edIdentity
+	for i := len(commitments) - 1; i >= 0; i-- {
+		value = edScalarMult(value, ident[:])
+		value = edAdd(&value, &commitments[i])
+	}
+	return value
+}
+
+// FROSTDKGStart begins key generation for participant identifier (1 to
+// maxSigners) of a minSigners-of-maxSigners group.
+func FROSTDKGStart(identifier uint16, minSigners int, maxSigners int, rand io.Reader) (*FROSTDKGSecret, *FROSTDKGRound1, error) {
+	if minSigners < 2 || maxSigners < minSigners || maxSigners > 0xFFFF {
+		return nil, nil, errors.Errorf("frost: cannot make a %d-of-%d group", minSigners, maxSigners)
+	}
+	if identifier == 0 || int(identifier) > maxSigners {
+		return nil, nil, errors.Errorf("frost: identifier %d is not in 1..%d", identifier, maxSigners)
+	}
+	secret := &FROSTDKGSecret{identifier: identifier, maxSigners: maxSigners}
+	round1 := &FROSTDKGRound1{Identifier: identifier}
+	for i := 0; i < minSigners; i++ {
+		a, err := frostRandomScalar(rand)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret.coefficients = append(secret.coefficients, a)
+		p := edScalarBase(a[:])
+		round1.Commitments = append(round1.Commitments, edEncode(&p))
+	}
+
+	// Schnorr proof of knowledge of the first coefficient
+	k, err := frostRandomScalar(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	R := edScalarBase(k[:])
+	encodedR := edEncode(&R)
+	c := frostDKGChallenge(identifier, round1.Commitments[0], encodedR)
+	z := scMulAdd(&secret.coefficients[0], &c, &k)
+	round1.Proof = append(encodedR, z[:]...)
+	return secret, round1, nil
+}
+
+// frostCheckRound1 checks that round1 has one valid package for each of
+// the n participants and returns their decoded commitments by identifier.
+func frostCheckRound1(secret *FROSTDKGSecret, round1 []*FROSTDKGRound1) (map[uint16][]edPoint, error) {
+	if len(round1) != secret.maxSigners {
+		return nil, errors.Errorf("frost: %d round 1 packages, want %d", len(round1), secret.maxSigners)
+	}
+	minSigners := len(secret.coefficients)
+	commitments := make(map[uint16][]edPoint)
+	for _, pkg := range round1 {
+		id := pkg.Identifier
+		if id == 0 || int(id) > secret.maxSigners {
+			return nil, errors.Errorf("frost: identifier %d is not in 1..%d", id, secret.maxSigners)
+		}
+		if _, dup := commitments[id]; dup {
+			return nil, errors.Errorf("frost: two round 1 packages from participant %d", id)
+		}
+		if len(pkg.Commitments) != minSigners || len(pkg.Proof) != 64 {
+			return nil, errors.Errorf("frost: malformed round 1 package from participant %d", id)
+		}
+		var points []edPoint
+		for _, c := range pkg.Commitments {
+			p, ok := edDecodeElement(c)
+			if !ok {
+				return nil, errors.Errorf("frost: bad commitment from participant %d", id)
+			}
+			points = append(points, p)
+		}
+
+		// R = [z]G - [c]C_0
+		R, okR := edDecodeElement(pkg.Proof[:32])
+		z, okZ := scDecode(pkg.Proof[32:])
+		if !okR || !okZ {
+			return nil, errors.Errorf("frost: malformed proof from participant %d", id)
+		}
+		c := frostDKGChallenge(id, pkg.Commitments[0], pkg.Proof[:32])
+		negC := scNeg(&c)
+		zG := edScalarBase(z[:])
+		cC := edScalarMult(points[0], negC[:])
+		expected := edAdd(&zG, &cC)
+		if !edEqual(&R, &expected) {
+			return nil, errors.Errorf("frost: invalid proof from participant %d", id)
+		}
+		commitments[id] = points
+	}
+	if _, ok := commitments[secret.identifier]; !ok {
+		return nil, errors.New("frost: our own round 1 package is missing")
+	}
+	return commitments, nil
+}
+
+// FROSTDKGShares checks the round 1 packages of all participants, this
+// one's included, and returns the secret share to send to each other
+// participant over a private, authenticated channel.
+func FROSTDKGShares(secret *FROSTDKGSecret, round1 []*FROSTDKGRound1) (map[uint16][]byte, error) {
+	if _, err := frostCheckRound1(secret, round1); err != nil {
+		return nil, err
+	}
+	shares := make(map[uint16][]byte)
+	for id := 1; id <= secret.maxSigners; id++ {
+		if uint16(id) == secret.identifier {
+			continue
+		}
+		share := frostEvaluate(secret.coefficients, uint16(id))
+		shares[uint16(id)] = share[:]
+	}
+	return shares, nil
+}
+
+// FROSTDKGFinish checks the shares received from the other participants
+// against their round 1 commitments and returns this participant's key
+// share.
+func FROSTDKGFinish(secret *FROSTDKGSecret, round1 []*FROSTDKGRound1, shares map[uint16][]byte) (*FROSTKeyShare, error) {
+	commitments, err := frostCheckRound1(secret, round1)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) != secret.maxSigners-1 {
+		return nil, errors.Errorf("frost: %d shares received, want %d", len(shares), secret.maxSigners-1)
+	}
+	signingShare := frostEvaluate(secret.coefficients, secret.identifier)
+	for id, b := range shares {
+		if _, ok := commitments[id]; !ok || id == secret.identifier {
+			return nil, errors.Errorf("frost: unexpected share from participant %d", id)
+		}
+		share, ok := scDecode(b)
+		if !ok {
+			return nil, errors.Errorf("frost: malformed share from participant %d", id)
+		}
+		expected := frostEvaluateCommitments(commitments[id], secret.identifier)
+		got := edScalarBase(share[:])
+		if !edEqual(&got, &expected) {
+			return nil, errors.Errorf("frost: share from participant %d does not match its commitments", id)
+		}
+		signingShare = scAdd(&signingShare, &share)
+	}
+
+	// the group commitments are the sums of everyone's
+	group := make([]edPoint, len(secret.coefficients))
+	for i := range group {
+		group[i] = edIdentity
+		for _, c := range commitments {
+			group[i] = edAdd(&group[i], &c[i])
+		}
+	}
+	public := &FROSTPublicKeys{
+		MinSigners:      len(secret.coefficients),
+		GroupPublicKey:  edEncode(&group[0]),
+		VerifyingShares: make(map[uint16][]byte),
+	}
+	for id := range commitments {
+		p := frostEvaluateCommitments(group, id)
+		public.VerifyingShares[id] = edEncode(&p)
+	}
+	return &FROSTKeyShare{Identifier: secret.identifier, SigningShare: signingShare[:], FROSTPublicKeys: public}, nil
+}
+
+// frostNonce is nonce_generate: H3(random || secret).
+func frostNonce(secret []byte, rand io.Reader) (edScalar, error) {
+	var random [32]byte
+	if _, err := io.ReadFull(rand, random[:]); err != nil {
+		return edScalar{}, errors.Wrapf(err, "frost: randomness")
+	}
+	return frostHashScalar("nonce", random[:], secret), nil
+}
+
+// FROSTCommit is the first round of signing: it draws the signer's nonces
+// and returns them with the commitment to send to the coordinator.
+func FROSTCommit(key *FROSTKeyShare, rand io.Reader) (*FROSTNonces, *FROSTCommitment, error) {
+	hiding, err := frostNonce(key.SigningShare, rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	binding, err := frostNonce(key.SigningShare, rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	D := edScalarBase(hiding[:])
+	E := edScalarBase(binding[:])
+	commitment := FROSTCommitment{Identifier: key.Identifier, Hiding: edEncode(&D), Binding: edEncode(&E)}
+	return &FROSTNonces{hiding: hiding, binding: binding, commitment: commitment}, &commitment, nil
+}
+
+// frostSession is what all signers and the coordinator derive from the
+// message and the commitments.
+type frostSession struct {
+	ids            []uint16
+	hiding         map[uint16]edPoint
+	binding        map[uint16]edPoint
+	bindingFactors map[uint16]edScalar
+	R              edPoint
+	challenge      edScalar
+}
+
+func newFROSTSession(public *FROSTPublicKeys, msg []byte, commitments []FROSTCommitment) (*frostSession, error) {
+	if len(commitments) < public.MinSigners {
+		return nil, errors.Errorf("frost: %d signers, want at least %d", len(commitments), public.MinSigners)
+	}
+	s := &frostSession{
+		hiding:         make(map[uint16]edPoint),
+		binding:        make(map[uint16]edPoint),
+		bindingFactors: make(map[uint16]edScalar),
+	}
+	sorted := append([]FROSTCommitment{}, commitments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Identifier < sorted[j].Identifier })
+
+	// encode_group_commitment_list
+	var encoded []byte
+	for i, c := range sorted {
+		if i > 0 && c.Identifier == sorted[i-1].Identifier {
+			return nil, errors.Errorf("frost: two commitments from participant %d", c.Identifier)
+		}
+		if _, ok := public.VerifyingShares[c.Identifier]; !ok {
+			return nil, errors.Errorf("frost: unknown participant %d", c.Identifier)
+		}
+		D, okD := edDecodeElement(c.Hiding)
+		E, okE := edDecodeElement(c.Binding)
+		if !okD || !okE {
+			return nil, errors.Errorf("frost: bad commitment from participant %d", c.Identifier)
+		}
+		s.ids = append(s.ids, c.Identifier)
+		s.hiding[c.Identifier], s.binding[c.Identifier] = D, E
+		ident := frostIdentifier(c.Identifier)
+		encoded = append(encoded, ident[:]...)
+		encoded = append(encoded, c.Hiding...)
+		encoded = append(encoded, c.Binding...)
+	}
+
+	// compute_binding_factors and compute_group_commitment
+	prefix := append(append([]byte{}, public.GroupPublicKey...), frostHash("msg", msg)...)
+	prefix = append(prefix, frostHash("com", encoded)...)
+	s.R = edIdentity
+	for _, id := range s.ids {
+		ident := frostIdentifier(id)
+		rho := frostHashScalar("rho", prefix, ident[:])
+		s.bindingFactors[id] = rho
+		E := s.binding[id]
+		rhoE := edScalarMult(E, rho[:])
+		D := s.hiding[id]
+		s.R = edAdd(&s.R, &D)
+		s.R = edAdd(&s.R, &rhoE)
+	}
+
+	// compute_challenge: H2 is plain SHA-512, as in Ed25519
+	h := sha512.New()
+	h.Write(edEncode(&s.R))
+	h.Write(public.GroupPublicKey)
+	h.Write(msg)
+	s.challenge = scReduce(h.Sum(nil))
+	return s, nil
+}
+
+// lagrange is derive_interpolating_value: the Lagrange coefficient of id
+// at 0 for the signers of the session. Identifiers are public, so this
+// need not be constant time.
+func (s *frostSession) lagrange(id uint16) edScalar {
+	x := frostIdentifier(id)
+	num, den := edScalar{1}, edScalar{1}
+	for _, other := range s.ids {
+		if other == id {
+			continue
+		}
+		xj := frostIdentifier(other)
+		num = scMul(&num, &xj)
+		negX := scNeg(&x)
+		diff := scAdd(&xj, &negX)
+		den = scMul(&den, &diff)
+	}
+	inv := scInvert(&den)
+	return scMul(&num, &inv)
+}
+
+// FROSTSign is the second round of signing: it returns the signer's share
+// of the signature of msg, given the commitments of all signers, its own
+// included. The nonces are erased, so that they are never used twice.
+func FROSTSign(key *FROSTKeyShare, nonces *FROSTNonces, msg []byte, commitments []FROSTCommitment) ([]byte, error) {
+	if nonces.used {
+		return nil, errors.New("frost: nonces already used")
+	}
+	skShare, ok := scDecode(key.SigningShare)
+	if !ok {
+		return nil, errors.New("frost: malformed signing share")
+	}
+	s, err := newFROSTSession(key.FROSTPublicKeys, msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+	own := -1
+	for i, c := range commitments {
+		if c.Identifier == key.Identifier {
+			own = i
+		}
+	}
+	mine := nonces.commitment
+	if own < 0 || string(commitments[own].Hiding) != string(mine.Hiding) || string(commitments[own].Binding) != string(mine.Binding) {
+		return nil, errors.New("frost: our commitment is not among the signers'")
+	}
+
+	// z_i = d_i + e_i rho_i + lambda_i s_i c
+	lambda := s.lagrange(key.Identifier)
+	rho := s.bindingFactors[key.Identifier]
+	lc := scMul(&lambda, &s.challenge)
+	z := scMulAdd(&nonces.binding, &rho, &nonces.hiding)
+	z = scMulAdd(&lc, &skShare, &z)
+
+	nonces.hiding, nonces.binding, nonces.used = edScalar{}, edScalar{}, true
+	return z[:], nil
+}
+
+// verifyShare checks a signature share: [z_i]G = D_i + [rho_i]E_i +
+// [lambda_i c]Y_i.
+func (s *frostSession) verifyShare(public *FROSTPublicKeys, id uint16, share []byte) bool {
+	z, ok := scDecode(share)
+	if !ok {
+		return false
+	}
+	Y, ok := edDecodeElement(public.VerifyingShares[id])
+	if !ok {
+		return false
+	}
+	rho := s.bindingFactors[id]
+	lambda := s.lagrange(id)
+	lc := scMul(&lambda, &s.challenge)
+	expected := s.hiding[id]
+	E := edScalarMult(s.binding[id], rho[:])
+	expected = edAdd(&expected, &E)
+	lcY := edScalarMult(Y, lc[:])
+	expected = edAdd(&expected, &lcY)
+	got := edScalarBase(z[:])
+	return edEqual(&got, &expected)
+}
+
+// FROSTAggregate checks the signature shares of every signer, by
+// identifier, and combines them into an Ed25519 signature of msg under the
+// group public key. A bad share is reported with its signer.
+func FROSTAggregate(public *FROSTPublicKeys, msg []byte, commitments []FROSTCommitment, shares map[uint16][]byte) ([]byte, error) {
+	s, err := newFROSTSession(public, msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+	if len(shares) != len(s.ids) {
+		return nil, errors.Errorf("frost: %d signature shares for %d signers", len(shares), len(s.ids))
+	}
+	var z edScalar
+	for _, id := range s.ids {
+		share, ok := shares[id]
+		if !ok {
+			return nil, errors.Errorf("frost: no signature share from participant %d", id)
+		}
+		if !s.verifyShare(public, id, share) {
+			return nil, errors.Errorf("frost: invalid signature share from participant %d", id)
+		}
+		zi, _ := scDecode(share)
+		z = scAdd(&z, &zi)
+	}
+	sig := append(edEncode(&s.R), z[:]...)
+	if !ed25519.Verify(public.GroupPublicKey, msg, sig) {
+		return nil, errors.New("frost: aggregate signature does not verify")
+	}
+	return sig, nil
+}
@@ -0,0 +1,290 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEdwards25519_ScalarBase(t *testing.T) {
+	// an Ed25519 public key is [clamp(SHA-512(seed)[:32])]G
+	for i := 0; i < 8; i++ {
+		seed := make([]byte, ed25519.SeedSize)
+		_, _ = rand.Read(seed)
+		h := sha512.Sum512(seed)
+		h[0] &= 248
+		h[31] &= 127
+		h[31] |= 64
+		p := edScalarBase(h[:32])
+		publicKey := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+		assert.Equal(t, []byte(publicKey), edEncode(&p))
+
+		q, ok := edDecode(publicKey)
+		assert.True(t, ok)
+		assert.True(t, edEqual(&p, &q))
+	}
+
+	one := scFromUint(1)
+	g := edScalarBase(one[:])
+	assert.Equal(t, unhex("5866666666666666666666666666666666666666666666666666666666666666"), edEncode(&g))
+	l := edScalar(edL)
+	lg := edScalarMult(g, l[:])
+	assert.True(t, edIsIdentity(&lg))
+}
+
+func TestEdwards25519_Scalars(t *testing.T) {
+	a, _ := frostRandomScalar(rand.Reader)
+	b, _ := frostRandomScalar(rand.Reader)
+	ab := scMul(&a, &b)
+	inv := scInvert(&b)
+	assert.Equal(t, a, scMul(&ab, &inv))
+	neg := scNeg(&a)
+	assert.Equal(t, edScalar{}, scAdd(&a, &neg))
+
+	// [a]G + [b]G = [a + b]G
+	sum := scAdd(&a, &b)
+	ag, bg, sumG := edScalarBase(a[:]), edScalarBase(b[:]), edScalarBase(sum[:])
+	agbg := edAdd(&ag, &bg)
+	assert.True(t, edEqual(&agbg, &sumG))
+
+	_, ok := scDecode(edL[:])
+	assert.False(t, ok)
+}
+
+func TestEdwards25519_DecodeElement(t *testing.T) {
+	one := scFromUint(1)
+	g := edScalarBase(one[:])
+	_, ok := edDecodeElement(edEncode(&g))
+	assert.True(t, ok)
+
+	// the identity
+	_, ok = edDecodeElement(unhex("0100000000000000000000000000000000000000000000000000000000000000"))
+	assert.False(t, ok)
+	// a point of order 8
+	small := unhex("c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac037a")
+	_, ok = edDecode(small)
+	assert.True(t, ok)
+	_, ok = edDecodeElement(small)
+	assert.False(t, ok)
+	// y = p, not canonical
+	_, ok = edDecodeElement(unhex("edffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f"))
+	assert.False(t, ok)
+	// not on the curve
+	_, ok = edDecodeElement(unhex("0200000000000000000000000000000000000000000000000000000000000000"))
+	assert.False(t, ok)
+	_, ok = edDecodeElement(edEncode(&g)[:31])
+	assert.False(t, ok)
+}
+
+func TestFROST(t *testing.T) {
+	msg := []byte("release 1.2.0")
+	keys := HelperFROSTGroup(3, 5)
+	for _, key := range keys {
+		assert.Equal(t, keys[0].GroupPublicKey, key.GroupPublicKey)
+		assert.Equal(t, keys[0].VerifyingShares, key.VerifyingShares)
+		assert.Equal(t, 3, key.MinSigners)
+		assert.Len(t, key.VerifyingShares, 5)
+	}
+
+	// any three or more participants can sign, and the result is a plain
+	// Ed25519 signature
+	for _, signers := range [][]int{{0, 1, 2}, {0, 2, 4}, {4, 3, 1}, {1, 2, 3, 4}, {0, 1, 2, 3, 4}} {
+		var subset []*FROSTKeyShare
+		for _, i := range signers {
+			subset = append(subset, keys[i])
+		}
+		sig := SignFROST(subset, msg)
+		assert.Len(t, sig, ed25519.SignatureSize)
+		assert.True(t, ed25519.Verify(keys[0].GroupPublicKey, msg, sig))
+		assert.False(t, ed25519.Verify(keys[0].GroupPublicKey, []byte("release 1.2.1"), sig))
+	}
+}
+
+func frostRound1(t *testing.T, keys []*FROSTKeyShare) ([]*FROSTNonces, []FROSTCommitment) {
+	var nonces []*FROSTNonces
+	var commitments []FROSTCommitment
+	for _, key := range keys {
+		n, c, err := FROSTCommit(key, rand.Reader)
+		assert.Nil(t, err)
+		nonces = append(nonces, n)
+		commitments = append(commitments, *c)
+	}
+	return nonces, commitments
+}
+
+func TestFROST_Signing(t *testing.T) {
+	msg := []byte("release 1.2.0")
+	keys := HelperFROSTGroup(2, 3)
+
+	// too few signers
+	nonces, commitments := frostRound1(t, keys[:1])
+	_, err := FROSTSign(keys[0], nonces[0], msg, commitments)
+	assert.NotNil(t, err)
+
+	nonces, commitments = frostRound1(t, keys[:2])
+	// a signer that is not in the commitments
+	_, err = FROSTSign(keys[2], nonces[0], msg, commitments)
+	assert.NotNil(t, err)
+	// duplicated commitments
+	_, err = FROSTSign(keys[0], nonces[0], msg, append(commitments, commitments[1]))
+	assert.NotNil(t, err)
+
+	shares := make(map[uint16][]byte)
+	for i, key := range keys[:2] {
+		shares[key.Identifier], err = FROSTSign(key, nonces[i], msg, commitments)
+		assert.Nil(t, err)
+	}
+	// nonces are single use
+	_, err = FROSTSign(keys[0], nonces[0], msg, commitments)
+	assert.NotNil(t, err)
+
+	sig, err := FROSTAggregate(keys[0].FROSTPublicKeys, msg, commitments, shares)
+	assert.Nil(t, err)
+	assert.True(t, ed25519.Verify(keys[0].GroupPublicKey, msg, sig))
+
+	// a bad share is blamed on its signer
+	bad := append([]byte{}, shares[2]...)
+	bad[0] ^= 1
+	_, err = FROSTAggregate(keys[0].FROSTPublicKeys, msg, commitments, map[uint16][]byte{1: shares[1], 2: bad})
+	assert.EqualError(t, err, "frost: invalid signature share from participant 2")
+	// shares for another message
+	_, err = FROSTAggregate(keys[0].FROSTPublicKeys, []byte("release 1.2.1"), commitments, shares)
+	assert.EqualError(t, err, "frost: invalid signature share from participant 1")
+	_, err = FROSTAggregate(keys[0].FROSTPublicKeys, msg, commitments, map[uint16][]byte{1: shares[1]})
+	assert.NotNil(t, err)
+
+	// a commitment the coordinator swapped
+	_, other := frostRound1(t, keys[1:2])
+	_, err = FROSTAggregate(keys[0].FROSTPublicKeys, msg, []FROSTCommitment{commitments[0], other[0]}, shares)
+	assert.EqualError(t, err, "frost: invalid signature share from participant 1")
+}
+
+func TestFROST_RFC9591Vectors(t *testing.T) {
+	// RFC 9591, Appendix E.1: FROST(Ed25519, SHA-512), 2-of-3, participants
+	// 1 and 3 sign. The key was dealt with the polynomial below.
+	groupSecretKey := unhex("7b1c33d3f5291d85de664833beb1ad469f7fb6025a0ec78b3a790c6e13a98304")
+	groupPublicKey := unhex("15d21ccd7ee42959562fc8aa63224c8851fb3ec85a3faf66040d380fb9738673")
+	msg := unhex("74657374")
+	coefficient, _ := scDecode(unhex("178199860edd8c62f5212ee91eff1295d0d670ab4ed4506866bae57e7030b204"))
+	shares := map[uint16][]byte{
+		1: unhex("929dcc590407aae7d388761cddb0c0db6f5627aea8e217f4a033f2ec83d93509"),
+		2: unhex("a91e66e012e4364ac9aaa405fcafd370402d9859f7b6685c07eed76bf409e80d"),
+		3: unhex("d3cb090a075eb154e82fdb4b3cb507f110040905468bb9c46da8bdea643a9a02"),
+	}
+
+	Y := edScalarBase(groupSecretKey)
+	assert.Equal(t, groupPublicKey, edEncode(&Y))
+	secret, _ := scDecode(groupSecretKey)
+	public := &FROSTPublicKeys{MinSigners: 2, GroupPublicKey: groupPublicKey, VerifyingShares: map[uint16][]byte{}}
+	for id, share := range shares {
+		value := frostEvaluate([]edScalar{secret, coefficient}, id)
+		assert.Equal(t, share, value[:], "share %d", id)
+		Yi := edScalarBase(share)
+		public.VerifyingShares[id] = edEncode(&Yi)
+	}
+	key1 := &FROSTKeyShare{Identifier: 1, SigningShare: shares[1], FROSTPublicKeys: public}
+	key3 := &FROSTKeyShare{Identifier: 3, SigningShare: shares[3], FROSTPublicKeys: public}
+
+	// round one: participant 1 draws its nonces from the given randomness
+	random := bytes.NewReader(unhex("0fd2e39e111cdc266f6c0f4d0fd45c947761f1f5d3cb583dfcb9bbaf8d4c9fec" +
+		"69cd85f631d5f7f2721ed5e40519b1366f340a87c2f6856363dbdcda348a7501"))
+	nonces1, commitment1, err := FROSTCommit(key1, random)
+	assert.Nil(t, err)
+	assert.Equal(t, unhex("812d6104142944d5a55924de6d49940956206909f2acaeedecda2b726e630407"), nonces1.hiding[:])
+	assert.Equal(t, unhex("b1110165fc2334149750b28dd813a39244f315cff14d4e89e6142f262ed83301"), nonces1.binding[:])
+	assert.Equal(t, unhex("b5aa8ab305882a6fc69cbee9327e5a45e54c08af61ae77cb8207be3d2ce13de3"), commitment1.Hiding)
+	assert.Equal(t, unhex("67e98ab55aa310c3120418e5050c9cf76cf387cb20ac9e4b6fdb6f82a469f932"), commitment1.Binding)
+
+	// participant 3: the hiding nonce from its randomness, the binding nonce
+	// as given
+	hiding3 := frostHashScalar("nonce", unhex("86d64a260059e495d0fb4fcc17ea3da7452391baa494d4b00321098ed2a0062f"), shares[3])
+	assert.Equal(t, unhex("c256de65476204095ebdc01bd11dc10e57b36bc96284595b8215222374f99c0e"), hiding3[:])
+	binding3, _ := scDecode(unhex("243d71944d929063bc51205714ae3c2218bd3451d0214dfb5aeec2a90c35180d"))
+	commitment3 := FROSTCommitment{
+		Identifier: 3,
+		Hiding:     unhex("cfbdb165bd8aad6eb79deb8d287bcc0ab6658ae57fdcc98ed12c0669e90aec91"),
+		Binding:    unhex("7487bc41a6e712eea2f2af24681b58b1cf1da278ea11fe4e8b78398965f13552"),
+	}
+	D3, E3 := edScalarBase(hiding3[:]), edScalarBase(binding3[:])
+	assert.Equal(t, commitment3.Hiding, edEncode(&D3))
+	assert.Equal(t, commitment3.Binding, edEncode(&E3))
+	nonces3 := &FROSTNonces{hiding: hiding3, binding: binding3, commitment: commitment3}
+
+	// round two
+	commitments := []FROSTCommitment{*commitment1, commitment3}
+	session, err := newFROSTSession(public, msg, commitments)
+	assert.Nil(t, err)
+	rho1, rho3 := session.bindingFactors[1], session.bindingFactors[3]
+	assert.Equal(t, unhex("f2cb9d7dd9beff688da6fcc83fa89046b3479417f47f55600b106760eb3b5603"), rho1[:])
+	assert.Equal(t, unhex("b087686bf35a13f3dc78e780a34b0fe8a77fef1b9938c563f5573d71d8d7890f"), rho3[:])
+
+	share1, err := FROSTSign(key1, nonces1, msg, commitments)
+	assert.Nil(t, err)
+	assert.Equal(t, unhex("001719ab5a53ee1a12095cd088fd149702c0720ce5fd2f29dbecf24b7281b603"), share1)
+	share3, err := FROSTSign(key3, nonces3, msg, commitments)
+	assert.Nil(t, err)
+	assert.Equal(t, unhex("bd86125de990acc5e1f13781d8e32c03a9bbd4c53539bbc106058bfd14326007"), share3)
+
+	sig, err := FROSTAggregate(public, msg, commitments, map[uint16][]byte{1: share1, 3: share3})
+	assert.Nil(t, err)
+	assert.Equal(t, unhex("36282629c383bb820a88b71cae937d41f2f2adfcc3d02e55507e2fb9e2dd3cbe"+
+		"bd9d2b0844e49ae0f3fa935161e1419aab7b47d21a37ebeae1f17d4987b3160b"), sig)
+}
+
+func TestFROST_DKG(t *testing.T) {
+	_, _, err := FROSTDKGStart(1, 1, 3, rand.Reader)
+	assert.NotNil(t, err)
+	_, _, err = FROSTDKGStart(1, 4, 3, rand.Reader)
+	assert.NotNil(t, err)
+	_, _, err = FROSTDKGStart(0, 2, 3, rand.Reader)
+	assert.NotNil(t, err)
+	_, _, err = FROSTDKGStart(4, 2, 3, rand.Reader)
+	assert.NotNil(t, err)
+
+	var secrets []*FROSTDKGSecret
+	var round1 []*FROSTDKGRound1
+	for id := uint16(1); id <= 3; id++ {
+		secret, pkg, err := FROSTDKGStart(id, 2, 3, rand.Reader)
+		assert.Nil(t, err)
+		secrets = append(secrets, secret)
+		round1 = append(round1, pkg)
+	}
+
+	// a proof of knowledge that does not verify
+	forged := *round1[1]
+	forged.Proof = append([]byte{}, forged.Proof...)
+	forged.Proof[40] ^= 1
+	_, err = FROSTDKGShares(secrets[0], []*FROSTDKGRound1{round1[0], &forged, round1[2]})
+	assert.EqualError(t, err, "frost: invalid proof from participant 2")
+	// a proof copied from another participant
+	forged = *round1[2]
+	forged.Identifier = 2
+	_, err = FROSTDKGShares(secrets[0], []*FROSTDKGRound1{round1[0], &forged, round1[2]})
+	assert.NotNil(t, err)
+	_, err = FROSTDKGShares(secrets[0], round1[:2])
+	assert.NotNil(t, err)
+
+	shares1, err := FROSTDKGShares(secrets[1], round1)
+	assert.Nil(t, err)
+	shares2, err := FROSTDKGShares(secrets[2], round1)
+	assert.Nil(t, err)
+	assert.Len(t, shares1, 2)
+
+	// a share that does not match the sender's commitments
+	bad := append([]byte{}, shares2[1]...)
+	bad[0] ^= 1
+	_, err = FROSTDKGFinish(secrets[0], round1, map[uint16][]byte{2: shares1[1], 3: bad})
+	assert.EqualError(t, err, "frost: share from participant 3 does not match its commitments")
+	_, err = FROSTDKGFinish(secrets[0], round1, map[uint16][]byte{2: shares1[1]})
+	assert.NotNil(t, err)
+
+	key, err := FROSTDKGFinish(secrets[0], round1, map[uint16][]byte{2: shares1[1], 3: shares2[1]})
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(1), key.Identifier)
+	share, _ := scDecode(key.SigningShare)
+	p := edScalarBase(share[:])
+	assert.Equal(t, key.VerifyingShares[1], edEncode(&p))
+}
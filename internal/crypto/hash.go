@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+)
+
+// Message digests a signature check can be made over (OP_SIGVERIFYHASH).
+const (
+	HashSHA256   = byte(1)
+	HashSHA384   = byte(2)
+	HashSHA512   = byte(3)
+	HashSHA3_256 = byte(4)
+)
+
+// HashSize returns the digest size of alg, or 0 if alg is unknown.
+func HashSize(alg byte) int {
+	switch alg {
+	case HashSHA256, HashSHA3_256:
+		return 32
+	case HashSHA384:
+		return 48
+	case HashSHA512:
+		return 64
+	}
+	return 0
+}
+
+// NewHash returns an incremental hash for alg, or nil if alg is unknown.
+func NewHash(alg byte) hash.Hash {
+	switch alg {
+	case HashSHA256:
+		return sha256.New()
+	case HashSHA384:
+		return sha512.New384()
+	case HashSHA512:
+		return sha512.New()
+	case HashSHA3_256:
+		return NewSHA3_256()
+	}
+	return nil
+}
+
+// Digest returns the alg digest of msg, or nil if alg is unknown.
+func Digest(alg byte, msg []byte) []byte {
+	h := NewHash(alg)
+	if h == nil {
+		return nil
+	}
+	h.Write(msg)
+	return h.Sum(nil)
+}
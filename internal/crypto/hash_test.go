@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	abc := []byte("abc")
+	assert.Equal(t, unhex("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"), Digest(HashSHA256, abc))
+	assert.Equal(t, unhex("cb00753f45a35e8bb5a03d699ac65007272c32ab0eded1631a8b605a43ff5bed8086072ba1e7cc2358baeca134c825a7"), Digest(HashSHA384, abc))
+	assert.Equal(t, unhex("ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"), Digest(HashSHA512, abc))
+	assert.Equal(t, unhex("3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"), Digest(HashSHA3_256, abc))
+	assert.Equal(t, unhex("a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"), Digest(HashSHA3_256, nil))
+	// exactly one block, so the padding goes in a block of its own
+	assert.Equal(t, unhex("3fc5559f14db8e453a0a3091edbd2bc25e11528d81c66fa570a4efdcc2695ee1"), Digest(HashSHA3_256, bytes.Repeat([]byte("a"), 136)))
+
+	for alg := byte(1); alg <= 4; alg++ {
+		assert.Len(t, Digest(alg, abc), HashSize(alg))
+	}
+	assert.Nil(t, Digest(5, abc))
+	assert.Equal(t, 0, HashSize(0))
+}
+
+func TestNewSHA3_256_Incremental(t *testing.T) {
+	msg := bytes.Repeat([]byte("0123456789"), 50)
+	h := NewSHA3_256()
+	for i := 0; i < len(msg); i += 7 {
+		end := i + 7
+		if end > len(msg) {
+			end = len(msg)
+		}
+		h.Write(msg[i:end])
+	}
+	want := Digest(HashSHA3_256, msg)
+	assert.Equal(t, want, h.Sum(nil))
+	// Sum does not change the state
+	assert.Equal(t, want, h.Sum(nil))
+	h.Reset()
+	h.Write(msg)
+	assert.Equal(t, want, h.Sum(nil))
+}
+
+func TestVerifyDigestSignature(t *testing.T) {
+	msg := []byte("firmware image")
+	for _, keyType := range []byte{KeyTypeP256, KeyTypeSecp256k1, KeyTypeP384} {
+		for alg := byte(1); alg <= 4; alg++ {
+			publicKeyBytes, sig := HelperVerifyDataDigest(keyType, alg, msg)
+			digest := Digest(alg, msg)
+			assert.True(t, VerifyDigestSignature(keyType, digest, publicKeyBytes, sig), "type %d alg %d", keyType, alg)
+			assert.False(t, VerifyDigestSignature(keyType, Digest(alg, []byte("other")), publicKeyBytes, sig))
+			if len(sig) > MaxDERSignatureSize {
+				t.Errorf("signature of %d bytes", len(sig))
+			}
+		}
+	}
+
+	// only the leftmost bytes of a long digest are signed
+	publicKeyBytes, sig := HelperVerifyDataDigest(KeyTypeP256, HashSHA512, msg)
+	digest := Digest(HashSHA512, msg)
+	assert.True(t, VerifyDigestSignature(KeyTypeP256, digest[:32], publicKeyBytes, sig))
+	digest[40] ^= 1
+	assert.True(t, VerifyDigestSignature(KeyTypeP256, digest, publicKeyBytes, sig))
+
+	// a key of the wrong curve or type
+	publicKeyBytes, sig = HelperVerifyDataDigest(KeyTypeP384, HashSHA384, msg)
+	assert.Len(t, publicKeyBytes, P384PublicKeySize)
+	assert.False(t, VerifyDigestSignature(KeyTypeP256, Digest(HashSHA384, msg), publicKeyBytes, sig))
+	assert.False(t, VerifyDigestSignature(KeyTypeSchnorr, Digest(HashSHA384, msg), publicKeyBytes, sig))
+}
@@ -1,10 +1,17 @@
 package crypto
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
 )
 
 func HelperVerifyData(msg []byte) (privateKey *ecdsa.PrivateKey, publicKeyBytes []byte, sig []byte){
@@ -23,3 +30,473 @@ func HelperVerifyData(msg []byte) (privateKey *ecdsa.PrivateKey, publicKeyBytes
 	return privateKey, publicKeyBytes, sig
 }
 
+
+// HelperVerifyDataSecp256k1 is HelperVerifyData for a secp256k1 key.
+func HelperVerifyDataSecp256k1(msg []byte) (privateKey *big.Int, publicKeyBytes []byte, sig []byte) {
+	privateKey = helperScalar()
+	hash := sha256.Sum256(msg)
+	sig = signSecp256k1(privateKey, hash[:])
+	x, y := k1ScalarMult(k1G, privateKey).affine()
+	publicKeyBytes = append([]byte{0x02 | byte(y.Bit(0))}, k1Bytes32(x)...)
+	return privateKey, publicKeyBytes, sig
+}
+
+// HelperVerifyDataDigest is HelperVerifyData for OP_SIGVERIFYHASH: a key of
+// keyType (P-256, secp256k1 or P-384) and a DER signature over the hashAlg
+// digest of msg.
+func HelperVerifyDataDigest(keyType byte, hashAlg byte, msg []byte) (publicKeyBytes []byte, sig []byte) {
+	digest := Digest(hashAlg, msg)
+	if keyType == KeyTypeSecp256k1 {
+		if len(digest) > 32 {
+			digest = digest[:32]
+		}
+		d := helperScalar()
+		x, y := k1ScalarMult(k1G, d).affine()
+		return append([]byte{0x02 | byte(y.Bit(0))}, k1Bytes32(x)...), signSecp256k1(d, digest)
+	}
+	curve := elliptic.P256()
+	if keyType == KeyTypeP384 {
+		curve = elliptic.P384()
+	}
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil { panic(err) }
+	sig, err = ecdsa.SignASN1(rand.Reader, privateKey, digest)
+	if err != nil { panic(err) }
+	pk := privateKey.PublicKey
+	return elliptic.MarshalCompressed(pk.Curve, pk.X, pk.Y), sig
+}
+
+// HelperVerifyDataSchnorr is HelperVerifyData for a BIP-340 key: it returns
+// the x-only public key and a signature over SHA-256(msg).
+func HelperVerifyDataSchnorr(msg []byte) (privateKey *big.Int, publicKeyBytes []byte, sig []byte) {
+	privateKey = helperScalar()
+	digest := sha256.Sum256(msg)
+	aux := make([]byte, 32)
+	if _, err := rand.Read(aux); err != nil { panic(err) }
+	x, _ := k1ScalarMult(k1G, privateKey).affine()
+	return privateKey, k1Bytes32(x), signSchnorr(privateKey, digest[:], aux)
+}
+
+func helperScalar() *big.Int {
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(k1N, big.NewInt(1)))
+	if err != nil { panic(err) }
+	return k.Add(k, big.NewInt(1))
+}
+
+// signSecp256k1 returns a DER ECDSA signature over hash. Not constant time.
+func signSecp256k1(d *big.Int, hash []byte) []byte {
+	z := new(big.Int).SetBytes(hash)
+	for {
+		k := helperScalar()
+		x, _ := k1ScalarMult(k1G, k).affine()
+		r := x.Mod(x, k1N)
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, z)
+		s.Mul(s, new(big.Int).ModInverse(k, k1N))
+		s.Mod(s, k1N)
+		if r.Sign() == 0 || s.Sign() == 0 {
+			continue
+		}
+		sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil { panic(err) }
+		return sig
+	}
+}
+
+// signSchnorr is BIP-340 signing of m with auxiliary randomness aux. Not
+// constant time.
+func signSchnorr(secretKey *big.Int, m []byte, aux []byte) []byte {
+	px, py := k1ScalarMult(k1G, secretKey).affine()
+	d := new(big.Int).Set(secretKey)
+	if py.Bit(0) == 1 {
+		d.Sub(k1N, d)
+	}
+	t := taggedHash("BIP0340/aux", aux)
+	for i, b := range k1Bytes32(d) {
+		t[i] ^= b
+	}
+	k := new(big.Int).SetBytes(taggedHash("BIP0340/nonce", t, k1Bytes32(px), m))
+	k.Mod(k, k1N)
+	if k.Sign() == 0 { panic("signSchnorr: zero nonce") }
+	rx, ry := k1ScalarMult(k1G, k).affine()
+	if ry.Bit(0) == 1 {
+		k.Sub(k1N, k)
+	}
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", k1Bytes32(rx), k1Bytes32(px), m))
+	s := e.Mul(e, d)
+	s.Add(s, k)
+	s.Mod(s, k1N)
+	return append(k1Bytes32(rx), k1Bytes32(s)...)
+}
+
+// HelperVerifyDataEthereum is HelperVerifyData for an Ethereum wallet: it
+// returns the address and a signature in the given mode (see
+// VerifyEthSignature).
+func HelperVerifyDataEthereum(mode byte, domainSeparator []byte, msg []byte) (privateKey *big.Int, address []byte, sig []byte) {
+	privateKey = helperScalar()
+	hash := EthPersonalSignHash(msg)
+	if mode == EthTypedData {
+		hash = EthTypedDataHash(domainSeparator, msg)
+	}
+	return privateKey, EthAddressOf(privateKey), SignEthereum(privateKey, hash)
+}
+
+// EthAddressOf returns the address of a secp256k1 private key.
+func EthAddressOf(d *big.Int) []byte {
+	x, y := k1ScalarMult(k1G, d).affine()
+	return EthAddress(append(k1Bytes32(x), k1Bytes32(y)...))
+}
+
+// SignEthereum returns an r || s || v signature over hash as wallets make
+// it: low s and v of 27 or 28. Not constant time.
+func SignEthereum(d *big.Int, hash []byte) []byte {
+	z := new(big.Int).SetBytes(hash)
+	for {
+		k := helperScalar()
+		x, y := k1ScalarMult(k1G, k).affine()
+		r := new(big.Int).Mod(x, k1N)
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, z)
+		s.Mul(s, new(big.Int).ModInverse(k, k1N))
+		s.Mod(s, k1N)
+		if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(x) != 0 {
+			continue
+		}
+		odd := byte(y.Bit(0))
+		if s.Cmp(k1HalfN) > 0 {
+			s.Sub(k1N, s)
+			odd ^= 1
+		}
+		return append(append(k1Bytes32(r), k1Bytes32(s)...), 27+odd)
+	}
+}
+
+// HelperVerifyDataFROST is HelperVerifyData for a minSigners-of-maxSigners
+// FROST group: it returns everyone's key shares, the group public key and
+// a signature by the first minSigners participants.
+func HelperVerifyDataFROST(minSigners int, maxSigners int, msg []byte) (keys []*FROSTKeyShare, publicKeyBytes []byte, sig []byte) {
+	keys = HelperFROSTGroup(minSigners, maxSigners)
+	return keys, keys[0].GroupPublicKey, SignFROST(keys[:minSigners], msg)
+}
+
+// HelperFROSTGroup runs key generation for a minSigners-of-maxSigners group
+// and returns the key shares of participants 1 to maxSigners.
+func HelperFROSTGroup(minSigners int, maxSigners int) []*FROSTKeyShare {
+	var secrets []*FROSTDKGSecret
+	var round1 []*FROSTDKGRound1
+	for id := 1; id <= maxSigners; id++ {
+		secret, pkg, err := FROSTDKGStart(uint16(id), minSigners, maxSigners, rand.Reader)
+		if err != nil { panic(err) }
+		secrets = append(secrets, secret)
+		round1 = append(round1, pkg)
+	}
+	received := make([]map[uint16][]byte, maxSigners)
+	for i := range received {
+		received[i] = make(map[uint16][]byte)
+	}
+	for i, secret := range secrets {
+		shares, err := FROSTDKGShares(secret, round1)
+		if err != nil { panic(err) }
+		for id, share := range shares {
+			received[id-1][uint16(i+1)] = share
+		}
+	}
+	var keys []*FROSTKeyShare
+	for i, secret := range secrets {
+		key, err := FROSTDKGFinish(secret, round1, received[i])
+		if err != nil { panic(err) }
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// SignFROST runs both rounds of signing with the given participants and
+// returns the aggregate signature of msg.
+func SignFROST(keys []*FROSTKeyShare, msg []byte) []byte {
+	var nonces []*FROSTNonces
+	var commitments []FROSTCommitment
+	for _, key := range keys {
+		n, c, err := FROSTCommit(key, rand.Reader)
+		if err != nil { panic(err) }
+		nonces = append(nonces, n)
+		commitments = append(commitments, *c)
+	}
+	shares := make(map[uint16][]byte)
+	for i, key := range keys {
+		share, err := FROSTSign(key, nonces[i], msg, commitments)
+		if err != nil { panic(err) }
+		shares[key.Identifier] = share
+	}
+	sig, err := FROSTAggregate(keys[0].FROSTPublicKeys, msg, commitments, shares)
+	if err != nil { panic(err) }
+	return sig
+}
+
+// HelperVerifyDataRSA is HelperVerifyData for an RSA key of the given size,
+// returning the key as encoded by RSAPublicKeyBytes.
+func HelperVerifyDataRSA(bits int, scheme byte, msg []byte) (privateKey *rsa.PrivateKey, publicKeyBytes []byte, sig []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil { panic(err) }
+	return privateKey, RSAPublicKeyBytes(&privateKey.PublicKey), SignRSA(scheme, privateKey, msg)
+}
+
+// SignRSA signs SHA-256(msg); PSS signatures use a 32-byte salt.
+func SignRSA(scheme byte, privateKey *rsa.PrivateKey, msg []byte) []byte {
+	hash := sha256.Sum256(msg)
+	var sig []byte
+	var err error
+	switch scheme {
+	case RSAPKCS1v15:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hash[:])
+	case RSAPSS:
+		sig, err = rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, hash[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		panic("SignRSA: unknown scheme")
+	}
+	if err != nil { panic(err) }
+	return sig
+}
+
+// HelperVerifyDataWebAuthn is HelperVerifyData for a security key
+// registered with the relying party rpID, returning an assertion over msg
+// with user presence and user verification.
+func HelperVerifyDataWebAuthn(rpID string, msg []byte) (privateKey *ecdsa.PrivateKey, publicKeyBytes []byte, authData []byte, clientDataJSON []byte, sig []byte) {
+	privateKey, publicKeyBytes, _ = HelperVerifyData(nil)
+	authData = WebAuthnAuthData(rpID, WebAuthnUserPresent|WebAuthnUserVerified, 1)
+	clientDataJSON = WebAuthnClientData(msg, "https://"+rpID)
+	return privateKey, publicKeyBytes, authData, clientDataJSON, SignWebAuthn(privateKey, authData, clientDataJSON)
+}
+
+// WebAuthnAuthData is the authenticator data of an assertion without
+// extensions.
+func WebAuthnAuthData(rpID string, flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(authData[WebAuthnRPIDHashSize+1:], signCount)
+	return authData
+}
+
+// WebAuthnClientData is the client data JSON a browser at origin produces
+// when asked for an assertion over WebAuthnChallenge(msg).
+func WebAuthnClientData(msg []byte, origin string) []byte {
+	return append(WebAuthnClientDataPrefix(msg), `,"origin":"`+origin+`","crossOrigin":false}`...)
+}
+
+// SignWebAuthn signs as an authenticator does: authData || SHA-256(clientDataJSON).
+func SignWebAuthn(privateKey *ecdsa.PrivateKey, authData []byte, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	hash := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, privateKey, hash[:])
+	if err != nil { panic(err) }
+	return sig
+}
+
+// HelperVerifyDataSSH is HelperVerifyData for an SSH key of the given
+// type, returning its wire format and an sshsig signature in namespace.
+func HelperVerifyDataSSH(keyType string, namespace []byte, msg []byte) (privateKey crypto.Signer, publicKeyBytes []byte, sig []byte) {
+	var err error
+	switch keyType {
+	case SSHKeyTypeECDSAP256:
+		privateKey, _, _ = HelperVerifyData(nil)
+	case SSHKeyTypeEd25519:
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		panic("HelperVerifyDataSSH: unknown key type")
+	}
+	if err != nil { panic(err) }
+	publicKeyBytes, err = SSHPublicKeyBytes(privateKey.Public())
+	if err != nil { panic(err) }
+	return privateKey, publicKeyBytes, SignSSHSig(privateKey, namespace, "sha512", msg)
+}
+
+// SignSSHSig signs as ssh-keygen -Y sign does, returning the signature blob.
+func SignSSHSig(privateKey crypto.Signer, namespace []byte, hashAlgorithm string, msg []byte) []byte {
+	publicKeyBytes, err := SSHPublicKeyBytes(privateKey.Public())
+	if err != nil { panic(err) }
+	signed := SSHSigSignedData(namespace, hashAlgorithm, msg)
+
+	switch k := privateKey.(type) {
+	case ed25519.PrivateKey:
+		return SSHSigBlob(publicKeyBytes, namespace, hashAlgorithm, SSHKeyTypeEd25519, ed25519.Sign(k, signed))
+	case *ecdsa.PrivateKey:
+		hash := sha256.Sum256(signed)
+		r, s, err := ecdsa.Sign(rand.Reader, k, hash[:])
+		if err != nil { panic(err) }
+		rs := append(sshString(sshMPIntBytes(r)), sshString(sshMPIntBytes(s))...)
+		return SSHSigBlob(publicKeyBytes, namespace, hashAlgorithm, SSHKeyTypeECDSAP256, rs)
+	}
+	panic("SignSSHSig: unsupported key")
+}
+
+// SSHSigSignedData is what an SSH key signs for msg in namespace, with an
+// empty reserved field.
+func SSHSigSignedData(namespace []byte, hashAlgorithm string, msg []byte) []byte {
+	return sshsigSignedData(namespace, nil, hashAlgorithm, Digest(sshsigHashAlgorithm(hashAlgorithm), msg))
+}
+
+// SSHSigBlob assembles a signature blob around sig, the signature proper
+// (r and s as mpints for ECDSA).
+func SSHSigBlob(publicKeyBytes []byte, namespace []byte, hashAlgorithm string, sigType string, sig []byte) []byte {
+	out := []byte(sshsigMagic)
+	out = binary.BigEndian.AppendUint32(out, sshsigVersion)
+	out = append(out, sshString(publicKeyBytes)...)
+	out = append(out, sshString(namespace)...)
+	out = append(out, sshString(nil)...)
+	out = append(out, sshString([]byte(hashAlgorithm))...)
+	return append(out, sshString(append(sshString([]byte(sigType)), sshString(sig)...))...)
+}
+
+// sshMPIntBytes encodes a positive value as an SSH mpint, with a leading
+// zero if its top bit is set.
+func sshMPIntBytes(x *big.Int) []byte {
+	b := x.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+// HelperVerifyDataSLHDSA is HelperVerifyData for an SLH-DSA key of the given
+// parameter set. The private key is SK.seed || SK.prf || PK.seed || PK.root.
+func HelperVerifyDataSLHDSA(paramSet byte, msg []byte) (privateKey []byte, publicKeyBytes []byte, sig []byte) {
+	seeds := make([]byte, 3*slhN)
+	if _, err := rand.Read(seeds); err != nil { panic(err) }
+	privateKey = SLHDSAKeyFromSeed(paramSet, seeds)
+	return privateKey, privateKey[2*slhN:], SignSLHDSA(paramSet, privateKey, msg)
+}
+
+// SLHDSAKeyFromSeed derives an SLH-DSA private key from SK.seed || SK.prf ||
+// PK.seed, so that test vectors can use fixed keys.
+func SLHDSAKeyFromSeed(paramSet byte, seeds []byte) []byte {
+	p, ok := slhParamsFor(paramSet)
+	if !ok || len(seeds) != 3*slhN { panic("SLHDSAKeyFromSeed: bad parameters") }
+	skSeed, pkSeed := seeds[:slhN], seeds[2*slhN:]
+	var adrs slhAddress
+	adrs.setLayer(uint32(p.d - 1))
+	root := slhXMSSNode(p, skSeed, 0, p.hp, pkSeed, &adrs)
+	return append(append([]byte{}, seeds...), root...)
+}
+
+// SignSLHDSA deterministically signs msg (pure, empty context). Slow and not
+// constant time: SLH-DSA-SHA2-128s takes a couple of million hashes.
+func SignSLHDSA(paramSet byte, privateKey []byte, msg []byte) []byte {
+	p, ok := slhParamsFor(paramSet)
+	if !ok || len(privateKey) != 4*slhN { panic("SignSLHDSA: bad parameters") }
+	skSeed, skPRF := privateKey[:slhN], privateKey[slhN:2*slhN]
+	pkSeed, pkRoot := privateKey[2*slhN:3*slhN], privateKey[3*slhN:]
+	m := slhMessage(msg)
+
+	mac := hmac.New(sha256.New, skPRF)
+	mac.Write(pkSeed) // opt_rand = PK.seed: the deterministic variant
+	mac.Write(m)
+	r := mac.Sum(nil)[:slhN]
+
+	md, idxTree, idxLeaf := slhDigestIndices(p, slhHashMsg(p, r, pkSeed, pkRoot, m))
+	var adrs slhAddress
+	adrs.setTree(idxTree)
+	adrs.setTypeAndClear(slhFORSTree)
+	adrs.setKeyPair(idxLeaf)
+	sig := append([]byte{}, r...)
+	forsSig := slhFORSSign(p, md, skSeed, pkSeed, &adrs)
+	sig = append(sig, forsSig...)
+	node := slhFORSPKFromSig(p, forsSig, md, pkSeed, &adrs)
+
+	adrs = slhAddress{}
+	for j := 0; j < p.d; j++ {
+		if j > 0 {
+			idxLeaf = uint32(idxTree & (1<<uint(p.hp) - 1))
+			idxTree >>= uint(p.hp)
+		}
+		adrs.setLayer(uint32(j))
+		adrs.setTree(idxTree)
+		xmssSig := slhXMSSSign(p, node, skSeed, idxLeaf, pkSeed, &adrs)
+		sig = append(sig, xmssSig...)
+		node = slhXMSSPKFromSig(p, idxLeaf, xmssSig, node, pkSeed, &adrs)
+	}
+	return sig
+}
+
+// slhWOTSSecrets returns the secret chain starts of the WOTS+ key in adrs.
+func slhWOTSSecrets(skSeed, pkSeed []byte, adrs *slhAddress) [][]byte {
+	skAdrs := *adrs
+	skAdrs.setTypeAndClear(slhWOTSPRF)
+	skAdrs.setKeyPair(adrs.keyPair())
+	sk := make([][]byte, slhLen)
+	for i := range sk {
+		skAdrs.setChain(uint32(i))
+		sk[i] = slhHash(pkSeed, &skAdrs, skSeed)
+	}
+	return sk
+}
+
+func slhXMSSNode(p *slhParams, skSeed []byte, i uint32, z int, pkSeed []byte, adrs *slhAddress) []byte {
+	if z == 0 {
+		adrs.setTypeAndClear(slhWOTSHash)
+		adrs.setKeyPair(i)
+		tmp := slhWOTSSecrets(skSeed, pkSeed, adrs)
+		for j := range tmp {
+			adrs.setChain(uint32(j))
+			tmp[j] = slhChain(tmp[j], 0, slhW-1, pkSeed, adrs)
+		}
+		pkAdrs := *adrs
+		pkAdrs.setTypeAndClear(slhWOTSPK)
+		pkAdrs.setKeyPair(i)
+		return slhHash(pkSeed, &pkAdrs, tmp...)
+	}
+	l := slhXMSSNode(p, skSeed, 2*i, z-1, pkSeed, adrs)
+	r := slhXMSSNode(p, skSeed, 2*i+1, z-1, pkSeed, adrs)
+	adrs.setTypeAndClear(slhTree)
+	adrs.setTreeHeight(uint32(z))
+	adrs.setTreeIndex(i)
+	return slhHash(pkSeed, adrs, l, r)
+}
+
+func slhXMSSSign(p *slhParams, msg []byte, skSeed []byte, idx uint32, pkSeed []byte, adrs *slhAddress) []byte {
+	var auth []byte
+	for j := 0; j < p.hp; j++ {
+		auth = append(auth, slhXMSSNode(p, skSeed, (idx>>uint(j))^1, j, pkSeed, adrs)...)
+	}
+	adrs.setTypeAndClear(slhWOTSHash)
+	adrs.setKeyPair(idx)
+	sk := slhWOTSSecrets(skSeed, pkSeed, adrs)
+	var sig []byte
+	for i, d := range slhWOTSMessage(msg) {
+		adrs.setChain(uint32(i))
+		sig = append(sig, slhChain(sk[i], 0, d, pkSeed, adrs)...)
+	}
+	return append(sig, auth...)
+}
+
+func slhFORSSecret(skSeed, pkSeed []byte, adrs *slhAddress, idx uint32) []byte {
+	skAdrs := *adrs
+	skAdrs.setTypeAndClear(slhFORSPRF)
+	skAdrs.setKeyPair(adrs.keyPair())
+	skAdrs.setTreeIndex(idx)
+	return slhHash(pkSeed, &skAdrs, skSeed)
+}
+
+func slhFORSNode(skSeed []byte, i uint32, z int, pkSeed []byte, adrs *slhAddress) []byte {
+	if z == 0 {
+		sk := slhFORSSecret(skSeed, pkSeed, adrs, i)
+		adrs.setTreeHeight(0)
+		adrs.setTreeIndex(i)
+		return slhHash(pkSeed, adrs, sk)
+	}
+	l := slhFORSNode(skSeed, 2*i, z-1, pkSeed, adrs)
+	r := slhFORSNode(skSeed, 2*i+1, z-1, pkSeed, adrs)
+	adrs.setTreeHeight(uint32(z))
+	adrs.setTreeIndex(i)
+	return slhHash(pkSeed, adrs, l, r)
+}
+
+func slhFORSSign(p *slhParams, md []byte, skSeed, pkSeed []byte, adrs *slhAddress) []byte {
+	var sig []byte
+	for i, idx := range slhBase2b(md, p.a, p.k) {
+		sig = append(sig, slhFORSSecret(skSeed, pkSeed, adrs, uint32(i)<<uint(p.a)+idx)...)
+		for j := 0; j < p.a; j++ {
+			s := (idx >> uint(j)) ^ 1
+			sig = append(sig, slhFORSNode(skSeed, uint32(i)<<uint(p.a-j)+s, j, pkSeed, adrs)...)
+		}
+	}
+	return sig
+}
@@ -0,0 +1,137 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// Keccak-256 as used by Ethereum: the original Keccak submission, which pads
+// with 0x01 where the standardized SHA3-256 pads with 0x06.
+
+// KeccakSize is the size of a Keccak-256 digest.
+const KeccakSize = 32
+
+const keccakRate = 136
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotation offsets and destination lanes of the combined rho and pi steps
+var keccakRho = [24]uint{1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44}
+var keccakPi = [24]int{10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1}
+
+func rotl64(x uint64, n uint) uint64 {
+	return x<<n | x>>(64-n)
+}
+
+func keccakF1600(a *[25]uint64) {
+	var c [5]uint64
+	for round := 0; round < 24; round++ {
+		// theta
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ rotl64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= d
+			}
+		}
+		// rho and pi
+		t := a[1]
+		for i := 0; i < 24; i++ {
+			j := keccakPi[i]
+			t, a[j] = a[j], rotl64(t, keccakRho[i])
+		}
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				c[x] = a[y+x]
+			}
+			for x := 0; x < 5; x++ {
+				a[y+x] = c[x] ^ (^c[(x+1)%5] & c[(x+2)%5])
+			}
+		}
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+// keccakHash is an incremental Keccak sponge with a 32-byte output: Keccak-256
+// or SHA3-256, depending on the domain padding byte.
+type keccakHash struct {
+	a     [25]uint64
+	block [keccakRate]byte
+	n     int
+	pad   byte
+}
+
+func (k *keccakHash) absorb() {
+	for i := 0; i < keccakRate/8; i++ {
+		k.a[i] ^= binary.LittleEndian.Uint64(k.block[8*i:])
+	}
+	keccakF1600(&k.a)
+	k.n = 0
+}
+
+func (k *keccakHash) Write(d []byte) (int, error) {
+	written := len(d)
+	for len(d) > 0 {
+		c := copy(k.block[k.n:], d)
+		k.n += c
+		d = d[c:]
+		if k.n == keccakRate {
+			k.absorb()
+		}
+	}
+	return written, nil
+}
+
+// Sum appends the digest to b without changing the state.
+func (k *keccakHash) Sum(b []byte) []byte {
+	f := *k
+	for i := f.n; i < keccakRate; i++ {
+		f.block[i] = 0
+	}
+	f.block[f.n] ^= f.pad
+	f.block[keccakRate-1] ^= 0x80
+	f.absorb()
+
+	out := make([]byte, KeccakSize)
+	for i := 0; i < KeccakSize/8; i++ {
+		binary.LittleEndian.PutUint64(out[8*i:], f.a[i])
+	}
+	return append(b, out...)
+}
+
+func (k *keccakHash) Reset() {
+	*k = keccakHash{pad: k.pad}
+}
+
+func (k *keccakHash) Size() int      { return KeccakSize }
+func (k *keccakHash) BlockSize() int { return keccakRate }
+
+// NewKeccak256 returns an incremental Keccak-256.
+func NewKeccak256() hash.Hash {
+	return &keccakHash{pad: 0x01}
+}
+
+// NewSHA3_256 returns an incremental SHA3-256 (FIPS 202).
+func NewSHA3_256() hash.Hash {
+	return &keccakHash{pad: 0x06}
+}
+
+// Keccak256 returns the Keccak-256 digest of the concatenation of data.
+func Keccak256(data ...[]byte) []byte {
+	h := NewKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"github.com/pkg/errors"
+)
+
+// Merkle trees over public keys. Leaves and inner nodes are hashed with
+// different prefixes so that an inner node can never be passed off as a leaf:
+//
+//	leaf = SHA-256(0x00 || public key)
+//	node = SHA-256(0x01 || left || right)
+//
+// A level with an odd number of nodes promotes the last one unchanged to the
+// level above (it is not paired with itself).
+
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleStep is one level of an inclusion proof, from the leaf upwards.
+// Left reports whether Sibling sits to the left of the node being proven.
+type MerkleStep struct {
+	Sibling []byte
+	Left    bool
+}
+
+func MerkleLeafHash(publicKey []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(publicKey)
+	return h.Sum(nil)
+}
+
+func MerkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func merkleLevels(publicKeys [][]byte) ([][][]byte, error) {
+	if len(publicKeys) == 0 {
+		return nil, errors.New("merkle tree needs at least one leaf")
+	}
+	level := make([][]byte, len(publicKeys))
+	for i, pk := range publicKeys {
+		level[i] = MerkleLeafHash(pk)
+	}
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i+1 < len(level); i += 2 {
+			next = append(next, MerkleNodeHash(level[i], level[i+1]))
+		}
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels, nil
+}
+
+// MerkleRoot returns the root of the tree whose leaves are publicKeys, in order.
+func MerkleRoot(publicKeys [][]byte) ([]byte, error) {
+	levels, err := merkleLevels(publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	return levels[len(levels)-1][0], nil
+}
+
+// MerkleProof returns the inclusion proof for publicKeys[index].
+func MerkleProof(publicKeys [][]byte, index int) ([]MerkleStep, error) {
+	if index < 0 || index >= len(publicKeys) {
+		return nil, errors.Errorf("leaf index %d out of range", index)
+	}
+	levels, err := merkleLevels(publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	var proof []MerkleStep
+	for _, level := range levels[:len(levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, MerkleStep{Sibling: level[sibling], Left: sibling < index})
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// MerkleRootFromProof recomputes the root from a public key and its proof.
+func MerkleRootFromProof(publicKey []byte, proof []MerkleStep) []byte {
+	node := MerkleLeafHash(publicKey)
+	for _, step := range proof {
+		if step.Left {
+			node = MerkleNodeHash(step.Sibling, node)
+		} else {
+			node = MerkleNodeHash(node, step.Sibling)
+		}
+	}
+	return node
+}
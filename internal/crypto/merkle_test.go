@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func helperMerkleKeys(n int) [][]byte {
+	var pks [][]byte
+	for i := 0; i < n; i++ {
+		_, pk, _ := HelperVerifyData([]byte("merkle"))
+		pks = append(pks, pk)
+	}
+	return pks
+}
+
+func TestMerkleRoot_SingleLeaf(t *testing.T) {
+	pks := helperMerkleKeys(1)
+	root, err := MerkleRoot(pks)
+	assert.Nil(t, err)
+	expected := sha256.Sum256(append([]byte{0x00}, pks[0]...))
+	assert.Equal(t, expected[:], root)
+
+	proof, err := MerkleProof(pks, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, proof)
+}
+
+func TestMerkleRoot_TwoLeaves(t *testing.T) {
+	pks := helperMerkleKeys(2)
+	root, err := MerkleRoot(pks)
+	assert.Nil(t, err)
+	l0 := sha256.Sum256(append([]byte{0x00}, pks[0]...))
+	l1 := sha256.Sum256(append([]byte{0x00}, pks[1]...))
+	expected := sha256.Sum256(append(append([]byte{0x01}, l0[:]...), l1[:]...))
+	assert.Equal(t, expected[:], root)
+}
+
+func TestMerkleProof_AllLeaves(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 5, 8, 13} {
+		pks := helperMerkleKeys(n)
+		root, err := MerkleRoot(pks)
+		assert.Nil(t, err)
+		for i, pk := range pks {
+			proof, err := MerkleProof(pks, i)
+			assert.Nil(t, err)
+			assert.Equal(t, root, MerkleRootFromProof(pk, proof), "n=%d i=%d", n, i)
+		}
+	}
+}
+
+func TestMerkleProof_WrongLeaf(t *testing.T) {
+	pks := helperMerkleKeys(4)
+	root, _ := MerkleRoot(pks)
+	proof, _ := MerkleProof(pks, 1)
+	assert.NotEqual(t, root, MerkleRootFromProof(pks[2], proof))
+}
+
+func TestMerkleProof_Errors(t *testing.T) {
+	_, err := MerkleRoot(nil)
+	assert.NotNil(t, err)
+	pks := helperMerkleKeys(3)
+	_, err = MerkleProof(pks, 3)
+	assert.NotNil(t, err)
+	_, err = MerkleProof(pks, -1)
+	assert.NotNil(t, err)
+}
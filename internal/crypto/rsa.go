@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+// RSA signature schemes, as identified on the stack by OP_RSAVERIFY. Both
+// sign SHA-256 of the message.
+const (
+	RSAPKCS1v15 = byte(1) // RSASSA-PKCS1-v1_5
+	RSAPSS      = byte(2) // RSASSA-PSS, MGF1-SHA-256, any salt length
+)
+
+// RSA public keys are encoded as the public exponent (4 bytes, big-endian)
+// followed by the modulus, whose size must be one of RSAModulusSizes.
+const rsaExponentSize = 4
+
+// RSAModulusSizes are the accepted moduli in bytes: 2048, 3072 and 4096
+// bits.
+var RSAModulusSizes = []int{256, 384, 512}
+
+// RSAPublicKeyBytes encodes pub for OP_RSAVERIFY.
+func RSAPublicKeyBytes(pub *rsa.PublicKey) []byte {
+	out := make([]byte, rsaExponentSize, rsaExponentSize+pub.Size())
+	binary.BigEndian.PutUint32(out, uint32(pub.E))
+	return append(out, pub.N.Bytes()...)
+}
+
+// ParseRSAPublicKey decodes a key encoded by RSAPublicKeyBytes. The modulus
+// must be odd and use all of its bytes, and the exponent must be odd and
+// between 3 and 2^31-1.
+func ParseRSAPublicKey(b []byte) (*rsa.PublicKey, error) {
+	if len(b) < rsaExponentSize {
+		return nil, errors.New("RSA public key too short")
+	}
+	e := binary.BigEndian.Uint32(b)
+	modulus := b[rsaExponentSize:]
+	sizeOK := false
+	for _, size := range RSAModulusSizes {
+		sizeOK = sizeOK || len(modulus) == size
+	}
+	if !sizeOK {
+		return nil, errors.Errorf("RSA modulus of %d bytes, must be 256, 384 or 512", len(modulus))
+	}
+	if modulus[0]&0x80 == 0 || modulus[len(modulus)-1]&1 == 0 {
+		return nil, errors.New("RSA modulus must have its top bit set and be odd")
+	}
+	if e < 3 || e > 1<<31-1 || e&1 == 0 {
+		return nil, errors.Errorf("RSA public exponent %d must be odd and between 3 and 2^31-1", e)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(e)}, nil
+}
+
+// VerifySignatureRSA checks an RSA signature over SHA-256(msg).
+func VerifySignatureRSA(scheme byte, msg []byte, pub *rsa.PublicKey, sig []byte) bool {
+	hash := sha256.Sum256(msg)
+	return VerifyRSADigestSignature(scheme, hash[:], pub, sig)
+}
+
+// VerifyRSADigestSignature is VerifySignatureRSA over SHA-256(msg) computed
+// by the caller.
+func VerifyRSADigestSignature(scheme byte, hash []byte, pub *rsa.PublicKey, sig []byte) bool {
+	switch scheme {
+	case RSAPKCS1v15:
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, hash, sig) == nil
+	case RSAPSS:
+		return rsa.VerifyPSS(pub, crypto.SHA256, hash, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}) == nil
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRSA_PublicKeyBytes(t *testing.T) {
+	privateKey, pkBytes, _ := HelperVerifyDataRSA(2048, RSAPKCS1v15, nil)
+	assert.Equal(t, 4+256, len(pkBytes))
+	assert.Equal(t, []byte{0, 1, 0, 1}, pkBytes[:4])
+	pub, err := ParseRSAPublicKey(pkBytes)
+	assert.Nil(t, err)
+	assert.True(t, pub.Equal(&privateKey.PublicKey))
+
+	bad := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte{}, pkBytes...))
+	}
+	for _, b := range [][]byte{
+		nil,
+		pkBytes[:3],
+		pkBytes[:len(pkBytes)-1],
+		append(append([]byte{}, pkBytes...), 0),
+		bad(func(b []byte) []byte { b[3] = 2; return b }),                    // even exponent
+		bad(func(b []byte) []byte { copy(b, []byte{0, 0, 0, 1}); return b }), // e = 1
+		bad(func(b []byte) []byte { b[0] = 0x80; return b }),                 // e >= 2^31
+		bad(func(b []byte) []byte { b[4] &= 0x7F; return b }),                // 2047-bit modulus
+		bad(func(b []byte) []byte { b[len(b)-1] &= 0xFE; return b }),         // even modulus
+	} {
+		_, err := ParseRSAPublicKey(b)
+		assert.NotNil(t, err)
+	}
+
+	_, err = ParseRSAPublicKey(bad(func(b []byte) []byte { copy(b, []byte{0, 0, 0, 3}); return b }))
+	assert.Nil(t, err)
+}
+
+func TestVerifySignatureRSA(t *testing.T) {
+	msg := []byte("code signing")
+	for _, scheme := range []byte{RSAPKCS1v15, RSAPSS} {
+		privateKey, pkBytes, sig := HelperVerifyDataRSA(2048, scheme, msg)
+		pub, err := ParseRSAPublicKey(pkBytes)
+		assert.Nil(t, err)
+		assert.True(t, VerifySignatureRSA(scheme, msg, pub, sig))
+		assert.True(t, VerifyRSADigestSignature(scheme, Digest(HashSHA256, msg), pub, sig))
+		assert.False(t, VerifyRSADigestSignature(scheme, Digest(HashSHA512, msg), pub, sig))
+		assert.False(t, VerifySignatureRSA(scheme, []byte("code signing!"), pub, sig))
+		assert.False(t, VerifySignatureRSA(RSAPKCS1v15+RSAPSS-scheme, msg, pub, sig))
+		assert.False(t, VerifySignatureRSA(3, msg, pub, sig))
+
+		corrupted := append([]byte{}, sig...)
+		corrupted[100] ^= 0x01
+		assert.False(t, VerifySignatureRSA(scheme, msg, pub, corrupted))
+		assert.False(t, VerifySignatureRSA(scheme, msg, pub, sig[1:]))
+		assert.False(t, VerifySignatureRSA(scheme, msg, pub, nil))
+
+		// a signature by another key of the same size
+		other, _, _ := HelperVerifyDataRSA(2048, scheme, nil)
+		assert.False(t, VerifySignatureRSA(scheme, msg, pub, SignRSA(scheme, other, msg)))
+		assert.True(t, VerifySignatureRSA(scheme, msg, pub, SignRSA(scheme, privateKey, msg)))
+	}
+}
+
+func TestVerifySignatureRSA_PSSSaltLengths(t *testing.T) {
+	msg := []byte("legacy HSM")
+	privateKey, pkBytes, _ := HelperVerifyDataRSA(2048, RSAPSS, nil)
+	pub, _ := ParseRSAPublicKey(pkBytes)
+	hash := sha256.Sum256(msg)
+	for _, saltLength := range []int{0, 20, 32, 64, rsa.PSSSaltLengthAuto} {
+		sig, err := rsa.SignPSS(rand.Reader, privateKey, crypto.SHA256, hash[:], &rsa.PSSOptions{SaltLength: saltLength})
+		assert.Nil(t, err)
+		assert.True(t, VerifySignatureRSA(RSAPSS, msg, pub, sig), saltLength)
+	}
+}
+
+func TestVerifySignatureRSA_3072(t *testing.T) {
+	msg := []byte("release")
+	_, pkBytes, sig := HelperVerifyDataRSA(3072, RSAPKCS1v15, msg)
+	assert.Equal(t, 4+384, len(pkBytes))
+	pub, err := ParseRSAPublicKey(pkBytes)
+	assert.Nil(t, err)
+	assert.True(t, VerifySignatureRSA(RSAPKCS1v15, msg, pub, sig))
+}
@@ -0,0 +1,230 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+// secp256k1 (SEC 2), the curve of Bitcoin hardware wallets. The standard
+// library does not ship it, so the arithmetic is done here with math/big.
+// It is not constant time, which is fine for verification: everything it
+// handles is public.
+
+func k1Hex(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("secp256k1: bad constant")
+	}
+	return x
+}
+
+var (
+	k1P = k1Hex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F")
+	k1N = k1Hex("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141")
+	k1G = &k1Point{
+		x: k1Hex("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798"),
+		y: k1Hex("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8"),
+		z: big.NewInt(1),
+	}
+	k1Infinity = &k1Point{x: big.NewInt(0), y: big.NewInt(1), z: big.NewInt(0)}
+	// square roots are a^((p+1)/4) since p = 3 mod 4
+	k1SqrtExp = new(big.Int).Rsh(new(big.Int).Add(k1P, big.NewInt(1)), 2)
+)
+
+// SchnorrPublicKeySize is the size of a BIP-340 x-only public key.
+const SchnorrPublicKeySize = 32
+
+// SchnorrSignatureSize is the size of a BIP-340 signature.
+const SchnorrSignatureSize = 64
+
+// k1Point is a point in Jacobian coordinates, (x/z², y/z³); z = 0 is the
+// point at infinity.
+type k1Point struct {
+	x, y, z *big.Int
+}
+
+func k1Mul(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, k1P)
+}
+
+func k1Sub(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	return r.Mod(r, k1P)
+}
+
+func k1Small(k int64, a *big.Int) *big.Int {
+	return k1Mul(big.NewInt(k), a)
+}
+
+func k1Double(p *k1Point) *k1Point {
+	if p.z.Sign() == 0 || p.y.Sign() == 0 {
+		return k1Infinity
+	}
+	yy := k1Mul(p.y, p.y)
+	s := k1Small(4, k1Mul(p.x, yy))
+	m := k1Small(3, k1Mul(p.x, p.x))
+	x := k1Sub(k1Mul(m, m), k1Small(2, s))
+	y := k1Sub(k1Mul(m, k1Sub(s, x)), k1Small(8, k1Mul(yy, yy)))
+	z := k1Small(2, k1Mul(p.y, p.z))
+	return &k1Point{x: x, y: y, z: z}
+}
+
+func k1Add(p, q *k1Point) *k1Point {
+	if p.z.Sign() == 0 {
+		return q
+	}
+	if q.z.Sign() == 0 {
+		return p
+	}
+	pz2, qz2 := k1Mul(p.z, p.z), k1Mul(q.z, q.z)
+	u1, u2 := k1Mul(p.x, qz2), k1Mul(q.x, pz2)
+	s1, s2 := k1Mul(p.y, k1Mul(q.z, qz2)), k1Mul(q.y, k1Mul(p.z, pz2))
+	h, r := k1Sub(u2, u1), k1Sub(s2, s1)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return k1Double(p)
+		}
+		return k1Infinity
+	}
+	hh := k1Mul(h, h)
+	hhh := k1Mul(h, hh)
+	v := k1Mul(u1, hh)
+	x := k1Sub(k1Sub(k1Mul(r, r), hhh), k1Small(2, v))
+	y := k1Sub(k1Mul(r, k1Sub(v, x)), k1Mul(s1, hhh))
+	z := k1Mul(h, k1Mul(p.z, q.z))
+	return &k1Point{x: x, y: y, z: z}
+}
+
+func k1ScalarMult(p *k1Point, k *big.Int) *k1Point {
+	r := k1Infinity
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r = k1Double(r)
+		if k.Bit(i) == 1 {
+			r = k1Add(r, p)
+		}
+	}
+	return r
+}
+
+// affine returns x/z² and y/z³; p must not be the point at infinity.
+func (p *k1Point) affine() (*big.Int, *big.Int) {
+	zInv := new(big.Int).ModInverse(p.z, k1P)
+	zInv2 := k1Mul(zInv, zInv)
+	return k1Mul(p.x, zInv2), k1Mul(p.y, k1Mul(zInv, zInv2))
+}
+
+// k1LiftX returns the point with the given x coordinate and y of the given
+// parity, if there is one.
+func k1LiftX(x *big.Int, odd bool) (*k1Point, bool) {
+	if x.Cmp(k1P) >= 0 {
+		return nil, false
+	}
+	c := new(big.Int).Add(k1Mul(x, k1Mul(x, x)), big.NewInt(7))
+	c.Mod(c, k1P)
+	y := new(big.Int).Exp(c, k1SqrtExp, k1P)
+	if k1Mul(y, y).Cmp(c) != 0 {
+		return nil, false
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(k1P, y)
+	}
+	return &k1Point{x: new(big.Int).Set(x), y: y, z: big.NewInt(1)}, true
+}
+
+func k1ParseCompressed(publicKeyBytes []byte) (*k1Point, bool) {
+	if len(publicKeyBytes) != 33 || (publicKeyBytes[0] != 0x02 && publicKeyBytes[0] != 0x03) {
+		return nil, false
+	}
+	return k1LiftX(new(big.Int).SetBytes(publicKeyBytes[1:]), publicKeyBytes[0] == 0x03)
+}
+
+func k1Bytes32(x *big.Int) []byte {
+	return x.FillBytes(make([]byte, 32))
+}
+
+// VerifySignatureSecp256k1 is VerifySignature for a compressed secp256k1
+// public key: an ECDSA signature, ASN.1 DER encoded, over SHA-256(msg).
+func VerifySignatureSecp256k1(msg []byte, publicKeyBytes []byte, sig []byte) bool {
+	hash := sha256.Sum256(msg)
+	return verifySecp256k1Digest(hash[:], publicKeyBytes, sig)
+}
+
+// verifySecp256k1Digest is VerifySignatureSecp256k1 over a digest, of which
+// only the leftmost 32 bytes are used.
+func verifySecp256k1Digest(digest []byte, publicKeyBytes []byte, sig []byte) bool {
+	raw, err := RawSignature(sig)
+	if err != nil {
+		return false
+	}
+	q, ok := k1ParseCompressed(publicKeyBytes)
+	if !ok {
+		return false
+	}
+	r := new(big.Int).SetBytes(raw[:32])
+	s := new(big.Int).SetBytes(raw[32:])
+	if r.Cmp(k1N) >= 0 || s.Cmp(k1N) >= 0 {
+		return false
+	}
+	if len(digest) > 32 {
+		digest = digest[:32]
+	}
+	z := new(big.Int).SetBytes(digest)
+	w := new(big.Int).ModInverse(s, k1N)
+	u1 := new(big.Int).Mul(z, w)
+	u1.Mod(u1, k1N)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, k1N)
+	R := k1Add(k1ScalarMult(k1G, u1), k1ScalarMult(q, u2))
+	if R.z.Sign() == 0 {
+		return false
+	}
+	x, _ := R.affine()
+	return x.Mod(x, k1N).Cmp(r) == 0
+}
+
+// taggedHash is the BIP-340 tagged hash SHA-256(SHA-256(tag) ||
+// SHA-256(tag) || data...).
+func taggedHash(tag string, data ...[]byte) []byte {
+	t := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(t[:])
+	h.Write(t[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// VerifySignatureSchnorr checks a BIP-340 Schnorr signature under a 32-byte
+// x-only public key. The BIP-340 message is SHA-256(msg), so signers that
+// only accept 32-byte messages can produce it.
+func VerifySignatureSchnorr(msg []byte, publicKeyBytes []byte, sig []byte) bool {
+	digest := sha256.Sum256(msg)
+	return VerifySchnorrDigestSignature(digest[:], publicKeyBytes, sig)
+}
+
+// VerifySchnorrDigestSignature is BIP-340 verification of a signature over
+// m, which for VerifySignatureSchnorr is SHA-256(msg).
+func VerifySchnorrDigestSignature(m []byte, publicKeyBytes []byte, sig []byte) bool {
+	if len(publicKeyBytes) != SchnorrPublicKeySize || len(sig) != SchnorrSignatureSize {
+		return false
+	}
+	p, ok := k1LiftX(new(big.Int).SetBytes(publicKeyBytes), false)
+	if !ok {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Cmp(k1P) >= 0 || s.Cmp(k1N) >= 0 {
+		return false
+	}
+	e := new(big.Int).SetBytes(taggedHash("BIP0340/challenge", sig[:32], publicKeyBytes, m))
+	e.Mod(e, k1N)
+	R := k1Add(k1ScalarMult(k1G, s), k1ScalarMult(p, e.Sub(k1N, e)))
+	if R.z.Sign() == 0 {
+		return false
+	}
+	x, y := R.affine()
+	return y.Bit(0) == 0 && x.Cmp(r) == 0
+}
@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Test vectors 0 and 1 from BIP-340.
+func TestSchnorr_BIP340Vectors(t *testing.T) {
+	vectors := []struct {
+		secretKey, publicKey, aux, msg, sig string
+	}{
+		{
+			"0000000000000000000000000000000000000000000000000000000000000003",
+			"F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0",
+		},
+		{
+			"B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF",
+			"DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659",
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89",
+			"6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A",
+		},
+	}
+	for _, v := range vectors {
+		sk := new(big.Int).SetBytes(unhex(v.secretKey))
+		x, _ := k1ScalarMult(k1G, sk).affine()
+		assert.Equal(t, unhex(v.publicKey), k1Bytes32(x))
+		sig := signSchnorr(sk, unhex(v.msg), unhex(v.aux))
+		assert.Equal(t, unhex(v.sig), sig)
+		assert.True(t, VerifySchnorrDigestSignature(unhex(v.msg), unhex(v.publicKey), sig))
+	}
+}
+
+func TestSchnorr_Invalid(t *testing.T) {
+	msg := []byte("hello, world")
+	_, pk, sig := HelperVerifyDataSchnorr(msg)
+	assert.True(t, VerifySignatureSchnorr(msg, pk, sig))
+	assert.False(t, VerifySignatureSchnorr([]byte("hello, world!"), pk, sig))
+
+	corrupted := append([]byte{}, sig...)
+	corrupted[63] ^= 0x01
+	assert.False(t, VerifySignatureSchnorr(msg, pk, corrupted))
+
+	// s = n is out of range
+	highS := append(append([]byte{}, sig[:32]...), k1Bytes32(k1N)...)
+	assert.False(t, VerifySignatureSchnorr(msg, pk, highS))
+
+	// x = p is not a valid coordinate
+	assert.False(t, VerifySignatureSchnorr(msg, k1Bytes32(k1P), sig))
+	assert.False(t, VerifySignatureSchnorr(msg, pk[:31], sig))
+	assert.False(t, VerifySignatureSchnorr(msg, pk, sig[:63]))
+}
+
+func TestSecp256k1_GeneratorKey(t *testing.T) {
+	x, y := k1ScalarMult(k1G, big.NewInt(1)).affine()
+	assert.Equal(t, unhex("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798"), k1Bytes32(x))
+	assert.Equal(t, uint(0), y.Bit(0))
+	// n*G is the point at infinity
+	assert.Equal(t, 0, k1ScalarMult(k1G, k1N).z.Sign())
+}
+
+func TestVerifySignatureSecp256k1(t *testing.T) {
+	msg := []byte("hello, world")
+	for i := 0; i < 20; i++ {
+		_, pk, sig := HelperVerifyDataSecp256k1(msg)
+		assert.True(t, VerifySignatureSecp256k1(msg, pk, sig))
+		assert.False(t, VerifySignatureSecp256k1([]byte("hello"), pk, sig))
+		// the same bytes as a P-256 key do not verify
+		assert.False(t, VerifySignature(msg, pk, sig))
+	}
+}
+
+func TestVerifySignatureSecp256k1_Invalid(t *testing.T) {
+	msg := []byte("hello")
+	_, pk, sig := HelperVerifyDataSecp256k1(msg)
+
+	flipped := append([]byte{}, pk...)
+	flipped[0] ^= 0x01 // the other y
+	assert.False(t, VerifySignatureSecp256k1(msg, flipped, sig))
+
+	corrupted := append([]byte{}, sig...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	assert.False(t, VerifySignatureSecp256k1(msg, pk, corrupted))
+
+	assert.False(t, VerifySignatureSecp256k1(msg, pk[:32], sig))
+	assert.False(t, VerifySignatureSecp256k1(msg, pk, []byte{0x30, 0x00}))
+	assert.False(t, VerifySignatureSecp256k1(nil, nil, nil))
+}
@@ -2,8 +2,12 @@ package crypto
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/sha256"
+	"encoding/asn1"
+	"github.com/pkg/errors"
+	"math/big"
 )
 
 func VerifySignature(msg []byte, publicKeyBytes []byte, sig []byte) bool {
@@ -21,3 +25,155 @@ func VerifySignature(msg []byte, publicKeyBytes []byte, sig []byte) bool {
 	return ecdsa.VerifyASN1(&pku, hash[:], sig)
 }
 
+
+// RawSignatureSize is the size of a fixed-size r || s signature, each
+// value big-endian and left-padded to 32 bytes.
+const RawSignatureSize = 64
+
+// VerifySignatureRaw is VerifySignature for a raw r || s signature.
+func VerifySignatureRaw(msg []byte, publicKeyBytes []byte, sig []byte) bool {
+	hash := sha256.Sum256(msg)
+	return VerifyRawDigestSignature(hash[:], publicKeyBytes, sig)
+}
+
+// VerifyRawDigestSignature is VerifySignatureRaw over SHA-256(msg) computed
+// by the caller.
+func VerifyRawDigestSignature(hash []byte, publicKeyBytes []byte, sig []byte) bool {
+	if len(sig) != RawSignatureSize {
+		return false
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), publicKeyBytes)
+	if x == nil {
+		return false
+	}
+	pku := ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     x,
+		Y:     y,
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	return ecdsa.Verify(&pku, hash, r, s)
+}
+
+// RawSignature converts an ASN.1 DER signature, as produced by
+// ecdsa.SignASN1, to raw r || s.
+func RawSignature(derSig []byte) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	rest, err := asn1.Unmarshal(derSig, &sig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "RawSignature")
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("RawSignature: trailing data")
+	}
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > 256 || sig.S.BitLen() > 256 {
+		return nil, errors.New("RawSignature: r or s out of range")
+	}
+	raw := make([]byte, RawSignatureSize)
+	sig.R.FillBytes(raw[:32])
+	sig.S.FillBytes(raw[32:])
+	return raw, nil
+}
+
+// KeyFingerprintSize is the size of a key fingerprint in bytes.
+const KeyFingerprintSize = sha256.Size
+
+// KeyFingerprint identifies a public key outside of a program, e.g. in a
+// revocation list: the SHA-256 of the key as it is pushed on the stack.
+func KeyFingerprint(publicKeyBytes []byte) [KeyFingerprintSize]byte {
+	return sha256.Sum256(publicKeyBytes)
+}
+
+// PublicKeyHash is the commitment to a public key checked by OP_CHECKPKHASH:
+// its fingerprint, truncated to size bytes. size must be 20 or 32.
+func PublicKeyHash(publicKeyBytes []byte, size int) ([]byte, error) {
+	if size != 20 && size != 32 {
+		return nil, errors.Errorf("public key hash size must be 20 or 32, got %d", size)
+	}
+	fp := KeyFingerprint(publicKeyBytes)
+	return fp[:size], nil
+}
+
+// Key types, for opcodes that take keys of more than one signature scheme
+// (OP_MIXEDMULTISIGVERIFY).
+const (
+	KeyTypeP256      = byte(1) // compressed P-256 key, DER ECDSA signature
+	KeyTypeSecp256k1 = byte(2) // compressed secp256k1 key, DER ECDSA signature
+	KeyTypeSchnorr   = byte(3) // BIP-340 x-only key and signature
+	KeyTypeEd25519   = byte(4) // RFC 8032 key and signature, as made by FROST
+	KeyTypeP384      = byte(5) // compressed P-384 key, DER ECDSA signature; OP_SIGVERIFYHASH only
+)
+
+// P384PublicKeySize is the size of a compressed KeyTypeP384 key.
+const P384PublicKeySize = 49
+
+// MaxDERSignatureSize bounds the DER ECDSA signatures OP_SIGVERIFYHASH
+// accepts: two 49-byte integers (P-384, with a leading zero) and headers.
+const MaxDERSignatureSize = 104
+
+// Sizes of KeyTypeEd25519 keys and signatures.
+const (
+	Ed25519PublicKeySize = ed25519.PublicKeySize
+	Ed25519SignatureSize = ed25519.SignatureSize
+)
+
+// PublicKeySize returns the size of a public key of the given type, or 0 if
+// the type is unknown.
+func PublicKeySize(keyType byte) int {
+	switch keyType {
+	case KeyTypeP256, KeyTypeSecp256k1:
+		return 33
+	case KeyTypeSchnorr:
+		return SchnorrPublicKeySize
+	case KeyTypeEd25519:
+		return Ed25519PublicKeySize
+	}
+	return 0
+}
+
+// VerifyTypedSignature verifies sig with the scheme of keyType.
+func VerifyTypedSignature(keyType byte, msg []byte, publicKeyBytes []byte, sig []byte) bool {
+	switch keyType {
+	case KeyTypeP256:
+		return VerifySignature(msg, publicKeyBytes, sig)
+	case KeyTypeSecp256k1:
+		return VerifySignatureSecp256k1(msg, publicKeyBytes, sig)
+	case KeyTypeSchnorr:
+		return VerifySignatureSchnorr(msg, publicKeyBytes, sig)
+	case KeyTypeEd25519:
+		return len(publicKeyBytes) == Ed25519PublicKeySize && len(sig) == Ed25519SignatureSize &&
+			ed25519.Verify(publicKeyBytes, msg, sig)
+	}
+	return false
+}
+
+// VerifyDigestSignature verifies a DER ECDSA signature over a digest
+// computed by the caller, for KeyTypeP256, KeyTypeSecp256k1 or KeyTypeP384.
+// As usual for ECDSA, a digest longer than the group order is truncated to
+// its leftmost bytes: 32 for the 256-bit curves, 48 for P-384.
+func VerifyDigestSignature(keyType byte, digest []byte, publicKeyBytes []byte, sig []byte) bool {
+	var curve elliptic.Curve
+	switch keyType {
+	case KeyTypeP256:
+		curve = elliptic.P256()
+	case KeyTypeP384:
+		curve = elliptic.P384()
+	case KeyTypeSecp256k1:
+		return verifySecp256k1Digest(digest, publicKeyBytes, sig)
+	default:
+		return false
+	}
+	x, y := elliptic.UnmarshalCompressed(curve, publicKeyBytes)
+	if x == nil {
+		return false
+	}
+	pku := ecdsa.PublicKey{
+		Curve: curve,
+		X:     x,
+		Y:     y,
+	}
+	return ecdsa.VerifyASN1(&pku, digest, sig)
+}
@@ -0,0 +1,324 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// SLH-DSA (FIPS 205), the stateless hash-based signature scheme, for
+// keys that must outlive ECDSA. Only the SHA2 parameter sets of security
+// category 1 are implemented: they need nothing but SHA-256, which the C
+// interpreter already has. Signatures are over the pure (not pre-hashed)
+// variant with an empty context string.
+
+// SLH-DSA parameter sets, as identified on the stack by OP_SLHDSAVERIFY.
+const (
+	SLHDSASHA2128s = byte(1) // SLH-DSA-SHA2-128s: 7856-byte signatures, slow to sign
+	SLHDSASHA2128f = byte(2) // SLH-DSA-SHA2-128f: 17088-byte signatures, fast to sign
+)
+
+// SLHDSAPublicKeySize is the size of an SLH-DSA public key, PK.seed ||
+// PK.root, for the parameter sets above.
+const SLHDSAPublicKeySize = 2 * slhN
+
+const (
+	slhN    = 16 // hash output size in bytes
+	slhLgW  = 4  // Winternitz parameter: chains of w = 16 values
+	slhW    = 1 << slhLgW
+	slhLen1 = 8 * slhN / slhLgW
+	slhLen2 = 3
+	slhLen  = slhLen1 + slhLen2
+)
+
+type slhParams struct {
+	h  int // total tree height
+	d  int // layers
+	hp int // height of each XMSS tree, h / d
+	a  int // FORS tree height
+	k  int // FORS trees
+	m  int // message digest size in bytes
+}
+
+func slhParamsFor(paramSet byte) (*slhParams, bool) {
+	switch paramSet {
+	case SLHDSASHA2128s:
+		return &slhParams{h: 63, d: 7, hp: 9, a: 12, k: 14, m: 30}, true
+	case SLHDSASHA2128f:
+		return &slhParams{h: 66, d: 22, hp: 3, a: 6, k: 33, m: 34}, true
+	}
+	return nil, false
+}
+
+func (p *slhParams) forsSigSize() int {
+	return p.k * (1 + p.a) * slhN
+}
+
+func (p *slhParams) xmssSigSize() int {
+	return (slhLen + p.hp) * slhN
+}
+
+func (p *slhParams) sigSize() int {
+	return slhN + p.forsSigSize() + p.d*p.xmssSigSize()
+}
+
+// SLHDSASignatureSize returns the signature size of a parameter set, or 0
+// if it is unknown.
+func SLHDSASignatureSize(paramSet byte) int {
+	p, ok := slhParamsFor(paramSet)
+	if !ok {
+		return 0
+	}
+	return p.sigSize()
+}
+
+// Address types.
+const (
+	slhWOTSHash  = 0
+	slhWOTSPK    = 1
+	slhTree      = 2
+	slhFORSTree  = 3
+	slhFORSRoots = 4
+	slhWOTSPRF   = 5
+	slhFORSPRF   = 6
+)
+
+// slhAddress is the 32-byte ADRS: layer (4 bytes), tree address (12),
+// type (4), then three type-specific words.
+type slhAddress [32]byte
+
+func (a *slhAddress) setLayer(l uint32) {
+	binary.BigEndian.PutUint32(a[0:4], l)
+}
+
+func (a *slhAddress) setTree(t uint64) {
+	binary.BigEndian.PutUint32(a[4:8], 0)
+	binary.BigEndian.PutUint64(a[8:16], t)
+}
+
+func (a *slhAddress) setTypeAndClear(t uint32) {
+	binary.BigEndian.PutUint32(a[16:20], t)
+	for i := 20; i < 32; i++ {
+		a[i] = 0
+	}
+}
+
+func (a *slhAddress) setKeyPair(i uint32) {
+	binary.BigEndian.PutUint32(a[20:24], i)
+}
+
+func (a *slhAddress) keyPair() uint32 {
+	return binary.BigEndian.Uint32(a[20:24])
+}
+
+// setChain and setTreeHeight share a word, as do setHash and setTreeIndex.
+func (a *slhAddress) setChain(i uint32) {
+	binary.BigEndian.PutUint32(a[24:28], i)
+}
+
+func (a *slhAddress) setTreeHeight(i uint32) {
+	binary.BigEndian.PutUint32(a[24:28], i)
+}
+
+func (a *slhAddress) setHash(i uint32) {
+	binary.BigEndian.PutUint32(a[28:32], i)
+}
+
+func (a *slhAddress) setTreeIndex(i uint32) {
+	binary.BigEndian.PutUint32(a[28:32], i)
+}
+
+func (a *slhAddress) treeIndex() uint32 {
+	return binary.BigEndian.Uint32(a[28:32])
+}
+
+// compressed is ADRSc, the 22-byte form hashed by the SHA2 parameter sets.
+func (a *slhAddress) compressed() []byte {
+	c := make([]byte, 0, 22)
+	c = append(c, a[3])
+	c = append(c, a[8:16]...)
+	c = append(c, a[19])
+	return append(c, a[20:32]...)
+}
+
+// slhHash is F, H and T_l: Trunc_n(SHA-256(PK.seed || 0^(64-n) || ADRSc ||
+// M)). PRF is the same with SK.seed as M.
+func slhHash(pkSeed []byte, adrs *slhAddress, m ...[]byte) []byte {
+	h := sha256.New()
+	h.Write(pkSeed)
+	h.Write(make([]byte, 64-slhN))
+	h.Write(adrs.compressed())
+	for _, x := range m {
+		h.Write(x)
+	}
+	return h.Sum(nil)[:slhN]
+}
+
+// slhHashMsg is H_msg: MGF1-SHA-256(R || PK.seed || SHA-256(R || PK.seed ||
+// PK.root || M), m).
+func slhHashMsg(p *slhParams, r, pkSeed, pkRoot, msg []byte) []byte {
+	h := sha256.New()
+	h.Write(r)
+	h.Write(pkSeed)
+	h.Write(pkRoot)
+	h.Write(msg)
+	seed := append(append(append([]byte{}, r...), pkSeed...), h.Sum(nil)...)
+
+	var out []byte
+	for counter := uint32(0); len(out) < p.m; counter++ {
+		var c [4]byte
+		binary.BigEndian.PutUint32(c[:], counter)
+		block := sha256.Sum256(append(append([]byte{}, seed...), c[:]...))
+		out = append(out, block[:]...)
+	}
+	return out[:p.m]
+}
+
+// slhBase2b splits x into outLen big-endian b-bit integers.
+func slhBase2b(x []byte, b int, outLen int) []uint32 {
+	out := make([]uint32, outLen)
+	in, bits, total := 0, 0, uint32(0)
+	for i := range out {
+		for bits < b {
+			total = total<<8 | uint32(x[in])
+			in++
+			bits += 8
+		}
+		bits -= b
+		out[i] = (total >> uint(bits)) & (1<<uint(b) - 1)
+	}
+	return out
+}
+
+// slhDigestIndices splits the message digest into the FORS message and the
+// tree and leaf indices of the signing FORS key.
+func slhDigestIndices(p *slhParams, digest []byte) (md []byte, idxTree uint64, idxLeaf uint32) {
+	mdLen := (p.k*p.a + 7) / 8
+	treeLen := (p.h - p.hp + 7) / 8
+	leafLen := (p.hp + 7) / 8
+	md = digest[:mdLen]
+	var buf [8]byte
+	copy(buf[8-treeLen:], digest[mdLen:mdLen+treeLen])
+	idxTree = binary.BigEndian.Uint64(buf[:]) & (1<<uint(p.h-p.hp) - 1)
+	buf = [8]byte{}
+	copy(buf[8-leafLen:], digest[mdLen+treeLen:mdLen+treeLen+leafLen])
+	idxLeaf = uint32(binary.BigEndian.Uint64(buf[:]) & (1<<uint(p.hp) - 1))
+	return md, idxTree, idxLeaf
+}
+
+func slhChain(x []byte, start, steps uint32, pkSeed []byte, adrs *slhAddress) []byte {
+	tmp := x
+	for j := start; j < start+steps; j++ {
+		adrs.setHash(j)
+		tmp = slhHash(pkSeed, adrs, tmp)
+	}
+	return tmp
+}
+
+// slhWOTSMessage is the message of a WOTS+ signature: msg in base w followed
+// by its checksum.
+func slhWOTSMessage(msg []byte) []uint32 {
+	digits := slhBase2b(msg, slhLgW, slhLen1)
+	csum := uint32(0)
+	for _, d := range digits {
+		csum += slhW - 1 - d
+	}
+	csum <<= (8 - (slhLen2*slhLgW)%8) % 8
+	return append(digits, slhBase2b([]byte{byte(csum >> 8), byte(csum)}, slhLgW, slhLen2)...)
+}
+
+func slhWOTSPKFromSig(sig []byte, msg []byte, pkSeed []byte, adrs *slhAddress) []byte {
+	digits := slhWOTSMessage(msg)
+	tmp := make([][]byte, slhLen)
+	for i, d := range digits {
+		adrs.setChain(uint32(i))
+		tmp[i] = slhChain(sig[i*slhN:(i+1)*slhN], d, slhW-1-d, pkSeed, adrs)
+	}
+	pkAdrs := *adrs
+	pkAdrs.setTypeAndClear(slhWOTSPK)
+	pkAdrs.setKeyPair(adrs.keyPair())
+	return slhHash(pkSeed, &pkAdrs, tmp...)
+}
+
+// slhClimb hashes node up an authentication path, the tree index and height
+// being kept in adrs.
+func slhClimb(node []byte, leaf uint32, auth []byte, height int, pkSeed []byte, adrs *slhAddress) []byte {
+	for j := 0; j < height; j++ {
+		adrs.setTreeHeight(uint32(j + 1))
+		sibling := auth[j*slhN : (j+1)*slhN]
+		if (leaf>>uint(j))%2 == 0 {
+			adrs.setTreeIndex(adrs.treeIndex() / 2)
+			node = slhHash(pkSeed, adrs, node, sibling)
+		} else {
+			adrs.setTreeIndex((adrs.treeIndex() - 1) / 2)
+			node = slhHash(pkSeed, adrs, sibling, node)
+		}
+	}
+	return node
+}
+
+func slhXMSSPKFromSig(p *slhParams, idx uint32, sig []byte, msg []byte, pkSeed []byte, adrs *slhAddress) []byte {
+	adrs.setTypeAndClear(slhWOTSHash)
+	adrs.setKeyPair(idx)
+	node := slhWOTSPKFromSig(sig[:slhLen*slhN], msg, pkSeed, adrs)
+	adrs.setTypeAndClear(slhTree)
+	adrs.setTreeIndex(idx)
+	return slhClimb(node, idx, sig[slhLen*slhN:], p.hp, pkSeed, adrs)
+}
+
+func slhFORSPKFromSig(p *slhParams, sig []byte, md []byte, pkSeed []byte, adrs *slhAddress) []byte {
+	indices := slhBase2b(md, p.a, p.k)
+	roots := make([][]byte, p.k)
+	for i, idx := range indices {
+		off := i * (1 + p.a) * slhN
+		adrs.setTreeHeight(0)
+		adrs.setTreeIndex(uint32(i)<<uint(p.a) + idx)
+		node := slhHash(pkSeed, adrs, sig[off:off+slhN])
+		roots[i] = slhClimb(node, idx, sig[off+slhN:], p.a, pkSeed, adrs)
+	}
+	pkAdrs := *adrs
+	pkAdrs.setTypeAndClear(slhFORSRoots)
+	pkAdrs.setKeyPair(adrs.keyPair())
+	return slhHash(pkSeed, &pkAdrs, roots...)
+}
+
+// slhMessage is M' for pure SLH-DSA with an empty context string.
+func slhMessage(msg []byte) []byte {
+	return append([]byte{0, 0}, msg...)
+}
+
+// VerifySignatureSLHDSA checks an SLH-DSA signature over msg (pure, empty
+// context) under a public key of the given parameter set.
+func VerifySignatureSLHDSA(paramSet byte, msg []byte, publicKeyBytes []byte, sig []byte) bool {
+	p, ok := slhParamsFor(paramSet)
+	if !ok || len(publicKeyBytes) != SLHDSAPublicKeySize || len(sig) != p.sigSize() {
+		return false
+	}
+	pkSeed, pkRoot := publicKeyBytes[:slhN], publicKeyBytes[slhN:]
+	r := sig[:slhN]
+	forsSig := sig[slhN : slhN+p.forsSigSize()]
+	htSig := sig[slhN+p.forsSigSize():]
+
+	digest := slhHashMsg(p, r, pkSeed, pkRoot, slhMessage(msg))
+	md, idxTree, idxLeaf := slhDigestIndices(p, digest)
+
+	var adrs slhAddress
+	adrs.setTree(idxTree)
+	adrs.setTypeAndClear(slhFORSTree)
+	adrs.setKeyPair(idxLeaf)
+	node := slhFORSPKFromSig(p, forsSig, md, pkSeed, &adrs)
+
+	// hypertree: each layer signs the root of the one below
+	adrs = slhAddress{}
+	for j := 0; j < p.d; j++ {
+		if j > 0 {
+			idxLeaf = uint32(idxTree & (1<<uint(p.hp) - 1))
+			idxTree >>= uint(p.hp)
+		}
+		adrs.setLayer(uint32(j))
+		adrs.setTree(idxTree)
+		xmssSig := htSig[j*p.xmssSigSize() : (j+1)*p.xmssSigSize()]
+		node = slhXMSSPKFromSig(p, idxLeaf, xmssSig, node, pkSeed, &adrs)
+	}
+	return bytes.Equal(node, pkRoot)
+}
@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSLHDSA_Sizes(t *testing.T) {
+	assert.Equal(t, 7856, SLHDSASignatureSize(SLHDSASHA2128s))
+	assert.Equal(t, 17088, SLHDSASignatureSize(SLHDSASHA2128f))
+	assert.Equal(t, 0, SLHDSASignatureSize(0))
+	assert.Equal(t, 32, SLHDSAPublicKeySize)
+}
+
+func TestSLHDSA_Base2b(t *testing.T) {
+	assert.Equal(t, []uint32{0xA, 0xB, 0xC, 0xD}, slhBase2b([]byte{0xAB, 0xCD}, 4, 4))
+	assert.Equal(t, []uint32{0xABC}, slhBase2b([]byte{0xAB, 0xCD}, 12, 1))
+	assert.Equal(t, []uint32{0x2A, 0x3C, 0x37}, slhBase2b([]byte{0xAB, 0xCD, 0xEF}, 6, 3))
+}
+
+func TestSLHDSA_WOTSChecksum(t *testing.T) {
+	// all-zero digits give the largest checksum, 32*15 = 0x1E0
+	digits := slhWOTSMessage(make([]byte, slhN))
+	assert.Equal(t, []uint32{1, 0xE, 0}, digits[slhLen1:])
+	digits = slhWOTSMessage(bytesRepeat(0xFF, slhN))
+	assert.Equal(t, []uint32{0, 0, 0}, digits[slhLen1:])
+}
+
+func TestSLHDSA_AddressCompression(t *testing.T) {
+	var a slhAddress
+	a.setLayer(0x01020304)
+	a.setTree(0x05060708090A0B0C)
+	a.setTypeAndClear(slhFORSTree)
+	a.setKeyPair(0x0D0E0F10)
+	a.setTreeHeight(0x11121314)
+	a.setTreeIndex(0x15161718)
+	assert.Equal(t, unhex("04"+"05060708090A0B0C"+"03"+"0D0E0F10"+"11121314"+"15161718"), a.compressed())
+}
+
+func TestSLHDSA_RoundTrip(t *testing.T) {
+	for _, paramSet := range []byte{SLHDSASHA2128f, SLHDSASHA2128s} {
+		msg := []byte("hello, world")
+		_, pk, sig := HelperVerifyDataSLHDSA(paramSet, msg)
+		assert.Equal(t, SLHDSASignatureSize(paramSet), len(sig))
+		assert.True(t, VerifySignatureSLHDSA(paramSet, msg, pk, sig))
+		assert.False(t, VerifySignatureSLHDSA(paramSet, []byte("hello, world!"), pk, sig))
+		assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128s+SLHDSASHA2128f-paramSet, msg, pk, sig))
+	}
+}
+
+func TestSLHDSA_Deterministic(t *testing.T) {
+	sk := SLHDSAKeyFromSeed(SLHDSASHA2128f, bytesRepeat(0x42, 3*slhN))
+	sig1 := SignSLHDSA(SLHDSASHA2128f, sk, []byte("abc"))
+	sig2 := SignSLHDSA(SLHDSASHA2128f, sk, []byte("abc"))
+	assert.Equal(t, sig1, sig2)
+	assert.NotEqual(t, sig1, SignSLHDSA(SLHDSASHA2128f, sk, []byte("abd")))
+}
+
+// These keys and signatures were checked with the SLH-DSA verifier of
+// OpenSSL 3.5.2. They are not NIST ACVP vectors.
+func TestSLHDSA_OpenSSLInterop(t *testing.T) {
+	seeds := make([]byte, 3*slhN)
+	for i := range seeds {
+		seeds[i] = byte(i)
+	}
+	msg := []byte("xsig slh-dsa interop")
+	for _, tc := range []struct {
+		paramSet byte
+		pk       string
+		sigHash  string
+	}{
+		{SLHDSASHA2128s, "202122232425262728292a2b2c2d2e2f990ce6298792b128846a8e4a3a68954c",
+			"66eb9b1d279474a90c6dccaf6a838fcd7ed783379f46644a396e6095b22c25f6"},
+		{SLHDSASHA2128f, "202122232425262728292a2b2c2d2e2f3b56e816847f000386aeec2e2bb9e1b5",
+			"f052703be2f3dcb537415d31ae43f3a44aa47657dbfae05180f425eb1c275e2e"},
+	} {
+		sk := SLHDSAKeyFromSeed(tc.paramSet, seeds)
+		pk := sk[2*slhN:]
+		assert.Equal(t, tc.pk, hex.EncodeToString(pk))
+		sig := SignSLHDSA(tc.paramSet, sk, msg)
+		sigHash := sha256.Sum256(sig)
+		assert.Equal(t, tc.sigHash, hex.EncodeToString(sigHash[:]))
+		assert.True(t, VerifySignatureSLHDSA(tc.paramSet, msg, pk, sig))
+	}
+}
+
+func TestSLHDSA_Invalid(t *testing.T) {
+	msg := []byte("hello")
+	_, pk, sig := HelperVerifyDataSLHDSA(SLHDSASHA2128f, msg)
+	assert.True(t, VerifySignatureSLHDSA(SLHDSASHA2128f, msg, pk, sig))
+
+	// R, the FORS signature, the first and last XMSS signatures
+	for _, i := range []int{0, slhN + 5, slhN + 33*7*slhN, len(sig) - 1} {
+		corrupted := append([]byte{}, sig...)
+		corrupted[i] ^= 0x01
+		assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128f, msg, pk, corrupted), i)
+	}
+	for i := range pk {
+		corrupted := append([]byte{}, pk...)
+		corrupted[i] ^= 0x80
+		assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128f, msg, corrupted, sig), i)
+	}
+	assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128f, msg, pk[:31], sig))
+	assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128f, msg, pk, sig[:len(sig)-1]))
+	assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128f, msg, pk, append(sig, 0)))
+	assert.False(t, VerifySignatureSLHDSA(3, msg, pk, sig))
+	assert.False(t, VerifySignatureSLHDSA(SLHDSASHA2128f, nil, nil, nil))
+}
+
+func bytesRepeat(b byte, n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = b
+	}
+	return out
+}
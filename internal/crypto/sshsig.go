@@ -0,0 +1,290 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+// SSH key types OP_SSHSIGVERIFY accepts, as named in the OpenSSH wire
+// format and in authorized_keys lines.
+const (
+	SSHKeyTypeECDSAP256 = "ecdsa-sha2-nistp256"
+	SSHKeyTypeEd25519   = "ssh-ed25519"
+)
+
+// sshsig is the signature format of ssh-keygen -Y sign, see PROTOCOL.sshsig
+// in OpenSSH.
+const (
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+)
+
+const sshsigArmorBegin = "-----BEGIN SSH SIGNATURE-----"
+const sshsigArmorEnd = "-----END SSH SIGNATURE-----"
+
+// sshReader reads the length-prefixed strings of the SSH wire format.
+type sshReader struct {
+	b  []byte
+	ok bool
+}
+
+func newSSHReader(b []byte) *sshReader {
+	return &sshReader{b: b, ok: true}
+}
+
+func (r *sshReader) bytes(n int) []byte {
+	if !r.ok || len(r.b) < n {
+		r.ok = false
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *sshReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if uint64(n) > uint64(len(r.b)) {
+		r.ok = false
+		return nil
+	}
+	return r.bytes(int(n))
+}
+
+// done reports whether everything was read, with nothing left over.
+func (r *sshReader) done() bool {
+	return r.ok && len(r.b) == 0
+}
+
+func sshString(b []byte) []byte {
+	out := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(out, uint32(len(b)))
+	return append(out, b...)
+}
+
+// SSHPublicKeyBytes encodes a P-256 ECDSA or an Ed25519 public key in the
+// SSH wire format.
+func SSHPublicKeyBytes(pub interface{}) ([]byte, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 ECDSA keys are supported")
+		}
+		out := sshString([]byte(SSHKeyTypeECDSAP256))
+		out = append(out, sshString([]byte("nistp256"))...)
+		return append(out, sshString(elliptic.Marshal(pub.Curve, pub.X, pub.Y))...), nil
+	case ed25519.PublicKey:
+		return append(sshString([]byte(SSHKeyTypeEd25519)), sshString(pub)...), nil
+	}
+	return nil, errors.Errorf("unsupported public key type %T", pub)
+}
+
+// parseSSHPublicKey splits an SSH wire-format public key into its type and
+// key: the uncompressed point for ECDSA, the 32-byte key for Ed25519. Only
+// the structure is checked, not that the key is a valid point.
+func parseSSHPublicKey(b []byte) (keyType string, key []byte, err error) {
+	r := newSSHReader(b)
+	keyType = string(r.string())
+	switch keyType {
+	case SSHKeyTypeECDSAP256:
+		curve := r.string()
+		key = r.string()
+		if !r.done() || string(curve) != "nistp256" || len(key) != 65 || key[0] != 0x04 {
+			return "", nil, errors.New("malformed ecdsa-sha2-nistp256 key")
+		}
+	case SSHKeyTypeEd25519:
+		key = r.string()
+		if !r.done() || len(key) != ed25519.PublicKeySize {
+			return "", nil, errors.New("malformed ssh-ed25519 key")
+		}
+	default:
+		if !r.ok {
+			return "", nil, errors.New("malformed SSH public key")
+		}
+		return "", nil, errors.Errorf("unsupported SSH key type %q", keyType)
+	}
+	return keyType, key, nil
+}
+
+// CheckSSHPublicKey checks that b is an SSH wire-format public key of a
+// supported type.
+func CheckSSHPublicKey(b []byte) error {
+	_, _, err := parseSSHPublicKey(b)
+	return err
+}
+
+// ParseSSHAuthorizedKey imports a public key as found in authorized_keys or
+// a .pub file ("ssh-ed25519 AAAA... comment"), returning its wire format,
+// which is what policies push.
+func ParseSSHAuthorizedKey(line []byte) ([]byte, error) {
+	fields := bytes.Fields(line)
+	if len(fields) < 2 {
+		return nil, errors.New("expected a key type and a base64 key")
+	}
+	b, err := base64.StdEncoding.DecodeString(string(fields[1]))
+	if err != nil {
+		return nil, errors.Wrapf(err, "SSH public key")
+	}
+	keyType, _, err := parseSSHPublicKey(b)
+	if err != nil {
+		return nil, err
+	}
+	if keyType != string(fields[0]) {
+		return nil, errors.Errorf("key type %q does not match the encoded %q", fields[0], keyType)
+	}
+	return b, nil
+}
+
+// ParseSSHSignatureArmor decodes the "-----BEGIN SSH SIGNATURE-----" file
+// written by ssh-keygen -Y sign into the signature blob OP_SSHSIGVERIFY
+// reads.
+func ParseSSHSignatureArmor(armored []byte) ([]byte, error) {
+	s := bytes.TrimSpace(armored)
+	if !bytes.HasPrefix(s, []byte(sshsigArmorBegin)) || !bytes.HasSuffix(s, []byte(sshsigArmorEnd)) {
+		return nil, errors.New("not an SSH signature")
+	}
+	s = s[len(sshsigArmorBegin) : len(s)-len(sshsigArmorEnd)]
+	blob, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(s), nil)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "SSH signature")
+	}
+	return blob, nil
+}
+
+// sshsigHashAlgorithm maps the two hash algorithms sshsig allows to their
+// Hash* constant, and anything else to 0.
+func sshsigHashAlgorithm(hashAlgorithm string) byte {
+	switch hashAlgorithm {
+	case "sha256":
+		return HashSHA256
+	case "sha512":
+		return HashSHA512
+	}
+	return 0
+}
+
+// sshsigSignedData is what the SSH key signs for a message in namespace,
+// given the hashAlgorithm digest of the message.
+func sshsigSignedData(namespace, reserved []byte, hashAlgorithm string, digest []byte) []byte {
+	out := []byte(sshsigMagic)
+	out = append(out, sshString(namespace)...)
+	out = append(out, sshString(reserved)...)
+	out = append(out, sshString([]byte(hashAlgorithm))...)
+	return append(out, sshString(digest)...)
+}
+
+// sshMPInt reads an SSH mpint as an unsigned value of at most 32 bytes.
+// Leading zeros are allowed, as in OpenSSH; negative values are not.
+func sshMPInt(b []byte) (*big.Int, bool) {
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		return nil, false
+	}
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+	if len(b) > 32 {
+		return nil, false
+	}
+	return new(big.Int).SetBytes(b), true
+}
+
+// VerifySSHSig checks an sshsig signature blob over msg: it must be made by
+// publicKey (SSH wire format) in namespace, with a SHA-256 or SHA-512 message
+// hash. The reserved field is signed but otherwise ignored.
+func VerifySSHSig(msg []byte, publicKey []byte, namespace []byte, blob []byte) bool {
+	alg := SSHSigHashAlgorithm(blob)
+	if alg == 0 {
+		return false
+	}
+	return VerifySSHSigDigest(Digest(alg, msg), publicKey, namespace, blob)
+}
+
+// sshsigFields splits a signature blob into the fields VerifySSHSigDigest
+// checks. ok is false if the blob is malformed.
+func sshsigFields(blob []byte) (signer, namespace, reserved []byte, hashAlgorithm string, signature []byte, ok bool) {
+	r := newSSHReader(blob)
+	magic := r.bytes(len(sshsigMagic))
+	version := r.uint32()
+	signer = r.string()
+	namespace = r.string()
+	reserved = r.string()
+	hashAlgorithm = string(r.string())
+	signature = r.string()
+	ok = r.done() && string(magic) == sshsigMagic && version == sshsigVersion
+	return
+}
+
+// SSHSigHashAlgorithm returns the Hash* algorithm the signature blob hashes
+// the message with (HashSHA256 or HashSHA512), or 0 if the blob is malformed
+// or uses another one.
+func SSHSigHashAlgorithm(blob []byte) byte {
+	_, _, _, hashAlgorithm, _, ok := sshsigFields(blob)
+	if !ok {
+		return 0
+	}
+	return sshsigHashAlgorithm(hashAlgorithm)
+}
+
+// VerifySSHSigDigest is VerifySSHSig given the digest of the message with
+// SSHSigHashAlgorithm(blob), computed by the caller.
+func VerifySSHSigDigest(digest []byte, publicKey []byte, namespace []byte, blob []byte) bool {
+	keyType, key, err := parseSSHPublicKey(publicKey)
+	if err != nil {
+		return false
+	}
+
+	signer, sigNamespace, reserved, hashAlgorithm, signature, ok := sshsigFields(blob)
+	if !ok {
+		return false
+	}
+	if !bytes.Equal(signer, publicKey) || !bytes.Equal(sigNamespace, namespace) {
+		return false
+	}
+	alg := sshsigHashAlgorithm(hashAlgorithm)
+	if alg == 0 || len(digest) != HashSize(alg) {
+		return false
+	}
+
+	r := newSSHReader(signature)
+	sigType := string(r.string())
+	sig := r.string()
+	if !r.done() || sigType != keyType {
+		return false
+	}
+	signed := sshsigSignedData(namespace, reserved, hashAlgorithm, digest)
+
+	switch keyType {
+	case SSHKeyTypeEd25519:
+		return len(sig) == ed25519.SignatureSize && ed25519.Verify(key, signed, sig)
+	case SSHKeyTypeECDSAP256:
+		r = newSSHReader(sig)
+		rBytes, sBytes := r.string(), r.string()
+		if !r.done() {
+			return false
+		}
+		rInt, ok1 := sshMPInt(rBytes)
+		sInt, ok2 := sshMPInt(sBytes)
+		x, y := elliptic.Unmarshal(elliptic.P256(), key)
+		if !ok1 || !ok2 || x == nil {
+			return false
+		}
+		hash := sha256.Sum256(signed)
+		return ecdsa.Verify(&ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, hash[:], rInt, sInt)
+	}
+	return false
+}
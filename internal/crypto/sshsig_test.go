@@ -0,0 +1,131 @@
+package crypto
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Keys and signatures made with OpenSSH 9.2:
+//
+//	ssh-keygen -Y sign -f ed -n xsig msg
+//	ssh-keygen -Y sign -f ec -n xsig -O hashalg=sha256 msg
+const (
+	sshTestMsg        = "release 1.0.0"
+	sshTestEd25519Key = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIES4Vz+1NBaOgNYo8cdDpSzLSsK0612fIiqtOI1FSYnR dev@example.com"
+	sshTestECDSAKey   = "ecdsa-sha2-nistp256 AAAAE2VjZHNhLXNoYTItbmlzdHAyNTYAAAAIbmlzdHAyNTYAAABBBDPCPprkF4qLin3bBLWloMyN7QA2vg6hc86KceADLPYnuTdcHCZsi1c6nooUOnINjSGqIxXzMXaPx5/akufg66A= dev2@example.com"
+	sshTestEd25519Sig = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgRLhXP7U0Fo6A1ijxx0OlLMtKwr
+TrXZ8iKq04jUVJidEAAAAEeHNpZwAAAAAAAAAGc2hhNTEyAAAAUwAAAAtzc2gtZWQyNTUx
+OQAAAEBrh2Qpp1zReW37aHv9nJFlslq60KUKAbHkcQO4OUr0nP+ds/ihBceweQPWzDsB/B
+qftt5uzcXNjYCzqiE50hoP
+-----END SSH SIGNATURE-----`
+	sshTestECDSASig = `-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAAGgAAAATZWNkc2Etc2hhMi1uaXN0cDI1NgAAAAhuaXN0cDI1NgAAAE
+EEM8I+muQXiouKfdsEtaWgzI3tADa+DqFzzopx4AMs9ie5N1wcJmyLVzqeihQ6cg2NIaoj
+FfMxdo/Hn9qS5+DroAAAAAR4c2lnAAAAAAAAAAZzaGEyNTYAAABkAAAAE2VjZHNhLXNoYT
+ItbmlzdHAyNTYAAABJAAAAIEa72FLhjcFeBQYQ9cxi/BKecNPYy4u4Cwa2yn8IC5ZNAAAA
+IQDp7vaSe1OB7rmuVr+OSsws1HXv7Q3Kh8cTHyD+4f6JAw==
+-----END SSH SIGNATURE-----`
+)
+
+func TestVerifySSHSig_OpenSSH(t *testing.T) {
+	for _, tc := range []struct{ key, sig string }{
+		{sshTestEd25519Key, sshTestEd25519Sig},
+		{sshTestECDSAKey, sshTestECDSASig},
+	} {
+		pk, err := ParseSSHAuthorizedKey([]byte(tc.key))
+		assert.Nil(t, err)
+		sig, err := ParseSSHSignatureArmor([]byte(tc.sig))
+		assert.Nil(t, err)
+		assert.True(t, VerifySSHSig([]byte(sshTestMsg), pk, []byte("xsig"), sig))
+		assert.False(t, VerifySSHSig([]byte("release 1.0.1"), pk, []byte("xsig"), sig))
+		assert.False(t, VerifySSHSig([]byte(sshTestMsg), pk, []byte("file"), sig))
+		assert.False(t, VerifySSHSig([]byte(sshTestMsg), pk, []byte("xsig"), sig[:len(sig)-1]))
+		assert.False(t, VerifySSHSig([]byte(sshTestMsg), pk, []byte("xsig"), append(sig, 0)))
+	}
+	ed, _ := ParseSSHAuthorizedKey([]byte(sshTestEd25519Key))
+	ecSig, _ := ParseSSHSignatureArmor([]byte(sshTestECDSASig))
+	assert.False(t, VerifySSHSig([]byte(sshTestMsg), ed, []byte("xsig"), ecSig))
+}
+
+func TestVerifySSHSigDigest_OpenSSH(t *testing.T) {
+	for _, tc := range []struct {
+		key, sig string
+		alg      byte
+	}{
+		{sshTestEd25519Key, sshTestEd25519Sig, HashSHA512},
+		{sshTestECDSAKey, sshTestECDSASig, HashSHA256},
+	} {
+		pk, _ := ParseSSHAuthorizedKey([]byte(tc.key))
+		sig, _ := ParseSSHSignatureArmor([]byte(tc.sig))
+		assert.Equal(t, tc.alg, SSHSigHashAlgorithm(sig))
+		digest := Digest(tc.alg, []byte(sshTestMsg))
+		assert.True(t, VerifySSHSigDigest(digest, pk, []byte("xsig"), sig))
+		assert.False(t, VerifySSHSigDigest(digest[:len(digest)-1], pk, []byte("xsig"), sig))
+		assert.False(t, VerifySSHSigDigest(Digest(tc.alg, []byte("release 1.0.1")), pk, []byte("xsig"), sig))
+		assert.Equal(t, byte(0), SSHSigHashAlgorithm(sig[:len(sig)-1]))
+	}
+}
+
+func TestParseSSHAuthorizedKey(t *testing.T) {
+	pk, err := ParseSSHAuthorizedKey([]byte(sshTestEd25519Key))
+	assert.Nil(t, err)
+	assert.Equal(t, 51, len(pk))
+	pk, err = ParseSSHAuthorizedKey([]byte(sshTestECDSAKey + "\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 104, len(pk))
+
+	for _, line := range []string{
+		"",
+		"ssh-ed25519",
+		"ssh-ed25519 !!!",
+		"ecdsa-sha2-nistp256" + sshTestEd25519Key[len("ssh-ed25519"):],
+		"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAAQC7",
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAH0S4Vz+1NBaOgNYo8cdDpSzLSsK0612fIiqtOI1FSYk=",
+	} {
+		_, err := ParseSSHAuthorizedKey([]byte(line))
+		assert.NotNil(t, err, line)
+	}
+}
+
+func TestVerifySSHSig(t *testing.T) {
+	msg := []byte("deploy 42")
+	for _, keyType := range []string{SSHKeyTypeECDSAP256, SSHKeyTypeEd25519} {
+		privateKey, pk, sig := HelperVerifyDataSSH(keyType, []byte("xsig"), msg)
+		assert.True(t, VerifySSHSig(msg, pk, []byte("xsig"), sig))
+		assert.True(t, VerifySSHSig(msg, pk, []byte("xsig"), SignSSHSig(privateKey, []byte("xsig"), "sha256", msg)))
+		assert.False(t, VerifySSHSig(msg, pk, []byte("xsig"), SignSSHSig(privateKey, []byte("xsig"), "sha384", msg)))
+		assert.False(t, VerifySSHSig(msg, pk, []byte("xsig"), SignSSHSig(privateKey, []byte("git"), "sha512", msg)))
+
+		_, otherPK, _ := HelperVerifyDataSSH(keyType, []byte("xsig"), msg)
+		assert.False(t, VerifySSHSig(msg, otherPK, []byte("xsig"), sig))
+		corrupt := append([]byte{}, sig...)
+		corrupt[len(corrupt)-5] ^= 1
+		assert.False(t, VerifySSHSig(msg, pk, []byte("xsig"), corrupt))
+	}
+
+	// the public key embedded in the signature must be the policy's
+	_, pk, sig := HelperVerifyDataSSH(SSHKeyTypeEd25519, []byte("xsig"), msg)
+	_, otherPK, _ := HelperVerifyDataSSH(SSHKeyTypeEd25519, []byte("xsig"), msg)
+	swapped := append(append(append([]byte{}, sig[:14]...), otherPK...), sig[14+len(pk):]...)
+	assert.False(t, VerifySSHSig(msg, pk, []byte("xsig"), swapped))
+	assert.False(t, VerifySSHSig(msg, otherPK, []byte("xsig"), swapped))
+}
+
+func TestSSHMPInt(t *testing.T) {
+	for _, tc := range []struct {
+		b  []byte
+		ok bool
+	}{
+		{nil, true},
+		{[]byte{0x7F}, true},
+		{[]byte{0x80}, false},
+		{[]byte{0, 0x80}, true},
+		{[]byte{0, 0, 1}, true},
+		{append([]byte{0}, bytesRepeat(0xFF, 32)...), true},
+		{append([]byte{1}, bytesRepeat(0xFF, 32)...), false},
+	} {
+		_, ok := sshMPInt(tc.b)
+		assert.Equal(t, tc.ok, ok, tc.b)
+	}
+}
@@ -0,0 +1,73 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// Authenticator data flags checked by OP_WEBAUTHNVERIFY. User presence is
+// always required; user verification (PIN or biometrics) on request.
+const (
+	WebAuthnUserPresent  = byte(0x01)
+	WebAuthnUserVerified = byte(0x04)
+)
+
+// WebAuthnRPIDHashSize is the size of the SHA-256 of the relying party ID
+// that starts the authenticator data.
+const WebAuthnRPIDHashSize = sha256.Size
+
+// Authenticator data is the RP ID hash, a flags byte and a 4-byte signature
+// counter, possibly followed by extensions.
+const webAuthnAuthDataMinSize = WebAuthnRPIDHashSize + 1 + 4
+
+// WebAuthnChallenge is the challenge a client passes to
+// navigator.credentials.get to approve msg: SHA-256(msg).
+func WebAuthnChallenge(msg []byte) []byte {
+	hash := sha256.Sum256(msg)
+	return hash[:]
+}
+
+// WebAuthnClientDataPrefix is how the client data JSON of an assertion over
+// msg must start. Clients serialize the type and the challenge first, in this
+// order and without whitespace, so the JSON needs no parser (the "limited
+// verification algorithm" of the WebAuthn spec).
+func WebAuthnClientDataPrefix(msg []byte) []byte {
+	return webAuthnClientDataPrefix(WebAuthnChallenge(msg))
+}
+
+func webAuthnClientDataPrefix(challenge []byte) []byte {
+	encoded := base64.RawURLEncoding.EncodeToString(challenge)
+	return []byte(`{"type":"webauthn.get","challenge":"` + encoded + `"`)
+}
+
+// VerifyWebAuthnAssertion checks a WebAuthn assertion approving msg: the
+// authenticator data is for rpIDHash with user presence and the flags
+// requested, the client data is a webauthn.get over WebAuthnChallenge(msg),
+// and sig is a P-256 DER signature by publicKey of
+// authData || SHA-256(clientDataJSON). The signature counter and the origin
+// are not checked.
+func VerifyWebAuthnAssertion(msg []byte, publicKey []byte, rpIDHash []byte, flags byte, authData []byte, clientDataJSON []byte, sig []byte) bool {
+	return VerifyWebAuthnAssertionDigest(WebAuthnChallenge(msg), publicKey, rpIDHash, flags, authData, clientDataJSON, sig)
+}
+
+// VerifyWebAuthnAssertionDigest is VerifyWebAuthnAssertion given
+// SHA-256(msg), computed by the caller.
+func VerifyWebAuthnAssertionDigest(hash []byte, publicKey []byte, rpIDHash []byte, flags byte, authData []byte, clientDataJSON []byte, sig []byte) bool {
+	if len(hash) != sha256.Size {
+		return false
+	}
+	if len(authData) < webAuthnAuthDataMinSize || !bytes.Equal(authData[:WebAuthnRPIDHashSize], rpIDHash) {
+		return false
+	}
+	flags |= WebAuthnUserPresent
+	if authData[WebAuthnRPIDHashSize]&flags != flags {
+		return false
+	}
+	if !bytes.HasPrefix(clientDataJSON, webAuthnClientDataPrefix(hash)) {
+		return false
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	return VerifySignature(signed, publicKey, sig)
+}
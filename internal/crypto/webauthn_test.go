@@ -0,0 +1,55 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWebAuthnClientDataPrefix(t *testing.T) {
+	// SHA-256("abc") in base64url, no padding
+	assert.Equal(t, `{"type":"webauthn.get","challenge":"ungWv48Bz-pBQUDeXa4iI7ADYaOWF3qctBD_YfIAFa0"`,
+		string(WebAuthnClientDataPrefix([]byte("abc"))))
+}
+
+func TestVerifyWebAuthnAssertion(t *testing.T) {
+	msg := []byte("release 1.2.0")
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	privateKey, pk, authData, clientData, sig := HelperVerifyDataWebAuthn("example.com", msg)
+
+	assert.True(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, authData, clientData, sig))
+	assert.True(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], WebAuthnUserVerified, authData, clientData, sig))
+	hash := Digest(HashSHA256, msg)
+	assert.True(t, VerifyWebAuthnAssertionDigest(hash, pk, rpIDHash[:], 0, authData, clientData, sig))
+	assert.False(t, VerifyWebAuthnAssertionDigest(hash[:31], pk, rpIDHash[:], 0, authData, clientData, sig))
+	assert.False(t, VerifyWebAuthnAssertion([]byte("release 1.2.1"), pk, rpIDHash[:], 0, authData, clientData, sig))
+	otherRP := sha256.Sum256([]byte("example.org"))
+	assert.False(t, VerifyWebAuthnAssertion(msg, pk, otherRP[:], 0, authData, clientData, sig))
+	assert.False(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, authData[:36], clientData, sig))
+	_, otherPK, _ := HelperVerifyData(nil)
+	assert.False(t, VerifyWebAuthnAssertion(msg, otherPK, rpIDHash[:], 0, authData, clientData, sig))
+
+	// the signature covers the whole client data, not only the checked prefix
+	tampered := append(append([]byte{}, clientData[:len(clientData)-1]...), ' ', '}')
+	assert.False(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, authData, tampered, sig))
+
+	// extensions after the signature counter are signed along
+	withExtensions := append(append([]byte{}, authData...), 0xA0)
+	withExtensions[32] |= 0x80
+	sig = SignWebAuthn(privateKey, withExtensions, clientData)
+	assert.True(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, withExtensions, clientData, sig))
+
+	// user presence is always required, user verification on request
+	presentOnly := WebAuthnAuthData("example.com", WebAuthnUserPresent, 7)
+	sig = SignWebAuthn(privateKey, presentOnly, clientData)
+	assert.True(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, presentOnly, clientData, sig))
+	assert.False(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], WebAuthnUserVerified, presentOnly, clientData, sig))
+	silent := WebAuthnAuthData("example.com", WebAuthnUserVerified, 7)
+	sig = SignWebAuthn(privateKey, silent, clientData)
+	assert.False(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, silent, clientData, sig))
+
+	// registrations are not assertions
+	create := append([]byte(`{"type":"webauthn.create"`), clientData[len(`{"type":"webauthn.get"`):]...)
+	sig = SignWebAuthn(privateKey, authData, create)
+	assert.False(t, VerifyWebAuthnAssertion(msg, pk, rpIDHash[:], 0, authData, create, sig))
+}
@@ -1,6 +1,10 @@
 package lowlevel
 
-import "github.com/pkg/errors"
+import (
+	"crypto/sha256"
+	"github.com/oreparaz/xsig/internal/crypto"
+	"github.com/pkg/errors"
+)
 
 type Assembler struct {
 	Code []byte
@@ -31,6 +35,18 @@ func Not() Instruction {
 	return Instruction{ Opcode: OP_NOT }
 }
 
+func If() Instruction {
+	return Instruction{ Opcode: OP_IF }
+}
+
+func Else() Instruction {
+	return Instruction{ Opcode: OP_ELSE }
+}
+
+func EndIf() Instruction {
+	return Instruction{ Opcode: OP_ENDIF }
+}
+
 func Push1(literal int) Instruction {
 	return Instruction{
 		Opcode: OP_PUSH,
@@ -45,19 +61,335 @@ func Push(data []byte) Instruction {
 	}
 }
 
+// PushLarge adds data, up to 65535 bytes, to the evaluation's objects
+// instead of the stack; see Eval.Objects.
+func PushLarge(data []byte) Instruction {
+	return Instruction{
+		Opcode: OP_PUSHLARGE,
+		Literal: data,
+	}
+}
+
+// MaxPushBlobSize is the largest byte string PushBlob can push: together
+// with its length it has to fit in the literal of a single OP_PUSH. Blobs of
+// up to MaxBlobSize bytes can still be built on the stack with Cat.
+const MaxPushBlobSize = MaxBlobSize - 1
+
+// PushBlob pushes a length-prefixed byte string, see Stack.PushBlob.
+// Assembler.Append rejects it if data is longer than MaxPushBlobSize.
+func PushBlob(data []byte) Instruction {
+	return Push(append([]byte{byte(len(data))}, data...))
+}
+
+// PushNum pushes a multi-byte integer, see EncodeNum.
+func PushNum(x int64) Instruction {
+	return PushBlob(EncodeNum(x))
+}
+
+// Equal compares the two blobs on top of the stack.
+func Equal() Instruction {
+	return Instruction{ Opcode: OP_EQUAL }
+}
+
+// Cat concatenates two blobs: push a, push b, Cat leaves a || b.
+func Cat() Instruction {
+	return Instruction{ Opcode: OP_CAT }
+}
+
+// Split expects a blob and a position (a number) on top of it.
+func Split() Instruction {
+	return Instruction{ Opcode: OP_SPLIT }
+}
+
+func Size() Instruction {
+	return Instruction{ Opcode: OP_SIZE }
+}
+
+func NumAdd() Instruction {
+	return Instruction{ Opcode: OP_NUMADD }
+}
+
+func NumSub() Instruction {
+	return Instruction{ Opcode: OP_NUMSUB }
+}
+
+func NumMul() Instruction {
+	return Instruction{ Opcode: OP_NUMMUL }
+}
+
+func NumLessThan() Instruction {
+	return Instruction{ Opcode: OP_NUMLESSTHAN }
+}
+
+func NumEqual() Instruction {
+	return Instruction{ Opcode: OP_NUMEQUAL }
+}
+
+func ToNum() Instruction {
+	return Instruction{ Opcode: OP_TONUM }
+}
+
 func SignatureVerify() Instruction {
 	return Instruction{ Opcode: OP_SIGVERIFY }
 }
 
+// SignatureVerifyRaw is SignatureVerify for a 64-byte r || s signature,
+// see crypto.RawSignature.
+func SignatureVerifyRaw() Instruction {
+	return Instruction{ Opcode: OP_SIGVERIFYRAW }
+}
+
+// CheckSigFromStack expects, from the top: a public key, the signed message
+// as a blob (see PushBlob) and the signature.
+func CheckSigFromStack() Instruction {
+	return Instruction{ Opcode: OP_CHECKSIGFROMSTACK }
+}
+
 // TODO: make this more ergonomic and include as argument the M/N parameters
 func MultisigVerify() Instruction {
 	return Instruction{ Opcode: OP_MULTISIGVERIFY }
 }
 
+// StrictMultisigVerify is MultisigVerify, except that evaluation fails if
+// a public key or a signature appears twice.
+func StrictMultisigVerify() Instruction {
+	return Instruction{ Opcode: OP_STRICTMULTISIGVERIFY }
+}
+
+// MultisigVerifyRaw is StrictMultisigVerify for 64-byte r || s signatures.
+// It is always strict: a repeated public key or signature fails evaluation.
+func MultisigVerifyRaw() Instruction {
+	return Instruction{ Opcode: OP_MULTISIGVERIFYRAW }
+}
+
+// SignatureVerifySecp256k1 is SignatureVerify for a compressed secp256k1
+// public key.
+func SignatureVerifySecp256k1() Instruction {
+	return Instruction{ Opcode: OP_SIGVERIFYSECP256K1 }
+}
+
+// SignatureVerifySchnorr is SignatureVerify for a 32-byte BIP-340 public key
+// and a 64-byte signature over SHA-256 of the message.
+func SignatureVerifySchnorr() Instruction {
+	return Instruction{ Opcode: OP_SIGVERIFYSCHNORR }
+}
+
+// SignatureVerifyEd25519 is SignatureVerify for a 32-byte Ed25519 public
+// key, such as a FROST group key, and a 64-byte signature.
+func SignatureVerifyEd25519() Instruction {
+	return Instruction{ Opcode: OP_SIGVERIFYED25519 }
+}
+
+// SignatureVerifyHash expects, from the top: a crypto.Hash* algorithm, a
+// key type (crypto.KeyTypeP256, KeyTypeSecp256k1 or KeyTypeP384), a
+// compressed public key of that type and a DER signature over the digest of
+// the message.
+func SignatureVerifyHash() Instruction {
+	return Instruction{ Opcode: OP_SIGVERIFYHASH }
+}
+
+// SigVerifyHash returns the instructions checking a signature pushed by the
+// xsig, made by publicKey over the hashAlg digest of the message.
+func SigVerifyHash(keyType byte, hashAlg byte, publicKey []byte) []Instruction {
+	return []Instruction{Push(publicKey), Push1(int(keyType)), Push1(int(hashAlg)), SignatureVerifyHash()}
+}
+
+// MixedMultisigVerify expects, from the top: N, K, N (key type, public key)
+// pairs and N signature blobs; see MixedMultisig and PushSignatureSlots.
+func MixedMultisigVerify() Instruction {
+	return Instruction{ Opcode: OP_MIXEDMULTISIGVERIFY }
+}
+
+// SLHDSAVerify expects, from the top: a crypto.SLHDSA* parameter set, a
+// public key and the index of the object holding the signature.
+func SLHDSAVerify() Instruction {
+	return Instruction{ Opcode: OP_SLHDSAVERIFY }
+}
+
+// SLHDSASigVerify returns the instructions checking the signature in object
+// number object (the object-th PushLarge of the xsig) under publicKey.
+func SLHDSASigVerify(object int, paramSet byte, publicKey []byte) []Instruction {
+	return []Instruction{Push1(object), Push(publicKey), Push1(int(paramSet)), SLHDSAVerify()}
+}
+
+// RSAVerify expects, from the top: a crypto.RSA* scheme, the 32-byte
+// commitment to the key, the index of the object holding the key and the
+// index of the object holding the signature.
+func RSAVerify() Instruction {
+	return Instruction{ Opcode: OP_RSAVERIFY }
+}
+
+// RSASigVerify returns the instructions checking the signature in object
+// sigObject under the RSA key in object keyObject, which must be the one
+// encoded as publicKeyBytes (crypto.RSAPublicKeyBytes). Only its commitment
+// goes in the code.
+func RSASigVerify(sigObject int, keyObject int, scheme byte, publicKeyBytes []byte) []Instruction {
+	fp := crypto.KeyFingerprint(publicKeyBytes)
+	return []Instruction{Push1(sigObject), Push1(keyObject), Push(fp[:]), Push1(int(scheme)), RSAVerify()}
+}
+
+// WebAuthnVerify expects, from the top: the crypto.WebAuthn* flags required
+// besides user presence, the RP ID hash, a compressed public key, the index
+// of the object holding the client data JSON, the index of the object
+// holding the authenticator data and a DER signature.
+func WebAuthnVerify() Instruction {
+	return Instruction{ Opcode: OP_WEBAUTHNVERIFY }
+}
+
+// WebAuthnSigVerify returns the instructions checking a WebAuthn assertion
+// by the security key publicKey, registered with the relying party rpID,
+// whose authenticator data and client data are objects authDataObject and
+// clientDataObject. The xsig pushes the signature.
+func WebAuthnSigVerify(authDataObject int, clientDataObject int, publicKey []byte, rpID string, flags byte) []Instruction {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	return []Instruction{Push1(authDataObject), Push1(clientDataObject), Push(publicKey), Push(rpIDHash[:]), Push1(int(flags)), WebAuthnVerify()}
+}
+
+// SSHVerify expects, from the top: the namespace and the SSH wire-format
+// public key, both blobs, and the index of the object holding the signature.
+func SSHVerify() Instruction {
+	return Instruction{ Opcode: OP_SSHSIGVERIFY }
+}
+
+// SSHSigVerify returns the instructions checking an ssh-keygen -Y sign
+// signature in object number object, made by publicKey (see
+// crypto.ParseSSHAuthorizedKey) in namespace.
+func SSHSigVerify(object int, publicKey []byte, namespace string) []Instruction {
+	return []Instruction{Push1(object), PushBlob(publicKey), PushBlob([]byte(namespace)), SSHVerify()}
+}
+
+// EthVerify expects, from the top: the mode, the 32-byte domain separator
+// in crypto.EthTypedData mode, a 20-byte address and a 65-byte signature.
+func EthVerify() Instruction {
+	return Instruction{ Opcode: OP_ETHVERIFY }
+}
+
+// EthSigVerify returns the instructions checking a wallet signature by
+// address in the given mode; domainSeparator (see
+// crypto.EIP712DomainSeparator) is only used for crypto.EthTypedData.
+func EthSigVerify(mode byte, domainSeparator []byte, address []byte) []Instruction {
+	ins := []Instruction{Push(address)}
+	if mode == crypto.EthTypedData {
+		ins = append(ins, Push(domainSeparator))
+	}
+	return append(ins, Push1(int(mode)), EthVerify())
+}
+
+// TypedPublicKey is a public key of one of the crypto.KeyType* schemes.
+type TypedPublicKey struct {
+	Type      byte
+	PublicKey []byte
+}
+
+// MixedMultisig returns the instructions for a nMin-of-len(keys) policy
+// over keys of any type, e.g. P-256 and secp256k1 signers in one quorum.
+func MixedMultisig(nMin int, keys []TypedPublicKey) []Instruction {
+	var ins []Instruction
+	for i := len(keys) - 1; i >= 0; i-- {
+		ins = append(ins, Push(keys[i].PublicKey), Push1(int(keys[i].Type)))
+	}
+	return append(ins, Push1(nMin), Push1(len(keys)), MixedMultisigVerify())
+}
+
+// PushSignatureSlots returns the instructions an xsig uses to satisfy
+// MixedMultisig: sigs[i] is the signature for keys[i], nil if that key did
+// not sign.
+func PushSignatureSlots(sigs [][]byte) []Instruction {
+	var ins []Instruction
+	for i := len(sigs) - 1; i >= 0; i-- {
+		ins = append(ins, PushBlob(sigs[i]))
+	}
+	return ins
+}
+
+// WeightedSigVerify expects, from the top: the number of keys N, the target
+// weight, then N (weight, public key) pairs; below them, from the xsig, the
+// number of signatures M and M signatures.
+func WeightedSigVerify() Instruction {
+	return Instruction{ Opcode: OP_WEIGHTEDSIGVERIFY }
+}
+
+// MerkleSigVerify expects, from the top: a 32-byte Merkle root, then the
+// public key, the proof and the signature as pushed by PushMerkleProof.
+func MerkleSigVerify() Instruction {
+	return Instruction{ Opcode: OP_MERKLESIGVERIFY }
+}
+
+// PushMerkleProof returns the instructions an xsig uses to push a Merkle
+// inclusion proof for publicKey, to be pushed after the signature.
+func PushMerkleProof(publicKey []byte, proof []crypto.MerkleStep) []Instruction {
+	var ins []Instruction
+	for i := len(proof) - 1; i >= 0; i-- {
+		side := 0
+		if proof[i].Left {
+			side = 1
+		}
+		ins = append(ins, Push(proof[i].Sibling), Push1(side))
+	}
+	ins = append(ins, Push1(len(proof)), Push(publicKey))
+	return ins
+}
+
+// MsgField pushes the value of the signed message field whose tag is on top
+// of the stack, e.g. Push1(tag), MsgField().
+func MsgField() Instruction {
+	return Instruction{ Opcode: OP_MSGFIELD }
+}
+
+// CheckPKHash expects N key commitments and N pushed on top of the N
+// public keys they commit to; see CheckPKHashes.
+func CheckPKHash() Instruction {
+	return Instruction{ Opcode: OP_CHECKPKHASH }
+}
+
+// CheckPKHashes checks the public keys the xsig pushes against commitments
+// made with crypto.PublicKeyHash, commitments[i] being for the i-th key from
+// the top (so the xsig pushes the key for commitments[0] last). Each
+// commitment is a length-prefixed blob, followed by N and the opcode, so the
+// key set a policy commits to can be read back from its code.
+func CheckPKHashes(commitments [][]byte) []Instruction {
+	var ins []Instruction
+	for i := len(commitments) - 1; i >= 0; i-- {
+		ins = append(ins, PushBlob(commitments[i]))
+	}
+	return append(ins, Push1(len(commitments)), CheckPKHash())
+}
+
+// CheckNonce expects the tag of the message field holding the nonce on top
+// of the stack, e.g. Push1(tag), CheckNonce().
+func CheckNonce() Instruction {
+	return Instruction{ Opcode: OP_CHECKNONCE }
+}
+
+// CheckSecurityVersion expects the version the signers committed to, as a
+// number, on top of the stack. The xpubkey should take it from the signed
+// message, e.g. Push1(tag), MsgField(), CheckSecurityVersion(), next to an
+// OP_SIGVERIFY over that message: a version pushed by the xsig is not
+// covered by any signature, so an old signature could claim a newer one.
+func CheckSecurityVersion() Instruction {
+	return Instruction{ Opcode: OP_CHECKSECURITYVERSION }
+}
+
+// Threshold expects K and N pushed (in that order) on top of N boolean results.
+func Threshold() Instruction {
+	return Instruction{ Opcode: OP_THRESHOLD }
+}
+
+func ToAltStack() Instruction {
+	return Instruction{ Opcode: OP_TOALTSTACK }
+}
+
+func FromAltStack() Instruction {
+	return Instruction{ Opcode: OP_FROMALTSTACK }
+}
+
 func (a *Assembler) Append(in Instruction) error {
 	if in.Opcode == OP_PUSH && len(in.Literal) > 255 {
 		return errors.Errorf("OP_PUSH literal too large: %d bytes (max 255)", len(in.Literal))
 	}
+	if in.Opcode == OP_PUSHLARGE && len(in.Literal) > 65535 {
+		return errors.Errorf("OP_PUSHLARGE literal too large: %d bytes (max 65535)", len(in.Literal))
+	}
 	a.Code = append(a.Code, in.Opcode)
 	if in.Opcode == OP_PUSH {
 		ll := len(in.Literal)
@@ -67,5 +399,11 @@ func (a *Assembler) Append(in Instruction) error {
 			a.Code = append(a.Code, in.Literal[ll-i-1])
 		}
 	}
+	if in.Opcode == OP_PUSHLARGE {
+		// not reversed: objects are read in place, never popped
+		ll := len(in.Literal)
+		a.Code = append(a.Code, byte(ll), byte(ll>>8))
+		a.Code = append(a.Code, in.Literal...)
+	}
 	return nil
 }
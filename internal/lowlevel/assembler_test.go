@@ -1,6 +1,7 @@
 package lowlevel
 
 import (
+	"github.com/oreparaz/xsig/internal/crypto"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -35,7 +36,34 @@ func TestAssembler_AllConstructors(t *testing.T) {
 	assert.Equal(t, OP_OR, Or().Opcode)
 	assert.Equal(t, OP_NOT, Not().Opcode)
 	assert.Equal(t, OP_MULTISIGVERIFY, MultisigVerify().Opcode)
+	assert.Equal(t, OP_STRICTMULTISIGVERIFY, StrictMultisigVerify().Opcode)
 	assert.Equal(t, OP_SIGVERIFY, SignatureVerify().Opcode)
+	assert.Equal(t, OP_SIGVERIFYRAW, SignatureVerifyRaw().Opcode)
+	assert.Equal(t, OP_MULTISIGVERIFYRAW, MultisigVerifyRaw().Opcode)
+	assert.Equal(t, OP_SIGVERIFYSECP256K1, SignatureVerifySecp256k1().Opcode)
+	assert.Equal(t, OP_SIGVERIFYSCHNORR, SignatureVerifySchnorr().Opcode)
+	assert.Equal(t, OP_SIGVERIFYED25519, SignatureVerifyEd25519().Opcode)
+	assert.Equal(t, OP_MIXEDMULTISIGVERIFY, MixedMultisigVerify().Opcode)
+	assert.Equal(t, OP_WEIGHTEDSIGVERIFY, WeightedSigVerify().Opcode)
+	assert.Equal(t, OP_MERKLESIGVERIFY, MerkleSigVerify().Opcode)
+	assert.Equal(t, OP_CHECKSECURITYVERSION, CheckSecurityVersion().Opcode)
+	assert.Equal(t, OP_MSGFIELD, MsgField().Opcode)
+	assert.Equal(t, OP_CHECKNONCE, CheckNonce().Opcode)
+	assert.Equal(t, OP_CHECKPKHASH, CheckPKHash().Opcode)
+	assert.Equal(t, OP_EQUAL, Equal().Opcode)
+	assert.Equal(t, OP_CHECKSIGFROMSTACK, CheckSigFromStack().Opcode)
+	assert.Equal(t, OP_CAT, Cat().Opcode)
+	assert.Equal(t, OP_SPLIT, Split().Opcode)
+	assert.Equal(t, OP_SIZE, Size().Opcode)
+	assert.Equal(t, OP_THRESHOLD, Threshold().Opcode)
+	assert.Equal(t, OP_TOALTSTACK, ToAltStack().Opcode)
+	assert.Equal(t, OP_FROMALTSTACK, FromAltStack().Opcode)
+	assert.Equal(t, OP_NUMADD, NumAdd().Opcode)
+	assert.Equal(t, OP_NUMSUB, NumSub().Opcode)
+	assert.Equal(t, OP_NUMMUL, NumMul().Opcode)
+	assert.Equal(t, OP_NUMLESSTHAN, NumLessThan().Opcode)
+	assert.Equal(t, OP_NUMEQUAL, NumEqual().Opcode)
+	assert.Equal(t, OP_TONUM, ToNum().Opcode)
 
 	// Verify they produce correct bytecode through Append
 	a := Assembler{}
@@ -47,9 +75,73 @@ func TestAssembler_AllConstructors(t *testing.T) {
 	assert.Equal(t, expected, a.Code)
 }
 
+func TestAssembler_ControlFlow(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push1(1))
+	a.Append(If())
+	a.Append(Push1(2))
+	a.Append(Else())
+	a.Append(Push1(3))
+	a.Append(EndIf())
+	expected := []byte{OP_PUSH, 1, 1, OP_IF, OP_PUSH, 1, 2, OP_ELSE, OP_PUSH, 1, 3, OP_ENDIF}
+	assert.Equal(t, expected, a.Code)
+}
+
 func TestAssembler_AppendNonPushReturnsNil(t *testing.T) {
 	a := Assembler{}
 	assert.Nil(t, a.Append(Add()))
 	assert.Nil(t, a.Append(And()))
 	assert.Nil(t, a.Append(SignatureVerify()))
 }
+
+func TestAssembler_PushLarge(t *testing.T) {
+	a := Assembler{}
+	assert.Nil(t, a.Append(PushLarge([]byte{1, 2, 3})))
+	assert.Equal(t, []byte{OP_PUSHLARGE, 3, 0, 1, 2, 3}, a.Code)
+
+	a = Assembler{}
+	assert.Nil(t, a.Append(PushLarge(make([]byte, 300))))
+	assert.Equal(t, []byte{OP_PUSHLARGE, 0x2C, 0x01}, a.Code[:3])
+	assert.Equal(t, 303, len(a.Code))
+
+	assert.NotNil(t, a.Append(PushLarge(make([]byte, 65536))))
+	assert.Equal(t, OP_SLHDSAVERIFY, SLHDSAVerify().Opcode)
+}
+
+func TestAssembler_PushBlob(t *testing.T) {
+	data := make([]byte, MaxPushBlobSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	a := Assembler{}
+	assert.Nil(t, a.Append(PushBlob(data)))
+	assert.Equal(t, []byte{OP_PUSH, 255}, a.Code[:2])
+
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	blob, err := e.Stack.PopBlob()
+	assert.Nil(t, err)
+	assert.Equal(t, data, blob)
+
+	// the length byte no longer fits in the literal
+	for _, n := range []int{MaxPushBlobSize + 1, 300} {
+		a := Assembler{}
+		assert.NotNil(t, a.Append(PushBlob(make([]byte, n))), n)
+		assert.Empty(t, a.Code, n)
+	}
+}
+
+func TestAssembler_RSASigVerify(t *testing.T) {
+	pk := []byte{0, 1, 0, 1, 0xAB}
+	fp := crypto.KeyFingerprint(pk)
+	a := Assembler{}
+	for _, in := range RSASigVerify(1, 0, crypto.RSAPSS, pk) {
+		a.Append(in)
+	}
+	expected := []byte{OP_PUSH, 1, 1, OP_PUSH, 1, 0, OP_PUSH, 32}
+	for i := range fp {
+		expected = append(expected, fp[31-i])
+	}
+	expected = append(expected, OP_PUSH, 1, crypto.RSAPSS, OP_RSAVERIFY)
+	assert.Equal(t, expected, a.Code)
+}
@@ -1,6 +1,7 @@
 package lowlevel
 
 import (
+	"bytes"
 	"github.com/oreparaz/xsig/internal/crypto"
 	"github.com/pkg/errors"
 )
@@ -50,18 +51,358 @@ func (e *Eval) not() error {
 	return nil
 }
 
-func (e *Eval) sigverify(xmsg []byte) error {
+// Number words operate on multi-byte integers (see num.go). Operands are
+// taken in push order: "push x, push y, numsub" computes x - y. Results that
+// do not fit in 64 bits are an error rather than wrapping around.
+
+func (e *Eval) numAdd() error {
+	a, b, err := e.Stack.PopNum2()
+	if err != nil {
+		return errors.Wrapf(err, "numadd")
+	}
+	r, ok := addInt64(b, a)
+	if !ok {
+		return errors.New("numadd: overflow")
+	}
+	return e.Stack.PushNum(r)
+}
+
+func (e *Eval) numSub() error {
+	a, b, err := e.Stack.PopNum2()
+	if err != nil {
+		return errors.Wrapf(err, "numsub")
+	}
+	r, ok := subInt64(b, a)
+	if !ok {
+		return errors.New("numsub: overflow")
+	}
+	return e.Stack.PushNum(r)
+}
+
+func (e *Eval) numMul() error {
+	a, b, err := e.Stack.PopNum2()
+	if err != nil {
+		return errors.Wrapf(err, "nummul")
+	}
+	r, ok := mulInt64(b, a)
+	if !ok {
+		return errors.New("nummul: overflow")
+	}
+	return e.Stack.PushNum(r)
+}
+
+// numLessThan pushes the 8-bit boolean x < y.
+func (e *Eval) numLessThan() error {
+	a, b, err := e.Stack.PopNum2()
+	if err != nil {
+		return errors.Wrapf(err, "numlessthan")
+	}
+	if b < a {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// numEqual pushes the 8-bit boolean x == y.
+func (e *Eval) numEqual() error {
+	a, b, err := e.Stack.PopNum2()
+	if err != nil {
+		return errors.Wrapf(err, "numequal")
+	}
+	if b == a {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// toNum converts an 8-bit word (e.g. a boolean result) into a number.
+func (e *Eval) toNum() error {
+	a, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "tonum")
+	}
+	return e.Stack.PushNum(int64(a))
+}
+
+// threshold pops N (number of conditions), K (number required) and then N
+// boolean results, and pushes 1 if at least K of them are 1.
+func (e *Eval) threshold() error {
+	n, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "threshold")
+	}
+	k, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "threshold")
+	}
+
+	if n == 0 {
+		return errors.New("threshold: n must be > 0")
+	}
+	if k == 0 {
+		return errors.New("threshold: k must be > 0")
+	}
+	if k > n {
+		return errors.Errorf("threshold: k (%d) > n (%d)", k, n)
+	}
+
+	countTrue := 0
+	for i := 0; i < int(n); i++ {
+		cond, err := e.Stack.Pop()
+		if err != nil {
+			return errors.Wrapf(err, "threshold")
+		}
+		if cond > 1 {
+			return errors.Errorf("threshold: condition must be 0 or 1, got %d", cond)
+		}
+		countTrue += int(cond)
+	}
+
+	if countTrue >= int(k) {
+		e.Stack.Push(1)
+	} else {
+		e.Stack.Push(0)
+	}
+	return nil
+}
+
+// toAltStack moves one 8-bit word to the alt stack, so that intermediate
+// results can be set aside while later conditions consume the data below.
+func (e *Eval) toAltStack() error {
+	a, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "toaltstack")
+	}
+	if len(e.AltStack.S) >= MaxAltStackSize {
+		return errors.New("toaltstack: alt stack overflow")
+	}
+	e.AltStack.Push(a)
+	return nil
+}
+
+func (e *Eval) fromAltStack() error {
+	a, err := e.AltStack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "fromaltstack")
+	}
+	return e.Stack.Push(a)
+}
+
+// equal pops two blobs and pushes 1 if they are identical.
+func (e *Eval) equal() error {
+	a, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "equal")
+	}
+	b, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "equal")
+	}
+	if bytes.Equal(a, b) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// cat pops blobs b and a (b on top) and pushes a || b, which must fit in a
+// blob.
+func (e *Eval) cat() error {
+	b, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "cat")
+	}
+	a, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "cat")
+	}
+	if len(a)+len(b) > MaxBlobSize {
+		return errors.Errorf("cat: result too large (%d bytes, max %d)", len(a)+len(b), MaxBlobSize)
+	}
+	return e.Stack.PushBlob(append(a, b...))
+}
+
+// split pops a number n and a blob a, and pushes a[:n] and then a[n:].
+func (e *Eval) split() error {
+	n, err := e.Stack.PopNum()
+	if err != nil {
+		return errors.Wrapf(err, "split")
+	}
+	a, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "split")
+	}
+	if n < 0 || n > int64(len(a)) {
+		return errors.Errorf("split: position %d out of range [0, %d]", n, len(a))
+	}
+	err = e.Stack.PushBlob(a[:n])
+	if err != nil {
+		return err
+	}
+	return e.Stack.PushBlob(a[n:])
+}
+
+// size pushes the length of the blob on top of the stack, as a number,
+// leaving the blob in place.
+func (e *Eval) size() error {
+	a, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "size")
+	}
+	err = e.Stack.PushBlob(a)
+	if err != nil {
+		return err
+	}
+	return e.Stack.PushNum(int64(len(a)))
+}
+
+// msgField pops a tag and pushes the value of that field of the signed
+// message as a blob.
+func (e *Eval) msgField(xmsg []byte) error {
+	tag, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "msgfield")
+	}
+	value, err := LookupMessageField(xmsg, tag)
+	if err != nil {
+		return errors.Wrapf(err, "msgfield")
+	}
+	return e.Stack.PushBlob(value)
+}
+
+// checkNonce pops a tag and pushes 1 if that field of the signed message
+// holds the verifier's nonce. Without a nonce there is nothing to bind the
+// signature to, so that is an error rather than a match on an empty field.
+func (e *Eval) checkNonce(xmsg []byte, nonce []byte) error {
+	tag, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "checknonce")
+	}
+	if len(nonce) == 0 {
+		return errors.New("checknonce: no nonce supplied by the verifier")
+	}
+	value, err := LookupMessageField(xmsg, tag)
+	if err != nil {
+		return errors.Wrapf(err, "checknonce")
+	}
+	if bytes.Equal(value, nonce) {
+		e.NonceChecked = true
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// checkSecurityVersion pops the version v the signers committed to and
+// pushes 1 if v is not older than the verifier's security version.
+func (e *Eval) checkSecurityVersion(securityVersion int64) error {
+	if securityVersion < 0 {
+		return errors.Errorf("checksecurityversion: negative security version %d", securityVersion)
+	}
+	v, err := e.Stack.PopNum()
+	if err != nil {
+		return errors.Wrapf(err, "checksecurityversion")
+	}
+	if v < 0 {
+		return errors.Errorf("checksecurityversion: negative version %d", v)
+	}
+	if v >= securityVersion {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// checkPKHash pops N and N key commitments, then checks the N public keys
+// below them (supplied by the xsig) against the commitments. The keys stay
+// on the stack for the signature opcode that follows.
+func (e *Eval) checkPKHash() error {
+	n, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "checkpkhash")
+	}
+	if n == 0 {
+		return errors.New("checkpkhash: N must be > 0")
+	}
+
+	commitments := make([][]byte, n)
+	for i := range commitments {
+		commitments[i], err = e.Stack.PopBlob()
+		if err != nil {
+			return errors.Wrapf(err, "checkpkhash")
+		}
+		if len(commitments[i]) != 20 && len(commitments[i]) != 32 {
+			return errors.Errorf("checkpkhash: commitment %d is %d bytes, want 20 or 32", i, len(commitments[i]))
+		}
+	}
+
+	pks := make([][]byte, n)
+	for i := range pks {
+		pks[i], err = e.Stack.PopPublicKeyCompressed()
+		if err != nil {
+			return errors.Wrapf(err, "PopPublicKey")
+		}
+		fp := crypto.KeyFingerprint(pks[i])
+		if !bytes.Equal(fp[:len(commitments[i])], commitments[i]) {
+			return errors.Errorf("checkpkhash: public key %d does not match its commitment", i)
+		}
+	}
+
+	// put the keys back as they were, the first one on top
+	for i := len(pks) - 1; i >= 0; i-- {
+		for j := len(pks[i]) - 1; j >= 0; j-- {
+			err = e.Stack.Push(pks[i][j])
+			if err != nil {
+				return errors.Wrapf(err, "checkpkhash")
+			}
+		}
+	}
+	return nil
+}
+
+// verifySignature is crypto.VerifySignature given SHA-256 of the message,
+// except that a key revoked by the verifier never validates.
+func (e *Eval) verifySignature(digest []byte, publicKey []byte, sig []byte) bool {
+	if e.revoked.Contains(publicKey) {
+		return false
+	}
+	return crypto.VerifyDigestSignature(crypto.KeyTypeP256, digest, publicKey, sig)
+}
+
+// popSignature pops a DER signature, or a raw r || s one if raw.
+func (e *Eval) popSignature(raw bool) ([]byte, error) {
+	if raw {
+		return e.Stack.PopBytes(crypto.RawSignatureSize)
+	}
+	return e.Stack.PopSignature()
+}
+
+// verifyEncodedSignature is verifySignature for a signature popped by
+// popSignature.
+func (e *Eval) verifyEncodedSignature(digest []byte, publicKey []byte, sig []byte, raw bool) bool {
+	if !raw {
+		return e.verifySignature(digest, publicKey, sig)
+	}
+	if e.revoked.Contains(publicKey) {
+		return false
+	}
+	return crypto.VerifyRawDigestSignature(digest, publicKey, sig)
+}
+
+// sigverify implements OP_SIGVERIFY, or OP_SIGVERIFYRAW if raw.
+func (e *Eval) sigverify(xmsg []byte, raw bool) error {
 	publicKey, err := e.Stack.PopPublicKeyCompressed()
 	if err != nil {
 		return errors.Wrapf(err, "PopPublicKey")
 	}
 
-	sig, err := e.Stack.PopSignature()
+	sig, err := e.popSignature(raw)
 	if err != nil {
 		return errors.Wrapf(err, "PopSignature")
 	}
 
-	signatureValidates := crypto.VerifySignature(xmsg, publicKey, sig)
+	digest, err := e.messageDigest(xmsg, crypto.HashSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "sigverify")
+	}
+	signatureValidates := e.verifyEncodedSignature(digest, publicKey, sig, raw)
 
 	if signatureValidates {
 		e.Stack.Push(1)
@@ -71,7 +412,354 @@ func (e *Eval) sigverify(xmsg []byte) error {
 	return nil
 }
 
-func (e *Eval) multisigverify(xmsg []byte) error {
+// popTypedPublicKey pops a public key of the given type, see
+// crypto.PublicKeySize.
+func (e *Eval) popTypedPublicKey(keyType byte) ([]byte, error) {
+	switch keyType {
+	case crypto.KeyTypeP256, crypto.KeyTypeSecp256k1:
+		return e.Stack.PopPublicKeyCompressed()
+	case crypto.KeyTypeSchnorr:
+		return e.Stack.PopBytes(crypto.SchnorrPublicKeySize)
+	case crypto.KeyTypeEd25519:
+		return e.Stack.PopBytes(crypto.Ed25519PublicKeySize)
+	}
+	return nil, errors.Errorf("unknown key type %d", keyType)
+}
+
+// verifyTypedSignature is verifySignature for a key of the given type. Only
+// Ed25519 reads msg; the other types sign digest, SHA-256 of msg.
+func (e *Eval) verifyTypedSignature(keyType byte, msg []byte, digest []byte, publicKey []byte, sig []byte) bool {
+	if e.revoked.Contains(publicKey) {
+		return false
+	}
+	switch keyType {
+	case crypto.KeyTypeEd25519:
+		return crypto.VerifyTypedSignature(keyType, msg, publicKey, sig)
+	case crypto.KeyTypeSchnorr:
+		return crypto.VerifySchnorrDigestSignature(digest, publicKey, sig)
+	}
+	return crypto.VerifyDigestSignature(keyType, digest, publicKey, sig)
+}
+
+// sigverifyTyped is sigverify for keys other than P-256:
+// OP_SIGVERIFYSECP256K1 takes a DER signature, OP_SIGVERIFYSCHNORR a 64-byte
+// BIP-340 one and OP_SIGVERIFYED25519 a 64-byte Ed25519 one.
+func (e *Eval) sigverifyTyped(xmsg []byte, keyType byte) error {
+	publicKey, err := e.popTypedPublicKey(keyType)
+	if err != nil {
+		return errors.Wrapf(err, "PopPublicKey")
+	}
+
+	var sig []byte
+	switch keyType {
+	case crypto.KeyTypeSchnorr:
+		sig, err = e.Stack.PopBytes(crypto.SchnorrSignatureSize)
+	case crypto.KeyTypeEd25519:
+		sig, err = e.Stack.PopBytes(crypto.Ed25519SignatureSize)
+	default:
+		sig, err = e.Stack.PopSignature()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "PopSignature")
+	}
+
+	var digest []byte
+	if keyType != crypto.KeyTypeEd25519 {
+		digest, err = e.messageDigest(xmsg, crypto.HashSHA256)
+		if err != nil {
+			return errors.Wrapf(err, "sigverify")
+		}
+	}
+	if e.verifyTypedSignature(keyType, xmsg, digest, publicKey, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// sigverifyHash implements OP_SIGVERIFYHASH. It pops a crypto.Hash*
+// algorithm, a key type (P-256, secp256k1 or P-384), a compressed public key
+// of that type and a DER signature of at most crypto.MaxDERSignatureSize
+// bytes, and pushes whether the signature is valid over the message digest.
+func (e *Eval) sigverifyHash(xmsg []byte) error {
+	alg, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "sigverifyhash")
+	}
+	if crypto.HashSize(alg) == 0 {
+		return errors.Errorf("sigverifyhash: unknown hash algorithm %d", alg)
+	}
+	keyType, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "sigverifyhash")
+	}
+
+	var publicKey []byte
+	switch keyType {
+	case crypto.KeyTypeP256, crypto.KeyTypeSecp256k1:
+		publicKey, err = e.Stack.PopPublicKeyCompressed()
+	case crypto.KeyTypeP384:
+		publicKey, err = e.Stack.PopBytes(crypto.P384PublicKeySize)
+		if err == nil && publicKey[0] != 0x02 && publicKey[0] != 0x03 {
+			err = errors.New("unknown public key format")
+		}
+	default:
+		return errors.Errorf("sigverifyhash: unsupported key type %d", keyType)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "PopPublicKey")
+	}
+
+	sig, err := e.Stack.PopSignature()
+	if err != nil {
+		return errors.Wrapf(err, "PopSignature")
+	}
+	if len(sig) > crypto.MaxDERSignatureSize {
+		return errors.Errorf("sigverifyhash: signature of %d bytes", len(sig))
+	}
+
+	digest, err := e.messageDigest(xmsg, alg)
+	if err != nil {
+		return errors.Wrapf(err, "sigverifyhash")
+	}
+	if !e.revoked.Contains(publicKey) && crypto.VerifyDigestSignature(keyType, digest, publicKey, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// slhdsaVerify implements OP_SLHDSAVERIFY. It pops the parameter set, a
+// 32-byte public key and the index of the object (see OP_PUSHLARGE) holding
+// the signature, and pushes whether it is valid. An empty object stands for
+// a signer who did not sign.
+func (e *Eval) slhdsaVerify(xmsg []byte) error {
+	paramSet, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "slhdsaverify")
+	}
+	if crypto.SLHDSASignatureSize(paramSet) == 0 {
+		return errors.Errorf("slhdsaverify: unknown parameter set %d", paramSet)
+	}
+
+	publicKey, err := e.Stack.PopBytes(crypto.SLHDSAPublicKeySize)
+	if err != nil {
+		return errors.Wrapf(err, "PopPublicKey")
+	}
+
+	sig, err := e.popObject()
+	if err != nil {
+		return errors.Wrapf(err, "slhdsaverify")
+	}
+
+	if !e.revoked.Contains(publicKey) && crypto.VerifySignatureSLHDSA(paramSet, xmsg, publicKey, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// popObject pops an object index and returns that object.
+func (e *Eval) popObject() ([]byte, error) {
+	index, err := e.Stack.Pop()
+	if err != nil {
+		return nil, err
+	}
+	if int(index) >= len(e.Objects) {
+		return nil, errors.Errorf("no object %d (%d pushed)", index, len(e.Objects))
+	}
+	return e.Objects[index], nil
+}
+
+// rsaVerify implements OP_RSAVERIFY. It pops the scheme, a commitment to
+// the key (its crypto.KeyFingerprint), the index of the object holding the
+// key (see crypto.RSAPublicKeyBytes) and the index of the object holding
+// the signature, and pushes whether the signature is valid. The commitment
+// pins the key, so its object can come from the xsig. An empty signature
+// object stands for a signer who did not sign.
+func (e *Eval) rsaVerify(xmsg []byte) error {
+	scheme, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "rsaverify")
+	}
+	if scheme != crypto.RSAPKCS1v15 && scheme != crypto.RSAPSS {
+		return errors.Errorf("rsaverify: unknown scheme %d", scheme)
+	}
+
+	commitment, err := e.Stack.PopBytes(crypto.KeyFingerprintSize)
+	if err != nil {
+		return errors.Wrapf(err, "rsaverify: key commitment")
+	}
+	publicKeyBytes, err := e.popObject()
+	if err != nil {
+		return errors.Wrapf(err, "rsaverify: key")
+	}
+	if fp := crypto.KeyFingerprint(publicKeyBytes); !bytes.Equal(fp[:], commitment) {
+		return errors.New("rsaverify: key does not match its commitment")
+	}
+	publicKey, err := crypto.ParseRSAPublicKey(publicKeyBytes)
+	if err != nil {
+		return errors.Wrapf(err, "rsaverify")
+	}
+
+	sig, err := e.popObject()
+	if err != nil {
+		return errors.Wrapf(err, "rsaverify: signature")
+	}
+
+	digest, err := e.messageDigest(xmsg, crypto.HashSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "rsaverify")
+	}
+	if !e.revoked.Contains(publicKeyBytes) && crypto.VerifyRSADigestSignature(scheme, digest, publicKey, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// webauthnVerify implements OP_WEBAUTHNVERIFY. It pops the required flags,
+// the RP ID hash, the public key, the index of the object holding the client
+// data JSON, the index of the object holding the authenticator data and the
+// DER signature, and pushes whether they make a WebAuthn assertion over xmsg
+// (see crypto.VerifyWebAuthnAssertion).
+func (e *Eval) webauthnVerify(xmsg []byte) error {
+	flags, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify")
+	}
+	rpIDHash, err := e.Stack.PopBytes(crypto.WebAuthnRPIDHashSize)
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify: RP ID hash")
+	}
+	publicKey, err := e.Stack.PopPublicKeyCompressed()
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify")
+	}
+	clientDataJSON, err := e.popObject()
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify: client data")
+	}
+	authData, err := e.popObject()
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify: authenticator data")
+	}
+	sig, err := e.Stack.PopSignature()
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify")
+	}
+
+	digest, err := e.messageDigest(xmsg, crypto.HashSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "webauthnverify")
+	}
+	if !e.revoked.Contains(publicKey) && crypto.VerifyWebAuthnAssertionDigest(digest, publicKey, rpIDHash, flags, authData, clientDataJSON, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// sshsigVerify implements OP_SSHSIGVERIFY. It pops the namespace and the
+// SSH wire-format public key, both blobs, and the index of the object
+// holding the signature blob, and pushes whether that is an sshsig
+// signature of xmsg (see crypto.VerifySSHSig). An empty object stands for a
+// signer who did not sign.
+func (e *Eval) sshsigVerify(xmsg []byte) error {
+	namespace, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "sshsigverify: namespace")
+	}
+	if len(namespace) == 0 {
+		return errors.New("sshsigverify: empty namespace")
+	}
+	publicKey, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "sshsigverify: public key")
+	}
+	if err := crypto.CheckSSHPublicKey(publicKey); err != nil {
+		return errors.Wrapf(err, "sshsigverify")
+	}
+	sig, err := e.popObject()
+	if err != nil {
+		return errors.Wrapf(err, "sshsigverify: signature")
+	}
+
+	// the blob names the hash algorithm; one sshsig does not accept is
+	// just a bad signature
+	alg := crypto.SSHSigHashAlgorithm(sig)
+	if alg == 0 || e.revoked.Contains(publicKey) {
+		return e.Stack.Push(0)
+	}
+	digest, err := e.messageDigest(xmsg, alg)
+	if err != nil {
+		return errors.Wrapf(err, "sshsigverify")
+	}
+	if crypto.VerifySSHSigDigest(digest, publicKey, namespace, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// ethVerify implements OP_ETHVERIFY. It pops the mode, the 32-byte EIP-712
+// domain separator in crypto.EthTypedData mode, a 20-byte Ethereum address
+// and a 65-byte wallet signature, and pushes whether the signature over
+// xmsg recovers to that address (see crypto.VerifyEthSignature).
+func (e *Eval) ethVerify(xmsg []byte) error {
+	mode, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "ethverify")
+	}
+	var domainSeparator []byte
+	switch mode {
+	case crypto.EthPersonalSign:
+	case crypto.EthTypedData:
+		domainSeparator, err = e.Stack.PopBytes(crypto.KeccakSize)
+		if err != nil {
+			return errors.Wrapf(err, "ethverify: domain separator")
+		}
+	default:
+		return errors.Errorf("ethverify: unknown mode %d", mode)
+	}
+	address, err := e.Stack.PopBytes(crypto.EthAddressSize)
+	if err != nil {
+		return errors.Wrapf(err, "ethverify: address")
+	}
+	sig, err := e.Stack.PopBytes(crypto.EthSignatureSize)
+	if err != nil {
+		return errors.Wrapf(err, "ethverify: signature")
+	}
+
+	if !e.revoked.Contains(address) && crypto.VerifyEthSignature(mode, domainSeparator, xmsg, address, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// checkSigFromStack is sigverify over a message taken from the stack instead
+// of xmsg.
+func (e *Eval) checkSigFromStack() error {
+	publicKey, err := e.Stack.PopPublicKeyCompressed()
+	if err != nil {
+		return errors.Wrapf(err, "PopPublicKey")
+	}
+
+	msg, err := e.Stack.PopBlob()
+	if err != nil {
+		return errors.Wrapf(err, "checksigfromstack")
+	}
+
+	sig, err := e.Stack.PopSignature()
+	if err != nil {
+		return errors.Wrapf(err, "PopSignature")
+	}
+
+	if e.verifySignature(crypto.Digest(crypto.HashSHA256, msg), publicKey, sig) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// multisigverify implements OP_MULTISIGVERIFY and, if strict, also
+// OP_STRICTMULTISIGVERIFY: that one fails if the same public key or the same
+// signature appears twice, and spends each signature on at most one key.
+// OP_MULTISIGVERIFYRAW is always strict and takes raw signatures.
+func (e *Eval) multisigverify(xmsg []byte, strict bool, raw bool) error {
 	// N1: number of public keys
 	// N2: number of min signatures required valid
 	// N1 public keys
@@ -105,17 +793,37 @@ func (e *Eval) multisigverify(xmsg []byte) error {
 
 	sigs := make([][]byte, nMinValid)
 	for i:=0; i < int(nMinValid); i++ {
-		sigs[i], err = e.Stack.PopSignature()
+		sigs[i], err = e.popSignature(raw)
 		if err != nil {
 			return errors.Wrapf(err, "PopSignature")
 		}
 	}
 
+	if strict {
+		if i, j, dup := findDuplicate(pk); dup {
+			return errors.Errorf("multisigverify: public keys %d and %d are the same", i, j)
+		}
+		if i, j, dup := findDuplicate(sigs); dup {
+			return errors.Errorf("multisigverify: signatures %d and %d are the same", i, j)
+		}
+	}
+
+	// hashed once for all the N1 x N2 attempts
+	digest, err := e.messageDigest(xmsg, crypto.HashSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "multisigverify")
+	}
+
+	used := make([]bool, nMinValid)
 	countValid := 0
 OUTER:
 	for i:=0; i < int(nPublicKeys); i++ {
 		for j:=0; j < int(nMinValid); j++ {
-			if crypto.VerifySignature(xmsg, pk[i], sigs[j]) {
+			if strict && used[j] {
+				continue
+			}
+			if e.verifyEncodedSignature(digest, pk[i], sigs[j], raw) {
+				used[j] = true
 				countValid++
 				continue OUTER
 			}
@@ -130,3 +838,247 @@ OUTER:
 
 	return nil
 }
+
+// mixedMultisigVerify implements OP_MIXEDMULTISIGVERIFY, a K-of-N over keys
+// of different types. Signatures are positional, so no signature can count
+// twice; a key that did not sign gets an empty one.
+func (e *Eval) mixedMultisigVerify(xmsg []byte) error {
+	// N: number of public keys
+	// K: number of valid signatures required
+	// N (key type, public key) pairs
+	// N signatures as blobs, the one for the first key on top
+	nPublicKeys, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "mixedmultisigverify")
+	}
+	nMinValid, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "mixedmultisigverify")
+	}
+
+	if nPublicKeys == 0 {
+		return errors.New("mixedmultisigverify: nPublicKeys must be > 0")
+	}
+	if nMinValid == 0 {
+		return errors.New("mixedmultisigverify: nMinValid must be > 0")
+	}
+	if nMinValid > nPublicKeys {
+		return errors.Errorf("mixedmultisigverify: nMinValid (%d) > nPublicKeys (%d)", nMinValid, nPublicKeys)
+	}
+
+	keyTypes := make([]byte, nPublicKeys)
+	typedKeys := make([][]byte, nPublicKeys) // type || key, to spot duplicates
+	for i := 0; i < int(nPublicKeys); i++ {
+		keyTypes[i], err = e.Stack.Pop()
+		if err != nil {
+			return errors.Wrapf(err, "mixedmultisigverify")
+		}
+		pk, err := e.popTypedPublicKey(keyTypes[i])
+		if err != nil {
+			return errors.Wrapf(err, "mixedmultisigverify: key %d", i)
+		}
+		typedKeys[i] = append([]byte{keyTypes[i]}, pk...)
+	}
+	if i, j, dup := findDuplicate(typedKeys); dup {
+		return errors.Errorf("mixedmultisigverify: public keys %d and %d are the same", i, j)
+	}
+
+	sigs := make([][]byte, nPublicKeys)
+	for i := range sigs {
+		sigs[i], err = e.Stack.PopBlob()
+		if err != nil {
+			return errors.Wrapf(err, "mixedmultisigverify: signature %d", i)
+		}
+	}
+
+	var digest []byte
+	for i := range keyTypes {
+		if keyTypes[i] == crypto.KeyTypeEd25519 {
+			if e.prehashed {
+				return errors.Errorf("mixedmultisigverify: Ed25519 key %d needs the message, which prehashed mode does not have", i)
+			}
+		} else if digest == nil {
+			digest, err = e.messageDigest(xmsg, crypto.HashSHA256)
+			if err != nil {
+				return errors.Wrapf(err, "mixedmultisigverify")
+			}
+		}
+	}
+
+	countValid := 0
+	for i := range sigs {
+		if len(sigs[i]) > 0 && e.verifyTypedSignature(keyTypes[i], xmsg, digest, typedKeys[i][1:], sigs[i]) {
+			countValid++
+		}
+	}
+
+	if countValid >= int(nMinValid) {
+		return e.Stack.Push(1)
+	}
+	return e.Stack.Push(0)
+}
+
+// findDuplicate returns the indices of the first two equal items, if any.
+func findDuplicate(items [][]byte) (int, int, bool) {
+	for j := range items {
+		for i := 0; i < j; i++ {
+			if bytes.Equal(items[i], items[j]) {
+				return i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func (e *Eval) weightedsigverify(xmsg []byte) error {
+	// N1: number of public keys
+	// T: target weight (number)
+	// N1 (weight (number), public key) pairs
+	// N2: number of signatures (pushed by the xsig)
+	// N2 signatures
+	nPublicKeys, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "weightedsigverify")
+	}
+	target, err := e.Stack.PopNum()
+	if err != nil {
+		return errors.Wrapf(err, "weightedsigverify")
+	}
+
+	if nPublicKeys == 0 {
+		return errors.New("weightedsigverify: nPublicKeys must be > 0")
+	}
+	if target <= 0 {
+		return errors.New("weightedsigverify: target must be > 0")
+	}
+
+	weights := make([]int64, nPublicKeys)
+	pk := make([][]byte, nPublicKeys)
+	for i := 0; i < int(nPublicKeys); i++ {
+		weights[i], err = e.Stack.PopNum()
+		if err != nil {
+			return errors.Wrapf(err, "weightedsigverify")
+		}
+		if weights[i] <= 0 {
+			return errors.Errorf("weightedsigverify: weight %d must be > 0", i)
+		}
+		pk[i], err = e.Stack.PopPublicKeyCompressed()
+		if err != nil {
+			return errors.Wrapf(err, "PopPublicKey")
+		}
+	}
+	// a key listed twice would count its weight twice
+	if i, j, dup := findDuplicate(pk); dup {
+		return errors.Errorf("weightedsigverify: public keys %d and %d are the same", i, j)
+	}
+
+	nSignatures, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "weightedsigverify")
+	}
+	if nSignatures > nPublicKeys {
+		return errors.Errorf("weightedsigverify: nSignatures (%d) > nPublicKeys (%d)", nSignatures, nPublicKeys)
+	}
+
+	sigs := make([][]byte, nSignatures)
+	for i := 0; i < int(nSignatures); i++ {
+		sigs[i], err = e.Stack.PopSignature()
+		if err != nil {
+			return errors.Wrapf(err, "PopSignature")
+		}
+	}
+
+	digest, err := e.messageDigest(xmsg, crypto.HashSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "weightedsigverify")
+	}
+
+	// each key counts at most once, and each signature is spent on at
+	// most one key
+	used := make([]bool, nSignatures)
+	var sum int64
+OUTER:
+	for i := 0; i < int(nPublicKeys); i++ {
+		for j := 0; j < int(nSignatures); j++ {
+			if used[j] {
+				continue
+			}
+			if e.verifySignature(digest, pk[i], sigs[j]) {
+				used[j] = true
+				var ok bool
+				sum, ok = addInt64(sum, weights[i])
+				if !ok {
+					return errors.New("weightedsigverify: overflow")
+				}
+				continue OUTER
+			}
+		}
+	}
+
+	if sum >= target {
+		e.Stack.Push(1)
+	} else {
+		e.Stack.Push(0)
+	}
+
+	return nil
+}
+
+func (e *Eval) merklesigverify(xmsg []byte) error {
+	// 32-byte Merkle root (pushed by the xpubkey)
+	// public key (the leaf being proven)
+	// D: proof depth
+	// D (side, sibling) steps from the leaf upwards; side 1 means the
+	//    sibling is the left child
+	// signature
+	root, err := e.Stack.PopBytes(32)
+	if err != nil {
+		return errors.Wrapf(err, "merklesigverify")
+	}
+	publicKey, err := e.Stack.PopPublicKeyCompressed()
+	if err != nil {
+		return errors.Wrapf(err, "PopPublicKey")
+	}
+	depth, err := e.Stack.Pop()
+	if err != nil {
+		return errors.Wrapf(err, "merklesigverify")
+	}
+	if depth > MaxMerkleDepth {
+		return errors.Errorf("merklesigverify: proof depth %d > %d", depth, MaxMerkleDepth)
+	}
+
+	proof := make([]crypto.MerkleStep, depth)
+	for i := range proof {
+		side, err := e.Stack.Pop()
+		if err != nil {
+			return errors.Wrapf(err, "merklesigverify")
+		}
+		if side > 1 {
+			return errors.Errorf("merklesigverify: side must be 0 or 1, got %d", side)
+		}
+		sibling, err := e.Stack.PopBytes(32)
+		if err != nil {
+			return errors.Wrapf(err, "merklesigverify")
+		}
+		proof[i] = crypto.MerkleStep{Sibling: sibling, Left: side == 1}
+	}
+
+	sig, err := e.Stack.PopSignature()
+	if err != nil {
+		return errors.Wrapf(err, "PopSignature")
+	}
+
+	digest, err := e.messageDigest(xmsg, crypto.HashSHA256)
+	if err != nil {
+		return errors.Wrapf(err, "merklesigverify")
+	}
+
+	// a key outside the tree is treated like a bad signature
+	if bytes.Equal(crypto.MerkleRootFromProof(publicKey, proof), root) &&
+		e.verifySignature(digest, publicKey, sig) {
+		e.Stack.Push(1)
+	} else {
+		e.Stack.Push(0)
+	}
+	return nil
+}
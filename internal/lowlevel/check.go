@@ -0,0 +1,223 @@
+package lowlevel
+
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	"github.com/pkg/errors"
+)
+
+// CheckCode looks for mistakes in assembled code that evaluation alone
+// would not reveal. It tracks the bytes pushed by consecutive OP_PUSH
+// instructions and rejects an OP_MULTISIGVERIFY (or its strict and raw
+// variants), OP_WEIGHTEDSIGVERIFY or OP_MIXEDMULTISIGVERIFY whose
+// parameters and key list, pushed right before it, name the same public key
+// twice: with OP_MULTISIGVERIFY that lets one signer count as several, the
+// other two fail on it. Key lists that come from elsewhere (e.g. from the
+// xsig, checked with OP_CHECKPKHASH) cannot be checked statically and are
+// accepted.
+func CheckCode(code []byte) error {
+	var stack []byte // bytes pushed since the last other instruction
+	for pc := 0; pc < len(code); {
+		n, err := instructionLength(code, pc)
+		if err != nil {
+			return err
+		}
+		var check func(staticStack) error
+		switch code[pc] {
+		case OP_PUSH:
+			stack = append(stack, code[pc+2:pc+n]...)
+			pc += n
+			continue
+		case OP_MULTISIGVERIFY, OP_STRICTMULTISIGVERIFY, OP_MULTISIGVERIFYRAW:
+			check = checkMultisigKeys
+		case OP_WEIGHTEDSIGVERIFY:
+			check = checkWeightedKeys
+		case OP_MIXEDMULTISIGVERIFY:
+			check = checkMixedMultisigKeys
+		}
+		if check != nil {
+			err := check(staticStack(stack))
+			if err != nil {
+				return errors.Wrapf(err, "offset %d", pc)
+			}
+		}
+		stack = stack[:0]
+		pc += n
+	}
+	return nil
+}
+
+// staticStack is the stack as left by the pushes right before an
+// instruction. A pop that runs out of it means the operand comes from
+// elsewhere.
+type staticStack []byte
+
+func (s *staticStack) pop(n int) ([]byte, bool) {
+	if n > len(*s) {
+		return nil, false
+	}
+	x := (*s)[len(*s)-n:]
+	*s = (*s)[:len(*s)-n]
+	return x, true
+}
+
+func (s *staticStack) popByte() (byte, bool) {
+	x, ok := s.pop(1)
+	if !ok {
+		return 0, false
+	}
+	return x[0], true
+}
+
+func (s *staticStack) popBlob() ([]byte, bool) {
+	n, ok := s.popByte()
+	if !ok {
+		return nil, false
+	}
+	return s.pop(int(n))
+}
+
+// checkMultisigKeys checks the key list of a multisig whose operands are
+// on stack, if they are all there.
+func checkMultisigKeys(stack staticStack) error {
+	nPublicKeys, ok := stack.popByte()
+	if !ok {
+		return nil
+	}
+	if _, ok := stack.popByte(); !ok {
+		return nil
+	}
+	pks := make([][]byte, nPublicKeys)
+	for i := range pks {
+		if pks[i], ok = stack.pop(33); !ok {
+			return nil
+		}
+	}
+	if i, j, dup := findDuplicate(pks); dup {
+		return errors.Errorf("multisig lists the same public key as keys %d and %d", i, j)
+	}
+	return nil
+}
+
+// checkWeightedKeys is checkMultisigKeys for OP_WEIGHTEDSIGVERIFY, whose
+// keys each come with a weight.
+func checkWeightedKeys(stack staticStack) error {
+	nPublicKeys, ok := stack.popByte()
+	if !ok {
+		return nil
+	}
+	if _, ok := stack.popBlob(); !ok {
+		return nil
+	}
+	pks := make([][]byte, nPublicKeys)
+	for i := range pks {
+		if _, ok := stack.popBlob(); !ok {
+			return nil
+		}
+		if pks[i], ok = stack.pop(33); !ok {
+			return nil
+		}
+	}
+	if i, j, dup := findDuplicate(pks); dup {
+		return errors.Errorf("weighted multisig lists the same public key as keys %d and %d", i, j)
+	}
+	return nil
+}
+
+// checkMixedMultisigKeys is checkMultisigKeys for OP_MIXEDMULTISIGVERIFY,
+// whose keys each come with their type.
+func checkMixedMultisigKeys(stack staticStack) error {
+	nPublicKeys, ok := stack.popByte()
+	if !ok {
+		return nil
+	}
+	if _, ok := stack.popByte(); !ok {
+		return nil
+	}
+	typedKeys := make([][]byte, nPublicKeys)
+	for i := range typedKeys {
+		keyType, ok := stack.popByte()
+		if !ok {
+			return nil
+		}
+		size := crypto.PublicKeySize(keyType)
+		if size == 0 {
+			// evaluation fails on it anyway
+			return nil
+		}
+		pk, ok := stack.pop(size)
+		if !ok {
+			return nil
+		}
+		typedKeys[i] = append([]byte{keyType}, pk...)
+	}
+	if i, j, dup := findDuplicate(typedKeys); dup {
+		return errors.Errorf("mixed multisig lists the same public key as keys %d and %d", i, j)
+	}
+	return nil
+}
+
+// DigestAlgorithms returns the crypto.Hash* digests of the message that an
+// evaluation of code may ask for, in increasing order, so that a verifier
+// can compute all of them in one pass over a large message and evaluate in
+// prehashed mode (see Context.Digests). SHA-256 is always included. The
+// algorithm of an OP_SIGVERIFYHASH is the byte pushed right before it; if
+// it comes from elsewhere, every algorithm is included. It fails if code
+// has an opcode that needs the message itself, such as OP_SIGVERIFYED25519,
+// even in a branch that might not be taken.
+func DigestAlgorithms(code []byte) ([]byte, error) {
+	algs := map[byte]bool{crypto.HashSHA256: true}
+	var stack []byte // bytes pushed since the last other instruction
+	for pc := 0; pc < len(code); {
+		n, err := instructionLength(code, pc)
+		if err != nil {
+			return nil, err
+		}
+		opcode := code[pc]
+		if messageOpcodes[opcode] {
+			return nil, errors.Errorf("offset %d: opcode %v needs the message, not a digest", pc, opcode)
+		}
+		switch opcode {
+		case OP_PUSH:
+			stack = append(stack, code[pc+2:pc+n]...)
+		case OP_SIGVERIFYHASH:
+			if len(stack) > 0 && crypto.HashSize(stack[len(stack)-1]) != 0 {
+				algs[stack[len(stack)-1]] = true
+			} else {
+				for _, alg := range []byte{crypto.HashSHA256, crypto.HashSHA384, crypto.HashSHA512, crypto.HashSHA3_256} {
+					algs[alg] = true
+				}
+			}
+			stack = stack[:0]
+		case OP_SSHSIGVERIFY:
+			// the signature blob names SHA-256 or SHA-512
+			algs[crypto.HashSHA512] = true
+			stack = stack[:0]
+		default:
+			stack = stack[:0]
+		}
+		pc += n
+	}
+	var out []byte
+	for alg := 0; alg < 256; alg++ {
+		if algs[byte(alg)] {
+			out = append(out, byte(alg))
+		}
+	}
+	return out, nil
+}
+
+// HasOpcode reports whether code has an instruction with the given opcode,
+// in any branch. Bytes pushed by OP_PUSH and OP_PUSHLARGE are skipped.
+func HasOpcode(code []byte, opcode byte) (bool, error) {
+	for pc := 0; pc < len(code); {
+		n, err := instructionLength(code, pc)
+		if err != nil {
+			return false, err
+		}
+		if code[pc] == opcode {
+			return true, nil
+		}
+		pc += n
+	}
+	return false, nil
+}
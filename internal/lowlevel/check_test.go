@@ -0,0 +1,175 @@
+package lowlevel
+
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckCode_Multisig(t *testing.T) {
+	_, pk1, _ := crypto.HelperVerifyData(nil)
+	_, pk2, _ := crypto.HelperVerifyData(nil)
+	_, pk3, _ := crypto.HelperVerifyData(nil)
+
+	multisig := func(op Instruction, pks ...[]byte) []byte {
+		a := Assembler{}
+		for _, pk := range pks {
+			a.Append(Push(pk))
+		}
+		a.Append(Push1(2))
+		a.Append(Push1(len(pks)))
+		a.Append(op)
+		return a.Code
+	}
+
+	for _, op := range []Instruction{MultisigVerify(), StrictMultisigVerify()} {
+		assert.Nil(t, CheckCode(multisig(op, pk1, pk2, pk3)))
+		assert.NotNil(t, CheckCode(multisig(op, pk1, pk2, pk1)))
+		assert.NotNil(t, CheckCode(multisig(op, pk2, pk2, pk3)))
+	}
+
+	// the parameters pushed in one go, with the keys
+	a := Assembler{}
+	a.Append(Push(append(append([]byte{2, 2}, pk1...), pk1...)))
+	a.Append(MultisigVerify())
+	assert.NotNil(t, CheckCode(a.Code))
+
+	// a duplicate below the listed keys does not matter
+	a = Assembler{}
+	a.Append(Push(pk1))
+	for _, pk := range [][]byte{pk1, pk2, pk3} {
+		a.Append(Push(pk))
+	}
+	a.Append(Push1(2))
+	a.Append(Push1(3))
+	a.Append(MultisigVerify())
+	assert.Nil(t, CheckCode(a.Code))
+
+	// keys supplied by the xsig cannot be checked
+	a = Assembler{}
+	a.Append(Push1(1))
+	a.Append(Push1(3))
+	a.Append(MultisigVerify())
+	assert.Nil(t, CheckCode(a.Code))
+
+	// an instruction in between breaks the chain of pushes
+	a = Assembler{}
+	a.Append(Push(pk1))
+	a.Append(Push(pk1))
+	a.Append(Push1(1))
+	a.Append(Add())
+	a.Append(Push1(2))
+	a.Append(MultisigVerify())
+	assert.Nil(t, CheckCode(a.Code))
+
+	// an object is skipped whole, even if its bytes look like opcodes
+	a = Assembler{}
+	a.Append(PushLarge([]byte{OP_PUSH, 1, OP_MULTISIGVERIFY}))
+	a.Append(Push1(1))
+	assert.Nil(t, CheckCode(a.Code))
+
+	assert.NotNil(t, CheckCode([]byte{OP_PUSH, 4, 1}))
+	assert.NotNil(t, CheckCode([]byte{OP_PUSHLARGE, 2, 0, 1}))
+	assert.NotNil(t, CheckCode([]byte{OP_PUSHLARGE, 2}))
+}
+
+func TestCheckCode_WeightedSigVerify(t *testing.T) {
+	_, pk1, _ := crypto.HelperVerifyData(nil)
+	_, pk2, _ := crypto.HelperVerifyData(nil)
+
+	assert.Nil(t, CheckCode(weightedProgram(nil, [][]byte{pk1, pk2}, []int64{3, 1}, 3)))
+	assert.NotNil(t, CheckCode(weightedProgram(nil, [][]byte{pk1, pk1}, []int64{2, 2}, 4)))
+	assert.NotNil(t, CheckCode(weightedProgram(nil, [][]byte{pk1, pk2, pk1}, []int64{1, 300, 1}, 2)))
+
+	// weights supplied by the xsig cannot be checked
+	a := Assembler{}
+	a.Append(Push(pk1))
+	a.Append(ToAltStack())
+	a.Append(Push(pk1))
+	a.Append(PushNum(1))
+	a.Append(PushNum(1))
+	a.Append(Push1(2))
+	a.Append(WeightedSigVerify())
+	assert.Nil(t, CheckCode(a.Code))
+}
+
+func TestCheckCode_MixedMultisig(t *testing.T) {
+	_, pk1, _ := crypto.HelperVerifyData(nil)
+	_, pk2, _ := crypto.HelperVerifyData(nil)
+
+	check := func(keys ...TypedPublicKey) error {
+		a := Assembler{}
+		for _, in := range MixedMultisig(1, keys) {
+			a.Append(in)
+		}
+		return CheckCode(a.Code)
+	}
+	p256 := func(pk []byte) TypedPublicKey { return TypedPublicKey{Type: crypto.KeyTypeP256, PublicKey: pk} }
+
+	assert.Nil(t, check(p256(pk1), p256(pk2)))
+	assert.NotNil(t, check(p256(pk1), p256(pk2), p256(pk1)))
+	// the same bytes as keys of different types are different keys
+	assert.Nil(t, check(p256(pk1), TypedPublicKey{Type: crypto.KeyTypeSecp256k1, PublicKey: pk1}))
+	assert.Nil(t, check(TypedPublicKey{Type: crypto.KeyTypeSchnorr, PublicKey: pk1[1:]},
+		TypedPublicKey{Type: crypto.KeyTypeEd25519, PublicKey: pk1[1:]}))
+	assert.NotNil(t, check(TypedPublicKey{Type: crypto.KeyTypeEd25519, PublicKey: pk1[1:]},
+		TypedPublicKey{Type: crypto.KeyTypeEd25519, PublicKey: pk1[1:]}))
+	// evaluation rejects an unknown type
+	assert.Nil(t, check(TypedPublicKey{Type: 99, PublicKey: pk1}, TypedPublicKey{Type: 99, PublicKey: pk1}))
+}
+
+func TestDigestAlgorithms(t *testing.T) {
+	_, pk, _ := crypto.HelperVerifyData(nil)
+	assemble := func(ins ...Instruction) []byte {
+		a := Assembler{}
+		for _, in := range ins {
+			a.Append(in)
+		}
+		return a.Code
+	}
+
+	algs, err := DigestAlgorithms(assemble(Push(pk), SignatureVerify()))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{crypto.HashSHA256}, algs)
+
+	algs, err = DigestAlgorithms(assemble(append(SigVerifyHash(crypto.KeyTypeP256, crypto.HashSHA3_256, pk),
+		SigVerifyHash(crypto.KeyTypeP256, crypto.HashSHA384, pk)...)...))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{crypto.HashSHA256, crypto.HashSHA384, crypto.HashSHA3_256}, algs)
+
+	// an algorithm the scan cannot see could be any of them
+	algs, err = DigestAlgorithms(assemble(Push(pk), Push1(int(crypto.KeyTypeP256)), ToAltStack(), FromAltStack(), SignatureVerifyHash()))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{crypto.HashSHA256, crypto.HashSHA384, crypto.HashSHA512, crypto.HashSHA3_256}, algs)
+
+	algs, err = DigestAlgorithms(assemble(SSHSigVerify(0, []byte("key"), "xsig")...))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{crypto.HashSHA256, crypto.HashSHA512}, algs)
+
+	_, err = DigestAlgorithms(assemble(Push(pk), SignatureVerifyEd25519()))
+	assert.NotNil(t, err)
+	_, err = DigestAlgorithms([]byte{OP_PUSH, 1, 0, OP_IF, OP_MSGFIELD, OP_ENDIF})
+	assert.NotNil(t, err)
+	_, err = DigestAlgorithms([]byte{OP_PUSH, 5, 1})
+	assert.NotNil(t, err)
+}
+
+func TestHasOpcode(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push1(2))
+	a.Append(CheckNonce())
+	has, err := HasOpcode(a.Code, OP_CHECKNONCE)
+	assert.Nil(t, err)
+	assert.True(t, has)
+
+	// the opcode as pushed data
+	a = Assembler{}
+	a.Append(Push1(int(OP_CHECKNONCE)))
+	a.Append(PushLarge([]byte{OP_CHECKNONCE}))
+	has, err = HasOpcode(a.Code, OP_CHECKNONCE)
+	assert.Nil(t, err)
+	assert.False(t, has)
+
+	_, err = HasOpcode([]byte{OP_PUSH, 2, OP_CHECKNONCE}, OP_CHECKNONCE)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,36 @@
+package lowlevel
+
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+)
+
+// Context is what an evaluation can observe besides the program and its
+// stack: the signed message and any state supplied by the verifier. The zero
+// value is an empty message with security version 0 and no revoked keys.
+type Context struct {
+	Xmsg []byte
+	// SecurityVersion is the verifier's current monotonic security version
+	// (e.g. the anti-rollback counter of a bootloader), compared against by
+	// OP_CHECKSECURITYVERSION. Must be >= 0.
+	SecurityVersion int64
+	// Revoked holds keys the verifier no longer trusts. Every signature
+	// opcode treats a revoked key as not matching any signature. May be nil.
+	Revoked RevocationSet
+	// Nonce is a fresh challenge issued by the verifier. OP_CHECKNONCE
+	// requires the signed message to contain it, which makes an xsig
+	// single-use when each nonce is accepted only once (see pkg.NonceStore).
+	Nonce []byte
+	// Digests, if not nil, switches to prehashed mode: the message itself is
+	// not available, only its digests by crypto.Hash* algorithm, and Xmsg is
+	// ignored. OP_SIGVERIFYHASH checks signatures over them; opcodes that
+	// need the message fail.
+	Digests map[byte][]byte
+}
+
+// RevocationSet is a set of key fingerprints, see crypto.KeyFingerprint.
+type RevocationSet map[[crypto.KeyFingerprintSize]byte]bool
+
+// Contains reports whether publicKey is revoked. A nil set revokes nothing.
+func (r RevocationSet) Contains(publicKey []byte) bool {
+	return r[crypto.KeyFingerprint(publicKey)]
+}
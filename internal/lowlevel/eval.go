@@ -1,6 +1,7 @@
 package lowlevel
 
 import (
+	"github.com/oreparaz/xsig/internal/crypto"
 	"github.com/pkg/errors"
 )
 
@@ -9,9 +10,60 @@ type Word struct {
 	Function func() error
 }
 
+// MaxBranchDepth bounds how deeply OP_IF blocks can be nested.
+const MaxBranchDepth = 16
+
+// branch tracks one open OP_IF block.
+type branch struct {
+	taken   bool // whether the code currently being visited runs
+	sawElse bool
+}
+
+// MaxAltStackSize bounds the alt stack, which only holds small intermediate
+// results (e.g. the outcome of each sub-condition of a threshold).
+const MaxAltStackSize = 64
+
+// MaxMerkleDepth bounds the length of a Merkle inclusion proof. 2^24 leaves
+// is far beyond any allowlist, and a proof that long already takes ~800
+// bytes of stack.
+const MaxMerkleDepth = 24
+
+// MaxObjects bounds the number of large objects (see OP_PUSHLARGE) a single
+// evaluation can hold. Each is at most 65535 bytes and is not copied: it
+// points into the code.
+const MaxObjects = 8
+
 type Eval struct {
 	Stack      Stack
+	AltStack   Stack
 	Dictionary []Word
+	// Steps counts the instructions visited (executed or skipped) by the
+	// last evaluation. Since pc only moves forward, Steps <= len(code).
+	Steps    int
+	// NonceChecked is set when an OP_CHECKNONCE of the last evaluation found
+	// the verifier's nonce in the message. A branch that is not taken does
+	// not set it.
+	NonceChecked bool
+	// Objects holds the operands of OP_PUSHLARGE, in order. They live
+	// outside the stack, which is too small for e.g. an SLH-DSA signature,
+	// and are only read by opcodes that take an object index.
+	Objects  [][]byte
+	branches []branch
+	revoked  RevocationSet
+	// digests of the message by hash algorithm, see messageDigest
+	digests   map[byte][]byte
+	prehashed bool
+}
+
+// messageOpcodes need the message itself, so they fail in prehashed mode
+// (see Context.Digests). Every other signature opcode works from a digest;
+// OP_MIXEDMULTISIGVERIFY fails there only if it has an Ed25519 key.
+var messageOpcodes = map[byte]bool{
+	OP_SIGVERIFYED25519: true,
+	OP_SLHDSAVERIFY:     true,
+	OP_ETHVERIFY:        true,
+	OP_MSGFIELD:         true,
+	OP_CHECKNONCE:       true,
 }
 
 func NewEval() *Eval {
@@ -22,6 +74,20 @@ func NewEval() *Eval {
 		{OP_AND, e.and},
 		{OP_OR, e.or},
 		{OP_NOT, e.not},
+		{OP_THRESHOLD, e.threshold},
+		{OP_TOALTSTACK, e.toAltStack},
+		{OP_FROMALTSTACK, e.fromAltStack},
+		{OP_NUMADD, e.numAdd},
+		{OP_NUMSUB, e.numSub},
+		{OP_NUMMUL, e.numMul},
+		{OP_NUMLESSTHAN, e.numLessThan},
+		{OP_NUMEQUAL, e.numEqual},
+		{OP_TONUM, e.toNum},
+		{OP_EQUAL, e.equal},
+		{OP_CAT, e.cat},
+		{OP_SPLIT, e.split},
+		{OP_SIZE, e.size},
+		{OP_CHECKPKHASH, e.checkPKHash},
 	}
 	return e
 }
@@ -31,11 +97,44 @@ func NewEval() *Eval {
 // compact Forth interpreter: https://github.com/skx/foth/blob/master/part1/eval.go
 
 func (e *Eval) EvalWithXmsg(code []byte, xmsg []byte) error {
+	return e.EvalWithContext(code, &Context{Xmsg: xmsg})
+}
+
+func (e *Eval) EvalWithContext(code []byte, ctx *Context) error {
+	xmsg := ctx.Xmsg
 	pc := 0
 	pend := len(code)
+	e.Steps = 0
+	e.NonceChecked = false
+	e.branches = e.branches[:0]
+	e.revoked = ctx.Revoked
+	err := e.setDigests(ctx.Digests)
+	if err != nil {
+		return err
+	}
 
 	for pc < pend {
 		opcode := code[pc]
+		e.Steps++
+
+		if opcode == OP_IF || opcode == OP_ELSE || opcode == OP_ENDIF {
+			err := e.controlFlow(opcode)
+			if err != nil {
+				return err
+			}
+			goto next
+		}
+
+		if !e.executing() {
+			// Inside a branch not taken: step over the instruction
+			// (and its operand) without running it.
+			n, err := instructionLength(code, pc)
+			if err != nil {
+				return err
+			}
+			pc = pc + n
+			goto end
+		}
 
 		for _, word := range e.Dictionary {
 			if opcode == word.Opcode {
@@ -47,6 +146,10 @@ func (e *Eval) EvalWithXmsg(code []byte, xmsg []byte) error {
 			}
 		}
 
+		if e.prehashed && messageOpcodes[opcode] {
+			return errors.Errorf("opcode %v needs the message, which prehashed mode does not have", opcode)
+		}
+
 		switch opcode {
 		case OP_PUSH:
 			if pc+1 >= pend {
@@ -64,14 +167,139 @@ func (e *Eval) EvalWithXmsg(code []byte, xmsg []byte) error {
 			}
 			pc = pc + 2 + howMany
 			goto end
+		case OP_PUSHLARGE:
+			n, err := instructionLength(code, pc)
+			if err != nil {
+				return err
+			}
+			if len(e.Objects) >= MaxObjects {
+				return errors.Errorf("OP_PUSHLARGE: more than %d objects", MaxObjects)
+			}
+			e.Objects = append(e.Objects, code[pc+3:pc+n])
+			pc = pc + n
+			goto end
 		case OP_SIGVERIFY:
-			err := e.sigverify(xmsg)
+			err := e.sigverify(xmsg, false)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SIGVERIFYRAW:
+			err := e.sigverify(xmsg, true)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SIGVERIFYSECP256K1:
+			err := e.sigverifyTyped(xmsg, crypto.KeyTypeSecp256k1)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SIGVERIFYSCHNORR:
+			err := e.sigverifyTyped(xmsg, crypto.KeyTypeSchnorr)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SIGVERIFYED25519:
+			err := e.sigverifyTyped(xmsg, crypto.KeyTypeEd25519)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SLHDSAVERIFY:
+			err := e.slhdsaVerify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_RSAVERIFY:
+			err := e.rsaVerify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_WEBAUTHNVERIFY:
+			err := e.webauthnVerify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SSHSIGVERIFY:
+			err := e.sshsigVerify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_ETHVERIFY:
+			err := e.ethVerify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_SIGVERIFYHASH:
+			err := e.sigverifyHash(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_MIXEDMULTISIGVERIFY:
+			err := e.mixedMultisigVerify(xmsg)
 			if err != nil {
 				return err
 			}
 			goto next
 		case OP_MULTISIGVERIFY:
-			err := e.multisigverify(xmsg)
+			err := e.multisigverify(xmsg, false, false)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_STRICTMULTISIGVERIFY:
+			err := e.multisigverify(xmsg, true, false)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_MULTISIGVERIFYRAW:
+			err := e.multisigverify(xmsg, true, true)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_CHECKSIGFROMSTACK:
+			err := e.checkSigFromStack()
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_WEIGHTEDSIGVERIFY:
+			err := e.weightedsigverify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_MERKLESIGVERIFY:
+			err := e.merklesigverify(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_CHECKSECURITYVERSION:
+			err := e.checkSecurityVersion(ctx.SecurityVersion)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_MSGFIELD:
+			err := e.msgField(xmsg)
+			if err != nil {
+				return err
+			}
+			goto next
+		case OP_CHECKNONCE:
+			err := e.checkNonce(xmsg, ctx.Nonce)
 			if err != nil {
 				return err
 			}
@@ -84,9 +312,128 @@ func (e *Eval) EvalWithXmsg(code []byte, xmsg []byte) error {
 		pc = pc + 1
 	end:
 	}
+	if len(e.branches) != 0 {
+		return errors.Errorf("unterminated OP_IF (%d open)", len(e.branches))
+	}
+	return nil
+}
+
+// setDigests starts an evaluation in prehashed mode if digests is not nil,
+// after checking their sizes. Otherwise digests are computed on demand.
+func (e *Eval) setDigests(digests map[byte][]byte) error {
+	e.digests = nil
+	e.prehashed = digests != nil
+	for alg, digest := range digests {
+		if crypto.HashSize(alg) == 0 {
+			return errors.Errorf("unknown hash algorithm %d", alg)
+		}
+		if len(digest) != crypto.HashSize(alg) {
+			return errors.Errorf("hash algorithm %d: digest of %d bytes, want %d", alg, len(digest), crypto.HashSize(alg))
+		}
+	}
+	if e.prehashed {
+		e.digests = digests
+	}
+	return nil
+}
+
+// messageDigest returns the alg digest of the message: the one supplied in
+// prehashed mode, or else xmsg hashed, once per evaluation.
+func (e *Eval) messageDigest(xmsg []byte, alg byte) ([]byte, error) {
+	if crypto.HashSize(alg) == 0 {
+		return nil, errors.Errorf("unknown hash algorithm %d", alg)
+	}
+	digest, ok := e.digests[alg]
+	if ok {
+		return digest, nil
+	}
+	if e.prehashed {
+		return nil, errors.Errorf("no digest for hash algorithm %d", alg)
+	}
+	if e.digests == nil {
+		e.digests = make(map[byte][]byte)
+	}
+	digest = crypto.Digest(alg, xmsg)
+	e.digests[alg] = digest
+	return digest, nil
+}
+
+// executing reports whether every enclosing OP_IF block is taken.
+func (e *Eval) executing() bool {
+	for _, b := range e.branches {
+		if !b.taken {
+			return false
+		}
+	}
+	return true
+}
+
+// controlFlow implements OP_IF, OP_ELSE and OP_ENDIF. There are no jumps:
+// instructions in a branch not taken are still visited once, just not run.
+// OP_IF pops its condition (0 or 1) only when the enclosing code runs.
+func (e *Eval) controlFlow(opcode byte) error {
+	switch opcode {
+	case OP_IF:
+		if len(e.branches) >= MaxBranchDepth {
+			return errors.Errorf("OP_IF: nesting deeper than %d", MaxBranchDepth)
+		}
+		taken := false
+		if e.executing() {
+			cond, err := e.Stack.Pop()
+			if err != nil {
+				return errors.Wrapf(err, "OP_IF")
+			}
+			if cond > 1 {
+				return errors.Errorf("OP_IF: condition must be 0 or 1, got %d", cond)
+			}
+			taken = cond == 1
+		}
+		e.branches = append(e.branches, branch{taken: taken})
+	case OP_ELSE:
+		if len(e.branches) == 0 {
+			return errors.New("OP_ELSE without OP_IF")
+		}
+		b := &e.branches[len(e.branches)-1]
+		if b.sawElse {
+			return errors.New("OP_ELSE: duplicate OP_ELSE")
+		}
+		b.sawElse = true
+		b.taken = !b.taken
+	case OP_ENDIF:
+		if len(e.branches) == 0 {
+			return errors.New("OP_ENDIF without OP_IF")
+		}
+		e.branches = e.branches[:len(e.branches)-1]
+	}
 	return nil
 }
 
+// instructionLength returns the size in bytes of the instruction at pc,
+// including any operand that follows the opcode.
+func instructionLength(code []byte, pc int) (int, error) {
+	if code[pc] == OP_PUSHLARGE {
+		if pc+2 >= len(code) {
+			return 0, errors.New("OP_PUSHLARGE: missing length operand")
+		}
+		n := 3 + (int(code[pc+1]) | int(code[pc+2])<<8)
+		if pc+n > len(code) {
+			return 0, errors.Errorf("OP_PUSHLARGE: operand extends past end of code (%d bytes needed, %d available)", n-3, len(code)-pc-3)
+		}
+		return n, nil
+	}
+	if code[pc] != OP_PUSH {
+		return 1, nil
+	}
+	if pc+1 >= len(code) {
+		return 0, errors.New("OP_PUSH: missing length operand")
+	}
+	n := 2 + int(code[pc+1])
+	if pc+n > len(code) {
+		return 0, errors.Errorf("OP_PUSH: operand extends past end of code (%d bytes needed, %d available)", n-2, len(code)-pc-2)
+	}
+	return n, nil
+}
+
 func (e *Eval) Eval(code []byte) error {
 	return e.EvalWithXmsg(code, []byte{})
 }
@@ -3,6 +3,8 @@ package lowlevel
 import (
 	"github.com/oreparaz/xsig/internal/crypto"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -323,3 +325,1735 @@ func TestAssembler_PushMaxLength(t *testing.T) {
 	err := a.Append(Push(data))
 	assert.Nil(t, err, "assembler should accept Push with exactly 255 bytes")
 }
+
+func TestEval_IfTaken(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push1(1))
+	a.Append(If())
+	a.Append(Push1(42))
+	a.Append(Else())
+	a.Append(Push1(7))
+	a.Append(EndIf())
+	e := NewEval()
+	err := e.Eval(a.Code)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{42}, e.Stack.S)
+}
+
+func TestEval_IfNotTaken(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push1(0))
+	a.Append(If())
+	a.Append(Push1(42))
+	a.Append(Else())
+	a.Append(Push1(7))
+	a.Append(EndIf())
+	e := NewEval()
+	err := e.Eval(a.Code)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{7}, e.Stack.S)
+}
+
+func TestEval_IfWithoutElse(t *testing.T) {
+	code := []byte{OP_PUSH, 1, 5, OP_PUSH, 1, 0, OP_IF, OP_NOT, OP_ENDIF}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{5}, e.Stack.S)
+}
+
+func TestEval_IfSkippedBranchDoesNotTouchStack(t *testing.T) {
+	// OP_ADD on an empty stack would fail, but it is never run
+	code := []byte{OP_PUSH, 1, 0, OP_IF, OP_ADD, OP_SIGVERIFY, OP_MULTISIGVERIFY, OP_ENDIF}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.Nil(t, err)
+	assert.True(t, e.Stack.IsEmpty())
+}
+
+func TestEval_IfSkippedPushOperandIsData(t *testing.T) {
+	// the skipped OP_PUSH carries OP_ENDIF/OP_ELSE bytes as data; they
+	// must not be interpreted as control flow
+	code := []byte{OP_PUSH, 1, 0, OP_IF, OP_PUSH, 2, OP_ENDIF, OP_ELSE, OP_ENDIF}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.Nil(t, err)
+	assert.True(t, e.Stack.IsEmpty())
+}
+
+func TestEval_IfNested(t *testing.T) {
+	build := func(outer, inner int) []byte {
+		a := Assembler{}
+		a.Append(Push1(inner))
+		a.Append(Push1(outer))
+		a.Append(If())
+		a.Append(If())
+		a.Append(Push1(1))
+		a.Append(Else())
+		a.Append(Push1(2))
+		a.Append(EndIf())
+		a.Append(Else())
+		a.Append(Push1(3))
+		a.Append(EndIf())
+		return a.Code
+	}
+	cases := []struct {
+		outer, inner int
+		expected     []byte
+	}{
+		{1, 1, []byte{1}},
+		{1, 0, []byte{2}},
+		// inner condition is left on the stack: the inner OP_IF is skipped
+		{0, 1, []byte{1, 3}},
+		{0, 0, []byte{0, 3}},
+	}
+	for _, c := range cases {
+		e := NewEval()
+		err := e.Eval(build(c.outer, c.inner))
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, e.Stack.S)
+	}
+}
+
+func TestEval_IfConditionMustBeBoolean(t *testing.T) {
+	code := []byte{OP_PUSH, 1, 2, OP_IF, OP_ENDIF}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "must be 0 or 1")
+}
+
+func TestEval_IfEmptyStack(t *testing.T) {
+	e := NewEval()
+	err := e.Eval([]byte{OP_IF, OP_ENDIF})
+	assert.NotNil(t, err)
+}
+
+func TestEval_IfUnbalanced(t *testing.T) {
+	cases := [][]byte{
+		{OP_PUSH, 1, 1, OP_IF},
+		{OP_PUSH, 1, 0, OP_IF, OP_ELSE},
+		{OP_ELSE},
+		{OP_ENDIF},
+		{OP_PUSH, 1, 1, OP_IF, OP_ENDIF, OP_ENDIF},
+		{OP_PUSH, 1, 1, OP_IF, OP_ELSE, OP_ELSE, OP_ENDIF},
+	}
+	for _, code := range cases {
+		e := NewEval()
+		assert.NotNil(t, e.Eval(code), "code %x should fail", code)
+	}
+}
+
+func TestEval_IfTruncatedPushInSkippedBranch(t *testing.T) {
+	code := []byte{OP_PUSH, 1, 0, OP_IF, OP_PUSH, 5, 1}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.NotNil(t, err)
+}
+
+func TestEval_IfMaxDepth(t *testing.T) {
+	nest := func(depth int) []byte {
+		a := Assembler{}
+		for i := 0; i < depth; i++ {
+			a.Append(Push1(1))
+			a.Append(If())
+		}
+		for i := 0; i < depth; i++ {
+			a.Append(EndIf())
+		}
+		return a.Code
+	}
+	e := NewEval()
+	assert.Nil(t, e.Eval(nest(MaxBranchDepth)))
+	e = NewEval()
+	assert.NotNil(t, e.Eval(nest(MaxBranchDepth+1)))
+}
+
+func TestEval_IfStepsBoundedByCodeLength(t *testing.T) {
+	// Control flow never moves backwards: whatever the branches taken,
+	// every evaluation visits each instruction at most once.
+	rng := rand.New(rand.NewSource(1))
+	ops := []byte{OP_IF, OP_ELSE, OP_ENDIF, OP_NOT, OP_ADD, OP_AND}
+	for i := 0; i < 2000; i++ {
+		a := Assembler{}
+		for j := 0; j < rng.Intn(40); j++ {
+			if rng.Intn(3) == 0 {
+				a.Append(Push1(rng.Intn(2)))
+			} else {
+				a.Append(Instruction{Opcode: ops[rng.Intn(len(ops))]})
+			}
+		}
+		e := NewEval()
+		e.Eval(a.Code)
+		assert.LessOrEqual(t, e.Steps, len(a.Code))
+	}
+}
+
+func TestEval_IfStepsCountSkippedInstructions(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push1(0))
+	a.Append(If())
+	a.Append(Push1(1))
+	a.Append(Push1(2))
+	a.Append(Add())
+	a.Append(EndIf())
+	e := NewEval()
+	err := e.Eval(a.Code)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, e.Steps)
+}
+
+func TestEval_Threshold(t *testing.T) {
+	cases := []struct {
+		conds    []int
+		k        int
+		expected byte
+	}{
+		{[]int{1, 1, 0}, 2, 1},
+		{[]int{1, 0, 0}, 2, 0},
+		{[]int{0, 0, 0}, 1, 0},
+		{[]int{1, 1, 1}, 3, 1},
+		{[]int{0, 1}, 1, 1},
+		{[]int{1}, 1, 1},
+	}
+	for _, c := range cases {
+		a := Assembler{}
+		for _, cond := range c.conds {
+			a.Append(Push1(cond))
+		}
+		a.Append(Push1(c.k))
+		a.Append(Push1(len(c.conds)))
+		a.Append(Threshold())
+		e := NewEval()
+		err := e.Eval(a.Code)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{c.expected}, e.Stack.S, "%d-of-%v", c.k, c.conds)
+	}
+}
+
+func TestEval_ThresholdLeavesRestOfStack(t *testing.T) {
+	code := []byte{OP_PUSH, 1, 0xAA, OP_PUSH, 1, 1, OP_PUSH, 1, 1, OP_PUSH, 1, 1, OP_THRESHOLD}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xAA, 1}, e.Stack.S)
+}
+
+func TestEval_ThresholdBadParameters(t *testing.T) {
+	cases := []struct {
+		code   []byte
+		errMsg string
+	}{
+		{[]byte{OP_PUSH, 1, 1, OP_PUSH, 1, 0, OP_THRESHOLD}, "n must be > 0"},
+		{[]byte{OP_PUSH, 1, 1, OP_PUSH, 1, 0, OP_PUSH, 1, 1, OP_THRESHOLD}, "k must be > 0"},
+		{[]byte{OP_PUSH, 1, 1, OP_PUSH, 1, 2, OP_PUSH, 1, 1, OP_THRESHOLD}, "k (2) > n (1)"},
+		{[]byte{OP_PUSH, 1, 2, OP_PUSH, 1, 1, OP_PUSH, 1, 1, OP_THRESHOLD}, "must be 0 or 1"},
+	}
+	for _, c := range cases {
+		e := NewEval()
+		err := e.Eval(c.code)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), c.errMsg)
+	}
+}
+
+func TestEval_ThresholdUnderflow(t *testing.T) {
+	cases := [][]byte{
+		{OP_THRESHOLD},
+		{OP_PUSH, 1, 1, OP_THRESHOLD},
+		// 1-of-2 with a single condition on the stack
+		{OP_PUSH, 1, 1, OP_PUSH, 1, 1, OP_PUSH, 1, 2, OP_THRESHOLD},
+	}
+	for _, code := range cases {
+		e := NewEval()
+		assert.NotNil(t, e.Eval(code))
+	}
+}
+
+func TestEval_AltStack(t *testing.T) {
+	code := []byte{OP_PUSH, 1, 1, OP_PUSH, 1, 2, OP_TOALTSTACK, OP_PUSH, 1, 3, OP_FROMALTSTACK}
+	e := NewEval()
+	err := e.Eval(code)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 3, 2}, e.Stack.S)
+	assert.True(t, e.AltStack.IsEmpty())
+}
+
+func TestEval_AltStackUnderflow(t *testing.T) {
+	e := NewEval()
+	assert.NotNil(t, e.Eval([]byte{OP_FROMALTSTACK}))
+	e = NewEval()
+	assert.NotNil(t, e.Eval([]byte{OP_TOALTSTACK}))
+}
+
+func TestEval_AltStackOverflow(t *testing.T) {
+	a := Assembler{}
+	for i := 0; i <= MaxAltStackSize; i++ {
+		a.Append(Push1(1))
+		a.Append(ToAltStack())
+	}
+	e := NewEval()
+	err := e.Eval(a.Code)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "alt stack overflow")
+}
+
+func TestEval_NumArithmetic(t *testing.T) {
+	cases := []struct {
+		x, y     int64
+		op       Instruction
+		expected int64
+	}{
+		{1000, 24, NumAdd(), 1024},
+		{-5, 3, NumAdd(), -2},
+		{1000, 24, NumSub(), 976},
+		{24, 1000, NumSub(), -976},
+		{1000, -3, NumMul(), -3000},
+		{1 << 40, 1 << 20, NumMul(), 1 << 60},
+		{0, 77, NumMul(), 0},
+	}
+	for _, c := range cases {
+		a := Assembler{}
+		a.Append(PushNum(c.x))
+		a.Append(PushNum(c.y))
+		a.Append(c.op)
+		e := NewEval()
+		err := e.Eval(a.Code)
+		assert.Nil(t, err)
+		r, err := e.Stack.PopNum()
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, r)
+		assert.True(t, e.Stack.IsEmpty())
+	}
+}
+
+func TestEval_NumOverflow(t *testing.T) {
+	cases := []struct {
+		x, y int64
+		op   Instruction
+	}{
+		{math.MaxInt64, 1, NumAdd()},
+		{math.MinInt64, 1, NumSub()},
+		{1 << 32, 1 << 31, NumMul()},
+		{math.MinInt64, -1, NumMul()},
+	}
+	for _, c := range cases {
+		a := Assembler{}
+		a.Append(PushNum(c.x))
+		a.Append(PushNum(c.y))
+		a.Append(c.op)
+		e := NewEval()
+		err := e.Eval(a.Code)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "overflow")
+	}
+}
+
+func TestEval_NumCompare(t *testing.T) {
+	cases := []struct {
+		x, y     int64
+		op       Instruction
+		expected byte
+	}{
+		{1, 2, NumLessThan(), 1},
+		{2, 1, NumLessThan(), 0},
+		{2, 2, NumLessThan(), 0},
+		{-300, 5, NumLessThan(), 1},
+		{1675209600, 1675209600, NumEqual(), 1},
+		{1675209600, 1675209601, NumEqual(), 0},
+	}
+	for _, c := range cases {
+		a := Assembler{}
+		a.Append(PushNum(c.x))
+		a.Append(PushNum(c.y))
+		a.Append(c.op)
+		e := NewEval()
+		err := e.Eval(a.Code)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{c.expected}, e.Stack.S)
+	}
+}
+
+func TestEval_NumCountBeyond255(t *testing.T) {
+	// summing 256 ones no longer wraps around to 0
+	a := Assembler{}
+	a.Append(PushNum(0))
+	for i := 0; i < 256; i++ {
+		a.Append(Push1(1))
+		a.Append(ToNum())
+		a.Append(NumAdd())
+	}
+	e := NewEval()
+	err := e.Eval(a.Code)
+	assert.Nil(t, err)
+	r, err := e.Stack.PopNum()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(256), r)
+}
+
+func TestEval_NumBadOperands(t *testing.T) {
+	cases := [][]byte{
+		{OP_NUMADD},
+		{OP_PUSH, 1, 0, OP_NUMADD},
+		// non-minimal encoding of 5
+		{OP_PUSH, 3, 0x00, 0x05, 0x02, OP_PUSH, 1, 0, OP_NUMADD},
+		// 9-byte number
+		{OP_PUSH, 10, 1, 1, 1, 1, 1, 1, 1, 1, 1, 9, OP_PUSH, 1, 0, OP_NUMSUB},
+		{OP_TONUM},
+	}
+	for _, code := range cases {
+		e := NewEval()
+		assert.NotNil(t, e.Eval(code), "%x", code)
+	}
+}
+
+func weightedProgram(sigs [][]byte, pks [][]byte, weights []int64, target int64) []byte {
+	a := Assembler{}
+	for _, sig := range sigs {
+		a.Append(Push(sig))
+	}
+	a.Append(Push1(len(sigs)))
+	for i, pk := range pks {
+		a.Append(Push(pk))
+		a.Append(PushNum(weights[i]))
+	}
+	a.Append(PushNum(target))
+	a.Append(Push1(len(pks)))
+	a.Append(WeightedSigVerify())
+	return a.Code
+}
+
+func TestEval_WeightedSigVerify(t *testing.T) {
+	msg := []byte("test")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+	pks := [][]byte{pk1, pk2, pk3}
+	weights := []int64{3, 1, 1}
+
+	tests := []struct {
+		sigs     [][]byte
+		expected byte
+	}{
+		{[][]byte{sig1}, 1},
+		{[][]byte{sig2, sig3}, 0},
+		{[][]byte{sig3, sig1}, 1},
+		{[][]byte{sig2, sig3, sig1}, 1},
+		{[][]byte{}, 0},
+		{[][]byte{sig2, sig2, sig2}, 0},
+	}
+	for i, tt := range tests {
+		e := NewEval()
+		err := e.EvalWithXmsg(weightedProgram(tt.sigs, pks, weights, 3), msg)
+		assert.Nil(t, err, "case %d", i)
+		assert.Equal(t, []byte{tt.expected}, e.Stack.S, "case %d", i)
+	}
+}
+
+func TestEval_WeightedSigVerifyRepeatedKey(t *testing.T) {
+	// a key listed twice is rejected, whatever signatures come with it
+	msg := []byte("test")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, _ := crypto.HelperVerifyData(msg)
+
+	for _, sigs := range [][][]byte{{sig1}, {sig1, sig1}} {
+		e := NewEval()
+		err := e.EvalWithXmsg(weightedProgram(sigs, [][]byte{pk1, pk1}, []int64{2, 2}, 4), msg)
+		assert.NotNil(t, err)
+	}
+
+	e := NewEval()
+	err := e.EvalWithXmsg(weightedProgram([][]byte{sig1}, [][]byte{pk1, pk2, pk1}, []int64{2, 1, 2}, 2), msg)
+	assert.NotNil(t, err)
+}
+
+func TestEval_WeightedSigVerifyBadParameters(t *testing.T) {
+	msg := []byte("test")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+
+	cases := [][]byte{
+		weightedProgram([][]byte{sig1}, [][]byte{}, []int64{}, 1),
+		weightedProgram([][]byte{sig1}, [][]byte{pk1}, []int64{1}, 0),
+		weightedProgram([][]byte{sig1}, [][]byte{pk1}, []int64{1}, -1),
+		weightedProgram([][]byte{sig1}, [][]byte{pk1}, []int64{0}, 1),
+		weightedProgram([][]byte{sig1}, [][]byte{pk1, pk2}, []int64{1, -5}, 1),
+		weightedProgram([][]byte{sig1, sig2}, [][]byte{pk1}, []int64{1}, 1),
+		weightedProgram([][]byte{sig1, sig2}, [][]byte{pk1, pk2}, []int64{math.MaxInt64, 1}, 1),
+		{OP_WEIGHTEDSIGVERIFY},
+		{OP_PUSH, 1, 1, OP_WEIGHTEDSIGVERIFY},
+	}
+	for i, code := range cases {
+		e := NewEval()
+		assert.NotNil(t, e.EvalWithXmsg(code, msg), "case %d", i)
+	}
+}
+
+func merkleProgram(sig, pk []byte, proof []crypto.MerkleStep, root []byte) []byte {
+	a := Assembler{}
+	a.Append(Push(sig))
+	for _, in := range PushMerkleProof(pk, proof) {
+		a.Append(in)
+	}
+	a.Append(Push(root))
+	a.Append(MerkleSigVerify())
+	return a.Code
+}
+
+func TestEval_MerkleSigVerify(t *testing.T) {
+	msg := []byte("test")
+	var pks, sigs [][]byte
+	for i := 0; i < 5; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		pks = append(pks, pk)
+		sigs = append(sigs, sig)
+	}
+	root, err := crypto.MerkleRoot(pks)
+	assert.Nil(t, err)
+
+	for i := range pks {
+		proof, err := crypto.MerkleProof(pks, i)
+		assert.Nil(t, err)
+
+		e := NewEval()
+		assert.Nil(t, e.EvalWithXmsg(merkleProgram(sigs[i], pks[i], proof, root), msg))
+		assert.Equal(t, []byte{1}, e.Stack.S, "leaf %d", i)
+
+		e = NewEval()
+		assert.Nil(t, e.EvalWithXmsg(merkleProgram(sigs[i], pks[i], proof, root), []byte("wrong")))
+		assert.Equal(t, []byte{0}, e.Stack.S, "leaf %d", i)
+	}
+
+	// a valid signature by a key outside the tree
+	_, outsider, outsiderSig := crypto.HelperVerifyData(msg)
+	proof, _ := crypto.MerkleProof(pks, 0)
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(merkleProgram(outsiderSig, outsider, proof, root), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// a tree member's proof used with another member's key
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(merkleProgram(sigs[1], pks[1], proof, root), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// tampered sibling
+	tampered := make([]crypto.MerkleStep, len(proof))
+	copy(tampered, proof)
+	tampered[0].Sibling = make([]byte, 32)
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(merkleProgram(sigs[0], pks[0], tampered, root), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+}
+
+func TestEval_MerkleSigVerifyBadProof(t *testing.T) {
+	msg := []byte("test")
+	_, pk, sig := crypto.HelperVerifyData(msg)
+	root := crypto.MerkleLeafHash(pk)
+	sibling := make([]byte, 32)
+
+	// side byte must be 0 or 1
+	a := Assembler{}
+	a.Append(Push(sig))
+	a.Append(Push(sibling))
+	a.Append(Push1(2))
+	a.Append(Push1(1))
+	a.Append(Push(pk))
+	a.Append(Push(root))
+	a.Append(MerkleSigVerify())
+	assert.NotNil(t, NewEval().EvalWithXmsg(a.Code, msg))
+
+	// proof too deep
+	deep := make([]crypto.MerkleStep, MaxMerkleDepth+1)
+	for i := range deep {
+		deep[i].Sibling = sibling
+	}
+	assert.NotNil(t, NewEval().EvalWithXmsg(merkleProgram(sig, pk, deep, root), msg))
+
+	// missing proof steps, missing signature, empty stack
+	a = Assembler{}
+	a.Append(Push1(1))
+	a.Append(Push(pk))
+	a.Append(Push(root))
+	a.Append(MerkleSigVerify())
+	assert.NotNil(t, NewEval().EvalWithXmsg(a.Code, msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg(merkleProgram(nil, pk, nil, root)[2:], msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg([]byte{OP_MERKLESIGVERIFY}, msg))
+
+	// single-leaf tree: the root is the leaf hash
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(merkleProgram(sig, pk, nil, root), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+}
+
+func TestEval_CheckSecurityVersion(t *testing.T) {
+	tests := []struct {
+		v, device int64
+		expected  byte
+	}{
+		{0, 0, 1},
+		{5, 4, 1},
+		{5, 5, 1},
+		{4, 5, 0},
+		{0, 1, 0},
+		{math.MaxInt64, math.MaxInt64, 1},
+		{1 << 40, 1<<40 + 1, 0},
+	}
+	for _, tt := range tests {
+		a := Assembler{}
+		a.Append(PushNum(tt.v))
+		a.Append(CheckSecurityVersion())
+		e := NewEval()
+		err := e.EvalWithContext(a.Code, &Context{SecurityVersion: tt.device})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{tt.expected}, e.Stack.S, "v=%d device=%d", tt.v, tt.device)
+	}
+}
+
+func TestEval_CheckSecurityVersionDefaultContext(t *testing.T) {
+	// Eval and EvalWithXmsg run with security version 0
+	a := Assembler{}
+	a.Append(PushNum(0))
+	a.Append(CheckSecurityVersion())
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+}
+
+func TestEval_CheckSecurityVersionErrors(t *testing.T) {
+	a := Assembler{}
+	a.Append(PushNum(-1))
+	a.Append(CheckSecurityVersion())
+	assert.NotNil(t, NewEval().EvalWithContext(a.Code, &Context{}))
+
+	a = Assembler{}
+	a.Append(PushNum(1))
+	a.Append(CheckSecurityVersion())
+	assert.NotNil(t, NewEval().EvalWithContext(a.Code, &Context{SecurityVersion: -1}))
+
+	assert.NotNil(t, NewEval().EvalWithContext([]byte{OP_CHECKSECURITYVERSION}, &Context{}))
+	assert.NotNil(t, NewEval().EvalWithContext([]byte{OP_PUSH, 1, 9, OP_CHECKSECURITYVERSION}, &Context{}))
+}
+
+func TestEval_Equal(t *testing.T) {
+	tests := []struct {
+		a, b     []byte
+		expected byte
+	}{
+		{[]byte{}, []byte{}, 1},
+		{[]byte{1, 2, 3}, []byte{1, 2, 3}, 1},
+		{[]byte{1, 2, 3}, []byte{1, 2}, 0},
+		{[]byte{1, 2, 3}, []byte{1, 2, 4}, 0},
+		{[]byte{}, []byte{0}, 0},
+	}
+	for _, tt := range tests {
+		a := Assembler{}
+		a.Append(PushBlob(tt.a))
+		a.Append(PushBlob(tt.b))
+		a.Append(Equal())
+		e := NewEval()
+		assert.Nil(t, e.Eval(a.Code))
+		assert.Equal(t, []byte{tt.expected}, e.Stack.S, "%x %x", tt.a, tt.b)
+	}
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSH, 1, 0, OP_EQUAL}))
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSH, 2, 0, 5, OP_EQUAL}))
+}
+
+func TestEval_MsgField(t *testing.T) {
+	msg, err := EncodeMessage([]MessageField{
+		{Tag: 1, Value: []byte{0x42}},
+		{Tag: 2, Value: []byte("debug-unlock")},
+		{Tag: 3, Value: make([]byte, 256)},
+	})
+	assert.Nil(t, err)
+
+	// product ID 0x42
+	a := Assembler{}
+	a.Append(Push1(1))
+	a.Append(MsgField())
+	a.Append(PushBlob([]byte{0x42}))
+	a.Append(Equal())
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(a.Code, msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	a = Assembler{}
+	a.Append(Push1(2))
+	a.Append(MsgField())
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(a.Code, msg))
+	value, err := e.Stack.PopBlob()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("debug-unlock"), value)
+
+	// missing tag, value too large for a blob, malformed message
+	assert.NotNil(t, NewEval().EvalWithXmsg([]byte{OP_PUSH, 1, 9, OP_MSGFIELD}, msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg([]byte{OP_PUSH, 1, 3, OP_MSGFIELD}, msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg([]byte{OP_PUSH, 1, 1, OP_MSGFIELD}, append(msg, 0)))
+	assert.NotNil(t, NewEval().EvalWithXmsg([]byte{OP_MSGFIELD}, msg))
+}
+
+func TestEval_MsgFieldSecurityVersion(t *testing.T) {
+	// the version the signers committed to is a field of the signed message
+	msg, _ := EncodeMessage([]MessageField{{Tag: 4, Value: EncodeNum(12)}})
+	a := Assembler{}
+	a.Append(Push1(4))
+	a.Append(MsgField())
+	a.Append(CheckSecurityVersion())
+	for _, tt := range []struct {
+		device   int64
+		expected byte
+	}{{11, 1}, {12, 1}, {13, 0}} {
+		e := NewEval()
+		assert.Nil(t, e.EvalWithContext(a.Code, &Context{Xmsg: msg, SecurityVersion: tt.device}))
+		assert.Equal(t, []byte{tt.expected}, e.Stack.S)
+	}
+}
+
+func TestEval_CheckSigFromStack(t *testing.T) {
+	attestation := []byte("build 1234 passed tests")
+	_, pk, sig := crypto.HelperVerifyData(attestation)
+
+	program := func(sig, msg, pk []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(PushBlob(msg))
+		a.Append(Push(pk))
+		a.Append(CheckSigFromStack())
+		return a.Code
+	}
+
+	// xmsg plays no part
+	for _, xmsg := range [][]byte{nil, []byte("anything")} {
+		e := NewEval()
+		assert.Nil(t, e.EvalWithXmsg(program(sig, attestation, pk), xmsg))
+		assert.Equal(t, []byte{1}, e.Stack.S)
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.Eval(program(sig, []byte("build 1235 passed tests"), pk)))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	_, otherPK, _ := crypto.HelperVerifyData(attestation)
+	e = NewEval()
+	assert.Nil(t, e.Eval(program(sig, attestation, otherPK)))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.Eval(program([]byte{0x30, 0x00}, attestation, pk)))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// empty message
+	_, pk2, sig2 := crypto.HelperVerifyData([]byte{})
+	e = NewEval()
+	assert.Nil(t, e.Eval(program(sig2, nil, pk2)))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+}
+
+func TestEval_CheckSigFromStackErrors(t *testing.T) {
+	msg := []byte("m")
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	assert.NotNil(t, NewEval().Eval([]byte{OP_CHECKSIGFROMSTACK}))
+
+	// missing signature
+	a := Assembler{}
+	a.Append(PushBlob(msg))
+	a.Append(Push(pk))
+	a.Append(CheckSigFromStack())
+	assert.NotNil(t, NewEval().Eval(a.Code))
+
+	// message blob longer than what is on the stack
+	a = Assembler{}
+	a.Append(Push(sig))
+	a.Append(Push1(200))
+	a.Append(Push(pk))
+	a.Append(CheckSigFromStack())
+	assert.NotNil(t, NewEval().Eval(a.Code))
+}
+
+func TestEval_Cat(t *testing.T) {
+	a := Assembler{}
+	a.Append(PushBlob([]byte("domain:")))
+	a.Append(PushBlob([]byte("build-1")))
+	a.Append(Cat())
+	a.Append(PushBlob(nil))
+	a.Append(Cat())
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	r, err := e.Stack.PopBlob()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("domain:build-1"), r)
+	assert.True(t, e.Stack.IsEmpty())
+
+	// up to MaxBlobSize and not beyond
+	a = Assembler{}
+	a.Append(PushBlob(make([]byte, 200)))
+	a.Append(PushBlob(make([]byte, 55)))
+	a.Append(Cat())
+	assert.Nil(t, NewEval().Eval(a.Code))
+	a.Append(PushBlob([]byte{1}))
+	a.Append(Cat())
+	assert.NotNil(t, NewEval().Eval(a.Code))
+
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSH, 1, 0, OP_CAT}))
+}
+
+func TestEval_Split(t *testing.T) {
+	for n := 0; n <= 5; n++ {
+		a := Assembler{}
+		a.Append(PushBlob([]byte("abcde")))
+		a.Append(PushNum(int64(n)))
+		a.Append(Split())
+		e := NewEval()
+		assert.Nil(t, e.Eval(a.Code))
+		right, err := e.Stack.PopBlob()
+		assert.Nil(t, err)
+		left, err := e.Stack.PopBlob()
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("abcde")[:n], left)
+		assert.Equal(t, []byte("abcde")[n:], right)
+		assert.True(t, e.Stack.IsEmpty())
+	}
+
+	for _, n := range []int64{-1, 6, math.MaxInt64} {
+		a := Assembler{}
+		a.Append(PushBlob([]byte("abcde")))
+		a.Append(PushNum(n))
+		a.Append(Split())
+		assert.NotNil(t, NewEval().Eval(a.Code), "n=%d", n)
+	}
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSH, 1, 0, OP_SPLIT}))
+}
+
+func TestEval_SplitCatRoundTrip(t *testing.T) {
+	a := Assembler{}
+	a.Append(PushBlob([]byte("hello world")))
+	a.Append(PushNum(5))
+	a.Append(Split())
+	a.Append(Cat())
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	r, _ := e.Stack.PopBlob()
+	assert.Equal(t, []byte("hello world"), r)
+}
+
+func TestEval_Size(t *testing.T) {
+	a := Assembler{}
+	a.Append(PushBlob([]byte("abc")))
+	a.Append(Size())
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	n, err := e.Stack.PopNum()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	r, err := e.Stack.PopBlob()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("abc"), r)
+
+	a = Assembler{}
+	a.Append(PushBlob(nil))
+	a.Append(Size())
+	e = NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	n, _ = e.Stack.PopNum()
+	assert.Equal(t, int64(0), n)
+
+	assert.NotNil(t, NewEval().Eval([]byte{OP_SIZE}))
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSH, 1, 4, OP_SIZE}))
+}
+
+func TestEval_RevokedKeys(t *testing.T) {
+	msg := []byte("revocation")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	revoked := RevocationSet{crypto.KeyFingerprint(pk1): true}
+
+	run := func(code []byte, revoked RevocationSet) []byte {
+		e := NewEval()
+		assert.Nil(t, e.EvalWithContext(code, &Context{Xmsg: msg, Revoked: revoked}))
+		return e.Stack.S
+	}
+
+	a := Assembler{}
+	a.Append(Push(sig1))
+	a.Append(Push(pk1))
+	a.Append(SignatureVerify())
+	assert.Equal(t, []byte{1}, run(a.Code, nil))
+	assert.Equal(t, []byte{0}, run(a.Code, revoked))
+
+	// 1-of-2: the revoked key no longer counts, the other one still does
+	multisig := func(sig []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(Push(pk2))
+		a.Append(Push(pk1))
+		a.Append(Push1(1))
+		a.Append(Push1(2))
+		a.Append(MultisigVerify())
+		return a.Code
+	}
+	assert.Equal(t, []byte{0}, run(multisig(sig1), revoked))
+	assert.Equal(t, []byte{1}, run(multisig(sig2), revoked))
+
+	a = Assembler{}
+	a.Append(Push(sig1))
+	a.Append(PushBlob(msg))
+	a.Append(Push(pk1))
+	a.Append(CheckSigFromStack())
+	assert.Equal(t, []byte{1}, run(a.Code, nil))
+	assert.Equal(t, []byte{0}, run(a.Code, revoked))
+
+	// revoking a key does not leak into the next evaluation
+	e := NewEval()
+	assert.Nil(t, e.EvalWithContext(multisig(sig1), &Context{Xmsg: msg, Revoked: revoked}))
+	assert.Nil(t, e.EvalWithXmsg(multisig(sig1), msg))
+	assert.Equal(t, []byte{0, 1}, e.Stack.S)
+}
+
+func TestEval_CheckNonce(t *testing.T) {
+	nonce := []byte{0xde, 0xad, 0xbe, 0xef}
+	msg, _ := EncodeMessage([]MessageField{{Tag: 1, Value: []byte("unlock debug port")}, {Tag: 2, Value: nonce}})
+
+	check := func(tag int) []byte {
+		a := Assembler{}
+		a.Append(Push1(tag))
+		a.Append(CheckNonce())
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithContext(check(2), &Context{Xmsg: msg, Nonce: nonce}))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	assert.True(t, e.NonceChecked)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(check(2), &Context{Xmsg: msg, Nonce: []byte{0xde, 0xad, 0xbe}}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+	assert.False(t, e.NonceChecked)
+
+	// a check in a branch that is not taken does not count
+	skipped := append([]byte{OP_PUSH, 1, 0, OP_IF}, check(2)...)
+	skipped = append(skipped, OP_ELSE, OP_PUSH, 1, 1, OP_ENDIF)
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(skipped, &Context{Xmsg: msg, Nonce: nonce}))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	assert.False(t, e.NonceChecked)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(check(1), &Context{Xmsg: msg, Nonce: nonce}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// no nonce from the verifier, missing field, malformed message
+	assert.NotNil(t, NewEval().EvalWithContext(check(2), &Context{Xmsg: msg}))
+	assert.NotNil(t, NewEval().EvalWithContext(check(3), &Context{Xmsg: msg, Nonce: nonce}))
+	assert.NotNil(t, NewEval().EvalWithContext(check(2), &Context{Xmsg: append(msg, 0), Nonce: nonce}))
+	assert.NotNil(t, NewEval().EvalWithContext([]byte{OP_CHECKNONCE}, &Context{Xmsg: msg, Nonce: nonce}))
+
+	// an empty field never matches, even if empty nonces were allowed
+	empty, _ := EncodeMessage([]MessageField{{Tag: 2}})
+	assert.NotNil(t, NewEval().EvalWithContext(check(2), &Context{Xmsg: empty}))
+}
+
+func TestEval_CheckPKHash(t *testing.T) {
+	msg := []byte("pkhash")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, _ := crypto.HelperVerifyData(msg)
+	h1, _ := crypto.PublicKeyHash(pk1, 20)
+	h2, _ := crypto.PublicKeyHash(pk2, 32)
+
+	// keys are left in place, ready for the signature opcode
+	a := Assembler{}
+	a.Append(Push(pk2))
+	a.Append(Push(pk1))
+	for _, in := range CheckPKHashes([][]byte{h1, h2}) {
+		a.Append(in)
+	}
+	keys := Assembler{}
+	keys.Append(Push(pk2))
+	keys.Append(Push(pk1))
+	expected := NewEval()
+	assert.Nil(t, expected.Eval(keys.Code))
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	assert.Equal(t, expected.Stack.S, e.Stack.S)
+
+	a = Assembler{}
+	a.Append(Push(sig1))
+	a.Append(Push(pk1))
+	for _, in := range CheckPKHashes([][]byte{h1}) {
+		a.Append(in)
+	}
+	a.Append(SignatureVerify())
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(a.Code, msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	fails := func(build func(a *Assembler)) {
+		a := Assembler{}
+		build(&a)
+		assert.NotNil(t, NewEval().Eval(a.Code))
+	}
+	// wrong key, swapped keys, bad commitment sizes, N = 0, missing key
+	fails(func(a *Assembler) {
+		a.Append(Push(pk2))
+		for _, in := range CheckPKHashes([][]byte{h1}) {
+			a.Append(in)
+		}
+	})
+	fails(func(a *Assembler) {
+		a.Append(Push(pk1))
+		a.Append(Push(pk2))
+		for _, in := range CheckPKHashes([][]byte{h1, h2}) {
+			a.Append(in)
+		}
+	})
+	fails(func(a *Assembler) {
+		a.Append(Push(pk1))
+		for _, in := range CheckPKHashes([][]byte{h1[:19]}) {
+			a.Append(in)
+		}
+	})
+	fails(func(a *Assembler) {
+		a.Append(Push(pk1))
+		a.Append(Push1(0))
+		a.Append(CheckPKHash())
+	})
+	fails(func(a *Assembler) {
+		for _, in := range CheckPKHashes([][]byte{h1}) {
+			a.Append(in)
+		}
+	})
+}
+
+func TestEval_StrictMultisigVerify(t *testing.T) {
+	msg := []byte("strict")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, _ := crypto.HelperVerifyData(msg)
+
+	// 2-of-3, pushed so that pks[0] is popped first
+	multisig := func(op Instruction, pks [][]byte, sigs ...[]byte) []byte {
+		a := Assembler{}
+		for _, sig := range sigs {
+			a.Append(Push(sig))
+		}
+		for i := len(pks) - 1; i >= 0; i-- {
+			a.Append(Push(pks[i]))
+		}
+		a.Append(Push1(2))
+		a.Append(Push1(len(pks)))
+		a.Append(op)
+		return a.Code
+	}
+	run := func(code []byte) ([]byte, error) {
+		e := NewEval()
+		err := e.EvalWithXmsg(code, msg)
+		return e.Stack.S, err
+	}
+
+	// the mistake: key 1 listed twice lets signer 1 alone satisfy 2-of-3
+	dup := [][]byte{pk1, pk2, pk1}
+	stack, err := run(multisig(MultisigVerify(), dup, sig1, sig1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+	_, err = run(multisig(StrictMultisigVerify(), dup, sig1, sig1))
+	assert.NotNil(t, err)
+	_, err = run(multisig(StrictMultisigVerify(), dup, sig1, sig2))
+	assert.NotNil(t, err)
+
+	// duplicate signatures under distinct keys
+	pks := [][]byte{pk1, pk2, pk3}
+	_, err = run(multisig(StrictMultisigVerify(), pks, sig1, sig1))
+	assert.NotNil(t, err)
+
+	stack, err = run(multisig(StrictMultisigVerify(), pks, sig1, sig2))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+	stack, err = run(multisig(StrictMultisigVerify(), pks, sig1, []byte{0x30, 0x00}))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+}
+
+func TestEval_SigVerifyRaw(t *testing.T) {
+	msg := []byte("raw signatures")
+	_, pk, der := crypto.HelperVerifyData(msg)
+	raw, err := crypto.RawSignature(der)
+	assert.Nil(t, err)
+
+	program := func(sig []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(Push(pk))
+		a.Append(SignatureVerifyRaw())
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(raw), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(raw), []byte("other")))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// always pops exactly 64 bytes, whatever they look like
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(append([]byte{OP_PUSH, 1, 7}, program(make([]byte, 64))...), msg))
+	assert.Equal(t, []byte{7, 0}, e.Stack.S)
+
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(raw[:63]), msg))
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(program(raw), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+}
+
+func TestEval_MultisigVerifyRaw(t *testing.T) {
+	msg := []byte("raw multisig")
+	var pks, sigs [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, der := crypto.HelperVerifyData(msg)
+		raw, _ := crypto.RawSignature(der)
+		pks, sigs = append(pks, pk), append(sigs, raw)
+	}
+
+	multisig := func(pks [][]byte, sigs ...[]byte) []byte {
+		a := Assembler{}
+		for _, sig := range sigs {
+			a.Append(Push(sig))
+		}
+		for i := len(pks) - 1; i >= 0; i-- {
+			a.Append(Push(pks[i]))
+		}
+		a.Append(Push1(len(sigs)))
+		a.Append(Push1(len(pks)))
+		a.Append(MultisigVerifyRaw())
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(multisig(pks, sigs[2], sigs[0]), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(multisig(pks, sigs[2], make([]byte, 64)), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// always strict: repeated keys or signatures fail
+	assert.NotNil(t, NewEval().EvalWithXmsg(multisig([][]byte{pks[0], pks[1], pks[0]}, sigs[0], sigs[1]), msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg(multisig(pks, sigs[0], sigs[0]), msg))
+
+	// one signer listed twice satisfies a non-strict OP_MULTISIGVERIFY 2-of-3,
+	// but not OP_MULTISIGVERIFYRAW
+	_, pk, der := crypto.HelperVerifyData(msg)
+	raw, _ := crypto.RawSignature(der)
+	dupKeys := [][]byte{pk, pks[1], pk}
+	nonStrict := multisig(dupKeys, der, der)
+	nonStrict[len(nonStrict)-1] = OP_MULTISIGVERIFY
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(nonStrict, msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	assert.NotNil(t, NewEval().EvalWithXmsg(multisig(dupKeys, raw, raw), msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg(multisig(dupKeys, raw), msg))
+}
+
+func TestEval_SigVerifySecp256k1(t *testing.T) {
+	msg := []byte("hardware wallet")
+	_, pk, sig := crypto.HelperVerifyDataSecp256k1(msg)
+
+	program := func(sig []byte, pk []byte, op Instruction) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(Push(pk))
+		a.Append(op)
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig, pk, SignatureVerifySecp256k1()), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig, pk, SignatureVerifySecp256k1()), []byte("other")))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// the same key and signature do not verify as P-256
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig, pk, SignatureVerify()), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(program(sig, pk, SignatureVerifySecp256k1()), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+}
+
+func TestEval_SigVerifySchnorr(t *testing.T) {
+	msg := []byte("hardware wallet")
+	_, pk, sig := crypto.HelperVerifyDataSchnorr(msg)
+
+	program := func(sig []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(Push(pk))
+		a.Append(SignatureVerifySchnorr())
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig), []byte("other")))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// always pops exactly 64 bytes
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(append([]byte{OP_PUSH, 1, 7}, program(make([]byte, 64))...), msg))
+	assert.Equal(t, []byte{7, 0}, e.Stack.S)
+
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(sig[:63]), msg))
+}
+
+func TestEval_SigVerifyEd25519(t *testing.T) {
+	msg := []byte("release 1.2.0")
+	// a 2-of-3 FROST group signs as a single Ed25519 key
+	keys, pk, sig := crypto.HelperVerifyDataFROST(2, 3, msg)
+
+	program := func(sig []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(Push(pk))
+		a.Append(SignatureVerifyEd25519())
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(crypto.SignFROST(keys[1:], msg)), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig), []byte("other")))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// always pops exactly 64 bytes
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(append([]byte{OP_PUSH, 1, 7}, program(make([]byte, 64))...), msg))
+	assert.Equal(t, []byte{7, 0}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(program(sig), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(sig[:63]), msg))
+}
+
+func TestEval_SigVerifyHash(t *testing.T) {
+	msg := []byte("artifact sha384:0f3a")
+	program := func(keyType byte, alg byte, pk []byte, sig []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		for _, in := range SigVerifyHash(keyType, alg, pk) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+
+	for _, keyType := range []byte{crypto.KeyTypeP256, crypto.KeyTypeSecp256k1, crypto.KeyTypeP384} {
+		for alg := crypto.HashSHA256; alg <= crypto.HashSHA3_256; alg++ {
+			pk, sig := crypto.HelperVerifyDataDigest(keyType, alg, msg)
+			e := NewEval()
+			assert.Nil(t, e.EvalWithXmsg(program(keyType, alg, pk, sig), msg))
+			assert.Equal(t, []byte{1}, e.Stack.S, "type %d alg %d", keyType, alg)
+
+			e = NewEval()
+			assert.Nil(t, e.EvalWithXmsg(program(keyType, alg, pk, sig), []byte("other")))
+			assert.Equal(t, []byte{0}, e.Stack.S)
+
+			// the same signature under another digest
+			other := alg%4 + 1
+			e = NewEval()
+			assert.Nil(t, e.EvalWithXmsg(program(keyType, other, pk, sig), msg))
+			assert.Equal(t, []byte{0}, e.Stack.S)
+
+			// prehashed: only the digest is available
+			e = NewEval()
+			assert.Nil(t, e.EvalWithContext(program(keyType, alg, pk, sig), &Context{Digests: map[byte][]byte{alg: crypto.Digest(alg, msg)}}))
+			assert.Equal(t, []byte{1}, e.Stack.S)
+		}
+	}
+
+	pk, sig := crypto.HelperVerifyDataDigest(crypto.KeyTypeP384, crypto.HashSHA384, msg)
+	code := program(crypto.KeyTypeP384, crypto.HashSHA384, pk, sig)
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithContext(code, &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// prehashed mode ignores Xmsg, and needs a digest of the right algorithm
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(code, &Context{Xmsg: []byte("other"), Digests: map[byte][]byte{crypto.HashSHA384: crypto.Digest(crypto.HashSHA384, msg)}}))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	assert.NotNil(t, NewEval().EvalWithContext(code, &Context{Xmsg: msg, Digests: map[byte][]byte{}}))
+	assert.NotNil(t, NewEval().EvalWithContext(code, &Context{Digests: map[byte][]byte{crypto.HashSHA384: make([]byte, 32)}}))
+	assert.NotNil(t, NewEval().EvalWithContext(code, &Context{Digests: map[byte][]byte{9: make([]byte, 32)}}))
+
+	// a key of the wrong size for its type
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(crypto.KeyTypeP256, crypto.HashSHA384, pk, sig), msg))
+	bad := append([]byte{0x04}, pk[1:]...)
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(crypto.KeyTypeP384, crypto.HashSHA384, bad, sig), msg))
+	// unsupported key types and algorithms
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(crypto.KeyTypeSchnorr, crypto.HashSHA384, pk, sig), msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(crypto.KeyTypeP384, 0, pk, sig), msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(crypto.KeyTypeP384, 5, pk, sig), msg))
+	// a signature longer than any P-384 one
+	long := append([]byte{0x30, 103}, make([]byte, 103)...)
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(crypto.KeyTypeP384, crypto.HashSHA384, pk, long), msg))
+	// malformed DER does not validate
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(crypto.KeyTypeP384, crypto.HashSHA384, pk, []byte{0x30, 0}), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+}
+
+func TestEval_Prehashed(t *testing.T) {
+	msg := []byte("hello")
+	_, pk, sig := crypto.HelperVerifyData(msg)
+	a := Assembler{}
+	a.Append(Push(sig))
+	a.Append(Push(pk))
+	a.Append(SignatureVerify())
+	// signature opcodes over SHA-256 of the message take the digest, and
+	// Xmsg is ignored
+	ctx := &Context{Xmsg: []byte("ignored"), Digests: map[byte][]byte{crypto.HashSHA256: crypto.Digest(crypto.HashSHA256, msg)}}
+	e := NewEval()
+	assert.Nil(t, e.EvalWithContext(a.Code, ctx))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(a.Code, &Context{Xmsg: msg, Digests: map[byte][]byte{crypto.HashSHA256: crypto.Digest(crypto.HashSHA256, []byte("other"))}}))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+	assert.NotNil(t, NewEval().EvalWithContext(a.Code, &Context{Digests: map[byte][]byte{crypto.HashSHA512: crypto.Digest(crypto.HashSHA512, msg)}}))
+
+	// a multisig needs nothing else
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	a = Assembler{}
+	a.Append(Push(sig)); a.Append(Push(sig2))
+	a.Append(Push(pk)); a.Append(Push(pk2))
+	a.Append(Push1(2)); a.Append(Push1(2)); a.Append(MultisigVerify())
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(a.Code, ctx))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	// sshsig takes the digest its signature names, here SHA-512
+	_, sshPK, sshSig := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeEd25519, []byte("xsig"), msg)
+	a = Assembler{}
+	a.Append(PushLarge(sshSig))
+	for _, in := range SSHSigVerify(0, sshPK, "xsig") {
+		a.Append(in)
+	}
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(a.Code, &Context{Digests: map[byte][]byte{crypto.HashSHA512: crypto.Digest(crypto.HashSHA512, msg)}}))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	assert.NotNil(t, NewEval().EvalWithContext(a.Code, ctx))
+
+	// Ed25519 signs the message itself
+	_, edPK, edSig := crypto.HelperVerifyDataFROST(2, 2, msg)
+	a = Assembler{}
+	a.Append(Push(edSig)); a.Append(Push(edPK)); a.Append(SignatureVerifyEd25519())
+	assert.NotNil(t, NewEval().EvalWithContext(a.Code, ctx))
+	a = Assembler{}
+	for _, in := range PushSignatureSlots([][]byte{sig, nil}) {
+		a.Append(in)
+	}
+	for _, in := range MixedMultisig(1, []TypedPublicKey{{crypto.KeyTypeP256, pk}, {crypto.KeyTypeEd25519, edPK}}) {
+		a.Append(in)
+	}
+	assert.NotNil(t, NewEval().EvalWithContext(a.Code, ctx))
+	e = NewEval()
+	assert.Nil(t, e.EvalWithContext(a.Code, &Context{Xmsg: msg}))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+	assert.NotNil(t, NewEval().EvalWithContext([]byte{OP_PUSH, 1, 0, OP_PUSH, 1, 1, OP_MSGFIELD}, ctx))
+
+	// opcodes that do not look at the message still work, also in a branch
+	// that is not taken
+	e = NewEval()
+	code := []byte{OP_PUSH, 1, 0, OP_IF, OP_MSGFIELD, OP_ENDIF, OP_PUSH, 1, 2, OP_PUSH, 1, 3, OP_ADD}
+	assert.Nil(t, e.EvalWithContext(code, ctx))
+	assert.Equal(t, []byte{5}, e.Stack.S)
+}
+
+func TestEval_MixedMultisigVerify(t *testing.T) {
+	msg := []byte("treasury transfer")
+	_, p256PK, p256Sig := crypto.HelperVerifyData(msg)
+	_, k1PK, k1Sig := crypto.HelperVerifyDataSecp256k1(msg)
+	_, schnorrPK, schnorrSig := crypto.HelperVerifyDataSchnorr(msg)
+	_, ed25519PK, ed25519Sig := crypto.HelperVerifyDataFROST(2, 2, msg)
+	keys := []TypedPublicKey{
+		{Type: crypto.KeyTypeP256, PublicKey: p256PK},
+		{Type: crypto.KeyTypeSecp256k1, PublicKey: k1PK},
+		{Type: crypto.KeyTypeSchnorr, PublicKey: schnorrPK},
+	}
+
+	run := func(nMin int, keys []TypedPublicKey, sigs ...[]byte) ([]byte, error) {
+		a := Assembler{}
+		for _, in := range PushSignatureSlots(sigs) {
+			a.Append(in)
+		}
+		for _, in := range MixedMultisig(nMin, keys) {
+			a.Append(in)
+		}
+		e := NewEval()
+		err := e.EvalWithXmsg(a.Code, msg)
+		return e.Stack.S, err
+	}
+
+	stack, err := run(2, keys, p256Sig, nil, schnorrSig)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+
+	stack, err = run(2, keys, nil, k1Sig, schnorrSig)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+
+	stack, err = run(3, keys, p256Sig, k1Sig, schnorrSig)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+
+	stack, err = run(2, keys, p256Sig, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+
+	withEd25519 := append(keys, TypedPublicKey{Type: crypto.KeyTypeEd25519, PublicKey: ed25519PK})
+	stack, err = run(2, withEd25519, nil, k1Sig, nil, ed25519Sig)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+	stack, err = run(2, withEd25519, nil, k1Sig, nil, schnorrSig)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+
+	// signatures are positional: a valid one in the wrong slot does not count
+	stack, err = run(2, keys, k1Sig, p256Sig, schnorrSig)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+
+	_, err = run(2, []TypedPublicKey{keys[0], keys[1], keys[0]}, p256Sig, k1Sig, p256Sig)
+	assert.NotNil(t, err)
+	_, err = run(1, []TypedPublicKey{{Type: 9, PublicKey: p256PK}}, p256Sig)
+	assert.NotNil(t, err)
+	_, err = run(1, keys, p256Sig)
+	assert.NotNil(t, err)
+}
+
+func TestEval_PushLarge(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push1(7))
+	a.Append(PushLarge(make([]byte, 1000)))
+	a.Append(PushLarge(nil))
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	assert.Equal(t, []byte{7}, e.Stack.S)
+	assert.Equal(t, [][]byte{make([]byte, 1000), {}}, e.Objects)
+
+	// skipped in a branch not taken
+	a = Assembler{}
+	a.Append(Push1(0))
+	a.Append(If())
+	a.Append(PushLarge([]byte{OP_ADD, OP_ADD}))
+	a.Append(EndIf())
+	e = NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	assert.Equal(t, 0, len(e.Objects))
+
+	a = Assembler{}
+	for i := 0; i <= MaxObjects; i++ {
+		a.Append(PushLarge([]byte{1}))
+	}
+	assert.NotNil(t, NewEval().Eval(a.Code))
+
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSHLARGE, 3, 0, 1, 2}))
+	assert.NotNil(t, NewEval().Eval([]byte{OP_PUSHLARGE, 0}))
+}
+
+func TestEval_SLHDSAVerify(t *testing.T) {
+	msg := []byte("long-lived root key")
+	_, pk, sig := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128f, msg)
+
+	program := func(sig []byte, object int, paramSet byte) []byte {
+		a := Assembler{}
+		a.Append(PushLarge(nil))
+		a.Append(PushLarge(sig))
+		for _, in := range SLHDSASigVerify(object, paramSet, pk) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+
+	e := NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig, 1, crypto.SLHDSASHA2128f), msg))
+	assert.Equal(t, []byte{1}, e.Stack.S)
+
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig, 1, crypto.SLHDSASHA2128f), []byte("other")))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// the empty object: no signature
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig, 0, crypto.SLHDSASHA2128f), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	// a signature of the wrong size, or for the other parameter set
+	e = NewEval()
+	assert.Nil(t, e.EvalWithXmsg(program(sig[:7856], 1, crypto.SLHDSASHA2128s), msg))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	e = NewEval()
+	ctx := &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}}
+	assert.Nil(t, e.EvalWithContext(program(sig, 1, crypto.SLHDSASHA2128f), ctx))
+	assert.Equal(t, []byte{0}, e.Stack.S)
+
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(sig, 2, crypto.SLHDSASHA2128f), msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg(program(sig, 1, 3), msg))
+	assert.NotNil(t, NewEval().EvalWithXmsg([]byte{OP_PUSH, 1, 0, OP_PUSH, 1, 2, OP_SLHDSAVERIFY}, msg))
+}
+
+func TestEval_RSAVerify(t *testing.T) {
+	msg := []byte("code signing certificate")
+	privateKey, pk, sig := crypto.HelperVerifyDataRSA(2048, crypto.RSAPKCS1v15, msg)
+	pssSig := crypto.SignRSA(crypto.RSAPSS, privateKey, msg)
+	_, otherPK, _ := crypto.HelperVerifyDataRSA(2048, crypto.RSAPKCS1v15, nil)
+
+	// objects, then OP_RSAVERIFY on object 0 (signature) and 1 (key)
+	program := func(scheme byte, sig []byte, key []byte, committed []byte) []byte {
+		a := Assembler{}
+		a.Append(PushLarge(sig))
+		a.Append(PushLarge(key))
+		for _, in := range RSASigVerify(0, 1, scheme, committed) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	run := func(code []byte, ctx *Context) ([]byte, error) {
+		e := NewEval()
+		err := e.EvalWithContext(code, ctx)
+		return e.Stack.S, err
+	}
+	ctx := &Context{Xmsg: msg}
+
+	stack, err := run(program(crypto.RSAPKCS1v15, sig, pk, pk), ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+	stack, err = run(program(crypto.RSAPSS, pssSig, pk, pk), ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+
+	for _, code := range [][]byte{
+		program(crypto.RSAPSS, sig, pk, pk),
+		program(crypto.RSAPKCS1v15, pssSig, pk, pk),
+		program(crypto.RSAPKCS1v15, nil, pk, pk),
+		program(crypto.RSAPKCS1v15, sig[1:], pk, pk),
+	} {
+		stack, err := run(code, ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0}, stack)
+	}
+	stack, err = run(program(crypto.RSAPKCS1v15, sig, pk, pk), &Context{Xmsg: []byte("other")})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+	stack, err = run(program(crypto.RSAPKCS1v15, sig, pk, pk), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+
+	// a key other than the committed one, even a valid one, is an error
+	_, err = run(program(crypto.RSAPKCS1v15, sig, otherPK, pk), ctx)
+	assert.NotNil(t, err)
+	malformed := append([]byte{0, 0, 0, 2}, pk[4:]...)
+	_, err = run(program(crypto.RSAPKCS1v15, sig, malformed, malformed), ctx)
+	assert.NotNil(t, err)
+	_, err = run(program(3, sig, pk, pk), ctx)
+	assert.NotNil(t, err)
+
+	// no signature object
+	a := Assembler{}
+	a.Append(PushLarge(pk))
+	for _, in := range RSASigVerify(1, 0, crypto.RSAPKCS1v15, pk) {
+		a.Append(in)
+	}
+	_, err = run(a.Code, ctx)
+	assert.NotNil(t, err)
+	_, err = run([]byte{OP_RSAVERIFY}, ctx)
+	assert.NotNil(t, err)
+}
+
+func TestEval_WebAuthnVerify(t *testing.T) {
+	msg := []byte("release 3.0.0")
+	_, pk, authData, clientData, sig := crypto.HelperVerifyDataWebAuthn("example.com", msg)
+
+	// signature, objects authenticator data (0) and client data (1), then
+	// OP_WEBAUTHNVERIFY
+	program := func(sig []byte, rpID string, flags byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		a.Append(PushLarge(authData))
+		a.Append(PushLarge(clientData))
+		for _, in := range WebAuthnSigVerify(0, 1, pk, rpID, flags) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	run := func(code []byte, ctx *Context) ([]byte, error) {
+		e := NewEval()
+		err := e.EvalWithContext(code, ctx)
+		return e.Stack.S, err
+	}
+	ctx := &Context{Xmsg: msg}
+
+	stack, err := run(program(sig, "example.com", crypto.WebAuthnUserVerified), ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, stack)
+
+	badSig := append([]byte{}, sig...)
+	badSig[len(badSig)-1] ^= 1
+	for _, code := range [][]byte{
+		program(badSig, "example.com", 0),
+		program(sig, "example.org", 0),
+		program(sig, "example.com", 0x40),
+	} {
+		stack, err := run(code, ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0}, stack)
+	}
+	stack, err = run(program(sig, "example.com", 0), &Context{Xmsg: []byte("release 3.0.1")})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+	stack, err = run(program(sig, "example.com", 0), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, stack)
+
+	// a missing object or signature is an error
+	a := Assembler{}
+	a.Append(Push(sig))
+	a.Append(PushLarge(authData))
+	for _, in := range WebAuthnSigVerify(0, 1, pk, "example.com", 0) {
+		a.Append(in)
+	}
+	_, err = run(a.Code, ctx)
+	assert.NotNil(t, err)
+	_, err = run(program(nil, "example.com", 0)[2:], ctx)
+	assert.NotNil(t, err)
+	_, err = run([]byte{OP_WEBAUTHNVERIFY}, ctx)
+	assert.NotNil(t, err)
+}
+
+func TestEval_SSHSigVerify(t *testing.T) {
+	msg := []byte("tag v1.4.0")
+	run := func(code []byte, ctx *Context) ([]byte, error) {
+		e := NewEval()
+		err := e.EvalWithContext(code, ctx)
+		return e.Stack.S, err
+	}
+	program := func(sig []byte, publicKey []byte, namespace string) []byte {
+		a := Assembler{}
+		a.Append(PushLarge(sig))
+		for _, in := range SSHSigVerify(0, publicKey, namespace) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	ctx := &Context{Xmsg: msg}
+
+	for _, keyType := range []string{crypto.SSHKeyTypeECDSAP256, crypto.SSHKeyTypeEd25519} {
+		_, pk, sig := crypto.HelperVerifyDataSSH(keyType, []byte("xsig"), msg)
+		stack, err := run(program(sig, pk, "xsig"), ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{1}, stack)
+
+		for _, code := range [][]byte{
+			program(sig, pk, "git"),
+			program(nil, pk, "xsig"),
+			program(sig[:len(sig)-1], pk, "xsig"),
+		} {
+			stack, err := run(code, ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, []byte{0}, stack)
+		}
+		stack, err = run(program(sig, pk, "xsig"), &Context{Xmsg: []byte("tag v1.4.1")})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0}, stack)
+		stack, err = run(program(sig, pk, "xsig"), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(pk): true}})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0}, stack)
+
+		// a malformed policy is an error, not a failed verification
+		_, err = run(program(sig, pk[:len(pk)-1], "xsig"), ctx)
+		assert.NotNil(t, err)
+		_, err = run(program(sig, pk, ""), ctx)
+		assert.NotNil(t, err)
+	}
+
+	a := Assembler{}
+	_, pk, _ := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeEd25519, []byte("xsig"), msg)
+	for _, in := range SSHSigVerify(0, pk, "xsig") {
+		a.Append(in)
+	}
+	_, err := run(a.Code, ctx)
+	assert.NotNil(t, err)
+	_, err = run([]byte{OP_SSHSIGVERIFY}, ctx)
+	assert.NotNil(t, err)
+}
+
+func TestEval_EthVerify(t *testing.T) {
+	msg := []byte("withdraw 10 ETH")
+	domain := crypto.EIP712DomainSeparator("xsig", "1", 1, nil)
+	run := func(code []byte, ctx *Context) ([]byte, error) {
+		e := NewEval()
+		err := e.EvalWithContext(code, ctx)
+		return e.Stack.S, err
+	}
+	program := func(sig []byte, mode byte, address []byte) []byte {
+		a := Assembler{}
+		a.Append(Push(sig))
+		for _, in := range EthSigVerify(mode, domain, address) {
+			a.Append(in)
+		}
+		return a.Code
+	}
+	ctx := &Context{Xmsg: msg}
+
+	for _, mode := range []byte{crypto.EthPersonalSign, crypto.EthTypedData} {
+		_, address, sig := crypto.HelperVerifyDataEthereum(mode, domain, msg)
+		stack, err := run(program(sig, mode, address), ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{1}, stack)
+
+		_, otherAddress, _ := crypto.HelperVerifyDataEthereum(mode, domain, msg)
+		badV := append(append([]byte{}, sig[:64]...), 2)
+		for _, code := range [][]byte{
+			program(sig, mode, otherAddress),
+			program(sig, mode^1, address),
+			program(badV, mode, address),
+			program(make([]byte, 65), mode, address),
+		} {
+			stack, err := run(code, ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, []byte{0}, stack)
+		}
+		stack, err = run(program(sig, mode, address), &Context{Xmsg: []byte("withdraw 11 ETH")})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0}, stack)
+		stack, err = run(program(sig, mode, address), &Context{Xmsg: msg, Revoked: RevocationSet{crypto.KeyFingerprint(address): true}})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0}, stack)
+
+		// a short signature or a missing operand is an error
+		_, err = run(program(sig[:64], mode, address), ctx)
+		assert.NotNil(t, err)
+	}
+
+	_, err := run(append(program(nil, crypto.EthPersonalSign, make([]byte, 20))[:22], OP_PUSH, 1, 2, OP_ETHVERIFY), ctx)
+	assert.NotNil(t, err)
+	_, err = run([]byte{OP_ETHVERIFY}, ctx)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,77 @@
+package lowlevel
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Structured messages. A message OP_MSGFIELD can read is a sequence of
+// fields, each encoded as
+//
+//	tag (1 byte) || length (2 bytes, big-endian) || value
+//
+// with tags strictly increasing and nothing after the last field. Requiring
+// sorted, unique tags makes the encoding canonical: a set of fields has
+// exactly one valid encoding, so signers and policies cannot disagree about
+// which value a tag refers to.
+
+// MaxMessageFieldSize is the largest value a field can hold.
+const MaxMessageFieldSize = 0xFFFF
+
+type MessageField struct {
+	Tag   byte
+	Value []byte
+}
+
+// EncodeMessage serializes fields, which must be sorted by strictly increasing tag.
+func EncodeMessage(fields []MessageField) ([]byte, error) {
+	var msg []byte
+	for i, f := range fields {
+		if i > 0 && f.Tag <= fields[i-1].Tag {
+			return nil, errors.Errorf("field tags not strictly increasing (%d after %d)", f.Tag, fields[i-1].Tag)
+		}
+		if len(f.Value) > MaxMessageFieldSize {
+			return nil, errors.Errorf("field %d too large: %d bytes (max %d)", f.Tag, len(f.Value), MaxMessageFieldSize)
+		}
+		msg = append(msg, f.Tag, byte(len(f.Value)>>8), byte(len(f.Value)))
+		msg = append(msg, f.Value...)
+	}
+	return msg, nil
+}
+
+// ParseMessage parses and validates a structured message.
+func ParseMessage(msg []byte) ([]MessageField, error) {
+	var fields []MessageField
+	pos := 0
+	for pos < len(msg) {
+		if len(msg)-pos < 3 {
+			return nil, errors.New("truncated field header")
+		}
+		tag := msg[pos]
+		n := int(msg[pos+1])<<8 | int(msg[pos+2])
+		pos += 3
+		if len(fields) > 0 && tag <= fields[len(fields)-1].Tag {
+			return nil, errors.Errorf("field tags not strictly increasing (%d after %d)", tag, fields[len(fields)-1].Tag)
+		}
+		if n > len(msg)-pos {
+			return nil, errors.Errorf("field %d extends past end of message", tag)
+		}
+		fields = append(fields, MessageField{Tag: tag, Value: msg[pos : pos+n]})
+		pos += n
+	}
+	return fields, nil
+}
+
+// LookupMessageField returns the value of the field with the given tag. The whole
+// message is validated, not just the part up to the field.
+func LookupMessageField(msg []byte, tag byte) ([]byte, error) {
+	fields, err := ParseMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		if f.Tag == tag {
+			return f.Value, nil
+		}
+	}
+	return nil, errors.Errorf("no field with tag %d", tag)
+}
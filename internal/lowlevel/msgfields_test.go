@@ -0,0 +1,73 @@
+package lowlevel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncodeMessage(t *testing.T) {
+	msg, err := EncodeMessage([]MessageField{
+		{Tag: 1, Value: []byte{0x42}},
+		{Tag: 7, Value: []byte{}},
+		{Tag: 9, Value: []byte("unlock")},
+	})
+	assert.Nil(t, err)
+	expected := []byte{1, 0, 1, 0x42, 7, 0, 0, 9, 0, 6, 'u', 'n', 'l', 'o', 'c', 'k'}
+	assert.Equal(t, expected, msg)
+
+	fields, err := ParseMessage(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(fields))
+	assert.Equal(t, []byte("unlock"), fields[2].Value)
+}
+
+func TestEncodeMessage_Errors(t *testing.T) {
+	_, err := EncodeMessage([]MessageField{{Tag: 2}, {Tag: 1}})
+	assert.NotNil(t, err)
+	_, err = EncodeMessage([]MessageField{{Tag: 2}, {Tag: 2}})
+	assert.NotNil(t, err)
+	_, err = EncodeMessage([]MessageField{{Tag: 1, Value: make([]byte, MaxMessageFieldSize+1)}})
+	assert.NotNil(t, err)
+}
+
+func TestEncodeMessage_LargeField(t *testing.T) {
+	value := make([]byte, 300)
+	value[299] = 0xAA
+	msg, err := EncodeMessage([]MessageField{{Tag: 3, Value: value}})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3, 0x01, 0x2C}, msg[:3])
+	got, err := LookupMessageField(msg, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, value, got)
+}
+
+func TestParseMessage_Malformed(t *testing.T) {
+	cases := [][]byte{
+		{1},
+		{1, 0},
+		{1, 0, 2, 0xAA},
+		{1, 0, 0, 1, 0, 0},
+		{2, 0, 0, 1, 0, 0},
+		{1, 0, 1, 0xAA, 0},
+	}
+	for _, msg := range cases {
+		_, err := ParseMessage(msg)
+		assert.NotNil(t, err, "%x", msg)
+	}
+
+	fields, err := ParseMessage(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, fields)
+}
+
+func TestLookupMessageField(t *testing.T) {
+	msg, _ := EncodeMessage([]MessageField{{Tag: 1, Value: []byte{0x42}}, {Tag: 5, Value: []byte{1, 2}}})
+	v, err := LookupMessageField(msg, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2}, v)
+	_, err = LookupMessageField(msg, 2)
+	assert.NotNil(t, err)
+	// a malformed tail is rejected even if the field comes before it
+	_, err = LookupMessageField(append(msg, 0x09), 1)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,98 @@
+package lowlevel
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Numbers are signed integers of up to 64 bits. On the stack they are blobs
+// (see PushBlob) holding the minimal little-endian two's complement encoding
+// of the value: zero is the empty blob, and the most significant byte is
+// never a redundant sign extension. Minimality makes the encoding of every
+// value unique.
+
+// MaxNumSize is the largest encoding of a number, in bytes.
+const MaxNumSize = 8
+
+// EncodeNum returns the minimal encoding of x.
+func EncodeNum(x int64) []byte {
+	buf := []byte{}
+	for x != 0 && x != -1 {
+		buf = append(buf, byte(x))
+		x >>= 8
+	}
+	// make sure the sign bit of the last byte agrees with the sign of x
+	if x == 0 && len(buf) > 0 && buf[len(buf)-1]&0x80 != 0 {
+		buf = append(buf, 0x00)
+	}
+	if x == -1 && (len(buf) == 0 || buf[len(buf)-1]&0x80 == 0) {
+		buf = append(buf, 0xFF)
+	}
+	return buf
+}
+
+// DecodeNum parses a minimally-encoded number.
+func DecodeNum(buf []byte) (int64, error) {
+	if len(buf) > MaxNumSize {
+		return 0, errors.Errorf("number too long (%d bytes, max %d)", len(buf), MaxNumSize)
+	}
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	last := buf[len(buf)-1]
+	if len(buf) == 1 && last == 0x00 {
+		return 0, errors.New("number not minimally encoded")
+	}
+	if len(buf) > 1 {
+		signBelow := buf[len(buf)-2] & 0x80
+		if (last == 0x00 && signBelow == 0) || (last == 0xFF && signBelow != 0) {
+			return 0, errors.New("number not minimally encoded")
+		}
+	}
+	var x int64
+	for i := len(buf) - 1; i >= 0; i-- {
+		x = x<<8 | int64(buf[i])
+	}
+	// sign-extend
+	shift := uint(64 - 8*len(buf))
+	return x << shift >> shift, nil
+}
+
+func addInt64(a, b int64) (int64, bool) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, false
+	}
+	return a + b, true
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, false
+	}
+	return a - b, true
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	if a > 0 {
+		if b > 0 {
+			if a > math.MaxInt64/b {
+				return 0, false
+			}
+		} else if b < math.MinInt64/a {
+			return 0, false
+		}
+	} else {
+		if b > 0 {
+			if a < math.MinInt64/b {
+				return 0, false
+			}
+		} else if a < math.MaxInt64/b {
+			return 0, false
+		}
+	}
+	return a * b, true
+}
@@ -0,0 +1,80 @@
+package lowlevel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestNum_Encode(t *testing.T) {
+	cases := []struct {
+		x        int64
+		expected []byte
+	}{
+		{0, []byte{}},
+		{1, []byte{0x01}},
+		{-1, []byte{0xFF}},
+		{127, []byte{0x7F}},
+		{128, []byte{0x80, 0x00}},
+		{-128, []byte{0x80}},
+		{-129, []byte{0x7F, 0xFF}},
+		{255, []byte{0xFF, 0x00}},
+		{256, []byte{0x00, 0x01}},
+		{math.MaxInt64, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F}},
+		{math.MinInt64, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, EncodeNum(c.x), "EncodeNum(%d)", c.x)
+		x, err := DecodeNum(c.expected)
+		assert.Nil(t, err)
+		assert.Equal(t, c.x, x)
+	}
+}
+
+func TestNum_RoundTrip(t *testing.T) {
+	for _, x := range []int64{2, -2, 1000, -1000, 1 << 31, -(1 << 31), 1<<56 - 1, -(1 << 56), 1675209600} {
+		y, err := DecodeNum(EncodeNum(x))
+		assert.Nil(t, err)
+		assert.Equal(t, x, y)
+	}
+}
+
+func TestNum_DecodeRejectsNonMinimal(t *testing.T) {
+	for _, buf := range [][]byte{
+		{0x00},
+		{0x01, 0x00},
+		{0xFF, 0xFF},
+		{0x80, 0xFF, 0xFF},
+	} {
+		_, err := DecodeNum(buf)
+		assert.NotNil(t, err, "%x", buf)
+		assert.Contains(t, err.Error(), "not minimally encoded")
+	}
+}
+
+func TestNum_DecodeRejectsTooLong(t *testing.T) {
+	_, err := DecodeNum([]byte{1, 2, 3, 4, 5, 6, 7, 8, 0x01})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "too long")
+}
+
+func TestNum_Overflow(t *testing.T) {
+	_, ok := addInt64(math.MaxInt64, 1)
+	assert.False(t, ok)
+	_, ok = addInt64(math.MinInt64, -1)
+	assert.False(t, ok)
+	_, ok = subInt64(math.MinInt64, 1)
+	assert.False(t, ok)
+	_, ok = subInt64(0, math.MinInt64)
+	assert.False(t, ok)
+	_, ok = mulInt64(math.MinInt64, -1)
+	assert.False(t, ok)
+	_, ok = mulInt64(1<<32, 1<<31)
+	assert.False(t, ok)
+	r, ok := mulInt64(-(1 << 32), 1<<31)
+	assert.True(t, ok)
+	assert.Equal(t, int64(math.MinInt64), r)
+	r, ok = subInt64(-1, math.MaxInt64)
+	assert.True(t, ok)
+	assert.Equal(t, int64(math.MinInt64), r)
+}
@@ -9,3 +9,42 @@ const OP_MULTISIGVERIFY = byte(5)
 const OP_AND = byte(6)
 const OP_OR  = byte(7)
 const OP_NOT = byte(8)
+const OP_IF = byte(9)
+const OP_ELSE = byte(10)
+const OP_ENDIF = byte(11)
+const OP_THRESHOLD = byte(12)
+const OP_TOALTSTACK = byte(13)
+const OP_FROMALTSTACK = byte(14)
+const OP_NUMADD = byte(15)
+const OP_NUMSUB = byte(16)
+const OP_NUMMUL = byte(17)
+const OP_NUMLESSTHAN = byte(18)
+const OP_NUMEQUAL = byte(19)
+const OP_TONUM = byte(20)
+const OP_WEIGHTEDSIGVERIFY = byte(21)
+const OP_MERKLESIGVERIFY = byte(22)
+const OP_CHECKSECURITYVERSION = byte(23)
+const OP_MSGFIELD = byte(24)
+const OP_EQUAL = byte(25)
+const OP_CHECKSIGFROMSTACK = byte(26)
+const OP_CAT = byte(27)
+const OP_SPLIT = byte(28)
+const OP_SIZE = byte(29)
+const OP_CHECKNONCE = byte(30)
+const OP_CHECKPKHASH = byte(31)
+const OP_STRICTMULTISIGVERIFY = byte(32)
+const OP_SIGVERIFYRAW = byte(33)
+// OP_MULTISIGVERIFYRAW is always strict: there is no raw counterpart of the
+// non-strict OP_MULTISIGVERIFY.
+const OP_MULTISIGVERIFYRAW = byte(34)
+const OP_SIGVERIFYSECP256K1 = byte(35)
+const OP_SIGVERIFYSCHNORR = byte(36)
+const OP_MIXEDMULTISIGVERIFY = byte(37)
+const OP_PUSHLARGE = byte(38)
+const OP_SLHDSAVERIFY = byte(39)
+const OP_RSAVERIFY = byte(40)
+const OP_WEBAUTHNVERIFY = byte(41)
+const OP_SSHSIGVERIFY = byte(42)
+const OP_ETHVERIFY = byte(43)
+const OP_SIGVERIFYED25519 = byte(44)
+const OP_SIGVERIFYHASH = byte(45)
@@ -54,6 +54,19 @@ func (s *Stack) Pop2() (uint8, uint8, error) {
 	return a, b, nil
 }
 
+// PopBytes pops n bytes; the first byte popped is buf[0].
+func (s *Stack) PopBytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	for i := range buf {
+		val, err := s.Pop()
+		if err != nil {
+			return nil, errors.Wrapf(err, "PopBytes")
+		}
+		buf[i] = val
+	}
+	return buf, nil
+}
+
 // PopPublicKey pops a NIST-P256 (aka secp256r1) public key from the stack.
 // We assume they are encoded according to ANSI X9.63 (uncompressed):
 // 04 || X || Y where X and Y are 32 bytes.
@@ -138,3 +151,67 @@ func (s *Stack) PopSignature() ([]byte, error) {
 
 	return sig, nil
 }
+
+// MaxBlobSize is the largest byte string PushBlob accepts: its length has
+// to fit in the 8-bit word on top of it.
+const MaxBlobSize = 255
+
+// PushBlob pushes a length-prefixed byte string: buf in reverse order, then
+// its length, so that popping yields the length followed by buf[0], buf[1]...
+// This matches what OP_PUSH does with the literal len(buf) || buf.
+func (s *Stack) PushBlob(buf []byte) error {
+	if len(buf) > MaxBlobSize {
+		return errors.Errorf("blob too large: %d bytes (max %d)", len(buf), MaxBlobSize)
+	}
+	for i := len(buf) - 1; i >= 0; i-- {
+		err := s.Push(buf[i])
+		if err != nil {
+			return err
+		}
+	}
+	return s.Push(byte(len(buf)))
+}
+
+// PopBlob pops a byte string pushed by PushBlob.
+func (s *Stack) PopBlob() ([]byte, error) {
+	n, err := s.Pop()
+	if err != nil {
+		return nil, errors.Wrapf(err, "PopBlob")
+	}
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i], err = s.Pop()
+		if err != nil {
+			return nil, errors.Wrapf(err, "PopBlob")
+		}
+	}
+	return buf, nil
+}
+
+func (s *Stack) PushNum(x int64) error {
+	return s.PushBlob(EncodeNum(x))
+}
+
+func (s *Stack) PopNum() (int64, error) {
+	buf, err := s.PopBlob()
+	if err != nil {
+		return 0, errors.Wrapf(err, "PopNum")
+	}
+	x, err := DecodeNum(buf)
+	if err != nil {
+		return 0, errors.Wrapf(err, "PopNum")
+	}
+	return x, nil
+}
+
+func (s *Stack) PopNum2() (int64, int64, error) {
+	a, err := s.PopNum()
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "PopNum2")
+	}
+	b, err := s.PopNum()
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "PopNum2")
+	}
+	return a, b, nil
+}
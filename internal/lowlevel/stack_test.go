@@ -176,3 +176,72 @@ func TestStack_Pop2CompletelyEmpty(t *testing.T) {
 	_, _, err := s.Pop2()
 	assert.NotNil(t, err, "Pop2 on empty stack should fail")
 }
+
+func TestStack_Blob(t *testing.T) {
+	s := Stack{}
+	err := s.PushBlob([]byte{1, 2, 3})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3, 2, 1, 3}, s.S)
+	buf, err := s.PopBlob()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, buf)
+	assert.True(t, s.IsEmpty())
+}
+
+func TestStack_BlobMatchesAssembler(t *testing.T) {
+	a := Assembler{}
+	a.Append(PushBlob([]byte("hello")))
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	buf, err := e.Stack.PopBlob()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), buf)
+}
+
+func TestStack_PushBlobTooLarge(t *testing.T) {
+	s := Stack{}
+	assert.Nil(t, s.PushBlob(make([]byte, MaxBlobSize)))
+	s = Stack{}
+	err := s.PushBlob(make([]byte, MaxBlobSize+1))
+	assert.NotNil(t, err)
+	assert.True(t, s.IsEmpty())
+}
+
+func TestStack_PopBlobUnderflow(t *testing.T) {
+	s := Stack{}
+	_, err := s.PopBlob()
+	assert.NotNil(t, err)
+	s.Push(1)
+	s.Push(3)
+	_, err = s.PopBlob()
+	assert.NotNil(t, err)
+}
+
+func TestStack_Num(t *testing.T) {
+	s := Stack{}
+	assert.Nil(t, s.PushNum(-1000))
+	assert.Nil(t, s.PushNum(0))
+	b, a, err := s.PopNum2()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), b)
+	assert.Equal(t, int64(-1000), a)
+}
+
+func TestStack_PopNumNotMinimal(t *testing.T) {
+	s := Stack{}
+	s.PushBlob([]byte{0x05, 0x00})
+	_, err := s.PopNum()
+	assert.NotNil(t, err)
+}
+
+func TestStack_PopBytes(t *testing.T) {
+	a := Assembler{}
+	a.Append(Push([]byte{1, 2, 3}))
+	e := NewEval()
+	assert.Nil(t, e.Eval(a.Code))
+	buf, err := e.Stack.PopBytes(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 2}, buf)
+	_, err = e.Stack.PopBytes(2)
+	assert.NotNil(t, err)
+}
@@ -7,35 +7,53 @@ import (
 )
 
 func RunMachine001(XpPubKey []byte, XpSig []byte, XpMsg []byte) bool {
+	return RunMachine001WithContext(XpPubKey, XpSig, &lowlevel.Context{Xmsg: XpMsg})
+}
+
+// RunMachine001WithContext is RunMachine001 with verifier-supplied state.
+// Only the xpubkey sees ctx: the xsig is still evaluated with an empty
+// context, as it only supplies data.
+func RunMachine001WithContext(XpPubKey []byte, XpSig []byte, ctx *lowlevel.Context) bool {
+	ok, _ := RunMachine001CheckNonce(XpPubKey, XpSig, ctx)
+	return ok
+}
+
+// RunMachine001CheckNonce is RunMachine001WithContext that also reports
+// whether the evaluation of the xpubkey went through an OP_CHECKNONCE that
+// found ctx.Nonce, see lowlevel.Eval.NonceChecked.
+func RunMachine001CheckNonce(XpPubKey []byte, XpSig []byte, ctx *lowlevel.Context) (bool, bool) {
 	mc := MachineCode{}
 	err := mc.Deserialize(XpSig, CodeTypeXSig)
 	if err != nil {
 		log.Println("Deserialize", err)
-		return false
+		return false, false
 	}
 	e := lowlevel.NewEval()
 	err = e.Eval(mc.Code)
 	if err != nil {
 		log.Println("Eval part 1", err)
-		return false
+		return false, false
 	}
 
 	intermediateStack := e.Stack.S
+	objects := e.Objects
 	e = lowlevel.NewEval()
 	e.Stack.S = intermediateStack
+	// large objects (e.g. SLH-DSA signatures) pushed by the xsig
+	e.Objects = objects
 
 	err = mc.Deserialize(XpPubKey, CodeTypeXPublicKey)
 	if err != nil {
 		log.Println("Deserialize:", err)
-		return false
+		return false, false
 	}
-	err = e.EvalWithXmsg(mc.Code, XpMsg)
+	err = e.EvalWithContext(mc.Code, ctx)
 	if err != nil {
 		log.Println("Eval part 2", err)
-		return false
+		return false, false
 	}
 
 	expectedEndStack := []byte{byte(1)}
 	endStackOk := bytes.Equal(e.Stack.S, expectedEndStack)
-	return endStackOk
+	return endStackOk, e.NonceChecked
 }
@@ -1,9 +1,15 @@
 package machines
 
 import (
+	stdcrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"github.com/oreparaz/xsig/internal/crypto"
 	ll "github.com/oreparaz/xsig/internal/lowlevel"
 	"github.com/stretchr/testify/assert"
+	"math/big"
 	"testing"
 )
 
@@ -14,13 +20,13 @@ func TestRunMachine001(t *testing.T) {
 	a := MachineCode{}
 	a.Append(ll.Push(sig))
 
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(publicKeyBytes))
 	b.Append(ll.SignatureVerify())
 
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.True(t, RunMachine001(xPubKey, xSig, msg))
 }
@@ -30,7 +36,7 @@ func helperTestMultisignature(msg, pk1, pk2, pk3, sig1, sig2 []byte) bool {
 	a.Append(ll.Push(sig1))
 	a.Append(ll.Push(sig2))
 
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk1))
@@ -41,7 +47,7 @@ func helperTestMultisignature(msg, pk1, pk2, pk3, sig1, sig2 []byte) bool {
 
 	b.Append(ll.MultisigVerify())
 
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	return RunMachine001(xPubKey, xSig, msg)
 }
@@ -73,12 +79,12 @@ func TestRunMachine001_WrongMessage(t *testing.T) {
 
 	a := MachineCode{}
 	a.Append(ll.Push(sig))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk))
 	b.Append(ll.SignatureVerify())
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.False(t, RunMachine001(xPubKey, xSig, []byte("wrong")),
 		"signature verified against wrong message should fail")
@@ -90,14 +96,14 @@ func TestRunMachine001_1of1Multisig(t *testing.T) {
 
 	a := MachineCode{}
 	a.Append(ll.Push(sig1))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk1))
 	b.Append(ll.Push1(1))
 	b.Append(ll.Push1(1))
 	b.Append(ll.MultisigVerify())
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.True(t, RunMachine001(xPubKey, xSig, msg))
 }
@@ -112,7 +118,7 @@ func TestRunMachine001_3of3Multisig(t *testing.T) {
 	a.Append(ll.Push(sig1))
 	a.Append(ll.Push(sig2))
 	a.Append(ll.Push(sig3))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk1))
@@ -121,7 +127,7 @@ func TestRunMachine001_3of3Multisig(t *testing.T) {
 	b.Append(ll.Push1(3))
 	b.Append(ll.Push1(3))
 	b.Append(ll.MultisigVerify())
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.True(t, RunMachine001(xPubKey, xSig, msg),
 		"3-of-3 multisig with all valid signatures should pass")
@@ -138,7 +144,7 @@ func TestRunMachine001_3of3MultisigMissingSig(t *testing.T) {
 	a.Append(ll.Push(sig1))
 	a.Append(ll.Push(sig2))
 	a.Append(ll.Push(sig1))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk1))
@@ -147,7 +153,7 @@ func TestRunMachine001_3of3MultisigMissingSig(t *testing.T) {
 	b.Append(ll.Push1(3))
 	b.Append(ll.Push1(3))
 	b.Append(ll.MultisigVerify())
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.False(t, RunMachine001(xPubKey, xSig, msg),
 		"3-of-3 with only 2 distinct valid signers should fail")
@@ -163,7 +169,7 @@ func TestRunMachine001_DuplicateSigsDistinctKeys(t *testing.T) {
 	a := MachineCode{}
 	a.Append(ll.Push(sig1))
 	a.Append(ll.Push(sig1)) // duplicate signature
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk1))
@@ -172,7 +178,7 @@ func TestRunMachine001_DuplicateSigsDistinctKeys(t *testing.T) {
 	b.Append(ll.Push1(2))
 	b.Append(ll.Push1(3))
 	b.Append(ll.MultisigVerify())
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.False(t, RunMachine001(xPubKey, xSig, msg),
 		"duplicate signatures from same signer should not satisfy quorum")
@@ -187,7 +193,7 @@ func TestRunMachine001_MultisigWrongMessage(t *testing.T) {
 	a := MachineCode{}
 	a.Append(ll.Push(sig1))
 	a.Append(ll.Push(sig2))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push(pk1))
@@ -196,7 +202,7 @@ func TestRunMachine001_MultisigWrongMessage(t *testing.T) {
 	b.Append(ll.Push1(2))
 	b.Append(ll.Push1(3))
 	b.Append(ll.MultisigVerify())
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.False(t, RunMachine001(xPubKey, xSig, []byte("wrong")),
 		"multisig against wrong message should fail")
@@ -214,11 +220,11 @@ func TestRunMachine001_XSigEvalError(t *testing.T) {
 	// Valid xsig prefix but contains an unknown opcode → eval part 1 fails
 	mc := MachineCode{}
 	mc.Code = []byte{0xFF} // unknown opcode
-	xSig := mc.Serialize(CodeTypeXSig)
+	xSig := mc.SerializeUnchecked(CodeTypeXSig)
 
 	b := MachineCode{}
 	b.Append(ll.Push1(1))
-	xPubKey := b.Serialize(CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.False(t, RunMachine001(xPubKey, xSig, []byte("msg")))
 }
@@ -227,7 +233,7 @@ func TestRunMachine001_XPubKeyBadPrefix(t *testing.T) {
 	// Valid xsig, but xpubkey has wrong prefix
 	a := MachineCode{}
 	a.Append(ll.Push1(1))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	assert.False(t, RunMachine001([]byte("garbage"), xSig, []byte("msg")))
 }
@@ -236,11 +242,900 @@ func TestRunMachine001_XPubKeyEvalError(t *testing.T) {
 	// Valid xsig (eval succeeds), valid xpubkey prefix but code has unknown opcode
 	a := MachineCode{}
 	a.Append(ll.Push1(1))
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
 
 	mc := MachineCode{}
 	mc.Code = []byte{0xFF}
-	xPubKey := mc.Serialize(CodeTypeXPublicKey)
+	xPubKey := mc.SerializeUnchecked(CodeTypeXPublicKey)
 
 	assert.False(t, RunMachine001(xPubKey, xSig, []byte("msg")))
 }
+
+func helperTestBranchPolicy(msg, pkA, pkB, pkC []byte, xsigCode func(mc *MachineCode)) bool {
+	a := MachineCode{}
+	xsigCode(&a)
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+
+	// "signed by A" OR "signed by both B and C": only the branch picked
+	// by the xsig is evaluated
+	b := MachineCode{}
+	b.Append(ll.If())
+	b.Append(ll.Push(pkA))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.Else())
+	b.Append(ll.Push(pkB))
+	b.Append(ll.Push(pkC))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(2))
+	b.Append(ll.MultisigVerify())
+	b.Append(ll.EndIf())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	return RunMachine001(xPubKey, xSig, msg)
+}
+
+func TestRunMachine001_BranchPolicy(t *testing.T) {
+	msg := []byte("test")
+	_, pkA, sigA := crypto.HelperVerifyData(msg)
+	_, pkB, sigB := crypto.HelperVerifyData(msg)
+	_, pkC, sigC := crypto.HelperVerifyData(msg)
+
+	assert.True(t, helperTestBranchPolicy(msg, pkA, pkB, pkC, func(mc *MachineCode) {
+		mc.Append(ll.Push(sigA))
+		mc.Append(ll.Push1(1))
+	}))
+	assert.True(t, helperTestBranchPolicy(msg, pkA, pkB, pkC, func(mc *MachineCode) {
+		mc.Append(ll.Push(sigB))
+		mc.Append(ll.Push(sigC))
+		mc.Append(ll.Push1(0))
+	}))
+	// right signatures, wrong branch
+	assert.False(t, helperTestBranchPolicy(msg, pkA, pkB, pkC, func(mc *MachineCode) {
+		mc.Append(ll.Push(sigA))
+		mc.Append(ll.Push1(0))
+	}))
+	assert.False(t, helperTestBranchPolicy(msg, pkA, pkB, pkC, func(mc *MachineCode) {
+		mc.Append(ll.Push(sigB))
+		mc.Append(ll.Push1(1))
+	}))
+	// the xsig cannot leave an OP_IF open for the xpubkey to close
+	assert.False(t, helperTestBranchPolicy(msg, pkA, pkB, pkC, func(mc *MachineCode) {
+		mc.Append(ll.Push(sigA))
+		mc.Append(ll.Push1(1))
+		mc.Append(ll.Push1(0))
+		mc.Append(ll.If())
+	}))
+}
+
+// dummySig is a well-formed but empty DER signature, used to fill the slot
+// of a signer who did not sign.
+var dummySig = []byte{0x30, 0x00}
+
+func helperTestThresholdPolicy(msg []byte, eng, mgr, vp [][]byte, engSigs, mgrSigs, vpSigs [][]byte) bool {
+	// [two engineers and one manager] or [two vice-presidents]
+	b := MachineCode{}
+	for _, pk := range eng {
+		b.Append(ll.Push(pk))
+	}
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(len(eng)))
+	b.Append(ll.MultisigVerify())
+	b.Append(ll.ToAltStack())
+	for _, pk := range mgr {
+		b.Append(ll.Push(pk))
+	}
+	b.Append(ll.Push1(1))
+	b.Append(ll.Push1(len(mgr)))
+	b.Append(ll.MultisigVerify())
+	b.Append(ll.ToAltStack())
+	for _, pk := range vp {
+		b.Append(ll.Push(pk))
+	}
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(len(vp)))
+	b.Append(ll.MultisigVerify())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Threshold())
+	b.Append(ll.Push1(1))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Threshold())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	// signatures are consumed in reverse order: engineers first
+	a := MachineCode{}
+	for _, sigs := range [][][]byte{vpSigs, mgrSigs, engSigs} {
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+	}
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+
+	return RunMachine001(xPubKey, xSig, msg)
+}
+
+func TestRunMachine001_ThresholdPolicy(t *testing.T) {
+	msg := []byte("release 1.2.3")
+	keys := func(n int) ([][]byte, [][]byte) {
+		var pks, sigs [][]byte
+		for i := 0; i < n; i++ {
+			_, pk, sig := crypto.HelperVerifyData(msg)
+			pks = append(pks, pk)
+			sigs = append(sigs, sig)
+		}
+		return pks, sigs
+	}
+	eng, engSig := keys(3)
+	mgr, mgrSig := keys(2)
+	vp, vpSig := keys(3)
+	none2 := [][]byte{dummySig, dummySig}
+	none1 := [][]byte{dummySig}
+
+	// two engineers and one manager
+	assert.True(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		[][]byte{engSig[0], engSig[2]}, [][]byte{mgrSig[1]}, none2))
+	// two vice-presidents
+	assert.True(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		none2, none1, [][]byte{vpSig[1], vpSig[2]}))
+	// everybody
+	assert.True(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		[][]byte{engSig[0], engSig[1]}, [][]byte{mgrSig[0]}, [][]byte{vpSig[0], vpSig[1]}))
+
+	// two engineers, no manager
+	assert.False(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		[][]byte{engSig[0], engSig[1]}, none1, none2))
+	// one engineer and one manager
+	assert.False(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		[][]byte{engSig[0], dummySig}, [][]byte{mgrSig[0]}, none2))
+	// one vice-president and one manager
+	assert.False(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		none2, [][]byte{mgrSig[0]}, [][]byte{vpSig[0], dummySig}))
+	// a manager signature does not count as an engineer
+	assert.False(t, helperTestThresholdPolicy(msg, eng, mgr, vp,
+		[][]byte{engSig[0], mgrSig[0]}, [][]byte{mgrSig[1]}, none2))
+}
+
+func helperTestWeightedPolicy(msg []byte, pks [][]byte, weights []int64, target int64, sigs [][]byte) bool {
+	b := MachineCode{}
+	for i, pk := range pks {
+		b.Append(ll.Push(pk))
+		b.Append(ll.PushNum(weights[i]))
+	}
+	b.Append(ll.PushNum(target))
+	b.Append(ll.Push1(len(pks)))
+	b.Append(ll.WeightedSigVerify())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	a := MachineCode{}
+	for _, sig := range sigs {
+		a.Append(ll.Push(sig))
+	}
+	a.Append(ll.Push1(len(sigs)))
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+
+	return RunMachine001(xPubKey, xSig, msg)
+}
+
+func TestRunMachine001_WeightedPolicy(t *testing.T) {
+	// the CTO alone, or any three engineers
+	msg := []byte("release 1.2.3")
+	_, cto, ctoSig := crypto.HelperVerifyData(msg)
+	pks := [][]byte{cto}
+	weights := []int64{3}
+	var engSig [][]byte
+	for i := 0; i < 4; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		pks = append(pks, pk)
+		weights = append(weights, 1)
+		engSig = append(engSig, sig)
+	}
+
+	assert.True(t, helperTestWeightedPolicy(msg, pks, weights, 3, [][]byte{ctoSig}))
+	assert.True(t, helperTestWeightedPolicy(msg, pks, weights, 3, [][]byte{engSig[0], engSig[2], engSig[3]}))
+	assert.True(t, helperTestWeightedPolicy(msg, pks, weights, 3, [][]byte{engSig[1], ctoSig}))
+
+	assert.False(t, helperTestWeightedPolicy(msg, pks, weights, 3, [][]byte{engSig[0], engSig[1]}))
+	assert.False(t, helperTestWeightedPolicy(msg, pks, weights, 3, [][]byte{engSig[0], engSig[0], engSig[0]}))
+	assert.False(t, helperTestWeightedPolicy(msg, pks, weights, 3, [][]byte{}))
+	assert.False(t, helperTestWeightedPolicy([]byte("release 1.2.4"), pks, weights, 3, [][]byte{ctoSig}))
+}
+
+func TestRunMachine001_MerkleAllowlist(t *testing.T) {
+	msg := []byte("release 1.2.3")
+	var pks [][]byte
+	var privs []*ecdsa.PrivateKey
+	for i := 0; i < 400; i++ {
+		priv, pk, _ := crypto.HelperVerifyData(msg)
+		pks = append(pks, pk)
+		privs = append(privs, priv)
+	}
+	root, err := crypto.MerkleRoot(pks)
+	assert.Nil(t, err)
+
+	b := MachineCode{}
+	b.Append(ll.Push(root))
+	b.Append(ll.MerkleSigVerify())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+	assert.Less(t, len(xPubKey), 64)
+
+	xSig := func(signer, claimed int) []byte {
+		hash := sha256.Sum256(msg)
+		sig, err := ecdsa.SignASN1(rand.Reader, privs[signer], hash[:])
+		assert.Nil(t, err)
+		proof, err := crypto.MerkleProof(pks, claimed)
+		assert.Nil(t, err)
+		a := MachineCode{}
+		a.Append(ll.Push(sig))
+		for _, in := range ll.PushMerkleProof(pks[claimed], proof) {
+			a.Append(in)
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	for _, i := range []int{0, 1, 255, 398, 399} {
+		assert.True(t, RunMachine001(xPubKey, xSig(i, i), msg), "engineer %d", i)
+	}
+	// signed by one engineer, proof for another
+	assert.False(t, RunMachine001(xPubKey, xSig(3, 4), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(7, 7), []byte("release 1.2.4")))
+
+	// a key outside the allowlist
+	_, outsiderPK, outsiderSig := crypto.HelperVerifyData(msg)
+	proof, _ := crypto.MerkleProof(pks, 0)
+	a := MachineCode{}
+	a.Append(ll.Push(outsiderSig))
+	for _, in := range ll.PushMerkleProof(outsiderPK, proof) {
+		a.Append(in)
+	}
+	assert.False(t, RunMachine001(xPubKey, a.SerializeUnchecked(CodeTypeXSig), msg))
+}
+
+func TestRunMachine001WithContext_SecurityVersion(t *testing.T) {
+	// signature valid and the version field of the signed image >= device
+	// security version
+	const tagVersion = 4
+	image := func(version int64) []byte {
+		msg, _ := ll.EncodeMessage([]ll.MessageField{
+			{Tag: 1, Value: []byte("firmware image")},
+			{Tag: tagVersion, Value: ll.EncodeNum(version)},
+		})
+		return msg
+	}
+	msg := image(3)
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.Push1(tagVersion))
+	b.Append(ll.MsgField())
+	b.Append(ll.CheckSecurityVersion())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	a := MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+
+	assert.True(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{Xmsg: msg, SecurityVersion: 2}))
+	assert.True(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{Xmsg: msg, SecurityVersion: 3}))
+	assert.False(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{Xmsg: msg, SecurityVersion: 4}))
+	// RunMachine001 runs with security version 0
+	assert.True(t, RunMachine001(xPubKey, xSig, msg))
+
+	// the xsig cannot claim a newer version than the one signed
+	a = MachineCode{}
+	a.Append(ll.PushNum(4))
+	a.Append(ll.Push(sig))
+	assert.False(t, RunMachine001WithContext(xPubKey, a.SerializeUnchecked(CodeTypeXSig), &ll.Context{Xmsg: msg, SecurityVersion: 4}))
+	assert.False(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{Xmsg: image(4), SecurityVersion: 4}))
+}
+
+func TestRunMachine001WithContext_XSigSeesEmptyContext(t *testing.T) {
+	a := MachineCode{}
+	a.Append(ll.PushNum(0))
+	a.Append(ll.CheckSecurityVersion())
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+	xPubKey := (&MachineCode{}).SerializeUnchecked(CodeTypeXPublicKey)
+
+	// in the xsig, version 0 always passes: it never sees the device state
+	assert.True(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{SecurityVersion: 9}))
+}
+
+func TestRunMachine001_MessageFieldPolicy(t *testing.T) {
+	// 2-of-3 approval, only for product 0x42 debug-unlock commands
+	const tagProduct, tagCommand = 1, 2
+	unlock42, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: tagProduct, Value: []byte{0x42}},
+		{Tag: tagCommand, Value: []byte("debug-unlock")},
+	})
+	unlock43, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: tagProduct, Value: []byte{0x43}},
+		{Tag: tagCommand, Value: []byte("debug-unlock")},
+	})
+	flash42, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: tagProduct, Value: []byte{0x42}},
+		{Tag: tagCommand, Value: []byte("flash")},
+	})
+
+	privs := make([]*ecdsa.PrivateKey, 3)
+	pks := make([][]byte, 3)
+	for i := range privs {
+		privs[i], pks[i], _ = crypto.HelperVerifyData(nil)
+	}
+
+	b := MachineCode{}
+	for _, pk := range pks {
+		b.Append(ll.Push(pk))
+	}
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.MultisigVerify())
+	b.Append(ll.Push1(tagProduct))
+	b.Append(ll.MsgField())
+	b.Append(ll.PushBlob([]byte{0x42}))
+	b.Append(ll.Equal())
+	b.Append(ll.And())
+	b.Append(ll.Push1(tagCommand))
+	b.Append(ll.MsgField())
+	b.Append(ll.PushBlob([]byte("debug-unlock")))
+	b.Append(ll.Equal())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(msg []byte) []byte {
+		a := MachineCode{}
+		for _, i := range []int{0, 2} {
+			hash := sha256.Sum256(msg)
+			sig, err := ecdsa.SignASN1(rand.Reader, privs[i], hash[:])
+			assert.Nil(t, err)
+			a.Append(ll.Push(sig))
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(unlock42), unlock42))
+	// validly signed, but for another product or command
+	assert.False(t, RunMachine001(xPubKey, xSig(unlock43), unlock43))
+	assert.False(t, RunMachine001(xPubKey, xSig(flash42), flash42))
+	// an unstructured message is rejected outright
+	assert.False(t, RunMachine001(xPubKey, xSig([]byte("yolo")), []byte("yolo")))
+}
+
+func TestRunMachine001_OracleAttestation(t *testing.T) {
+	// release signed by a maintainer, and CI attested that the build id
+	// in the release message passed tests
+	const tagBuild = 1
+	release := func(build string) []byte {
+		msg, _ := ll.EncodeMessage([]ll.MessageField{{Tag: tagBuild, Value: []byte(build)}})
+		return msg
+	}
+	sign := func(priv *ecdsa.PrivateKey, msg []byte) []byte {
+		hash := sha256.Sum256(msg)
+		sig, err := ecdsa.SignASN1(rand.Reader, priv, hash[:])
+		assert.Nil(t, err)
+		return sig
+	}
+	maintainer, maintainerPK, _ := crypto.HelperVerifyData(nil)
+	ci, ciPK, _ := crypto.HelperVerifyData(nil)
+
+	b := MachineCode{}
+	b.Append(ll.Push(maintainerPK))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.ToAltStack())
+	b.Append(ll.Push1(tagBuild))
+	b.Append(ll.MsgField())
+	b.Append(ll.Push(ciPK))
+	b.Append(ll.CheckSigFromStack())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(ciSig, releaseSig []byte) []byte {
+		a := MachineCode{}
+		a.Append(ll.Push(ciSig))
+		a.Append(ll.Push(releaseSig))
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	msg := release("build-1234")
+	assert.True(t, RunMachine001(xPubKey, xSig(sign(ci, []byte("build-1234")), sign(maintainer, msg)), msg))
+	// CI attested a different build
+	assert.False(t, RunMachine001(xPubKey, xSig(sign(ci, []byte("build-1233")), sign(maintainer, msg)), msg))
+	// attestation by the maintainer instead of CI
+	assert.False(t, RunMachine001(xPubKey, xSig(sign(maintainer, []byte("build-1234")), sign(maintainer, msg)), msg))
+	// no release signature
+	assert.False(t, RunMachine001(xPubKey, xSig(sign(ci, []byte("build-1234")), sign(ci, msg)), msg))
+}
+
+func TestRunMachine001_OracleAttestationWithDomain(t *testing.T) {
+	// CI signs "ci-passed:" || build id; the policy assembles that message
+	// itself from the release message, so the xsig only supplies signatures
+	const tagBuild = 1
+	msg, _ := ll.EncodeMessage([]ll.MessageField{{Tag: tagBuild, Value: []byte("build-1234")}})
+	ci, ciPK, _ := crypto.HelperVerifyData(nil)
+	sign := func(m []byte) []byte {
+		hash := sha256.Sum256(m)
+		sig, err := ecdsa.SignASN1(rand.Reader, ci, hash[:])
+		assert.Nil(t, err)
+		return sig
+	}
+
+	b := MachineCode{}
+	b.Append(ll.PushBlob([]byte("ci-passed:")))
+	b.Append(ll.Push1(tagBuild))
+	b.Append(ll.MsgField())
+	b.Append(ll.Cat())
+	b.Append(ll.Push(ciPK))
+	b.Append(ll.CheckSigFromStack())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(sig []byte) []byte {
+		a := MachineCode{}
+		a.Append(ll.Push(sig))
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(sign([]byte("ci-passed:build-1234"))), msg))
+	// an attestation from another domain does not count
+	assert.False(t, RunMachine001(xPubKey, xSig(sign([]byte("ci-failed:build-1234"))), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(sign([]byte("build-1234"))), msg))
+}
+
+func TestRunMachine001_RevokedSigner(t *testing.T) {
+	// 2-of-3; one signer loses their laptop and the key is revoked
+	msg := []byte("release 2.0")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk3))
+	b.Append(ll.Push(pk2))
+	b.Append(ll.Push(pk1))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.MultisigVerify())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(sigs ...[]byte) []byte {
+		a := MachineCode{}
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	revoked := ll.RevocationSet{crypto.KeyFingerprint(pk1): true}
+	ctx := &ll.Context{Xmsg: msg, Revoked: revoked}
+	assert.True(t, RunMachine001(xPubKey, xSig(sig1, sig2), msg))
+	assert.False(t, RunMachine001WithContext(xPubKey, xSig(sig1, sig2), ctx))
+	assert.True(t, RunMachine001WithContext(xPubKey, xSig(sig2, sig3), ctx))
+}
+
+func TestRunMachine001_NonceChallenge(t *testing.T) {
+	// debug unlock: the device issues a nonce, the token must sign over it
+	const tagCommand, tagNonce = 1, 2
+	nonce := []byte("0123456789abcdef")
+	msg, _ := ll.EncodeMessage([]ll.MessageField{
+		{Tag: tagCommand, Value: []byte("unlock debug port")},
+		{Tag: tagNonce, Value: nonce},
+	})
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.Push1(tagNonce))
+	b.Append(ll.CheckNonce())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	a := MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+
+	assert.True(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{Xmsg: msg, Nonce: nonce}))
+	// the same token against the next challenge
+	assert.False(t, RunMachine001WithContext(xPubKey, xSig, &ll.Context{Xmsg: msg, Nonce: []byte("fedcba9876543210")}))
+	assert.False(t, RunMachine001(xPubKey, xSig, msg))
+}
+
+func TestRunMachine001_PKHashMultisig(t *testing.T) {
+	// 2-of-3 committing to 20-byte key hashes; the xsig reveals all keys
+	msg := []byte("release 3.0")
+	var pks, sigs, hashes [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, sig := crypto.HelperVerifyData(msg)
+		h, err := crypto.PublicKeyHash(pk, 20)
+		assert.Nil(t, err)
+		pks, sigs, hashes = append(pks, pk), append(sigs, sig), append(hashes, h)
+	}
+
+	b := MachineCode{}
+	for _, in := range ll.CheckPKHashes(hashes) {
+		b.Append(in)
+	}
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.MultisigVerify())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(keys [][]byte, sigs ...[]byte) []byte {
+		a := MachineCode{}
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		for i := len(keys) - 1; i >= 0; i-- {
+			a.Append(ll.Push(keys[i]))
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(pks, sigs[0], sigs[2]), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(pks, sigs[0]), msg))
+	// a key set other than the committed one
+	_, outsider, outsiderSig := crypto.HelperVerifyData(msg)
+	assert.False(t, RunMachine001(xPubKey, xSig([][]byte{pks[0], pks[1], outsider}, sigs[0], outsiderSig), msg))
+}
+
+func TestRunMachine001_RawSignature(t *testing.T) {
+	msg := []byte("bootloader image")
+	_, pk, der := crypto.HelperVerifyData(msg)
+	raw, err := crypto.RawSignature(der)
+	assert.Nil(t, err)
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerifyRaw())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	a := MachineCode{}
+	a.Append(ll.Push(raw))
+	xSig := a.SerializeUnchecked(CodeTypeXSig)
+
+	// header, OP_PUSH, length, r || s: the size never varies
+	assert.Len(t, xSig, 6+2+64)
+	assert.True(t, RunMachine001(xPubKey, xSig, msg))
+	assert.False(t, RunMachine001(xPubKey, xSig, []byte("other image")))
+
+	// a DER signature is not accepted by the raw opcode
+	c := MachineCode{}
+	c.Append(ll.Push(der))
+	assert.False(t, RunMachine001(xPubKey, c.SerializeUnchecked(CodeTypeXSig), msg))
+}
+
+func TestRunMachine001_MixedCurveQuorum(t *testing.T) {
+	// 2-of-3: an HSM (P-256), a hardware wallet doing ECDSA and one doing
+	// BIP-340, all on secp256k1
+	msg := []byte("treasury transfer #42")
+	_, p256PK, p256Sig := crypto.HelperVerifyData(msg)
+	_, k1PK, k1Sig := crypto.HelperVerifyDataSecp256k1(msg)
+	_, schnorrPK, schnorrSig := crypto.HelperVerifyDataSchnorr(msg)
+
+	b := MachineCode{}
+	for _, in := range ll.MixedMultisig(2, []ll.TypedPublicKey{
+		{Type: crypto.KeyTypeP256, PublicKey: p256PK},
+		{Type: crypto.KeyTypeSecp256k1, PublicKey: k1PK},
+		{Type: crypto.KeyTypeSchnorr, PublicKey: schnorrPK},
+	}) {
+		b.Append(in)
+	}
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(sigs ...[]byte) []byte {
+		a := MachineCode{}
+		for _, in := range ll.PushSignatureSlots(sigs) {
+			a.Append(in)
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(p256Sig, k1Sig, nil), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(nil, k1Sig, schnorrSig), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(p256Sig, nil, schnorrSig), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(p256Sig, nil, nil), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(p256Sig, k1Sig, nil), []byte("treasury transfer #43")))
+}
+
+func TestRunMachine001_PostQuantumHybrid(t *testing.T) {
+	// ECDSA 2-of-3 AND SLH-DSA 1-of-2: the ECDSA quorum signs day to day,
+	// and one of two offline SLH-DSA keys must countersign
+	msg := []byte("firmware v7")
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, sig2 := crypto.HelperVerifyData(msg)
+	_, pk3, _ := crypto.HelperVerifyData(msg)
+	_, pqA, pqSigA := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128f, msg)
+	_, pqB, _ := crypto.HelperVerifyDataSLHDSA(crypto.SLHDSASHA2128s, msg)
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk1))
+	b.Append(ll.Push(pk2))
+	b.Append(ll.Push(pk3))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.MultisigVerify())
+	for _, in := range ll.SLHDSASigVerify(0, crypto.SLHDSASHA2128f, pqA) {
+		b.Append(in)
+	}
+	for _, in := range ll.SLHDSASigVerify(1, crypto.SLHDSASHA2128s, pqB) {
+		b.Append(in)
+	}
+	b.Append(ll.Or())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	// one object per SLH-DSA key, empty if that key did not sign
+	xSig := func(pqSigA, pqSigB []byte, sigs ...[]byte) []byte {
+		a := MachineCode{}
+		assert.Nil(t, a.Append(ll.PushLarge(pqSigA)))
+		assert.Nil(t, a.Append(ll.PushLarge(pqSigB)))
+		for _, sig := range sigs {
+			a.Append(ll.Push(sig))
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(pqSigA, nil, sig1, sig2), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(nil, nil, sig1, sig2), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(pqSigA, nil, sig1, sig1), msg))
+	// A's signature in B's slot
+	assert.False(t, RunMachine001(xPubKey, xSig(nil, pqSigA, sig1, sig2), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(pqSigA, nil, sig1, sig2), []byte("firmware v8")))
+}
+
+func TestRunMachine001_RSAQuorum(t *testing.T) {
+	// 2-of-3: a legacy HSM with an RSA-3072 key, a P-256 key and a
+	// secp256k1 hardware wallet. The RSA key travels in the xsig and the
+	// xpubkey only commits to it.
+	msg := []byte("release 2.4.1")
+	hsm, rsaPK, rsaSig := crypto.HelperVerifyDataRSA(3072, crypto.RSAPSS, msg)
+	_, p256PK, p256Sig := crypto.HelperVerifyData(msg)
+	_, k1PK, k1Sig := crypto.HelperVerifyDataSecp256k1(msg)
+
+	b := MachineCode{}
+	for _, in := range ll.RSASigVerify(0, 1, crypto.RSAPSS, rsaPK) {
+		b.Append(in)
+	}
+	b.Append(ll.ToAltStack())
+	for _, key := range []ll.TypedPublicKey{
+		{Type: crypto.KeyTypeP256, PublicKey: p256PK},
+		{Type: crypto.KeyTypeSecp256k1, PublicKey: k1PK},
+	} {
+		for _, in := range ll.MixedMultisig(1, []ll.TypedPublicKey{key}) {
+			b.Append(in)
+		}
+		b.Append(ll.ToAltStack())
+	}
+	b.Append(ll.FromAltStack())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.Threshold())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+	// the whole policy is smaller than the RSA key alone
+	assert.Less(t, len(xPubKey), len(rsaPK))
+
+	xSig := func(rsaSig []byte, p256Sig []byte, k1Sig []byte) []byte {
+		a := MachineCode{}
+		assert.Nil(t, a.Append(ll.PushLarge(rsaSig)))
+		assert.Nil(t, a.Append(ll.PushLarge(rsaPK)))
+		a.Append(ll.PushBlob(k1Sig))
+		a.Append(ll.PushBlob(p256Sig))
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(rsaSig, p256Sig, nil), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(rsaSig, nil, k1Sig), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(nil, p256Sig, k1Sig), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(rsaSig, nil, nil), msg))
+	// PKCS#1 v1.5 where the policy expects PSS
+	assert.False(t, RunMachine001(xPubKey, xSig(crypto.SignRSA(crypto.RSAPKCS1v15, hsm, msg), p256Sig, nil), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(rsaSig, p256Sig, nil), []byte("release 2.4.2")))
+}
+
+func TestRunMachine001_WebAuthn(t *testing.T) {
+	// a release is approved with a tap on a security key: PIN-verified
+	// assertions from 2 of 3 engineers
+	msg := []byte("release 2.5.0")
+	var pks, sigs, authData, clientData [][]byte
+	for i := 0; i < 3; i++ {
+		_, pk, a, c, sig := crypto.HelperVerifyDataWebAuthn("releases.example.com", msg)
+		pks = append(pks, pk)
+		sigs = append(sigs, sig)
+		authData = append(authData, a)
+		clientData = append(clientData, c)
+	}
+
+	policy := func() []byte {
+		b := MachineCode{}
+		for i := range pks {
+			for _, in := range ll.WebAuthnSigVerify(2*i, 2*i+1, pks[i], "releases.example.com", crypto.WebAuthnUserVerified) {
+				b.Append(in)
+			}
+			b.Append(ll.ToAltStack())
+		}
+		b.Append(ll.FromAltStack())
+		b.Append(ll.FromAltStack())
+		b.Append(ll.FromAltStack())
+		b.Append(ll.Push1(2))
+		b.Append(ll.Push1(3))
+		b.Append(ll.Threshold())
+		return b.SerializeUnchecked(CodeTypeXPublicKey)
+	}
+	xPubKey := policy()
+
+	// engineers who did not approve leave empty objects and any signature
+	xSig := func(signed ...bool) []byte {
+		a := MachineCode{}
+		for i := 2; i >= 0; i-- {
+			a.Append(ll.Push(sigs[i]))
+		}
+		for i := range signed {
+			if signed[i] {
+				assert.Nil(t, a.Append(ll.PushLarge(authData[i])))
+				assert.Nil(t, a.Append(ll.PushLarge(clientData[i])))
+			} else {
+				assert.Nil(t, a.Append(ll.PushLarge(nil)))
+				assert.Nil(t, a.Append(ll.PushLarge(nil)))
+			}
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(true, true, false), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(false, true, true), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(false, true, false), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(true, true, true), []byte("release 2.5.1")))
+
+	// a tap without the PIN is not enough
+	privateKey, pk, _, c, _ := crypto.HelperVerifyDataWebAuthn("releases.example.com", msg)
+	presentOnly := crypto.WebAuthnAuthData("releases.example.com", crypto.WebAuthnUserPresent, 1)
+	pks[1], clientData[1], authData[1] = pk, c, presentOnly
+	sigs[1] = crypto.SignWebAuthn(privateKey, presentOnly, c)
+	xPubKey = policy()
+	assert.False(t, RunMachine001(xPubKey, xSig(true, true, false), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(true, false, true), msg))
+}
+
+func TestRunMachine001_SSHSig(t *testing.T) {
+	// 2-of-3 developers with the keys already in their ssh-agent, imported
+	// from their .pub files
+	msg := []byte("merge 5f2e1c0")
+	var keys []stdcrypto.Signer
+	var pks [][]byte
+	for _, keyType := range []string{crypto.SSHKeyTypeEd25519, crypto.SSHKeyTypeECDSAP256, crypto.SSHKeyTypeEd25519} {
+		key, pk, _ := crypto.HelperVerifyDataSSH(keyType, []byte("xsig"), nil)
+		line := keyType + " " + base64.StdEncoding.EncodeToString(pk) + " dev@example.com\n"
+		imported, err := crypto.ParseSSHAuthorizedKey([]byte(line))
+		assert.Nil(t, err)
+		keys = append(keys, key)
+		pks = append(pks, imported)
+	}
+
+	b := MachineCode{}
+	for i := range pks {
+		for _, in := range ll.SSHSigVerify(i, pks[i], "xsig") {
+			b.Append(in)
+		}
+	}
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.Threshold())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(namespace string, signers ...int) []byte {
+		sigs := make([][]byte, len(keys))
+		for _, i := range signers {
+			sigs[i] = crypto.SignSSHSig(keys[i], []byte(namespace), "sha512", msg)
+		}
+		a := MachineCode{}
+		for _, sig := range sigs {
+			assert.Nil(t, a.Append(ll.PushLarge(sig)))
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig("xsig", 0, 1), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig("xsig", 1, 2), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig("xsig", 2), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig("xsig", 0, 1), []byte("merge 5f2e1c1")))
+	// a signature made for another purpose, e.g. a git commit
+	assert.False(t, RunMachine001(xPubKey, xSig("git", 0, 1), msg))
+}
+
+func TestRunMachine001_Ethereum(t *testing.T) {
+	// 2-of-3 approval board of Ethereum wallets; the second one signs
+	// EIP-712 typed data, the others personal_sign
+	msg := []byte("pay invoice 2031")
+	domain := crypto.EIP712DomainSeparator("xsig approvals", "1", 1, nil)
+	modes := []byte{crypto.EthPersonalSign, crypto.EthTypedData, crypto.EthPersonalSign}
+	var keys []*big.Int
+	var addresses [][]byte
+	for _, mode := range modes {
+		key, address, _ := crypto.HelperVerifyDataEthereum(mode, domain, nil)
+		keys = append(keys, key)
+		addresses = append(addresses, address)
+	}
+
+	b := MachineCode{}
+	for i := range addresses {
+		for _, in := range ll.EthSigVerify(modes[i], domain, addresses[i]) {
+			b.Append(in)
+		}
+		b.Append(ll.ToAltStack())
+	}
+	b.Append(ll.FromAltStack())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.Threshold())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	// signatures in reverse, as the last check pops first; non-signers
+	// send 65 zero bytes
+	xSig := func(signers ...int) []byte {
+		sigs := make([][]byte, len(keys))
+		for i := range sigs {
+			sigs[i] = make([]byte, crypto.EthSignatureSize)
+		}
+		for _, i := range signers {
+			hash := crypto.EthPersonalSignHash(msg)
+			if modes[i] == crypto.EthTypedData {
+				hash = crypto.EthTypedDataHash(domain, msg)
+			}
+			sigs[i] = crypto.SignEthereum(keys[i], hash)
+		}
+		a := MachineCode{}
+		for i := len(sigs) - 1; i >= 0; i-- {
+			assert.Nil(t, a.Append(ll.Push(sigs[i])))
+		}
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(0, 1), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(1, 2), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(1), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(0, 1), []byte("pay invoice 2032")))
+}
+
+func TestRunMachine001_FROST(t *testing.T) {
+	// release signing: any 3 of 5 maintainers, as one FROST group key, and
+	// the CI key
+	msg := []byte("release 3.1.0")
+	keys := crypto.HelperFROSTGroup(3, 5)
+	ciKey, ciPK, _ := crypto.HelperVerifyData(nil)
+
+	b := MachineCode{}
+	b.Append(ll.Push(keys[0].GroupPublicKey))
+	b.Append(ll.SignatureVerifyEd25519())
+	b.Append(ll.ToAltStack())
+	b.Append(ll.Push(ciPK))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(CodeTypeXPublicKey)
+
+	xSig := func(msg []byte, signers ...int) []byte {
+		var group []*crypto.FROSTKeyShare
+		for _, i := range signers {
+			group = append(group, keys[i])
+		}
+		hash := sha256.Sum256(msg)
+		ciSig, err := ecdsa.SignASN1(rand.Reader, ciKey, hash[:])
+		assert.Nil(t, err)
+		a := MachineCode{}
+		assert.Nil(t, a.Append(ll.Push(ciSig)))
+		assert.Nil(t, a.Append(ll.Push(crypto.SignFROST(group, msg))))
+		return a.SerializeUnchecked(CodeTypeXSig)
+	}
+
+	assert.True(t, RunMachine001(xPubKey, xSig(msg, 0, 1, 2), msg))
+	assert.True(t, RunMachine001(xPubKey, xSig(msg, 4, 2, 3, 1), msg))
+	assert.False(t, RunMachine001(xPubKey, xSig(msg, 0, 1, 2), []byte("release 3.1.1")))
+	assert.False(t, RunMachine001(xPubKey, xSig([]byte("release 3.1.1"), 0, 1, 2), msg))
+}
@@ -33,7 +33,37 @@ func prefix(codeType CodeType) []byte {
 	return x
 }
 
-func (m *MachineCode) Serialize(codeType CodeType) []byte {
+// Check runs lowlevel.CheckCode on the code built so far, e.g. to catch a
+// hand-assembled multisig that lists the same key twice before it is
+// serialized and handed out.
+func (m *MachineCode) Check() error {
+	return lowlevel.CheckCode(m.Code)
+}
+
+// DigestAlgorithms returns the message digests an evaluation of the code
+// may ask for, see lowlevel.DigestAlgorithms.
+func (m *MachineCode) DigestAlgorithms() ([]byte, error) {
+	return lowlevel.DigestAlgorithms(m.Code)
+}
+
+// HasOpcode reports whether the code has an instruction with the given
+// opcode, see lowlevel.HasOpcode.
+func (m *MachineCode) HasOpcode(opcode byte) (bool, error) {
+	return lowlevel.HasOpcode(m.Code, opcode)
+}
+
+// Serialize runs Check and, if the code passes, returns it with the prefix
+// of codeType, ready to be handed out as an xpubkey or xsig.
+func (m *MachineCode) Serialize(codeType CodeType) ([]byte, error) {
+	if err := m.Check(); err != nil {
+		return nil, errors.Wrapf(err, "check")
+	}
+	return m.SerializeUnchecked(codeType), nil
+}
+
+// SerializeUnchecked is Serialize without Check, for code that is meant to
+// be rejected, e.g. test vectors.
+func (m *MachineCode) SerializeUnchecked(codeType CodeType) []byte {
 	return append(prefix(codeType), m.Code...)
 }
 
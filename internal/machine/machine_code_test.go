@@ -1,19 +1,77 @@
 package machines
 
 import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestSerialize(t *testing.T) {
 	a := MachineCode{}
-	xSig := a.Serialize(CodeTypeXSig)
+	xSig, err := a.Serialize(CodeTypeXSig)
+	assert.NoError(t, err)
 
 	b := MachineCode{}
-	err := b.Deserialize(xSig, CodeTypeXPublicKey)
+	err = b.Deserialize(xSig, CodeTypeXPublicKey)
 	assert.Error(t, err)
 
 	c := MachineCode{}
 	err = c.Deserialize(xSig, CodeTypeXSig)
 	assert.NoError(t, err)
 }
+
+func TestMachineCode_Check(t *testing.T) {
+	_, pk1, _ := crypto.HelperVerifyData(nil)
+	_, pk2, _ := crypto.HelperVerifyData(nil)
+
+	a := MachineCode{}
+	a.Append(ll.Push(pk1))
+	a.Append(ll.Push(pk2))
+	a.Append(ll.Push(pk1))
+	a.Append(ll.Push1(2))
+	a.Append(ll.Push1(3))
+	a.Append(ll.MultisigVerify())
+	assert.Error(t, a.Check())
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk1))
+	b.Append(ll.Push(pk2))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(2))
+	b.Append(ll.StrictMultisigVerify())
+	assert.NoError(t, b.Check())
+}
+
+func TestMachineCode_Serialize(t *testing.T) {
+	_, pk1, _ := crypto.HelperVerifyData(nil)
+	_, pk2, _ := crypto.HelperVerifyData(nil)
+
+	a := MachineCode{}
+	a.Append(ll.Push(pk1))
+	a.Append(ll.PushNum(1))
+	a.Append(ll.Push(pk1))
+	a.Append(ll.PushNum(1))
+	a.Append(ll.PushNum(2))
+	a.Append(ll.Push1(2))
+	a.Append(ll.WeightedSigVerify())
+	x, err := a.Serialize(CodeTypeXPublicKey)
+	assert.Error(t, err)
+	assert.Nil(t, x)
+
+	b := MachineCode{}
+	b.Append(ll.Push(pk1))
+	b.Append(ll.Push(pk2))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(2))
+	b.Append(ll.MultisigVerify())
+	x, err = b.Serialize(CodeTypeXPublicKey)
+	assert.NoError(t, err)
+	assert.Equal(t, b.SerializeUnchecked(CodeTypeXPublicKey), x)
+
+	// malformed code: a truncated OP_PUSH
+	c := MachineCode{}
+	c.Code = []byte{ll.OP_PUSH, 2, 1}
+	_, err = c.Serialize(CodeTypeXSig)
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"github.com/oreparaz/xsig/internal/crypto"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ParseEthereumAddress decodes a "0x..." wallet address into the 20 bytes
+// OP_ETHVERIFY policies push, checking its EIP-55 checksum if it has one.
+func ParseEthereumAddress(s string) ([]byte, error) {
+	return crypto.ParseEthAddress(s)
+}
+
+// ParseEthereumSignature decodes the "0x..." r || s || v signature returned
+// by personal_sign or eth_signTypedData_v4.
+func ParseEthereumSignature(s string) ([]byte, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Ethereum signature")
+	}
+	if len(sig) != crypto.EthSignatureSize {
+		return nil, errors.Errorf("Ethereum signature is %d bytes, want %d", len(sig), crypto.EthSignatureSize)
+	}
+	return sig, nil
+}
+
+// EIP712DomainSeparator returns the domain separator OP_ETHVERIFY policies
+// push for typed data signed in the EIP712Domain {name, version, chainId}.
+// Wallets are asked to sign primaryType XsigMessage, defined as
+// [{name: "message", type: "bytes"}], with the message as its value.
+func EIP712DomainSeparator(name string, version string, chainID uint64) []byte {
+	return crypto.EIP712DomainSeparator(name, version, chainID, nil)
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"encoding/hex"
+	"github.com/oreparaz/xsig/internal/crypto"
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEthereumSig(t *testing.T) {
+	// web3.eth.accounts.sign("Some data", key) from the web3.js documentation
+	msg := []byte("Some data")
+	address, err := ParseEthereumAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65c23")
+	assert.Nil(t, err)
+	sig, err := ParseEthereumSignature("0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd" +
+		"6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c")
+	assert.Nil(t, err)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+	b := machines.MachineCode{}
+	for _, in := range ll.EthSigVerify(crypto.EthPersonalSign, nil, address) {
+		b.Append(in)
+	}
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
+	assert.False(t, EvaluateXSig(xPubKey, xSig, []byte("wrong")))
+
+	// typed data in a domain of our own
+	domain := EIP712DomainSeparator("xsig", "1", 1)
+	_, address, rawSig := crypto.HelperVerifyDataEthereum(crypto.EthTypedData, domain, msg)
+	sig, err = ParseEthereumSignature("0x" + hex.EncodeToString(rawSig))
+	assert.Nil(t, err)
+	a = machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	b = machines.MachineCode{}
+	for _, in := range ll.EthSigVerify(crypto.EthTypedData, domain, address) {
+		b.Append(in)
+	}
+	assert.True(t, EvaluateXSig(b.SerializeUnchecked(machines.CodeTypeXPublicKey), a.SerializeUnchecked(machines.CodeTypeXSig), msg))
+
+	_, err = ParseEthereumSignature("0x1234")
+	assert.NotNil(t, err)
+	_, err = ParseEthereumAddress("0x2c7536E3605D9C16a7a3D7b1898e529396a65C23")
+	assert.NotNil(t, err)
+}
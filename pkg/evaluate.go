@@ -1,7 +1,37 @@
 package pkg
 
-import machines "github.com/oreparaz/xsig/internal/machine"
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	"github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+)
+
+// Context carries verifier-supplied state into an evaluation, see
+// lowlevel.Context.
+type Context = lowlevel.Context
+
+// Message digests OP_SIGVERIFYHASH can check signatures over.
+const (
+	HashSHA256   = crypto.HashSHA256
+	HashSHA384   = crypto.HashSHA384
+	HashSHA512   = crypto.HashSHA512
+	HashSHA3_256 = crypto.HashSHA3_256
+)
 
 func EvaluateXSig(XpPubKey []byte, XpSig []byte, XpMsg []byte) bool {
 	return machines.RunMachine001(XpPubKey, XpSig, XpMsg)
 }
+
+func EvaluateXSigWithContext(XpPubKey []byte, XpSig []byte, ctx *Context) bool {
+	return machines.RunMachine001WithContext(XpPubKey, XpSig, ctx)
+}
+
+// EvaluateXSigDigest evaluates in prehashed mode, for a verifier that only
+// has the hashAlg digest of the message (see Context.Digests): only
+// policies whose signature checks all use hashAlg can succeed. Most
+// signature opcodes sign SHA-256 of the message; OP_SIGVERIFYHASH names its
+// algorithm. See EvaluateXSigReader to hash a message with all the
+// algorithms a policy needs.
+func EvaluateXSigDigest(XpPubKey []byte, XpSig []byte, hashAlg byte, digest []byte) bool {
+	return machines.RunMachine001WithContext(XpPubKey, XpSig, &Context{Digests: map[byte][]byte{hashAlg: digest}})
+}
@@ -14,17 +14,121 @@ func TestEvaluateXSig(t *testing.T) {
 
 	a := machines.MachineCode{}
 	a.Append(ll.Push(sig))
-	xSig := a.Serialize(machines.CodeTypeXSig)
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
 
 	b := machines.MachineCode{}
 	b.Append(ll.Push(pk))
 	b.Append(ll.SignatureVerify())
-	xPubKey := b.Serialize(machines.CodeTypeXPublicKey)
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
 
 	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
 	assert.False(t, EvaluateXSig(xPubKey, xSig, []byte("wrong")))
 }
 
+func TestEvaluateXSigDigest(t *testing.T) {
+	// an HSM that only signs SHA-384 digests, and a verifier that only
+	// gets the digest
+	msg := []byte("artifact 1.4.2")
+	pk, sig := crypto.HelperVerifyDataDigest(crypto.KeyTypeP384, HashSHA384, msg)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	b := machines.MachineCode{}
+	for _, in := range ll.SigVerifyHash(crypto.KeyTypeP384, HashSHA384, pk) {
+		b.Append(in)
+	}
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	digest := crypto.Digest(HashSHA384, msg)
+	assert.True(t, EvaluateXSigDigest(xPubKey, xSig, HashSHA384, digest))
+	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
+	assert.False(t, EvaluateXSigDigest(xPubKey, xSig, HashSHA384, crypto.Digest(HashSHA384, []byte("artifact 1.4.3"))))
+	assert.False(t, EvaluateXSigDigest(xPubKey, xSig, HashSHA512, crypto.Digest(HashSHA512, msg)))
+	assert.False(t, EvaluateXSigDigest(xPubKey, xSig, HashSHA384, digest[:32]))
+}
+
 func TestEvaluateXSig_Invalid(t *testing.T) {
 	assert.False(t, EvaluateXSig(nil, nil, nil))
 }
+
+func TestEvaluateXSigWithContext_SecurityVersion(t *testing.T) {
+	// the image version is a field of the signed message, so the xsig
+	// cannot claim another one
+	const tagVersion, tagImage = 1, 2
+	image := func(version int64) []byte {
+		msg, _ := ll.EncodeMessage([]ll.MessageField{
+			{Tag: tagVersion, Value: ll.EncodeNum(version)},
+			{Tag: tagImage, Value: []byte("firmware")},
+		})
+		return msg
+	}
+	msg := image(7)
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.Push1(tagVersion))
+	b.Append(ll.MsgField())
+	b.Append(ll.CheckSecurityVersion())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	assert.True(t, EvaluateXSigWithContext(xPubKey, xSig, &Context{Xmsg: msg, SecurityVersion: 6}))
+	assert.True(t, EvaluateXSigWithContext(xPubKey, xSig, &Context{Xmsg: msg, SecurityVersion: 7}))
+	assert.False(t, EvaluateXSigWithContext(xPubKey, xSig, &Context{Xmsg: msg, SecurityVersion: 8}))
+	assert.False(t, EvaluateXSigWithContext(xPubKey, xSig, &Context{Xmsg: []byte("wrong"), SecurityVersion: 6}))
+}
+
+func TestEvaluateXSigWithContext_SecurityVersionLie(t *testing.T) {
+	// an old image, signed as version 7, replayed to a device at version 8
+	const tagVersion, tagImage = 1, 2
+	image := func(version int64) []byte {
+		msg, _ := ll.EncodeMessage([]ll.MessageField{
+			{Tag: tagVersion, Value: ll.EncodeNum(version)},
+			{Tag: tagImage, Value: []byte("firmware")},
+		})
+		return msg
+	}
+	msg := image(7)
+	_, pk, sig := crypto.HelperVerifyData(msg)
+	ctx := &Context{Xmsg: msg, SecurityVersion: 8}
+
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.Push1(tagVersion))
+	b.Append(ll.MsgField())
+	b.Append(ll.CheckSecurityVersion())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	// the xsig pushes a version of its own: it is left on the stack
+	a := machines.MachineCode{}
+	a.Append(ll.PushNum(9))
+	a.Append(ll.Push(sig))
+	lie := a.SerializeUnchecked(machines.CodeTypeXSig)
+	assert.False(t, EvaluateXSigWithContext(xPubKey, lie, ctx))
+
+	// the message claims a version of its own: the signature does not cover it
+	a = machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+	assert.False(t, EvaluateXSigWithContext(xPubKey, xSig, &Context{Xmsg: image(9), SecurityVersion: 8}))
+
+	// whereas a policy checking a version pushed by the xsig takes the lie
+	c := machines.MachineCode{}
+	c.Append(ll.Push(pk))
+	c.Append(ll.SignatureVerify())
+	c.Append(ll.ToAltStack())
+	c.Append(ll.CheckSecurityVersion())
+	c.Append(ll.FromAltStack())
+	c.Append(ll.And())
+	assert.True(t, EvaluateXSigWithContext(c.SerializeUnchecked(machines.CodeTypeXPublicKey), lie, ctx))
+}
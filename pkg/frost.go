@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"github.com/oreparaz/xsig/internal/crypto"
+)
+
+// FROST threshold signing (RFC 9591, Ed25519 with SHA-512). A group of n
+// participants runs a distributed key generation once; afterwards any t of
+// them can sign together, in two rounds, and the result is an ordinary
+// Ed25519 signature under the group public key. The xpubkey only holds that
+// one key:
+//
+//	PUSH(groupPublicKey) OP_SIGVERIFYED25519
+//
+// so the policy is as small and as quick to check as a single signer's,
+// and does not reveal who signed or how many could have.
+//
+// Key generation, for participants 1 to n:
+//
+//	secret, round1, _ := FROSTDKGStart(id, t, n)  // broadcast round1
+//	shares, _ := FROSTDKGShares(secret, all)      // send shares[j] to j, privately
+//	key, _ := FROSTDKGFinish(secret, all, received)
+//
+// Signing, with a coordinator:
+//
+//	nonces, commitment, _ := FROSTCommit(key)     // send commitment
+//	share, _ := FROSTSign(key, nonces, msg, commitments)
+//	sig, _ := FROSTAggregate(key.FROSTPublicKeys, msg, commitments, shares)
+//
+// Nonces must never be reused: FROSTSign refuses to use them twice, and
+// they must not be persisted and restored.
+
+type FROSTKeyShare = crypto.FROSTKeyShare
+type FROSTPublicKeys = crypto.FROSTPublicKeys
+type FROSTDKGRound1 = crypto.FROSTDKGRound1
+type FROSTDKGSecret = crypto.FROSTDKGSecret
+type FROSTCommitment = crypto.FROSTCommitment
+type FROSTNonces = crypto.FROSTNonces
+
+// FROSTDKGStart begins key generation for participant identifier, from 1 to
+// maxSigners, of a group where any minSigners can sign. The FROSTDKGRound1
+// is broadcast to all other participants; the secret is kept.
+func FROSTDKGStart(identifier uint16, minSigners int, maxSigners int) (*FROSTDKGSecret, *FROSTDKGRound1, error) {
+	return crypto.FROSTDKGStart(identifier, minSigners, maxSigners, rand.Reader)
+}
+
+// FROSTDKGShares checks the round 1 packages of all participants and
+// returns the share to send to each other participant, by identifier, over
+// a confidential and authenticated channel.
+func FROSTDKGShares(secret *FROSTDKGSecret, round1 []*FROSTDKGRound1) (map[uint16][]byte, error) {
+	return crypto.FROSTDKGShares(secret, round1)
+}
+
+// FROSTDKGFinish checks the shares received from every other participant,
+// by sender, and returns this participant's key share.
+func FROSTDKGFinish(secret *FROSTDKGSecret, round1 []*FROSTDKGRound1, shares map[uint16][]byte) (*FROSTKeyShare, error) {
+	return crypto.FROSTDKGFinish(secret, round1, shares)
+}
+
+// FROSTCommit is a signer's first round: fresh nonces to keep for FROSTSign
+// and the commitment to send to the coordinator.
+func FROSTCommit(key *FROSTKeyShare) (*FROSTNonces, *FROSTCommitment, error) {
+	return crypto.FROSTCommit(key, rand.Reader)
+}
+
+// FROSTSign is a signer's second round: its share of the signature of msg,
+// given the commitments of all the signers.
+func FROSTSign(key *FROSTKeyShare, nonces *FROSTNonces, msg []byte, commitments []FROSTCommitment) ([]byte, error) {
+	return crypto.FROSTSign(key, nonces, msg, commitments)
+}
+
+// FROSTAggregate checks the signature shares, by signer, and combines them
+// into the Ed25519 signature for OP_SIGVERIFYED25519. An invalid share is
+// reported with the identifier of its signer.
+func FROSTAggregate(public *FROSTPublicKeys, msg []byte, commitments []FROSTCommitment, shares map[uint16][]byte) ([]byte, error) {
+	return crypto.FROSTAggregate(public, msg, commitments, shares)
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFROST(t *testing.T) {
+	// a 2-of-3 group, end to end through the public API
+	var secrets []*FROSTDKGSecret
+	var round1 []*FROSTDKGRound1
+	for id := uint16(1); id <= 3; id++ {
+		secret, pkg, err := FROSTDKGStart(id, 2, 3)
+		assert.Nil(t, err)
+		secrets, round1 = append(secrets, secret), append(round1, pkg)
+	}
+	received := map[uint16]map[uint16][]byte{1: {}, 2: {}, 3: {}}
+	for i, secret := range secrets {
+		shares, err := FROSTDKGShares(secret, round1)
+		assert.Nil(t, err)
+		for to, share := range shares {
+			received[to][uint16(i+1)] = share
+		}
+	}
+	var keys []*FROSTKeyShare
+	for i, secret := range secrets {
+		key, err := FROSTDKGFinish(secret, round1, received[uint16(i+1)])
+		assert.Nil(t, err)
+		keys = append(keys, key)
+	}
+
+	msg := []byte("deploy build 8812")
+	signers := []*FROSTKeyShare{keys[2], keys[0]}
+	var nonces []*FROSTNonces
+	var commitments []FROSTCommitment
+	for _, key := range signers {
+		n, c, err := FROSTCommit(key)
+		assert.Nil(t, err)
+		nonces, commitments = append(nonces, n), append(commitments, *c)
+	}
+	shares := make(map[uint16][]byte)
+	for i, key := range signers {
+		share, err := FROSTSign(key, nonces[i], msg, commitments)
+		assert.Nil(t, err)
+		shares[key.Identifier] = share
+	}
+	sig, err := FROSTAggregate(keys[1].FROSTPublicKeys, msg, commitments, shares)
+	assert.Nil(t, err)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+	b := machines.MachineCode{}
+	b.Append(ll.Push(keys[1].GroupPublicKey))
+	b.Append(ll.SignatureVerifyEd25519())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
+	assert.False(t, EvaluateXSig(xPubKey, xSig, []byte("deploy build 8813")))
+}
@@ -0,0 +1,17 @@
+package pkg
+
+import "github.com/oreparaz/xsig/internal/lowlevel"
+
+// MessageField is one field of a structured message, see
+// lowlevel.EncodeMessage for the layout policies read with OP_MSGFIELD.
+type MessageField = lowlevel.MessageField
+
+// EncodeMessage builds a structured message from fields sorted by tag.
+func EncodeMessage(fields []MessageField) ([]byte, error) {
+	return lowlevel.EncodeMessage(fields)
+}
+
+// ParseMessage splits a structured message into its fields.
+func ParseMessage(msg []byte) ([]MessageField, error) {
+	return lowlevel.ParseMessage(msg)
+}
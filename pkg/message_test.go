@@ -0,0 +1,18 @@
+package pkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncodeMessage(t *testing.T) {
+	fields := []MessageField{{Tag: 1, Value: []byte{0x42}}, {Tag: 2, Value: []byte("flash")}}
+	msg, err := EncodeMessage(fields)
+	assert.Nil(t, err)
+	parsed, err := ParseMessage(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, fields, parsed)
+
+	_, err = EncodeMessage([]MessageField{{Tag: 2}, {Tag: 1}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/pkg/errors"
+)
+
+// Single-use authorizations. The verifier issues a fresh nonce (NewNonce),
+// the signers sign a structured message with the nonce in one of its fields,
+// and the xpubkey checks it with PUSH(tag) OP_CHECKNONCE. The signature then
+// only holds for that nonce; EvaluateXSigSingleUse additionally records the
+// nonce in a NonceStore so the same xsig cannot be redeemed twice.
+
+// NonceSize is the size of the nonces returned by NewNonce.
+const NonceSize = 16
+
+// ErrNonceUsed is returned when a nonce has already been accepted once.
+var ErrNonceUsed = errors.New("nonce already used")
+
+// NonceStore remembers which nonces have been accepted.
+type NonceStore interface {
+	// Use marks nonce as used. It returns ErrNonceUsed if it already was,
+	// and must be atomic: of two concurrent calls with the same nonce at
+	// most one succeeds.
+	Use(nonce []byte) error
+}
+
+// NewNonce returns a random nonce to use as a challenge.
+func NewNonce() ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrapf(err, "nonce")
+	}
+	return nonce, nil
+}
+
+// EvaluateXSigSingleUse is EvaluateXSigWithContext for policies that check
+// ctx.Nonce. It returns true only the first time a valid xsig is presented
+// for a given nonce; a replay returns ErrNonceUsed. The nonce is only
+// consumed by a valid xsig, so a bad attempt does not burn the challenge.
+// It fails on an xpubkey without OP_CHECKNONCE, whose xsigs are not tied to
+// the nonce and would be accepted once per fresh nonce, and on an xsig that
+// is valid but steered the evaluation past every OP_CHECKNONCE (e.g. into
+// the other branch of an OP_IF): only a nonce that was checked is consumed.
+func EvaluateXSigSingleUse(XpPubKey []byte, XpSig []byte, ctx *Context, store NonceStore) (bool, error) {
+	if len(ctx.Nonce) == 0 {
+		return false, errors.New("single-use evaluation needs a nonce")
+	}
+	mc := machines.MachineCode{}
+	if err := mc.Deserialize(XpPubKey, machines.CodeTypeXPublicKey); err != nil {
+		return false, errors.Wrapf(err, "xpubkey")
+	}
+	checksNonce, err := mc.HasOpcode(lowlevel.OP_CHECKNONCE)
+	if err != nil {
+		return false, errors.Wrapf(err, "xpubkey")
+	}
+	if !checksNonce {
+		return false, errors.New("xpubkey does not check the nonce (no OP_CHECKNONCE)")
+	}
+	ok, nonceChecked := machines.RunMachine001CheckNonce(XpPubKey, XpSig, ctx)
+	if !ok {
+		return false, nil
+	}
+	if !nonceChecked {
+		return false, errors.New("xsig is valid without checking the nonce")
+	}
+	if err := store.Use(ctx.Nonce); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MemoryNonceStore is a NonceStore that forgets everything when the process
+// exits. It suits challenge-response protocols where nonces are issued by
+// the same process.
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	used map[string]bool
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{used: make(map[string]bool)}
+}
+
+func (s *MemoryNonceStore) Use(nonce []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[string(nonce)] {
+		return ErrNonceUsed
+	}
+	s.used[string(nonce)] = true
+	return nil
+}
+
+// FileNonceStore is a NonceStore that survives restarts. Used nonces are
+// appended, hex-encoded, one per line, to a file that is synced before Use
+// returns. A crash in the middle of a Use can leave a partial last line;
+// that Use did not succeed, so opening the store drops it. The file grows
+// without bound; rotate it together with the key that signs the tokens.
+type FileNonceStore struct {
+	mu   sync.Mutex
+	f    *os.File
+	used map[string]bool
+}
+
+// OpenFileNonceStore opens (or creates) the store at path.
+func OpenFileNonceStore(path string) (*FileNonceStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "nonce store")
+	}
+	s := &FileNonceStore{f: f, used: make(map[string]bool)}
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "nonce store %s", path)
+	}
+	return s, nil
+}
+
+// load reads the used nonces and truncates a partial last line.
+func (s *FileNonceStore) load() error {
+	r := bufio.NewReader(s.f)
+	var complete int64 // length of the complete lines read so far
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return s.f.Truncate(complete)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		nonce, err := hex.DecodeString(strings.TrimSuffix(line, "\n"))
+		if err != nil {
+			return err
+		}
+		s.used[string(nonce)] = true
+		complete += int64(len(line))
+	}
+}
+
+func (s *FileNonceStore) Use(nonce []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[string(nonce)] {
+		return ErrNonceUsed
+	}
+	if _, err := s.f.WriteString(hex.EncodeToString(nonce) + "\n"); err != nil {
+		return errors.Wrapf(err, "nonce store")
+	}
+	if err := s.f.Sync(); err != nil {
+		return errors.Wrapf(err, "nonce store")
+	}
+	s.used[string(nonce)] = true
+	return nil
+}
+
+func (s *FileNonceStore) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMemoryNonceStore(t *testing.T) {
+	s := NewMemoryNonceStore()
+	assert.Nil(t, s.Use([]byte{1}))
+	assert.Nil(t, s.Use([]byte{2}))
+	assert.Equal(t, ErrNonceUsed, s.Use([]byte{1}))
+}
+
+func TestMemoryNonceStore_Concurrent(t *testing.T) {
+	s := NewMemoryNonceStore()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Use([]byte("token")) == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, accepted)
+}
+
+func TestFileNonceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces")
+	s, err := OpenFileNonceStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Use([]byte{1, 2, 3}))
+	assert.Equal(t, ErrNonceUsed, s.Use([]byte{1, 2, 3}))
+	assert.Nil(t, s.Close())
+
+	// used nonces survive reopening
+	s, err = OpenFileNonceStore(path)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrNonceUsed, s.Use([]byte{1, 2, 3}))
+	assert.Nil(t, s.Use([]byte{4}))
+	assert.Nil(t, s.Close())
+}
+
+func TestFileNonceStore_TornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces")
+	s, err := OpenFileNonceStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, s.Use([]byte{1, 2, 3}))
+	assert.Nil(t, s.Close())
+
+	// a crash in the middle of writing nonce 040506
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.Nil(t, err)
+	_, err = f.WriteString("0405")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	s, err = OpenFileNonceStore(path)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrNonceUsed, s.Use([]byte{1, 2, 3}))
+	assert.Nil(t, s.Use([]byte{4, 5, 6}))
+	assert.Nil(t, s.Close())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "010203\n040506\n", string(data))
+
+	// a complete line that is not a nonce is not a torn write
+	assert.Nil(t, os.WriteFile(path, []byte("zz\n"), 0600))
+	_, err = OpenFileNonceStore(path)
+	assert.NotNil(t, err)
+}
+
+func TestEvaluateXSigSingleUse(t *testing.T) {
+	nonce, err := NewNonce()
+	assert.Nil(t, err)
+	assert.Len(t, nonce, NonceSize)
+	msg, _ := EncodeMessage([]MessageField{{Tag: 1, Value: []byte("unlock")}, {Tag: 2, Value: nonce}})
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.Push1(2))
+	b.Append(ll.CheckNonce())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	store := NewMemoryNonceStore()
+	ctx := &Context{Xmsg: msg, Nonce: nonce}
+
+	// a bad attempt does not consume the nonce
+	ok, err := EvaluateXSigSingleUse(xPubKey, xSig, &Context{Xmsg: []byte("other"), Nonce: nonce}, store)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+
+	ok, err = EvaluateXSigSingleUse(xPubKey, xSig, ctx, store)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+
+	ok, err = EvaluateXSigSingleUse(xPubKey, xSig, ctx, store)
+	assert.False(t, ok)
+	assert.Equal(t, ErrNonceUsed, err)
+
+	_, err = EvaluateXSigSingleUse(xPubKey, xSig, &Context{Xmsg: msg}, store)
+	assert.NotNil(t, err)
+}
+
+func TestEvaluateXSigSingleUse_NoCheckNonce(t *testing.T) {
+	msg := []byte("unlock")
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	// valid signatures, but nothing ties them to the nonce
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	store := NewMemoryNonceStore()
+	for _, nonce := range [][]byte{[]byte("nonce 1"), []byte("nonce 2")} {
+		ok, err := EvaluateXSigSingleUse(xPubKey, xSig, &Context{Xmsg: msg, Nonce: nonce}, store)
+		assert.False(t, ok)
+		assert.NotNil(t, err)
+	}
+
+	// an OP_CHECKNONCE byte inside a push does not count
+	b = machines.MachineCode{}
+	b.Append(ll.Push1(int(ll.OP_CHECKNONCE)))
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	ok, err := EvaluateXSigSingleUse(b.SerializeUnchecked(machines.CodeTypeXPublicKey), xSig,
+		&Context{Xmsg: msg, Nonce: []byte("nonce 3")}, store)
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+
+	_, err = EvaluateXSigSingleUse([]byte("garbage"), xSig, &Context{Xmsg: msg, Nonce: []byte("nonce 4")}, store)
+	assert.NotNil(t, err)
+}
+
+func TestEvaluateXSigSingleUse_NonceCheckNotTaken(t *testing.T) {
+	nonce, err := NewNonce()
+	assert.Nil(t, err)
+	msg, _ := EncodeMessage([]MessageField{{Tag: 1, Value: []byte("unlock")}, {Tag: 2, Value: nonce}})
+	_, pk, sig := crypto.HelperVerifyData(msg)
+
+	// the xsig picks the branch: IF (check the nonce) ELSE (push 1) ENDIF
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerify())
+	b.Append(ll.ToAltStack())
+	b.Append(ll.If())
+	b.Append(ll.Push1(2))
+	b.Append(ll.CheckNonce())
+	b.Append(ll.Else())
+	b.Append(ll.Push1(1))
+	b.Append(ll.EndIf())
+	b.Append(ll.FromAltStack())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	xSig := func(branch int) []byte {
+		a := machines.MachineCode{}
+		a.Append(ll.Push1(branch))
+		a.Append(ll.Push(sig))
+		return a.SerializeUnchecked(machines.CodeTypeXSig)
+	}
+
+	// valid either way, but the ELSE branch never looks at the nonce
+	ctx := &Context{Xmsg: msg, Nonce: nonce}
+	assert.True(t, EvaluateXSigWithContext(xPubKey, xSig(0), ctx))
+	store := NewMemoryNonceStore()
+	ok, err := EvaluateXSigSingleUse(xPubKey, xSig(0), ctx, store)
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+	ok, err = EvaluateXSigSingleUse(xPubKey, xSig(0), &Context{Xmsg: msg, Nonce: []byte("other")}, store)
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+
+	// the nonce was not consumed by the attempts above
+	ok, err = EvaluateXSigSingleUse(xPubKey, xSig(1), ctx, store)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	ok, err = EvaluateXSigSingleUse(xPubKey, xSig(1), ctx, store)
+	assert.False(t, ok)
+	assert.Equal(t, ErrNonceUsed, err)
+}
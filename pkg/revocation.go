@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/oreparaz/xsig/internal/crypto"
+	"github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/pkg/errors"
+)
+
+// Signed revocation lists. A revocation authority, itself described by an
+// xpubkey (e.g. 2-of-3 security officers), signs a structured message with
+// the fields
+//
+//	0: "xsig-revocation-list"
+//	1: sequence number, 8 bytes big-endian
+//	2: fingerprints of the revoked keys, 32 bytes each, concatenated
+//
+// Field 0 keeps a revocation list from being mistaken for any other message
+// the authority signs. Verifiers should only replace their list with one
+// carrying a higher sequence number, so that replaying an old list cannot
+// un-revoke a key.
+
+// RevocationSet is a set of revoked key fingerprints, see Context.Revoked.
+type RevocationSet = lowlevel.RevocationSet
+
+// KeyFingerprint returns the fingerprint that identifies publicKey in a
+// revocation list.
+func KeyFingerprint(publicKey []byte) [32]byte {
+	return crypto.KeyFingerprint(publicKey)
+}
+
+const (
+	revocationTagDomain   = 0
+	revocationTagSequence = 1
+	revocationTagRevoked  = 2
+)
+
+var revocationDomain = []byte("xsig-revocation-list")
+
+// MaxRevokedKeys is the most fingerprints a single list can carry.
+const MaxRevokedKeys = lowlevel.MaxMessageFieldSize / 32
+
+type RevocationList struct {
+	Sequence uint64
+	Revoked  [][32]byte
+}
+
+// Set returns the revoked fingerprints as a set for Context.Revoked.
+func (l *RevocationList) Set() RevocationSet {
+	set := make(RevocationSet, len(l.Revoked))
+	for _, fp := range l.Revoked {
+		set[fp] = true
+	}
+	return set
+}
+
+// EncodeRevocationList builds the message a revocation authority signs.
+func EncodeRevocationList(l *RevocationList) ([]byte, error) {
+	if len(l.Revoked) > MaxRevokedKeys {
+		return nil, errors.Errorf("too many revoked keys: %d (max %d)", len(l.Revoked), MaxRevokedKeys)
+	}
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], l.Sequence)
+	var revoked []byte
+	for _, fp := range l.Revoked {
+		revoked = append(revoked, fp[:]...)
+	}
+	return lowlevel.EncodeMessage([]MessageField{
+		{Tag: revocationTagDomain, Value: revocationDomain},
+		{Tag: revocationTagSequence, Value: seq[:]},
+		{Tag: revocationTagRevoked, Value: revoked},
+	})
+}
+
+// ParseRevocationList parses a revocation list message. It does not check
+// any signature, see VerifyRevocationList.
+func ParseRevocationList(msg []byte) (*RevocationList, error) {
+	fields, err := lowlevel.ParseMessage(msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "revocation list")
+	}
+	if len(fields) != 3 ||
+		fields[0].Tag != revocationTagDomain ||
+		fields[1].Tag != revocationTagSequence ||
+		fields[2].Tag != revocationTagRevoked {
+		return nil, errors.New("revocation list: unexpected fields")
+	}
+	if !bytes.Equal(fields[0].Value, revocationDomain) {
+		return nil, errors.New("revocation list: not a revocation list")
+	}
+	if len(fields[1].Value) != 8 {
+		return nil, errors.Errorf("revocation list: sequence is %d bytes, want 8", len(fields[1].Value))
+	}
+	revoked := fields[2].Value
+	if len(revoked)%32 != 0 {
+		return nil, errors.Errorf("revocation list: %d bytes of fingerprints is not a multiple of 32", len(revoked))
+	}
+
+	l := &RevocationList{Sequence: binary.BigEndian.Uint64(fields[1].Value)}
+	for i := 0; i < len(revoked); i += 32 {
+		var fp [32]byte
+		copy(fp[:], revoked[i:])
+		l.Revoked = append(l.Revoked, fp)
+	}
+	return l, nil
+}
+
+// VerifyRevocationList checks that xsig satisfies the authority's xpubkey
+// over msg and returns the parsed list. The authority's own policy is
+// evaluated without any revocations.
+func VerifyRevocationList(authorityXPubKey []byte, xsig []byte, msg []byte) (*RevocationList, error) {
+	l, err := ParseRevocationList(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !machines.RunMachine001(authorityXPubKey, xsig, msg) {
+		return nil, errors.New("revocation list: signature does not satisfy the authority's xpubkey")
+	}
+	return l, nil
+}
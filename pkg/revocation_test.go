@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRevocationList_RoundTrip(t *testing.T) {
+	_, pk1, _ := crypto.HelperVerifyData(nil)
+	_, pk2, _ := crypto.HelperVerifyData(nil)
+	l := &RevocationList{Sequence: 7, Revoked: [][32]byte{KeyFingerprint(pk1), KeyFingerprint(pk2)}}
+	msg, err := EncodeRevocationList(l)
+	assert.Nil(t, err)
+	parsed, err := ParseRevocationList(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, l, parsed)
+	assert.True(t, parsed.Set().Contains(pk1))
+
+	empty, err := EncodeRevocationList(&RevocationList{Sequence: 1})
+	assert.Nil(t, err)
+	parsed, err = ParseRevocationList(empty)
+	assert.Nil(t, err)
+	assert.Empty(t, parsed.Revoked)
+
+	_, err = EncodeRevocationList(&RevocationList{Revoked: make([][32]byte, MaxRevokedKeys+1)})
+	assert.NotNil(t, err)
+}
+
+func TestParseRevocationList_Errors(t *testing.T) {
+	seq := make([]byte, 8)
+	for _, fields := range [][]MessageField{
+		nil,
+		{{Tag: 0, Value: []byte("something-else")}, {Tag: 1, Value: seq}, {Tag: 2}},
+		{{Tag: 0, Value: revocationDomain}, {Tag: 1, Value: seq[:4]}, {Tag: 2}},
+		{{Tag: 0, Value: revocationDomain}, {Tag: 1, Value: seq}, {Tag: 2, Value: make([]byte, 33)}},
+		{{Tag: 0, Value: revocationDomain}, {Tag: 1, Value: seq}},
+		{{Tag: 0, Value: revocationDomain}, {Tag: 1, Value: seq}, {Tag: 2}, {Tag: 3}},
+	} {
+		msg, err := EncodeMessage(fields)
+		assert.Nil(t, err)
+		_, err = ParseRevocationList(msg)
+		assert.NotNil(t, err, "%v", fields)
+	}
+}
+
+func TestVerifyRevocationList(t *testing.T) {
+	_, lostPK, _ := crypto.HelperVerifyData(nil)
+	msg, err := EncodeRevocationList(&RevocationList{Sequence: 1, Revoked: [][32]byte{KeyFingerprint(lostPK)}})
+	assert.Nil(t, err)
+
+	_, authorityPK, sig := crypto.HelperVerifyData(msg)
+	b := machines.MachineCode{}
+	b.Append(ll.Push(authorityPK))
+	b.Append(ll.SignatureVerify())
+	authorityXPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	xSig := func(sig []byte) []byte {
+		a := machines.MachineCode{}
+		a.Append(ll.Push(sig))
+		return a.SerializeUnchecked(machines.CodeTypeXSig)
+	}
+
+	l, err := VerifyRevocationList(authorityXPubKey, xSig(sig), msg)
+	assert.Nil(t, err)
+	assert.True(t, l.Set().Contains(lostPK))
+
+	_, _, otherSig := crypto.HelperVerifyData(msg)
+	_, err = VerifyRevocationList(authorityXPubKey, xSig(otherSig), msg)
+	assert.NotNil(t, err)
+}
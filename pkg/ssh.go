@@ -0,0 +1,16 @@
+package pkg
+
+import "github.com/oreparaz/xsig/internal/crypto"
+
+// ParseSSHAuthorizedKey imports an OpenSSH public key, one line of
+// authorized_keys or a .pub file, into the form OP_SSHSIGVERIFY policies
+// push. ecdsa-sha2-nistp256 and ssh-ed25519 keys are supported.
+func ParseSSHAuthorizedKey(line []byte) ([]byte, error) {
+	return crypto.ParseSSHAuthorizedKey(line)
+}
+
+// ParseSSHSignature decodes a signature file written by ssh-keygen -Y sign
+// into the blob an xsig pushes as an object for OP_SSHSIGVERIFY.
+func ParseSSHSignature(armored []byte) ([]byte, error) {
+	return crypto.ParseSSHSignatureArmor(armored)
+}
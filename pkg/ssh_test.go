@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"github.com/oreparaz/xsig/internal/crypto"
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSSHSig(t *testing.T) {
+	msg := []byte("hello")
+	_, pk, blob := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeEd25519, []byte("xsig"), msg)
+	publicKey, err := ParseSSHAuthorizedKey([]byte("ssh-ed25519 " + base64.StdEncoding.EncodeToString(pk) + " dev@example.com"))
+	assert.Nil(t, err)
+	armored := "-----BEGIN SSH SIGNATURE-----\n" + base64.StdEncoding.EncodeToString(blob) + "\n-----END SSH SIGNATURE-----\n"
+	sig, err := ParseSSHSignature([]byte(armored))
+	assert.Nil(t, err)
+
+	a := machines.MachineCode{}
+	a.Append(ll.PushLarge(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	b := machines.MachineCode{}
+	for _, in := range ll.SSHSigVerify(0, publicKey, "xsig") {
+		b.Append(in)
+	}
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
+	assert.False(t, EvaluateXSig(xPubKey, xSig, []byte("wrong")))
+
+	_, err = ParseSSHSignature([]byte("-----BEGIN PGP SIGNATURE-----"))
+	assert.NotNil(t, err)
+}
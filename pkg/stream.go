@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"github.com/oreparaz/xsig/internal/crypto"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/pkg/errors"
+	"hash"
+	"io"
+)
+
+// Digests reads r to the end and returns its digest with each of the
+// Hash* algorithms algs. The data is read once, whatever the number of
+// algorithms, and never held in memory as a whole.
+func Digests(r io.Reader, algs ...byte) (map[byte][]byte, error) {
+	hashes := make(map[byte]hash.Hash, len(algs))
+	writers := make([]io.Writer, 0, len(algs))
+	for _, alg := range algs {
+		if hashes[alg] != nil {
+			continue
+		}
+		h := crypto.NewHash(alg)
+		if h == nil {
+			return nil, errors.Errorf("unknown hash algorithm %d", alg)
+		}
+		hashes[alg] = h
+		writers = append(writers, h)
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading message")
+	}
+	digests := make(map[byte][]byte, len(hashes))
+	for alg, h := range hashes {
+		digests[alg] = h.Sum(nil)
+	}
+	return digests, nil
+}
+
+// EvaluateXSigReader is EvaluateXSig for a message read from r, e.g. a disk
+// image too large to load in memory. The message is hashed once, with every
+// algorithm the xpubkey may need, and the policy is evaluated over those
+// digests (see Context.Digests). An xpubkey with an opcode that needs the
+// message itself, such as OP_SIGVERIFYED25519, is an error, as is failing to
+// read r; an xsig that does not satisfy the policy is not.
+func EvaluateXSigReader(XpPubKey []byte, XpSig []byte, r io.Reader) (bool, error) {
+	return EvaluateXSigReaderWithContext(XpPubKey, XpSig, r, &Context{})
+}
+
+// EvaluateXSigReaderWithContext is EvaluateXSigReader with verifier-supplied
+// state. ctx.Xmsg and ctx.Digests are ignored.
+func EvaluateXSigReaderWithContext(XpPubKey []byte, XpSig []byte, r io.Reader, ctx *Context) (bool, error) {
+	mc := machines.MachineCode{}
+	err := mc.Deserialize(XpPubKey, machines.CodeTypeXPublicKey)
+	if err != nil {
+		return false, errors.Wrapf(err, "xpubkey")
+	}
+	algs, err := mc.DigestAlgorithms()
+	if err != nil {
+		return false, errors.Wrapf(err, "xpubkey")
+	}
+	digests, err := Digests(r, algs...)
+	if err != nil {
+		return false, err
+	}
+	prehashed := *ctx
+	prehashed.Xmsg = nil
+	prehashed.Digests = digests
+	return EvaluateXSigWithContext(XpPubKey, XpSig, &prehashed), nil
+}
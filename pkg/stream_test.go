@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"bytes"
+	"github.com/oreparaz/xsig/internal/crypto"
+	ll "github.com/oreparaz/xsig/internal/lowlevel"
+	machines "github.com/oreparaz/xsig/internal/machine"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDigests(t *testing.T) {
+	msg := bytes.Repeat([]byte("0123456789"), 100000)
+	digests, err := Digests(iotest.OneByteReader(bytes.NewReader(msg[:1000])), HashSHA256, HashSHA3_256, HashSHA256)
+	assert.Nil(t, err)
+	assert.Equal(t, map[byte][]byte{
+		HashSHA256:   crypto.Digest(HashSHA256, msg[:1000]),
+		HashSHA3_256: crypto.Digest(HashSHA3_256, msg[:1000]),
+	}, digests)
+
+	digests, err = Digests(bytes.NewReader(msg), HashSHA384, HashSHA512)
+	assert.Nil(t, err)
+	assert.Equal(t, crypto.Digest(HashSHA384, msg), digests[HashSHA384])
+	assert.Equal(t, crypto.Digest(HashSHA512, msg), digests[HashSHA512])
+
+	_, err = Digests(bytes.NewReader(msg), 9)
+	assert.NotNil(t, err)
+	_, err = Digests(iotest.ErrReader(io.ErrUnexpectedEOF), HashSHA256)
+	assert.NotNil(t, err)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestEvaluateXSigReader(t *testing.T) {
+	msg := bytes.Repeat([]byte("disk image "), 200000)
+	_, pk1, sig1 := crypto.HelperVerifyData(msg)
+	_, pk2, _ := crypto.HelperVerifyData(msg)
+	_, pk3, sig3 := crypto.HelperVerifyData(msg)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig1))
+	a.Append(ll.Push(sig3))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk1))
+	b.Append(ll.Push(pk2))
+	b.Append(ll.Push(pk3))
+	b.Append(ll.Push1(2))
+	b.Append(ll.Push1(3))
+	b.Append(ll.MultisigVerify())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	r := &countingReader{r: bytes.NewReader(msg)}
+	ok, err := EvaluateXSigReader(xPubKey, xSig, r)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, len(msg), r.n)
+
+	ok, err = EvaluateXSigReader(xPubKey, xSig, bytes.NewReader(msg[1:]))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	revoked := &Context{Revoked: ll.RevocationSet{crypto.KeyFingerprint(pk1): true}}
+	ok, err = EvaluateXSigReaderWithContext(xPubKey, xSig, bytes.NewReader(msg), revoked)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, err = EvaluateXSigReader(xPubKey, xSig, iotest.ErrReader(io.ErrUnexpectedEOF))
+	assert.NotNil(t, err)
+	_, err = EvaluateXSigReader(xSig, xSig, bytes.NewReader(msg))
+	assert.NotNil(t, err)
+}
+
+func TestEvaluateXSigReader_Digests(t *testing.T) {
+	// a SHA-384 signature and an sshsig, which names SHA-512, over the same
+	// stream
+	msg := bytes.Repeat([]byte{0x5a}, 1<<20)
+	pk, sig := crypto.HelperVerifyDataDigest(crypto.KeyTypeP384, HashSHA384, msg)
+	_, sshPK, sshSig := crypto.HelperVerifyDataSSH(crypto.SSHKeyTypeECDSAP256, []byte("xsig"), msg)
+
+	a := machines.MachineCode{}
+	a.Append(ll.PushLarge(sshSig))
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	b := machines.MachineCode{}
+	for _, in := range ll.SigVerifyHash(crypto.KeyTypeP384, HashSHA384, pk) {
+		b.Append(in)
+	}
+	b.Append(ll.ToAltStack())
+	for _, in := range ll.SSHSigVerify(0, sshPK, "xsig") {
+		b.Append(in)
+	}
+	b.Append(ll.FromAltStack())
+	b.Append(ll.And())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	ok, err := EvaluateXSigReader(xPubKey, xSig, bytes.NewReader(msg))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
+
+	msg[len(msg)-1] ^= 1
+	ok, err = EvaluateXSigReader(xPubKey, xSig, bytes.NewReader(msg))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestEvaluateXSigReader_NeedsMessage(t *testing.T) {
+	msg := []byte("hello")
+	_, pk, sig := crypto.HelperVerifyDataFROST(2, 2, msg)
+
+	a := machines.MachineCode{}
+	a.Append(ll.Push(sig))
+	xSig := a.SerializeUnchecked(machines.CodeTypeXSig)
+
+	b := machines.MachineCode{}
+	b.Append(ll.Push(pk))
+	b.Append(ll.SignatureVerifyEd25519())
+	xPubKey := b.SerializeUnchecked(machines.CodeTypeXPublicKey)
+
+	assert.True(t, EvaluateXSig(xPubKey, xSig, msg))
+	_, err := EvaluateXSigReader(xPubKey, xSig, bytes.NewReader(msg))
+	assert.NotNil(t, err)
+}